@@ -0,0 +1,42 @@
+package client
+
+import "context"
+
+// AccountSpec describes an account to create.
+type AccountSpec struct {
+	RootXPubs []string               `json:"root_xpubs"`
+	Quorum    int                    `json:"quorum"`
+	Alias     string                 `json:"alias,omitempty"`
+	Tags      map[string]interface{} `json:"tags,omitempty"`
+
+	// ClientToken, if set, makes creation idempotent:
+	// creating another account with the same token
+	// returns the first one.
+	ClientToken string `json:"client_token,omitempty"`
+}
+
+// Account is an account in the core.
+type Account struct {
+	ID     string                 `json:"id"`
+	Alias  string                 `json:"alias"`
+	Keys   []AccountKey           `json:"keys"`
+	Quorum int                    `json:"quorum"`
+	Tags   map[string]interface{} `json:"tags"`
+}
+
+// AccountKey is one of an account's keys.
+type AccountKey struct {
+	RootXPub              string   `json:"root_xpub"`
+	AccountXPub           string   `json:"account_xpub"`
+	AccountDerivationPath [][]byte `json:"account_derivation_path"`
+}
+
+// CreateAccount creates an account.
+func (c *Client) CreateAccount(ctx context.Context, spec *AccountSpec) (*Account, error) {
+	acc := new(Account)
+	err := c.callOne(ctx, "/create-account", []*AccountSpec{spec}, acc)
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
@@ -0,0 +1,182 @@
+// Package client is a Go client for the Chain Core API.
+//
+// A Client performs each call as a JSON POST request,
+// authenticating with its access token and retrying
+// network failures and errors the core reports as temporary.
+// Endpoints that take a batch of requests are exposed
+// as methods operating on a single item.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"chain/errors"
+)
+
+// Parameters of the exponential backoff between retries.
+const (
+	DefaultMaxRetries = 10
+	retryBaseDelay    = 40 * time.Millisecond
+	retryMaxDelay     = 4 * time.Second
+)
+
+// A Client calls the API of the Chain Core at URL.
+type Client struct {
+	// URL is the base URL of the core,
+	// such as http://localhost:1999.
+	URL string
+
+	// AccessToken, if set, is a client access token
+	// of the form "id:secret".
+	AccessToken string
+
+	// HTTPClient is the HTTP client to use.
+	// If it's nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a failed call is retried.
+	// If it's zero, DefaultMaxRetries is used;
+	// if it's negative, calls are not retried.
+	MaxRetries int
+}
+
+// Error is an error returned by the core.
+type Error struct {
+	// HTTPStatus is the status code of the response
+	// carrying the error. It's zero for an error
+	// returned as one item of a batch response.
+	HTTPStatus int `json:"-"`
+
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Detail    string                 `json:"detail"`
+	Data      map[string]interface{} `json:"data"`
+	Temporary bool                   `json:"temporary"`
+}
+
+func (e *Error) Error() string {
+	s := e.Code + ": " + e.Message
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// Call calls the API endpoint at path with request,
+// decoding the response into response, if it's non-nil.
+// It retries network errors and temporary errors
+// reported by the core.
+func (c *Client) Call(ctx context.Context, path string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err())
+			case <-time.After(backoff(attempt)):
+			}
+		}
+		var retry bool
+		retry, err = c.do(ctx, path, body, response)
+		if err == nil || !retry || attempt >= maxRetries {
+			return err
+		}
+	}
+}
+
+// do makes a single request, reporting whether
+// a failed request may be retried.
+func (c *Client) do(ctx context.Context, path string, body []byte, response interface{}) (retry bool, err error) {
+	u := strings.TrimRight(c.URL, "/") + path
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "chain-sdk-go")
+	if c.AccessToken != "" {
+		toks := strings.SplitN(c.AccessToken, ":", 2)
+		toks = append(toks, "")
+		req.SetBasicAuth(toks[0], toks[1])
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, errors.Wrap(ctx.Err())
+		}
+		return true, errors.Wrap(err, "calling ", path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		apiErr := &Error{HTTPStatus: resp.StatusCode}
+		err = json.NewDecoder(resp.Body).Decode(apiErr)
+		if err != nil || apiErr.Code == "" {
+			// Not an error from the core itself,
+			// such as one from a proxy in front of it.
+			return resp.StatusCode >= 500, fmt.Errorf("%s responded %s", path, resp.Status)
+		}
+		return apiErr.Temporary, apiErr
+	}
+	if response == nil || resp.StatusCode == http.StatusNoContent {
+		return false, nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(response)
+	return false, errors.Wrap(err, "decoding response from ", path)
+}
+
+// callOne calls a batch endpoint at path with a batch
+// consisting of request alone (request must be a
+// one-element slice or a struct holding one), and decodes
+// the single item of the response into response.
+func (c *Client) callOne(ctx context.Context, path string, request, response interface{}) error {
+	var items []json.RawMessage
+	err := c.Call(ctx, path, request, &items)
+	if err != nil {
+		return err
+	}
+	if len(items) != 1 {
+		return fmt.Errorf("%s returned %d items, want 1", path, len(items))
+	}
+	return decodeItem(items[0], response)
+}
+
+// decodeItem decodes an item of a batch response into v,
+// or returns the error it holds.
+func decodeItem(item json.RawMessage, v interface{}) error {
+	var apiErr Error
+	err := json.Unmarshal(item, &apiErr)
+	if err == nil && apiErr.Code != "" {
+		return &apiErr
+	}
+	return errors.Wrap(json.Unmarshal(item, v), "decoding batch item")
+}
+
+func backoff(attempt int) time.Duration {
+	max := retryBaseDelay << uint(attempt-1)
+	if max > retryMaxDelay || max <= 0 {
+		max = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(max))) + 1
+}
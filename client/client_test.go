@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCallRetries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if user, pass, _ := req.BasicAuth(); user != "tok" || pass != "secret" {
+			t.Errorf("got credentials %s:%s, want tok:secret", user, pass)
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"code":"CH000","message":"Chain API Error","temporary":true}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, AccessToken: "tok:secret"}
+	var resp struct{ OK bool }
+	err := c.Call(context.Background(), "/info", nil, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || !resp.OK {
+		t.Errorf("got %d calls and response %+v, want 3 calls and ok", calls, resp)
+	}
+
+	calls = 0
+	c.MaxRetries = 1
+	err = c.Call(context.Background(), "/info", nil, &resp)
+	if apiErr, ok := err.(*Error); !ok || apiErr.Code != "CH000" || apiErr.HTTPStatus != 500 {
+		t.Errorf("got error %v, want CH000 with status 500", err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+}
+
+func TestCallNoRetry(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"code":"CH003","message":"Invalid request body","detail":"unknown field body.x","temporary":false}`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.Call(context.Background(), "/create-account", nil, nil)
+	if apiErr, ok := err.(*Error); !ok || apiErr.Detail != "unknown field body.x" {
+		t.Errorf("got error %v, want CH003", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+func TestBuildSignSubmit(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		var body interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		switch req.URL.Path {
+		case "/build-transaction":
+			io.WriteString(w, `[{"raw_transaction":"07","signing_instructions":[{"position":0}],"local":true}]`)
+		case "/mockhsm/sign-transaction":
+			want := map[string]interface{}{
+				"transactions": []interface{}{map[string]interface{}{
+					"raw_transaction":          "07",
+					"signing_instructions":     []interface{}{map[string]interface{}{"position": 0.0}},
+					"local":                    true,
+					"allow_additional_actions": false,
+				}},
+				"xpubs": []interface{}{"xpub1"},
+			}
+			if !reflect.DeepEqual(body, want) {
+				t.Errorf("got sign request %v, want %v", body, want)
+			}
+			io.WriteString(w, `[{"raw_transaction":"0701","signing_instructions":[],"local":true}]`)
+		case "/submit-transaction":
+			io.WriteString(w, `[{"code":"CH735","message":"Transaction rejected","temporary":false}]`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{URL: srv.URL}
+	tpl, err := c.BuildTransaction(ctx, &BuildRequest{Actions: []map[string]interface{}{{"type": "issue"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl, err = c.SignTransaction(ctx, tpl, []string{"xpub1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.RawTransaction != "0701" {
+		t.Errorf("got signed transaction %s, want 0701", tpl.RawTransaction)
+	}
+	_, err = c.Submit(ctx, tpl)
+	if apiErr, ok := err.(*Error); !ok || apiErr.Code != "CH735" {
+		t.Errorf("got error %v, want CH735", err)
+	}
+
+	want := []string{"/build-transaction", "/mockhsm/sign-transaction", "/submit-transaction"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var q Query
+		json.NewDecoder(req.Body).Decode(&q)
+		if q.Filter != "alias=$1" {
+			t.Errorf("got filter %q, want alias=$1", q.Filter)
+		}
+		after, _ := strconv.Atoi(q.After)
+		var p page
+		for i := after; i < after+2 && i < 5; i++ {
+			p.Items = append(p.Items, json.RawMessage(strconv.Itoa(i)))
+		}
+		p.Next = q
+		p.Next.After = strconv.Itoa(after + 2)
+		p.LastPage = after+2 >= 5
+		json.NewEncoder(w).Encode(p)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	var got []string
+	err := c.Query(context.Background(), "/list-accounts", Query{Filter: "alias=$1"}, func(item json.RawMessage) error {
+		got = append(got, string(item))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0", "1", "2", "3", "4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got items %v, want %v", got, want)
+	}
+}
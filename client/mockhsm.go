@@ -0,0 +1,22 @@
+package client
+
+import "context"
+
+// Key is a key pair held by the core's MockHSM.
+type Key struct {
+	Alias string `json:"alias"`
+	XPub  string `json:"xpub"`
+}
+
+// CreateKey creates a key in the core's MockHSM.
+// Sign templates with it using SignTransaction.
+func (c *Client) CreateKey(ctx context.Context, alias string) (*Key, error) {
+	key := new(Key)
+	err := c.Call(ctx, "/mockhsm/create-key", struct {
+		Alias string `json:"alias,omitempty"`
+	}{alias}, key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
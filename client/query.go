@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Query selects items from a list endpoint,
+// such as /list-transactions or /list-accounts.
+type Query struct {
+	Filter       string        `json:"filter,omitempty"`
+	FilterParams []interface{} `json:"filter_params,omitempty"`
+
+	// PageSize is how many items to fetch per request.
+	// If it's zero, the core's default is used.
+	PageSize int `json:"page_size,omitempty"`
+
+	// After is the opaque cursor returned by the core
+	// with each page. Leave it empty to start at the beginning.
+	After string `json:"after,omitempty"`
+
+	// StartTimeMS and EndTimeMS bound the time range
+	// of /list-transactions.
+	StartTimeMS uint64 `json:"start_time,omitempty"`
+	EndTimeMS   uint64 `json:"end_time,omitempty"`
+
+	// TimestampMS selects the point in time
+	// for /list-balances and /list-unspent-outputs.
+	TimestampMS uint64 `json:"timestamp,omitempty"`
+}
+
+type page struct {
+	Items    []json.RawMessage `json:"items"`
+	Next     Query             `json:"next"`
+	LastPage bool              `json:"last_page"`
+}
+
+// Query calls the list endpoint at path with q,
+// fetching page after page, and calls f with each item
+// in turn until there are no more items or f returns
+// an error, which Query then returns.
+//
+// For example, to print the ID of each transaction:
+//
+//	err := c.Query(ctx, "/list-transactions", client.Query{}, func(item json.RawMessage) error {
+//		var tx struct{ ID string }
+//		err := json.Unmarshal(item, &tx)
+//		fmt.Println(tx.ID)
+//		return err
+//	})
+func (c *Client) Query(ctx context.Context, path string, q Query, f func(json.RawMessage) error) error {
+	for {
+		var p page
+		err := c.Call(ctx, path, q, &p)
+		if err != nil {
+			return err
+		}
+		for _, item := range p.Items {
+			err = f(item)
+			if err != nil {
+				return err
+			}
+		}
+		if p.LastPage || len(p.Items) == 0 {
+			return nil
+		}
+		q = p.Next
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	chainjson "chain/encoding/json"
+)
+
+// BuildRequest describes a transaction to build.
+type BuildRequest struct {
+	// BaseTransaction, if set, is a partial transaction,
+	// in hex, to which the actions are added.
+	BaseTransaction string `json:"base_transaction,omitempty"`
+
+	// Actions are the actions making up the transaction,
+	// such as {"type": "spend_account", "account_alias": "alice",
+	// "asset_alias": "gold", "amount": 10}.
+	Actions []map[string]interface{} `json:"actions"`
+
+	// TTL is how long the built transaction remains valid.
+	// If it's zero, the core's default is used.
+	TTL chainjson.Duration `json:"ttl"`
+}
+
+// Template is a transaction template: a transaction
+// along with the instructions for signing it.
+// Its signing instructions are kept in the form the
+// core returns them, and are interpreted only by signers.
+type Template struct {
+	RawTransaction      string            `json:"raw_transaction"`
+	SigningInstructions []json.RawMessage `json:"signing_instructions"`
+	Local               bool              `json:"local"`
+	AllowAdditional     bool              `json:"allow_additional_actions"`
+	ExpiresAt           *time.Time        `json:"expires_at,omitempty"`
+}
+
+// SubmitResponse is the result of submitting a transaction.
+type SubmitResponse struct {
+	ID string `json:"id"`
+}
+
+// BuildTransaction builds a transaction template from req.
+func (c *Client) BuildTransaction(ctx context.Context, req *BuildRequest) (*Template, error) {
+	tpl := new(Template)
+	err := c.callOne(ctx, "/build-transaction", []*BuildRequest{req}, tpl)
+	if err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// SignTransaction signs tpl with those of xpubs
+// whose private keys are held by the core's MockHSM,
+// returning the signed template.
+func (c *Client) SignTransaction(ctx context.Context, tpl *Template, xpubs []string) (*Template, error) {
+	req := struct {
+		Transactions []*Template `json:"transactions"`
+		XPubs        []string    `json:"xpubs"`
+	}{[]*Template{tpl}, xpubs}
+	signed := new(Template)
+	err := c.callOne(ctx, "/mockhsm/sign-transaction", req, signed)
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// Submit submits the signed transaction in tpl to the network
+// and waits for it to be confirmed in a block.
+func (c *Client) Submit(ctx context.Context, tpl *Template) (*SubmitResponse, error) {
+	req := struct {
+		Transactions []*Template `json:"transactions"`
+	}{[]*Template{tpl}}
+	resp := new(SubmitResponse)
+	err := c.callOne(ctx, "/submit-transaction", req, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,90 @@
+/*
+
+Command bcvectors prints the test vectors
+of package chain/protocol/bc/bctest as JSON.
+
+Usage:
+
+	bcvectors [-o file] [-check file]
+
+Each vector has a name and a type. Vectors of type "tx" and
+"block" have the hex serialization; valid ones also list the
+hashes computed from it, and invalid ones are marked "invalid"
+and must fail to deserialize. Vectors of type "asset_id" have
+an issuance program, initial block hash, and VM version, and
+the asset ID computed from them. Vectors of type "merkle_root"
+have a list of serialized transactions and their Merkle root.
+Implementations of the protocol in other languages can use the
+output to check their serialization and hashing.
+
+To regenerate the golden file checked by the tests of package bc,
+run go generate in chain/protocol/bc/bctest, or:
+
+	bcvectors -o $CHAIN/protocol/bc/testdata/vectors.json
+
+Flag -o writes the vectors to the named file
+instead of standard output.
+
+Flag -check reads vectors from the named file instead,
+and checks them against this implementation.
+
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"chain/protocol/bc/bctest"
+)
+
+var (
+	check = flag.String("check", "", "check the vectors in `file`")
+	out   = flag.String("o", "", "write the vectors to `file`")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("bcvectors: ")
+	flag.Parse()
+
+	if *check != "" {
+		f, err := os.Open(*check)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var vectors []*bctest.Vector
+		err = json.NewDecoder(f).Decode(&vectors)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var failed bool
+		for _, v := range vectors {
+			if err := v.Check(); err != nil {
+				log.Println(err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	b, err := json.MarshalIndent(bctest.Vectors(), "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+	b = append(b, '\n')
+	if *out != "" {
+		err = ioutil.WriteFile(*out, b, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	os.Stdout.Write(b)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"chain/core/blockfile"
+	"chain/core/config"
+	"chain/core/txdb"
+	"chain/database/sql"
+	"chain/protocol"
+	"chain/protocol/mempool"
+)
+
+func exportBlocks(db *sql.DB, args []string) {
+	const usage = "usage: corectl export-blocks [-from height] [-to height] file"
+	var flags flag.FlagSet
+	flagFrom := flags.Uint64("from", 1, "export blocks from `height`")
+	flagTo := flags.Uint64("to", 0, "export blocks through `height`; the default is the latest block")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fatalln(usage)
+	}
+
+	f, err := os.Create(flags.Arg(0))
+	if err != nil {
+		fatalln("error:", err)
+	}
+	ctx := context.Background()
+	if db == nil {
+		req := struct {
+			From uint64 `json:"from_height"`
+			To   uint64 `json:"to_height"`
+		}{*flagFrom, *flagTo}
+		var resp io.ReadCloser
+		resp, err = client.CallRaw(ctx, "/export-blocks", req)
+		if err == nil {
+			_, err = io.Copy(f, resp)
+			resp.Close()
+		}
+	} else {
+		store := txdb.NewStore(db)
+		to := *flagTo
+		if to == 0 {
+			to, err = store.Height(ctx)
+			if err != nil {
+				fatalln("error:", err)
+			}
+		}
+		err = blockfile.Export(ctx, store, f, *flagFrom, to)
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		fatalln("error:", err)
+	}
+}
+
+func importBlocks(db *sql.DB, args []string) {
+	const usage = "usage: corectl import-blocks file"
+	if len(args) != 1 {
+		fatalln(usage)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		fatalln("error:", err)
+	}
+	r, err := blockfile.NewReader(f, info.Size())
+	if err != nil {
+		fatalln("error:", err)
+	}
+
+	ctx := context.Background()
+	conf, err := config.Load(ctx, db)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	if conf == nil {
+		fatalln("error: the core is not configured")
+	}
+	c, err := protocol.NewChain(ctx, conf.BlockchainID, txdb.NewStore(db), mempool.New(), nil)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	_, _, err = c.Recover(ctx)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	n, err := blockfile.Import(ctx, c, r)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Printf("imported %d blocks; height is now %d\n", n, c.Height())
+}
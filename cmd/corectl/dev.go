@@ -9,13 +9,14 @@ import (
 	"chain/database/sql"
 )
 
-func reset(db *sql.DB, args []string) {
-	if len(args) != 0 {
-		fatalln("error: reset takes no args")
-	}
-
+func resetLocal(db *sql.DB, sandbox bool) {
 	ctx := context.Background()
-	err := coreunsafe.ResetEverything(ctx, db)
+	var err error
+	if sandbox {
+		err = coreunsafe.ResetSandbox(ctx, db)
+	} else {
+		err = coreunsafe.ResetEverything(ctx, db)
+	}
 	if err != nil {
 		fatalln("error:", err)
 	}
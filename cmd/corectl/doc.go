@@ -7,6 +7,14 @@ variable; the default is to connect to the "core" database on localhost.
 
 The config commands initialize the schema if necessary.
 
+To run commands through the API of a running core instead of
+connecting to its database, set CORE_URL to the core's URL and
+CORE_ACCESS_TOKEN to a client access token. Commands config,
+config-generator, create-token, export-blocks, and reset work either
+way; create-block-keypair, import-blocks, and restore need the database,
+and snapshot and status need the API. Configuring or resetting a core through
+its API restarts it.
+
 Config Generator
 
 Subcommand 'config-generator' configures a new core as a generator.
@@ -48,14 +56,79 @@ Subcommand 'create-token' generates a new access token with the given name.
 Flag -net means to create a network token,
 otherwise it will create a client token.
 
-    corectl create-token [-net] [name]
+    corectl create-token [-net] [-q quota] [name]
+
+Flag -q sets the token's priority quota: the number of transactions
+submitted with it that can go in the high-priority lane of each block.
+The default is 0, meaning the token cannot submit high-priority transactions.
+
+Export and Import Blocks
+
+Subcommand 'export-blocks' writes a range of blocks to a block file,
+a compact flat file of raw blocks with an index (see package
+chain/core/blockfile). Subcommand 'import-blocks' validates the
+blocks in such a file and adds them to the blockchain of another
+core, so that a new core can be seeded from a copy of the file in
+object storage rather than downloading each block from the generator.
+
+    corectl export-blocks [-from height] [-to height] file
+    corectl import-blocks file
+
+Flags -from and -to set the first and last heights to export;
+the defaults are the first and latest blocks.
+
+Configure the new core as before, but stop it before it downloads
+the blockchain, then import. Import skips blocks the core already
+has, and fails if the file doesn't continue its blockchain.
 
 Reset
 
 Subcommand 'reset' resets the database so the Chain Core can be configured again.
 It deletes all data.
 
-    corectl reset
+    corectl reset [-sandbox]
+
+Flag -sandbox instead deletes only the blockchain data, keeping
+the core configured as a generator with a new blockchain. It is
+a quick way to give each test run a fresh blockchain.
+
+Restore
+
+Subcommand 'restore' rebuilds the accounts of a core whose database
+was lost, from their keys and the blockchain. Configure a new core
+as before, let it download the blockchain, stop it, then run:
+
+    corectl restore [-gap n] [accounts.json]
+
+The file, or standard input if none is given, holds a JSON array
+of accounts, each with its root_xpubs, quorum, and key_index (the
+signer index in the first element of its account_derivation_path),
+and optionally its id, alias, tags, watch_only, and parent_id.
+Accounts without an id get the one derived from their keys, as when
+created with deterministic_id, and so does their key_index if it is
+omitted.
+
+Restore finds each account's control programs on the blockchain and
+makes the core index it again from the start when it next runs,
+rebuilding account balances and unspent outputs. Flag -gap sets how
+many unused control programs to derive before giving up on a block
+of indexes; the default is 1000.
+
+Snapshot
+
+Subcommand 'snapshot' makes the core save a snapshot of its
+blockchain state now, rather than at the next periodic snapshot.
+New cores joining the network bootstrap from the latest one.
+
+    corectl snapshot
+
+Status
+
+Subcommand 'status' prints the core's configuration and sync
+status: its block height, the generator's block height, and the
+progress of any snapshot it is downloading.
+
+    corectl status
 
 */
 package main
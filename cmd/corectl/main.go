@@ -15,6 +15,7 @@ import (
 	"chain/core/config"
 	"chain/core/migrate"
 	"chain/core/mockhsm"
+	"chain/core/rpc"
 	"chain/crypto/ed25519"
 	"chain/database/sql"
 	"chain/env"
@@ -23,33 +24,47 @@ import (
 
 // config vars
 var (
-	dbURL = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
+	dbURL       = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
+	coreURL     = env.String("CORE_URL", "")
+	accessToken = env.String("CORE_ACCESS_TOKEN", "")
 )
 
+// client is the API client of the core at CORE_URL.
+// It is nil when corectl uses the database directly.
+var client *rpc.Client
+
 // We collect log output in this buffer,
 // and display it only when there's an error.
 var logbuf bytes.Buffer
 
+// Commands run against the database, against the API
+// of the core at CORE_URL, or either.
+const (
+	local = 1 << iota
+	remote
+)
+
 type command struct {
-	f func(*sql.DB, []string)
+	f     func(*sql.DB, []string)
+	modes int
 }
 
 var commands = map[string]*command{
-	"config-generator":     {configGenerator},
-	"create-block-keypair": {createBlockKeyPair},
-	"create-token":         {createToken},
-	"config":               {configNongenerator},
-	"reset":                {reset},
+	"config-generator":     {configGenerator, local | remote},
+	"create-block-keypair": {createBlockKeyPair, local},
+	"create-token":         {createToken, local | remote},
+	"export-blocks":        {exportBlocks, local | remote},
+	"import-blocks":        {importBlocks, local},
+	"config":               {configNongenerator, local | remote},
+	"reset":                {reset, local | remote},
+	"restore":              {restore, local},
+	"snapshot":             {snapshot, remote},
+	"status":               {status, remote},
 }
 
 func main() {
 	log.SetOutput(&logbuf)
 	env.Parse()
-	db, err := sql.Open("hapg", *dbURL)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(2)
-	}
 
 	if len(os.Args) < 2 {
 		help(os.Stdout)
@@ -61,6 +76,28 @@ func main() {
 		help(os.Stderr)
 		os.Exit(1)
 	}
+
+	if *coreURL != "" {
+		if cmd.modes&remote == 0 {
+			fatalln("error:", os.Args[1], "needs database access; unset CORE_URL")
+		}
+		client = &rpc.Client{
+			BaseURL:     *coreURL,
+			AccessToken: *accessToken,
+			Username:    "corectl",
+		}
+		cmd.f(nil, os.Args[2:])
+		return
+	}
+	if cmd.modes&local == 0 {
+		fatalln("error:", os.Args[1], "needs the core API; set CORE_URL")
+	}
+
+	db, err := sql.Open("hapg", *dbURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
 	err = migrate.Run(db)
 	if err != nil {
 		fatalln("error: init schema", err)
@@ -69,7 +106,7 @@ func main() {
 }
 
 func configGenerator(db *sql.DB, args []string) {
-	const usage = "usage: corectl config-generator [-s] [-w duration] [quorum] [pubkey url]..."
+	const usage = "usage: corectl config-generator [-s] [-w duration] [-max-block-bytes n] [-max-block-txs n] [quorum] [pubkey url]..."
 	var (
 		quorum  int
 		signers []config.BlockSigner
@@ -79,6 +116,8 @@ func configGenerator(db *sql.DB, args []string) {
 	var flags flag.FlagSet
 	maxIssuanceWindow := flags.Duration("w", 24*time.Hour, "the maximum issuance window `duration` for this generator")
 	isSigner := flags.Bool("s", false, "whether this core is a signer")
+	maxBlockBytes := flags.Uint64("max-block-bytes", 0, "the maximum serialized size of a block, or 0 for no limit")
+	maxBlockTxs := flags.Uint64("max-block-txs", 0, "the maximum number of transactions in a block, or 0 for no limit")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
@@ -123,9 +162,20 @@ func configGenerator(db *sql.DB, args []string) {
 		Quorum:            quorum,
 		Signers:           signers,
 		MaxIssuanceWindow: *maxIssuanceWindow,
+		MaxBlockBytes:     *maxBlockBytes,
+		MaxBlockTxs:       *maxBlockTxs,
 	}
 
 	ctx := context.Background()
+	if client != nil {
+		// The core restarts once configured; see its status
+		// for the new blockchain ID.
+		err = client.Call(ctx, "/configure", conf, nil)
+		if err != nil {
+			fatalln("error:", err)
+		}
+		return
+	}
 	err = config.Configure(ctx, db, conf)
 	if err != nil {
 		fatalln("error:", err)
@@ -150,9 +200,10 @@ func createBlockKeyPair(db *sql.DB, args []string) {
 }
 
 func createToken(db *sql.DB, args []string) {
-	const usage = "usage: corectl create-token [-net] [name]"
+	const usage = "usage: corectl create-token [-net] [-q quota] [name]"
 	var flags flag.FlagSet
 	flagNet := flags.Bool("net", false, "create a network token instead of client")
+	flagQ := flags.Int("q", 0, "number of high-priority txs per block allowed for this token")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
@@ -164,12 +215,24 @@ func createToken(db *sql.DB, args []string) {
 		fatalln(usage)
 	}
 
-	accessTokens := &accesstoken.CredentialStore{DB: db}
 	typ := map[bool]string{true: "network", false: "client"}[*flagNet]
-	tok, err := accessTokens.Create(context.Background(), args[0], typ)
+	ctx := context.Background()
+	if client != nil {
+		createTokenRemote(ctx, args[0], typ, *flagQ)
+		return
+	}
+
+	accessTokens := &accesstoken.CredentialStore{DB: db}
+	tok, err := accessTokens.Create(ctx, args[0], typ)
 	if err != nil {
 		fatalln("error:", err)
 	}
+	if *flagQ != 0 {
+		err = accessTokens.SetPriorityQuota(ctx, tok.ID, *flagQ)
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}
 	fmt.Println(tok.Token)
 }
 
@@ -200,12 +263,36 @@ func configNongenerator(db *sql.DB, args []string) {
 	conf.BlockPub = *flagK
 
 	ctx := context.Background()
-	err = config.Configure(ctx, db, &conf)
+	if client != nil {
+		err = client.Call(ctx, "/configure", &conf, nil)
+	} else {
+		err = config.Configure(ctx, db, &conf)
+	}
 	if err != nil {
 		fatalln("error:", err)
 	}
 }
 
+func reset(db *sql.DB, args []string) {
+	const usage = "usage: corectl reset [-sandbox]"
+	var flags flag.FlagSet
+	flagSandbox := flags.Bool("sandbox", false, "keep the configuration and start a new blockchain")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		fatalln(usage)
+	}
+	if client != nil {
+		resetRemote(context.Background(), *flagSandbox)
+		return
+	}
+	resetLocal(db, *flagSandbox)
+}
+
 func fatalln(v ...interface{}) {
 	io.Copy(os.Stderr, &logbuf)
 	fmt.Fprintln(os.Stderr, v...)
@@ -2,8 +2,8 @@
 
 package main
 
-import "database/sql"
+import "chain/database/sql"
 
-func reset(db *sql.DB, args []string) {
+func resetLocal(db *sql.DB, sandbox bool) {
 	fatalln("error: reset disabled in prod build")
 }
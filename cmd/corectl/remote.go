@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"chain/core/accesstoken"
+	"chain/database/sql"
+)
+
+// This file holds the commands, and parts of commands,
+// that use the API of the core at CORE_URL.
+
+func createTokenRemote(ctx context.Context, id, typ string, quota int) {
+	var tok accesstoken.Token
+	req := map[string]string{"id": id, "type": typ}
+	err := client.Call(ctx, "/create-access-token", req, &tok)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	if quota != 0 {
+		req := map[string]interface{}{"id": tok.ID, "priority_quota": quota}
+		err = client.Call(ctx, "/update-access-token", req, nil)
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}
+	fmt.Println(tok.Token)
+}
+
+func resetRemote(ctx context.Context, sandbox bool) {
+	// The core closes the connection and restarts
+	// to delete its data.
+	req := map[string]bool{"everything": !sandbox, "sandbox": sandbox}
+	err := client.Call(ctx, "/reset", req, nil)
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+func snapshot(_ *sql.DB, args []string) {
+	if len(args) != 0 {
+		fatalln("error: snapshot takes no args")
+	}
+	var resp struct{ Height uint64 }
+	err := client.Call(context.Background(), "/create-snapshot", nil, &resp)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Println("snapshot height", resp.Height)
+}
+
+func status(_ *sql.DB, args []string) {
+	if len(args) != 0 {
+		fatalln("error: status takes no args")
+	}
+	var info map[string]interface{}
+	err := client.Call(context.Background(), "/info", nil, &info)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		fatalln("error:", err)
+	}
+	os.Stdout.Write(append(b, '\n'))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"chain/core/account"
+	"chain/core/query"
+	"chain/core/txdb"
+	"chain/database/sql"
+)
+
+func restore(db *sql.DB, args []string) {
+	const usage = "usage: corectl restore [-gap n] [accounts.json]"
+	var flags flag.FlagSet
+	flagGap := flags.Int("gap", 1000, "stop after `n` unused control programs in each block of indexes")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 || *flagGap < 1 {
+		fatalln(usage)
+	}
+
+	// Read the accounts from standard input if no file is given.
+	f := os.Stdin
+	if flags.NArg() == 1 {
+		var err error
+		f, err = os.Open(flags.Arg(0))
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}
+	var accts []account.RestoreAccount
+	err := json.NewDecoder(f).Decode(&accts)
+	f.Close()
+	if err != nil {
+		fatalln("error: reading accounts:", err)
+	}
+
+	ctx := context.Background()
+	accounts := account.NewManager(db, nil, nil)
+	accounts.IndexAccounts(query.NewIndexer(db, nil, nil))
+	n, err := accounts.Restore(ctx, txdb.NewStore(db), accts, *flagGap)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	err = query.Reindex(ctx, db)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Printf("restored %d accounts with %d control programs\n", len(accts), n)
+}
@@ -10,17 +10,30 @@ import (
 	"os"
 
 	"chain/core/coreunsafe"
-	"chain/database/pg"
+	"chain/database/sql"
 	"chain/env"
 	"chain/log"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
 )
 
 var (
 	reset = env.String("RESET", "")
 	prod  = "no"
+
+	// wasmVM enables the experimental WebAssembly VM.
+	// Every core on the network must set it alike.
+	// See vm.EnableWASM.
+	wasmVM = env.Bool("EXPERIMENTAL_WASM_VM", false)
+
+	// confidentialAmounts enables experimental outputs whose
+	// amounts are hidden in commitments. Every core on the
+	// network must set it alike.
+	// See validation.EnableConfidentialAmounts.
+	confidentialAmounts = env.Bool("EXPERIMENTAL_CONFIDENTIAL_AMOUNTS", false)
 )
 
-func resetInDevIfRequested(db pg.DB) {
+func resetInDevIfRequested(db *sql.DB) {
 	if *reset != "" {
 		os.Setenv("RESET", "")
 
@@ -31,6 +44,8 @@ func resetInDevIfRequested(db pg.DB) {
 			err = coreunsafe.ResetBlockchain(ctx, db)
 		case "everything":
 			err = coreunsafe.ResetEverything(ctx, db)
+		case "sandbox":
+			err = coreunsafe.ResetSandbox(ctx, db)
 		default:
 			log.Fatal(ctx, log.KeyError, fmt.Errorf("unrecognized argument to reset: %s", *reset))
 		}
@@ -40,6 +55,15 @@ func resetInDevIfRequested(db pg.DB) {
 	}
 }
 
+func enableExperimentsInDev() {
+	if *wasmVM {
+		vm.EnableWASM()
+	}
+	if *confidentialAmounts {
+		validation.EnableConfidentialAmounts()
+	}
+}
+
 func authLoopbackInDev(req *http.Request) bool {
 	// Allow connections from the local host.
 	a, err := net.ResolveTCPAddr("tcp", req.RemoteAddr)
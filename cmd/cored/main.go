@@ -13,6 +13,8 @@ import (
 	"net/url"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kr/secureheader"
@@ -20,36 +22,58 @@ import (
 	"chain/core"
 	"chain/core/accesstoken"
 	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/asset"
 	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/contract"
+	"chain/core/deadletter"
+	"chain/core/events"
+	"chain/core/explorer"
 	"chain/core/fetch"
+	"chain/core/forward"
+	"chain/core/freeze"
 	"chain/core/generator"
+	"chain/core/htlc"
 	"chain/core/leader"
 	"chain/core/migrate"
+	"chain/core/mirror"
 	"chain/core/mockhsm"
 	"chain/core/pin"
 	"chain/core/query"
+	"chain/core/refdata"
+	"chain/core/risk"
 	"chain/core/rpc"
+	"chain/core/schedule"
+	"chain/core/signreq"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
 	"chain/crypto/ed25519"
+	"chain/crypto/kms"
+	"chain/database/pg"
 	"chain/database/sql"
 	"chain/env"
 	"chain/errors"
 	chainlog "chain/log"
 	"chain/log/rotation"
 	"chain/log/splunk"
+	"chain/net/http/cors"
 	"chain/net/http/limit"
+	"chain/net/ntp"
 	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/mempool"
+	"chain/protocol/standard"
 )
 
 const (
 	httpReadTimeout  = 2 * time.Minute
 	httpWriteTimeout = time.Hour
+
+	// rpcKeyAlias is the alias, in the mock HSM, of the key
+	// this core signs its requests to other cores with.
+	rpcKeyAlias = "_CHAIN_CORE_RPC_KEY"
 )
 
 var (
@@ -63,11 +87,125 @@ var (
 	logSize       = env.Int("LOGSIZE", 5e6) // 5MB
 	logCount      = env.Int("LOGCOUNT", 9)
 	logQueries    = env.Bool("LOG_QUERIES", false)
+	logLevel      = env.String("LOG_LEVEL", "info")     // error, info, or debug; see /set-log-level
 	maxDBConns    = env.Int("MAXDBCONNS", 10)           // set to 100 in prod
 	rpsToken      = env.Int("RATELIMIT_TOKEN", 0)       // reqs/sec
 	rpsRemoteAddr = env.Int("RATELIMIT_REMOTE_ADDR", 0) // reqs/sec
 	indexTxs      = env.Bool("INDEX_TRANSACTIONS", true)
 
+	// How long responses to requests with an Idempotency-Key
+	// header are saved for retries.
+	idempotencyRetention = env.Duration("IDEMPOTENCY_KEY_RETENTION", 24*time.Hour)
+
+	// partitionBlocks, if set, is the number of blocks per partition
+	// of the blocks and query index tables. See pg.Partitions.
+	partitionBlocks = env.Int("PARTITION_BLOCKS", 0)
+
+	// pollNotifications, if set, sends notifications between
+	// processes through a table instead of LISTEN and NOTIFY.
+	// See pg.PollNotifications.
+	pollNotifications = env.Bool("DATABASE_POLL_NOTIFICATIONS", false)
+
+	// finalityDepth, if set, is the number of confirmations
+	// after which a block is final. checkpoints is a
+	// comma-separated list of height:hash pairs naming blocks
+	// that are final. See protocol.Chain.FinalHeight.
+	finalityDepth = env.Int("FINALITY_DEPTH", 0)
+	checkpoints   = env.String("CHECKPOINTS", "")
+
+	// Retention of query index entries; zero keeps them forever.
+	// See query.Retention.
+	txRetention     = env.Duration("INDEX_TX_RETENTION", 0)
+	outputRetention = env.Duration("INDEX_SPENT_OUTPUT_RETENTION", 0)
+
+	// signerURL, if set, is the address of a cmd/signerd
+	// holding this block signer's key.
+	signerURL = env.String("SIGNER_SERVICE_URL", "")
+
+	// blockSignerKMSKey, if set, is the URI of the key in a
+	// cloud KMS this block signer signs with. See package kms.
+	blockSignerKMSKey = env.String("BLOCK_SIGNER_KMS_KEY", "")
+
+	// mockHSMKMSKeys are URIs of keys in a cloud KMS the
+	// mock HSM signs for in place of keys of its own.
+	mockHSMKMSKeys = env.StringSlice("MOCKHSM_KMS_KEYS")
+
+	// Local rules a block signer applies before signing a
+	// block. See blocksigner.Policy.
+	signerMaxTxs      = env.Int("SIGNER_MAX_BLOCK_TXS", 0)
+	signerGenerators  = env.StringSlice("SIGNER_GENERATOR_TOKENS")
+	signerMinInterval = env.Duration("SIGNER_MIN_INTERVAL", 0)
+	signerEmbargoed   = env.StringSlice("SIGNER_EMBARGOED_ASSETS")
+
+	// txTTL is how long built transactions remain valid
+	// when build requests don't give a ttl.
+	txTTL = env.Duration("TX_TTL", 5*time.Minute)
+
+	// Backpressure on submissions to a generator:
+	// above maxPendingTxs transactions waiting for a block,
+	// it refuses more, telling clients to retry after
+	// submitRetryAfter. Zero means no limit.
+	maxPendingTxs    = env.Int("MAX_PENDING_TXS", 0)
+	submitRetryAfter = env.Duration("SUBMIT_RETRY_AFTER", 5*time.Second)
+
+	// Limits on chains of transactions spending outputs of
+	// pending ones, in a generator's pool. See mempool.MemPool.
+	maxChainDepth   = env.Int("MAX_UNCONFIRMED_CHAIN_DEPTH", mempool.DefaultMaxChainDepth)
+	maxOrphanBlocks = env.Int("MAX_ORPHAN_BLOCKS", mempool.DefaultMaxOrphanBlocks)
+	maxOrphans      = env.Int("MAX_ORPHAN_TXS", mempool.DefaultMaxOrphans)
+
+	// Standardness policy for transactions submitted to
+	// a generator. See standard.Policy.
+	maxWitnessSize = env.Int("STANDARD_MAX_WITNESS_SIZE", 0)
+	knownPrograms  = env.Bool("STANDARD_KNOWN_PROGRAMS", false)
+	minAmount      = env.Int("STANDARD_MIN_AMOUNT", 0)
+
+	// minAmounts is a comma-separated list of asset:amount
+	// pairs overriding minAmount for particular assets.
+	minAmounts = env.String("STANDARD_MIN_AMOUNTS", "")
+
+	// maxClockSkew is how far ahead of the local clock a block
+	// may be timestamped for this core to sign it. ntpServer,
+	// if set, is asked for the time at startup, to report a
+	// local clock off by more than that. See checkClock.
+	maxClockSkew = env.Duration("MAX_CLOCK_SKEW", protocol.DefaultMaxClockSkew)
+	ntpServer    = env.String("NTP_SERVER", ntp.DefaultServer)
+
+	// publicExplorer, if set, serves the block explorer
+	// endpoints (/explorer/...) without an access token.
+	publicExplorer = env.Bool("PUBLIC_EXPLORER", false)
+
+	// corsOrigins lists the origins of browser pages, such as
+	// internal dashboards, that may call the API, or "*" for
+	// any origin. See package cors.
+	corsOrigins = env.StringSlice("CORS_ALLOWED_ORIGINS")
+
+	// pathPrefix, if set, is the path under which a reverse
+	// proxy serves the API, such as "/chain". Requests whose
+	// paths start with it are served as if it were absent.
+	pathPrefix = env.String("PATH_PREFIX", "")
+
+	// mirrorOutbox, if set, writes each confirmed block to
+	// the mirror_outbox table for an external relay.
+	// See mirror.Outbox.
+	mirrorOutbox = env.Bool("MIRROR_OUTBOX", false)
+
+	// Publishing of block and transaction events; see package
+	// events. At most one of the NATS and Kafka URLs may be set.
+	eventsNATS        = env.String("EVENTS_NATS_URL", "")
+	eventsKafkaREST   = env.String("EVENTS_KAFKA_REST_URL", "")
+	eventsBlockTopic  = env.String("EVENTS_BLOCK_TOPIC", "chain.blocks")
+	eventsTxTopic     = env.String("EVENTS_TX_TOPIC", "chain.transactions")
+	eventsSignTopic   = env.String("EVENTS_SIGNING_TOPIC", "chain.signing")
+	eventsPartitionBy = env.String("EVENTS_PARTITION_BY", events.ByAsset)
+
+	// Risk scoring of indexed transactions, and, if
+	// riskSubmissions is set, of submitted ones; see
+	// package risk. With no scorer URL, nothing is scored.
+	riskScorerURL   = env.String("RISK_SCORER_URL", "")
+	riskScorerToken = env.String("RISK_SCORER_TOKEN", "")
+	riskSubmissions = env.Bool("RISK_SCORE_SUBMISSIONS", false)
+
 	// build vars; initialized by the linker
 	buildTag    = "dev"
 	buildCommit = "?"
@@ -78,6 +216,11 @@ var (
 
 	blockPeriod              = time.Second
 	expireReservationsPeriod = time.Second
+	scheduledPaymentsPeriod  = time.Second
+	forwardRetryPeriod       = time.Second
+	expireSigningPeriod      = time.Second
+	rescanPeriod             = time.Second
+	pruneIndexPeriod         = time.Hour
 )
 
 func init() {
@@ -95,8 +238,21 @@ func main() {
 
 	ctx := context.Background()
 	env.Parse()
+	enableExperimentsInDev()
 
+	level, err := chainlog.ParseLevel(*logLevel)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	chainlog.SetLevel(level)
 	sql.EnableQueryLogging(*logQueries)
+	pg.PollNotifications = *pollNotifications
+	if *partitionBlocks != 0 && *pollNotifications {
+		// Partitions are tables inheriting from their parent,
+		// and CockroachDB, the database without LISTEN and
+		// NOTIFY that polling is for, has no INHERITS.
+		chainlog.Fatal(ctx, chainlog.KeyError, "PARTITION_BLOCKS must not be set with DATABASE_POLL_NOTIFICATIONS")
+	}
 	db, err := sql.Open("hapg", *dbURL)
 	if err != nil {
 		chainlog.Fatal(ctx, chainlog.KeyError, err)
@@ -133,7 +289,9 @@ func main() {
 
 	var h http.Handler
 	if conf != nil {
-		h = launchConfiguredCore(ctx, db, conf, processID)
+		hsm := mockhsm.New(db)
+		blockKey := validateConfig(ctx, db, conf, hsm)
+		h = launchConfiguredCore(ctx, db, conf, hsm, blockKey, processID)
 	} else {
 		chainlog.Messagef(ctx, "Launching as unconfigured Core.")
 		h = &core.Handler{
@@ -143,6 +301,17 @@ func main() {
 		}
 	}
 
+	if len(*corsOrigins) > 0 {
+		h = cors.Handler{
+			Handler:        h,
+			Origins:        *corsOrigins,
+			ExposedHeaders: []string{"Chain-Request-Id", rpc.HeaderBlockchainID},
+		}
+	}
+	if *pathPrefix != "" {
+		h = stripPathPrefix(*pathPrefix, h)
+	}
+
 	secureheader.DefaultConfig.PermitClearLoopback = true
 	secureheader.DefaultConfig.HTTPSRedirect = httpsRedirect
 	secureheader.DefaultConfig.Next = h
@@ -185,7 +354,12 @@ func main() {
 	}
 }
 
-func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config, processID string) http.Handler {
+// launchConfiguredCore starts a configured core, signing with
+// hsm and, if it's a block signer whose key is in a KMS, with
+// blockKey. See validateConfig.
+func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config, hsm *mockhsm.HSM, blockKey kms.Signer, processID string) http.Handler {
+	rpcKey, rpcSign := rpcSigner(ctx, hsm)
+
 	var remoteGenerator *rpc.Client
 	if !conf.IsGenerator {
 		remoteGenerator = &rpc.Client{
@@ -195,6 +369,8 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 			CoreID:       conf.ID,
 			BuildTag:     buildTag,
 			BlockchainID: conf.BlockchainID.String(),
+			Key:          rpcKey,
+			Sign:         rpcSign,
 		}
 	}
 	txbuilder.Generator = remoteGenerator
@@ -204,11 +380,40 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 		chainlog.Fatal(ctx, chainlog.KeyError, err)
 	}
 	pool := mempool.New()
+	pool.MaxChainDepth = *maxChainDepth
+	pool.MaxOrphanBlocks = *maxOrphanBlocks
+	pool.MaxOrphans = *maxOrphans
 	store := txdb.NewStore(db)
+	height, err := store.Height(ctx)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	store.Partition(uint64(*partitionBlocks), height)
 	c, err := protocol.NewChain(ctx, conf.BlockchainID, store, pool, heights)
 	if err != nil {
 		chainlog.Fatal(ctx, chainlog.KeyError, err)
 	}
+	c.Checkpoints, err = parseCheckpoints(*checkpoints)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	if *finalityDepth < 0 {
+		chainlog.Fatal(ctx, chainlog.KeyError, "FINALITY_DEPTH must not be negative")
+	}
+	c.FinalityDepth = uint64(*finalityDepth)
+	c.MaxClockSkew = *maxClockSkew
+	if *maxWitnessSize < 0 || *minAmount < 0 {
+		chainlog.Fatal(ctx, chainlog.KeyError, "STANDARD_MAX_WITNESS_SIZE and STANDARD_MIN_AMOUNT must not be negative")
+	}
+	c.Standard = standard.Policy{
+		MaxWitnessSize: *maxWitnessSize,
+		KnownPrograms:  *knownPrograms,
+		MinAmount:      uint64(*minAmount),
+	}
+	c.Standard.MinAmounts, err = parseMinAmounts(*minAmounts)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
 
 	// Set up the pin store for block processing
 	pinStore := pin.NewStore(db)
@@ -219,21 +424,35 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 	// Start listeners
 	go pinStore.Listen(ctx, account.PinName, *dbURL)
 	go pinStore.Listen(ctx, asset.PinName, *dbURL)
+	go pinStore.Listen(ctx, htlc.PinName, *dbURL)
 
 	// Setup the transaction query indexer to index every transaction.
 	indexer := query.NewIndexer(db, c, pinStore)
+	indexer.Partition(uint64(*partitionBlocks), c.Height())
+	indexer.Retain(query.Retention{
+		Transactions: *txRetention,
+		SpentOutputs: *outputRetention,
+	})
 
 	assets := asset.NewRegistry(db, c, pinStore)
 	accounts := account.NewManager(db, c, pinStore)
+	refdataKeys := refdata.NewKeyring(db)
+	var riskScores *risk.Store
 	if *indexTxs {
+		riskScores = &risk.Store{DB: db, Scorer: risk.Nop}
+		if *riskScorerURL != "" {
+			riskScores.Scorer = &risk.HTTPScorer{URL: *riskScorerURL, Token: *riskScorerToken}
+		}
 		go pinStore.Listen(ctx, query.TxPinName, *dbURL)
+		go pinStore.Listen(ctx, query.BalanceSnapshotPinName, *dbURL)
 		indexer.RegisterAnnotator(assets.AnnotateTxs)
 		indexer.RegisterAnnotator(accounts.AnnotateTxs)
+		indexer.RegisterAnnotator(refdataKeys.AnnotateTxs)
+		indexer.RegisterAnnotator(riskScores.AnnotateTxs)
 		assets.IndexAssets(indexer)
 		accounts.IndexAccounts(indexer)
 	}
 
-	hsm := mockhsm.New(db)
 	var generatorSigners []generator.BlockSigner
 	var signBlockHandler func(context.Context, *bc.Block) ([]byte, error)
 	if conf.IsSigner {
@@ -241,7 +460,26 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 		if err != nil {
 			chainlog.Fatal(ctx, chainlog.KeyError, err)
 		}
-		s := blocksigner.New(blockPub, hsm, db, c)
+		var s *blocksigner.Signer
+		if blockKey != nil {
+			s = blocksigner.NewKMS(blockKey, db, c)
+		} else if *signerURL != "" {
+			s = blocksigner.NewRemote(blockPub, &rpc.Client{
+				BaseURL:      *signerURL,
+				Username:     processID,
+				CoreID:       conf.ID,
+				BuildTag:     buildTag,
+				BlockchainID: conf.BlockchainID.String(),
+				Key:          rpcKey,
+				Sign:         rpcSign,
+			}, db, c)
+		} else {
+			s = blocksigner.New(blockPub, hsm, db, c)
+		}
+		s.Policy, err = signerPolicy()
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
 		generatorSigners = append(generatorSigners, s) // "local" signer
 		signBlockHandler = func(ctx context.Context, b *bc.Block) ([]byte, error) {
 			sig, err := s.ValidateAndSignBlock(ctx, b)
@@ -253,7 +491,7 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 	}
 
 	if conf.IsGenerator {
-		for _, signer := range remoteSignerInfo(ctx, processID, buildTag, conf.BlockchainID.String(), conf) {
+		for _, signer := range remoteSignerInfo(ctx, processID, buildTag, conf.BlockchainID.String(), conf, rpcKey, rpcSign) {
 			generatorSigners = append(generatorSigners, signer)
 		}
 		c.MaxIssuanceWindow = conf.MaxIssuanceWindow
@@ -261,22 +499,46 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 
 	// GC old submitted txs periodically.
 	go core.CleanupSubmittedTxs(ctx, db)
+	go core.CleanupIdempotentRequests(ctx, db, *idempotencyRetention)
+	go core.CleanupBuildCommitments(ctx, db)
 
 	h := &core.Handler{
 		Chain:        c,
+		Pool:         pool,
 		Store:        store,
 		PinStore:     pinStore,
 		Assets:       assets,
 		Accounts:     accounts,
 		HSM:          hsm,
+		RPCKey:       rpcKey,
 		TxFeeds:      &txfeed.Tracker{DB: db},
 		Indexer:      indexer,
 		AccessTokens: &accesstoken.CredentialStore{DB: db},
+		Approvals:    &approval.Controller{DB: db},
+		Freezes:      &freeze.Controller{DB: db},
+		HTLCs:        htlc.NewCoordinator(db, c, pinStore, hsm),
+		Contracts:    &contract.Registry{DB: db},
+		Explorer:     &explorer.Explorer{DB: db},
+		Schedules:    &schedule.Scheduler{DB: db},
 		Config:       conf,
 		DB:           db,
 		Addr:         *listenAddr,
 		Signer:       signBlockHandler,
 		AltAuth:      authLoopbackInDev,
+		TxTTL:        *txTTL,
+
+		PublicExplorer:     *publicExplorer,
+		MaxPendingTxs:      *maxPendingTxs,
+		SubmitRetryAfter:   *submitRetryAfter,
+		SubmissionFailures: &deadletter.Queue{DB: db},
+		ReferenceDataKeys:  refdataKeys,
+		RiskScores:         riskScores,
+		ScoreSubmissions:   *riskSubmissions,
+	}
+	pub := eventsPublisher(ctx)
+	h.SigningRequests = &signreq.Tracker{DB: db, Publisher: pub, Topic: *eventsSignTopic}
+	if !conf.IsGenerator {
+		h.Forwarder = &forward.Forwarder{DB: db, Chain: c}
 	}
 	if *rpsToken > 0 {
 		h.RequestLimits = append(h.RequestLimits, core.RequestLimit{
@@ -293,10 +555,21 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 		})
 	}
 
+	if *mirrorOutbox {
+		mirror.Register("outbox", mirror.Outbox(db))
+	}
+	mirror.Register("balance-subscriptions", accounts.NotifyBalanceChanges)
+	if pub != nil {
+		mirror.Register("events", eventsHook(ctx, pub, accounts))
+	}
+
 	var (
 		genhealth   = h.HealthSetter("generator")
 		fetchhealth = h.HealthSetter("fetch")
 	)
+	if *ntpServer != "" {
+		go checkClock(ctx, *ntpServer, *maxClockSkew, h.HealthSetter("clock"))
+	}
 
 	go func() {
 		<-c.Ready()
@@ -316,6 +589,18 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 		if err != nil {
 			chainlog.Fatal(ctx, chainlog.KeyError, err)
 		}
+		err = pinStore.CreatePin(ctx, query.BalanceSnapshotPinName, height)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
+		err = pinStore.CreatePin(ctx, htlc.PinName, height)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
+		err = mirror.CreatePins(ctx, pinStore, height)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
 	}()
 
 	// Note, it's important for any services that will install blockchain
@@ -327,11 +612,23 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 			go generator.Generate(ctx, c, generatorSigners, db, blockPeriod, genhealth)
 		} else {
 			go fetch.Fetch(ctx, c, remoteGenerator, fetchhealth)
+			go h.Forwarder.Retry(ctx, forwardRetryPeriod)
 		}
 		go h.Accounts.ProcessBlocks(ctx)
+		var reindexer account.Reindexer
+		if *indexTxs {
+			reindexer = h.Indexer
+		}
+		go h.Accounts.ProcessRescans(ctx, rescanPeriod, reindexer)
 		go h.Assets.ProcessBlocks(ctx)
+		go h.ProcessHTLCs(ctx)
+		go h.ProcessScheduledPayments(ctx, scheduledPaymentsPeriod)
+		go h.SigningRequests.ExpireRequests(ctx, expireSigningPeriod)
+		go mirror.ProcessBlocks(ctx, c, pinStore)
 		if *indexTxs {
 			go h.Indexer.ProcessBlocks(ctx)
+			go h.Indexer.ProcessBalanceSnapshots(ctx)
+			go h.Indexer.PruneIndex(ctx, pruneIndexPeriod)
 		}
 	})
 
@@ -348,7 +645,25 @@ type remoteSigner struct {
 	Key    ed25519.PublicKey
 }
 
-func remoteSignerInfo(ctx context.Context, processID, buildTag, blockchainID string, conf *config.Config) (a []*remoteSigner) {
+// rpcSigner returns the key this core signs its requests to
+// other cores with, creating it in hsm the first time, and
+// a function to sign with it.
+func rpcSigner(ctx context.Context, hsm *mockhsm.HSM) (ed25519.PublicKey, rpc.SignFunc) {
+	pub, created, err := hsm.GetOrCreate(ctx, rpcKeyAlias)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	if created {
+		chainlog.Messagef(ctx, "Generated new network RPC key %x", []byte(pub.Pub))
+	} else {
+		chainlog.Messagef(ctx, "Using network RPC key %x", []byte(pub.Pub))
+	}
+	return pub.Pub, func(ctx context.Context, msg []byte) ([]byte, error) {
+		return hsm.Sign(ctx, pub.Pub, msg)
+	}
+}
+
+func remoteSignerInfo(ctx context.Context, processID, buildTag, blockchainID string, conf *config.Config, key ed25519.PublicKey, sign rpc.SignFunc) (a []*remoteSigner) {
 	for _, signer := range conf.Signers {
 		u, err := url.Parse(signer.URL)
 		if err != nil {
@@ -364,6 +679,8 @@ func remoteSignerInfo(ctx context.Context, processID, buildTag, blockchainID str
 			CoreID:       conf.ID,
 			BuildTag:     buildTag,
 			BlockchainID: blockchainID,
+			Key:          key,
+			Sign:         sign,
 		}
 		a = append(a, &remoteSigner{Client: client, Key: ed25519.PublicKey(signer.Pubkey)})
 	}
@@ -415,3 +732,150 @@ func (w *errlog) Write(p []byte) (int, error) {
 	}
 	return len(p), nil // report success for the MultiWriter
 }
+
+// eventsPublisher returns the message bus configured
+// in the environment, or nil if there is none.
+func eventsPublisher(ctx context.Context) events.Publisher {
+	switch {
+	case *eventsNATS != "" && *eventsKafkaREST != "":
+		chainlog.Fatal(ctx, chainlog.KeyError, "set only one of EVENTS_NATS_URL and EVENTS_KAFKA_REST_URL")
+	case *eventsNATS != "":
+		n, err := events.NewNATS(*eventsNATS)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
+		return n
+	case *eventsKafkaREST != "":
+		return &events.KafkaREST{URL: *eventsKafkaREST}
+	}
+	return nil
+}
+
+// eventsHook returns a hook publishing
+// block and transaction events to pub.
+func eventsHook(ctx context.Context, pub events.Publisher, accounts *account.Manager) mirror.Hook {
+	hook, err := events.Hook(pub, events.Config{
+		BlockTopic:  *eventsBlockTopic,
+		TxTopic:     *eventsTxTopic,
+		PartitionBy: *eventsPartitionBy,
+		AccountIDs:  accounts.ControlProgramAccounts,
+	})
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	return hook
+}
+
+// parseCheckpoints parses a comma-separated list
+// of checkpoints, each a block height and hash
+// separated by a colon.
+func parseCheckpoints(s string) ([]protocol.Checkpoint, error) {
+	var cps []protocol.Checkpoint
+	for _, item := range strings.Split(s, ",") {
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("checkpoint %q is not height:hash", item)
+		}
+		var cp protocol.Checkpoint
+		var err error
+		cp.Height, err = strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "checkpoint %q", item)
+		}
+		err = cp.Hash.UnmarshalText([]byte(parts[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "checkpoint %q", item)
+		}
+		cps = append(cps, cp)
+	}
+	return cps, nil
+}
+
+// parseMinAmounts parses a comma-separated
+// list of asset:amount pairs.
+func parseMinAmounts(s string) (map[bc.AssetID]uint64, error) {
+	m := make(map[bc.AssetID]uint64)
+	for _, item := range strings.Split(s, ",") {
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("minimum amount %q is not asset:amount", item)
+		}
+		var assetID bc.AssetID
+		err := assetID.UnmarshalText([]byte(parts[0]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "minimum amount %q", item)
+		}
+		m[assetID], err = strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "minimum amount %q", item)
+		}
+	}
+	return m, nil
+}
+
+// signerPolicy returns the block signer policy
+// set in the environment.
+func signerPolicy() (blocksigner.Policy, error) {
+	p := blocksigner.Policy{
+		MaxTxs:      *signerMaxTxs,
+		Generators:  *signerGenerators,
+		MinInterval: *signerMinInterval,
+	}
+	for _, s := range *signerEmbargoed {
+		var id bc.AssetID
+		err := id.UnmarshalText([]byte(s))
+		if err != nil {
+			return p, errors.Wrapf(err, "embargoed asset %q", s)
+		}
+		p.EmbargoedAssets = append(p.EmbargoedAssets, id)
+	}
+	return p, nil
+}
+
+// stripPathPrefix serves requests with h, removing prefix
+// from the paths of those that start with it.
+func stripPathPrefix(prefix string, h http.Handler) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p := strings.TrimPrefix(req.URL.Path, prefix)
+		if len(p) < len(req.URL.Path) && (p == "" || p[0] == '/') {
+			if p == "" {
+				p = "/"
+			}
+			u := *req.URL
+			u.Path, u.RawPath = p, ""
+			r := *req
+			r.URL = &u
+			req = &r
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// checkClock asks the NTP server for the time and reports,
+// with health, whether the local clock is off by more than
+// tolerance. Other cores reject blocks made or signed with
+// such a clock. Failing to reach the server is only logged,
+// since many cores can't reach public NTP servers.
+func checkClock(ctx context.Context, server string, tolerance time.Duration, health func(error)) {
+	offset, err := ntp.Offset(ctx, server)
+	if err != nil {
+		chainlog.Error(ctx, err, "checking clock against NTP server "+server)
+		return
+	}
+	skew, dir := offset, "behind"
+	if skew < 0 {
+		skew, dir = -skew, "ahead of"
+	}
+	if tolerance > 0 && skew > tolerance {
+		err = fmt.Errorf("local clock is %s %s NTP server %s, more than the %s tolerated", skew, dir, server, tolerance)
+		chainlog.Error(ctx, err)
+	}
+	health(err)
+}
@@ -5,12 +5,14 @@ package main
 import (
 	"net/http"
 
-	"chain/database/pg"
+	"chain/database/sql"
 )
 
 var prod = "yes"
 
-func resetInDevIfRequested(db pg.DB) {}
+func resetInDevIfRequested(db *sql.DB) {}
+
+func enableExperimentsInDev() {}
 
 func authLoopbackInDev(req *http.Request) bool {
 	return false
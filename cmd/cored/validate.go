@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chain/core/config"
+	"chain/core/migrate"
+	"chain/core/mockhsm"
+	"chain/crypto/ed25519"
+	"chain/crypto/kms"
+	"chain/database/sql"
+	"chain/errors"
+	chainlog "chain/log"
+)
+
+// signerServiceCheckTimeout limits how long the startup
+// check of a block signer service waits for it.
+const signerServiceCheckTimeout = 10 * time.Second
+
+// validateConfig checks, before a configured core serves
+// traffic, that its database, keys, and generator are as its
+// configuration says. It prints a report of every check and
+// exits if any fails, rather than leave a misconfigured core
+// to fail later, deep in block processing.
+//
+// It adds the keys in MOCKHSM_KMS_KEYS to hsm, and returns the
+// key in BLOCK_SIGNER_KMS_KEY, if set.
+func validateConfig(ctx context.Context, db *sql.DB, conf *config.Config, hsm *mockhsm.HSM) (blockKey kms.Signer) {
+	checks := []config.Check{{
+		Name: "database schema",
+		Hint: "The database was migrated by a newer version of Chain Core. Upgrade cored, or set DATABASE_URL to a database for this version.",
+		Run:  func(context.Context) error { return migrate.Check(db) },
+	}, {
+		Name: "blockchain id",
+		Hint: "DATABASE_URL may name another core's database. Set it to this core's database, or reset this core and configure it again.",
+		Run:  func(ctx context.Context) error { return config.CheckBlockchainID(ctx, db, conf) },
+	}}
+	for _, uri := range *mockHSMKMSKeys {
+		uri := uri
+		checks = append(checks, config.Check{
+			Name: "mock HSM key " + uri,
+			Hint: "Check MOCKHSM_KMS_KEYS, and that this core's cloud credentials may use the key.",
+			Run: func(ctx context.Context) error {
+				k, err := kms.Open(ctx, uri)
+				if err != nil {
+					return err
+				}
+				hsm.AddSigner(k)
+				return nil
+			},
+		})
+	}
+	if conf.IsSigner {
+		checks = append(checks, blockKeyCheck(conf, hsm, &blockKey))
+	}
+	if !conf.IsGenerator {
+		checks = append(checks, config.Check{
+			Name: "generator",
+			Hint: fmt.Sprintf("Check that the generator at %s is running and reachable from this core, and that this core's generator access token is valid.", conf.GeneratorURL),
+			Run:  func(ctx context.Context) error { return config.CheckGenerator(ctx, conf) },
+		})
+	}
+
+	report := config.Validate(ctx, checks)
+	fmt.Print(report)
+	if report.Failed() > 0 {
+		chainlog.Fatal(ctx, chainlog.KeyError, fmt.Sprintf("%d startup checks failed", report.Failed()))
+	}
+	return blockKey
+}
+
+// blockKeyCheck returns the check that a block signer can
+// sign with its block_pub, wherever the key is held. A key
+// in a KMS is stored in *blockKey.
+func blockKeyCheck(conf *config.Config, hsm *mockhsm.HSM, blockKey *kms.Signer) config.Check {
+	pub, err := hex.DecodeString(conf.BlockPub)
+	if err == nil && len(pub) != ed25519.PublicKeySize {
+		err = config.ErrBadSignerPubkey
+	}
+	if err != nil {
+		return config.Check{
+			Name: "block signing key",
+			Hint: "The core's configured block_pub is invalid. Reset this core and configure it again.",
+			Run:  func(context.Context) error { return errors.Wrap(err, "block_pub") },
+		}
+	}
+
+	switch {
+	case *signerURL != "" && *blockSignerKMSKey != "":
+		return config.Check{
+			Name: "block signing key",
+			Hint: "Unset one of SIGNER_SERVICE_URL and BLOCK_SIGNER_KMS_KEY.",
+			Run: func(context.Context) error {
+				return errors.New("SIGNER_SERVICE_URL and BLOCK_SIGNER_KMS_KEY are mutually exclusive")
+			},
+		}
+	case *blockSignerKMSKey != "":
+		return config.Check{
+			Name: "block signing key",
+			Hint: "Check that BLOCK_SIGNER_KMS_KEY names the key whose public key is block_pub, and that this core's cloud credentials may sign with it.",
+			Run: func(ctx context.Context) error {
+				k, err := kms.Open(ctx, *blockSignerKMSKey)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(k.Public(), pub) {
+					return fmt.Errorf("BLOCK_SIGNER_KMS_KEY has public key %x, not block_pub %x", []byte(k.Public()), pub)
+				}
+				err = trySign(ctx, k.Sign, pub)
+				if err != nil {
+					return err
+				}
+				*blockKey = k
+				return nil
+			},
+		}
+	case *signerURL != "":
+		// The signer service signs nothing but block
+		// headers, so this can only check it's there.
+		return config.Check{
+			Name: "block signer service",
+			Hint: "Check that signerd is running at SIGNER_SERVICE_URL and reachable from this core.",
+			Run: func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, signerServiceCheckTimeout)
+				defer cancel()
+				req, err := http.NewRequest("GET", *signerURL, nil)
+				if err != nil {
+					return errors.Wrap(err, "SIGNER_SERVICE_URL")
+				}
+				resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+				if err != nil {
+					return errors.Wrap(err, "reaching signer service")
+				}
+				resp.Body.Close()
+				return nil
+			},
+		}
+	}
+	return config.Check{
+		Name: "block signing key",
+		Hint: fmt.Sprintf("The mock HSM has no key %s. Restore it with /mockhsm/restore-keys, or set BLOCK_SIGNER_KMS_KEY or SIGNER_SERVICE_URL.", conf.BlockPub),
+		Run: func(ctx context.Context) error {
+			return trySign(ctx, func(ctx context.Context, msg []byte) ([]byte, error) {
+				return hsm.Sign(ctx, pub, msg)
+			}, pub)
+		},
+	}
+}
+
+// trySign signs a test message with sign,
+// and checks the signature with pub.
+func trySign(ctx context.Context, sign func(context.Context, []byte) ([]byte, error), pub ed25519.PublicKey) error {
+	msg := []byte("chain core startup check")
+	sig, err := sign(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		return errors.New("test signature does not verify with block_pub")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"chain/core/asset"
+)
+
+// amount converts amounts of an asset between base units, in
+// which transactions hold them, and display units, given the
+// asset's decimals or its definition. Amounts may be of any
+// size, such as totals of many outputs, but it warns of those
+// too large for a single output.
+func amount(args []string) {
+	var flags flag.FlagSet
+	decimalsFlag := flags.String("decimals", "", "the asset has `n` decimals")
+	def := flags.String("def", "", "read decimals from asset `definition`, JSON or @FILE")
+	toBase := flags.Bool("to-base", false, "convert display units to base units, instead of base units to display units")
+	flags.Usage = func() {
+		fmt.Println("usage: multitool amount [-to-base] -decimals n | -def definition amount...")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 || (*decimalsFlag == "") == (*def == "") {
+		flags.Usage()
+	}
+	var decimals int
+	if *def != "" {
+		decimals = definitionDecimals(*def)
+	} else {
+		var err error
+		decimals, err = strconv.Atoi(*decimalsFlag)
+		if err != nil || decimals < 0 || decimals > asset.MaxDecimals {
+			errorf("error: decimals must be an integer from 0 to %d", asset.MaxDecimals)
+		}
+	}
+
+	maxAmount := big.NewInt(math.MaxInt64)
+	for _, arg := range flags.Args() {
+		var n *big.Int
+		if *toBase {
+			var err error
+			n, err = asset.ParseBigAmount(arg, decimals)
+			if err != nil {
+				errorf("error: %s", err)
+			}
+			fmt.Println(n)
+		} else {
+			var ok bool
+			n, ok = new(big.Int).SetString(arg, 10)
+			if !ok || n.Sign() < 0 {
+				errorf("error: %q is not a whole number of base units", arg)
+			}
+			fmt.Println(asset.FormatBigAmount(n, decimals))
+		}
+		if n.Cmp(maxAmount) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s exceeds 2^63-1 base units, the most one output can hold\n", arg)
+		}
+	}
+}
+
+// definitionDecimals returns the decimals declared by the
+// asset definition s, given as JSON or as @FILE.
+func definitionDecimals(s string) int {
+	b := []byte(s)
+	if strings.HasPrefix(s, "@") {
+		var err error
+		b, err = ioutil.ReadFile(s[1:])
+		if err != nil {
+			errorf("error: %s", err)
+		}
+	}
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&m)
+	if err != nil {
+		errorf("error decoding asset definition: %s", err)
+	}
+	d, ok := asset.Decimals(m)
+	if !ok {
+		errorf("error: asset definition declares no valid %s", asset.DefDecimals)
+	}
+	return d
+}
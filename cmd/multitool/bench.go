@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+type benchmark struct {
+	name string
+	fn   func(*testing.B)
+}
+
+// bench runs a fixed set of benchmarks, so that reports from
+// different machines can be compared line by line.
+func bench(_ []string) {
+	_, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		errorf("unexpected error %s", err)
+	}
+	pub := prv.Public().(ed25519.PublicKey)
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		errorf("unexpected error %s", err)
+	}
+
+	msg := make([]byte, 32)
+	sig := ed25519.Sign(prv, msg)
+	xsig := xprv.Sign(msg)
+	kb := make([]byte, 1024)
+	tx := benchTx(prv)
+
+	benchmarks := []benchmark{
+		{"ed25519 sign", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ed25519.Sign(prv, msg)
+			}
+		}},
+		{"ed25519 verify", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ed25519.Verify(pub, msg, sig)
+			}
+		}},
+		{"chainkd sign", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				xprv.Sign(msg)
+			}
+		}},
+		{"chainkd verify", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				xpub.Verify(msg, xsig)
+			}
+		}},
+		{"sha3-256 32B", func(b *testing.B) {
+			b.SetBytes(int64(len(msg)))
+			for i := 0; i < b.N; i++ {
+				sha3.Sum256(msg)
+			}
+		}},
+		{"sha3-256 1KiB", func(b *testing.B) {
+			b.SetBytes(int64(len(kb)))
+			for i := 0; i < b.N; i++ {
+				sha3.Sum256(kb)
+			}
+		}},
+		{"script 1-of-1 multisig", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ok, err := vm.VerifyTxInputUncached(tx, 0)
+				if err != nil || !ok {
+					b.Fatalf("script failed: %v", err)
+				}
+			}
+		}},
+		{"script cached result", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ok, err := vm.VerifyTxInput(tx, 0)
+				if err != nil || !ok {
+					b.Fatalf("script failed: %v", err)
+				}
+			}
+		}},
+		// After the first run, the script's result comes from
+		// the cache, so this measures the rest of validation.
+		{"validate tx 1 input", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				err := validation.CheckTxWellFormed(tx)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+	}
+
+	fmt.Printf("%s/%s, %d CPUs, %s\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.Version())
+	for _, bm := range benchmarks {
+		r := testing.Benchmark(bm.fn)
+		if r.N == 0 {
+			errorf("%s: benchmark failed", bm.name)
+		}
+		opsPerSec := float64(r.N) / r.T.Seconds()
+		fmt.Printf("%-24s %12.0f ops/s %12s/op", bm.name, opsPerSec, time.Duration(r.NsPerOp()))
+		if r.Bytes > 0 {
+			mbPerSec := float64(r.Bytes) * float64(r.N) / 1e6 / r.T.Seconds()
+			fmt.Printf(" %10.2f MB/s", mbPerSec)
+		}
+		fmt.Println()
+	}
+}
+
+// benchTx returns a well-formed transaction issuing to,
+// and signed by, a 1-of-1 multisig program on prv,
+// the way Chain Core's account programs are.
+func benchTx(prv ed25519.PrivateKey) *bc.Tx {
+	pub := prv.Public().(ed25519.PublicKey)
+	prog, err := vmutil.P2SPMultiSigProgram([]ed25519.PublicKey{pub}, 1)
+	if err != nil {
+		errorf("unexpected error %s", err)
+	}
+	assetID := bc.ComputeAssetID(prog, bc.Hash{}, 1)
+	now := bc.Millis(time.Now())
+	tx := &bc.TxData{
+		Version: 1,
+		MinTime: now,
+		MaxTime: now + uint64(time.Hour/time.Millisecond),
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{1}, 100, nil, bc.Hash{}, prog, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 100, prog, nil)},
+	}
+
+	sighash := tx.HashForSig(0)
+	pred, err := vm.Assemble(fmt.Sprintf("0x%x TXSIGHASH EQUAL", sighash[:]))
+	if err != nil {
+		errorf("unexpected error %s", err)
+	}
+	h := sha3.Sum256(pred)
+	sig := ed25519.Sign(prv, h[:])
+	tx.Inputs[0].SetArguments([][]byte{vm.Int64Bytes(0), sig, pred})
+	return bc.NewTx(*tx)
+}
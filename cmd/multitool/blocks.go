@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"chain/core/blockfile"
+	"chain/core/txdb"
+	"chain/database/sql"
+	"chain/protocol/bc"
+)
+
+// openBlocks opens a blockchain's blocks from the initial block
+// on, from the database at dbURL if it's set, or else from the
+// block file named filename. It returns the number of blocks and
+// a function to get the i'th.
+func openBlocks(dbURL, filename string) (n int, get func(i int) (*bc.Block, error)) {
+	ctx := context.Background()
+	if dbURL != "" {
+		db, err := sql.Open("hapg", dbURL)
+		if err != nil {
+			errorf("error: %s", err)
+		}
+		store := txdb.NewStore(db)
+		height, err := store.Height(ctx)
+		if err != nil {
+			errorf("error: %s", err)
+		}
+		return int(height), func(i int) (*bc.Block, error) {
+			return store.GetBlock(ctx, uint64(i+1))
+		}
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		errorf("error: %s", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		errorf("error: %s", err)
+	}
+	r, err := blockfile.NewReader(f, info.Size())
+	if err != nil {
+		errorf("error: %s", err)
+	}
+	if r.Len() > 0 && r.Height(0) != 1 {
+		errorf("error: file starts at block %d, not the initial block", r.Height(0))
+	}
+	return r.Len(), r.Block
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/keystore"
+	"chain/crypto/kms"
+	"chain/errors"
+)
+
+// Key files keep xprvs encrypted at rest; see package keystore.
+// Subcommands that take an XPRV also take @FILE, naming a key
+// file. Passphrases are read from MULTITOOL_PASSPHRASE or, if
+// it's unset, from a line of stdin. Rotating a file's passphrase
+// reads the new one from MULTITOOL_NEW_PASSPHRASE likewise.
+const (
+	passphraseEnv    = "MULTITOOL_PASSPHRASE"
+	newPassphraseEnv = "MULTITOOL_NEW_PASSPHRASE"
+)
+
+var stdinLines = bufio.NewReader(os.Stdin)
+
+func keystoreCmd(args []string) {
+	if len(args) < 2 {
+		errorf("must specify -create, -unlock, or -rotate, and a key file")
+	}
+	which, path := args[0], args[1]
+	switch which {
+	case "-create":
+		var (
+			xprv chainkd.XPrv
+			err  error
+		)
+		if len(args) > 2 {
+			_, xprv = mustXPrv(args[2])
+		} else {
+			xprv, _, err = chainkd.NewXKeys(nil)
+			if err != nil {
+				errorf("unexpected error %s", err)
+			}
+		}
+		k, err := keystore.Create(xprv, passphrase(passphraseEnv, "passphrase"), keystore.StandardParams)
+		if err != nil {
+			errorf("error encrypting key: %s", err)
+		}
+		err = keystore.WriteFile(path, k)
+		if err != nil {
+			errorf("error writing key file: %s", err)
+		}
+		fmt.Println(tagKey(os.Getenv(networkEnv), k.XPub.String()))
+	case "-unlock":
+		network, xprv := mustXPrv("@" + path)
+		fmt.Println(tagKey(network, xprv.String()))
+	case "-rotate":
+		k := mustReadKeyFile(path)
+		rotated, err := k.Rotate(passphrase(passphraseEnv, "passphrase"), passphrase(newPassphraseEnv, "new passphrase"))
+		if err != nil {
+			errorf("error rotating passphrase: %s", err)
+		}
+		tmp := path + ".tmp"
+		err = keystore.WriteFile(tmp, rotated)
+		if err != nil {
+			errorf("error writing key file: %s", err)
+		}
+		err = os.Rename(tmp, path)
+		if err != nil {
+			os.Remove(tmp)
+			errorf("error replacing key file: %s", err)
+		}
+	default:
+		errorf("must specify -create, -unlock, or -rotate")
+	}
+}
+
+// mustXPrv parses s as an xprv, which may be tagged with
+// its network as mustKey allows, or, if s is @FILE, unlocks
+// the key file FILE. It returns the network the key is for,
+// if known, and the xprv.
+func mustXPrv(s string) (network string, xprv chainkd.XPrv) {
+	if strings.HasPrefix(s, "@") {
+		k := mustReadKeyFile(s[1:])
+		xprv, err := k.Unlock(passphrase(passphraseEnv, "passphrase"))
+		if errors.Root(err) == keystore.ErrBadPassphrase {
+			errorf("wrong passphrase for %s", s[1:])
+		} else if err != nil {
+			errorf("error unlocking %s: %s", s[1:], err)
+		}
+		return os.Getenv(networkEnv), xprv
+	}
+	network, k := mustKey(s)
+	err := xprv.UnmarshalText([]byte(k))
+	if err != nil {
+		errorf("could not parse xprv")
+	}
+	return network, xprv
+}
+
+func mustReadKeyFile(path string) *keystore.Key {
+	k, err := keystore.ReadFile(path)
+	if err != nil {
+		errorf("error reading key file: %s", err)
+	}
+	return k
+}
+
+// passphrase returns the value of the environment variable
+// env or, if it's unset, a line read from stdin, prompting
+// for what on stderr.
+func passphrase(env, what string) []byte {
+	if p, ok := os.LookupEnv(env); ok {
+		return []byte(p)
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", what)
+	line, err := stdinLines.ReadString('\n')
+	if err != nil && line == "" {
+		errorf("error reading %s: %s", what, err)
+	}
+	return []byte(strings.TrimRight(line, "\r\n"))
+}
+
+// kmsenvelope writes an envelope file, for block signers
+// whose keys must be wrapped by a cloud KMS. See package kms.
+func kmsenvelope(args []string) {
+	if len(args) < 2 {
+		errorf("must specify a KEK uri and an envelope file")
+	}
+	kek, path := args[0], args[1]
+	var prv ed25519.PrivateKey
+	if len(args) > 2 {
+		_, k := mustKey(args[2])
+		prv = ed25519.PrivateKey(mustDecodeHex(k))
+		if len(prv) != ed25519.PrivateKeySize {
+			errorf("bad prv length")
+		}
+	} else {
+		var err error
+		_, prv, err = ed25519.GenerateKey(nil)
+		if err != nil {
+			errorf("unexpected error %s", err)
+		}
+	}
+	ctx := context.Background()
+	w, err := kms.OpenWrapper(kek)
+	if err != nil {
+		errorf("%s", err)
+	}
+	e, err := kms.Seal(ctx, prv, kek, w)
+	if err != nil {
+		errorf("error sealing key: %s", err)
+	}
+	err = kms.WriteEnvelope(path, e)
+	if err != nil {
+		errorf("error writing envelope file: %s", err)
+	}
+	fmt.Println(hex.EncodeToString(e.Pub))
+}
@@ -69,26 +69,35 @@ type command struct {
 }
 
 var subcommands = map[string]command{
-	"assetid":     command{assetid, "compute asset id", "ISSUANCEPROG GENESISHASH"},
-	"block":       command{block, "decode and pretty-print a block", "BLOCK"},
-	"blockheader": command{blockheader, "decode and pretty-print a block header", "BLOCKHEADER"},
-	"derive":      command{derive, "derive child from given xpub or xprv and given path", "[-xpub|-xprv] XPUB/XPRV PATH PATH..."},
-	"genprv":      command{genprv, "generate prv", ""},
-	"genxprv":     command{genxprv, "generate xprv", ""},
-	"hex":         command{hexCmd, "string <-> hex", "INPUT"},
-	"hmac512":     command{hmac512, "compute the hmac512 digest", "KEY VALUE"},
-	"pub":         command{pub, "get pub key from prv, or xpub from xprv", "PRV/XPRV"},
-	"script":      command{script, "hex <-> opcodes", "INPUT"},
-	"sha3":        command{sha3Cmd, "produce sha3 hash", "INPUT"},
-	"sha512":      command{sha512Cmd, "produce sha512 hash", "INPUT"},
-	"sha512alt":   command{sha512alt, "produce sha512alt hash", "INPUT"},
-	"sign":        command{sign, "sign, using hex PRV or XPRV, the given hex MSG", "PRV/XPRV MSG"},
-	"tx":          command{tx, "decode and pretty-print a transaction", "TX"},
-	"txhash":      command{txhash, "decode a hex transaction and show its txhash", "TX"},
-	"uvarint":     command{uvarint, "decimal <-> hex", "[-from|-to] VAL"},
-	"varint":      command{varint, "decimal <-> hex", "[-from|-to] VAL"},
-	"verify":      command{verify, "verify, using hex PUB or XPUB and the given hex MSG and SIG", "PUB/XPUB MSG SIG"},
-	"zerohash":    command{zerohash, "produce an all-zeroes hash", ""},
+	"amount":       command{amount, "convert amounts between the base units and display units of an asset", "[-to-base] -decimals N | -def DEFINITION AMOUNT..."},
+	"assetid":      command{assetid, "compute asset id (-v: also show the serialization hashed)", "[-v] [-vm VERSION] ISSUANCEPROG GENESISHASH"},
+	"bench":        command{bench, "benchmark crypto and validation on this machine", ""},
+	"block":        command{block, "decode and pretty-print a block", "BLOCK"},
+	"blockheader":  command{blockheader, "decode and pretty-print a block header", "BLOCKHEADER"},
+	"derive":       command{derive, "derive child from given xpub or xprv (or @KEYFILE) and given path", "[-xpub|-xprv] XPUB/XPRV PATH PATH..."},
+	"genprv":       command{genprv, "generate prv", ""},
+	"genxprv":      command{genxprv, "generate xprv", ""},
+	"hex":          command{hexCmd, "string <-> hex", "INPUT"},
+	"hmac512":      command{hmac512, "compute the hmac512 digest", "KEY VALUE"},
+	"keystore":     command{keystoreCmd, "encrypt an XPRV (or a new one) to a key file, decrypt one, or change its passphrase", "-create FILE [XPRV] | -unlock FILE | -rotate FILE"},
+	"kmsenvelope":  command{kmsenvelope, "seal a PRV (or a new one) in an envelope file under KEK, an awskms: or gcpkms: key URI", "KEK FILE [PRV]"},
+	"pub":          command{pub, "get pub key from prv, or xpub from xprv", "PRV/XPRV"},
+	"qrtemplate":   command{qrtemplate, "convert a transaction template between JSON and compact QR-sized form", "[-decode] TEMPLATE"},
+	"script":       command{script, "hex <-> opcodes (-v: classify and annotate)", "[-v] INPUT"},
+	"sha3":         command{sha3Cmd, "produce sha3 hash", "INPUT"},
+	"sha512":       command{sha512Cmd, "produce sha512 hash", "INPUT"},
+	"sha512alt":    command{sha512alt, "produce sha512alt hash", "INPUT"},
+	"sigbundle":    command{sigbundle, "extract a compact bundle of a template's signatures, or add BUNDLE's to it", "TEMPLATE [BUNDLE]"},
+	"sign":         command{sign, "sign, using hex PRV or XPRV (or @KEYFILE), the given hex MSG", "PRV/XPRV MSG"},
+	"signtemplate": command{signtemplate, "sign a transaction template with root XPRV (or @KEYFILE), for keys with derivation PATH if given", "XPRV TEMPLATE [PATH...]"},
+	"supply":       command{supply, "total the issued, retired and circulating amounts of assets", "[-db URL | FILE] [ASSETID...]"},
+	"tx":           command{tx, "decode, pretty-print, and check the roundtrip of a transaction (hex or JSON)", "[-to-hex|-to-json] TX"},
+	"txhash":       command{txhash, "decode a hex transaction and show its txhash", "TX"},
+	"uvarint":      command{uvarint, "decimal <-> hex", "[-from|-to] VAL"},
+	"varint":       command{varint, "decimal <-> hex", "[-from|-to] VAL"},
+	"verify":       command{verify, "verify, using hex PUB or XPUB and the given hex MSG and SIG", "PUB/XPUB MSG SIG"},
+	"verifychain":  command{verifychain, "re-validate a blockchain from its initial block and report rule violations", "[-db URL] [FILE]"},
+	"zerohash":     command{zerohash, "produce an all-zeroes hash", ""},
 }
 
 func init() {
@@ -120,6 +129,8 @@ func help(args []string) {
 	for name, cmd := range subcommands {
 		fmt.Printf("%-16.16s %s\n", name, cmd.help)
 	}
+	fmt.Printf("\nKeys may be tagged with a network, as in testnet:XPRV.\n")
+	fmt.Printf("Set %s to refuse keys tagged for other networks.\n", networkEnv)
 }
 
 func mustSubcommand(name string) command {
@@ -169,12 +180,36 @@ func assetid(args []string) {
 	var (
 		issuanceInp, initialBlockInp string
 		usedStdin                    bool
+		verbose                      bool
+		vmVersion                    uint64 = 1
 	)
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		switch args[0] {
+		case "-v":
+			verbose = true
+			args = args[1:]
+		case "-vm":
+			if len(args) < 2 {
+				errorf("-vm requires a version")
+			}
+			v, err := strconv.ParseUint(args[1], 10, 63)
+			if err != nil {
+				errorf("error parsing vm version: %s", err)
+			}
+			vmVersion = v
+			args = args[2:]
+		default:
+			errorf("unknown flag %s", args[0])
+		}
+	}
 	issuanceInp, usedStdin = input(args, 0, false)
 	initialBlockInp, _ = input(args, 1, usedStdin)
 	issuance := mustDecodeHex(issuanceInp)
 	initialBlock := mustDecodeHash(initialBlockInp)
-	assetID := bc.ComputeAssetID(issuance, initialBlock, 1)
+	if verbose {
+		fmt.Println(hex.EncodeToString(bc.AssetIDPreimage(issuance, initialBlock, vmVersion)))
+	}
+	assetID := bc.ComputeAssetID(issuance, initialBlock, vmVersion)
 	fmt.Println(assetID.String())
 }
 
@@ -222,26 +257,22 @@ func derive(args []string) {
 		path = append(path, p)
 	}
 
-	k = strings.TrimSpace(k)
-
 	if which == "-xprv" {
-		var xprv chainkd.XPrv
-		err := xprv.UnmarshalText([]byte(k))
-		if err != nil {
-			errorf("could not parse key")
-		}
+		network, xprv := mustXPrv(k)
 		derived := xprv.Derive(path)
-		fmt.Println(derived.String())
+		fmt.Println(tagKey(network, derived.String()))
 		return
 	}
 
+	network, k := mustKey(k)
+
 	var xpub chainkd.XPub
 	err := xpub.UnmarshalText([]byte(k))
 	if err != nil {
 		errorf("could not parse key")
 	}
 	derived := xpub.Derive(path)
-	fmt.Println(derived.String())
+	fmt.Println(tagKey(network, derived.String()))
 }
 
 func genprv(_ []string) {
@@ -249,7 +280,7 @@ func genprv(_ []string) {
 	if err != nil {
 		errorf("unexpected error %s", err)
 	}
-	fmt.Println(hex.EncodeToString(prv))
+	fmt.Println(tagKey(os.Getenv(networkEnv), hex.EncodeToString(prv)))
 }
 
 func genxprv(_ []string) {
@@ -257,7 +288,7 @@ func genxprv(_ []string) {
 	if err != nil {
 		errorf("unexpected error %s", err)
 	}
-	fmt.Println(xprv.String())
+	fmt.Println(tagKey(os.Getenv(networkEnv), xprv.String()))
 }
 
 func hexCmd(args []string) {
@@ -280,22 +311,28 @@ func hmac512(args []string) {
 
 func pub(args []string) {
 	inp, _ := input(args, 0, false)
+	network, inp := mustKey(inp)
 	var xprv chainkd.XPrv
-	err := xprv.UnmarshalText([]byte(strings.TrimSpace(inp)))
+	err := xprv.UnmarshalText([]byte(inp))
 	if err == nil {
-		fmt.Println(xprv.XPub().String())
+		fmt.Println(tagKey(network, xprv.XPub().String()))
 		return
 	}
 	prv := ed25519.PrivateKey(mustDecodeHex(inp))
 	pub := prv.Public().(ed25519.PublicKey)
-	fmt.Println(hex.EncodeToString(pub))
+	fmt.Println(tagKey(network, hex.EncodeToString(pub)))
 }
 
 func script(args []string) {
+	disassemble := vm.Disassemble
+	if len(args) > 0 && args[0] == "-v" {
+		disassemble = vm.DisassembleAnnotated
+		args = args[1:]
+	}
 	inp, _ := input(args, 0, false)
 	b, err := decodeHex(inp)
 	if err == nil {
-		dis, err := vm.Disassemble(b)
+		dis, err := disassemble(b)
 		if err == nil {
 			fmt.Println(dis)
 			return
@@ -357,18 +394,14 @@ func sign(args []string) {
 	keyInp, usedStdin = input(args, 0, false)
 	msgInp, _ = input(args, 1, usedStdin)
 
-	keyInp = strings.TrimSpace(keyInp)
 	msg := mustDecodeHex(msgInp)
 	var signed []byte
 
 	if which == "-xprv" {
-		var xprv chainkd.XPrv
-		err := xprv.UnmarshalText([]byte(keyInp))
-		if err != nil {
-			errorf("could not parse xprv")
-		}
+		_, xprv := mustXPrv(keyInp)
 		signed = xprv.Sign(msg)
 	} else {
+		_, keyInp = mustKey(keyInp)
 		prv := ed25519.PrivateKey(mustDecodeHex(keyInp))
 		signed = ed25519.Sign(prv, msg)
 	}
@@ -376,16 +409,6 @@ func sign(args []string) {
 	fmt.Println(hex.EncodeToString(signed))
 }
 
-func tx(args []string) {
-	inp, _ := input(args, 0, false)
-	var tx bc.TxData
-	err := tx.UnmarshalText([]byte(strings.TrimSpace(inp)))
-	if err != nil {
-		errorf("error unmarshaling tx: %s", err)
-	}
-	spew.Printf("%v\n", tx)
-}
-
 func txhash(args []string) {
 	inp, _ := input(args, 0, false)
 	var tx bc.TxData
@@ -471,7 +494,7 @@ func verify(args []string) {
 	msgInp, usedStdin = input(args, 1, usedStdin)
 	sigInp, _ = input(args, 2, usedStdin)
 
-	keyInp = strings.TrimSpace(keyInp)
+	_, keyInp = mustKey(keyInp)
 	msg := mustDecodeHex(msgInp)
 	sig := mustDecodeHex(sigInp)
 
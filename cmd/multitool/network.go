@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Keys may be tagged with the name of the network they're
+// for, as in "testnet:XPRV", so keys for one environment are
+// harder to mistake for another's. When MULTITOOL_NETWORK is
+// set, subcommands refuse keys tagged for another network and
+// warn about untagged ones, and they tag the keys they print.
+// Derived keys keep their parents' tags.
+const networkEnv = "MULTITOOL_NETWORK"
+
+// splitNetwork splits a key string into its
+// network tag, if any, and the key itself.
+func splitNetwork(s string) (network, key string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return "", s
+	}
+	network, key = s[:i], s[i+1:]
+	if !validNetwork(network) {
+		errorf("invalid network tag %q", network)
+	}
+	return network, key
+}
+
+func validNetwork(network string) bool {
+	if network == "" {
+		return false
+	}
+	for _, c := range network {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// mustKey splits s as splitNetwork does, and exits if its network
+// tag conflicts with MULTITOOL_NETWORK. It returns the network
+// the key is for, which is MULTITOOL_NETWORK if s is untagged.
+func mustKey(s string) (network, key string) {
+	network, key = splitNetwork(s)
+	want := os.Getenv(networkEnv)
+	if want == "" {
+		return network, key
+	}
+	if !validNetwork(want) {
+		errorf("invalid %s %q", networkEnv, want)
+	}
+	if network == "" {
+		fmt.Fprintf(os.Stderr, "warning: key has no network tag; assuming %s\n", want)
+		return want, key
+	}
+	if network != want {
+		errorf("key is for network %s, but %s is %s", network, networkEnv, want)
+	}
+	return network, key
+}
+
+// tagKey returns key tagged with network, if any.
+func tagKey(network, key string) string {
+	if network == "" {
+		return key
+	}
+	return network + ":" + key
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519/chainkd"
+)
+
+// signtemplate signs a transaction template offline,
+// such as on an air-gapped machine, with a root xprv.
+// It adds a signature for each signature witness key
+// naming the xprv's xpub, or, if a path is given, for
+// only those with that derivation path, and prints the
+// updated template. It refuses to sign a signature witness
+// without a build commitment, or whose transaction no longer
+// satisfies it.
+func signtemplate(args []string) {
+	if len(args) < 2 {
+		errorf("must specify xprv and template")
+	}
+	_, xprv := mustXPrv(args[0])
+	path := make([][]byte, 0, len(args)-2)
+	for _, a := range args[2:] {
+		p, err := hex.DecodeString(a)
+		if err != nil {
+			errorf("could not parse %s as hex string", a)
+		}
+		path = append(path, p)
+	}
+
+	inp, _ := input(args, 1, false)
+	tpl := mustDecodeTemplate(inp)
+
+	before := countSigs(tpl)
+	signFn := signing.XPrvSignFunc([]chainkd.XPrv{xprv})
+	if len(path) > 0 {
+		xprvSignFn := signFn
+		signFn = func(ctx context.Context, xpub string, keyPath [][]byte, h [32]byte) ([]byte, error) {
+			if !equalPaths(keyPath, path) {
+				return nil, nil
+			}
+			return xprvSignFn(ctx, xpub, keyPath, h)
+		}
+	}
+	xpub := xprv.XPub().String()
+	// Offline, there's no record of which commitments the builder
+	// computed, but signing.Sign checks that the transaction still
+	// satisfies them, so none may be missing.
+	err := signing.RequireCommitments(tpl, []string{xpub}, func([]byte) (bool, error) { return true, nil })
+	if err != nil {
+		errorf("refusing to sign template: %s", err)
+	}
+	err = signing.Sign(context.Background(), tpl, []string{xpub}, signFn)
+	if err != nil {
+		errorf("error signing template: %s", err)
+	}
+	if countSigs(tpl) == before {
+		errorf("template has no unsigned keys matching xpub %s", xpub)
+	}
+
+	printTemplate(tpl)
+}
+
+// qrtemplate converts a transaction template between
+// JSON and the compact encoding that fits in a QR code.
+func qrtemplate(args []string) {
+	decode := len(args) > 0 && args[0] == "-decode"
+	if decode {
+		args = args[1:]
+	}
+	inp, _ := input(args, 0, false)
+	tpl := mustDecodeTemplate(inp)
+	if decode {
+		printTemplate(tpl)
+		return
+	}
+	enc, err := signing.EncodeCompact(tpl)
+	if err != nil {
+		errorf("error encoding template: %s", err)
+	}
+	fmt.Println(enc)
+}
+
+// sigbundle prints the compact bundle of the signatures in
+// a signed template, for carrying back from an offline signer,
+// or, given a bundle, adds its signatures to the template
+// and prints the result.
+func sigbundle(args []string) {
+	inp, usedStdin := input(args, 0, false)
+	tpl := mustDecodeTemplate(inp)
+	if len(args) < 2 {
+		bundle, err := signing.EncodeSigs(tpl)
+		if err != nil {
+			errorf("error encoding signatures: %s", err)
+		}
+		fmt.Println(bundle)
+		return
+	}
+	bundle, _ := input(args, 1, usedStdin)
+	err := signing.ApplySigs(tpl, strings.TrimSpace(bundle))
+	if err != nil {
+		errorf("error applying signatures: %s", err)
+	}
+	printTemplate(tpl)
+}
+
+// mustDecodeTemplate decodes a template
+// from JSON or from its compact encoding.
+func mustDecodeTemplate(s string) *signing.Template {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, signing.CompactTemplatePrefix) {
+		tpl, err := signing.DecodeCompact(s)
+		if err != nil {
+			errorf("error decoding template: %s", err)
+		}
+		return tpl
+	}
+	tpl := new(signing.Template)
+	err := json.Unmarshal([]byte(s), tpl)
+	if err != nil {
+		errorf("error unmarshaling template: %s", err)
+	}
+	return tpl
+}
+
+func printTemplate(tpl *signing.Template) {
+	out, err := json.MarshalIndent(tpl, "", "  ")
+	if err != nil {
+		errorf("error marshaling template: %s", err)
+	}
+	fmt.Println(string(out))
+}
+
+func countSigs(tpl *signing.Template) int {
+	var n int
+	for _, si := range tpl.SigningInstructions {
+		for _, c := range si.WitnessComponents {
+			if sw, ok := c.(*signing.SignatureWitness); ok {
+				for _, sig := range sw.Sigs {
+					if len(sig) > 0 {
+						n++
+					}
+				}
+			}
+		}
+	}
+	return n
+}
+
+func equalPaths(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
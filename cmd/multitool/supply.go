@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+// supply totals the issuances and retirements of assets
+// in a blockchain, read from a block file or from a core's
+// database, and prints the supply of each.
+func supply(args []string) {
+	var flags flag.FlagSet
+	dbURL := flags.String("db", "", "read blocks from the database at `url` instead of a file")
+	flags.Usage = func() {
+		fmt.Println("usage: multitool supply [-db url | file] [assetid...]")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	var filename string
+	if *dbURL == "" {
+		if len(args) == 0 {
+			flags.Usage()
+		}
+		filename, args = args[0], args[1:]
+	}
+	var assetIDs []bc.AssetID
+	for _, arg := range args {
+		var id bc.AssetID
+		err := id.UnmarshalText([]byte(arg))
+		if err != nil {
+			errorf("error: bad asset id %s: %s", arg, err)
+		}
+		assetIDs = append(assetIDs, id)
+	}
+
+	n, get := openBlocks(*dbURL, filename)
+	supplies := make(state.Supplies)
+	for i := 0; i < n; i++ {
+		b, err := get(i)
+		if err != nil {
+			errorf("error: %s", err)
+		}
+		for _, tx := range b.Transactions {
+			err = supplies.ApplyTx(tx)
+			if err != nil {
+				errorf("error: block %d: %s", b.Height, err)
+			}
+		}
+	}
+
+	if len(assetIDs) == 0 {
+		for id := range supplies {
+			assetIDs = append(assetIDs, id)
+		}
+		sort.Sort(byAssetID(assetIDs))
+	}
+	fmt.Printf("%-64s %20s %20s %20s\n", "ASSET", "ISSUED", "RETIRED", "CIRCULATING")
+	for _, id := range assetIDs {
+		s := supplies[id]
+		fmt.Printf("%-64s %20d %20d %20d\n", id, s.Issued, s.Retired, s.Circulating())
+	}
+}
+
+type byAssetID []bc.AssetID
+
+func (a byAssetID) Len() int           { return len(a) }
+func (a byAssetID) Less(i, j int) bool { return bytes.Compare(a[i][:], a[j][:]) < 0 }
+func (a byAssetID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
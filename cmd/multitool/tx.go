@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// tx decodes a transaction, given as wire hex or as the JSON
+// printed by -to-json, and checks that it survives a roundtrip
+// through both forms: that reserializing it reproduces the
+// original bytes and hash. It then pretty-prints the transaction,
+// or with -to-hex or -to-json prints it in that form.
+func tx(args []string) {
+	var flags flag.FlagSet
+	toHex := flags.Bool("to-hex", false, "print the transaction as wire hex")
+	toJSON := flags.Bool("to-json", false, "print the transaction as JSON")
+	flags.Usage = func() {
+		fmt.Println("usage: multitool tx [-to-hex|-to-json] tx")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if *toHex && *toJSON {
+		flags.Usage()
+	}
+
+	inp, _ := input(flags.Args(), 0, false)
+	inp = strings.TrimSpace(inp)
+	var (
+		txdata bc.TxData
+		wire   []byte
+		err    error
+	)
+	if strings.HasPrefix(inp, "{") {
+		var j txJSON
+		err = json.Unmarshal([]byte(inp), &j)
+		if err != nil {
+			errorf("error unmarshaling tx JSON: %s", err)
+		}
+		tx, err := j.txData()
+		if err != nil {
+			errorf("error in tx JSON: %s", err)
+		}
+		txdata = *tx
+	} else {
+		wire, err = hex.DecodeString(inp)
+		if err != nil {
+			errorf("error decoding hex: %s", err)
+		}
+		err = txdata.UnmarshalText([]byte(inp))
+		if err != nil {
+			errorf("error unmarshaling tx: %s", err)
+		}
+	}
+
+	err = checkRoundtrip(&txdata, wire)
+	if err != nil {
+		errorf("roundtrip failed: %s", err)
+	}
+
+	switch {
+	case *toHex:
+		b, _ := txdata.MarshalText() // error is impossible
+		fmt.Println(string(b))
+	case *toJSON:
+		b, err := json.MarshalIndent(toTxJSON(&txdata), "", "  ")
+		if err != nil {
+			errorf("error marshaling tx JSON: %s", err)
+		}
+		fmt.Println(string(b))
+	default:
+		spew.Printf("%v\n", txdata)
+		h := txdata.Hash()
+		fmt.Printf("hash: %x\n", h[:])
+	}
+}
+
+// checkRoundtrip checks that tx serializes to wire, if given,
+// and that it converts to JSON and back to a transaction
+// with the same serialization and hash.
+func checkRoundtrip(tx *bc.TxData, wire []byte) error {
+	var buf bytes.Buffer
+	_, err := tx.WriteTo(&buf)
+	if err != nil {
+		return errors.Wrap(err, "serializing")
+	}
+	if wire != nil && !bytes.Equal(buf.Bytes(), wire) {
+		return fmt.Errorf("reserialized to %x", buf.Bytes())
+	}
+
+	b, err := json.Marshal(toTxJSON(tx))
+	if err != nil {
+		return errors.Wrap(err, "marshaling JSON")
+	}
+	var j txJSON
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return errors.Wrap(err, "unmarshaling JSON")
+	}
+	tx2, err := j.txData()
+	if err != nil {
+		return errors.Wrap(err, "converting JSON")
+	}
+	var buf2 bytes.Buffer
+	_, err = tx2.WriteTo(&buf2)
+	if err != nil {
+		return errors.Wrap(err, "serializing from JSON")
+	}
+	if !bytes.Equal(buf2.Bytes(), buf.Bytes()) {
+		return fmt.Errorf("JSON reserialized to %x, want %x", buf2.Bytes(), buf.Bytes())
+	}
+	if h1, h2 := tx.Hash(), tx2.Hash(); h1 != h2 {
+		return fmt.Errorf("JSON hashed to %x, want %x", h2[:], h1[:])
+	}
+	return nil
+}
+
+// txJSON is the canonical JSON form of a transaction. Unlike
+// the annotated form returned by Chain Core, it contains every
+// serialized field, so it converts back to the same transaction.
+type txJSON struct {
+	Version       uint64             `json:"version"`
+	Inputs        []inputJSON        `json:"inputs"`
+	Outputs       []outputJSON       `json:"outputs"`
+	MinTime       uint64             `json:"min_time"`
+	MaxTime       uint64             `json:"max_time"`
+	ReferenceData chainjson.HexBytes `json:"reference_data"`
+}
+
+type inputJSON struct {
+	Type          string               `json:"type"`
+	AssetVersion  uint64               `json:"asset_version"`
+	ReferenceData chainjson.HexBytes   `json:"reference_data"`
+	Arguments     []chainjson.HexBytes `json:"arguments"`
+
+	// spend
+	SpentOutput      *bc.Outpoint    `json:"spent_output,omitempty"`
+	OutputCommitment *commitmentJSON `json:"output_commitment,omitempty"`
+
+	// issuance
+	Nonce           chainjson.HexBytes `json:"nonce,omitempty"`
+	Amount          uint64             `json:"amount,omitempty"`
+	AssetID         *bc.AssetID        `json:"asset_id,omitempty"` // informational; checked if present
+	InitialBlock    *bc.Hash           `json:"initial_block,omitempty"`
+	VMVersion       uint64             `json:"vm_version,omitempty"`
+	IssuanceProgram chainjson.HexBytes `json:"issuance_program,omitempty"`
+}
+
+type outputJSON struct {
+	AssetVersion uint64 `json:"asset_version"`
+	commitmentJSON
+	ReferenceData chainjson.HexBytes `json:"reference_data"`
+}
+
+type commitmentJSON struct {
+	AssetID        bc.AssetID         `json:"asset_id"`
+	Amount         uint64             `json:"amount"`
+	VMVersion      uint64             `json:"vm_version"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	Confidential   *struct {
+		Commitment chainjson.HexBytes `json:"commitment"`
+		RangeProof chainjson.HexBytes `json:"range_proof"`
+	} `json:"confidential,omitempty"`
+}
+
+func toTxJSON(tx *bc.TxData) *txJSON {
+	j := &txJSON{
+		Version:       tx.Version,
+		Inputs:        []inputJSON{},
+		Outputs:       []outputJSON{},
+		MinTime:       tx.MinTime,
+		MaxTime:       tx.MaxTime,
+		ReferenceData: tx.ReferenceData,
+	}
+	for _, in := range tx.Inputs {
+		ij := inputJSON{
+			AssetVersion:  in.AssetVersion,
+			ReferenceData: in.ReferenceData,
+		}
+		switch x := in.TypedInput.(type) {
+		case *bc.SpendInput:
+			ij.Type = "spend"
+			ij.SpentOutput = &x.Outpoint
+			c := toCommitmentJSON(&x.OutputCommitment)
+			ij.OutputCommitment = &c
+			ij.Arguments = hexSlices(x.Arguments)
+		case *bc.IssuanceInput:
+			assetID := x.AssetID()
+			ij.Type = "issuance"
+			ij.Nonce = x.Nonce
+			ij.Amount = x.Amount
+			ij.AssetID = &assetID
+			ij.InitialBlock = &x.InitialBlock
+			ij.VMVersion = x.VMVersion
+			ij.IssuanceProgram = x.IssuanceProgram
+			ij.Arguments = hexSlices(x.Arguments)
+		}
+		j.Inputs = append(j.Inputs, ij)
+	}
+	for _, out := range tx.Outputs {
+		j.Outputs = append(j.Outputs, outputJSON{
+			AssetVersion:   out.AssetVersion,
+			commitmentJSON: toCommitmentJSON(&out.OutputCommitment),
+			ReferenceData:  out.ReferenceData,
+		})
+	}
+	return j
+}
+
+func (j *txJSON) txData() (*bc.TxData, error) {
+	tx := &bc.TxData{
+		Version:       j.Version,
+		MinTime:       j.MinTime,
+		MaxTime:       j.MaxTime,
+		ReferenceData: j.ReferenceData,
+	}
+	for i, ij := range j.Inputs {
+		in := &bc.TxInput{
+			AssetVersion:  ij.AssetVersion,
+			ReferenceData: ij.ReferenceData,
+		}
+		switch ij.Type {
+		case "spend":
+			if ij.SpentOutput == nil || ij.OutputCommitment == nil {
+				return nil, fmt.Errorf("input %d: spend needs spent_output and output_commitment", i)
+			}
+			oc, err := ij.OutputCommitment.outputCommitment()
+			if err != nil {
+				return nil, errors.Wrapf(err, "input %d", i)
+			}
+			in.TypedInput = &bc.SpendInput{
+				Outpoint:         *ij.SpentOutput,
+				OutputCommitment: oc,
+				Arguments:        byteSlices(ij.Arguments),
+			}
+		case "issuance":
+			if ij.InitialBlock == nil {
+				return nil, fmt.Errorf("input %d: issuance needs initial_block", i)
+			}
+			ii := &bc.IssuanceInput{
+				Nonce:           ij.Nonce,
+				Amount:          ij.Amount,
+				InitialBlock:    *ij.InitialBlock,
+				VMVersion:       ij.VMVersion,
+				IssuanceProgram: ij.IssuanceProgram,
+				Arguments:       byteSlices(ij.Arguments),
+			}
+			if ij.AssetID != nil && *ij.AssetID != ii.AssetID() {
+				return nil, fmt.Errorf("input %d: asset_id %s does not match issuance program and initial block (%s)", i, ij.AssetID, ii.AssetID())
+			}
+			in.TypedInput = ii
+		default:
+			return nil, fmt.Errorf("input %d: unknown type %q", i, ij.Type)
+		}
+		tx.Inputs = append(tx.Inputs, in)
+	}
+	for i, oj := range j.Outputs {
+		oc, err := oj.commitmentJSON.outputCommitment()
+		if err != nil {
+			return nil, errors.Wrapf(err, "output %d", i)
+		}
+		tx.Outputs = append(tx.Outputs, &bc.TxOutput{
+			AssetVersion:     oj.AssetVersion,
+			OutputCommitment: oc,
+			ReferenceData:    oj.ReferenceData,
+		})
+	}
+	return tx, nil
+}
+
+func toCommitmentJSON(oc *bc.OutputCommitment) commitmentJSON {
+	c := commitmentJSON{
+		AssetID:        oc.AssetID,
+		Amount:         oc.Amount,
+		VMVersion:      oc.VMVersion,
+		ControlProgram: oc.ControlProgram,
+	}
+	if oc.Confidential != nil {
+		c.Confidential = &struct {
+			Commitment chainjson.HexBytes `json:"commitment"`
+			RangeProof chainjson.HexBytes `json:"range_proof"`
+		}{oc.Confidential.Commitment[:], oc.Confidential.RangeProof}
+	}
+	return c
+}
+
+func (c *commitmentJSON) outputCommitment() (bc.OutputCommitment, error) {
+	oc := bc.OutputCommitment{
+		AssetAmount:    bc.AssetAmount{AssetID: c.AssetID, Amount: c.Amount},
+		VMVersion:      c.VMVersion,
+		ControlProgram: c.ControlProgram,
+	}
+	if c.Confidential != nil {
+		if len(c.Confidential.Commitment) != 32 {
+			return oc, fmt.Errorf("amount commitment is %d bytes, want 32", len(c.Confidential.Commitment))
+		}
+		oc.Confidential = &bc.ConfidentialAmount{RangeProof: c.Confidential.RangeProof}
+		copy(oc.Confidential.Commitment[:], c.Confidential.Commitment)
+	}
+	return oc, nil
+}
+
+func hexSlices(a [][]byte) []chainjson.HexBytes {
+	res := make([]chainjson.HexBytes, 0, len(a))
+	for _, b := range a {
+		res = append(res, b)
+	}
+	return res
+}
+
+func byteSlices(a []chainjson.HexBytes) [][]byte {
+	var res [][]byte
+	for _, b := range a {
+		res = append(res, b)
+	}
+	return res
+}
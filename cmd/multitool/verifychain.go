@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/validation"
+)
+
+// A violation is a consensus rule broken by a block,
+// or by one of its transactions if tx is non-nil.
+type violation struct {
+	height uint64
+	tx     *bc.Tx
+	pos    int
+	err    error
+}
+
+func (v violation) String() string {
+	s := fmt.Sprintf("block %d", v.height)
+	if v.tx != nil {
+		s += fmt.Sprintf(" tx %d (%s)", v.pos, v.tx.Hash)
+	}
+	return s + ": " + v.err.Error()
+}
+
+// verifychain re-validates a blockchain from its initial block,
+// read from a block file or from a core's database. Unlike a core
+// accepting blocks, it doesn't stop at the first broken rule, but
+// applies every block as committed and reports all it finds.
+func verifychain(args []string) {
+	var flags flag.FlagSet
+	dbURL := flags.String("db", "", "read blocks from the database at `url` instead of a file")
+	flags.Usage = func() {
+		fmt.Println("usage: multitool verifychain [-db url] [file]")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+
+	if (*dbURL == "") == (flags.NArg() == 0) || flags.NArg() > 1 {
+		flags.Usage()
+	}
+	n, get := openBlocks(*dbURL, flags.Arg(0))
+	if n == 0 {
+		errorf("error: no blocks")
+	}
+
+	var (
+		prev       *bc.Block
+		initial    bc.Hash
+		snapshot   = state.Empty()
+		txs        int
+		violations []violation
+		start      = time.Now()
+	)
+	for i := 0; i < n; i++ {
+		b, err := get(i)
+		if err != nil {
+			errorf("error: %s", err)
+		}
+		if prev == nil {
+			initial = b.Hash()
+		}
+		vs := verifyBlock(snapshot, initial, prev, b)
+		violations = append(violations, vs...)
+		txs += len(b.Transactions)
+		prev = b
+	}
+
+	fmt.Printf("initial block:  %s\n", initial)
+	fmt.Printf("heights:        1 through %d\n", prev.Height)
+	fmt.Printf("latest block:   %s (%s)\n", prev.Hash(), prev.Time().UTC().Format(time.RFC3339))
+	fmt.Printf("blocks:         %d\n", n)
+	fmt.Printf("transactions:   %d\n", txs)
+	fmt.Printf("state root:     %s\n", snapshot.Tree.RootHash())
+	fmt.Printf("elapsed:        %s\n", time.Since(start))
+	fmt.Printf("violations:     %d\n", len(violations))
+	for _, v := range violations {
+		fmt.Println("  " + v.String())
+	}
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
+
+// verifyBlock checks b against the rules for accepting a block
+// that follows prev, and applies it to snapshot.
+// It makes the same checks as validation.ValidateBlockForAccept,
+// but reports each failure instead of stopping at the first.
+func verifyBlock(snapshot *state.Snapshot, initial bc.Hash, prev, b *bc.Block) []violation {
+	var vs []violation
+	bad := func(err error) {
+		vs = append(vs, violation{height: b.Height, err: err})
+	}
+
+	var prevHeader *bc.BlockHeader
+	if prev != nil {
+		prevHeader = &prev.BlockHeader
+		err := validation.CheckBlockSig(prevHeader, b)
+		if err != nil {
+			bad(err)
+		}
+	}
+	for _, err := range validation.CheckBlockHeader(prevHeader, b) {
+		bad(err)
+	}
+
+	validation.ApplySignals(snapshot, b)
+	snapshot.PruneIssuances(b.TimestampMS)
+	for i, tx := range b.Transactions {
+		err := validation.CheckTxWellFormed(tx)
+		if err == nil {
+			err = validation.ConfirmTx(snapshot, initial, b, tx)
+		}
+		if err != nil {
+			vs = append(vs, violation{b.Height, tx, i, err})
+		}
+		// The block was committed, so its transactions
+		// are applied regardless, to go on checking the
+		// blocks that follow.
+		err = validation.ApplyTx(snapshot, tx)
+		if err != nil {
+			vs = append(vs, violation{b.Height, tx, i, err})
+		}
+	}
+	if b.AssetsMerkleRoot != snapshot.Tree.RootHash() {
+		bad(validation.ErrBadStateRoot)
+	}
+	return vs
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+func TestVerifyBlockLimits(t *testing.T) {
+	txs := []*bc.Tx{
+		bc.NewTx(bc.TxData{Version: 1, MinTime: 1}),
+		bc.NewTx(bc.TxData{Version: 1, MinTime: 2}),
+	}
+
+	cases := []struct {
+		limits vmutil.BlockLimits
+		want   bool
+	}{
+		{vmutil.BlockLimits{}, false},
+		{vmutil.BlockLimits{MaxTxs: 2}, false},
+		{vmutil.BlockLimits{MaxTxs: 1}, true},
+	}
+	for _, c := range cases {
+		prev := &bc.Block{BlockHeader: bc.BlockHeader{
+			Height:           1,
+			ConsensusProgram: vmutil.BlockLimitsProgram(c.limits, []byte{byte(vm.OP_TRUE)}),
+		}}
+		b := &bc.Block{
+			BlockHeader: bc.BlockHeader{
+				PreviousBlockHash:      prev.Hash(),
+				Height:                 2,
+				TransactionsMerkleRoot: validation.CalcMerkleRoot(txs),
+			},
+			Transactions: txs,
+		}
+
+		var got bool
+		for _, v := range verifyBlock(state.Empty(), prev.Hash(), prev, b) {
+			if v.tx == nil && errors.Root(v.err) == validation.ErrBlockTooBig {
+				got = true
+			}
+		}
+		if got != c.want {
+			t.Errorf("limits %+v: reported block too big = %v, want %v", c.limits, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Command signerd signs blocks for a Chain Core block signer,
+// keeping the block-signing key out of the Chain Core process.
+//
+// The Chain Core validates each block, then sends its header to
+// signerd, authenticated with the core's network RPC key, which
+// must be listed in PEER_KEYS. Signerd refuses to sign two
+// different blocks at the same height, or a block below or
+// earlier than the last one it signed.
+//
+// On first run, signerd generates a key in KEY_FILE and logs its
+// public key. Configure the Chain Core as a block signer with
+// that key as its block_pub, and set its SIGNER_SERVICE_URL
+// to signerd's address.
+//
+// If KMS_KEY is set, signerd instead signs with the key it names
+// in a cloud KMS, or in a KMS-wrapped envelope file. See package
+// kms for the forms of KMS_KEY.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"chain/core"
+	"chain/core/blocksigner"
+	"chain/core/rpc"
+	"chain/crypto/ed25519"
+	"chain/crypto/kms"
+	"chain/env"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var (
+	listen       = env.String("LISTEN", ":1998")
+	keyFile      = env.String("KEY_FILE", "signerd.key")
+	kmsKey       = env.String("KMS_KEY", "")
+	stateFile    = env.String("STATE_FILE", "signerd.state")
+	blockchainID = env.String("BLOCKCHAIN_ID", "")
+	peerKeys     = env.StringSlice("PEER_KEYS") // hex public keys of cores allowed to request signatures
+	tlsCrt       = env.String("TLSCRT", "")
+	tlsKey       = env.String("TLSKEY", "")
+)
+
+func main() {
+	ctx := context.Background()
+	env.Parse()
+
+	if *blockchainID == "" {
+		log.Fatal(ctx, log.KeyError, "BLOCKCHAIN_ID is required")
+	}
+	allowed := make(map[string]bool)
+	for _, k := range *peerKeys {
+		pub, err := hex.DecodeString(strings.TrimSpace(k))
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			log.Fatal(ctx, log.KeyError, fmt.Sprintf("bad peer key %q", k))
+		}
+		allowed[string(pub)] = true
+	}
+	if len(allowed) == 0 {
+		log.Fatal(ctx, log.KeyError, "PEER_KEYS is required")
+	}
+
+	var service *blocksigner.Service
+	if *kmsKey != "" {
+		k, err := kms.Open(ctx, *kmsKey)
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, err)
+		}
+		service, err = blocksigner.NewKMSService(k, *stateFile)
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, err)
+		}
+	} else {
+		key, err := loadKey(ctx, *keyFile)
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, err)
+		}
+		service, err = blocksigner.NewService(key, *stateFile)
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, err)
+		}
+	}
+	log.Messagef(ctx, "Signing blocks with key %x", []byte(service.Pub()))
+
+	verifier := &rpc.Verifier{BlockchainID: *blockchainID}
+	http.Handle(blocksigner.ServicePath, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 1e5))
+		if err != nil {
+			core.WriteHTTPError(ctx, w, errors.WithDetail(httpjson.ErrBadRequest, err.Error()))
+			return
+		}
+		pub, err := verifier.Verify(req, body)
+		if err != nil {
+			core.WriteHTTPError(ctx, w, err)
+			return
+		}
+		if !allowed[string(pub)] {
+			core.WriteHTTPError(ctx, w, errors.WithDetailf(rpc.ErrBadSignature, "key %x is not in PEER_KEYS", []byte(pub)))
+			return
+		}
+
+		var in struct {
+			Header *bc.BlockHeader `json:"header"`
+		}
+		err = httpjson.Read(ctx, bytes.NewReader(body), &in)
+		if err != nil || in.Header == nil {
+			core.WriteHTTPError(ctx, w, errors.WithDetail(httpjson.ErrBadRequest, "header is required"))
+			return
+		}
+		ctx = log.AddFields(ctx, log.KeyBlockHeight, in.Header.Height)
+		sig, err := service.SignHeader(ctx, in.Header)
+		if err != nil {
+			core.WriteHTTPError(ctx, w, err)
+			return
+		}
+		log.Write(ctx, log.KeyMessage, "signed block", "peer", hex.EncodeToString(pub))
+		httpjson.Write(ctx, w, 200, sig)
+	}))
+
+	if *tlsCrt != "" {
+		cert, err := tls.X509KeyPair([]byte(*tlsCrt), []byte(*tlsKey))
+		if err != nil {
+			log.Fatal(ctx, log.KeyError, errors.Wrap(err, "parsing tls X509 key pair"))
+		}
+		server := &http.Server{
+			Addr:    *listen,
+			Handler: http.DefaultServeMux,
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+			},
+		}
+		err = server.ListenAndServeTLS("", "")
+		log.Fatal(ctx, log.KeyError, err)
+	}
+	err := http.ListenAndServe(*listen, http.DefaultServeMux)
+	log.Fatal(ctx, log.KeyError, err)
+}
+
+// loadKey reads the hex-encoded private key in path,
+// first generating one if path doesn't exist.
+func loadKey(ctx context.Context, path string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		err = ioutil.WriteFile(path, []byte(hex.EncodeToString(prv)+"\n"), 0600)
+		if err != nil {
+			return nil, errors.Wrap(err, "writing key file")
+		}
+		log.Messagef(ctx, "Generated new block-signing key in %s", path)
+		return prv, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading key file")
+	}
+	prv, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(prv) != ed25519.PrivateKeySize {
+		return nil, errors.New("key file does not hold a hex-encoded ed25519 private key")
+	}
+	return ed25519.PrivateKey(prv), nil
+}
@@ -2,15 +2,41 @@ package core
 
 import (
 	"context"
-	"errors"
 
 	"chain/core/accesstoken"
+	"chain/core/tenant"
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
+	"chain/errors"
 	"chain/net/http/httpjson"
 )
 
-var errCurrentToken = errors.New("token cannot delete itself")
+var (
+	errCurrentToken      = errors.New("token cannot delete itself")
+	errOtherTenant       = errors.New("cannot act for another tenant")
+	errCurrentTokenQuota = errors.New("token cannot set its own priority quota")
+)
 
-func (h *Handler) createAccessToken(ctx context.Context, x struct{ ID, Type string }) (*accesstoken.Token, error) {
+// createAccessToken creates an access token for the requesting
+// tenant. The default tenant may instead name another tenant,
+// thereby creating it. A network token given the public key of
+// a peer core also authenticates requests signed by that core.
+func (h *Handler) createAccessToken(ctx context.Context, x struct {
+	ID, Type, Tenant string
+	PublicKey        chainjson.HexBytes `json:"public_key"`
+}) (*accesstoken.Token, error) {
+	if x.Tenant != "" && x.Tenant != tenant.FromContext(ctx) {
+		if tenant.FromContext(ctx) != tenant.Default {
+			return nil, errOtherTenant
+		}
+		ctx = tenant.NewContext(ctx, x.Tenant)
+	}
+	if x.PublicKey != nil {
+		if x.Type != "network" {
+			return nil, errors.WithDetail(accesstoken.ErrBadKey, "only network access tokens can have a public key")
+		}
+		return h.AccessTokens.CreateForKey(ctx, x.ID, ed25519.PublicKey(x.PublicKey))
+	}
 	return h.AccessTokens.Create(ctx, x.ID, x.Type)
 }
 
@@ -35,10 +61,34 @@ func (h *Handler) listAccessTokens(ctx context.Context, x requestQuery) (*page,
 	}, nil
 }
 
+// updateAccessToken sets the high-priority quota of an access
+// token. Quotas share the core's capacity among all tenants, so
+// only the default tenant may set them, and no token its own.
+func (h *Handler) updateAccessToken(ctx context.Context, x struct {
+	ID            string
+	PriorityQuota int `json:"priority_quota"`
+}) error {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
+	if id := accessTokenID(ctx); id != "" && id == x.ID {
+		return errCurrentTokenQuota
+	}
+	return h.AccessTokens.SetPriorityQuota(ctx, x.ID, x.PriorityQuota)
+}
+
 func (h *Handler) deleteAccessToken(ctx context.Context, x struct{ ID string }) error {
 	currentID, _, _ := httpjson.Request(ctx).BasicAuth()
 	if currentID == x.ID {
 		return errCurrentToken
 	}
-	return h.AccessTokens.Delete(ctx, x.ID)
+	err := h.AccessTokens.Delete(ctx, x.ID)
+	if err != nil {
+		return err
+	}
+	// Revoke the token at once in this process; other
+	// processes stop accepting it within tokenExpiry.
+	h.authn.forget(x.ID)
+	h.peers.forget(x.ID)
+	return nil
 }
@@ -5,12 +5,18 @@ package accesstoken
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"fmt"
 	"regexp"
 	"time"
 
+	"github.com/lib/pq"
+
+	"chain/core/tenant"
+	"chain/crypto/ed25519"
 	"chain/crypto/sha3pool"
 	"chain/database/pg"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 )
 
@@ -23,6 +29,17 @@ var (
 	ErrDuplicateID = errors.New("duplicate access token ID")
 	// ErrBadType is returned when Create is called with a bad type.
 	ErrBadType = errors.New("type must be client or network")
+	// ErrBadQuota is returned when SetPriorityQuota is called
+	// with a negative quota.
+	ErrBadQuota = errors.New("priority quota must not be negative")
+	// ErrBadTenant is returned when Create is called for an invalid tenant.
+	ErrBadTenant = errors.New("invalid tenant")
+	// ErrBadKey is returned when CreateForKey is called
+	// with an invalid or already registered public key.
+	ErrBadKey = errors.New("invalid public key")
+	// ErrBadRateLimit is returned when SetRateLimit is called
+	// with a negative limit.
+	ErrBadRateLimit = errors.New("rate limit must not be negative")
 
 	defaultLimit = 100
 
@@ -36,15 +53,52 @@ type Token struct {
 	Token   string    `json:"token,omitempty"`
 	Type    string    `json:"type"`
 	Created time.Time `json:"created_at"`
-	sortID  string
+
+	// PriorityQuota is the number of high-priority transactions
+	// submitted with this token that may be included in each block.
+	PriorityQuota int `json:"priority_quota"`
+
+	// Tenant is the tenant the token authenticates requests for.
+	Tenant string `json:"tenant,omitempty"`
+
+	// PublicKey is the key of the peer core whose signed
+	// requests the token authenticates, if any.
+	PublicKey chainjson.HexBytes `json:"public_key,omitempty"`
+
+	// RateLimit is the number of requests per second that a
+	// peer core may make with a network token. Zero means
+	// no limit.
+	RateLimit int `json:"rate_limit"`
+
+	// LastSeenAt and LastSeenAddr tell when and from where
+	// a network token was last used, if ever. See RecordSeen.
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
+	LastSeenAddr string     `json:"last_seen_addr,omitempty"`
+
+	sortID string
 }
 
 type CredentialStore struct {
 	DB pg.DB
 }
 
-// Create generates a new access token with the given ID.
+// Create generates a new access token with the given ID,
+// belonging to the tenant that ctx acts for.
 func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token, error) {
+	return cs.create(ctx, id, typ, nil)
+}
+
+// CreateForKey generates a new network access token with the
+// given ID, like Create, that also authenticates requests from
+// another core signed with pub. Such requests need no secret.
+func (cs *CredentialStore) CreateForKey(ctx context.Context, id string, pub ed25519.PublicKey) (*Token, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.WithDetailf(ErrBadKey, "public key is %d bytes, want %d", len(pub), ed25519.PublicKeySize)
+	}
+	return cs.create(ctx, id, "network", pub)
+}
+
+func (cs *CredentialStore) create(ctx context.Context, id, typ string, pub ed25519.PublicKey) (*Token, error) {
 	if !validIDRegexp.MatchString(id) {
 		return nil, errors.WithDetailf(ErrBadID, "invalid id %q", id)
 	}
@@ -53,6 +107,11 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		return nil, errors.WithDetailf(ErrBadType, "unknown type %q", typ)
 	}
 
+	tenantID := tenant.FromContext(ctx)
+	if tenantID != tenant.Default && !validIDRegexp.MatchString(tenantID) {
+		return nil, errors.WithDetailf(ErrBadTenant, "invalid tenant %q", tenantID)
+	}
+
 	var secret [tokenSize]byte
 	_, err := rand.Read(secret[:])
 	if err != nil {
@@ -62,15 +121,22 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 	sha3pool.Sum256(hashedSecret[:], secret[:])
 
 	const q = `
-		INSERT INTO access_tokens (id, type, hashed_secret)
-		VALUES($1, $2, $3)
+		INSERT INTO access_tokens (id, type, hashed_secret, tenant, public_key)
+		VALUES($1, $2, $3, $4, $5)
 		RETURNING created, sort_id
 	`
 	var (
 		created time.Time
 		sortID  string
+		pubKey  interface{} // NULL, not an empty bytea, if pub is nil
 	)
-	err = cs.DB.QueryRow(ctx, q, id, typ, hashedSecret[:]).Scan(&created, &sortID)
+	if pub != nil {
+		pubKey = []byte(pub)
+	}
+	err = cs.DB.QueryRow(ctx, q, id, typ, hashedSecret[:], tenantID, pubKey).Scan(&created, &sortID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == "access_tokens_public_key_key" {
+		return nil, errors.WithDetail(ErrBadKey, "public key already belongs to another access token")
+	}
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetailf(ErrDuplicateID, "id %q already in use", id)
 	}
@@ -79,11 +145,13 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 	}
 
 	return &Token{
-		ID:      id,
-		Token:   fmt.Sprintf("%s:%x", id, secret),
-		Type:    typ,
-		Created: created,
-		sortID:  sortID,
+		ID:        id,
+		Token:     fmt.Sprintf("%s:%x", id, secret),
+		Type:      typ,
+		Created:   created,
+		Tenant:    tenantID,
+		PublicKey: chainjson.HexBytes(pub),
+		sortID:    sortID,
 	}, nil
 }
 
@@ -106,24 +174,55 @@ func (cs *CredentialStore) Check(ctx context.Context, id, typ string, secret []b
 	return valid, nil
 }
 
-// List lists all access tokens.
+// FindByKey returns the ID and tenant of the network
+// access token for the peer core with public key pub.
+func (cs *CredentialStore) FindByKey(ctx context.Context, pub ed25519.PublicKey) (id, tenantID string, err error) {
+	const q = `SELECT id, tenant FROM access_tokens WHERE public_key=$1 AND type='network'`
+	err = cs.DB.QueryRow(ctx, q, []byte(pub)).Scan(&id, &tenantID)
+	if err == sql.ErrNoRows {
+		return "", "", errors.WithDetailf(pg.ErrUserInputNotFound, "public key %x", []byte(pub))
+	}
+	return id, tenantID, errors.Wrap(err)
+}
+
+// Tenant returns the tenant that access token id belongs to.
+func (cs *CredentialStore) Tenant(ctx context.Context, id string) (string, error) {
+	const q = `SELECT tenant FROM access_tokens WHERE id=$1`
+	var tenantID string
+	err := cs.DB.QueryRow(ctx, q, id).Scan(&tenantID)
+	if err == sql.ErrNoRows {
+		return "", errors.WithDetailf(pg.ErrUserInputNotFound, "acccess token id %s", id)
+	}
+	return tenantID, errors.Wrap(err)
+}
+
+// List lists the access tokens visible to the tenant that ctx acts for.
 func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit int) ([]*Token, string, error) {
 	if limit == 0 {
 		limit = defaultLimit
 	}
 	const q = `
-		SELECT id, type, sort_id, created FROM access_tokens
+		SELECT id, type, sort_id, created, priority_quota, tenant, public_key,
+			rate_limit, last_seen_at, COALESCE(last_seen_addr, '')
+		FROM access_tokens
 		WHERE ($1='' OR type=$1::access_token_type) AND ($2='' OR sort_id<$2)
+			AND ($4='' OR tenant=$4)
 		ORDER BY sort_id DESC
 		LIMIT $3
 	`
 	var tokens []*Token
-	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id, typ, sortID string, created time.Time) {
+	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, tenant.FromContext(ctx), func(id, typ, sortID string, created time.Time, quota int, tenantID string, pub []byte, rateLimit int, lastSeenAt *time.Time, lastSeenAddr string) {
 		tokens = append(tokens, &Token{
-			ID:      id,
-			Type:    typ,
-			Created: created,
-			sortID:  sortID,
+			ID:            id,
+			Type:          typ,
+			Created:       created,
+			PriorityQuota: quota,
+			Tenant:        tenantID,
+			PublicKey:     pub,
+			RateLimit:     rateLimit,
+			LastSeenAt:    lastSeenAt,
+			LastSeenAddr:  lastSeenAddr,
+			sortID:        sortID,
 		})
 	})
 	if err != nil {
@@ -138,10 +237,99 @@ func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit in
 	return tokens, next, nil
 }
 
+// SetPriorityQuota sets the number of high-priority transactions
+// submitted with access token id that may be included in each block.
+func (cs *CredentialStore) SetPriorityQuota(ctx context.Context, id string, quota int) error {
+	if quota < 0 {
+		return errors.WithDetailf(ErrBadQuota, "quota %d", quota)
+	}
+
+	const q = `UPDATE access_tokens SET priority_quota=$2 WHERE id=$1 AND ($3='' OR tenant=$3)`
+	res, err := cs.DB.Exec(ctx, q, id, quota, tenant.FromContext(ctx))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	if updated == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "acccess token id %s", id)
+	}
+	return nil
+}
+
+// PriorityQuota returns the high-priority quota of access token id.
+func (cs *CredentialStore) PriorityQuota(ctx context.Context, id string) (int, error) {
+	const q = `SELECT priority_quota FROM access_tokens WHERE id=$1`
+	var quota int
+	err := cs.DB.QueryRow(ctx, q, id).Scan(&quota)
+	if err == sql.ErrNoRows {
+		return 0, errors.WithDetailf(pg.ErrUserInputNotFound, "acccess token id %s", id)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err)
+	}
+	return quota, nil
+}
+
+// SetRateLimit sets the number of requests per second that
+// the peer core using network access token id may make.
+// Zero removes the limit.
+func (cs *CredentialStore) SetRateLimit(ctx context.Context, id string, perSecond int) error {
+	if perSecond < 0 {
+		return errors.WithDetailf(ErrBadRateLimit, "rate limit %d", perSecond)
+	}
+
+	const q = `
+		UPDATE access_tokens SET rate_limit=$2
+		WHERE id=$1 AND type='network' AND ($3='' OR tenant=$3)
+	`
+	res, err := cs.DB.Exec(ctx, q, id, perSecond, tenant.FromContext(ctx))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	if updated == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "network access token id %s", id)
+	}
+	return nil
+}
+
+// RateLimit returns the rate limit of access token id.
+func (cs *CredentialStore) RateLimit(ctx context.Context, id string) (int, error) {
+	const q = `SELECT rate_limit FROM access_tokens WHERE id=$1`
+	var perSecond int
+	err := cs.DB.QueryRow(ctx, q, id).Scan(&perSecond)
+	if err == sql.ErrNoRows {
+		return 0, errors.WithDetailf(pg.ErrUserInputNotFound, "acccess token id %s", id)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err)
+	}
+	return perSecond, nil
+}
+
+// RecordSeen records that access token id was used
+// from network address addr at time t.
+func (cs *CredentialStore) RecordSeen(ctx context.Context, id, addr string, t time.Time) error {
+	const q = `UPDATE access_tokens SET last_seen_at=$2, last_seen_addr=$3 WHERE id=$1`
+	_, err := cs.DB.Exec(ctx, q, id, t, addr)
+	return errors.Wrap(err)
+}
+
 // Delete deletes an access token by id.
+// Tenants other than the default can delete only their own tokens.
 func (cs *CredentialStore) Delete(ctx context.Context, id string) error {
-	const q = `DELETE FROM access_tokens WHERE id=$1`
-	res, err := cs.DB.Exec(ctx, q, id)
+	const q = `DELETE FROM access_tokens WHERE id=$1 AND ($2='' OR tenant=$2)`
+	res, err := cs.DB.Exec(ctx, q, id, tenant.FromContext(ctx))
 	if err != nil {
 		return errors.Wrap(err)
 	}
@@ -1,14 +1,18 @@
 package accesstoken
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/crypto/ed25519"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 )
@@ -143,6 +147,102 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestPriorityQuota(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	token := mustCreateToken(t, ctx, cs, "x", "client")
+	err := cs.SetPriorityQuota(ctx, token.ID, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cs.PriorityQuota(ctx, token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 5 {
+		t.Errorf("PriorityQuota = %d want 5", got)
+	}
+
+	err = cs.SetPriorityQuota(ctx, token.ID, -1)
+	if errors.Root(err) != ErrBadQuota {
+		t.Errorf("SetPriorityQuota(-1) error = %v want %v", err, ErrBadQuota)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	client := mustCreateToken(t, ctx, cs, "x", "client")
+	err := cs.SetRateLimit(ctx, client.ID, 5)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("SetRateLimit(client token) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	network := mustCreateToken(t, ctx, cs, "y", "network")
+	err = cs.SetRateLimit(ctx, network.ID, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cs.RateLimit(ctx, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 5 {
+		t.Errorf("RateLimit = %d want 5", got)
+	}
+
+	err = cs.SetRateLimit(ctx, network.ID, -1)
+	if errors.Root(err) != ErrBadRateLimit {
+		t.Errorf("SetRateLimit(-1) error = %v want %v", err, ErrBadRateLimit)
+	}
+
+	seen := time.Now().Round(time.Second)
+	err = cs.RecordSeen(ctx, network.ID, "10.0.0.1:1999", seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, _, err := cs.List(ctx, "network", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].LastSeenAt == nil || !tokens[0].LastSeenAt.Equal(seen) || tokens[0].LastSeenAddr != "10.0.0.1:1999" {
+		t.Errorf("List = %s, want token last seen at %s from 10.0.0.1:1999", spew.Sdump(tokens), seen)
+	}
+}
+
+func TestCreateForKey(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	_, err := cs.CreateForKey(ctx, "short", ed25519.PublicKey{1, 2, 3})
+	if errors.Root(err) != ErrBadKey {
+		t.Errorf("CreateForKey(short key) error = %v want %v", err, ErrBadKey)
+	}
+
+	pub := ed25519.PublicKey(bytes.Repeat([]byte{1}, ed25519.PublicKeySize))
+	token, err := cs.CreateForKey(ctx, "peer", pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Type != "network" {
+		t.Errorf("CreateForKey() type = %s want network", token.Type)
+	}
+	id, _, err := cs.FindByKey(ctx, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "peer" {
+		t.Errorf("FindByKey() = %s want peer", id)
+	}
+
+	_, _, err = cs.FindByKey(ctx, ed25519.PublicKey(bytes.Repeat([]byte{2}, ed25519.PublicKeySize)))
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("FindByKey(unknown key) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
 func mustCreateToken(t *testing.T, ctx context.Context, cs *CredentialStore, id, typ string) *Token {
 	token, err := cs.Create(ctx, id, typ)
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 
 	"chain/core/pin"
 	"chain/core/signers"
+	"chain/core/tenant"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
@@ -25,8 +26,18 @@ import (
 
 const maxAccountCache = 1000
 
+// cpIndexBlock is the number of control program indexes
+// that nextIndex reserves at once from account_control_program_seq.
+// The first block is [1, cpIndexBlock], the second
+// [cpIndexBlock+1, 2*cpIndexBlock], and so on.
+const cpIndexBlock = 10000 // account_control_program_seq increments by 10,000
+
 var ErrDuplicateAlias = errors.New("duplicate account alias")
 
+// ErrWatchOnly is returned when building a transaction
+// that spends from a watch-only account.
+var ErrWatchOnly = errors.New("account is watch-only")
+
 func NewManager(db *sql.DB, chain *protocol.Chain, pinStore *pin.Store) *Manager {
 	return &Manager{
 		db:          db,
@@ -46,6 +57,7 @@ type Manager struct {
 	utxoDB   *reserver
 	indexer  Saver
 	pinStore *pin.Store
+	override OverrideFunc
 
 	cacheMu    sync.Mutex
 	cache      *lru.Cache
@@ -63,7 +75,8 @@ func (m *Manager) IndexAccounts(indexer Saver) {
 	m.indexer = indexer
 }
 
-// ExpireReservations removes reservations that have expired periodically.
+// ExpireReservations removes reservations that have expired periodically,
+// along with spends that no longer count toward daily limits.
 // It blocks until the context is canceled.
 func (m *Manager) ExpireReservations(ctx context.Context, period time.Duration) {
 	ticks := time.Tick(period)
@@ -77,6 +90,10 @@ func (m *Manager) ExpireReservations(ctx context.Context, period time.Duration)
 			if err != nil {
 				log.Error(ctx, err)
 			}
+			err = m.expireSpends(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+			}
 		}
 	}
 }
@@ -85,15 +102,45 @@ type Account struct {
 	*signers.Signer
 	Alias string
 	Tags  map[string]interface{}
+
+	// WatchOnly accounts have keys held outside of Chain Core.
+	// Their outputs are indexed, but they cannot spend.
+	WatchOnly bool
+
+	// ParentID, if set, is the account whose balances and
+	// transactions roll up those of this account, as an omnibus
+	// account does its customers' sub-accounts. See SetParent.
+	ParentID string
 }
 
-// Create creates a new Account.
-func (m *Manager) Create(ctx context.Context, xpubs []string, quorum int, alias string, tags map[string]interface{}, clientToken *string) (*Account, error) {
+// Create creates a new Account belonging to the tenant
+// that ctx acts for. If watchOnly is set, the account is
+// created watch-only, as by MarkWatchOnly.
+func (m *Manager) Create(ctx context.Context, xpubs []string, quorum int, alias string, tags map[string]interface{}, watchOnly bool, clientToken *string) (*Account, error) {
 	signer, err := signers.Create(ctx, m.db, "account", xpubs, quorum, clientToken)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
+	return m.create(ctx, signer, alias, tags, watchOnly)
+}
 
+// CreateDeterministic is like Create, but derives the account ID
+// and key index from xpubs and quorum, so that a core rebuilt from
+// scratch assigns the same ID to an account with the same keys
+// and derives the same control programs for it.
+// Only one such account may exist for each set of keys and quorum.
+func (m *Manager) CreateDeterministic(ctx context.Context, xpubs []string, quorum int, alias string, tags map[string]interface{}, watchOnly bool, clientToken *string) (*Account, error) {
+	signer, err := signers.CreateDeterministic(ctx, m.db, "account", xpubs, quorum, clientToken)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return m.create(ctx, signer, alias, tags, watchOnly)
+}
+
+// create inserts the account for signer, or, if it already
+// exists, updates its alias and tags, and marks it watch-only
+// if watchOnly is set.
+func (m *Manager) create(ctx context.Context, signer *signers.Signer, alias string, tags map[string]interface{}, watchOnly bool) (*Account, error) {
 	tagsParam, err := tagsToNullString(tags)
 	if err != nil {
 		return nil, err
@@ -105,10 +152,13 @@ func (m *Manager) Create(ctx context.Context, xpubs []string, quorum int, alias
 	}
 
 	const q = `
-		INSERT INTO accounts (account_id, alias, tags) VALUES ($1, $2, $3)
-		ON CONFLICT (account_id) DO UPDATE SET alias = $2, tags = $3
+		INSERT INTO accounts (account_id, alias, tags, watch_only, tenant) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (account_id) DO UPDATE SET alias = $2, tags = $3,
+			watch_only = accounts.watch_only OR $4
+		RETURNING watch_only, parent_id
 	`
-	_, err = m.db.Exec(ctx, q, signer.ID, aliasSQL, tagsParam)
+	var parentID stdsql.NullString
+	err = m.db.QueryRow(ctx, q, signer.ID, aliasSQL, tagsParam, watchOnly, tenant.FromContext(ctx)).Scan(&watchOnly, &parentID)
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetail(ErrDuplicateAlias, "an account with the provided alias already exists")
 	} else if err != nil {
@@ -116,9 +166,11 @@ func (m *Manager) Create(ctx context.Context, xpubs []string, quorum int, alias
 	}
 
 	account := &Account{
-		Signer: signer,
-		Alias:  alias,
-		Tags:   tags,
+		Signer:    signer,
+		Alias:     alias,
+		Tags:      tags,
+		WatchOnly: watchOnly,
+		ParentID:  parentID.String,
 	}
 
 	err = m.indexAnnotatedAccount(ctx, account)
@@ -129,6 +181,56 @@ func (m *Manager) Create(ctx context.Context, xpubs []string, quorum int, alias
 	return account, nil
 }
 
+// MarkWatchOnly marks the account with the given ID as watch-only,
+// for keys held outside of Chain Core, such as in cold storage.
+// Chain Core keeps indexing the account's outputs and balances,
+// but refuses to build transactions spending from it.
+func (m *Manager) MarkWatchOnly(ctx context.Context, accountID string) (*Account, error) {
+	signer, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		UPDATE accounts SET watch_only=true WHERE account_id=$1
+		RETURNING alias, tags, watch_only, parent_id
+	`
+	return m.update(ctx, signer, q, accountID)
+}
+
+// update runs q, an UPDATE of one account returning its alias,
+// tags, watch_only, and parent_id, and reindexes the account.
+func (m *Manager) update(ctx context.Context, signer *signers.Signer, q string, args ...interface{}) (*Account, error) {
+	var (
+		alias    stdsql.NullString
+		tagsJSON []byte
+		parentID stdsql.NullString
+	)
+	account := &Account{Signer: signer}
+	err := m.db.QueryRow(ctx, q, args...).Scan(&alias, &tagsJSON, &account.WatchOnly, &parentID)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	account.Alias = alias.String
+	account.ParentID = parentID.String
+	if len(tagsJSON) > 0 {
+		err = json.Unmarshal(tagsJSON, &account.Tags)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
+	m.cacheMu.Lock()
+	m.cache.Remove(signer.ID)
+	m.cacheMu.Unlock()
+
+	err = m.indexAnnotatedAccount(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated account")
+	}
+	return account, nil
+}
+
 // FindByAlias retrieves an account's Signer record by its alias
 func (m *Manager) FindByAlias(ctx context.Context, alias string) (*signers.Signer, error) {
 	var accountID string
@@ -154,21 +256,63 @@ func (m *Manager) FindByAlias(ctx context.Context, alias string) (*signers.Signe
 	return m.findByID(ctx, accountID)
 }
 
+type cachedAccount struct {
+	signer    *signers.Signer
+	tenant    string
+	watchOnly bool
+}
+
 // findByID returns an account's Signer record by its ID.
+// Accounts of tenants other than the one ctx acts for
+// are not found.
 func (m *Manager) findByID(ctx context.Context, id string) (*signers.Signer, error) {
-	m.cacheMu.Lock()
-	cached, ok := m.cache.Get(id)
-	m.cacheMu.Unlock()
-	if ok {
-		return cached.(*signers.Signer), nil
+	account, err := m.find(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	account, err := signers.Find(ctx, m.db, "account", id)
+	return account.signer, nil
+}
+
+// findSpendable is like findByID, but returns ErrWatchOnly
+// for watch-only accounts.
+func (m *Manager) findSpendable(ctx context.Context, id string) (*signers.Signer, error) {
+	account, err := m.find(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if account.watchOnly {
+		return nil, errors.WithDetailf(ErrWatchOnly, "account id: %s", id)
+	}
+	return account.signer, nil
+}
+
+func (m *Manager) find(ctx context.Context, id string) (*cachedAccount, error) {
 	m.cacheMu.Lock()
-	m.cache.Add(id, account)
+	cached, ok := m.cache.Get(id)
 	m.cacheMu.Unlock()
+
+	var account *cachedAccount
+	if ok {
+		account = cached.(*cachedAccount)
+	} else {
+		signer, err := signers.Find(ctx, m.db, "account", id)
+		if err != nil {
+			return nil, err
+		}
+		account = &cachedAccount{signer: signer}
+		const q = `SELECT tenant, watch_only FROM accounts WHERE account_id=$1`
+		err = m.db.QueryRow(ctx, q, id).Scan(&account.tenant, &account.watchOnly)
+		if err != nil && err != stdsql.ErrNoRows {
+			return nil, errors.Wrap(err)
+		}
+		m.cacheMu.Lock()
+		m.cache.Add(id, account)
+		m.cacheMu.Unlock()
+	}
+
+	if !tenant.CanSee(ctx, account.tenant) {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "account id: %s", id)
+	}
 	return account, nil
 }
 
@@ -177,6 +321,7 @@ type controlProgram struct {
 	keyIndex       uint64
 	controlProgram []byte
 	change         bool
+	singleUse      bool
 }
 
 func (m *Manager) createControlProgram(ctx context.Context, accountID string, change bool) (*controlProgram, error) {
@@ -190,10 +335,7 @@ func (m *Manager) createControlProgram(ctx context.Context, accountID string, ch
 		return nil, err
 	}
 
-	path := signers.Path(account, signers.AccountKeySpace, idx)
-	derivedXPubs := chainkd.DeriveXPubs(account.XPubs, path)
-	derivedPKs := chainkd.XPubKeys(derivedXPubs)
-	control, err := vmutil.P2SPMultiSigProgram(derivedPKs, account.Quorum)
+	control, err := deriveControlProgram(account, idx)
 	if err != nil {
 		return nil, err
 	}
@@ -205,13 +347,34 @@ func (m *Manager) createControlProgram(ctx context.Context, accountID string, ch
 	}, nil
 }
 
+// deriveControlProgram returns the control program
+// of account at the given index.
+func deriveControlProgram(account *signers.Signer, idx uint64) ([]byte, error) {
+	path := signers.Path(account, signers.AccountKeySpace, idx)
+	derivedXPubs := chainkd.DeriveXPubs(account.XPubs, path)
+	derivedPKs := chainkd.XPubKeys(derivedXPubs)
+	return vmutil.P2SPMultiSigProgram(derivedPKs, account.Quorum)
+}
+
 // CreateControlProgram creates a control program
 // that is tied to the Account and stores it in the database.
 func (m *Manager) CreateControlProgram(ctx context.Context, accountID string, change bool) ([]byte, error) {
+	return m.createAndInsertControlProgram(ctx, accountID, change, false)
+}
+
+// CreateSingleUseControlProgram is like CreateControlProgram,
+// but flags the control program single-use. The indexer reports
+// any transaction paying to it after the first; see Reuses.
+func (m *Manager) CreateSingleUseControlProgram(ctx context.Context, accountID string) ([]byte, error) {
+	return m.createAndInsertControlProgram(ctx, accountID, false, true)
+}
+
+func (m *Manager) createAndInsertControlProgram(ctx context.Context, accountID string, change, singleUse bool) ([]byte, error) {
 	cp, err := m.createControlProgram(ctx, accountID, change)
 	if err != nil {
 		return nil, err
 	}
+	cp.singleUse = singleUse
 
 	err = m.insertAccountControlProgram(ctx, cp)
 	if err != nil {
@@ -222,23 +385,26 @@ func (m *Manager) CreateControlProgram(ctx context.Context, accountID string, ch
 
 func (m *Manager) insertAccountControlProgram(ctx context.Context, progs ...*controlProgram) error {
 	const q = `
-		INSERT INTO account_control_programs (signer_id, key_index, control_program, change)
-		SELECT unnest($1::text[]), unnest($2::bigint[]), unnest($3::bytea[]), unnest($4::boolean[])
+		INSERT INTO account_control_programs (signer_id, key_index, control_program, change, single_use)
+		SELECT unnest($1::text[]), unnest($2::bigint[]), unnest($3::bytea[]), unnest($4::boolean[]),
+			unnest($5::boolean[])
 	`
 	var (
 		accountIDs   pq.StringArray
 		keyIndexes   pq.Int64Array
 		controlProgs pq.ByteaArray
 		change       pq.BoolArray
+		singleUse    pq.BoolArray
 	)
 	for _, p := range progs {
 		accountIDs = append(accountIDs, p.accountID)
 		keyIndexes = append(keyIndexes, int64(p.keyIndex))
 		controlProgs = append(controlProgs, p.controlProgram)
 		change = append(change, p.change)
+		singleUse = append(singleUse, p.singleUse)
 	}
 
-	_, err := m.db.Exec(ctx, q, accountIDs, keyIndexes, controlProgs, change)
+	_, err := m.db.Exec(ctx, q, accountIDs, keyIndexes, controlProgs, change, singleUse)
 	return errors.Wrap(err)
 }
 
@@ -248,14 +414,13 @@ func (m *Manager) nextIndex(ctx context.Context) (uint64, error) {
 
 	if m.acpIndexNext >= m.acpIndexCap {
 		var cap uint64
-		const incrby = 10000 // account_control_program_seq increments by 10,000
 		const q = `SELECT nextval('account_control_program_seq')`
 		err := m.db.QueryRow(ctx, q).Scan(&cap)
 		if err != nil {
 			return 0, errors.Wrap(err, "scan")
 		}
 		m.acpIndexCap = cap
-		m.acpIndexNext = cap - incrby
+		m.acpIndexNext = cap - cpIndexBlock
 	}
 
 	n := m.acpIndexNext
@@ -263,6 +428,28 @@ func (m *Manager) nextIndex(ctx context.Context) (uint64, error) {
 	return n, nil
 }
 
+// reserveIndexesThrough keeps nextIndex, here and in other
+// processes, from returning idx or any smaller index
+// that it has not returned yet.
+func (m *Manager) reserveIndexesThrough(ctx context.Context, idx uint64) error {
+	m.acpMu.Lock()
+	defer m.acpMu.Unlock()
+
+	// Start the next block of indexes after idx's block.
+	const q = `
+		SELECT setval('account_control_program_seq', GREATEST($1, last_value))
+		FROM account_control_program_seq
+	`
+	_, err := m.db.Exec(ctx, q, (idx-1)/cpIndexBlock*cpIndexBlock+cpIndexBlock+1)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if m.acpIndexNext <= idx {
+		m.acpIndexNext = m.acpIndexCap
+	}
+	return nil
+}
+
 func tagsToNullString(tags map[string]interface{}) (*stdsql.NullString, error) {
 	var tagsJSON []byte
 	if len(tags) != 0 {
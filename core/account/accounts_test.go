@@ -5,9 +5,14 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
+	"chain/core/signers"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
 	"chain/errors"
+	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/protocol/vm"
 	"chain/testutil"
@@ -16,11 +21,12 @@ import (
 var dummyXPub = testutil.TestXPub.String()
 
 func TestCreateAccount(t *testing.T) {
+	t.Parallel()
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 
-	account, err := m.Create(ctx, []string{dummyXPub}, 1, "", nil, nil)
+	account, err := m.Create(ctx, []string{dummyXPub}, 1, "", nil, false, nil)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -38,16 +44,17 @@ func TestCreateAccount(t *testing.T) {
 }
 
 func TestCreateAccountIdempotency(t *testing.T) {
+	t.Parallel()
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 	var clientToken = "a-unique-client-token"
 
-	account1, err := m.Create(ctx, []string{dummyXPub}, 1, "satoshi", nil, &clientToken)
+	account1, err := m.Create(ctx, []string{dummyXPub}, 1, "satoshi", nil, false, &clientToken)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	account2, err := m.Create(ctx, []string{dummyXPub}, 1, "satoshi", nil, &clientToken)
+	account2, err := m.Create(ctx, []string{dummyXPub}, 1, "satoshi", nil, false, &clientToken)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -57,24 +64,148 @@ func TestCreateAccountIdempotency(t *testing.T) {
 }
 
 func TestCreateAccountReusedAlias(t *testing.T) {
+	t.Parallel()
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 	m.createTestAccount(ctx, t, "some-account", nil)
 
-	_, err := m.Create(ctx, []string{dummyXPub}, 1, "some-account", nil, nil)
+	_, err := m.Create(ctx, []string{dummyXPub}, 1, "some-account", nil, false, nil)
 	if errors.Root(err) != ErrDuplicateAlias {
 		t.Errorf("Expected %s when reusing an alias, got %v", ErrDuplicateAlias, err)
 	}
 }
 
+func TestCreateAccountDeterministic(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, db1 := pgtest.NewDB(t, pgtest.SchemaPath)
+	m1 := NewManager(db1, prottest.NewChain(t), nil)
+	account1, err := m1.CreateDeterministic(ctx, []string{dummyXPub, xpub2.String()}, 1, "", nil, false, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A core rebuilt from scratch gets the same ID,
+	// whatever the order of the keys.
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	m2 := NewManager(db2, prottest.NewChain(t), nil)
+	m2.createTestAccount(ctx, t, "", nil)
+	account2, err := m2.CreateDeterministic(ctx, []string{xpub2.String(), dummyXPub}, 1, "", nil, false, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if account2.ID != account1.ID {
+		t.Errorf("rebuilt account ID = %s, want %s", account2.ID, account1.ID)
+	}
+
+	// It also derives the same control programs.
+	if account2.KeyIndex != account1.KeyIndex {
+		t.Errorf("rebuilt account key index = %d, want %d", account2.KeyIndex, account1.KeyIndex)
+	}
+	prog1, err := m1.CreateControlProgram(ctx, account1.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	prog2, err := m2.CreateControlProgram(ctx, account2.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(prog2, prog1) {
+		t.Errorf("rebuilt account control program = %x, want %x", prog2, prog1)
+	}
+
+	_, err = m1.CreateDeterministic(ctx, []string{dummyXPub, xpub2.String()}, 1, "", nil, false, nil)
+	if errors.Root(err) != signers.ErrDupeKeys {
+		t.Errorf("CreateDeterministic(same keys) error = %v, want %v", err, signers.ErrDupeKeys)
+	}
+
+	account3, err := m1.CreateDeterministic(ctx, []string{dummyXPub, xpub2.String()}, 2, "", nil, false, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if account3.ID == account1.ID {
+		t.Errorf("account ID with quorum 2 = account ID with quorum 1 = %s", account1.ID)
+	}
+}
+
+func TestMarkWatchOnly(t *testing.T) {
+	t.Parallel()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	account := m.createTestAccount(ctx, t, "cold", map[string]interface{}{"vault": 1.0})
+
+	// Load the account into the cache before marking it.
+	_, err := m.findSpendable(ctx, account.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	got, err := m.MarkWatchOnly(ctx, account.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := &Account{Signer: account.Signer, Alias: "cold", Tags: account.Tags, WatchOnly: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MarkWatchOnly() = %+v, want %+v", got, want)
+	}
+
+	amt := bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 1}
+	var b txbuilder.TemplateBuilder
+	err = m.NewSpendAction(amt, account.ID, nil, nil).Build(ctx, time.Now().Add(time.Minute), &b)
+	if errors.Root(err) != ErrWatchOnly {
+		t.Errorf("spend from watch-only account error = %v, want %v", err, ErrWatchOnly)
+	}
+
+	// Watch-only accounts still receive.
+	_, err = m.CreateControlProgram(ctx, account.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Creating the account again keeps it watch-only.
+	again, err := m.create(ctx, account.Signer, "cold", account.Tags, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !again.WatchOnly {
+		t.Error("account recreated as spendable, want watch-only")
+	}
+}
+
+func TestCreateWatchOnly(t *testing.T) {
+	t.Parallel()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	account, err := m.Create(ctx, []string{dummyXPub}, 1, "cold", nil, true, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !account.WatchOnly {
+		t.Error("Create(watchOnly) returned a spendable account")
+	}
+	_, err = m.findSpendable(ctx, account.ID)
+	if errors.Root(err) != ErrWatchOnly {
+		t.Errorf("findSpendable() error = %v, want %v", err, ErrWatchOnly)
+	}
+}
+
 func TestCreateControlProgram(t *testing.T) {
+	t.Parallel()
 	// use pgtest.NewDB for deterministic postgres sequences
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 
-	account, err := m.Create(ctx, []string{dummyXPub}, 1, "", nil, nil)
+	account, err := m.Create(ctx, []string{dummyXPub}, 1, "", nil, false, nil)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -95,7 +226,7 @@ func TestCreateControlProgram(t *testing.T) {
 }
 
 func (m *Manager) createTestAccount(ctx context.Context, t testing.TB, alias string, tags map[string]interface{}) *Account {
-	account, err := m.Create(ctx, []string{dummyXPub}, 1, alias, tags, nil)
+	account, err := m.Create(ctx, []string{dummyXPub}, 1, alias, tags, false, nil)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -117,6 +248,7 @@ func (m *Manager) createTestControlProgram(ctx context.Context, t testing.TB, ac
 }
 
 func TestFindByID(t *testing.T) {
+	t.Parallel()
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
@@ -133,6 +265,7 @@ func TestFindByID(t *testing.T) {
 }
 
 func TestFindByAlias(t *testing.T) {
+	t.Parallel()
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
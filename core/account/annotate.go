@@ -111,3 +111,18 @@ func (m *Manager) AnnotateTxs(ctx context.Context, txs []map[string]interface{})
 
 	return nil
 }
+
+// ControlProgramAccounts returns the IDs of the accounts
+// controlling progs, keyed by string(prog). Programs that
+// don't belong to an account are left out.
+func (m *Manager) ControlProgramAccounts(ctx context.Context, progs [][]byte) (map[string]string, error) {
+	const q = `
+		SELECT control_program, signer_id FROM account_control_programs
+		WHERE control_program=ANY($1::bytea[])
+	`
+	accounts := make(map[string]string)
+	err := pg.ForQueryRows(ctx, m.db, q, pq.ByteaArray(progs), func(program []byte, accountID string) {
+		accounts[string(program)] = accountID
+	})
+	return accounts, errors.Wrap(err, "looking up control program accounts")
+}
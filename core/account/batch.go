@@ -0,0 +1,147 @@
+package account
+
+import (
+	"chain/core/txbuilder"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrBadPayment is returned by BatchPayments
+// for a payment it can't include in a batch.
+var ErrBadPayment = errors.New("invalid payment")
+
+// Defaults for BatchLimits.
+const (
+	defaultBatchOutputs = 200
+	defaultBatchBytes   = 256 << 10
+)
+
+// outputOverhead estimates the serialized size of an output,
+// apart from its control program and reference data, and of
+// the input that spends an account's funds for it.
+const outputOverhead = 200
+
+// A Payment is one transfer of a batch: an amount of an asset
+// from a source account, either to another account or to a
+// control program.
+type Payment struct {
+	bc.AssetAmount
+	SourceAccountID string             `json:"source_account_id"`
+	AccountID       string             `json:"account_id"`
+	ControlProgram  chainjson.HexBytes `json:"control_program"`
+	ReferenceData   chainjson.Map      `json:"reference_data"`
+}
+
+// BatchLimits bound the size of each transaction
+// BatchPayments makes. Zero values mean the defaults.
+type BatchLimits struct {
+	// MaxOutputs is the most outputs a transaction may have,
+	// counting one change output for each account and asset
+	// it spends.
+	MaxOutputs int `json:"max_outputs"`
+
+	// MaxBytes is the most bytes a transaction's outputs and
+	// reference data may take, as estimated before the
+	// transaction is built.
+	MaxBytes int `json:"max_bytes"`
+}
+
+// A Batch is the actions of one transaction BatchPayments
+// makes, and the indexes of the payments it includes.
+type Batch struct {
+	Payments []int
+	Actions  []txbuilder.Action
+}
+
+// BatchPayments groups payments into as few transactions as
+// limits allow, in order. Each transaction spends, from each
+// of its source accounts, one sum of each asset for all the
+// payments it makes of that asset from that account, rather
+// than one sum per payment, so it needs fewer inputs and
+// change outputs than the payments built separately would.
+func (m *Manager) BatchPayments(payments []Payment, limits BatchLimits) ([]*Batch, error) {
+	if limits.MaxOutputs <= 0 {
+		limits.MaxOutputs = defaultBatchOutputs
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = defaultBatchBytes
+	}
+
+	var (
+		batches []*Batch
+		cur     *Batch
+		spends  map[spendKey]uint64
+		order   []spendKey
+		nbytes  int
+	)
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		for _, k := range order {
+			amt := bc.AssetAmount{AssetID: k.assetID, Amount: spends[k]}
+			cur.Actions = append(cur.Actions, m.NewSpendAction(amt, k.accountID, nil, nil))
+		}
+		batches = append(batches, cur)
+		cur = nil
+	}
+
+	for i, p := range payments {
+		out, size, err := m.paymentOutput(p)
+		if err != nil {
+			return nil, errors.WithDetailf(err, "payment %d", i)
+		}
+		if size > limits.MaxBytes {
+			return nil, errors.WithDetailf(ErrBadPayment, "payment %d is larger than a batch", i)
+		}
+		k := spendKey{p.SourceAccountID, p.AssetID}
+
+		if cur != nil {
+			_, spent := spends[k]
+			outputs := len(cur.Payments) + len(order) + 1
+			if !spent {
+				outputs++ // change
+			}
+			if outputs > limits.MaxOutputs || nbytes+size > limits.MaxBytes || spends[k]+p.Amount < p.Amount {
+				flush()
+			}
+		}
+		if cur == nil {
+			cur = new(Batch)
+			spends = make(map[spendKey]uint64)
+			order = nil
+			nbytes = 0
+		}
+
+		if _, ok := spends[k]; !ok {
+			order = append(order, k)
+		}
+		spends[k] += p.Amount
+		nbytes += size
+		cur.Payments = append(cur.Payments, i)
+		cur.Actions = append(cur.Actions, out)
+	}
+	flush()
+	return batches, nil
+}
+
+// paymentOutput returns the action that pays p
+// and an estimate of its size in a transaction.
+func (m *Manager) paymentOutput(p Payment) (txbuilder.Action, int, error) {
+	switch {
+	case p.SourceAccountID == "":
+		return nil, 0, errors.WithDetail(ErrBadPayment, "source_account_id is required")
+	case p.AssetID == (bc.AssetID{}):
+		return nil, 0, errors.WithDetail(ErrBadPayment, "asset_id is required")
+	case p.Amount == 0:
+		return nil, 0, errors.WithDetail(ErrBadPayment, "amount must be positive")
+	case (p.AccountID == "") == (len(p.ControlProgram) == 0):
+		return nil, 0, errors.WithDetail(ErrBadPayment, "exactly one of account_id and control_program is required")
+	}
+	size := outputOverhead + len(p.ControlProgram) + len(p.ReferenceData)
+	if p.AccountID != "" {
+		return m.NewControlAction(p.AssetAmount, p.AccountID, p.ReferenceData), size, nil
+	}
+	return txbuilder.NewControlProgramAction(p.AssetAmount, p.ControlProgram, p.ReferenceData), size, nil
+}
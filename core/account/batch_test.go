@@ -0,0 +1,85 @@
+package account
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestBatchPayments(t *testing.T) {
+	m := &Manager{}
+	gold, silver := bc.AssetID{1}, bc.AssetID{2}
+	pay := func(src string, asset bc.AssetID, amount uint64) Payment {
+		return Payment{
+			AssetAmount:     bc.AssetAmount{AssetID: asset, Amount: amount},
+			SourceAccountID: src,
+			AccountID:       "dest",
+		}
+	}
+
+	payments := []Payment{
+		pay("alice", gold, 1),
+		pay("alice", gold, 2),
+		pay("bob", gold, 3),
+		pay("alice", silver, 4),
+		pay("alice", gold, 5),
+	}
+
+	// Payments 0-2 need 3 outputs and 2 change outputs.
+	// Payment 3 would need 2 more.
+	batches, err := m.BatchPayments(payments, BatchLimits{MaxOutputs: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPayments := [][]int{{0, 1, 2}, {3, 4}}
+	wantSpends := [][]bc.AssetAmount{
+		{{AssetID: gold, Amount: 3}, {AssetID: gold, Amount: 3}},
+		{{AssetID: silver, Amount: 4}, {AssetID: gold, Amount: 5}},
+	}
+	if len(batches) != len(wantPayments) {
+		t.Fatalf("got %d batches want %d", len(batches), len(wantPayments))
+	}
+	for i, b := range batches {
+		if !reflect.DeepEqual(b.Payments, wantPayments[i]) {
+			t.Errorf("batch %d payments = %v want %v", i, b.Payments, wantPayments[i])
+		}
+		var spends []bc.AssetAmount
+		for _, a := range b.Actions {
+			if s, ok := a.(*spendAction); ok {
+				spends = append(spends, s.AssetAmount)
+			}
+		}
+		if !reflect.DeepEqual(spends, wantSpends[i]) {
+			t.Errorf("batch %d spends = %v want %v", i, spends, wantSpends[i])
+		}
+		if len(b.Actions) != len(b.Payments)+len(spends) {
+			t.Errorf("batch %d has %d actions want %d", i, len(b.Actions), len(b.Payments)+len(spends))
+		}
+	}
+
+	// Sums that would overflow go in separate batches.
+	batches, err = m.BatchPayments([]Payment{pay("alice", gold, math.MaxUint64), pay("alice", gold, 1)}, BatchLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Errorf("got %d batches for overflowing payments, want 2", len(batches))
+	}
+
+	bad := []Payment{
+		{AssetAmount: bc.AssetAmount{AssetID: gold, Amount: 1}, SourceAccountID: "alice"},
+		{AssetAmount: bc.AssetAmount{AssetID: gold, Amount: 1}, SourceAccountID: "alice", AccountID: "dest", ControlProgram: []byte{0x51}},
+		{AssetAmount: bc.AssetAmount{AssetID: gold}, SourceAccountID: "alice", AccountID: "dest"},
+		{AssetAmount: bc.AssetAmount{Amount: 1}, SourceAccountID: "alice", AccountID: "dest"},
+		{AssetAmount: bc.AssetAmount{AssetID: gold, Amount: 1}, AccountID: "dest"},
+	}
+	for i, p := range bad {
+		_, err := m.BatchPayments([]Payment{p}, BatchLimits{})
+		if errors.Root(err) != ErrBadPayment {
+			t.Errorf("bad payment %d: error = %v want %v", i, err, ErrBadPayment)
+		}
+	}
+}
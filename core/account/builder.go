@@ -7,6 +7,7 @@ import (
 
 	"chain/core/signers"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
@@ -49,11 +50,16 @@ func (a *spendAction) Build(ctx context.Context, maxTime time.Time, b *txbuilder
 		return txbuilder.MissingFieldsError(missing...)
 	}
 
-	acct, err := a.accounts.findByID(ctx, a.AccountID)
+	acct, err := a.accounts.findSpendable(ctx, a.AccountID)
 	if err != nil {
 		return errors.Wrap(err, "get account info")
 	}
 
+	err = a.accounts.checkLimit(ctx, a.AccountID, a.AssetID, a.Amount)
+	if err != nil {
+		return err
+	}
+
 	src := source{
 		AssetID:   a.AssetID,
 		AccountID: a.AccountID,
@@ -125,7 +131,11 @@ func (a *spendUTXOAction) Build(ctx context.Context, maxTime time.Time, b *txbui
 	}
 	b.OnRollback(canceler(ctx, a.accounts, res.ID))
 
-	acct, err := a.accounts.findByID(ctx, res.Source.AccountID)
+	acct, err := a.accounts.findSpendable(ctx, res.Source.AccountID)
+	if err != nil {
+		return err
+	}
+	err = a.accounts.checkLimit(ctx, res.Source.AccountID, res.Source.AssetID, res.UTXOs[0].Amount)
 	if err != nil {
 		return err
 	}
@@ -148,12 +158,12 @@ func canceler(ctx context.Context, m *Manager, rid uint64) func() {
 
 func utxoToInputs(ctx context.Context, account *signers.Signer, u *utxo, refData []byte) (
 	*bc.TxInput,
-	*txbuilder.SigningInstruction,
+	*signing.SigningInstruction,
 	error,
 ) {
 	txInput := bc.NewSpendInput(u.Hash, u.Index, nil, u.AssetID, u.Amount, u.ControlProgram, refData)
 
-	sigInst := &txbuilder.SigningInstruction{
+	sigInst := &signing.SigningInstruction{
 		AssetAmount: u.AssetAmount,
 	}
 
@@ -0,0 +1,103 @@
+package account
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// maxImportRange is the largest number of control programs
+// that ImportIndexRange derives in one call.
+const maxImportRange = 10000
+
+// ErrBadImport is returned by ImportControlPrograms
+// and ImportIndexRange when asked to import
+// control programs they cannot.
+var ErrBadImport = errors.New("invalid control program import")
+
+// ImportControlPrograms adds progs, created outside of Chain Core,
+// to the watch-only account with the given ID. Chain Core cannot
+// sign for arbitrary programs, so only watch-only accounts can
+// hold them. Programs that already belong to an account are left
+// as they are. It returns the programs imported.
+//
+// Outputs already on the blockchain are found by a rescan;
+// see StartRescan.
+func (m *Manager) ImportControlPrograms(ctx context.Context, accountID string, progs [][]byte) ([][]byte, error) {
+	account, err := m.find(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !account.watchOnly {
+		return nil, errors.WithDetail(ErrBadImport, "arbitrary control programs can only be imported into watch-only accounts")
+	}
+	var cps []*controlProgram
+	for _, p := range progs {
+		if len(p) == 0 {
+			return nil, errors.WithDetail(ErrBadImport, "empty control program")
+		}
+		cps = append(cps, &controlProgram{accountID: accountID, controlProgram: p})
+	}
+	return m.importControlPrograms(ctx, cps)
+}
+
+// ImportIndexRange adds the control programs of the account with
+// the given ID at indexes from through to, inclusive, such as those
+// handed out by another wallet using the account's keys. It keeps
+// CreateControlProgram from returning them again. It returns the
+// programs imported.
+//
+// Outputs already on the blockchain are found by a rescan;
+// see StartRescan.
+func (m *Manager) ImportIndexRange(ctx context.Context, accountID string, from, to uint64) ([][]byte, error) {
+	if from == 0 || to < from {
+		return nil, errors.WithDetailf(ErrBadImport, "bad index range %d to %d", from, to)
+	}
+	if to-from >= maxImportRange {
+		return nil, errors.WithDetailf(ErrBadImport, "index range is larger than %d", maxImportRange)
+	}
+	account, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var cps []*controlProgram
+	for idx := from; idx <= to; idx++ {
+		prog, err := deriveControlProgram(account, idx)
+		if err != nil {
+			return nil, err
+		}
+		cps = append(cps, &controlProgram{accountID: accountID, keyIndex: idx, controlProgram: prog})
+	}
+	err = m.reserveIndexesThrough(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return m.importControlPrograms(ctx, cps)
+}
+
+func (m *Manager) importControlPrograms(ctx context.Context, progs []*controlProgram) ([][]byte, error) {
+	const q = `
+		INSERT INTO account_control_programs (signer_id, key_index, control_program, change)
+		SELECT unnest($1::text[]), unnest($2::bigint[]), unnest($3::bytea[]), FALSE
+		ON CONFLICT (control_program) DO NOTHING
+		RETURNING control_program
+	`
+	var (
+		accountIDs   pq.StringArray
+		keyIndexes   pq.Int64Array
+		controlProgs pq.ByteaArray
+	)
+	for _, p := range progs {
+		accountIDs = append(accountIDs, p.accountID)
+		keyIndexes = append(keyIndexes, int64(p.keyIndex))
+		controlProgs = append(controlProgs, p.controlProgram)
+	}
+	var imported [][]byte
+	err := pg.ForQueryRows(ctx, m.db, q, accountIDs, keyIndexes, controlProgs, func(prog []byte) {
+		imported = append(imported, prog)
+	})
+	return imported, errors.Wrap(err)
+}
@@ -0,0 +1,168 @@
+package account_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/signers"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/protocol/vmutil"
+	"chain/testutil"
+)
+
+func TestImportControlPrograms(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+		indexer  = query.NewIndexer(db, c, pinStore)
+
+		spendable = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		cold      = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		assetID   = coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	)
+	coretest.CreatePins(ctx, t, pinStore)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go accounts.ProcessBlocks(ctx)
+	assets.IndexAssets(indexer)
+	accounts.IndexAccounts(indexer)
+
+	// A program from another wallet, and one from another
+	// core using the same keys as account spendable.
+	external := []byte{0x51}
+	derived := deriveProgram(ctx, t, db, spendable, 20005)
+	for _, prog := range [][]byte{external, derived} {
+		action, err := txbuilder.DecodeControlProgramAction([]byte(fmt.Sprintf(
+			`{"asset_id": "%s", "amount": 1, "control_program": "%x"}`, assetID, prog,
+		)))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		coretest.Transfer(ctx, t, c, []txbuilder.Action{
+			assets.NewIssueAction(bc.AssetAmount{AssetID: assetID, Amount: 1}, nil),
+			action,
+		})
+	}
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	_, err := accounts.ImportControlPrograms(ctx, spendable, [][]byte{external})
+	if errors.Root(err) != account.ErrBadImport {
+		t.Errorf("ImportControlPrograms(spendable) error = %v, want %v", err, account.ErrBadImport)
+	}
+
+	_, err = accounts.MarkWatchOnly(ctx, cold)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	imported, err := accounts.ImportControlPrograms(ctx, cold, [][]byte{external})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(imported) != 1 {
+		t.Errorf("ImportControlPrograms() imported %d programs, want 1", len(imported))
+	}
+	rangeProgs, err := accounts.ImportIndexRange(ctx, spendable, 20001, 20010)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(rangeProgs) != 10 {
+		t.Errorf("ImportIndexRange() imported %d programs, want 10", len(rangeProgs))
+	}
+
+	coldRescan, err := accounts.StartRescan(ctx, cold, imported, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	spendableRescan, err := accounts.StartRescan(ctx, spendable, rangeProgs, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	go accounts.ProcessRescans(ctx, time.Millisecond, indexer)
+	for _, id := range []string{coldRescan.ID, spendableRescan.ID} {
+		waitForRescan(ctx, t, accounts, id, c.Height())
+	}
+	for _, id := range []string{cold, spendable} {
+		var count int
+		const q = `SELECT COUNT(*) FROM account_utxos WHERE account_id=$1`
+		err = db.QueryRow(ctx, q, id).Scan(&count)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if count != 1 {
+			t.Errorf("account %s has %d utxos after rescan, want 1", id, count)
+		}
+	}
+
+	// New control programs don't reuse imported indexes.
+	prog, err := accounts.CreateControlProgram(ctx, spendable, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var idx uint64
+	const q = `SELECT key_index FROM account_control_programs WHERE control_program=$1`
+	err = db.QueryRow(ctx, q, prog).Scan(&idx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if idx <= 20010 {
+		t.Errorf("new control program index = %d, want > 20010", idx)
+	}
+}
+
+// waitForRescan waits for the rescan with the given ID
+// to finish, and checks that it scanned through height.
+func waitForRescan(ctx context.Context, t testing.TB, accounts *account.Manager, id string, height uint64) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		r, err := accounts.FindRescan(ctx, id)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if r.Status != account.RescanRunning {
+			if r.Status != account.RescanDone || r.ScannedHeight != height {
+				t.Fatalf("rescan %s ended with status %s (%s) at height %d, want %s at %d",
+					id, r.Status, r.Error, r.ScannedHeight, account.RescanDone, height)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rescan %s still running at height %d", id, r.ScannedHeight)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// deriveProgram returns the control program of account
+// accountID at index idx, as another core with the same
+// keys would.
+func deriveProgram(ctx context.Context, t testing.TB, db pg.DB, accountID string, idx uint64) []byte {
+	s, err := signers.Find(ctx, db, "account", accountID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	path := signers.Path(s, signers.AccountKeySpace, idx)
+	pks := chainkd.XPubKeys(chainkd.DeriveXPubs(s.XPubs, path))
+	prog, err := vmutil.P2SPMultiSigProgram(pks, s.Quorum)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	return prog
+}
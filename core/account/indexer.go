@@ -42,12 +42,18 @@ func (m *Manager) indexAnnotatedAccount(ctx context.Context, a *Account) error {
 			"account_derivation_path": jsonPath,
 		})
 	}
+	var parentID interface{}
+	if a.ParentID != "" {
+		parentID = a.ParentID
+	}
 	return m.indexer.SaveAnnotatedAccount(ctx, a.ID, map[string]interface{}{
-		"id":     a.ID,
-		"alias":  a.Alias,
-		"keys":   keys,
-		"tags":   a.Tags,
-		"quorum": a.Quorum,
+		"id":         a.ID,
+		"alias":      a.Alias,
+		"keys":       keys,
+		"tags":       a.Tags,
+		"quorum":     a.Quorum,
+		"watch_only": a.WatchOnly,
+		"parent_id":  parentID,
 	})
 }
 
@@ -88,6 +94,11 @@ func (m *Manager) indexAccountUTXOs(ctx context.Context, b *bc.Block) error {
 		return errors.Wrap(err, "upserting confirmed account utxos")
 	}
 
+	err = m.recordControlProgramUses(ctx, accOuts, b)
+	if err != nil {
+		return err
+	}
+
 	// Delete consumed account UTXOs.
 	deltxhash, delindex := prevoutDBKeys(b.Transactions...)
 	const delQ = `
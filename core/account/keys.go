@@ -0,0 +1,80 @@
+package account
+
+import (
+	"context"
+
+	"chain/core/signers"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/encoding/json"
+	"chain/errors"
+)
+
+// A DerivedKey is a control program of an account, with the
+// derivation path and public keys from which it was derived.
+// External wallets holding the account's root keys can
+// reconcile their own derivations against it.
+type DerivedKey struct {
+	KeyIndex       uint64          `json:"key_index"`
+	Change         bool            `json:"change"`
+	ControlProgram json.HexBytes   `json:"control_program"`
+	DerivationPath []json.HexBytes `json:"derivation_path"`
+	Pubkeys        []json.HexBytes `json:"pubkeys"`
+}
+
+// FindByID returns the Signer record, with the root xpubs,
+// quorum, and key index, of the account with the given ID.
+// Accounts of tenants other than the one ctx acts for
+// are not found.
+func (m *Manager) FindByID(ctx context.Context, id string) (*signers.Signer, error) {
+	return m.findByID(ctx, id)
+}
+
+// MaxKeyIndex returns the highest index of the control programs
+// derived for the account with the given ID, or 0 if there are none.
+func (m *Manager) MaxKeyIndex(ctx context.Context, accountID string) (uint64, error) {
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	const q = `SELECT COALESCE(MAX(key_index), 0) FROM account_control_programs WHERE signer_id=$1`
+	var idx uint64
+	err = m.db.QueryRow(ctx, q, accountID).Scan(&idx)
+	return idx, errors.Wrap(err, "finding max key index")
+}
+
+// DerivedKeys returns up to limit of the control programs derived
+// for the account with the given ID, in order of key index,
+// starting after key index after. Control programs imported into
+// a watch-only account weren't derived from its keys and are not
+// listed.
+func (m *Manager) DerivedKeys(ctx context.Context, accountID string, after uint64, limit int) ([]*DerivedKey, error) {
+	account, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		SELECT key_index, change, control_program
+		FROM account_control_programs
+		WHERE signer_id=$1 AND key_index > $2
+		ORDER BY key_index LIMIT $3
+	`
+	var keys []*DerivedKey
+	err = pg.ForQueryRows(ctx, m.db, q, accountID, after, limit, func(idx uint64, change bool, prog []byte) {
+		keys = append(keys, deriveKey(account, idx, change, prog))
+	})
+	return keys, errors.Wrap(err, "listing derived keys")
+}
+
+func deriveKey(account *signers.Signer, idx uint64, change bool, prog []byte) *DerivedKey {
+	k := &DerivedKey{KeyIndex: idx, Change: change, ControlProgram: prog}
+	path := signers.Path(account, signers.AccountKeySpace, idx)
+	for _, p := range path {
+		k.DerivationPath = append(k.DerivationPath, p)
+	}
+	for _, pub := range chainkd.XPubKeys(chainkd.DeriveXPubs(account.XPubs, path)) {
+		k.Pubkeys = append(k.Pubkeys, json.HexBytes(pub))
+	}
+	return k
+}
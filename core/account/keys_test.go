@@ -0,0 +1,65 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/database/pg/pgtest"
+	"chain/protocol/prottest"
+	"chain/protocol/vmutil"
+	"chain/testutil"
+)
+
+func TestDerivedKeys(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	acc := m.createTestAccount(ctx, t, "", nil)
+	progs := [][]byte{
+		m.createTestControlProgram(ctx, t, acc.ID),
+		m.createTestControlProgram(ctx, t, acc.ID),
+		m.createTestControlProgram(ctx, t, acc.ID),
+	}
+
+	max, err := m.MaxKeyIndex(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	first, err := m.DerivedKeys(ctx, acc.ID, 0, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	rest, err := m.DerivedKeys(ctx, acc.ID, first[len(first)-1].KeyIndex, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	keys := append(first, rest...)
+	if len(first) != 2 || len(keys) != len(progs) {
+		t.Fatalf("got pages of %d and %d keys, want 2 and 1", len(first), len(rest))
+	}
+	if keys[2].KeyIndex != max {
+		t.Errorf("last key index = %d, want max %d", keys[2].KeyIndex, max)
+	}
+
+	// The reported public keys reproduce each control program.
+	for i, k := range keys {
+		if !bytes.Equal(k.ControlProgram, progs[i]) {
+			t.Errorf("key %d control program = %x, want %x", i, k.ControlProgram, progs[i])
+		}
+		var pks []ed25519.PublicKey
+		for _, pk := range k.Pubkeys {
+			pks = append(pks, ed25519.PublicKey(pk))
+		}
+		prog, err := vmutil.P2SPMultiSigProgram(pks, acc.Quorum)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if !bytes.Equal(prog, progs[i]) {
+			t.Errorf("key %d pubkeys make program %x, want %x", i, prog, progs[i])
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	stdsql "database/sql"
+	"math"
+	"sort"
+
+	"github.com/lib/pq"
+
+	"chain/core/tenant"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+var (
+	// ErrOverLimit indicates that a transaction would spend more
+	// of an asset from an account than the account's limits allow.
+	ErrOverLimit = errors.New("spending limit exceeded")
+
+	// ErrBadLimit indicates a limit that cannot be stored.
+	ErrBadLimit = errors.New("invalid spending limit")
+
+	// ErrLoosenLimit is returned when a tenant other than the
+	// default tenant tries to raise or remove a limit.
+	ErrLoosenLimit = errors.New("limit may only be loosened by the default tenant")
+)
+
+const (
+	PeriodTransaction = "transaction"
+	PeriodDay         = "day"
+)
+
+// Limit caps the amount of an asset that an account may spend.
+// A nil maximum means there is no limit of that kind.
+// The daily limit applies to any 24-hour period.
+type Limit struct {
+	AccountID         string     `json:"account_id"`
+	AssetID           bc.AssetID `json:"asset_id"`
+	MaxPerTransaction *uint64    `json:"max_per_transaction"`
+	MaxPerDay         *uint64    `json:"max_per_day"`
+}
+
+// A Violation describes a spend that would exceed a limit.
+type Violation struct {
+	AccountID string
+	AssetID   bc.AssetID
+	Period    string // PeriodTransaction or PeriodDay
+	Limit     uint64
+
+	// Amount is the amount the account would spend
+	// in the period, including the violating spend.
+	Amount uint64
+}
+
+// An OverrideFunc decides whether a spend that violates a limit
+// may go ahead anyway. It returns nil to approve the spend,
+// or an error to be reported to the client.
+type OverrideFunc func(context.Context, *Violation) error
+
+// SetLimitOverride installs f to be consulted whenever a transaction
+// submitted through this Core would exceed an account's limits.
+// It must be called before the Manager is used.
+// Without an override, such transactions are rejected.
+func (m *Manager) SetLimitOverride(f OverrideFunc) {
+	m.override = f
+}
+
+// SetLimit stores l, replacing any limit on the same account and asset.
+// If l has no maximums, the limit is removed. Unless ctx acts for
+// the default tenant, a limit may only be added or lowered, so that
+// the tokens whose spends it limits can't lift it themselves:
+// raising or removing either maximum returns ErrLoosenLimit.
+func (m *Manager) SetLimit(ctx context.Context, l *Limit) error {
+	if l.AssetID == (bc.AssetID{}) {
+		return errors.WithDetail(ErrBadLimit, "asset_id is required")
+	}
+	for _, max := range []*uint64{l.MaxPerTransaction, l.MaxPerDay} {
+		if max != nil && *max > math.MaxInt64 {
+			return errors.WithDetailf(ErrBadLimit, "maximum %d exceeds 2^63", *max)
+		}
+	}
+	_, err := m.findByID(ctx, l.AccountID)
+	if err != nil {
+		return err
+	}
+
+	restricted := tenant.FromContext(ctx) != tenant.Default
+	if l.MaxPerTransaction == nil && l.MaxPerDay == nil {
+		if restricted {
+			return errors.WithDetailf(ErrLoosenLimit, "removing limit on asset %s", l.AssetID)
+		}
+		const q = `DELETE FROM account_limits WHERE account_id=$1 AND asset_id=$2`
+		_, err = m.db.Exec(ctx, q, l.AccountID, l.AssetID)
+		return errors.Wrap(err, "deleting limit")
+	}
+
+	// A restricted update must keep or lower each maximum
+	// the limit already has.
+	const q = `
+		INSERT INTO account_limits (account_id, asset_id, max_per_transaction, max_per_day)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id, asset_id) DO UPDATE
+		SET max_per_transaction=$3, max_per_day=$4
+		WHERE NOT $5
+			OR (account_limits.max_per_transaction IS NULL OR $3::bigint <= account_limits.max_per_transaction)
+			AND (account_limits.max_per_day IS NULL OR $4::bigint <= account_limits.max_per_day)
+	`
+	res, err := m.db.Exec(ctx, q, l.AccountID, l.AssetID, nullInt(l.MaxPerTransaction), nullInt(l.MaxPerDay), restricted)
+	if err != nil {
+		return errors.Wrap(err, "saving limit")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "saving limit")
+	}
+	if n == 0 {
+		return errors.WithDetailf(ErrLoosenLimit, "raising limit on asset %s", l.AssetID)
+	}
+	return nil
+}
+
+// Limits returns the limits on the given account.
+func (m *Manager) Limits(ctx context.Context, accountID string) ([]*Limit, error) {
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		SELECT asset_id, max_per_transaction, max_per_day
+		FROM account_limits WHERE account_id=$1
+		ORDER BY asset_id
+	`
+	var limits []*Limit
+	err = pg.ForQueryRows(ctx, m.db, q, accountID, func(assetID bc.AssetID, maxTx, maxDay *uint64) {
+		limits = append(limits, &Limit{
+			AccountID:         accountID,
+			AssetID:           assetID,
+			MaxPerTransaction: maxTx,
+			MaxPerDay:         maxDay,
+		})
+	})
+	return limits, errors.Wrap(err, "listing limits")
+}
+
+// checkLimit reports whether spending amount units of assetID
+// from accountID would exceed the account's limits.
+// It lets builders fail early; EnforceLimits has the final say,
+// so when an override is installed, checkLimit defers to it.
+func (m *Manager) checkLimit(ctx context.Context, accountID string, assetID bc.AssetID, amount uint64) error {
+	if m.override != nil {
+		return nil
+	}
+	const q = `
+		SELECT max_per_transaction, max_per_day FROM account_limits
+		WHERE account_id=$1 AND asset_id=$2
+	`
+	l := &Limit{AccountID: accountID, AssetID: assetID}
+	err := m.db.QueryRow(ctx, q, accountID, assetID).Scan(&l.MaxPerTransaction, &l.MaxPerDay)
+	if err == nil {
+		var v *Violation
+		v, err = violation(ctx, m.db, l, amount, bc.Hash{})
+		if v != nil {
+			err = m.approve(ctx, v)
+		}
+	} else if err == stdsql.ErrNoRows {
+		err = nil
+	}
+	return errors.Wrap(err)
+}
+
+// EnforceLimits checks the amounts tx spends from this Core's
+// accounts against their limits, consulting the override for any
+// violation. If tx may be submitted, EnforceLimits records its
+// spends against the accounts' daily limits.
+//
+// A recorded spend counts toward the daily limit even if tx
+// never makes it into a block. Calling EnforceLimits again
+// with the same tx doesn't count its spends twice.
+func (m *Manager) EnforceLimits(ctx context.Context, tx *bc.Tx) error {
+	spends, err := m.netSpends(ctx, tx)
+	if err != nil || len(spends) == 0 {
+		return err
+	}
+
+	var (
+		accountIDs pq.StringArray
+		assetIDs   pq.StringArray
+	)
+	for k := range spends {
+		accountIDs = append(accountIDs, k.accountID)
+		assetIDs = append(assetIDs, k.assetID.String())
+	}
+
+	dbtx, err := m.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer dbtx.Rollback(ctx)
+
+	// Lock the limits so that concurrent submissions
+	// can't exceed a daily limit together.
+	const limitsQ = `
+		SELECT account_id, asset_id, max_per_transaction, max_per_day
+		FROM account_limits
+		WHERE (account_id, asset_id) IN (SELECT unnest($1::text[]), unnest($2::text[]))
+		FOR UPDATE
+	`
+	var limits []*Limit
+	err = pg.ForQueryRows(ctx, dbtx, limitsQ, accountIDs, assetIDs, func(accountID string, assetID bc.AssetID, maxTx, maxDay *uint64) {
+		limits = append(limits, &Limit{
+			AccountID:         accountID,
+			AssetID:           assetID,
+			MaxPerTransaction: maxTx,
+			MaxPerDay:         maxDay,
+		})
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading limits")
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	var (
+		limitedAccounts pq.StringArray
+		limitedAssets   pq.StringArray
+		amounts         pq.Int64Array
+	)
+	for _, l := range limits {
+		amount := spends[spendKey{l.AccountID, l.AssetID}]
+		v, err := violation(ctx, dbtx, l, amount, tx.Hash)
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			err = m.approve(ctx, v)
+			if err != nil {
+				return err
+			}
+		}
+		limitedAccounts = append(limitedAccounts, l.AccountID)
+		limitedAssets = append(limitedAssets, l.AssetID.String())
+		amounts = append(amounts, int64(amount))
+	}
+
+	const insertQ = `
+		INSERT INTO account_spends (tx_hash, account_id, asset_id, amount)
+		SELECT $1, unnest($2::text[]), unnest($3::text[]), unnest($4::bigint[])
+		ON CONFLICT (tx_hash, account_id, asset_id) DO NOTHING
+	`
+	_, err = dbtx.Exec(ctx, insertQ, tx.Hash.String(), limitedAccounts, limitedAssets, amounts)
+	if err != nil {
+		return errors.Wrap(err, "recording spends")
+	}
+	return errors.Wrap(dbtx.Commit(ctx))
+}
+
+// expireSpends deletes recorded spends that no longer
+// count toward any daily limit.
+func (m *Manager) expireSpends(ctx context.Context) error {
+	const q = `DELETE FROM account_spends WHERE spent_at < now() - interval '1 day'`
+	_, err := m.db.Exec(ctx, q)
+	return errors.Wrap(err)
+}
+
+// violation returns the first limit in l that spending amount,
+// in a transaction other than those already recorded with hash
+// txHash, would exceed, or nil if there is none.
+func violation(ctx context.Context, db pg.DB, l *Limit, amount uint64, txHash bc.Hash) (*Violation, error) {
+	v := &Violation{AccountID: l.AccountID, AssetID: l.AssetID}
+	if l.MaxPerTransaction != nil && amount > *l.MaxPerTransaction {
+		v.Period, v.Limit, v.Amount = PeriodTransaction, *l.MaxPerTransaction, amount
+		return v, nil
+	}
+	if l.MaxPerDay == nil {
+		return nil, nil
+	}
+
+	const q = `
+		SELECT COALESCE(SUM(amount), 0) FROM account_spends
+		WHERE account_id=$1 AND asset_id=$2 AND tx_hash<>$3
+			AND spent_at > now() - interval '1 day'
+	`
+	var spent uint64
+	err := db.QueryRow(ctx, q, l.AccountID, l.AssetID, txHash.String()).Scan(&spent)
+	if err != nil {
+		return nil, errors.Wrap(err, "summing daily spends")
+	}
+	if spent+amount > *l.MaxPerDay || spent+amount < spent {
+		v.Period, v.Limit, v.Amount = PeriodDay, *l.MaxPerDay, spent+amount
+		return v, nil
+	}
+	return nil, nil
+}
+
+func (m *Manager) approve(ctx context.Context, v *Violation) error {
+	if m.override != nil {
+		return m.override(ctx, v)
+	}
+	return errors.WithDetailf(ErrOverLimit,
+		"account %s may spend at most %d units of asset %s per %s",
+		v.AccountID, v.Limit, v.AssetID, v.Period)
+}
+
+// A Spend is an amount of an asset that a transaction
+// moves out of one of this Core's accounts.
+type Spend struct {
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Amount    uint64     `json:"amount"`
+}
+
+// Spends returns the amounts tx moves out of this Core's accounts,
+// ordered by account and asset. Outputs paying back into the same
+// account, such as change, are not counted as spent.
+func (m *Manager) Spends(ctx context.Context, tx *bc.Tx) ([]Spend, error) {
+	spends, err := m.netSpends(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	var a []Spend
+	for k, amount := range spends {
+		a = append(a, Spend{AccountID: k.accountID, AssetID: k.assetID, Amount: amount})
+	}
+	sort.Sort(byAccountAsset(a))
+	return a, nil
+}
+
+type byAccountAsset []Spend
+
+func (a byAccountAsset) Len() int      { return len(a) }
+func (a byAccountAsset) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAccountAsset) Less(i, j int) bool {
+	if a[i].AccountID != a[j].AccountID {
+		return a[i].AccountID < a[j].AccountID
+	}
+	return bytes.Compare(a[i].AssetID[:], a[j].AssetID[:]) < 0
+}
+
+type spendKey struct {
+	accountID string
+	assetID   bc.AssetID
+}
+
+// netSpends returns the amount of each asset that tx moves out of
+// each of this Core's accounts. Outputs paying back into the same
+// account, such as change, are not counted as spent.
+func (m *Manager) netSpends(ctx context.Context, tx *bc.Tx) (map[spendKey]uint64, error) {
+	spends := make(map[spendKey]uint64)
+	txhash, index := prevoutDBKeys(tx)
+	if len(txhash) == 0 {
+		return spends, nil
+	}
+	const q = `
+		SELECT account_id, asset_id, amount FROM account_utxos
+		WHERE (tx_hash, index) IN (SELECT unnest($1::text[]), unnest($2::integer[]))
+	`
+	err := pg.ForQueryRows(ctx, m.db, q, txhash, index, func(accountID string, assetID bc.AssetID, amount uint64) {
+		spends[spendKey{accountID, assetID}] += amount
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading spent outputs")
+	}
+	if len(spends) == 0 {
+		return spends, nil
+	}
+
+	outs := make([]*state.Output, 0, len(tx.Outputs))
+	for i, out := range tx.Outputs {
+		outs = append(outs, &state.Output{
+			TxOutput: *out,
+			Outpoint: bc.Outpoint{Hash: tx.Hash, Index: uint32(i)},
+		})
+	}
+	accOuts, err := m.loadAccountInfo(ctx, outs)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account info from control programs")
+	}
+	for _, out := range accOuts {
+		k := spendKey{out.AccountID, out.AssetID}
+		if spends[k] > out.Amount {
+			spends[k] -= out.Amount
+		} else {
+			delete(spends, k)
+		}
+	}
+	return spends, nil
+}
+
+func nullInt(n *uint64) interface{} {
+	if n == nil {
+		return nil
+	}
+	return int64(*n)
+}
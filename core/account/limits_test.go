@@ -0,0 +1,225 @@
+package account_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/pin"
+	"chain/core/tenant"
+	"chain/core/txbuilder"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestSpendingLimits(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+
+		accID   = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		assetID = coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+		_       = coretest.IssueAssets(ctx, t, c, assets, accounts, assetID, 10, accID)
+	)
+
+	coretest.CreatePins(ctx, t, pinStore)
+	go accounts.ProcessBlocks(ctx)
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	maxTx, maxDay := uint64(3), uint64(5)
+	err := accounts.SetLimit(ctx, &account.Limit{
+		AccountID:         accID,
+		AssetID:           assetID,
+		MaxPerTransaction: &maxTx,
+		MaxPerDay:         &maxDay,
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	dest := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	build := func(amount uint64) (*bc.Tx, error) {
+		amt := bc.AssetAmount{AssetID: assetID, Amount: amount}
+		tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			accounts.NewSpendAction(amt, accID, nil, nil),
+			accounts.NewControlAction(amt, dest, nil),
+		}, time.Now().Add(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		return bc.NewTx(*tpl.Transaction), nil
+	}
+
+	_, err = build(4)
+	if !overLimit(err) {
+		t.Fatalf("build over per-transaction limit: got error %v, want %v", err, account.ErrOverLimit)
+	}
+
+	tx, err := build(3)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = accounts.EnforceLimits(ctx, tx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Enforcing the same tx again must not count it twice.
+	err = accounts.EnforceLimits(ctx, tx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = build(3)
+	if !overLimit(err) {
+		t.Fatalf("build over daily limit: got error %v, want %v", err, account.ErrOverLimit)
+	}
+
+	limits, err := accounts.Limits(ctx, accID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(limits) != 1 || *limits[0].MaxPerTransaction != maxTx || *limits[0].MaxPerDay != maxDay {
+		t.Errorf("Limits(%s) = %+v, want one limit of %d and %d", accID, limits, maxTx, maxDay)
+	}
+}
+
+// overLimit reports whether err is a build error
+// caused by an action exceeding a spending limit.
+func overLimit(err error) bool {
+	if errors.Root(err) != txbuilder.ErrAction {
+		return false
+	}
+	errs, _ := errors.Data(err)["actions"].([]error)
+	return len(errs) == 1 && errors.Root(errs[0]) == account.ErrOverLimit
+}
+
+func TestSpendingLimitOverride(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+
+		accID   = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		assetID = coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+		_       = coretest.IssueAssets(ctx, t, c, assets, accounts, assetID, 10, accID)
+	)
+
+	coretest.CreatePins(ctx, t, pinStore)
+	go accounts.ProcessBlocks(ctx)
+	prottest.MakeBlock(t, c)
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	maxTx := uint64(1)
+	err := accounts.SetLimit(ctx, &account.Limit{AccountID: accID, AssetID: assetID, MaxPerTransaction: &maxTx})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	var got *account.Violation
+	errDenied := errors.New("denied")
+	accounts.SetLimitOverride(func(ctx context.Context, v *account.Violation) error {
+		got = v
+		return errDenied
+	})
+
+	amt := bc.AssetAmount{AssetID: assetID, Amount: 2}
+	tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+		accounts.NewSpendAction(amt, accID, nil, nil),
+		accounts.NewControlAction(amt, coretest.CreateAccount(ctx, t, accounts, "", nil), nil),
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	err = accounts.EnforceLimits(ctx, bc.NewTx(*tpl.Transaction))
+	if err != errDenied {
+		t.Fatalf("EnforceLimits: got error %v, want %v", err, errDenied)
+	}
+	want := account.Violation{
+		AccountID: accID,
+		AssetID:   assetID,
+		Period:    account.PeriodTransaction,
+		Limit:     1,
+		Amount:    2,
+	}
+	if got == nil || *got != want {
+		t.Errorf("override got %+v, want %+v", got, want)
+	}
+}
+
+func TestLimitTenants(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		acme     = tenant.NewContext(ctx, "acme")
+		accounts = account.NewManager(db, prottest.NewChain(t), nil)
+		accID    = coretest.CreateAccount(acme, t, accounts, "", nil)
+		assetID  = bc.AssetID{1}
+	)
+	amount := func(n uint64) *uint64 { return &n }
+	set := func(ctx context.Context, maxTx, maxDay *uint64) error {
+		return accounts.SetLimit(ctx, &account.Limit{
+			AccountID:         accID,
+			AssetID:           assetID,
+			MaxPerTransaction: maxTx,
+			MaxPerDay:         maxDay,
+		})
+	}
+
+	// A tenant may add and lower its limits,
+	// but not raise or remove them.
+	err := set(acme, amount(100), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = set(acme, amount(50), amount(500))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	cases := []struct {
+		name          string
+		maxTx, maxDay *uint64
+	}{
+		{"raise per-transaction", amount(80), amount(500)},
+		{"raise daily", amount(50), amount(600)},
+		{"remove daily", amount(50), nil},
+		{"remove", nil, nil},
+	}
+	for _, c := range cases {
+		err = set(acme, c.maxTx, c.maxDay)
+		if errors.Root(err) != account.ErrLoosenLimit {
+			t.Errorf("SetLimit(%s) = %v, want %v", c.name, err, account.ErrLoosenLimit)
+		}
+	}
+	limits, err := accounts.Limits(acme, accID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(limits) != 1 || *limits[0].MaxPerTransaction != 50 || *limits[0].MaxPerDay != 500 {
+		t.Errorf("Limits() = %+v, want 50 per transaction and 500 per day", limits)
+	}
+
+	// The default tenant may raise or remove them.
+	err = set(ctx, amount(1000), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = set(ctx, nil, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+}
@@ -0,0 +1,326 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	stdsql "database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// ErrBadSubscription indicates a balance
+// subscription that cannot be created.
+var ErrBadSubscription = errors.New("invalid balance subscription")
+
+// Balance subscription statuses.
+const (
+	SubscriptionActive   = "active"
+	SubscriptionDisabled = "disabled" // after maxSubscriptionFailures
+)
+
+const (
+	// maxSubscriptionFailures is the number of deliveries in
+	// a row a subscription may fail before it's disabled.
+	maxSubscriptionFailures = 10
+
+	// SignatureHeader is the header field of each notification
+	// carrying the hex HMAC-SHA256 of the request body, keyed
+	// with the subscription's secret.
+	SignatureHeader = "Chain-Signature"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// A BalanceChange is the net change in an account's
+// balance of an asset made by one transaction.
+type BalanceChange struct {
+	AccountID     string     `json:"account_id"`
+	AssetID       bc.AssetID `json:"asset_id"`
+	Delta         int64      `json:"delta"`
+	TransactionID bc.Hash    `json:"transaction_id"`
+}
+
+// A Subscription has each block's balance changes of an
+// account POSTed to a URL. See NotifyBalanceChanges.
+type Subscription struct {
+	ID        string             `json:"id"`
+	AccountID string             `json:"account_id"`
+	URL       string             `json:"url"`
+	Secret    chainjson.HexBytes `json:"secret,omitempty"` // only when created
+	Status    string             `json:"status"`
+	LastError *string            `json:"last_error"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// notification is the body of a request to a subscription's URL.
+type notification struct {
+	SubscriptionID string           `json:"subscription_id"`
+	BlockHeight    uint64           `json:"block_height"`
+	BlockTime      time.Time        `json:"block_time"`
+	Changes        []*BalanceChange `json:"changes"`
+}
+
+// CreateSubscription subscribes rawURL, which must be an http
+// or https URL, to the balance changes of an account. The new
+// subscription has a random secret for verifying notifications;
+// see SignatureHeader.
+func (m *Manager) CreateSubscription(ctx context.Context, accountID, rawURL string) (*Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.WithDetailf(ErrBadSubscription, "url %q is not an absolute http or https URL", rawURL)
+	}
+	_, err = m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		AccountID: accountID,
+		URL:       rawURL,
+		Secret:    make([]byte, 32),
+		Status:    SubscriptionActive,
+	}
+	_, err = rand.Read(sub.Secret)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	const q = `
+		INSERT INTO balance_subscriptions (account_id, url, secret, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err = m.db.QueryRow(ctx, q, accountID, rawURL, []byte(sub.Secret), sub.Status).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting balance subscription")
+	}
+	return sub, nil
+}
+
+// Subscriptions returns the balance subscriptions of an account.
+func (m *Manager) Subscriptions(ctx context.Context, accountID string) ([]*Subscription, error) {
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	const q = `
+		SELECT id, account_id, url, status, last_error, created_at
+		FROM balance_subscriptions WHERE account_id=$1
+		ORDER BY created_at
+	`
+	rows, err := m.db.Query(ctx, q, accountID)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing balance subscriptions")
+	}
+	defer rows.Close()
+	var subs []*Subscription
+	for rows.Next() {
+		sub := new(Subscription)
+		err = rows.Scan(&sub.ID, &sub.AccountID, &sub.URL, &sub.Status, &sub.LastError, &sub.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, errors.Wrap(rows.Err())
+}
+
+// DeleteSubscription deletes a balance subscription.
+func (m *Manager) DeleteSubscription(ctx context.Context, id string) error {
+	var accountID string
+	err := m.db.QueryRow(ctx, `SELECT account_id FROM balance_subscriptions WHERE id=$1`, id).Scan(&accountID)
+	if err == stdsql.ErrNoRows {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "balance subscription id: %s", id)
+	} else if err != nil {
+		return errors.Wrap(err, "finding balance subscription")
+	}
+	_, err = m.findByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(ctx, `DELETE FROM balance_subscriptions WHERE id=$1`, id)
+	return errors.Wrap(err, "deleting balance subscription")
+}
+
+// BalanceChanges returns the changes the transactions in b make
+// to the balances of accounts in this core, in the order of the
+// transactions. Transfers within an account change nothing.
+func (m *Manager) BalanceChanges(ctx context.Context, b *bc.Block) ([]*BalanceChange, error) {
+	var progs [][]byte
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				progs = append(progs, in.ControlProgram())
+			}
+		}
+		for _, out := range tx.Outputs {
+			progs = append(progs, out.ControlProgram)
+		}
+	}
+	accounts, err := m.ControlProgramAccounts(ctx, progs)
+	if err != nil || len(accounts) == 0 {
+		return nil, err
+	}
+
+	var changes []*BalanceChange
+	for _, tx := range b.Transactions {
+		var (
+			txChanges []*BalanceChange
+			byKey     = make(map[[2]string]*BalanceChange)
+		)
+		add := func(prog []byte, assetID bc.AssetID, delta int64) {
+			accountID, ok := accounts[string(prog)]
+			if !ok {
+				return
+			}
+			key := [2]string{accountID, string(assetID[:])}
+			c := byKey[key]
+			if c == nil {
+				c = &BalanceChange{AccountID: accountID, AssetID: assetID, TransactionID: tx.Hash}
+				byKey[key] = c
+				txChanges = append(txChanges, c)
+			}
+			c.Delta += delta
+		}
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				add(in.ControlProgram(), in.AssetID(), -int64(in.Amount()))
+			}
+		}
+		for _, out := range tx.Outputs {
+			add(out.ControlProgram, out.AssetID, int64(out.Amount))
+		}
+		for _, c := range txChanges {
+			if c.Delta != 0 {
+				changes = append(changes, c)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// NotifyBalanceChanges POSTs the balance changes in b to the
+// URLs subscribed to the accounts they belong to. It's a
+// mirror.Hook, so each block's notifications are sent in order
+// of height, at least once: if any delivery fails, the block's
+// notifications are sent again later, but only to the
+// subscriptions that haven't received theirs. Each subscription
+// records the height of the last block delivered to it.
+// A subscription whose deliveries fail maxSubscriptionFailures
+// times in a row is disabled, so as not to hold up the others.
+//
+// A notification may still arrive more than once, if the core
+// stops after delivering it but before recording so. Its
+// subscription_id and block_height together identify it, so
+// receivers can discard duplicates.
+func (m *Manager) NotifyBalanceChanges(ctx context.Context, b *bc.Block) error {
+	const subsQ = `
+		SELECT id, account_id, url, secret, failures FROM balance_subscriptions
+		WHERE status='active' AND delivered_height < $1 ORDER BY created_at
+	`
+	type activeSub struct {
+		id, accountID, url string
+		secret             []byte
+		failures           int
+	}
+	var subs []activeSub
+	err := pg.ForQueryRows(ctx, m.db, subsQ, b.Height, func(id, accountID, target string, secret []byte, failures int) {
+		subs = append(subs, activeSub{id, accountID, target, secret, failures})
+	})
+	if err != nil || len(subs) == 0 {
+		return errors.Wrap(err, "listing balance subscriptions")
+	}
+
+	changes, err := m.BalanceChanges(ctx, b)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	byAccount := make(map[string][]*BalanceChange)
+	for _, c := range changes {
+		byAccount[c.AccountID] = append(byAccount[c.AccountID], c)
+	}
+
+	var retErr error
+	for _, sub := range subs {
+		accountChanges := byAccount[sub.accountID]
+		if len(accountChanges) == 0 {
+			continue
+		}
+		err := deliver(ctx, sub.url, sub.secret, &notification{
+			SubscriptionID: sub.id,
+			BlockHeight:    b.Height,
+			BlockTime:      b.Time(),
+			Changes:        accountChanges,
+		})
+		if err == nil {
+			const q = `
+				UPDATE balance_subscriptions
+				SET delivered_height=$2, failures=0, last_error=NULL
+				WHERE id=$1
+			`
+			_, err = m.db.Exec(ctx, q, sub.id, b.Height)
+			if err != nil {
+				return errors.Wrap(err, "saving balance subscription")
+			}
+			continue
+		}
+
+		log.Error(ctx, err, "balance subscription", sub.id)
+		status := SubscriptionActive
+		if sub.failures+1 >= maxSubscriptionFailures {
+			status = SubscriptionDisabled
+		} else {
+			retErr = err
+		}
+		const q = `
+			UPDATE balance_subscriptions SET failures=failures+1, last_error=$2, status=$3
+			WHERE id=$1
+		`
+		_, err = m.db.Exec(ctx, q, sub.id, err.Error(), status)
+		if err != nil {
+			return errors.Wrap(err, "saving balance subscription")
+		}
+	}
+	return retErr
+}
+
+// deliver POSTs n to target, signed with secret.
+func deliver(ctx context.Context, target string, secret []byte, n *notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	resp, err := webhookClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "notifying "+target)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notifying %s: %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package account
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestNotifyBalanceChanges(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	acc1 := m.createTestAccount(ctx, t, "", nil)
+	acc2 := m.createTestAccount(ctx, t, "", nil)
+	acp1 := m.createTestControlProgram(ctx, t, acc1.ID)
+	acp1Change := m.createTestControlProgram(ctx, t, acc1.ID)
+	acp2 := m.createTestControlProgram(ctx, t, acc2.ID)
+
+	var (
+		secret []byte
+		got    []notification
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if sig := req.Header.Get(SignatureHeader); sig != hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("%s = %s, want HMAC of body", SignatureHeader, sig)
+		}
+		var n notification
+		err := json.Unmarshal(body, &n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}))
+	defer server.Close()
+
+	sub, err := m.CreateSubscription(ctx, acc1.ID, server.URL)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	secret = sub.Secret
+
+	issue := bc.NewTx(bc.TxData{
+		Version: 1,
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 10, acp1, nil)},
+	})
+	pay := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(issue.Hash, 0, nil, bc.AssetID{}, 10, acp1, nil)},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(bc.AssetID{}, 3, acp2, nil),
+			bc.NewTxOutput(bc.AssetID{}, 7, acp1Change, nil),
+		},
+	})
+	b := &bc.Block{
+		BlockHeader:  bc.BlockHeader{Height: 2},
+		Transactions: []*bc.Tx{issue, pay},
+	}
+	err = m.NotifyBalanceChanges(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	n := got[0]
+	if n.SubscriptionID != sub.ID || n.BlockHeight != 2 || len(n.Changes) != 2 {
+		t.Fatalf("notification = %+v, want 2 changes at height 2 for %s", n, sub.ID)
+	}
+	for i, want := range []BalanceChange{
+		{AccountID: acc1.ID, AssetID: bc.AssetID{}, Delta: 10, TransactionID: issue.Hash},
+		{AccountID: acc1.ID, AssetID: bc.AssetID{}, Delta: -3, TransactionID: pay.Hash},
+	} {
+		if *n.Changes[i] != want {
+			t.Errorf("change %d = %+v want %+v", i, *n.Changes[i], want)
+		}
+	}
+
+	_, err = m.CreateSubscription(ctx, acc1.ID, "ftp://example.com/")
+	if err == nil {
+		t.Error("CreateSubscription(ftp URL) succeeded, want error")
+	}
+}
+
+func TestNotifyBalanceChangesRetry(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	acc := m.createTestAccount(ctx, t, "", nil)
+	acp := m.createTestControlProgram(ctx, t, acc.ID)
+
+	var okCalls, failCalls int
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		okCalls++
+	}))
+	defer ok.Close()
+	failing := true
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		failCalls++
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer flaky.Close()
+
+	for _, url := range []string{ok.URL, flaky.URL} {
+		_, err := m.CreateSubscription(ctx, acc.ID, url)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	b := &bc.Block{
+		BlockHeader: bc.BlockHeader{Height: 2},
+		Transactions: []*bc.Tx{bc.NewTx(bc.TxData{
+			Version: 1,
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 10, acp, nil)},
+		})},
+	}
+	err := m.NotifyBalanceChanges(ctx, b)
+	if err == nil {
+		t.Fatal("NotifyBalanceChanges with a failing subscription succeeded, want error")
+	}
+
+	failing = false
+	err = m.NotifyBalanceChanges(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if okCalls != 1 || failCalls != 2 {
+		t.Errorf("got %d and %d deliveries, want 1 to the working subscription and 2 to the failing one", okCalls, failCalls)
+	}
+}
@@ -0,0 +1,68 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+
+	"chain/errors"
+)
+
+// ErrBadParent indicates a parent account that would make an
+// account its own ancestor, or that belongs to another tenant.
+var ErrBadParent = errors.New("invalid parent account")
+
+// SetParent makes parentID the parent of the account with ID
+// accountID, or, if parentID is empty, removes its parent.
+//
+// The relationship only groups accounts for queries and reports,
+// which can roll the balances and transactions of an account's
+// descendants up into it. It has no effect on the blockchain:
+// each account keeps its own keys, outputs, and spending limits.
+func (m *Manager) SetParent(ctx context.Context, accountID, parentID string) (*Account, error) {
+	account, err := m.find(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	parentSQL := stdsql.NullString{String: parentID, Valid: parentID != ""}
+	if parentSQL.Valid {
+		parent, err := m.find(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.tenant != account.tenant {
+			return nil, errors.WithDetailf(ErrBadParent, "account %s belongs to another tenant", parentID)
+		}
+		cycle, err := m.isAncestor(ctx, accountID, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, errors.WithDetailf(ErrBadParent, "account %s is %s or one of its descendants", parentID, accountID)
+		}
+	}
+
+	const q = `
+		UPDATE accounts SET parent_id=$2 WHERE account_id=$1
+		RETURNING alias, tags, watch_only, parent_id
+	`
+	return m.update(ctx, account.signer, q, accountID, parentSQL)
+}
+
+// isAncestor reports whether the account with ID ancestor
+// is id or one of its ancestors.
+func (m *Manager) isAncestor(ctx context.Context, ancestor, id string) (bool, error) {
+	// UNION, unlike UNION ALL, ends the recursion even if
+	// concurrent calls to SetParent have made a cycle.
+	const q = `
+		WITH RECURSIVE up(id) AS (
+			SELECT $1::text
+			UNION
+			SELECT a.parent_id FROM accounts a JOIN up ON a.account_id=up.id
+			WHERE a.parent_id IS NOT NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM up WHERE id=$2)
+	`
+	var found bool
+	err := m.db.QueryRow(ctx, q, id, ancestor).Scan(&found)
+	return found, errors.Wrap(err)
+}
@@ -0,0 +1,64 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/tenant"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestSetParent(t *testing.T) {
+	t.Parallel()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	omnibus := m.createTestAccount(ctx, t, "omnibus", nil)
+	customer := m.createTestAccount(ctx, t, "customer", nil)
+	sub := m.createTestAccount(ctx, t, "sub", nil)
+
+	got, err := m.SetParent(ctx, customer.ID, omnibus.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.ParentID != omnibus.ID {
+		t.Errorf("ParentID = %q, want %q", got.ParentID, omnibus.ID)
+	}
+	_, err = m.SetParent(ctx, sub.ID, customer.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Creating the account again keeps its parent.
+	again, err := m.create(ctx, customer.Signer, "customer", nil, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if again.ParentID != omnibus.ID {
+		t.Errorf("recreated ParentID = %q, want %q", again.ParentID, omnibus.ID)
+	}
+
+	for _, id := range []string{omnibus.ID, sub.ID} {
+		_, err = m.SetParent(ctx, omnibus.ID, id)
+		if errors.Root(err) != ErrBadParent {
+			t.Errorf("SetParent(omnibus, %s) error = %v, want %v", id, err, ErrBadParent)
+		}
+	}
+
+	other := m.createTestAccount(tenant.NewContext(ctx, "t1"), t, "other", nil)
+	_, err = m.SetParent(ctx, other.ID, omnibus.ID)
+	if errors.Root(err) != ErrBadParent {
+		t.Errorf("SetParent across tenants error = %v, want %v", err, ErrBadParent)
+	}
+
+	got, err = m.SetParent(ctx, customer.ID, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.ParentID != "" {
+		t.Errorf("ParentID = %q after removing parent, want none", got.ParentID)
+	}
+}
@@ -0,0 +1,301 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/tenant"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+// Rescan statuses.
+const (
+	RescanRunning = "running"
+	RescanDone    = "done"
+	RescanFailed  = "failed"
+)
+
+// rescanSaveInterval is the number of blocks a rescan
+// reads between saves of its progress.
+const rescanSaveInterval = 1000
+
+// Rescan is a search of the blockchain, from block FromHeight on,
+// for outputs paying to control programs imported into an
+// account, and for spends of them. ScannedHeight is the last
+// block searched so far.
+type Rescan struct {
+	ID            string `json:"id"`
+	AccountID     string `json:"account_id"`
+	FromHeight    uint64 `json:"from_height"`
+	ScannedHeight uint64 `json:"scanned_height"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+
+	programs pq.ByteaArray
+}
+
+// A Reindexer replaces the query index entries of the blocks
+// in which a rescan finds outputs or spends, so they are
+// annotated with their account.
+type Reindexer interface {
+	// ReindexRange returns the heights of the first and
+	// last blocks whose entries ReindexBlock may replace.
+	ReindexRange(ctx context.Context) (first, last uint64, err error)
+
+	ReindexBlock(ctx context.Context, b *bc.Block) error
+}
+
+// StartRescan records a rescan, from block height from, for
+// outputs paying to progs, control programs just imported into
+// the account with the given ID, and returns it.
+// ProcessRescans runs it in the background.
+func (m *Manager) StartRescan(ctx context.Context, accountID string, progs [][]byte, from uint64) (*Rescan, error) {
+	if from == 0 {
+		from = 1
+	}
+	r := &Rescan{
+		AccountID:     accountID,
+		FromHeight:    from,
+		ScannedHeight: from - 1,
+		Status:        RescanRunning,
+		programs:      progs,
+	}
+	const q = `
+		INSERT INTO account_rescans (account_id, control_programs,
+			from_height, scanned_height, status, tenant)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err := m.db.QueryRow(ctx, q, accountID, r.programs, r.FromHeight, r.ScannedHeight,
+		r.Status, tenant.FromContext(ctx)).Scan(&r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting rescan")
+	}
+	return r, nil
+}
+
+// FindRescan returns the rescan with the given ID.
+func (m *Manager) FindRescan(ctx context.Context, id string) (*Rescan, error) {
+	rescans, err := m.listRescans(ctx, `id=$2`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rescans) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "rescan %s", id)
+	}
+	return rescans[0], nil
+}
+
+// listRescans returns the rescans visible to the tenant that ctx
+// acts for that match where. The tenant is parameter $1 of the
+// query, and args are parameters $2 and up.
+func (m *Manager) listRescans(ctx context.Context, where string, args ...interface{}) ([]*Rescan, error) {
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+	q := `
+		SELECT id, account_id, control_programs, from_height,
+			scanned_height, status, error
+		FROM account_rescans
+		WHERE ($1='' OR tenant=$1) AND ` + where
+	var rescans []*Rescan
+	args = append(args, func(id, accountID string, progs pq.ByteaArray, from, scanned uint64, status string, errStr sql.NullString) {
+		rescans = append(rescans, &Rescan{
+			ID:            id,
+			AccountID:     accountID,
+			FromHeight:    from,
+			ScannedHeight: scanned,
+			Status:        status,
+			Error:         errStr.String,
+			programs:      progs,
+		})
+	})
+	err := pg.ForQueryRows(ctx, m.db, q, args...)
+	return rescans, errors.Wrap(err, "listing rescans")
+}
+
+// ProcessRescans runs the rescans that haven't finished, checking
+// for them every period, until ctx is done. It must run only in
+// the leader process, alongside the account block processor.
+// If reindexer is not nil, it updates the query index of the
+// blocks each rescan finds outputs or spends in.
+func (m *Manager) ProcessRescans(ctx context.Context, period time.Duration, reindexer Reindexer) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, ProcessRescans exiting")
+			return
+		case <-ticks:
+			err := m.runRescans(ctx, reindexer)
+			if err != nil && ctx.Err() == nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+func (m *Manager) runRescans(ctx context.Context, reindexer Reindexer) error {
+	rescans, err := m.listRescans(ctx, `status=$2 ORDER BY id`, RescanRunning)
+	if err != nil {
+		return err
+	}
+	for _, r := range rescans {
+		err = m.runRescan(ctx, r, reindexer)
+		if ctx.Err() != nil {
+			// Leadership was lost; the next
+			// leader will carry on.
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Error(ctx, err, "rescan", r.ID)
+			r.Status, r.Error = RescanFailed, err.Error()
+		} else {
+			r.Status = RescanDone
+		}
+		err = m.saveRescan(ctx, r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRescan searches the blocks after r.ScannedHeight through the
+// last one the account block processor has indexed. The processor
+// finds the imported programs in later blocks itself. Only blocks
+// with outputs paying to the programs, or spends of those outputs,
+// are indexed again.
+func (m *Manager) runRescan(ctx context.Context, r *Rescan, reindexer Reindexer) error {
+	progs := make(map[string]bool, len(r.programs))
+	for _, p := range r.programs {
+		progs[string(p)] = true
+	}
+
+	// The outputs found so far that are still unspent,
+	// in case an earlier leader started the rescan.
+	found := make(map[bc.Outpoint]bool)
+	const q = `
+		SELECT tx_hash, index FROM account_utxos
+		WHERE control_program IN (SELECT unnest($1::bytea[]))
+	`
+	err := pg.ForQueryRows(ctx, m.db, q, r.programs, func(hash bc.Hash, index uint32) {
+		found[bc.Outpoint{Hash: hash, Index: index}] = true
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading rescanned outputs")
+	}
+
+	var reindexFrom, reindexThrough uint64
+	if reindexer != nil {
+		reindexFrom, reindexThrough, err = reindexer.ReindexRange(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for h := r.ScannedHeight + 1; h <= m.pinStore.Height(PinName); h++ {
+		b, err := m.chain.GetBlock(ctx, h)
+		if err != nil {
+			return errors.Wrapf(err, "getting block %d", h)
+		}
+		changed, err := m.rescanBlock(ctx, b, progs, found)
+		if err != nil {
+			return errors.Wrapf(err, "rescanning block %d", h)
+		}
+		if changed && reindexer != nil && h >= reindexFrom && h <= reindexThrough {
+			err = reindexer.ReindexBlock(ctx, b)
+			if err != nil {
+				return errors.Wrapf(err, "reindexing block %d", h)
+			}
+		}
+		r.ScannedHeight = h
+		if changed || h%rescanSaveInterval == 0 {
+			err = m.saveRescan(ctx, r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rescanBlock records the outputs in b paying to progs as account
+// UTXOs, and deletes those among found that b spends. It reports
+// whether it changed anything.
+func (m *Manager) rescanBlock(ctx context.Context, b *bc.Block, progs map[string]bool, found map[bc.Outpoint]bool) (bool, error) {
+	var outs []*state.Output
+	blockPositions := make(map[bc.Hash]uint32, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		blockPositions[tx.Hash] = uint32(i)
+		for j, out := range tx.Outputs {
+			if progs[string(out.ControlProgram)] {
+				outs = append(outs, &state.Output{
+					TxOutput: *out,
+					Outpoint: bc.Outpoint{Hash: tx.Hash, Index: uint32(j)},
+				})
+			}
+		}
+	}
+	var accOuts []*output
+	if len(outs) > 0 {
+		var err error
+		accOuts, err = m.loadAccountInfo(ctx, outs)
+		if err != nil {
+			return false, errors.Wrap(err, "loading account info from control programs")
+		}
+		err = m.upsertConfirmedAccountOutputs(ctx, accOuts, blockPositions, b)
+		if err != nil {
+			return false, errors.Wrap(err, "upserting confirmed account utxos")
+		}
+		err = m.recordControlProgramUses(ctx, accOuts, b)
+		if err != nil {
+			return false, err
+		}
+		for _, out := range accOuts {
+			found[out.Outpoint] = true
+		}
+	}
+
+	var (
+		deltxhash pq.StringArray
+		delindex  pg.Uint32s
+	)
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() || !found[in.Outpoint()] {
+				continue
+			}
+			o := in.Outpoint()
+			delete(found, o)
+			deltxhash = append(deltxhash, o.Hash.String())
+			delindex = append(delindex, o.Index)
+		}
+	}
+	if len(deltxhash) > 0 {
+		const delQ = `
+			DELETE FROM account_utxos
+			WHERE (tx_hash, index) IN (SELECT unnest($1::text[]), unnest($2::integer[]))
+		`
+		_, err := m.db.Exec(ctx, delQ, deltxhash, delindex)
+		if err != nil {
+			return false, errors.Wrap(err, "deleting spent account utxos")
+		}
+	}
+	return len(accOuts) > 0 || len(deltxhash) > 0, nil
+}
+
+func (m *Manager) saveRescan(ctx context.Context, r *Rescan) error {
+	var errStr *string
+	if r.Error != "" {
+		errStr = &r.Error
+	}
+	const q = `UPDATE account_rescans SET scanned_height=$2, status=$3, error=$4 WHERE id=$1`
+	_, err := m.db.Exec(ctx, q, r.ID, r.ScannedHeight, r.Status, errStr)
+	return errors.Wrap(err, "saving rescan")
+}
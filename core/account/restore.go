@@ -0,0 +1,204 @@
+package account
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"chain/core/pin"
+	"chain/core/signers"
+	"chain/errors"
+	"chain/protocol"
+)
+
+// restoreBlockGap is the number of consecutive blocks of control
+// program indexes with no outputs on the blockchain after which
+// Restore stops looking. Each time a core starts, it reserves
+// a new block of indexes, even if it never uses them.
+const restoreBlockGap = 10
+
+// ErrBadRestore is returned by Restore when
+// an account to restore is incomplete.
+var ErrBadRestore = errors.New("invalid account to restore")
+
+// RestoreAccount describes an account to be restored.
+// KeyIndex is the index of the account's signer, as in
+// the first element of its account_derivation_path.
+// If ID is empty, it is derived from RootXPubs and Quorum
+// as by CreateDeterministic, and so is KeyIndex if it is 0.
+type RestoreAccount struct {
+	ID        string                 `json:"id"`
+	Alias     string                 `json:"alias"`
+	RootXPubs []string               `json:"root_xpubs"`
+	Quorum    int                    `json:"quorum"`
+	KeyIndex  uint64                 `json:"key_index"`
+	Tags      map[string]interface{} `json:"tags"`
+	WatchOnly bool                   `json:"watch_only"`
+	ParentID  string                 `json:"parent_id"`
+}
+
+// Restore rebuilds accounts on a core whose database has been lost,
+// given their keys and the blockchain in store.
+// It recreates each account, then reads every block in store once,
+// noting the control programs of its outputs, and records the
+// account control programs among them. Within each block of indexes
+// reserved by nextIndex, it looks for control programs until it
+// has derived gap consecutive unused ones. A control program may be
+// paid in any block, before or after those of lower indexes, so
+// Restore keeps every control program on the blockchain in memory
+// rather than reading the blockchain again for each one it derives.
+//
+// Restore deletes the account UTXOs and rewinds the account pin,
+// so the core indexes the whole blockchain again when it starts.
+// It must only be called while the core is not running.
+// It returns the number of control programs restored.
+func (m *Manager) Restore(ctx context.Context, store protocol.Store, accts []RestoreAccount, gap int) (int, error) {
+	var accounts []*signers.Signer
+	for _, a := range accts {
+		if a.KeyIndex == 0 && a.ID != "" {
+			return 0, errors.WithDetailf(ErrBadRestore, "account %q: key_index is required", a.Alias)
+		}
+		signer, err := signers.Restore(ctx, m.db, "account", a.ID, a.RootXPubs, a.Quorum, a.KeyIndex)
+		if err != nil {
+			return 0, errors.Wrapf(err, "restoring account %q", a.Alias)
+		}
+		_, err = m.create(ctx, signer, a.Alias, a.Tags, a.WatchOnly)
+		if err != nil {
+			return 0, errors.Wrapf(err, "restoring account %q", a.Alias)
+		}
+		accounts = append(accounts, signer)
+	}
+	// A parent may come after its children.
+	for i, a := range accts {
+		if a.ParentID == "" {
+			continue
+		}
+		_, err := m.SetParent(ctx, accounts[i].ID, a.ParentID)
+		if err != nil {
+			return 0, errors.Wrapf(err, "restoring account %q", a.Alias)
+		}
+	}
+
+	height, err := store.Height(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err)
+	}
+
+	used := make(map[string]bool)
+	for h := uint64(1); h <= height; h++ {
+		b, err := store.GetBlock(ctx, h)
+		if err != nil {
+			return 0, errors.Wrapf(err, "getting block %d", h)
+		}
+		for _, tx := range b.Transactions {
+			for _, out := range tx.Outputs {
+				used[string(out.ControlProgram)] = true
+			}
+		}
+	}
+
+	s := &restoreScan{
+		accounts: accounts,
+		gap:      uint64(gap),
+		derived:  make(map[uint64]uint64),
+	}
+	s.extend(0)
+	var (
+		progs   []*controlProgram
+		maxUsed uint64
+	)
+	for len(s.pending) > 0 {
+		cp := s.pending[0]
+		s.pending = s.pending[1:]
+		if !used[string(cp.controlProgram)] {
+			continue
+		}
+		progs = append(progs, cp)
+		if cp.keyIndex > maxUsed {
+			maxUsed = cp.keyIndex
+		}
+		s.extend(cp.keyIndex)
+	}
+	if len(progs) > 0 {
+		const delQ = `DELETE FROM account_control_programs WHERE control_program IN (SELECT unnest($1::bytea[]))`
+		var programs pq.ByteaArray
+		for _, cp := range progs {
+			programs = append(programs, cp.controlProgram)
+		}
+		_, err = m.db.Exec(ctx, delQ, programs)
+		if err != nil {
+			return 0, errors.Wrap(err)
+		}
+		err = m.insertAccountControlProgram(ctx, progs...)
+		if err != nil {
+			return 0, errors.Wrap(err)
+		}
+		err = m.reserveIndexesThrough(ctx, maxUsed)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = m.db.Exec(ctx, `DELETE FROM account_utxos`)
+	if err != nil {
+		return 0, errors.Wrap(err)
+	}
+	err = pin.Rewind(ctx, m.db, PinName, 0)
+	return len(progs), err
+}
+
+// restoreScan keeps track of the control programs
+// derived by Restore.
+type restoreScan struct {
+	accounts []*signers.Signer
+	gap      uint64
+
+	// derived maps each block of indexes to the
+	// number of indexes derived in it so far.
+	derived map[uint64]uint64
+
+	// pending holds the control programs derived
+	// but not yet looked for on the blockchain.
+	pending []*controlProgram
+}
+
+// extend derives control programs for gap indexes after
+// index, and for the first gap indexes of each of the
+// restoreBlockGap blocks of indexes after index's block.
+func (s *restoreScan) extend(index uint64) {
+	var block uint64
+	if index > 0 {
+		block = (index - 1) / cpIndexBlock
+		s.derive(block, index-block*cpIndexBlock+s.gap)
+		block++
+	}
+	for i := uint64(0); i < restoreBlockGap; i++ {
+		s.derive(block+i, s.gap)
+	}
+}
+
+// derive derives control programs for the first n indexes
+// in the given block of indexes, for each account.
+func (s *restoreScan) derive(block, n uint64) {
+	if n > cpIndexBlock {
+		n = cpIndexBlock
+	}
+	for i := s.derived[block] + 1; i <= n; i++ {
+		idx := block*cpIndexBlock + i
+		for _, a := range s.accounts {
+			prog, err := deriveControlProgram(a, idx)
+			if err != nil {
+				// Keys and quorum were checked by signers.Restore.
+				panic(err)
+			}
+			s.pending = append(s.pending, &controlProgram{
+				accountID:      a.ID,
+				keyIndex:       idx,
+				controlProgram: prog,
+			})
+		}
+	}
+	if n > s.derived[block] {
+		s.derived[block] = n
+	}
+}
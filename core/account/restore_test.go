@@ -0,0 +1,91 @@
+package account
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/memstore"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, db1 := pgtest.NewDB(t, pgtest.SchemaPath)
+	m1 := NewManager(db1, prottest.NewChain(t), nil)
+	acc := m1.createTestAccount(ctx, t, "alice", nil)
+	m1.createTestControlProgram(ctx, t, acc.ID) // index 1, never paid
+	cp2 := m1.createTestControlProgram(ctx, t, acc.ID)
+
+	// Simulate a restart of the core, which
+	// reserves a new block of indexes.
+	m1.acpIndexNext, m1.acpIndexCap = 0, 0
+	cp10001 := m1.createTestControlProgram(ctx, t, acc.ID)
+
+	store := memstore.New()
+	for h, prog := range [][]byte{nil, cp2, cp10001} {
+		b := &bc.Block{BlockHeader: bc.BlockHeader{Height: uint64(h + 1)}}
+		if prog != nil {
+			b.Transactions = []*bc.Tx{bc.NewTx(bc.TxData{
+				Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, prog, nil)},
+			})}
+		}
+		err := store.SaveBlock(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	m2 := NewManager(db2, prottest.NewChain(t), nil)
+	accts := []RestoreAccount{{
+		ID:        acc.ID,
+		Alias:     "alice",
+		RootXPubs: []string{dummyXPub},
+		Quorum:    1,
+		KeyIndex:  acc.KeyIndex,
+	}}
+	n, err := m2.Restore(ctx, store, accts, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 2 {
+		t.Errorf("Restore() = %d control programs, want 2", n)
+	}
+
+	var indexes []int
+	const q = `SELECT key_index FROM account_control_programs WHERE signer_id=$1`
+	err = pg.ForQueryRows(ctx, m2.db, q, acc.ID, func(i int) {
+		indexes = append(indexes, i)
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	sort.Ints(indexes)
+	if len(indexes) != 2 || indexes[0] != 2 || indexes[1] != 10001 {
+		t.Errorf("restored control program indexes = %v, want [2 10001]", indexes)
+	}
+
+	found, err := m2.FindByAlias(ctx, "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.ID != acc.ID || found.KeyIndex != acc.KeyIndex {
+		t.Errorf("restored account = %s (key index %d), want %s (key index %d)", found.ID, found.KeyIndex, acc.ID, acc.KeyIndex)
+	}
+
+	// New control programs must not reuse restored indexes.
+	idx, err := m2.nextIndex(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if idx <= 10001 {
+		t.Errorf("next index after restore = %d, want > 10001", idx)
+	}
+}
@@ -0,0 +1,101 @@
+package account
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// A Reuse describes a control program of an account
+// that more than one transaction has paid to.
+// Reusing control programs lets observers of the
+// blockchain link an account's payments together.
+type Reuse struct {
+	AccountID      string        `json:"account_id"`
+	ControlProgram json.HexBytes `json:"control_program"`
+	SingleUse      bool          `json:"single_use"`
+	Transactions   uint64        `json:"transaction_count"`
+	LastUsedHeight uint64        `json:"last_used_height"`
+}
+
+// Reuses returns the control programs of the account that
+// more than one transaction has paid to, single-use ones first.
+func (m *Manager) Reuses(ctx context.Context, accountID string) ([]*Reuse, error) {
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		SELECT control_program, single_use, use_count, last_used_height
+		FROM account_control_programs
+		WHERE signer_id=$1 AND use_count > 1
+		ORDER BY single_use DESC, key_index
+	`
+	var reuses []*Reuse
+	err = pg.ForQueryRows(ctx, m.db, q, accountID, func(prog []byte, singleUse bool, uses, height uint64) {
+		reuses = append(reuses, &Reuse{
+			AccountID:      accountID,
+			ControlProgram: prog,
+			SingleUse:      singleUse,
+			Transactions:   uses,
+			LastUsedHeight: height,
+		})
+	})
+	return reuses, errors.Wrap(err, "listing control program reuses")
+}
+
+// recordControlProgramUses counts the transactions in b that
+// pay to each account control program in outs, and logs
+// the reuse of single-use control programs. Each block
+// is counted once, even if it is indexed again.
+func (m *Manager) recordControlProgramUses(ctx context.Context, outs []*output, b *bc.Block) error {
+	txs := make(map[string]map[bc.Hash]bool)
+	for _, out := range outs {
+		prog := string(out.ControlProgram)
+		if txs[prog] == nil {
+			txs[prog] = make(map[bc.Hash]bool)
+		}
+		txs[prog][out.Outpoint.Hash] = true
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+
+	var (
+		progs  pq.ByteaArray
+		counts pq.Int64Array
+	)
+	for prog, hashes := range txs {
+		progs = append(progs, []byte(prog))
+		counts = append(counts, int64(len(hashes)))
+	}
+
+	const q = `
+		UPDATE account_control_programs a
+		SET use_count = a.use_count + u.n, last_used_height = $3
+		FROM (SELECT unnest($1::bytea[]) AS control_program, unnest($2::bigint[]) AS n) u
+		WHERE a.control_program = u.control_program
+			AND (a.last_used_height IS NULL OR a.last_used_height < $3)
+		RETURNING a.signer_id, a.control_program, a.single_use, a.use_count
+	`
+	err := pg.ForQueryRows(ctx, m.db, q, progs, counts, b.Height, func(accountID string, prog []byte, singleUse bool, uses uint64) {
+		if singleUse && uses > 1 {
+			log.Write(ctx,
+				"at", "single-use control program reused",
+				"account_id", accountID,
+				"control_program", hex.EncodeToString(prog),
+				"transactions", uses,
+				"block_height", b.Height,
+			)
+		}
+	})
+	return errors.Wrap(err, "recording control program uses")
+}
@@ -0,0 +1,61 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/protocol/state"
+	"chain/testutil"
+)
+
+func TestControlProgramReuses(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	acc := m.createTestAccount(ctx, t, "", nil)
+	once := m.createTestControlProgram(ctx, t, acc.ID)
+	single, err := m.CreateSingleUseControlProgram(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// pay indexes a block at height with
+	// a transaction paying to each of progs.
+	pay := func(height uint64, progs ...[]byte) {
+		var outs []*state.Output
+		for i, prog := range progs {
+			outs = append(outs, &state.Output{
+				Outpoint: bc.Outpoint{Hash: bc.Hash{byte(height), byte(i)}},
+				TxOutput: *bc.NewTxOutput(bc.AssetID{}, 1, prog, nil),
+			})
+		}
+		accOuts, err := m.loadAccountInfo(ctx, outs)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		err = m.recordControlProgramUses(ctx, accOuts, &bc.Block{BlockHeader: bc.BlockHeader{Height: height}})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	pay(1, once, single)
+	pay(2, single)
+	pay(2, single) // indexing a block again doesn't count it twice
+
+	got, err := m.Reuses(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d reuses, want 1", len(got))
+	}
+	r := got[0]
+	if string(r.ControlProgram) != string(single) || !r.SingleUse || r.Transactions != 2 || r.LastUsedHeight != 2 {
+		t.Errorf("got reuse %+v, want single-use program used by 2 transactions through height 2", r)
+	}
+}
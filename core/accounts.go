@@ -2,25 +2,72 @@ package core
 
 import (
 	"context"
+	"strconv"
 	"sync"
 
+	"chain/core/account"
+	"chain/core/query"
 	"chain/core/signers"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 )
 
 // This type enforces JSON field ordering in API output.
 type accountResponse struct {
-	ID     interface{} `json:"id"`
-	Alias  interface{} `json:"alias"`
-	Keys   interface{} `json:"keys"`
-	Quorum interface{} `json:"quorum"`
-	Tags   interface{} `json:"tags"`
+	ID        interface{} `json:"id"`
+	Alias     interface{} `json:"alias"`
+	Keys      interface{} `json:"keys"`
+	Quorum    interface{} `json:"quorum"`
+	Tags      interface{} `json:"tags"`
+	WatchOnly interface{} `json:"watch_only"`
+	ParentID  interface{} `json:"parent_id"`
+}
+
+func newAccountResponse(acc *account.Account, keys []accountKey) *accountResponse {
+	r := &accountResponse{
+		ID:        acc.ID,
+		Alias:     acc.Alias,
+		Keys:      keys,
+		Quorum:    acc.Quorum,
+		Tags:      acc.Tags,
+		WatchOnly: acc.WatchOnly,
+	}
+	if acc.ParentID != "" {
+		r.ParentID = acc.ParentID
+	}
+	return r
 }
 
 type accountKey struct {
-	RootXPub              interface{} `json:"root_xpub"`
-	AccountXPub           interface{} `json:"account_xpub"`
-	AccountDerivationPath interface{} `json:"account_derivation_path"`
+	RootXPub              interface{}          `json:"root_xpub"`
+	AccountXPub           interface{}          `json:"account_xpub"`
+	AccountDerivationPath interface{}          `json:"account_derivation_path"`
+	Metadata              *signers.KeyMetadata `json:"metadata,omitempty"`
+}
+
+// accountKeys returns the keys of acc, with their metadata.
+func (h *Handler) accountKeys(ctx context.Context, acc *signers.Signer) ([]accountKey, error) {
+	var xpubs []string
+	for _, xpub := range acc.XPubs {
+		xpubs = append(xpubs, xpub.String())
+	}
+	mds, err := signers.GetKeyMetadata(ctx, h.DB, xpubs)
+	if err != nil {
+		return nil, err
+	}
+	path := signers.Path(acc, signers.AccountKeySpace)
+	var keys []accountKey
+	for _, xpub := range acc.XPubs {
+		keys = append(keys, accountKey{
+			RootXPub:              xpub,
+			AccountXPub:           xpub.Derive(path),
+			AccountDerivationPath: path,
+			Metadata:              mds[xpub.String()],
+		})
+	}
+	return keys, nil
 }
 
 // POST /create-account
@@ -35,6 +82,20 @@ func (h *Handler) createAccount(ctx context.Context, ins []struct {
 	// idempotency of create account requests. Duplicate create account requests
 	// with the same client_token will only create one account.
 	ClientToken *string `json:"client_token"`
+
+	// DeterministicID derives the account ID and key index from
+	// root_xpubs and quorum, so a core rebuilt from the same keys
+	// assigns the same ID and derives the same control programs,
+	// instead of allocating new ones.
+	DeterministicID bool `json:"deterministic_id"`
+
+	// WatchOnly marks the account as watch-only: its keys are held
+	// outside of Chain Core, which indexes its outputs but does
+	// not build transactions spending from it.
+	WatchOnly bool `json:"watch_only"`
+
+	// ParentID sets the account's parent, as /set-account-parent does.
+	ParentID string `json:"parent_id"`
 }) interface{} {
 	responses := make([]interface{}, len(ins))
 	var wg sync.WaitGroup
@@ -46,30 +107,271 @@ func (h *Handler) createAccount(ctx context.Context, ins []struct {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
-			acc, err := h.Accounts.Create(subctx, ins[i].RootXPubs, ins[i].Quorum, ins[i].Alias, ins[i].Tags, ins[i].ClientToken)
+			create := h.Accounts.Create
+			if ins[i].DeterministicID {
+				create = h.Accounts.CreateDeterministic
+			}
+			acc, err := create(subctx, ins[i].RootXPubs, ins[i].Quorum, ins[i].Alias, ins[i].Tags, ins[i].WatchOnly, ins[i].ClientToken)
+			if err == nil && ins[i].ParentID != "" && ins[i].ParentID != acc.ParentID {
+				acc, err = h.Accounts.SetParent(subctx, acc.ID, ins[i].ParentID)
+			}
 			if err != nil {
 				responses[i] = err
 				return
 			}
-			path := signers.Path(acc.Signer, signers.AccountKeySpace)
-			var keys []accountKey
-			for _, xpub := range acc.XPubs {
-				keys = append(keys, accountKey{
-					RootXPub:              xpub,
-					AccountXPub:           xpub.Derive(path),
-					AccountDerivationPath: path,
-				})
-			}
-			responses[i] = &accountResponse{
-				ID:     acc.ID,
-				Alias:  acc.Alias,
-				Keys:   keys,
-				Quorum: acc.Quorum,
-				Tags:   acc.Tags,
+			keys, err := h.accountKeys(subctx, acc.Signer)
+			if err != nil {
+				responses[i] = err
+				return
 			}
+			responses[i] = newAccountResponse(acc, keys)
 		}(i)
 	}
 
 	wg.Wait()
 	return responses
 }
+
+type importControlProgramsRequest struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+
+	// ControlPrograms are arbitrary programs to add to a
+	// watch-only account.
+	ControlPrograms []chainjson.HexBytes `json:"control_programs"`
+
+	// FromIndex and ToIndex, if set, select the account's own
+	// control programs to add, such as those handed out by
+	// another wallet holding the same keys.
+	FromIndex uint64 `json:"from_index"`
+	ToIndex   uint64 `json:"to_index"`
+
+	// RescanFrom is the height of the first block
+	// to search for outputs paying to the imported programs.
+	RescanFrom uint64 `json:"rescan_from"`
+}
+
+// POST /import-control-programs
+//
+// Responds with the number of programs imported and the rescan
+// that searches the blockchain for outputs paying to them. The
+// rescan runs in the background; /get-account-rescan reports
+// its progress.
+func (h *Handler) importControlPrograms(ctx context.Context, in importControlProgramsRequest) (map[string]interface{}, error) {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.AccountID = acc.ID
+	}
+
+	var imported [][]byte
+	if len(in.ControlPrograms) > 0 {
+		progs := make([][]byte, 0, len(in.ControlPrograms))
+		for _, p := range in.ControlPrograms {
+			progs = append(progs, p)
+		}
+		added, err := h.Accounts.ImportControlPrograms(ctx, in.AccountID, progs)
+		if err != nil {
+			return nil, err
+		}
+		imported = append(imported, added...)
+	}
+	if in.FromIndex != 0 || in.ToIndex != 0 {
+		added, err := h.Accounts.ImportIndexRange(ctx, in.AccountID, in.FromIndex, in.ToIndex)
+		if err != nil {
+			return nil, err
+		}
+		imported = append(imported, added...)
+	}
+
+	resp := map[string]interface{}{"imported": len(imported)}
+	if len(imported) > 0 {
+		rescan, err := h.Accounts.StartRescan(ctx, in.AccountID, imported, in.RescanFrom)
+		if err != nil {
+			return nil, err
+		}
+		resp["rescan"] = rescan
+	}
+	return resp, nil
+}
+
+// POST /get-account-rescan
+func (h *Handler) getAccountRescan(ctx context.Context, in struct {
+	ID string `json:"id"`
+}) (*account.Rescan, error) {
+	return h.Accounts.FindRescan(ctx, in.ID)
+}
+
+// POST /set-account-limit
+//
+// Setting both maximums to null removes the limit.
+// Only access tokens of the default tenant may raise
+// or remove a limit; others may only add or lower one.
+func (h *Handler) setAccountLimit(ctx context.Context, in struct {
+	account.Limit
+	AccountAlias string `json:"account_alias"`
+}) error {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return err
+		}
+		in.AccountID = acc.ID
+	}
+	return h.Accounts.SetLimit(ctx, &in.Limit)
+}
+
+// POST /set-account-parent
+//
+// Makes the parent account the parent of the account, so that
+// queries with roll_up_account_id set to the parent, or to any
+// of its ancestors, include the account. Setting neither
+// parent_id nor parent_alias removes the account's parent.
+func (h *Handler) setAccountParent(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+	ParentID     string `json:"parent_id"`
+	ParentAlias  string `json:"parent_alias"`
+}) (*accountResponse, error) {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.AccountID = acc.ID
+	}
+	if in.ParentID == "" && in.ParentAlias != "" {
+		parent, err := h.Accounts.FindByAlias(ctx, in.ParentAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.ParentID = parent.ID
+	}
+	acc, err := h.Accounts.SetParent(ctx, in.AccountID, in.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := h.accountKeys(ctx, acc.Signer)
+	if err != nil {
+		return nil, err
+	}
+	return newAccountResponse(acc, keys), nil
+}
+
+// POST /list-account-limits
+func (h *Handler) listAccountLimits(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) ([]*account.Limit, error) {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.AccountID = acc.ID
+	}
+	limits, err := h.Accounts.Limits(ctx, in.AccountID)
+	if limits == nil {
+		limits = []*account.Limit{}
+	}
+	return limits, err
+}
+
+// POST /list-control-program-reuses
+//
+// Lists the account's control programs that more than one
+// transaction has paid to, for auditing address reuse.
+func (h *Handler) listControlProgramReuses(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) ([]*account.Reuse, error) {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.AccountID = acc.ID
+	}
+	reuses, err := h.Accounts.Reuses(ctx, in.AccountID)
+	if reuses == nil {
+		reuses = []*account.Reuse{}
+	}
+	return reuses, err
+}
+
+// POST /get-account-keys
+//
+// Returns the root xpubs and quorum of an account, with any
+// key metadata, the account xpubs derived from them, and the
+// highest index of the account's control programs: what an
+// external wallet needs to derive the account's keys itself.
+// Accounts of other tenants are not found.
+func (h *Handler) getAccountKeys(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) (map[string]interface{}, error) {
+	var (
+		acc *signers.Signer
+		err error
+	)
+	if in.AccountID == "" {
+		acc, err = h.Accounts.FindByAlias(ctx, in.AccountAlias)
+	} else {
+		acc, err = h.Accounts.FindByID(ctx, in.AccountID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	maxIndex, err := h.Accounts.MaxKeyIndex(ctx, acc.ID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := h.accountKeys(ctx, acc)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"account_id":                acc.ID,
+		"keys":                      keys,
+		"quorum":                    acc.Quorum,
+		"key_index":                 acc.KeyIndex,
+		"max_control_program_index": maxIndex,
+	}, nil
+}
+
+// POST /list-account-derived-keys
+//
+// Lists the control programs derived for an account, in order
+// of index, with the derivation path and public keys of each,
+// for reconciling against an external wallet.
+func (h *Handler) listAccountDerivedKeys(ctx context.Context, in requestQuery) (*page, error) {
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	var (
+		after uint64
+		err   error
+	)
+	if in.After != "" {
+		after, err = strconv.ParseUint(in.After, 10, 63)
+		if err != nil {
+			return nil, errors.WithDetailf(query.ErrBadAfter, "%q", in.After)
+		}
+	}
+
+	keys, err := h.Accounts.DerivedKeys(ctx, in.AccountID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		in.After = strconv.FormatUint(keys[len(keys)-1].KeyIndex, 10)
+	}
+	return &page{
+		Items:    httpjson.Array(keys),
+		LastPage: len(keys) < limit,
+		Next:     in,
+	}, nil
+}
@@ -12,16 +12,30 @@ import (
 
 	"chain/core/accesstoken"
 	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/asset"
+	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/contract"
+	"chain/core/deadletter"
+	"chain/core/explorer"
+	"chain/core/forward"
+	"chain/core/freeze"
+	"chain/core/graphql"
+	"chain/core/htlc"
 	"chain/core/leader"
 	"chain/core/mockhsm"
 	"chain/core/pin"
 	"chain/core/query"
+	"chain/core/refdata"
+	"chain/core/risk"
 	"chain/core/rpc"
+	"chain/core/schedule"
+	"chain/core/signreq"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/crypto/ed25519"
 	"chain/database/pg"
 	"chain/encoding/json"
 	"chain/errors"
@@ -34,6 +48,7 @@ import (
 	"chain/net/http/static"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
 )
 
 const (
@@ -51,28 +66,67 @@ var (
 
 // Handler serves the Chain HTTP API
 type Handler struct {
-	Chain         *protocol.Chain
-	Store         *txdb.Store
-	PinStore      *pin.Store
-	Assets        *asset.Registry
-	Accounts      *account.Manager
-	HSM           *mockhsm.HSM
-	Indexer       *query.Indexer
-	TxFeeds       *txfeed.Tracker
-	AccessTokens  *accesstoken.CredentialStore
-	Config        *config.Config
-	DB            pg.DB
-	Addr          string
-	AltAuth       func(*http.Request) bool
-	Signer        func(context.Context, *bc.Block) ([]byte, error)
-	RequestLimits []RequestLimit
+	Chain              *protocol.Chain
+	Pool               *mempool.MemPool
+	Store              *txdb.Store
+	PinStore           *pin.Store
+	Assets             *asset.Registry
+	Accounts           *account.Manager
+	HSM                *mockhsm.HSM
+	Indexer            *query.Indexer
+	TxFeeds            *txfeed.Tracker
+	AccessTokens       *accesstoken.CredentialStore
+	Approvals          *approval.Controller
+	Freezes            *freeze.Controller
+	HTLCs              *htlc.Coordinator
+	Contracts          *contract.Registry
+	Explorer           *explorer.Explorer
+	Schedules          *schedule.Scheduler
+	ReferenceDataKeys  *refdata.Keyring
+	SubmissionFailures *deadletter.Queue
+	Forwarder          *forward.Forwarder // nil on a generator
+	SigningRequests    *signreq.Tracker
+	RiskScores         *risk.Store
+	Config             *config.Config
+	DB                 pg.DB
+	Addr               string
+	AltAuth            func(*http.Request) bool
+	Signer             func(context.Context, *bc.Block) ([]byte, error)
+	RPCKey             ed25519.PublicKey // signs requests to other cores
+	RequestLimits      []RequestLimit
+
+	// PublicExplorer lets requests to the block explorer
+	// endpoints through without an access token.
+	PublicExplorer bool
+
+	// TxTTL is how long built transactions remain valid when a
+	// build request doesn't give a ttl. Zero means 5 minutes.
+	TxTTL time.Duration
+
+	// MaxPendingTxs, if nonzero, is how many transactions may
+	// wait in a generator's pool before it refuses submissions
+	// with txbuilder.ErrOverloaded, telling clients to retry
+	// after SubmitRetryAfter. Zero SubmitRetryAfter means 5s.
+	MaxPendingTxs    int
+	SubmitRetryAfter time.Duration
+
+	// ScoreSubmissions scores transactions as they're submitted,
+	// as well as once they're confirmed. See risk.Store.
+	ScoreSubmissions bool
 
 	once           sync.Once
 	handler        http.Handler
 	actionDecoders map[string]func(data []byte) (txbuilder.Action, error)
+	graphQLSchema  *graphql.Schema
+	apiRoutes      []httpjson.Route
+	authn          *apiAuthn
+	peers          *peerTracker
 
 	healthMu     sync.Mutex
 	healthErrors map[string]interface{}
+
+	statsMu     sync.Mutex
+	cachedStats *statsResp
 }
 
 type RequestLimit struct {
@@ -104,6 +158,8 @@ func (h *Handler) init() {
 		"set_transaction_reference_data": txbuilder.DecodeSetTxRefDataAction,
 	}
 
+	h.graphQLSchema = h.buildGraphQLSchema()
+
 	// Setup the muxer.
 	needConfig := jsonHandler
 	if h.Config == nil {
@@ -115,28 +171,119 @@ func (h *Handler) init() {
 	m := http.NewServeMux()
 	m.Handle("/", alwaysError(errNotFound))
 
-	m.Handle("/create-account", needConfig(h.createAccount))
-	m.Handle("/create-asset", needConfig(h.createAsset))
-	m.Handle("/build-transaction", needConfig(h.build))
-	m.Handle("/submit-transaction", needConfig(h.submit))
-	m.Handle("/create-control-program", needConfig(h.createControlProgram))
-	m.Handle("/create-transaction-feed", needConfig(h.createTxFeed))
-	m.Handle("/get-transaction-feed", needConfig(h.getTxFeed))
-	m.Handle("/update-transaction-feed", needConfig(h.updateTxFeed))
-	m.Handle("/delete-transaction-feed", needConfig(h.deleteTxFeed))
-	m.Handle("/mockhsm/create-key", needConfig(h.mockhsmCreateKey))
-	m.Handle("/mockhsm/list-keys", needConfig(h.mockhsmListKeys))
-	m.Handle("/mockhsm/delkey", needConfig(h.mockhsmDelKey))
-	m.Handle("/mockhsm/sign-transaction", needConfig(h.mockhsmSignTemplates))
-	m.Handle("/list-accounts", needConfig(h.listAccounts))
-	m.Handle("/list-assets", needConfig(h.listAssets))
-	m.Handle("/list-transaction-feeds", needConfig(h.listTxFeeds))
-	m.Handle("/list-transactions", needConfig(h.listTransactions))
-	m.Handle("/list-balances", needConfig(h.listBalances))
-	m.Handle("/list-unspent-outputs", needConfig(h.listUnspentOutputs))
-	m.Handle("/reset", needConfig(h.reset))
-
-	m.Handle(networkRPCPrefix+"submit", needConfig(h.Chain.AddTx))
+	// api serves f at path, rejecting requests that don't
+	// match its request type, and lists it in /openapi.json.
+	// Unless always is set, f is served only once the core
+	// is configured. Paths in idempotentPaths accept an
+	// Idempotency-Key header.
+	api := func(path string, f interface{}, always bool) {
+		h.apiRoutes = append(h.apiRoutes, httpjson.Route{Path: path, Func: f})
+		if h.Config == nil && !always {
+			m.Handle(path, alwaysError(errUnconfigured))
+			return
+		}
+		handler := strictJSONHandler(f)
+		if idempotentPaths[path] {
+			handler = h.idempotent(path, handler)
+		}
+		m.Handle(path, handler)
+	}
+
+	api("/create-account", h.createAccount, false)
+	api("/create-asset", h.createAsset, false)
+	api("/build-transaction", h.build, false)
+	api("/amend-transaction", h.amendTransaction, false)
+	api("/build-transfer-batch", h.buildTransferBatch, false)
+	api("/describe-transaction", h.describeTransaction, false)
+	api("/submit-transaction", h.submit, false)
+	api("/get-transaction-status", h.getTxStatus, false)
+	api("/list-submission-failures", h.listSubmissionFailures, false)
+	api("/retry-submission-failure", h.retrySubmissionFailure, false)
+	api("/dismiss-submission-failure", h.dismissSubmissionFailure, false)
+	api("/create-control-program", h.createControlProgram, false)
+	api("/create-transaction-feed", h.createTxFeed, false)
+	api("/get-transaction-feed", h.getTxFeed, false)
+	api("/update-transaction-feed", h.updateTxFeed, false)
+	api("/delete-transaction-feed", h.deleteTxFeed, false)
+	api("/mockhsm/create-key", h.mockhsmCreateKey, false)
+	api("/mockhsm/list-keys", h.mockhsmListKeys, false)
+	api("/mockhsm/delkey", h.mockhsmDelKey, false)
+	api("/mockhsm/export-key", h.mockhsmExportKey, false)
+	api("/mockhsm/import-key", h.mockhsmImportKey, false)
+	api("/mockhsm/backup-keys", h.mockhsmBackupKeys, false)
+	api("/mockhsm/restore-keys", h.mockhsmRestoreKeys, false)
+	api("/mockhsm/sign-transaction", h.mockhsmSignTemplates, false)
+	api("/list-accounts", h.listAccounts, false)
+	api("/set-account-limit", h.setAccountLimit, false)
+	api("/import-control-programs", h.importControlPrograms, false)
+	api("/get-account-rescan", h.getAccountRescan, false)
+	api("/list-account-limits", h.listAccountLimits, false)
+	api("/set-account-parent", h.setAccountParent, false)
+	api("/get-account-keys", h.getAccountKeys, false)
+	api("/list-account-derived-keys", h.listAccountDerivedKeys, false)
+	api("/set-key-metadata", h.setKeyMetadata, false)
+	api("/list-key-metadata", h.listKeyMetadata, false)
+	api("/list-control-program-reuses", h.listControlProgramReuses, false)
+	api("/create-balance-subscription", h.createBalanceSubscription, false)
+	api("/list-balance-subscriptions", h.listBalanceSubscriptions, false)
+	api("/delete-balance-subscription", h.deleteBalanceSubscription, false)
+	api("/set-approval-threshold", h.setApprovalThreshold, false)
+	api("/list-approval-thresholds", h.listApprovalThresholds, false)
+	api("/list-approvals", h.listApprovals, false)
+	api("/approve-transaction", h.approveTx, false)
+	api("/reject-transaction", h.rejectTx, false)
+	api("/track-signing-request", h.trackSigningRequest, false)
+	api("/decline-signing-request", h.declineSigningRequest, false)
+	api("/get-signing-request", h.getSigningRequest, false)
+	api("/list-signing-requests", h.listSigningRequests, false)
+	api("/list-signing-events", h.listSigningEvents, false)
+	api("/freeze-asset", h.freezeAsset, false)
+	api("/unfreeze-asset", h.unfreezeAsset, false)
+	api("/list-freezes", h.listFreezes, false)
+	api("/list-freeze-events", h.listFreezeEvents, false)
+	api("/create-htlc", h.createHTLC, false)
+	api("/list-htlcs", h.listHTLCs, false)
+	api("/reveal-htlc-preimage", h.revealHTLCPreimage, false)
+	api("/create-scheduled-payment", h.createScheduledPayment, false)
+	api("/list-scheduled-payments", h.listScheduledPayments, false)
+	api("/get-scheduled-payment", h.getScheduledPayment, false)
+	api("/cancel-scheduled-payment", h.cancelScheduledPayment, false)
+	api("/register-contract-source", h.registerContractSource, false)
+	api("/verify-contract-source", h.verifyContractSource, false)
+	api("/get-contract-source", h.getContractSource, false)
+	api("/encrypt-reference-data", h.encryptReferenceData, false)
+	api("/decrypt-reference-data", h.decryptReferenceData, false)
+	api("/export-reference-data-keys", h.exportReferenceDataKeys, false)
+	api("/import-reference-data-keys", h.importReferenceDataKeys, false)
+	api(explorerPrefix+"get-block", h.explorerGetBlock, false)
+	api(explorerPrefix+"get-transaction", h.explorerGetTransaction, false)
+	api(explorerPrefix+"get-asset", h.explorerGetAsset, false)
+	api(explorerPrefix+"list-program-history", h.explorerListProgramHistory, false)
+	api(explorerPrefix+"get-stats", h.explorerGetStats, false)
+	api("/search", h.search, false)
+	api("/stats", h.stats, false)
+	api("/list-assets", h.listAssets, false)
+	api("/list-transaction-feeds", h.listTxFeeds, false)
+	api("/list-transactions", h.listTransactions, false)
+	api("/list-balances", h.listBalances, false)
+	api("/list-balance-snapshots", h.listBalanceSnapshots, false)
+	api("/list-asset-flows", h.listAssetFlows, false)
+	api("/list-unspent-outputs", h.listUnspentOutputs, false)
+	api("/get-output", h.getOutput, false)
+	api("/trace-output", h.traceOutput, false)
+	api("/get-risk-scores", h.getRiskScores, false)
+	api("/graphql", h.graphQL, false)
+	api("/reset", h.reset, false)
+	api("/halt-generator", h.haltGenerator, false)
+	api("/resume-generator", h.resumeGenerator, false)
+	api("/create-snapshot", h.createSnapshot, false)
+	m.Handle("/export-blocks", http.HandlerFunc(h.exportBlocks))
+	m.Handle("/export-journal", http.HandlerFunc(h.exportJournal))
+	api("/list-signed-blocks", h.listSignedBlocks, false)
+	api("/get-asset-supplies", h.getAssetSupplies, false)
+
+	m.Handle(networkRPCPrefix+"submit", needConfig(h.submitRPC))
+	m.Handle(networkRPCPrefix+"get-transaction-status", needConfig(h.getTxStatusRPC))
 	m.Handle(networkRPCPrefix+"get-blocks", needConfig(h.getBlocksRPC)) // DEPRECATED: use get-block instead
 	m.Handle(networkRPCPrefix+"get-block", needConfig(h.getBlockRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot-info", needConfig(h.getSnapshotInfoRPC))
@@ -149,11 +296,19 @@ func (h *Handler) init() {
 		}
 	}))
 
-	m.Handle("/create-access-token", jsonHandler(h.createAccessToken))
-	m.Handle("/list-access-tokens", jsonHandler(h.listAccessTokens))
-	m.Handle("/delete-access-token", jsonHandler(h.deleteAccessToken))
-	m.Handle("/configure", jsonHandler(h.configure))
-	m.Handle("/info", jsonHandler(h.info))
+	api("/create-access-token", h.createAccessToken, true)
+	api("/list-access-tokens", h.listAccessTokens, true)
+	api("/delete-access-token", h.deleteAccessToken, true)
+	api("/set-peer-rate-limit", h.setPeerRateLimit, true)
+	api("/get-log-level", h.getLogLevel, true)
+	api("/set-log-level", h.setLogLevel, true)
+	api("/network-status", h.networkStatus, true)
+	api("/update-access-token", h.updateAccessToken, true)
+	api("/configure", h.configure, true)
+	api("/info", h.info, true)
+	m.Handle("/openapi.json", jsonHandler(h.openAPI))
+	m.Handle("/error-codes.json", jsonHandler(errorCatalog))
+	m.Handle("/asset-definition-schema.json", jsonHandler(asset.DefinitionSchema))
 
 	m.Handle("/debug/vars", http.HandlerFunc(expvarHandler))
 	m.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
@@ -168,11 +323,22 @@ func (h *Handler) init() {
 		m.ServeHTTP(w, req)
 	})
 
-	var handler = (&apiAuthn{
+	var blockchainID string
+	if h.Config != nil {
+		blockchainID = h.Config.BlockchainID.String()
+	}
+	h.peers = &peerTracker{
+		tokens: h.AccessTokens,
+		peers:  make(map[string]*peer),
+	}
+	h.authn = &apiAuthn{
 		tokens:   h.AccessTokens,
+		verifier: &rpc.Verifier{BlockchainID: blockchainID},
 		tokenMap: make(map[string]tokenResult),
 		alt:      h.AltAuth,
-	}).handler(latencyHandler)
+		public:   h.PublicExplorer,
+	}
+	var handler = h.authn.handler(h.peers.handler(latencyHandler))
 	handler = maxBytes(handler)
 	handler = webAssetsHandler(handler)
 	handler = healthHandler(handler)
@@ -209,6 +375,10 @@ type requestQuery struct {
 	// should be included. It has no relationship to time.
 	After string `json:"after"`
 
+	// Search is used by /list-transactions for full-text search
+	// over reference data.
+	Search string `json:"search,omitempty"`
+
 	// These two are used for time-range queries like /list-transactions
 	StartTimeMS uint64 `json:"start_time,omitempty"`
 	EndTimeMS   uint64 `json:"end_time,omitempty"`
@@ -223,6 +393,31 @@ type requestQuery struct {
 
 	// Aliases is used to filter results from /mockshm/list-keys
 	Aliases []string `json:"aliases,omitempty"`
+
+	// Status is used to filter results from /list-approvals
+	// /list-htlcs, /list-scheduled-payments,
+	// /list-submission-failures, and /list-signing-requests. For
+	// approvals, value must be "pending", "approved", or
+	// "rejected"; for HTLCs, "pending", "locked", "claimed", or
+	// "refunded"; for scheduled payments, "active", "done",
+	// "failed", or "canceled"; for submission failures, "failed",
+	// "resolved", or "dismissed"; for signing requests, "pending",
+	// "signed", "declined", or "expired".
+	Status string `json:"status,omitempty"`
+
+	// AccountID and AssetID are used to filter results
+	// from /list-balance-snapshots. AccountID also selects
+	// the account for /list-account-derived-keys.
+	AccountID string `json:"account_id,omitempty"`
+	AssetID   string `json:"asset_id,omitempty"`
+
+	// RollUpAccountID restricts /list-balances,
+	// /list-transactions, and /list-unspent-outputs to an
+	// account and its descendants, as set by /set-account-parent.
+	RollUpAccountID string `json:"roll_up_account_id,omitempty"`
+
+	// ControlProgram is used by /explorer/list-program-history.
+	ControlProgram json.HexBytes `json:"control_program,omitempty"`
 }
 
 // Used as a response object for api queries
@@ -277,7 +472,7 @@ func (h *Handler) leaderSignHandler(f func(context.Context, *bc.Block) ([]byte,
 			return nil, errNotFound // TODO(kr): is this really the right error here?
 		}
 		if leader.IsLeading() {
-			return f(ctx, b)
+			return f(blocksigner.NewContext(ctx, accessTokenID(ctx)), b)
 		}
 		var resp []byte
 		err := h.forwardToLeader(ctx, "/rpc/signer/sign-block", b, &resp)
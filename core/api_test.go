@@ -16,6 +16,7 @@ import (
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/protocol/bc"
@@ -35,7 +36,7 @@ func TestBuildFinal(t *testing.T) {
 	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
 	go accounts.ProcessBlocks(ctx)
 
-	acc, err := accounts.Create(ctx, []string{testutil.TestXPub.String()}, 1, "", nil, nil)
+	acc, err := accounts.Create(ctx, []string{testutil.TestXPub.String()}, 1, "", nil, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,7 +76,7 @@ func TestBuildFinal(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	var tmpl2 txbuilder.Template
+	var tmpl2 signing.Template
 	err = json.Unmarshal(tmplJSON, &tmpl2)
 	if err != nil {
 		t.Fatal(err)
@@ -87,7 +88,7 @@ func TestBuildFinal(t *testing.T) {
 	coretest.SignTxTemplate(t, ctx, tmpl, nil)
 	coretest.SignTxTemplate(t, ctx, &tmpl2, nil)
 
-	prog1 := tmpl.SigningInstructions[0].WitnessComponents[0].(*txbuilder.SignatureWitness).Program
+	prog1 := tmpl.SigningInstructions[0].WitnessComponents[0].(*signing.SignatureWitness).Program
 	insts1, err := vm.ParseProgram(prog1)
 	if err != nil {
 		t.Fatal(err)
@@ -118,7 +119,7 @@ func TestBuildFinal(t *testing.T) {
 		t.Fatalf("sigwitness program1 opcode 18 is %02x, expected %02x", insts1[18].Op, vm.OP_CHECKOUTPUT)
 	}
 
-	prog2 := tmpl2.SigningInstructions[0].WitnessComponents[0].(*txbuilder.SignatureWitness).Program
+	prog2 := tmpl2.SigningInstructions[0].WitnessComponents[0].(*signing.SignatureWitness).Program
 	insts2, err := vm.ParseProgram(prog2)
 	if err != nil {
 		t.Fatal(err)
@@ -145,7 +146,7 @@ func TestAccountTransfer(t *testing.T) {
 	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
 	go accounts.ProcessBlocks(ctx)
 
-	acc, err := accounts.Create(ctx, []string{testutil.TestXPub.String()}, 1, "", nil, nil)
+	acc, err := accounts.Create(ctx, []string{testutil.TestXPub.String()}, 1, "", nil, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -374,12 +375,12 @@ func inspectTemplate(t *testing.T, inp map[string]interface{}, expectedReceiverA
 	return inp
 }
 
-func toTxTemplate(ctx context.Context, inp map[string]interface{}) (*txbuilder.Template, error) {
+func toTxTemplate(ctx context.Context, inp map[string]interface{}) (*signing.Template, error) {
 	jsonInp, err := json.Marshal(inp)
 	if err != nil {
 		return nil, err
 	}
-	tpl := new(txbuilder.Template)
+	tpl := new(signing.Template)
 	err = json.Unmarshal(jsonInp, tpl)
 	return tpl, err
 }
@@ -0,0 +1,314 @@
+// Package approval implements dual control over large transfers.
+//
+// An operator sets a threshold for an asset. A transaction that
+// spends more than the threshold of that asset from any one
+// account waits for approval: Chain Core will neither sign it
+// with its HSM nor submit it until an operator using a different
+// access token than the one that requested it approves it.
+//
+// Each tenant has its own thresholds. Those of the default tenant
+// apply to every tenant's transactions. A tenant may add or lower
+// its own thresholds, but only the default tenant may raise or
+// remove them, so that the tokens whose transactions need
+// approval can't lift the requirement themselves.
+package approval
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	"chain/core/account"
+	"chain/core/tenant"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+const defaultLimit = 100
+
+var (
+	// ErrPending is returned for transactions that
+	// have not been approved yet.
+	ErrPending = errors.New("transaction awaits approval")
+
+	// ErrRejected is returned for transactions that
+	// an approver has rejected.
+	ErrRejected = errors.New("transaction rejected by approver")
+
+	// ErrSameOperator is returned when the access token that
+	// requested approval of a transaction tries to decide on it,
+	// or when no access token is used to decide.
+	ErrSameOperator = errors.New("approval requires a second access token")
+
+	// ErrDecided is returned when deciding on a transaction
+	// that has already been approved or rejected.
+	ErrDecided = errors.New("transaction already decided")
+
+	ErrBadThreshold = errors.New("invalid approval threshold")
+
+	// ErrLoosenThreshold is returned when a tenant other than
+	// the default tenant tries to raise or remove a threshold,
+	// or to set one for another tenant.
+	ErrLoosenThreshold = errors.New("threshold may only be loosened by the default tenant")
+)
+
+// Controller keeps track of the thresholds, and of the
+// transactions that exceed them.
+type Controller struct {
+	DB pg.DB
+}
+
+// Threshold is the largest amount of an asset that a transaction
+// may spend from one account without approval.
+type Threshold struct {
+	AssetID bc.AssetID `json:"asset_id"`
+	Amount  uint64     `json:"amount"`
+	Tenant  string     `json:"tenant,omitempty"`
+}
+
+// Approval records the decision on a transaction exceeding
+// one or more thresholds.
+type Approval struct {
+	TxID        bc.Hash         `json:"transaction_id"`
+	Status      string          `json:"status"`
+	Spends      []account.Spend `json:"spends"`
+	RequestedBy string          `json:"requested_by"`
+	RequestedAt time.Time       `json:"requested_at"`
+	DecidedBy   *string         `json:"decided_by"`
+	DecidedAt   *time.Time      `json:"decided_at"`
+}
+
+// SetThreshold sets the threshold for assetID of tenant tenantID,
+// or of the default tenant, applying to all tenants, if tenantID is
+// empty. A nil amount removes it. Unless ctx acts for the default
+// tenant, tenantID must be the tenant that ctx acts for, and the
+// threshold may only be added or lowered; otherwise SetThreshold
+// returns ErrLoosenThreshold.
+func (c *Controller) SetThreshold(ctx context.Context, tenantID string, assetID bc.AssetID, amount *uint64) error {
+	if assetID == (bc.AssetID{}) {
+		return errors.WithDetail(ErrBadThreshold, "asset_id is required")
+	}
+	restricted := tenant.FromContext(ctx) != tenant.Default
+	if restricted && tenantID != tenant.FromContext(ctx) {
+		return errors.WithDetailf(ErrLoosenThreshold, "tenant %q", tenantID)
+	}
+	if amount == nil {
+		if restricted {
+			return errors.WithDetailf(ErrLoosenThreshold, "removing threshold for asset %s", assetID)
+		}
+		const q = `DELETE FROM approval_thresholds WHERE tenant=$1 AND asset_id=$2`
+		_, err := c.DB.Exec(ctx, q, tenantID, assetID)
+		return errors.Wrap(err)
+	}
+	if *amount > math.MaxInt64 {
+		return errors.WithDetailf(ErrBadThreshold, "amount %d exceeds 2^63", *amount)
+	}
+	const q = `
+		INSERT INTO approval_thresholds (tenant, asset_id, amount) VALUES ($1, $2, $3)
+		ON CONFLICT (tenant, asset_id) DO UPDATE SET amount=$3
+		WHERE NOT $4 OR approval_thresholds.amount >= $3
+	`
+	res, err := c.DB.Exec(ctx, q, tenantID, assetID, int64(*amount), restricted)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		return errors.WithDetailf(ErrLoosenThreshold, "raising threshold for asset %s", assetID)
+	}
+	return nil
+}
+
+// Thresholds returns the thresholds visible to the tenant that
+// ctx acts for, ordered by asset ID: the default tenant's, which
+// apply to every tenant, and the tenant's own. The default
+// tenant sees every tenant's thresholds.
+func (c *Controller) Thresholds(ctx context.Context) ([]*Threshold, error) {
+	const q = `
+		SELECT asset_id, amount, tenant FROM approval_thresholds
+		WHERE $1='' OR tenant=$1 OR tenant=''
+		ORDER BY asset_id, tenant
+	`
+	var thresholds []*Threshold
+	err := pg.ForQueryRows(ctx, c.DB, q, tenant.FromContext(ctx), func(assetID bc.AssetID, amount uint64, tenantID string) {
+		thresholds = append(thresholds, &Threshold{AssetID: assetID, Amount: amount, Tenant: tenantID})
+	})
+	return thresholds, errors.Wrap(err)
+}
+
+// Require returns nil if the transaction txID, with the given
+// spends, may proceed: either no spend exceeds a threshold, or
+// an approver has approved it. Otherwise it returns ErrPending or
+// ErrRejected. The first call for a pending transaction records
+// the request for approval on behalf of access token requester.
+func (c *Controller) Require(ctx context.Context, txID bc.Hash, spends []account.Spend, requester string) error {
+	over, err := c.exceeding(ctx, spends)
+	if err != nil || len(over) == 0 {
+		return err
+	}
+	spendsJSON, err := json.Marshal(over)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	const insertQ = `
+		INSERT INTO approvals (tx_hash, status, spends, requested_by, tenant)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+	_, err = c.DB.Exec(ctx, insertQ, txID.String(), StatusPending, spendsJSON, requester, tenant.FromContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "recording approval request")
+	}
+
+	var status string
+	const selectQ = `SELECT status FROM approvals WHERE tx_hash=$1`
+	err = c.DB.QueryRow(ctx, selectQ, txID.String()).Scan(&status)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	switch status {
+	case StatusApproved:
+		return nil
+	case StatusRejected:
+		return errors.WithDetailf(ErrRejected, "transaction %s", txID)
+	}
+	return errors.WithDetailf(ErrPending, "transaction %s", txID)
+}
+
+// exceeding returns the spends that exceed their asset's threshold:
+// the lower of the default tenant's and that of the tenant that
+// ctx acts for.
+func (c *Controller) exceeding(ctx context.Context, spends []account.Spend) ([]account.Spend, error) {
+	if len(spends) == 0 {
+		return nil, nil
+	}
+	const q = `
+		SELECT asset_id, min(amount) FROM approval_thresholds
+		WHERE tenant=$1 OR tenant=''
+		GROUP BY asset_id
+	`
+	max := make(map[bc.AssetID]uint64)
+	err := pg.ForQueryRows(ctx, c.DB, q, tenant.FromContext(ctx), func(assetID bc.AssetID, amount uint64) {
+		max[assetID] = amount
+	})
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var over []account.Spend
+	for _, s := range spends {
+		if m, ok := max[s.AssetID]; ok && s.Amount > m {
+			over = append(over, s)
+		}
+	}
+	return over, nil
+}
+
+// Decide approves or rejects the pending transaction txID
+// on behalf of access token approver.
+func (c *Controller) Decide(ctx context.Context, txID bc.Hash, approver string, approve bool) (*Approval, error) {
+	if approver == "" {
+		return nil, errors.WithDetail(ErrSameOperator, "decisions must be authenticated with an access token")
+	}
+	a, err := c.Find(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if a.RequestedBy == approver {
+		return nil, errors.WithDetailf(ErrSameOperator, "access token %s requested approval", approver)
+	}
+
+	status := StatusRejected
+	if approve {
+		status = StatusApproved
+	}
+	const q = `
+		UPDATE approvals SET status=$2, decided_by=$3, decided_at=now()
+		WHERE tx_hash=$1 AND status=$4
+		RETURNING decided_at
+	`
+	var decidedAt time.Time
+	err = c.DB.QueryRow(ctx, q, txID.String(), status, approver, StatusPending).Scan(&decidedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrDecided, "transaction %s", txID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	a.Status = status
+	a.DecidedBy = &approver
+	a.DecidedAt = &decidedAt
+	return a, nil
+}
+
+// Find returns the approval record of transaction txID.
+func (c *Controller) Find(ctx context.Context, txID bc.Hash) (*Approval, error) {
+	approvals, err := c.list(ctx, `tx_hash=$2`, txID.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(approvals) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %s", txID)
+	}
+	return approvals[0], nil
+}
+
+// List returns approval records with the given status,
+// or with any status if status is empty, in pages
+// ordered by transaction ID.
+func (c *Controller) List(ctx context.Context, status, after string, limit int) ([]*Approval, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	const where = `($2='' OR status=$2) AND ($3='' OR tx_hash<$3) ORDER BY tx_hash DESC LIMIT $4`
+	approvals, err := c.list(ctx, where, status, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(approvals) > 0 {
+		after = approvals[len(approvals)-1].TxID.String()
+	}
+	return approvals, after, nil
+}
+
+// list returns the approvals visible to the tenant that ctx acts
+// for that match where. The tenant is parameter $1 of the query,
+// and args are parameters $2 and up.
+func (c *Controller) list(ctx context.Context, where string, args ...interface{}) ([]*Approval, error) {
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+	q := `
+		SELECT tx_hash, status, spends, requested_by, created_at, decided_by, decided_at
+		FROM approvals
+		WHERE ($1='' OR tenant=$1) AND ` + where
+	var approvals []*Approval
+	args = append(args, func(txID bc.Hash, status string, spends []byte, requestedBy string, requestedAt time.Time, decidedBy *string, decidedAt *time.Time) error {
+		a := &Approval{
+			TxID:        txID,
+			Status:      status,
+			RequestedBy: requestedBy,
+			RequestedAt: requestedAt,
+			DecidedBy:   decidedBy,
+			DecidedAt:   decidedAt,
+		}
+		err := json.Unmarshal(spends, &a.Spends)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		approvals = append(approvals, a)
+		return nil
+	})
+	err := pg.ForQueryRows(ctx, c.DB, q, args...)
+	return approvals, errors.Wrap(err)
+}
@@ -0,0 +1,151 @@
+package approval
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/account"
+	"chain/core/tenant"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestRequireApproval(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := &Controller{DB: db}
+
+	assetID := bc.AssetID{1}
+	threshold := uint64(100)
+	err := c.SetThreshold(ctx, "", assetID, &threshold)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	small := []account.Spend{{AccountID: "acc1", AssetID: assetID, Amount: 100}}
+	err = c.Require(ctx, bc.Hash{1}, small, "alice")
+	if err != nil {
+		t.Fatalf("Require(spend at threshold) = %v, want nil", err)
+	}
+
+	large := []account.Spend{{AccountID: "acc1", AssetID: assetID, Amount: 101}}
+	txID := bc.Hash{2}
+	err = c.Require(ctx, txID, large, "alice")
+	if errors.Root(err) != ErrPending {
+		t.Fatalf("Require(spend over threshold) = %v, want %v", err, ErrPending)
+	}
+
+	_, err = c.Decide(ctx, txID, "alice", true)
+	if errors.Root(err) != ErrSameOperator {
+		t.Fatalf("Decide(requester) = %v, want %v", err, ErrSameOperator)
+	}
+	_, err = c.Decide(ctx, txID, "", true)
+	if errors.Root(err) != ErrSameOperator {
+		t.Fatalf("Decide(no token) = %v, want %v", err, ErrSameOperator)
+	}
+
+	a, err := c.Decide(ctx, txID, "bob", true)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if a.Status != StatusApproved || a.RequestedBy != "alice" || a.DecidedBy == nil || *a.DecidedBy != "bob" {
+		t.Errorf("Decide(bob) = %+v, want approved by bob", a)
+	}
+
+	err = c.Require(ctx, txID, large, "carol")
+	if err != nil {
+		t.Errorf("Require(approved) = %v, want nil", err)
+	}
+
+	_, err = c.Decide(ctx, txID, "carol", false)
+	if errors.Root(err) != ErrDecided {
+		t.Errorf("Decide(decided) = %v, want %v", err, ErrDecided)
+	}
+
+	rejected := bc.Hash{3}
+	c.Require(ctx, rejected, large, "alice")
+	_, err = c.Decide(ctx, rejected, "bob", false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.Require(ctx, rejected, large, "alice")
+	if errors.Root(err) != ErrRejected {
+		t.Errorf("Require(rejected) = %v, want %v", err, ErrRejected)
+	}
+
+	approved, _, err := c.List(ctx, StatusApproved, "", 0)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(approved) != 1 || approved[0].TxID != txID {
+		t.Errorf("List(approved) = %+v, want %x", approved, txID[:])
+	}
+}
+
+func TestThresholdTenants(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := &Controller{DB: db}
+	acme := tenant.NewContext(ctx, "acme")
+
+	assetID := bc.AssetID{1}
+	amount := func(n uint64) *uint64 { return &n }
+
+	// A tenant may add and lower its own threshold,
+	// but not raise or remove it.
+	err := c.SetThreshold(acme, "acme", assetID, amount(100))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.SetThreshold(acme, "acme", assetID, amount(50))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.SetThreshold(acme, "acme", assetID, amount(80))
+	if errors.Root(err) != ErrLoosenThreshold {
+		t.Errorf("SetThreshold(raise) = %v, want %v", err, ErrLoosenThreshold)
+	}
+	err = c.SetThreshold(acme, "acme", assetID, nil)
+	if errors.Root(err) != ErrLoosenThreshold {
+		t.Errorf("SetThreshold(remove) = %v, want %v", err, ErrLoosenThreshold)
+	}
+	err = c.SetThreshold(acme, "", assetID, amount(10))
+	if errors.Root(err) != ErrLoosenThreshold {
+		t.Errorf("SetThreshold(default tenant's) = %v, want %v", err, ErrLoosenThreshold)
+	}
+
+	// The tenant's threshold doesn't apply to other tenants.
+	spends := []account.Spend{{AccountID: "acc1", AssetID: assetID, Amount: 60}}
+	err = c.Require(ctx, bc.Hash{1}, spends, "alice")
+	if err != nil {
+		t.Errorf("Require(default tenant) = %v, want nil", err)
+	}
+	err = c.Require(acme, bc.Hash{2}, spends, "alice")
+	if errors.Root(err) != ErrPending {
+		t.Errorf("Require(acme) = %v, want %v", err, ErrPending)
+	}
+
+	// The default tenant's thresholds apply to every tenant,
+	// and it may raise or remove any tenant's.
+	err = c.SetThreshold(ctx, "", assetID, amount(20))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.SetThreshold(ctx, "acme", assetID, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	thresholds, err := c.Thresholds(acme)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(thresholds) != 1 || thresholds[0].Tenant != "" || thresholds[0].Amount != 20 {
+		t.Errorf("Thresholds(acme) = %+v, want the default tenant's 20", thresholds)
+	}
+	err = c.Require(acme, bc.Hash{3}, spends, "alice")
+	if errors.Root(err) != ErrPending {
+		t.Errorf("Require(acme, default threshold) = %v, want %v", err, ErrPending)
+	}
+}
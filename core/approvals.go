@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/approval"
+	"chain/core/tenant"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// requireApproval returns nil if tx may be signed and submitted,
+// and approval.ErrPending or approval.ErrRejected if it needs,
+// and hasn't got, a second operator's approval.
+func (h *Handler) requireApproval(ctx context.Context, txdata *bc.TxData) error {
+	if h.Approvals == nil || txdata == nil {
+		return nil
+	}
+	tx := bc.NewTx(*txdata)
+	spends, err := h.Accounts.Spends(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return h.Approvals.Require(ctx, tx.Hash, spends, accessTokenID(ctx))
+}
+
+// POST /set-approval-threshold
+//
+// A null amount removes the threshold. The threshold is the
+// requesting tenant's, unless the default tenant names another
+// tenant. Only the default tenant may raise or remove one.
+func (h *Handler) setApprovalThreshold(ctx context.Context, in struct {
+	AssetID bc.AssetID `json:"asset_id"`
+	Amount  *uint64
+	Tenant  *string
+}) error {
+	tenantID := tenant.FromContext(ctx)
+	if in.Tenant != nil {
+		tenantID = *in.Tenant
+	}
+	return h.Approvals.SetThreshold(ctx, tenantID, in.AssetID, in.Amount)
+}
+
+// POST /list-approval-thresholds
+func (h *Handler) listApprovalThresholds(ctx context.Context) ([]*approval.Threshold, error) {
+	thresholds, err := h.Approvals.Thresholds(ctx)
+	if thresholds == nil {
+		thresholds = []*approval.Threshold{}
+	}
+	return thresholds, err
+}
+
+// POST /list-approvals
+func (h *Handler) listApprovals(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	approvals, after, err := h.Approvals.List(ctx, query.Status, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(approvals),
+		LastPage: len(approvals) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /approve-transaction
+func (h *Handler) approveTx(ctx context.Context, in struct {
+	TxID bc.Hash `json:"transaction_id"`
+}) (*approval.Approval, error) {
+	return h.Approvals.Decide(ctx, in.TxID, accessTokenID(ctx), true)
+}
+
+// POST /reject-transaction
+func (h *Handler) rejectTx(ctx context.Context, in struct {
+	TxID bc.Hash `json:"transaction_id"`
+}) (*approval.Approval, error) {
+	return h.Approvals.Decide(ctx, in.TxID, accessTokenID(ctx), false)
+}
@@ -15,6 +15,8 @@ import (
 
 	"chain/core/pin"
 	"chain/core/signers"
+	"chain/core/tenant"
+	"chain/core/txbuilder"
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
@@ -27,7 +29,13 @@ import (
 
 const maxAssetCache = 1000
 
-var ErrDuplicateAlias = errors.New("duplicate asset alias")
+var (
+	ErrDuplicateAlias = errors.New("duplicate asset alias")
+
+	// ErrBadCosigner is returned by DefineCosigned
+	// for a cosigner without a URL or valid key.
+	ErrBadCosigner = errors.New("invalid asset cosigner")
+)
 
 func NewRegistry(db pg.DB, chain *protocol.Chain, pinStore *pin.Store) *Registry {
 	return &Registry{
@@ -67,12 +75,42 @@ type Asset struct {
 	IssuanceProgram  []byte
 	InitialBlockHash bc.Hash
 	Signer           *signers.Signer
+	Cosigner         *Cosigner
 	Tags             map[string]interface{}
 	sortID           string
+	tenant           string
+}
+
+// A Cosigner is an external service, such as a compliance service,
+// whose signature every issuance of an asset requires, in addition
+// to the asset's signers. Its key is built into the asset's issuance
+// program, so issuing without its approval is impossible.
+type Cosigner struct {
+	URL    string
+	PubKey ed25519.PublicKey
 }
 
-// Define defines a new Asset.
+// Define defines a new Asset belonging to the tenant
+// that ctx acts for.
 func (reg *Registry) Define(ctx context.Context, xpubs []string, quorum int, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken *string) (*Asset, error) {
+	return reg.define(ctx, xpubs, quorum, nil, definition, alias, tags, clientToken)
+}
+
+// DefineCosigned is like Define, but if cosigner is non-nil,
+// issuing the asset also requires a signature from cosigner.
+func (reg *Registry) DefineCosigned(ctx context.Context, xpubs []string, quorum int, cosigner *Cosigner, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken *string) (*Asset, error) {
+	if cosigner != nil && (cosigner.URL == "" || len(cosigner.PubKey) != ed25519.PublicKeySize) {
+		return nil, errors.WithDetail(ErrBadCosigner, "a cosigner needs a URL and a 32-byte public key")
+	}
+	return reg.define(ctx, xpubs, quorum, cosigner, definition, alias, tags, clientToken)
+}
+
+func (reg *Registry) define(ctx context.Context, xpubs []string, quorum int, cosigner *Cosigner, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken *string) (*Asset, error) {
+	err := ValidateDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+
 	assetSigner, err := signers.Create(ctx, reg.db, "asset", xpubs, quorum, clientToken)
 	if err != nil {
 		return nil, err
@@ -86,7 +124,7 @@ func (reg *Registry) Define(ctx context.Context, xpubs []string, quorum int, def
 	path := signers.Path(assetSigner, signers.AssetKeySpace)
 	derivedXPubs := chainkd.DeriveXPubs(assetSigner.XPubs, path)
 	derivedPKs := chainkd.XPubKeys(derivedXPubs)
-	issuanceProgram, err := programWithDefinition(derivedPKs, assetSigner.Quorum, serializedDef)
+	issuanceProgram, err := programWithDefinition(derivedPKs, assetSigner.Quorum, cosigner, serializedDef)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +135,9 @@ func (reg *Registry) Define(ctx context.Context, xpubs []string, quorum int, def
 		InitialBlockHash: reg.initialBlockHash,
 		AssetID:          bc.ComputeAssetID(issuanceProgram, reg.initialBlockHash, 1),
 		Signer:           assetSigner,
+		Cosigner:         cosigner,
 		Tags:             tags,
+		tenant:           tenant.FromContext(ctx),
 	}
 	if alias != "" {
 		asset.Alias = &alias
@@ -121,13 +161,14 @@ func (reg *Registry) Define(ctx context.Context, xpubs []string, quorum int, def
 	return asset, nil
 }
 
-// findByID retrieves an Asset record along with its signer, given an assetID.
-func (reg *Registry) findByID(ctx context.Context, id bc.AssetID) (*Asset, error) {
+// FindByID retrieves an Asset record along with its signer, given an assetID.
+// Assets of tenants other than the one ctx acts for are not found.
+func (reg *Registry) FindByID(ctx context.Context, id bc.AssetID) (*Asset, error) {
 	reg.cacheMu.Lock()
 	cached, ok := reg.cache.Get(id)
 	reg.cacheMu.Unlock()
 	if ok {
-		return cached.(*Asset), nil
+		return visible(ctx, cached.(*Asset))
 	}
 
 	untypedAsset, err := reg.idGroup.Do(id.String(), func() (interface{}, error) {
@@ -141,7 +182,7 @@ func (reg *Registry) findByID(ctx context.Context, id bc.AssetID) (*Asset, error
 	reg.cacheMu.Lock()
 	reg.cache.Add(id, asset)
 	reg.cacheMu.Unlock()
-	return asset, nil
+	return visible(ctx, asset)
 }
 
 // FindByAlias retrieves an Asset record along with its signer,
@@ -151,7 +192,7 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 	cachedID, ok := reg.aliasCache.Get(alias)
 	reg.cacheMu.Unlock()
 	if ok {
-		return reg.findByID(ctx, cachedID.(bc.AssetID))
+		return reg.FindByID(ctx, cachedID.(bc.AssetID))
 	}
 
 	untypedAsset, err := reg.aliasGroup.Do(alias, func() (interface{}, error) {
@@ -167,8 +208,16 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 	reg.aliasCache.Add(alias, a.AssetID)
 	reg.cache.Add(a.AssetID, a)
 	reg.cacheMu.Unlock()
-	return a, nil
+	return visible(ctx, a)
+}
 
+// visible returns a if the tenant that ctx acts for can see it,
+// and an error as if it didn't exist otherwise.
+func visible(ctx context.Context, a *Asset) (*Asset, error) {
+	if !tenant.CanSee(ctx, a.tenant) {
+		return nil, errors.Wrap(pg.ErrUserInputNotFound)
+	}
+	return a, nil
 }
 
 // insertAsset adds the asset to the database. If the asset has a client token,
@@ -177,8 +226,9 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken *string) (*Asset, error) {
 	const q = `
 		INSERT INTO assets
-			(id, alias, signer_id, initial_block_hash, issuance_program, definition, client_token)
-		VALUES($1, $2, $3, $4, $5, $6, $7)
+			(id, alias, signer_id, initial_block_hash, issuance_program, definition, client_token, tenant,
+			cosigner_url, cosigner_pubkey)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING sort_id
   `
@@ -191,12 +241,17 @@ func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken
 	if asset.Signer != nil {
 		signerID = sql.NullString{Valid: true, String: asset.Signer.ID}
 	}
+	var cosignerURL, cosignerPubKey interface{}
+	if asset.Cosigner != nil {
+		cosignerURL, cosignerPubKey = asset.Cosigner.URL, []byte(asset.Cosigner.PubKey)
+	}
 
 	err = reg.db.QueryRow(
 		ctx, q,
 		asset.AssetID, asset.Alias, signerID,
 		asset.InitialBlockHash, asset.IssuanceProgram,
-		defParams, clientToken,
+		defParams, clientToken, asset.tenant,
+		cosignerURL, cosignerPubKey,
 	).Scan(&asset.sortID)
 
 	if pg.IsUniqueViolation(err) {
@@ -242,10 +297,10 @@ func assetByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Ass
 func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{}) (*Asset, error) {
 	const baseQ = `
 		SELECT assets.id, assets.alias, assets.issuance_program, assets.definition,
-			assets.initial_block_hash, assets.sort_id,
+			assets.initial_block_hash, assets.sort_id, assets.tenant,
 			signers.id, COALESCE(signers.type, ''), COALESCE(signers.xpubs, '{}'),
 			COALESCE(signers.quorum, 0), COALESCE(signers.key_index, 0),
-			asset_tags.tags
+			asset_tags.tags, assets.cosigner_url, assets.cosigner_pubkey
 		FROM assets
 		LEFT JOIN signers ON signers.id=assets.signer_id
 		LEFT JOIN asset_tags ON asset_tags.asset_id=assets.id
@@ -253,15 +308,17 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		LIMIT 1
 	`
 	var (
-		a          Asset
-		alias      sql.NullString
-		definition []byte
-		signerID   sql.NullString
-		signerType string
-		quorum     int
-		keyIndex   uint64
-		xpubs      []string
-		tags       []byte
+		a              Asset
+		alias          sql.NullString
+		definition     []byte
+		signerID       sql.NullString
+		signerType     string
+		quorum         int
+		keyIndex       uint64
+		xpubs          []string
+		tags           []byte
+		cosignerURL    sql.NullString
+		cosignerPubKey []byte
 	)
 	err := db.QueryRow(ctx, fmt.Sprintf(baseQ, pred), args...).Scan(
 		&a.AssetID,
@@ -270,12 +327,15 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		&definition,
 		&a.InitialBlockHash,
 		&a.sortID,
+		&a.tenant,
 		&signerID,
 		&signerType,
 		(*pq.StringArray)(&xpubs),
 		&quorum,
 		&keyIndex,
 		&tags,
+		&cosignerURL,
+		&cosignerPubKey,
 	)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
@@ -290,6 +350,10 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		}
 	}
 
+	if cosignerURL.Valid {
+		a.Cosigner = &Cosigner{URL: cosignerURL.String, PubKey: ed25519.PublicKey(cosignerPubKey)}
+	}
+
 	if len(definition) > 0 {
 		err := json.Unmarshal(definition, &a.Definition)
 		if err != nil {
@@ -321,11 +385,14 @@ func serializeAssetDef(def map[string]interface{}) ([]byte, error) {
 	return json.MarshalIndent(def, "", "  ")
 }
 
-func programWithDefinition(pubkeys []ed25519.PublicKey, nrequired int, definition []byte) ([]byte, error) {
+func programWithDefinition(pubkeys []ed25519.PublicKey, nrequired int, cosigner *Cosigner, definition []byte) ([]byte, error) {
 	issuanceProg, err := vmutil.P2SPMultiSigProgram(pubkeys, nrequired)
 	if err != nil {
 		return nil, err
 	}
+	if cosigner != nil {
+		issuanceProg = txbuilder.CosignProgram(cosigner.PubKey, issuanceProg)
+	}
 	builder := vmutil.NewBuilder()
 	builder.AddData(definition).AddOp(vm.OP_DROP)
 	builder.AddRawBytes(issuanceProg)
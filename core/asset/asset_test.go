@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/prottest"
 	"chain/testutil"
 )
@@ -35,6 +36,38 @@ func TestDefineAsset(t *testing.T) {
 	}
 }
 
+func TestDefineCosigned(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	keys := []string{testutil.TestXPub.String()}
+	cosigner := &Cosigner{URL: "https://compliance.example.com/cosign", PubKey: testutil.TestPub}
+	asset, err := r.DefineCosigned(ctx, keys, 1, cosigner, nil, "", nil, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	plain, err := r.Define(ctx, keys, 1, nil, "", nil, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if reflect.DeepEqual(asset.IssuanceProgram, plain.IssuanceProgram) {
+		t.Error("cosigned asset has the same issuance program as an asset without a cosigner")
+	}
+
+	found, err := assetQuery(ctx, r.db, "assets.id=$1", asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(found.Cosigner, cosigner) {
+		t.Errorf("cosigner = %+v want %+v", found.Cosigner, cosigner)
+	}
+
+	_, err = r.DefineCosigned(ctx, keys, 1, &Cosigner{URL: cosigner.URL}, nil, "", nil, nil)
+	if errors.Root(err) != ErrBadCosigner {
+		t.Errorf("DefineCosigned(no key) err = %v want %v", err, ErrBadCosigner)
+	}
+}
+
 func TestDefineAssetIdempotency(t *testing.T) {
 	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
 	ctx := context.Background()
@@ -63,7 +96,7 @@ func TestFindAssetByID(t *testing.T) {
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	found, err := r.findByID(ctx, asset.AssetID)
+	found, err := r.FindByID(ctx, asset.AssetID)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/lib/pq"
 
 	"chain/core/signers"
+	"chain/core/tenant"
 	"chain/database/pg"
 	"chain/encoding/json"
 	"chain/errors"
@@ -37,6 +38,12 @@ func (reg *Registry) indexAnnotatedAsset(ctx context.Context, a *Asset) error {
 		"tags":             a.Tags,
 		"is_local":         "no",
 	}
+	if d, ok := Decimals(a.Definition); ok {
+		m["decimals"] = d
+	}
+	if s := Symbol(a.Definition); s != "" {
+		m["symbol"] = s
+	}
 	if a.Signer != nil {
 		var keys []map[string]interface{}
 		path := signers.Path(a.Signer, signers.AssetKeySpace)
@@ -68,6 +75,7 @@ func (reg *Registry) indexAnnotatedAsset(ctx context.Context, a *Asset) error {
 			m["quorum"] = quorum
 		}
 	}
+	ctx = tenant.NewContext(ctx, a.tenant)
 	return reg.indexer.SaveAnnotatedAsset(ctx, a.AssetID, m, a.sortID)
 }
 
@@ -141,7 +149,7 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 	// assets. We need to index them as annotated assets too.
 	for _, assetID := range newAssetIDs {
 		// TODO(jackson): Batch the asset lookups.
-		a, err := reg.findByID(ctx, assetID)
+		a, err := reg.FindByID(ctx, assetID)
 		if err != nil {
 			return errors.Wrap(err, "looking up new asset")
 		}
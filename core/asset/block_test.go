@@ -31,7 +31,7 @@ func TestIndexNonLocalAssets(t *testing.T) {
 	}
 
 	// Create the issuance program of a remote asset.
-	issuanceProgram, err := programWithDefinition([]ed25519.PublicKey{testutil.TestPub}, 1, []byte(def))
+	issuanceProgram, err := programWithDefinition([]ed25519.PublicKey{testutil.TestPub}, 1, nil, []byte(def))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -83,7 +83,7 @@ func TestIndexNonLocalAssets(t *testing.T) {
 	}
 
 	// Ensure that the asset was saved to the `assets` table.
-	got, err := r.findByID(ctx, remoteAssetID)
+	got, err := r.FindByID(ctx, remoteAssetID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -8,6 +8,7 @@ import (
 
 	"chain/core/signers"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/database/pg"
 	chainjson "chain/encoding/json"
 	"chain/errors"
@@ -39,7 +40,7 @@ func (a *issueAction) Build(ctx context.Context, maxTime time.Time, builder *txb
 		return txbuilder.MissingFieldsError("asset_id")
 	}
 
-	asset, err := a.assets.findByID(ctx, a.AssetID)
+	asset, err := a.assets.FindByID(ctx, a.AssetID)
 	if errors.Root(err) == pg.ErrUserInputNotFound {
 		err = errors.WithDetailf(err, "missing asset with ID %q", a.AssetID)
 	}
@@ -54,10 +55,16 @@ func (a *issueAction) Build(ctx context.Context, maxTime time.Time, builder *txb
 	}
 	txin := bc.NewIssuanceInput(nonce[:], a.Amount, a.ReferenceData, asset.InitialBlockHash, asset.IssuanceProgram, nil)
 
-	tplIn := &txbuilder.SigningInstruction{AssetAmount: a.AssetAmount}
+	tplIn := &signing.SigningInstruction{AssetAmount: a.AssetAmount}
 	path := signers.Path(asset.Signer, signers.AssetKeySpace)
 	keyIDs := txbuilder.KeyIDs(asset.Signer.XPubs, path)
 	tplIn.AddWitnessKeys(keyIDs, asset.Signer.Quorum)
+	if asset.Cosigner != nil {
+		tplIn.WitnessComponents = append(tplIn.WitnessComponents, &signing.CosignWitness{
+			URL:    asset.Cosigner.URL,
+			PubKey: chainjson.HexBytes(asset.Cosigner.PubKey),
+		})
+	}
 
 	builder.RestrictMinTimeMS(bc.Millis(time.Now()))
 	return builder.AddInput(txin, tplIn)
@@ -0,0 +1,163 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+
+	"chain/errors"
+)
+
+// Standard fields of asset definitions. An asset definition may
+// hold any JSON object, but, if it has these fields, Define
+// requires them to be as described by DefinitionSchema, so that
+// every client can rely on them.
+const (
+	// DefDecimals is the number of digits of an amount of the
+	// asset that come after the decimal point when it's shown
+	// in display units: an asset with 2 decimals has 100 base
+	// units in each display unit.
+	DefDecimals = "decimals"
+
+	// DefSymbol is the asset's ticker symbol, such as USD.
+	DefSymbol = "symbol"
+
+	// DefDocsURL is the http or https URL of
+	// documentation of the asset.
+	DefDocsURL = "docs_url"
+)
+
+// MaxDecimals is the most decimals an asset may have.
+const MaxDecimals = 18
+
+// ErrBadDefinition is returned by Define for an asset definition
+// whose standard fields don't match DefinitionSchema.
+var ErrBadDefinition = errors.New("invalid asset definition")
+
+var symbolRE = regexp.MustCompile(`^[A-Z0-9][A-Z0-9.\-]{0,11}$`)
+
+// definitionFields holds, for each standard field, its schema,
+// and a function reporting whether a value decoded from JSON
+// follows rule, which describes the schema in words.
+var definitionFields = map[string]struct {
+	schema map[string]interface{}
+	rule   string
+	valid  func(v interface{}) bool
+}{
+	DefDecimals: {
+		schema: map[string]interface{}{
+			"type":        "integer",
+			"minimum":     0,
+			"maximum":     MaxDecimals,
+			"description": "Digits after the decimal point of an amount in display units.",
+		},
+		rule: fmt.Sprintf("an integer from 0 to %d", MaxDecimals),
+		valid: func(v interface{}) bool {
+			_, ok := decimals(v)
+			return ok
+		},
+	},
+	DefSymbol: {
+		schema: map[string]interface{}{
+			"type":        "string",
+			"pattern":     symbolRE.String(),
+			"description": "Ticker symbol, such as USD.",
+		},
+		rule: "1 to 12 capital letters, digits, dots, and dashes, starting with a letter or digit",
+		valid: func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && symbolRE.MatchString(s)
+		},
+	},
+	DefDocsURL: {
+		schema: map[string]interface{}{
+			"type":        "string",
+			"format":      "uri",
+			"description": "http or https URL of documentation of the asset.",
+		},
+		rule: "an http or https URL",
+		valid: func(v interface{}) bool {
+			s, _ := v.(string)
+			u, err := url.Parse(s)
+			return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+		},
+	},
+}
+
+// DefinitionSchema returns the JSON schema of asset definitions,
+// describing their standard fields.
+func DefinitionSchema() map[string]interface{} {
+	props := make(map[string]interface{})
+	for name, f := range definitionFields {
+		props[name] = f.schema
+	}
+	return map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-04/schema#",
+		"title":      "Asset definition",
+		"type":       "object",
+		"properties": props,
+	}
+}
+
+// ValidateDefinition checks the standard fields
+// of def against DefinitionSchema.
+func ValidateDefinition(def map[string]interface{}) error {
+	var names []string
+	for name := range definitionFields {
+		names = append(names, name)
+	}
+	sort.Strings(names) // report the same error each time
+	for _, name := range names {
+		v, ok := def[name]
+		if !ok {
+			continue
+		}
+		if f := definitionFields[name]; !f.valid(v) {
+			return errors.WithDetailf(ErrBadDefinition, "%s must be %s", name, f.rule)
+		}
+	}
+	return nil
+}
+
+// Decimals returns the decimals declared by def,
+// and whether it declares a valid number of them.
+// Definitions of assets created elsewhere on the
+// blockchain may not have been validated.
+func Decimals(def map[string]interface{}) (int, bool) {
+	return decimals(def[DefDecimals])
+}
+
+// Symbol returns the ticker symbol declared by def,
+// or the empty string if it declares no valid symbol.
+func Symbol(def map[string]interface{}) string {
+	s, _ := def[DefSymbol].(string)
+	if !symbolRE.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// decimals returns v, a number decoded from JSON with
+// or without UseNumber, as a valid number of decimals.
+func decimals(v interface{}) (int, bool) {
+	var f float64
+	switch v := v.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, false
+		}
+		f = float64(n)
+	case float64:
+		f = v
+	default:
+		return 0, false
+	}
+	if f != math.Trunc(f) || f < 0 || f > MaxDecimals {
+		return 0, false
+	}
+	return int(f), true
+}
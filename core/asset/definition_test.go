@@ -0,0 +1,61 @@
+package asset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestValidateDefinition(t *testing.T) {
+	cases := []struct {
+		def  string
+		want error
+	}{
+		{`{}`, nil},
+		{`{"name": "dollars", "decimals": "2"}`, ErrBadDefinition},
+		{`{"decimals": 2, "symbol": "USD", "docs_url": "https://example.com/usd"}`, nil},
+		{`{"decimals": 0}`, nil},
+		{`{"decimals": 18}`, nil},
+		{`{"decimals": 19}`, ErrBadDefinition},
+		{`{"decimals": -1}`, ErrBadDefinition},
+		{`{"decimals": 2.5}`, ErrBadDefinition},
+		{`{"symbol": "usd"}`, ErrBadDefinition},
+		{`{"symbol": "BRK.B"}`, nil},
+		{`{"symbol": "TOOLONGSYMBOL"}`, ErrBadDefinition},
+		{`{"docs_url": "ftp://example.com"}`, ErrBadDefinition},
+		{`{"docs_url": "example.com"}`, ErrBadDefinition},
+	}
+	for _, c := range cases {
+		var def map[string]interface{}
+		err := json.Unmarshal([]byte(c.def), &def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ValidateDefinition(def)
+		if errors.Root(got) != c.want {
+			t.Errorf("ValidateDefinition(%s) = %v, want %v", c.def, got, c.want)
+		}
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want int
+		ok   bool
+	}{
+		{nil, 0, false},
+		{json.Number("6"), 6, true},
+		{json.Number("6.0"), 0, false},
+		{float64(2), 2, true},
+		{float64(2.5), 0, false},
+		{"2", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := Decimals(map[string]interface{}{DefDecimals: c.v})
+		if got != c.want || ok != c.ok {
+			t.Errorf("Decimals(%#v) = %d, %t, want %d, %t", c.v, got, ok, c.want, c.ok)
+		}
+	}
+}
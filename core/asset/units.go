@@ -0,0 +1,79 @@
+package asset
+
+import (
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"chain/errors"
+)
+
+// ErrBadAmount is returned by ParseAmount and ParseBigAmount for a
+// display amount that isn't a whole number of base units in range.
+var ErrBadAmount = errors.New("invalid display amount")
+
+var displayAmountRE = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// FormatAmount returns amount, in base units, in the display units
+// of an asset with the given decimals: a decimal number with that
+// many digits after the point. For instance, with 2 decimals,
+// 12345 base units are "123.45" display units.
+func FormatAmount(amount uint64, decimals int) string {
+	return formatDigits(strconv.FormatUint(amount, 10), decimals)
+}
+
+// FormatBigAmount is like FormatAmount, but for a nonnegative
+// amount of any size, such as a total of many amounts.
+func FormatBigAmount(amount *big.Int, decimals int) string {
+	return formatDigits(amount.String(), decimals)
+}
+
+func formatDigits(s string, decimals int) string {
+	if decimals <= 0 {
+		return s
+	}
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	return s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+}
+
+// ParseAmount returns the number of base units in s, an amount in
+// the display units of an asset with the given decimals, such as
+// "123.45". The conversion is exact: digits after the point beyond
+// decimals must be zero, and the result must be at most the
+// largest amount a transaction may hold, 2^63-1.
+func ParseAmount(s string, decimals int) (uint64, error) {
+	n, err := ParseBigAmount(s, decimals)
+	if err != nil {
+		return 0, err
+	}
+	if n.Cmp(big.NewInt(math.MaxInt64)) > 0 {
+		return 0, errors.WithDetailf(ErrBadAmount, "%s is too large", s)
+	}
+	return n.Uint64(), nil
+}
+
+// ParseBigAmount is like ParseAmount, but has no upper bound,
+// for amounts such as totals of many amounts.
+func ParseBigAmount(s string, decimals int) (*big.Int, error) {
+	if !displayAmountRE.MatchString(s) {
+		return nil, errors.WithDetailf(ErrBadAmount, "%q is not a decimal number", s)
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if len(frac) > decimals {
+		if strings.Trim(frac[decimals:], "0") != "" {
+			return nil, errors.WithDetailf(ErrBadAmount, "%s has more than %d digits after the point", s, decimals)
+		}
+		frac = frac[:decimals]
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+
+	n, _ := new(big.Int).SetString(whole+frac, 10) // digits checked above
+	return n, nil
+}
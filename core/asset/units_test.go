@@ -0,0 +1,74 @@
+package asset
+
+import (
+	"math"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestFormatAmount(t *testing.T) {
+	cases := []struct {
+		amount   uint64
+		decimals int
+		want     string
+	}{
+		{12345, 2, "123.45"},
+		{12300, 2, "123.00"},
+		{5, 2, "0.05"},
+		{0, 2, "0.00"},
+		{12345, 0, "12345"},
+		{math.MaxUint64, 18, "18.446744073709551615"},
+	}
+	for _, c := range cases {
+		got := FormatAmount(c.amount, c.decimals)
+		if got != c.want {
+			t.Errorf("FormatAmount(%d, %d) = %q, want %q", c.amount, c.decimals, got, c.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		s        string
+		decimals int
+		want     uint64
+		err      error
+	}{
+		{"123.45", 2, 12345, nil},
+		{"123.4", 2, 12340, nil},
+		{"123", 2, 12300, nil},
+		{"0.05", 2, 5, nil},
+		{"1.500", 2, 150, nil},
+		{"1.505", 2, 0, ErrBadAmount},
+		{"1.5", 0, 0, ErrBadAmount},
+		{"9223372036854775807", 0, math.MaxInt64, nil},
+		{"9223372036854775808", 0, 0, ErrBadAmount},
+		{"92233720368547758.08", 2, 0, ErrBadAmount},
+		{"99999999999999999999999", 2, 0, ErrBadAmount},
+		{"-1", 2, 0, ErrBadAmount},
+		{"1e3", 2, 0, ErrBadAmount},
+		{".5", 2, 0, ErrBadAmount},
+		{"", 2, 0, ErrBadAmount},
+	}
+	for _, c := range cases {
+		got, err := ParseAmount(c.s, c.decimals)
+		if errors.Root(err) != c.err || got != c.want {
+			t.Errorf("ParseAmount(%q, %d) = %d, %v, want %d, %v", c.s, c.decimals, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestBigAmount(t *testing.T) {
+	const s = "999999999999999999999.99"
+	n, err := ParseBigAmount(s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.String() != "99999999999999999999999" {
+		t.Errorf("ParseBigAmount(%q, 2) = %s, want 99999999999999999999999", s, n)
+	}
+	if got := FormatBigAmount(n, 2); got != s {
+		t.Errorf("FormatBigAmount(%s, 2) = %q, want %q", n, got, s)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"sync"
 
+	"chain/core/asset"
 	"chain/core/signers"
+	"chain/crypto/ed25519"
 	"chain/encoding/json"
 	"chain/net/http/reqid"
 )
@@ -17,14 +19,35 @@ type assetResponse struct {
 	Keys            interface{} `json:"keys"`
 	Quorum          interface{} `json:"quorum"`
 	Definition      interface{} `json:"definition"`
+	Decimals        interface{} `json:"decimals,omitempty"`
+	Symbol          interface{} `json:"symbol,omitempty"`
 	Tags            interface{} `json:"tags"`
 	IsLocal         interface{} `json:"is_local"`
+	Cosigner        interface{} `json:"cosigner,omitempty"`
+}
+
+// standardFields returns the decimals and symbol declared
+// by an asset definition, or nil for those it doesn't declare.
+func standardFields(def map[string]interface{}) (decimals, symbol interface{}) {
+	if d, ok := asset.Decimals(def); ok {
+		decimals = d
+	}
+	if s := asset.Symbol(def); s != "" {
+		symbol = s
+	}
+	return decimals, symbol
+}
+
+type assetCosigner struct {
+	URL    string        `json:"url"`
+	PubKey json.HexBytes `json:"pubkey"`
 }
 
 type assetKey struct {
-	RootXPub            interface{} `json:"root_xpub"`
-	AssetPubkey         interface{} `json:"asset_pubkey"`
-	AssetDerivationPath interface{} `json:"asset_derivation_path"`
+	RootXPub            interface{}          `json:"root_xpub"`
+	AssetPubkey         interface{}          `json:"asset_pubkey"`
+	AssetDerivationPath interface{}          `json:"asset_derivation_path"`
+	Metadata            *signers.KeyMetadata `json:"metadata,omitempty"`
 }
 
 // POST /create-asset
@@ -35,6 +58,10 @@ func (h *Handler) createAsset(ctx context.Context, ins []struct {
 	Definition map[string]interface{}
 	Tags       map[string]interface{}
 
+	// Cosigner, if set, names an external service, such as a
+	// compliance service, that must also sign every issuance.
+	Cosigner *assetCosigner
+
 	// ClientToken is the application's unique token for the asset. Every asset
 	// should have a unique client token. The client token is used to ensure
 	// idempotency of create asset requests. Duplicate create asset requests
@@ -51,10 +78,15 @@ func (h *Handler) createAsset(ctx context.Context, ins []struct {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
-			asset, err := h.Assets.Define(
+			var cosigner *asset.Cosigner
+			if c := ins[i].Cosigner; c != nil {
+				cosigner = &asset.Cosigner{URL: c.URL, PubKey: ed25519.PublicKey(c.PubKey)}
+			}
+			asset, err := h.Assets.DefineCosigned(
 				subctx,
 				ins[i].RootXPubs,
 				ins[i].Quorum,
+				cosigner,
 				ins[i].Definition,
 				ins[i].Alias,
 				ins[i].Tags,
@@ -64,6 +96,15 @@ func (h *Handler) createAsset(ctx context.Context, ins []struct {
 				responses[i] = err
 				return
 			}
+			var xpubs []string
+			for _, xpub := range asset.Signer.XPubs {
+				xpubs = append(xpubs, xpub.String())
+			}
+			mds, err := signers.GetKeyMetadata(subctx, h.DB, xpubs)
+			if err != nil {
+				responses[i] = err
+				return
+			}
 			var keys []assetKey
 			for _, xpub := range asset.Signer.XPubs {
 				path := signers.Path(asset.Signer, signers.AssetKeySpace)
@@ -72,9 +113,10 @@ func (h *Handler) createAsset(ctx context.Context, ins []struct {
 					AssetPubkey:         json.HexBytes(derived[:]),
 					RootXPub:            xpub,
 					AssetDerivationPath: path,
+					Metadata:            mds[xpub.String()],
 				})
 			}
-			responses[i] = &assetResponse{
+			resp := &assetResponse{
 				ID:              asset.AssetID,
 				Alias:           asset.Alias,
 				IssuanceProgram: asset.IssuanceProgram,
@@ -84,6 +126,11 @@ func (h *Handler) createAsset(ctx context.Context, ins []struct {
 				Tags:            asset.Tags,
 				IsLocal:         "yes",
 			}
+			resp.Decimals, resp.Symbol = standardFields(asset.Definition)
+			if asset.Cosigner != nil {
+				resp.Cosigner = assetCosigner{URL: asset.Cosigner.URL, PubKey: json.HexBytes(asset.Cosigner.PubKey)}
+			}
+			responses[i] = resp
 		}(i)
 	}
 
@@ -1,15 +1,22 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"chain/core/accesstoken"
+	"chain/core/rpc"
+	"chain/core/tenant"
+	"chain/crypto/ed25519"
+	"chain/database/pg"
 	"chain/errors"
+	"chain/net/http/httpjson"
 )
 
 var errNotAuthenticated = errors.New("not authenticated")
@@ -18,9 +25,15 @@ const tokenExpiry = time.Minute * 5
 
 type apiAuthn struct {
 	tokens *accesstoken.CredentialStore
+	// verifier checks requests from other cores
+	// signed with their keys instead of a secret.
+	verifier *rpc.Verifier
 	// alternative authentication mechanism,
 	// used when no basic auth creds are provided.
 	alt func(*http.Request) bool
+	// public lets requests to the explorer
+	// endpoints through unauthenticated.
+	public bool
 
 	tokenMu  sync.Mutex // protects the following
 	tokenMap map[string]tokenResult
@@ -28,57 +41,155 @@ type apiAuthn struct {
 
 type tokenResult struct {
 	valid      bool
+	id         string
+	tenant     string
 	lastLookup time.Time
 }
 
+type accessTokenKey struct{}
+
+// accessTokenID returns the ID of the access token
+// that authenticated the request in ctx, if any.
+func accessTokenID(ctx context.Context) string {
+	id, _ := ctx.Value(accessTokenKey{}).(string)
+	return id
+}
+
+func (res tokenResult) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, accessTokenKey{}, res.id)
+	return tenant.NewContext(ctx, res.tenant)
+}
+
 func (a *apiAuthn) handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		err := a.auth(req)
+		ctx, err := a.auth(req)
 		if err != nil {
 			WriteHTTPError(req.Context(), rw, err)
 			return
 		}
-		next.ServeHTTP(rw, req)
+		next.ServeHTTP(rw, req.WithContext(ctx))
 	})
 }
 
-func (a *apiAuthn) auth(req *http.Request) error {
+// auth authenticates req and returns its context,
+// set to act for the tenant of the access token used.
+func (a *apiAuthn) auth(req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if strings.HasPrefix(req.URL.Path, networkRPCPrefix) && rpc.IsSigned(req) {
+		signedCtx, ok, err := a.signedAuth(req)
+		if err != nil || ok {
+			return signedCtx, err
+		}
+		// The peer's key isn't registered with an access
+		// token; it may still send a token's secret.
+	}
 	user, pw, ok := req.BasicAuth()
+	if !ok && a.public && strings.HasPrefix(req.URL.Path, explorerPrefix) {
+		return ctx, nil
+	}
 	if !ok && a.alt(req) {
-		return nil
+		return ctx, nil
 	}
 
 	typ := "client"
 	if strings.HasPrefix(req.URL.Path, networkRPCPrefix) {
 		typ = "network"
 	}
-	return a.cachedAuthCheck(req.Context(), typ, user, pw)
+	res, err := a.cachedAuthCheck(ctx, typ, user, pw)
+	if err != nil {
+		return nil, err
+	}
+	return res.context(ctx), nil
 }
 
-func (a *apiAuthn) authCheck(ctx context.Context, typ, user, pw string) (bool, error) {
+// signedAuth authenticates a request signed by another core.
+// If the signing key belongs to a network access token, it
+// returns the request's context, set to act for the token's
+// tenant, and true.
+func (a *apiAuthn) signedAuth(req *http.Request) (context.Context, bool, error) {
+	ctx := req.Context()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, false, errors.WithDetail(httpjson.ErrBadRequest, err.Error())
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	pub, err := a.verifier.Verify(req, body)
+	if err != nil {
+		return nil, false, err
+	}
+	res, err := a.cached(ctx, "pubkey:"+string(pub), func() (tokenResult, error) {
+		return a.keyCheck(ctx, pub)
+	})
+	if errors.Root(err) == errNotAuthenticated {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return res.context(ctx), true, nil
+}
+
+func (a *apiAuthn) keyCheck(ctx context.Context, pub ed25519.PublicKey) (tokenResult, error) {
+	res := tokenResult{lastLookup: time.Now()}
+	id, tenantID, err := a.tokens.FindByKey(ctx, pub)
+	if errors.Root(err) == pg.ErrUserInputNotFound {
+		return res, nil
+	} else if err != nil {
+		return res, err
+	}
+	res.valid, res.id, res.tenant = true, id, tenantID
+	return res, nil
+}
+
+func (a *apiAuthn) authCheck(ctx context.Context, typ, user, pw string) (tokenResult, error) {
+	res := tokenResult{lastLookup: time.Now()}
 	pwBytes, err := hex.DecodeString(pw)
 	if err != nil {
-		return false, nil
+		return res, nil
+	}
+	res.valid, err = a.tokens.Check(ctx, user, typ, pwBytes)
+	if err != nil || !res.valid {
+		return res, err
+	}
+	res.id = user
+	res.tenant, err = a.tokens.Tenant(ctx, user)
+	return res, err
+}
+
+func (a *apiAuthn) cachedAuthCheck(ctx context.Context, typ, user, pw string) (tokenResult, error) {
+	return a.cached(ctx, typ+user+pw, func() (tokenResult, error) {
+		return a.authCheck(ctx, typ, user, pw)
+	})
+}
+
+// forget discards the cached results for access token id.
+func (a *apiAuthn) forget(id string) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	for key, res := range a.tokenMap {
+		if res.id == id {
+			delete(a.tokenMap, key)
+		}
 	}
-	return a.tokens.Check(ctx, user, typ, pwBytes)
 }
 
-func (a *apiAuthn) cachedAuthCheck(ctx context.Context, typ, user, pw string) error {
+// cached returns the result of check, cached under key.
+func (a *apiAuthn) cached(ctx context.Context, key string, check func() (tokenResult, error)) (tokenResult, error) {
 	a.tokenMu.Lock()
-	res, ok := a.tokenMap[typ+user+pw]
+	res, ok := a.tokenMap[key]
 	a.tokenMu.Unlock()
 	if !ok || time.Now().After(res.lastLookup.Add(tokenExpiry)) {
-		valid, err := a.authCheck(ctx, typ, user, pw)
+		var err error
+		res, err = check()
 		if err != nil {
-			return errors.Wrap(err)
+			return res, errors.Wrap(err)
 		}
-		res = tokenResult{valid: valid, lastLookup: time.Now()}
 		a.tokenMu.Lock()
-		a.tokenMap[typ+user+pw] = res
+		a.tokenMap[key] = res
 		a.tokenMu.Unlock()
 	}
 	if !res.valid {
-		return errNotAuthenticated
+		return res, errNotAuthenticated
 	}
-	return nil
+	return res, nil
 }
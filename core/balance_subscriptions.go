@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/account"
+)
+
+// POST /create-balance-subscription
+func (h *Handler) createBalanceSubscription(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+	URL          string `json:"url"`
+}) (*account.Subscription, error) {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.AccountID = acc.ID
+	}
+	return h.Accounts.CreateSubscription(ctx, in.AccountID, in.URL)
+}
+
+// POST /list-balance-subscriptions
+func (h *Handler) listBalanceSubscriptions(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) ([]*account.Subscription, error) {
+	if in.AccountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		in.AccountID = acc.ID
+	}
+	subs, err := h.Accounts.Subscriptions(ctx, in.AccountID)
+	if subs == nil {
+		subs = []*account.Subscription{}
+	}
+	return subs, err
+}
+
+// POST /delete-balance-subscription
+func (h *Handler) deleteBalanceSubscription(ctx context.Context, in struct {
+	ID string `json:"id"`
+}) error {
+	return h.Accounts.DeleteSubscription(ctx, in.ID)
+}
@@ -0,0 +1,214 @@
+// Package blockfile reads and writes block files, which hold a
+// range of blocks for copying a blockchain's history between
+// cores without the network RPC.
+//
+// A block file holds, in order:
+//
+//   - an 8-byte magic string, "CHAINBLK";
+//   - each block, as a uvarint byte length followed by the
+//     block's serialization, in ascending order of height;
+//   - an index with, for each block, its height and the offset
+//     of its length prefix, as 8-byte little-endian integers;
+//   - a footer with the number of blocks and the offset of the
+//     index, as 8-byte little-endian integers, followed by the
+//     magic string again.
+//
+// A Writer needs only an io.Writer, so a file can be streamed
+// as it's written. A Reader needs random access, to find the
+// index at the end of the file.
+package blockfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+const magic = "CHAINBLK"
+
+const (
+	indexEntrySize = 16
+	footerSize     = 16 + len(magic)
+)
+
+var (
+	// ErrBadFile is returned when reading a file
+	// that isn't a well-formed block file.
+	ErrBadFile = errors.New("malformed block file")
+
+	// ErrOrder is returned by WriteBlock for a block
+	// not higher than the one written before it.
+	ErrOrder = errors.New("blocks must be written in ascending order of height")
+)
+
+type indexEntry struct {
+	height uint64
+	offset uint64
+}
+
+// A Writer writes a block file.
+type Writer struct {
+	w     *bufio.Writer
+	n     uint64 // bytes written so far
+	index []indexEntry
+	err   error
+}
+
+// NewWriter returns a Writer that writes a block file to w.
+// The caller must call Close to complete the file.
+func NewWriter(w io.Writer) *Writer {
+	bw := &Writer{w: bufio.NewWriter(w)}
+	bw.write([]byte(magic))
+	return bw
+}
+
+func (w *Writer) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(p)
+	w.n += uint64(n)
+	w.err = err
+}
+
+func (w *Writer) writeUint64(v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	w.write(buf[:])
+}
+
+// WriteBlock appends b to the file.
+// Blocks must be written in ascending order of height.
+func (w *Writer) WriteBlock(b *bc.Block) error {
+	if n := len(w.index); n > 0 && b.Height <= w.index[n-1].height {
+		return errors.WithDetailf(ErrOrder, "block %d after block %d", b.Height, w.index[n-1].height)
+	}
+	data, err := b.Value()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	raw := data.([]byte)
+	w.index = append(w.index, indexEntry{b.Height, w.n})
+	var lenbuf [binary.MaxVarintLen64]byte
+	w.write(lenbuf[:binary.PutUvarint(lenbuf[:], uint64(len(raw)))])
+	w.write(raw)
+	return errors.Wrap(w.err, "writing block")
+}
+
+// Close writes the index and footer of the file
+// and flushes it to the underlying writer.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	indexOffset := w.n
+	for _, e := range w.index {
+		w.writeUint64(e.height)
+		w.writeUint64(e.offset)
+	}
+	w.writeUint64(uint64(len(w.index)))
+	w.writeUint64(indexOffset)
+	w.write([]byte(magic))
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return errors.Wrap(w.err, "writing block file index")
+}
+
+// A Reader reads the blocks in a block file.
+type Reader struct {
+	r     io.ReaderAt
+	index []indexEntry
+	end   uint64 // offset of the index
+}
+
+// NewReader reads the index of the block file in r,
+// which holds size bytes.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(len(magic)+footerSize) {
+		return nil, errors.WithDetail(ErrBadFile, "file is too short")
+	}
+	footer := make([]byte, footerSize)
+	_, err := r.ReadAt(footer, size-int64(footerSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading footer")
+	}
+	head := make([]byte, len(magic))
+	_, err = r.ReadAt(head, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading header")
+	}
+	if string(head) != magic || string(footer[16:]) != magic {
+		return nil, errors.WithDetail(ErrBadFile, "missing magic string")
+	}
+
+	count := binary.LittleEndian.Uint64(footer)
+	indexOffset := binary.LittleEndian.Uint64(footer[8:])
+	if indexOffset < uint64(len(magic)) || count > uint64(size)/indexEntrySize ||
+		indexOffset+count*indexEntrySize != uint64(size)-uint64(footerSize) {
+		return nil, errors.WithDetail(ErrBadFile, "bad index location")
+	}
+	buf := make([]byte, count*indexEntrySize)
+	_, err = r.ReadAt(buf, int64(indexOffset))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading index")
+	}
+	index := make([]indexEntry, count)
+	for i := range index {
+		e := buf[i*indexEntrySize:]
+		index[i] = indexEntry{
+			height: binary.LittleEndian.Uint64(e),
+			offset: binary.LittleEndian.Uint64(e[8:]),
+		}
+		if index[i].offset >= indexOffset || (i > 0 && index[i].height <= index[i-1].height) {
+			return nil, errors.WithDetailf(ErrBadFile, "bad index entry %d", i)
+		}
+	}
+	return &Reader{r: r, index: index, end: indexOffset}, nil
+}
+
+// Len returns the number of blocks in the file.
+func (r *Reader) Len() int {
+	return len(r.index)
+}
+
+// Height returns the height of the i'th block in the file.
+func (r *Reader) Height(i int) uint64 {
+	return r.index[i].height
+}
+
+// Find returns the position in the file of the block
+// at the given height, and whether the file has it.
+func (r *Reader) Find(height uint64) (int, bool) {
+	i := sort.Search(len(r.index), func(i int) bool { return r.index[i].height >= height })
+	return i, i < len(r.index) && r.index[i].height == height
+}
+
+// Block reads and decodes the i'th block in the file.
+func (r *Reader) Block(i int) (*bc.Block, error) {
+	e := r.index[i]
+	br := bufio.NewReader(io.NewSectionReader(r.r, int64(e.offset), int64(r.end-e.offset)))
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading length of block %d", e.height)
+	}
+	if n > r.end-e.offset {
+		return nil, errors.WithDetailf(ErrBadFile, "block %d overruns the file", e.height)
+	}
+	raw := make([]byte, n)
+	_, err = io.ReadFull(br, raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading block %d", e.height)
+	}
+	b := new(bc.Block)
+	err = b.Scan(raw)
+	if err != nil {
+		return nil, errors.WithDetailf(ErrBadFile, "decoding block %d: %s", e.height, err)
+	}
+	if b.Height != e.height {
+		return nil, errors.WithDetailf(ErrBadFile, "block at height %d is indexed at %d", b.Height, e.height)
+	}
+	return b, nil
+}
@@ -0,0 +1,112 @@
+package blockfile
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/mempool"
+	"chain/protocol/memstore"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := prottest.NewChain(t)
+	for i := 0; i < 3; i++ {
+		prottest.MakeBlock(t, src)
+	}
+
+	var buf bytes.Buffer
+	err := Export(ctx, src.Store(), &buf, 1, src.Height())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if r.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", r.Len())
+	}
+	if i, ok := r.Find(3); !ok || i != 2 {
+		t.Errorf("Find(3) = %d, %v, want 2, true", i, ok)
+	}
+
+	dst, err := protocol.NewChain(ctx, src.InitialBlockHash, memstore.New(), mempool.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	dst.MaxIssuanceWindow = src.MaxIssuanceWindow
+	_, _, err = dst.Recover(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	n, err := Import(ctx, dst, r)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 4 || dst.Height() != 4 {
+		t.Errorf("Import committed %d blocks, to height %d; want 4 blocks, to height 4", n, dst.Height())
+	}
+
+	// Importing again skips the blocks already committed.
+	n, err = Import(ctx, dst, r)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 0 {
+		t.Errorf("second Import committed %d blocks, want 0", n)
+	}
+}
+
+func TestImportGap(t *testing.T) {
+	ctx := context.Background()
+	src := prottest.NewChain(t)
+	for i := 0; i < 3; i++ {
+		prottest.MakeBlock(t, src)
+	}
+
+	var buf bytes.Buffer
+	err := Export(ctx, src.Store(), &buf, 3, 4)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	dst, err := protocol.NewChain(ctx, src.InitialBlockHash, memstore.New(), mempool.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, _, err = dst.Recover(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = Import(ctx, dst, r)
+	if errors.Root(err) != ErrGap {
+		t.Errorf("Import = %v, want %v", err, ErrGap)
+	}
+}
+
+func TestBadFile(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.Close()
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	data := buf.Bytes()
+	_, err = NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Errorf("empty file: %v", err)
+	}
+	_, err = NewReader(bytes.NewReader(data[1:]), int64(len(data)-1))
+	if errors.Root(err) != ErrBadFile {
+		t.Errorf("truncated file: got %v, want %v", err, ErrBadFile)
+	}
+}
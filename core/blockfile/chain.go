@@ -0,0 +1,73 @@
+package blockfile
+
+import (
+	"context"
+	"io"
+
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/state"
+)
+
+// ErrGap is returned by Import when the blocks in a file
+// don't follow on from the blockchain's latest block.
+var ErrGap = errors.New("block file doesn't continue the blockchain")
+
+// Export writes a block file to w holding the blocks in
+// store from height from through height to, inclusive.
+func Export(ctx context.Context, store protocol.Store, w io.Writer, from, to uint64) error {
+	if from == 0 {
+		from = 1
+	}
+	bw := NewWriter(w)
+	for h := from; h <= to; h++ {
+		b, err := store.GetBlock(ctx, h)
+		if err != nil {
+			return errors.Wrapf(err, "getting block %d", h)
+		}
+		err = bw.WriteBlock(b)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Close()
+}
+
+// Import validates and commits to c the blocks in r above c's
+// current height, in order, and returns the number committed.
+// Blocks in r at or below c's height are skipped. C must already
+// be recovered (see Chain.Recover), and must not also receive
+// blocks from elsewhere, as from a generator, during the import.
+func Import(ctx context.Context, c *protocol.Chain, r *Reader) (int, error) {
+	prev, snapshot := c.State()
+	if snapshot == nil {
+		snapshot = state.Empty()
+	}
+	var height uint64
+	if prev != nil {
+		height = prev.Height
+	}
+
+	i, _ := r.Find(height + 1)
+	var n int
+	for ; i < r.Len(); i++ {
+		if r.Height(i) != height+1 {
+			return n, errors.WithDetailf(ErrGap, "want block %d, file has block %d", height+1, r.Height(i))
+		}
+		b, err := r.Block(i)
+		if err != nil {
+			return n, err
+		}
+		snapshot, err = c.ValidateBlock(ctx, snapshot, prev, b)
+		if err != nil {
+			return n, err
+		}
+		err = c.CommitBlock(ctx, b, snapshot)
+		if err != nil {
+			return n, errors.Wrapf(err, "committing block %d", b.Height)
+		}
+		prev, height = b, b.Height
+		n++
+	}
+	return n, nil
+}
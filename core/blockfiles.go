@@ -0,0 +1,51 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"chain/core/blockfile"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+// POST /export-blocks
+//
+// Responds with a block file holding the blocks from from_height
+// through to_height, inclusive; see package blockfile. A zero
+// to_height means the latest block.
+func (h *Handler) exportBlocks(rw http.ResponseWriter, req *http.Request) {
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	var in struct {
+		FromHeight uint64 `json:"from_height"`
+		ToHeight   uint64 `json:"to_height"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&in)
+	if err != nil {
+		WriteHTTPError(ctx, rw, errors.WithDetail(httpjson.ErrBadRequest, err.Error()))
+		return
+	}
+	height := h.Chain.Height()
+	if in.ToHeight == 0 {
+		in.ToHeight = height
+	}
+	if in.ToHeight > height || in.FromHeight > in.ToHeight {
+		WriteHTTPError(ctx, rw, errors.WithDetailf(httpjson.ErrBadRequest,
+			"cannot export blocks %d through %d at height %d", in.FromHeight, in.ToHeight, height))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	err = blockfile.Export(ctx, h.Store, rw, in.FromHeight, in.ToHeight)
+	if err != nil {
+		// The response has begun; the client will find the file
+		// truncated.
+		log.Error(ctx, err)
+	}
+}
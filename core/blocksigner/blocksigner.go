@@ -5,19 +5,34 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"time"
 
 	"chain/core/mockhsm"
+	"chain/core/rpc"
 	"chain/crypto/ed25519"
+	"chain/crypto/kms"
 	"chain/database/pg"
 	"chain/errors"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/validation"
 )
 
 // ErrConsensusChange is returned from ValidateAndSignBlock
 // when a new consensus program is detected.
 var ErrConsensusChange = errors.New("consensus program has changed")
 
+// ErrUnknownSoftFork is returned from ValidateAndSignBlock
+// when the block signals a soft fork this core doesn't know.
+// Signers only agree to a soft fork by upgrading to a version
+// of Chain Core that enforces its rules.
+var ErrUnknownSoftFork = errors.New("block signals an unknown soft fork")
+
+// ErrNonCanonical is returned from ValidateAndSignBlock
+// when the block's transactions are not in canonical order.
+// See protocol.CanonicalTxOrder.
+var ErrNonCanonical = errors.New("block transactions are not in canonical order")
+
 // ErrInvalidKey is returned from SignBlock when the
 // key specified on the Signer is invalid. It may be
 // not found by the mock HSM or not paired to a valid
@@ -26,10 +41,11 @@ var ErrInvalidKey = errors.New("misconfigured signer public key")
 
 // Signer validates and signs blocks.
 type Signer struct {
-	Pub ed25519.PublicKey
-	hsm *mockhsm.HSM
-	db  pg.DB
-	c   *protocol.Chain
+	Pub    ed25519.PublicKey
+	Policy Policy // checked by ValidateAndSignBlock
+	sign   func(context.Context, *bc.BlockHeader) ([]byte, error)
+	db     pg.DB
+	c      *protocol.Chain
 }
 
 // New returns a new Signer that validates blocks with c and signs
@@ -37,21 +53,50 @@ type Signer struct {
 func New(pub ed25519.PublicKey, hsm *mockhsm.HSM, db pg.DB, c *protocol.Chain) *Signer {
 	return &Signer{
 		Pub: pub,
-		hsm: hsm,
-		db:  db,
-		c:   c,
+		sign: func(ctx context.Context, bh *bc.BlockHeader) ([]byte, error) {
+			hash := bh.HashForSig()
+			sig, err := hsm.Sign(ctx, pub, hash[:])
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidKey, "err=%s", err.Error())
+			}
+			return sig, nil
+		},
+		db: db,
+		c:  c,
+	}
+}
+
+// NewKMS returns a new Signer that validates blocks with c and
+// signs them with k, whose key may be held by a cloud KMS. See
+// package kms.
+func NewKMS(k kms.Signer, db pg.DB, c *protocol.Chain) *Signer {
+	return &Signer{
+		Pub: k.Public(),
+		sign: func(ctx context.Context, bh *bc.BlockHeader) ([]byte, error) {
+			hash := bh.HashForSig()
+			return k.Sign(ctx, hash[:])
+		},
+		db: db,
+		c:  c,
+	}
+}
+
+// NewRemote returns a new Signer that validates blocks with c and
+// has the signer service at client, which holds the private key
+// for pub, sign them. See Service.
+func NewRemote(pub ed25519.PublicKey, client *rpc.Client, db pg.DB, c *protocol.Chain) *Signer {
+	return &Signer{
+		Pub:  pub,
+		sign: remoteSign(pub, client),
+		db:   db,
+		c:    c,
 	}
 }
 
 // SignBlock computes the signature for the block using
 // the private key in s.  It does not validate the block.
 func (s *Signer) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
-	hash := b.HashForSig()
-	sig, err := s.hsm.Sign(ctx, s.Pub, hash[:])
-	if err != nil {
-		return nil, errors.Wrapf(ErrInvalidKey, "err=%s", err.Error())
-	}
-	return sig, nil
+	return s.sign(ctx, &b.BlockHeader)
 }
 
 func (s *Signer) String() string {
@@ -63,7 +108,7 @@ func (s *Signer) String() string {
 // is used as the httpjson handler for /rpc/signer/sign-block.
 //
 // This function fails if this node has ever signed a different block at the
-// same height as b.
+// same height as b, or if b violates s.Policy.
 func (s *Signer) ValidateAndSignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
 	err := <-s.c.BlockSoonWaiter(ctx, b.Height-1)
 	if err != nil {
@@ -82,10 +127,23 @@ func (s *Signer) ValidateAndSignBlock(ctx context.Context, b *bc.Block) ([]byte,
 	if !bytes.Equal(b.ConsensusProgram, prev.ConsensusProgram) {
 		return nil, errors.Wrap(ErrConsensusChange)
 	}
+	if validation.UnknownBits(b.Version) != 0 {
+		return nil, errors.WithDetailf(ErrUnknownSoftFork, "block version %#x", b.Version)
+	}
+	// With its transactions in canonical order, and its
+	// merkle roots checked by ValidateBlockForSig, the block
+	// is the one this signer would build from them.
+	if !protocol.IsCanonical(b) {
+		return nil, errors.Wrap(ErrNonCanonical)
+	}
 	err = s.c.ValidateBlockForSig(ctx, b)
 	if err != nil {
 		return nil, errors.Wrap(err, "validating block for signature")
 	}
+	err = s.Policy.Check(ctx, s.db, b)
+	if err != nil {
+		return nil, err
+	}
 	err = lockBlockHeight(ctx, s.db, b)
 	if err != nil {
 		return nil, errors.Wrap(err, "lock block height")
@@ -94,15 +152,56 @@ func (s *Signer) ValidateAndSignBlock(ctx context.Context, b *bc.Block) ([]byte,
 }
 
 // lockBlockHeight records a signer's intention to sign a given block
-// at a given height.  It's an error if a different block at the same
-// height has previously been signed.
+// at a given height in the signing journal. It returns ErrDoubleSign
+// if a different block at the same height has previously been signed.
+//
+// The journal entry is written before the signature is computed, and
+// an entry, once written, is never replaced. A signer that crashes
+// and restarts between the two steps refuses to sign a different block
+// at that height, even though it never returned a signature.
 func lockBlockHeight(ctx context.Context, db pg.DB, b *bc.Block) error {
 	const q = `
-		INSERT INTO signed_blocks (block_height, block_hash)
-		SELECT $1, $2
-		    WHERE NOT EXISTS (SELECT 1 FROM signed_blocks
-		                      WHERE block_height = $1 AND block_hash = $2)
+		INSERT INTO signed_blocks (block_height, block_hash) VALUES ($1, $2)
+		ON CONFLICT (block_height) DO UPDATE SET block_hash = signed_blocks.block_hash
+		RETURNING block_hash
+	`
+	var (
+		hash   = b.HashForSig()
+		signed bc.Hash
+	)
+	err := db.QueryRow(ctx, q, b.Height, hash).Scan(&signed)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if signed != hash {
+		return errors.WithDetailf(ErrDoubleSign, "already signed block %s at height %d", signed, b.Height)
+	}
+	return nil
+}
+
+// SignedBlock is an entry in a block signer's signing journal.
+type SignedBlock struct {
+	Height   uint64    `json:"block_height"`
+	Hash     bc.Hash   `json:"block_hash"` // the hash signed; see BlockHeader.HashForSig
+	SignedAt time.Time `json:"signed_at"`
+}
+
+// Journal returns up to limit entries of the signing journal in db
+// for heights above after, in ascending order of height. It
+// returns the height of the last entry, to pass as after in the
+// next call.
+func Journal(ctx context.Context, db pg.DB, after uint64, limit int) ([]*SignedBlock, uint64, error) {
+	const q = `
+		SELECT block_height, block_hash, signed_at FROM signed_blocks
+		WHERE block_height > $1 ORDER BY block_height LIMIT $2
 	`
-	_, err := db.Exec(ctx, q, b.Height, b.HashForSig())
-	return err
+	var entries []*SignedBlock
+	err := pg.ForQueryRows(ctx, db, q, after, limit, func(height uint64, hash bc.Hash, signedAt time.Time) {
+		entries = append(entries, &SignedBlock{Height: height, Hash: hash, SignedAt: signedAt})
+		after = height
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "reading signing journal")
+	}
+	return entries, after, nil
 }
@@ -0,0 +1,42 @@
+package blocksigner
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestLockBlockHeight(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+
+	b1 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 1}}
+	b2 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 2}}
+
+	err := lockBlockHeight(ctx, db, b1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Signing the same block again is fine...
+	err = lockBlockHeight(ctx, db, b1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// ...but a different one at the same height is not.
+	err = lockBlockHeight(ctx, db, b2)
+	if errors.Root(err) != ErrDoubleSign {
+		t.Errorf("lockBlockHeight(conflicting block) = %v, want %v", err, ErrDoubleSign)
+	}
+
+	entries, last, err := Journal(ctx, db, 0, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(entries) != 1 || entries[0].Hash != b1.HashForSig() || last != 2 {
+		t.Errorf("Journal = %+v (last %d), want one entry for %s at height 2", entries, last, b1.HashForSig())
+	}
+}
@@ -0,0 +1,117 @@
+package blocksigner
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrPolicy is returned from ValidateAndSignBlock
+// when the block violates the signer's Policy.
+var ErrPolicy = errors.New("block violates signer policy")
+
+// Policy is a set of local rules a Signer applies, beyond
+// the consensus rules, before signing a block. Any signer
+// may veto blocks it doesn't like; a block still needs only
+// a quorum of signatures. The zero Policy allows every block.
+type Policy struct {
+	// MaxTxs, if nonzero, limits the number
+	// of transactions in each block.
+	MaxTxs int
+
+	// Generators, if set, are the IDs of the network access
+	// tokens a generator may use to ask for signatures.
+	Generators []string
+
+	// MinInterval is the least time between
+	// signatures of blocks at different heights.
+	MinInterval time.Duration
+
+	// EmbargoedAssets are assets no transaction
+	// in a block may issue, spend, or control.
+	EmbargoedAssets []bc.AssetID
+}
+
+type generatorKey struct{}
+
+// NewContext returns a context for a request to sign a block
+// made by a generator authenticated with the network access
+// token named by id. See Policy.Generators.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, generatorKey{}, id)
+}
+
+// Check returns ErrPolicy, with detail naming the rule, if
+// b violates p. The generator asking for a signature is the
+// one in ctx; see NewContext. Signatures already made are
+// those in the signing journal in db.
+func (p *Policy) Check(ctx context.Context, db pg.DB, b *bc.Block) error {
+	if p.MaxTxs > 0 && len(b.Transactions) > p.MaxTxs {
+		return errors.WithDetailf(ErrPolicy, "block has %d transactions, more than %d", len(b.Transactions), p.MaxTxs)
+	}
+
+	if len(p.Generators) > 0 {
+		id, _ := ctx.Value(generatorKey{}).(string)
+		if !contains(p.Generators, id) {
+			return errors.WithDetailf(ErrPolicy, "generator access token %q is not allowed", id)
+		}
+	}
+
+	if len(p.EmbargoedAssets) > 0 {
+		embargoed := make(map[bc.AssetID]bool)
+		for _, a := range p.EmbargoedAssets {
+			embargoed[a] = true
+		}
+		for _, tx := range b.Transactions {
+			for _, in := range tx.Inputs {
+				if embargoed[in.AssetID()] {
+					return errors.WithDetailf(ErrPolicy, "transaction %s spends or issues embargoed asset %s", tx.Hash, in.AssetID())
+				}
+			}
+			for _, out := range tx.Outputs {
+				if embargoed[out.AssetID] {
+					return errors.WithDetailf(ErrPolicy, "transaction %s controls embargoed asset %s", tx.Hash, out.AssetID)
+				}
+			}
+		}
+	}
+
+	if p.MinInterval > 0 {
+		const q = `
+			SELECT block_height, block_hash, signed_at FROM signed_blocks
+			ORDER BY block_height DESC LIMIT 1
+		`
+		var (
+			height   uint64
+			hash     bc.Hash
+			signedAt time.Time
+		)
+		err := db.QueryRow(ctx, q).Scan(&height, &hash, &signedAt)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "reading signing journal")
+		}
+		// Signing the same block again is always allowed.
+		if height == b.Height && hash == b.HashForSig() {
+			return nil
+		}
+		if since := time.Since(signedAt); since < p.MinInterval {
+			return errors.WithDetailf(ErrPolicy, "last signed block %d %s ago, less than %s", height, since, p.MinInterval)
+		}
+	}
+	return nil
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
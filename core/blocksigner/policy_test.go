@@ -0,0 +1,61 @@
+package blocksigner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestPolicy(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := NewContext(context.Background(), "gen")
+
+	embargoed := bc.AssetID{1}
+	issue := func(assetID bc.AssetID) *bc.Tx {
+		return bc.NewTx(bc.TxData{
+			Version: 1,
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, nil, nil)},
+		})
+	}
+	block := func(height uint64, txs ...*bc.Tx) *bc.Block {
+		return &bc.Block{BlockHeader: bc.BlockHeader{Height: height}, Transactions: txs}
+	}
+
+	b2 := block(2, issue(bc.AssetID{2}))
+	err := lockBlockHeight(ctx, db, b2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	cases := []struct {
+		policy Policy
+		ctx    context.Context
+		b      *bc.Block
+		ok     bool
+	}{
+		{Policy{}, ctx, block(3, issue(embargoed), issue(embargoed)), true},
+		{Policy{MaxTxs: 1}, ctx, block(3, issue(bc.AssetID{}), issue(bc.AssetID{})), false},
+		{Policy{MaxTxs: 2}, ctx, block(3, issue(bc.AssetID{}), issue(bc.AssetID{})), true},
+		{Policy{Generators: []string{"gen"}}, ctx, block(3), true},
+		{Policy{Generators: []string{"gen"}}, context.Background(), block(3), false},
+		{Policy{Generators: []string{"other"}}, ctx, block(3), false},
+		{Policy{EmbargoedAssets: []bc.AssetID{embargoed}}, ctx, block(3, issue(embargoed)), false},
+		{Policy{EmbargoedAssets: []bc.AssetID{embargoed}}, ctx, block(3, issue(bc.AssetID{})), true},
+		{Policy{MinInterval: time.Hour}, ctx, block(3), false},
+		{Policy{MinInterval: time.Hour}, ctx, b2, true}, // signed already
+		{Policy{MinInterval: time.Nanosecond}, ctx, block(3), true},
+	}
+	for i, c := range cases {
+		err := c.policy.Check(c.ctx, db, c.b)
+		if c.ok && err != nil {
+			t.Errorf("case %d: Check = %v want nil", i, err)
+		} else if !c.ok && errors.Root(err) != ErrPolicy {
+			t.Errorf("case %d: Check = %v want %v", i, err, ErrPolicy)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package blocksigner
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"chain/core/rpc"
+	"chain/crypto/ed25519"
+	"chain/crypto/kms"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ServicePath is the path of the signer service's RPC
+// for signing block headers.
+const ServicePath = "/rpc/signer/sign-block-header"
+
+var (
+	// ErrDoubleSign is returned by SignHeader for a block
+	// header at the height of a different header it
+	// has already signed.
+	ErrDoubleSign = errors.New("refuse to sign a second block at the same height")
+
+	// ErrStaleBlock is returned by SignHeader for a block
+	// header lower or earlier than one it has already signed.
+	ErrStaleBlock = errors.New("refuse to sign a block before the last signed block")
+)
+
+// Service signs block headers with a key kept apart from
+// Chain Core, as in cmd/signerd. It knows nothing of the
+// blockchain; Chain Core validates each block before asking
+// it for a signature. Service only checks that it never signs
+// two different blocks at the same height, and that heights
+// and timestamps of the blocks it signs never decrease.
+type Service struct {
+	pub       ed25519.PublicKey
+	sign      func(context.Context, []byte) ([]byte, error)
+	statePath string
+
+	mu   sync.Mutex
+	last signedHeader
+}
+
+// signedHeader records the last block header a Service signed.
+type signedHeader struct {
+	Height      uint64  `json:"height"`
+	TimestampMS uint64  `json:"timestamp_ms"`
+	Hash        bc.Hash `json:"hash"`
+}
+
+// NewService returns a Service that signs with key, recording
+// the last header it signed in the file at statePath, which
+// it creates if it doesn't exist.
+func NewService(key ed25519.PrivateKey, statePath string) (*Service, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.Wrap(ErrInvalidKey)
+	}
+	return newService(key.Public().(ed25519.PublicKey), func(_ context.Context, msg []byte) ([]byte, error) {
+		return ed25519.Sign(key, msg), nil
+	}, statePath)
+}
+
+// NewKMSService is like NewService, but signs with k,
+// whose key may be held by a cloud KMS. See package kms.
+func NewKMSService(k kms.Signer, statePath string) (*Service, error) {
+	return newService(k.Public(), k.Sign, statePath)
+}
+
+func newService(pub ed25519.PublicKey, sign func(context.Context, []byte) ([]byte, error), statePath string) (*Service, error) {
+	s := &Service{pub: pub, sign: sign, statePath: statePath}
+	data, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading signer state")
+	}
+	err = json.Unmarshal(data, &s.last)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding signer state")
+	}
+	return s, nil
+}
+
+// Pub returns the public key the Service signs with.
+func (s *Service) Pub() ed25519.PublicKey {
+	return s.pub
+}
+
+// SignHeader returns a signature for bh, first recording bh
+// as the last header signed. A header already signed is
+// signed again.
+func (s *Service) SignHeader(ctx context.Context, bh *bc.BlockHeader) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := bh.HashForSig()
+	if s.last.Height > 0 {
+		switch {
+		case bh.Height == s.last.Height && hash != s.last.Hash:
+			return nil, errors.WithDetailf(ErrDoubleSign, "already signed block %s at height %d", s.last.Hash, bh.Height)
+		case bh.Height < s.last.Height:
+			return nil, errors.WithDetailf(ErrStaleBlock, "height %d is below last signed height %d", bh.Height, s.last.Height)
+		case bh.TimestampMS < s.last.TimestampMS:
+			return nil, errors.WithDetailf(ErrStaleBlock, "timestamp %d is before last signed timestamp %d", bh.TimestampMS, s.last.TimestampMS)
+		}
+	}
+
+	next := signedHeader{Height: bh.Height, TimestampMS: bh.TimestampMS, Hash: hash}
+	if next != s.last {
+		err := s.save(next)
+		if err != nil {
+			return nil, err
+		}
+		s.last = next
+	}
+	return s.sign(ctx, hash[:])
+}
+
+// save writes h to the state file. It writes a new file and
+// renames it over the old one, so the state file is never
+// left partly written.
+func (s *Service) save(h signedHeader) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	tmp := s.statePath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "saving signer state")
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "saving signer state")
+	}
+	return errors.Wrap(os.Rename(tmp, s.statePath), "saving signer state")
+}
+
+// remoteSign returns a function that asks the signer service
+// at client to sign block headers, and checks the signatures
+// it returns against pub.
+func remoteSign(pub ed25519.PublicKey, client *rpc.Client) func(context.Context, *bc.BlockHeader) ([]byte, error) {
+	return func(ctx context.Context, bh *bc.BlockHeader) ([]byte, error) {
+		var sig []byte
+		err := client.Call(ctx, ServicePath, struct {
+			Header *bc.BlockHeader `json:"header"`
+		}{bh}, &sig)
+		if err != nil {
+			return nil, errors.Wrap(err, "calling signer service")
+		}
+		hash := bh.HashForSig()
+		if !ed25519.Verify(pub, hash[:], sig) {
+			return nil, errors.WithDetailf(ErrInvalidKey, "signer service at %s does not sign with key %x", client.BaseURL, []byte(pub))
+		}
+		return sig, nil
+	}
+}
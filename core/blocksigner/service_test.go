@@ -0,0 +1,69 @@
+package blocksigner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestServiceSignHeader(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "signerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state")
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewService(prv, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b2 := &bc.BlockHeader{Height: 2, TimestampMS: 20}
+	sig, err := s.SignHeader(ctx, b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := b2.HashForSig()
+	if !ed25519.Verify(pub, hash[:], sig) {
+		t.Error("SignHeader() returned a bad signature")
+	}
+
+	// Signing the same header again is allowed.
+	_, err = s.SignHeader(ctx, b2)
+	if err != nil {
+		t.Errorf("SignHeader(same header) error = %v, want nil", err)
+	}
+
+	// A restarted service remembers the last header it signed.
+	s, err = NewService(prv, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		bh   *bc.BlockHeader
+		want error
+	}{
+		{&bc.BlockHeader{Height: 2, TimestampMS: 21}, ErrDoubleSign},
+		{&bc.BlockHeader{Height: 1, TimestampMS: 30}, ErrStaleBlock},
+		{&bc.BlockHeader{Height: 3, TimestampMS: 19}, ErrStaleBlock},
+		{&bc.BlockHeader{Height: 4, TimestampMS: 20}, nil},
+	}
+	for _, c := range cases {
+		_, err := s.SignHeader(ctx, c.bh)
+		if errors.Root(err) != c.want {
+			t.Errorf("SignHeader(height %d, timestamp %d) error = %v, want %v", c.bh.Height, c.bh.TimestampMS, err, c.want)
+		}
+	}
+}
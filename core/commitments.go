@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/txbuilder/signing"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+)
+
+// recordCommitments saves the build commitments of tpl, a
+// template this core just built or amended, until its transaction
+// expires. The MockHSM signs only witnesses whose commitments were
+// saved, so a client can't alter a template and recompute them.
+func recordCommitments(ctx context.Context, db pg.DB, tpl *signing.Template) error {
+	commitments := signing.Commitments(tpl)
+	if len(commitments) == 0 {
+		return nil
+	}
+	var (
+		hashes pq.ByteaArray
+		seen   = make(map[[32]byte]bool) // an upsert can't affect a row twice
+	)
+	for _, c := range commitments {
+		var h [32]byte
+		sha3pool.Sum256(h[:], c)
+		if !seen[h] {
+			seen[h] = true
+			hashes = append(hashes, h[:])
+		}
+	}
+	expiresAt := time.Now().Add(defaultTxTTL)
+	if tpl.ExpiresAt != nil {
+		expiresAt = *tpl.ExpiresAt
+	}
+
+	const q = `
+		INSERT INTO build_commitments (hash, expires_at)
+		SELECT unnest($1::bytea[]), $2
+		ON CONFLICT (hash) DO UPDATE
+		SET expires_at = greatest(build_commitments.expires_at, excluded.expires_at)
+	`
+	_, err := db.Exec(ctx, q, hashes, expiresAt)
+	return errors.Wrap(err, "recording build commitments")
+}
+
+// builtCommitment reports whether this core built or amended a
+// template with the given commitment that hasn't yet expired.
+func builtCommitment(ctx context.Context, db pg.DB, commitment []byte) (bool, error) {
+	var h [32]byte
+	sha3pool.Sum256(h[:], commitment)
+	const q = `SELECT EXISTS (SELECT 1 FROM build_commitments WHERE hash=$1 AND expires_at > now())`
+	var found bool
+	err := db.QueryRow(ctx, q, h[:]).Scan(&found)
+	return found, errors.Wrap(err, "looking up build commitment")
+}
+
+// CleanupBuildCommitments periodically deletes the build
+// commitments of expired transactions. This function blocks
+// and only exits when its context is cancelled.
+func CleanupBuildCommitments(ctx context.Context, db pg.DB) {
+	ticker := time.NewTicker(15 * time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			const q = `DELETE FROM build_commitments WHERE expires_at < now()`
+			_, err := db.Exec(ctx, q)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"chain/protocol/bc"
 	"chain/protocol/mempool"
 	"chain/protocol/state"
+	"chain/protocol/vmutil"
 )
 
 const (
@@ -49,6 +50,13 @@ type Config struct {
 	Signers              []BlockSigner `json:"block_signer_urls"`
 	Quorum               int
 	MaxIssuanceWindow    time.Duration
+
+	// MaxBlockBytes and MaxBlockTxs, if nonzero, limit the size
+	// of blocks. They're committed to in the initial block a
+	// generator creates (see vmutil.BlockLimits), so they're
+	// only used in configuring a generator and aren't stored.
+	MaxBlockBytes uint64 `json:"max_block_bytes,omitempty"`
+	MaxBlockTxs   uint64 `json:"max_block_txs,omitempty"`
 }
 
 type BlockSigner struct {
@@ -168,7 +176,8 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 			return errors.Wrap(ErrBadQuorum)
 		}
 
-		block, err := protocol.NewInitialBlock(signingKeys, c.Quorum, time.Now())
+		limits := vmutil.BlockLimits{MaxBytes: c.MaxBlockBytes, MaxTxs: c.MaxBlockTxs}
+		block, err := protocol.NewInitialBlockWithLimits(signingKeys, c.Quorum, limits, time.Now())
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrBlockchainMismatch is returned by CheckBlockchainID
+// for a database holding a blockchain other than the
+// configured one.
+var ErrBlockchainMismatch = errors.New("database holds a different blockchain")
+
+// generatorCheckTimeout limits how long CheckGenerator
+// waits for the generator.
+const generatorCheckTimeout = 10 * time.Second
+
+// A Check is one step of validating a configured core at
+// startup, before it serves traffic. Hint tells an operator
+// what to do if the check fails.
+type Check struct {
+	Name string
+	Hint string
+	Run  func(context.Context) error
+}
+
+// A Result is the outcome of a Check.
+type Result struct {
+	Name string
+	Hint string
+	Err  error
+}
+
+// A Report holds the results of every check run by Validate.
+type Report struct {
+	Results []Result
+}
+
+// Validate runs every check, even after one fails, so
+// its report covers every problem at once.
+func Validate(ctx context.Context, checks []Check) *Report {
+	r := new(Report)
+	for _, c := range checks {
+		err := c.Run(ctx)
+		r.Results = append(r.Results, Result{Name: c.Name, Hint: c.Hint, Err: err})
+	}
+	return r
+}
+
+// Failed returns the number of checks that failed.
+func (r *Report) Failed() int {
+	var n int
+	for _, res := range r.Results {
+		if res.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// String formats r for an operator, a line per check,
+// with the error and hint of each failure.
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "startup checks: %d of %d failed\n", r.Failed(), len(r.Results))
+	for _, res := range r.Results {
+		if res.Err == nil {
+			fmt.Fprintf(&buf, "  ok    %s\n", res.Name)
+			continue
+		}
+		fmt.Fprintf(&buf, "  FAIL  %s: %s\n", res.Name, res.Err)
+		if res.Hint != "" {
+			fmt.Fprintf(&buf, "        %s\n", res.Hint)
+		}
+	}
+	return buf.String()
+}
+
+// CheckBlockchainID returns ErrBlockchainMismatch if the
+// initial block in db isn't the one c names. A generator's
+// database must have its initial block; other cores may
+// not have fetched it yet.
+func CheckBlockchainID(ctx context.Context, db pg.DB, c *Config) error {
+	const q = `SELECT block_hash FROM blocks WHERE height = 1`
+	var hash bc.Hash
+	err := db.QueryRow(ctx, q).Scan(&hash)
+	if err == sql.ErrNoRows {
+		if c.IsGenerator {
+			return errors.WithDetail(ErrBlockchainMismatch, "generator has no initial block")
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "reading initial block")
+	}
+	if hash != c.BlockchainID {
+		return errors.WithDetailf(ErrBlockchainMismatch, "initial block is %s, but blockchain_id is %s", hash, c.BlockchainID)
+	}
+	return nil
+}
+
+// CheckGenerator returns ErrBadGenerator if the generator
+// c names can't be reached, or doesn't serve c's blockchain.
+func CheckGenerator(ctx context.Context, c *Config) error {
+	ctx, cancel := context.WithTimeout(ctx, generatorCheckTimeout)
+	defer cancel()
+	return tryGenerator(ctx, c.GeneratorURL, c.GeneratorAccessToken, c.BlockchainID.String())
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestValidate(t *testing.T) {
+	var ran []string
+	check := func(name string, err error) Check {
+		return Check{
+			Name: name,
+			Hint: "fix " + name,
+			Run: func(context.Context) error {
+				ran = append(ran, name)
+				return err
+			},
+		}
+	}
+	r := Validate(context.Background(), []Check{
+		check("a", nil),
+		check("b", errors.WithDetail(ErrBlockchainMismatch, "initial block is x")),
+		check("c", nil),
+	})
+	if strings.Join(ran, ",") != "a,b,c" {
+		t.Errorf("ran %v, want every check", ran)
+	}
+	if r.Failed() != 1 {
+		t.Errorf("Failed() = %d, want 1", r.Failed())
+	}
+	got := r.String()
+	want := `startup checks: 1 of 3 failed
+  ok    a
+  FAIL  b: initial block is x: database holds a different blockchain
+        fix b
+  ok    c
+`
+	if got != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+// Package contract keeps a registry of the source code of
+// control and issuance programs.
+//
+// Anyone can register the source of a program, with the compiler
+// that compiled it and the arguments it was instantiated with.
+// Registration compiles the source again and refuses it unless
+// the result is exactly the program, so explorers and auditors
+// can show what a program does rather than its raw opcodes.
+package contract
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+var (
+	// ErrUnknownCompiler is returned for source
+	// claiming a compiler the registry doesn't know.
+	ErrUnknownCompiler = errors.New("unknown compiler")
+
+	// ErrMismatch is returned when source doesn't
+	// compile to the program it's claimed for.
+	ErrMismatch = errors.New("source does not match program")
+)
+
+// A Compiler turns source code, instantiated with
+// constructor arguments, into a program.
+type Compiler struct {
+	Name      string
+	Version   string
+	VMVersion uint64
+	Compile   func(source string, args [][]byte) ([]byte, error)
+}
+
+// compilers lists the compilers the registry can check source with.
+var compilers = []Compiler{{
+	// The VM version 1 assembler; see vm.Assemble.
+	// The constructor arguments are pushed onto the stack
+	// before the assembled source runs.
+	Name:      "chainvm-asm",
+	Version:   "1",
+	VMVersion: 1,
+	Compile: func(source string, args [][]byte) ([]byte, error) {
+		var prefix []string
+		for _, a := range args {
+			prefix = append(prefix, "0x"+hex.EncodeToString(a))
+		}
+		return vm.Assemble(strings.Join(append(prefix, source), " "))
+	},
+}}
+
+func findCompiler(name, version string, vmVersion uint64) (*Compiler, error) {
+	for i := range compilers {
+		c := &compilers[i]
+		if c.Name == name && c.Version == version {
+			if c.VMVersion != vmVersion {
+				return nil, errors.WithDetailf(ErrMismatch, "%s %s compiles for VM version %d", name, version, c.VMVersion)
+			}
+			return c, nil
+		}
+	}
+	return nil, errors.WithDetailf(ErrUnknownCompiler, "%s %s", name, version)
+}
+
+// Source is the source code of a program.
+type Source struct {
+	ProgramHash     bc.Hash              `json:"program_hash"`
+	Program         chainjson.HexBytes   `json:"program"`
+	VMVersion       uint64               `json:"vm_version"`
+	Source          string               `json:"source"`
+	Compiler        string               `json:"compiler"`
+	CompilerVersion string               `json:"compiler_version"`
+	Args            []chainjson.HexBytes `json:"constructor_args"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
+
+// ProgramHash returns the hash identifying a program in the registry.
+func ProgramHash(prog []byte) (h bc.Hash) {
+	sha3pool.Sum256(h[:], prog)
+	return h
+}
+
+// Verify returns nil if s.Source, compiled by the compiler it
+// names with its constructor arguments, is s.Program, and
+// otherwise ErrMismatch or ErrUnknownCompiler. It sets
+// s.ProgramHash.
+func Verify(s *Source) error {
+	c, err := findCompiler(s.Compiler, s.CompilerVersion, s.VMVersion)
+	if err != nil {
+		return err
+	}
+	args := make([][]byte, 0, len(s.Args))
+	for _, a := range s.Args {
+		args = append(args, a)
+	}
+	prog, err := c.Compile(s.Source, args)
+	if err != nil {
+		return errors.WithDetailf(ErrMismatch, "compiling: %s", err)
+	}
+	if string(prog) != string(s.Program) {
+		return errors.WithDetailf(ErrMismatch, "source compiles to %x", prog)
+	}
+	s.ProgramHash = ProgramHash(s.Program)
+	return nil
+}
+
+// Registry stores verified source.
+type Registry struct {
+	DB pg.DB
+}
+
+// Register verifies s and stores it. If source is already
+// registered for the program, it returns that instead.
+func (r *Registry) Register(ctx context.Context, s *Source) (*Source, error) {
+	err := Verify(s)
+	if err != nil {
+		return nil, err
+	}
+	args, err := json.Marshal(s.Args)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	const q = `
+		INSERT INTO contract_sources
+			(program_hash, program, vm_version, source, compiler, compiler_version, constructor_args)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (program_hash) DO NOTHING
+	`
+	_, err = r.DB.Exec(ctx, q, s.ProgramHash, []byte(s.Program), s.VMVersion, s.Source, s.Compiler, s.CompilerVersion, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting contract source")
+	}
+	return r.Find(ctx, s.ProgramHash)
+}
+
+// Find returns the source registered for the program with hash h.
+func (r *Registry) Find(ctx context.Context, h bc.Hash) (*Source, error) {
+	const q = `
+		SELECT program, vm_version, source, compiler, compiler_version, constructor_args, created_at
+		FROM contract_sources WHERE program_hash=$1
+	`
+	s := &Source{ProgramHash: h}
+	var args []byte
+	err := r.DB.QueryRow(ctx, q, h).Scan(
+		(*[]byte)(&s.Program),
+		&s.VMVersion,
+		&s.Source,
+		&s.Compiler,
+		&s.CompilerVersion,
+		&args,
+		&s.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "program hash %s", h)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	err = json.Unmarshal(args, &s.Args)
+	return s, errors.Wrap(err)
+}
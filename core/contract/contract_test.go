@@ -0,0 +1,112 @@
+package contract
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+	"chain/testutil"
+)
+
+func TestVerify(t *testing.T) {
+	// The constructor argument comes before the
+	// assembled source, which must account for it
+	// in its jump targets.
+	b := vmutil.NewBuilder()
+	b.AddData([]byte{1, 2})
+	b.AddOp(vm.OP_JUMP)
+	b.AddRawBytes([]byte{9, 0, 0, 0})
+	b.AddOp(vm.OP_FAIL)
+	b.AddOp(vm.OP_SHA3)
+	b.AddOp(vm.OP_DROP)
+	b.AddOp(vm.OP_TRUE)
+	prog := b.Program
+
+	newSource := func() *Source {
+		return &Source{
+			Program:         prog,
+			VMVersion:       1,
+			Source:          "JUMP:$ok FAIL $ok SHA3 DROP TRUE",
+			Compiler:        "chainvm-asm",
+			CompilerVersion: "1",
+			Args:            []chainjson.HexBytes{{1, 2}},
+		}
+	}
+
+	s := newSource()
+	err := Verify(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ProgramHash != ProgramHash(prog) {
+		t.Errorf("program hash = %x want %x", s.ProgramHash, ProgramHash(prog))
+	}
+
+	cases := []struct {
+		change  func(*Source)
+		wantErr error
+	}{
+		{func(s *Source) { s.Args[0] = []byte{1, 3} }, ErrMismatch},
+		{func(s *Source) { s.Args = nil }, ErrMismatch},
+		{func(s *Source) { s.Source = "JUMP:$ok FAIL $ok TRUE" }, ErrMismatch},
+		{func(s *Source) { s.Source = "NOTANOP" }, ErrMismatch},
+		{func(s *Source) { s.VMVersion = 2 }, ErrMismatch},
+		{func(s *Source) { s.CompilerVersion = "2" }, ErrUnknownCompiler},
+		{func(s *Source) { s.Compiler = "ivy" }, ErrUnknownCompiler},
+	}
+	for i, c := range cases {
+		s := newSource()
+		c.change(s)
+		err := Verify(s)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: Verify error = %v want %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	r := &Registry{DB: db}
+
+	prog, err := vm.Assemble("0x01 EQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Find(ctx, ProgramHash(prog))
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Find(unregistered) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	src := &Source{
+		Program:         prog,
+		VMVersion:       1,
+		Source:          "EQUAL",
+		Compiler:        "chainvm-asm",
+		CompilerVersion: "1",
+		Args:            []chainjson.HexBytes{{1}},
+	}
+	_, err = r.Register(ctx, &Source{Program: prog, VMVersion: 1, Source: "NUMEQUAL", Compiler: "chainvm-asm", CompilerVersion: "1", Args: src.Args})
+	if errors.Root(err) != ErrMismatch {
+		t.Errorf("Register(wrong source) error = %v want %v", err, ErrMismatch)
+	}
+	got, err := r.Register(ctx, src)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Source != "EQUAL" || len(got.Args) != 1 || got.CreatedAt.IsZero() {
+		t.Errorf("Register = %+v", got)
+	}
+	got, err = r.Find(ctx, ProgramHash(prog))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Source != "EQUAL" || string(got.Program) != string(prog) {
+		t.Errorf("Find = %+v", got)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/contract"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+type contractSourceRequest struct {
+	Program         chainjson.HexBytes   `json:"program"`
+	VMVersion       uint64               `json:"vm_version"`
+	Source          string               `json:"source"`
+	Compiler        string               `json:"compiler"`
+	CompilerVersion string               `json:"compiler_version"`
+	Args            []chainjson.HexBytes `json:"constructor_args"`
+}
+
+// source returns the contract source in req.
+// The VM version defaults to 1.
+func (req contractSourceRequest) source() *contract.Source {
+	s := &contract.Source{
+		Program:         req.Program,
+		VMVersion:       req.VMVersion,
+		Source:          req.Source,
+		Compiler:        req.Compiler,
+		CompilerVersion: req.CompilerVersion,
+		Args:            req.Args,
+	}
+	if s.VMVersion == 0 {
+		s.VMVersion = 1
+	}
+	if s.Args == nil {
+		s.Args = []chainjson.HexBytes{}
+	}
+	return s
+}
+
+// POST /register-contract-source
+func (h *Handler) registerContractSource(ctx context.Context, req contractSourceRequest) (*contract.Source, error) {
+	return h.Contracts.Register(ctx, req.source())
+}
+
+// POST /verify-contract-source
+//
+// It checks the source like /register-contract-source,
+// without registering it.
+func (h *Handler) verifyContractSource(ctx context.Context, req contractSourceRequest) (*contract.Source, error) {
+	s := req.source()
+	err := contract.Verify(s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// POST /get-contract-source
+//
+// The program may be given in full or by its hash.
+func (h *Handler) getContractSource(ctx context.Context, in struct {
+	ProgramHash *bc.Hash           `json:"program_hash"`
+	Program     chainjson.HexBytes `json:"program"`
+}) (*contract.Source, error) {
+	switch {
+	case in.ProgramHash != nil:
+		return h.Contracts.Find(ctx, *in.ProgramHash)
+	case len(in.Program) > 0:
+		return h.Contracts.Find(ctx, contract.ProgramHash(in.Program))
+	}
+	return nil, errors.WithDetail(httpjson.ErrBadRequest, "program_hash or program is required")
+}
@@ -50,6 +50,7 @@ func (h *Handler) createAccountControlProgram(ctx context.Context, input []byte)
 	var parsed struct {
 		AccountAlias string `json:"account_alias"`
 		AccountID    string `json:"account_id"`
+		SingleUse    bool   `json:"single_use"`
 	}
 	err := stdjson.Unmarshal(input, &parsed)
 	if err != nil {
@@ -65,7 +66,12 @@ func (h *Handler) createAccountControlProgram(ctx context.Context, input []byte)
 		accountID = acc.ID
 	}
 
-	controlProgram, err := h.Accounts.CreateControlProgram(ctx, accountID, false)
+	var controlProgram []byte
+	if parsed.SingleUse {
+		controlProgram, err = h.Accounts.CreateSingleUseControlProgram(ctx, accountID)
+	} else {
+		controlProgram, err = h.Accounts.CreateControlProgram(ctx, accountID, false)
+	}
 	if err != nil {
 		return nil, err
 	}
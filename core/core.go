@@ -10,7 +10,10 @@ import (
 
 	"chain/core/config"
 	"chain/core/fetch"
+	"chain/core/generator"
 	"chain/core/leader"
+	"chain/core/tenant"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
 	"chain/net/http/httpjson"
@@ -24,6 +27,9 @@ var (
 	// errProdReset is returned when reset is called on a
 	// production system.
 	errProdReset = errors.New("reset called on production system")
+	// errSandboxNotGenerator is returned when a sandbox reset
+	// is requested of a core that is not a generator.
+	errSandboxNotGenerator = errors.New("sandbox reset called on non-generator")
 )
 
 // reserved mockhsm key alias
@@ -36,16 +42,31 @@ func isProduction() bool {
 	return p != nil && p.String() == `"yes"`
 }
 
+// reset deletes the core's data and restarts it.
+// By default, it deletes the blockchain data and the configuration.
+// With everything, it also deletes access tokens and mockhsm keys.
+// With sandbox, it deletes the blockchain data but keeps the core
+// configured, starting a new blockchain with a new initial block.
 func (h *Handler) reset(ctx context.Context, req struct {
 	Everything bool `json:"everything"`
+	Sandbox    bool `json:"sandbox"`
 }) error {
 	if isProduction() {
 		return errors.Wrap(errProdReset)
 	}
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
 
 	dataToReset := "blockchain"
-	if req.Everything {
+	switch {
+	case req.Everything:
 		dataToReset = "everything"
+	case req.Sandbox:
+		if !h.Config.IsGenerator {
+			return errSandboxNotGenerator
+		}
+		dataToReset = "sandbox"
 	}
 
 	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
@@ -113,12 +134,21 @@ func (h *Handler) leaderInfo(ctx context.Context) (map[string]interface{}, error
 		"generator_block_height_fetched_at": generatorFetched,
 		"is_production":                     isProduction(),
 		"network_rpc_version":               networkRPCVersion,
+		"network_rpc_key":                   chainjson.HexBytes(h.RPCKey),
 		"core_id":                           h.Config.ID,
 		"build_commit":                      &buildCommit,
 		"build_date":                        &buildDate,
 		"health":                            h.health(),
 	}
 
+	if h.Config.IsGenerator {
+		halt, err := generator.GetHalt(ctx, h.DB)
+		if err != nil {
+			return nil, err
+		}
+		m["generator_halt"] = halt
+	}
+
 	// Add in snapshot information if we're downloading a snapshot.
 	if snapshot != nil {
 		m["snapshot"] = map[string]interface{}{
@@ -132,10 +162,32 @@ func (h *Handler) leaderInfo(ctx context.Context) (map[string]interface{}, error
 	return m, nil
 }
 
+// createSnapshot saves a snapshot of the current blockchain
+// state now, instead of waiting for the next periodic snapshot.
+// New cores bootstrap from the latest snapshot.
+func (h *Handler) createSnapshot(ctx context.Context) (map[string]uint64, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	if !leader.IsLeading() {
+		var resp map[string]uint64
+		err := h.forwardToLeader(ctx, "/create-snapshot", nil, &resp)
+		return resp, err
+	}
+	height, err := h.Chain.SaveSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]uint64{"height": height}, nil
+}
+
 func (h *Handler) configure(ctx context.Context, x *config.Config) error {
 	if h.Config != nil {
 		return errAlreadyConfigured
 	}
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
 
 	if x.IsGenerator && x.MaxIssuanceWindow == 0 {
 		x.MaxIssuanceWindow = 24 * time.Hour
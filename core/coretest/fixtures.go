@@ -10,6 +10,7 @@ import (
 	"chain/core/asset"
 	"chain/core/pin"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/crypto/ed25519/chainkd"
 	"chain/errors"
 	"chain/protocol"
@@ -30,7 +31,7 @@ func CreatePins(ctx context.Context, t testing.TB, s *pin.Store) {
 
 func CreateAccount(ctx context.Context, t testing.TB, accounts *account.Manager, alias string, tags map[string]interface{}) string {
 	keys := []string{testutil.TestXPub.String()}
-	acc, err := accounts.Create(ctx, keys, 1, alias, tags, nil)
+	acc, err := accounts.Create(ctx, keys, 1, alias, tags, false, nil)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -89,11 +90,11 @@ func Transfer(ctx context.Context, t testing.TB, c *protocol.Chain, actions []tx
 	return tx
 }
 
-func SignTxTemplate(t testing.TB, ctx context.Context, template *txbuilder.Template, priv *chainkd.XPrv) {
+func SignTxTemplate(t testing.TB, ctx context.Context, template *signing.Template, priv *chainkd.XPrv) {
 	if priv == nil {
 		priv = &testutil.TestXPrv
 	}
-	err := txbuilder.Sign(ctx, template, []string{priv.XPub().String()}, func(_ context.Context, _ string, path [][]byte, data [32]byte) ([]byte, error) {
+	err := signing.Sign(ctx, template, []string{priv.XPub().String()}, func(_ context.Context, _ string, path [][]byte, data [32]byte) ([]byte, error) {
 		derived := priv.Derive(path)
 		return derived.Sign(data[:]), nil
 	})
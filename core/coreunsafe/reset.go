@@ -10,11 +10,16 @@ import (
 	"expvar"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 
+	"chain/core/config"
+	"chain/core/txdb"
 	"chain/database/pg"
+	"chain/database/sql"
 	"chain/errors"
+	"chain/protocol/bc"
 )
 
 var (
@@ -40,7 +45,74 @@ func ResetBlockchain(ctx context.Context, db pg.DB) error {
 	var skip []string
 	skip = append(skip, persistBlockchainReset...)
 	skip = append(skip, neverReset...)
+	return truncate(ctx, db, skip)
+}
+
+// ResetSandbox deletes all blockchain data and starts a new
+// blockchain, in one database transaction. Unlike ResetBlockchain,
+// it leaves the core configured: the new blockchain's initial
+// block has the same consensus program as the old one's, and the
+// core keeps its configuration, access tokens, and mockhsm keys.
+// Only a generator can start a new blockchain.
+func ResetSandbox(ctx context.Context, db *sql.DB) error {
+	if isProduction() {
+		// Shouldn't ever happen; This package shouldn't even be
+		// included in a production binary.
+		panic("reset called on production")
+	}
+
+	conf, err := config.Load(ctx, db)
+	if err != nil {
+		return err
+	}
+	if conf == nil || !conf.IsGenerator {
+		return errors.New("sandbox reset requires a core configured as a generator")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer tx.Rollback(ctx)
+
+	store := txdb.NewStore(tx)
+	prev, err := store.GetBlock(ctx, 1)
+	if err != nil {
+		return errors.Wrap(err, "loading initial block")
+	}
+
+	var skip []string
+	skip = append(skip, persistBlockchainReset...)
+	skip = append(skip, neverReset...)
+	skip = append(skip, "config")
+	err = truncate(ctx, tx, skip)
+	if err != nil {
+		return err
+	}
+
+	// The timestamp gives the new blockchain a new ID.
+	initial := &bc.Block{BlockHeader: prev.BlockHeader}
+	initial.TimestampMS = bc.Millis(time.Now())
+	if initial.TimestampMS <= prev.TimestampMS {
+		initial.TimestampMS = prev.TimestampMS + 1
+	}
+	err = store.SaveBlock(ctx, initial)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE config SET blockchain_id=$1`, initial.Hash())
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	err = store.FinalizeBlock(ctx, initial.Height)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(tx.Commit(ctx))
+}
 
+// truncate deletes the rows of every table but those in skip.
+func truncate(ctx context.Context, db pg.DB, skip []string) error {
 	const tableQ = `
 		SELECT table_name
 		FROM information_schema.tables
@@ -0,0 +1,52 @@
+package coreunsafe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/config"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestResetSandbox(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+
+	conf := &config.Config{IsGenerator: true, MaxIssuanceWindow: time.Hour}
+	err := config.Configure(ctx, db, conf)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = db.Exec(ctx, `INSERT INTO blocks (block_hash, height, data, header) SELECT '\xff', 2, data, header FROM blocks`)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	err = ResetSandbox(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	got, err := config.Load(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got == nil || !got.IsGenerator || got.BlockchainID == conf.BlockchainID {
+		t.Fatalf("config after reset = %+v, want generator with new blockchain ID", got)
+	}
+
+	var (
+		n    int
+		hash bc.Hash
+	)
+	err = db.QueryRow(ctx, `SELECT COUNT(*), MIN(block_hash) FROM blocks`).Scan(&n, &hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 1 || hash != got.BlockchainID {
+		t.Errorf("after reset got %d blocks, first %x; want 1 block, %x", n, hash[:], got.BlockchainID[:])
+	}
+}
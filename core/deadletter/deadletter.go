@@ -0,0 +1,188 @@
+// Package deadletter keeps transactions whose submission failed,
+// so that an operator can inspect them, retry them, or dismiss
+// them, rather than finding them only in client logs.
+//
+// A transaction has at most one open failure. Submitting it
+// again and failing updates that failure, counting the attempt,
+// and submitting it successfully resolves it.
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/core/tenant"
+	"chain/core/txbuilder/signing"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+const (
+	StatusFailed    = "failed"    // awaiting retry or dismissal
+	StatusResolved  = "resolved"  // submitted successfully since
+	StatusDismissed = "dismissed" // dismissed by an operator
+)
+
+const defaultLimit = 100
+
+// ErrClosed is returned when retrying or dismissing a failure
+// that has been resolved or dismissed.
+var ErrClosed = errors.New("submission failure already resolved or dismissed")
+
+// Failure is a failed submission of a transaction. Code,
+// Message, Detail, and Data describe the most recent error, as
+// the submit request's response did.
+type Failure struct {
+	ID        string                 `json:"id"`
+	TxID      bc.Hash                `json:"transaction_id"`
+	Template  *signing.Template      `json:"transaction"`
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Detail    string                 `json:"detail,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Submitter string                 `json:"submitter,omitempty"`
+	Attempts  int                    `json:"attempts"`
+	Status    string                 `json:"status"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// Queue stores failed submissions.
+type Queue struct {
+	DB pg.DB
+}
+
+// Record records that submitting f.Template failed as f
+// describes, either as a new failure or as another attempt
+// of the transaction's open failure.
+func (q *Queue) Record(ctx context.Context, f *Failure) error {
+	tpl, err := json.Marshal(f.Template)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	data, err := json.Marshal(f.Data)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	const insertQ = `
+		INSERT INTO submission_failures (tx_hash, template, code, message,
+			detail, data, submitter, status, tenant)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tx_hash) WHERE status='failed' DO UPDATE
+		SET template=excluded.template, code=excluded.code, message=excluded.message,
+			detail=excluded.detail, data=excluded.data, submitter=excluded.submitter,
+			attempts=submission_failures.attempts+1, updated_at=now()
+	`
+	_, err = q.DB.Exec(ctx, insertQ, f.Template.Transaction.Hash().String(), tpl, f.Code,
+		f.Message, f.Detail, data, f.Submitter, StatusFailed, tenant.FromContext(ctx))
+	return errors.Wrap(err, "recording submission failure")
+}
+
+// Resolve marks the open failure of the transaction
+// with the given ID, if any, resolved.
+func (q *Queue) Resolve(ctx context.Context, txID bc.Hash) error {
+	const updateQ = `
+		UPDATE submission_failures SET status=$2, updated_at=now()
+		WHERE tx_hash=$1 AND status=$3
+	`
+	_, err := q.DB.Exec(ctx, updateQ, txID.String(), StatusResolved, StatusFailed)
+	return errors.Wrap(err, "resolving submission failure")
+}
+
+// Dismiss marks the open failure with the given ID dismissed.
+func (q *Queue) Dismiss(ctx context.Context, id string) error {
+	const updateQ = `
+		UPDATE submission_failures SET status=$3, updated_at=now()
+		WHERE id=$1 AND ($2='' OR tenant=$2) AND status=$4
+	`
+	res, err := q.DB.Exec(ctx, updateQ, id, tenant.FromContext(ctx), StatusDismissed, StatusFailed)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		// Report a missing failure as such.
+		_, err = q.Find(ctx, id)
+		if err != nil {
+			return err
+		}
+		return errors.WithDetailf(ErrClosed, "submission failure %s", id)
+	}
+	return nil
+}
+
+// Find returns the failure with the given ID.
+func (q *Queue) Find(ctx context.Context, id string) (*Failure, error) {
+	failures, err := q.list(ctx, `id=$2`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "submission failure %s", id)
+	}
+	return failures[0], nil
+}
+
+// List returns failures with the given status, or with
+// any status if status is empty, in pages ordered by ID,
+// newest first.
+func (q *Queue) List(ctx context.Context, status, after string, limit int) ([]*Failure, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	const where = `($2='' OR status=$2) AND ($3='' OR id<$3) ORDER BY id DESC LIMIT $4`
+	failures, err := q.list(ctx, where, status, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(failures) > 0 {
+		after = failures[len(failures)-1].ID
+	}
+	return failures, after, nil
+}
+
+// list returns the failures visible to the tenant that ctx acts
+// for that match where. The tenant is parameter $1 of the query,
+// and args are parameters $2 and up.
+func (q *Queue) list(ctx context.Context, where string, args ...interface{}) ([]*Failure, error) {
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+	query := `
+		SELECT id, tx_hash, template, code, message, detail, data,
+			submitter, attempts, status, created_at, updated_at
+		FROM submission_failures
+		WHERE ($1='' OR tenant=$1) AND ` + where
+	rows, err := q.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	var failures []*Failure
+	for rows.Next() {
+		var (
+			f         Failure
+			tpl, data []byte
+		)
+		err := rows.Scan(&f.ID, &f.TxID, &tpl, &f.Code, &f.Message, &f.Detail, &data,
+			&f.Submitter, &f.Attempts, &f.Status, &f.CreatedAt, &f.UpdatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		f.Template = new(signing.Template)
+		err = json.Unmarshal(tpl, f.Template)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding template")
+		}
+		err = json.Unmarshal(data, &f.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding error data")
+		}
+		failures = append(failures, &f)
+	}
+	return failures, errors.Wrap(rows.Err())
+}
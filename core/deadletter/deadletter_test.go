@@ -0,0 +1,68 @@
+package deadletter
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/txbuilder/signing"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestQueue(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	q := &Queue{DB: db}
+
+	tpl := &signing.Template{Transaction: &bc.TxData{Version: 1}}
+	txID := tpl.Transaction.Hash()
+	fail := func(code string) {
+		err := q.Record(ctx, &Failure{Template: tpl, Code: code, Message: "failed", Data: map[string]interface{}{"n": 1.0}})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	// Two failures of one transaction are one failure.
+	fail("CH735")
+	fail("CH000")
+	failures, _, err := q.List(ctx, StatusFailed, "", 0)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("got %d failures, want 1", len(failures))
+	}
+	f := failures[0]
+	if f.TxID != txID || f.Code != "CH000" || f.Attempts != 2 || f.Template.Transaction.Hash() != txID || f.Data["n"] != 1.0 {
+		t.Errorf("failure = %+v, want the second attempt of %s", f, txID)
+	}
+
+	err = q.Dismiss(ctx, f.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = q.Dismiss(ctx, f.ID)
+	if errors.Root(err) != ErrClosed {
+		t.Errorf("Dismiss(dismissed) = %v want %v", err, ErrClosed)
+	}
+
+	// Once dismissed, a new failure is recorded separately,
+	// and a successful submission resolves it.
+	fail("CH735")
+	err = q.Resolve(ctx, txID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	for status, want := range map[string]int{StatusFailed: 0, StatusDismissed: 1, StatusResolved: 1, "": 2} {
+		failures, _, err := q.List(ctx, status, "", 0)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if len(failures) != want {
+			t.Errorf("List(%q) = %d failures, want %d", status, len(failures), want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import "sort"
+
+// errorHints says, for each code where there is something to
+// say, what a client or operator can do about the error. Hints
+// are in error responses and the error catalog.
+var errorHints = map[string]string{
+	"CH000": "Retry the request. If it keeps failing, check the core's logs for the error.",
+	"CH001": "Retry the request, or make it smaller.",
+	"CH002": "Check the ID or alias in the request.",
+	"CH003": "Check the request body against the endpoint's schema in /openapi.json.",
+	"CH004": "Check the request's headers.",
+	"CH006": "Check the request path.",
+	"CH007": "Wait, then retry at a lower rate, or ask an operator to raise the rate limit.",
+	"CH008": "Retry the request in a few seconds.",
+	"CH009": "Send a valid access token in the request's basic auth credentials.",
+	"CH010": "Add the fields named in the detail.",
+	"CH011": "Retry the transaction later.",
+	"CH012": "Wait for an operator to resume the generator, then retry.",
+	"CH013": "Use a new idempotency key for each distinct request.",
+	"CH014": "Retry the request later, with the same idempotency key.",
+	"CH050": "Choose another alias.",
+	"CH052": "Fix the definition's standard fields as described in /asset-definition-schema.json.",
+	"CH053": "Give the amount in base units, or in display units with no more digits after the point than the asset's decimals.",
+	"CH100": "Configure the core with /configure.",
+	"CH101": "Reset the core before configuring it again.",
+	"CH102": "Check the generator URL and access token.",
+	"CH104": "Check that every core is configured with the same blockchain ID.",
+	"CH105": "Request a height no further ahead of the blockchain.",
+	"CH112": "Ask an operator to enable asset supply tracking.",
+	"CH113": "Send the request to the generator.",
+	"CH120": "Create a client access token first.",
+	"CH121": "Register the peer's public key with this core.",
+	"CH154": "Check the clocks of the generator and this core.",
+	"CH200": "Set a quorum between 1 and the number of xpubs.",
+	"CH201": "Check that each xpub is 64 bytes, hex-encoded.",
+	"CH202": "Give at least one xpub.",
+	"CH205": "Use the existing key, account, or asset, or choose other xpubs.",
+	"CH300": "Use an ID of letters, digits, underscores, and dashes.",
+	"CH302": "Choose another access token ID.",
+	"CH310": "Delete the token using a different access token.",
+	"CH311": "Use an access token of the default tenant.",
+	"CH312": "Set the quota using a different access token.",
+	"CH600": "Pass the `after` value from the previous page's response, unchanged.",
+	"CH601": "Give one parameter for each placeholder in the filter.",
+	"CH602": "Check the filter's syntax and field names.",
+	"CH700": "Build the transaction with the reference data of the partial transaction.",
+	"CH701": "Use one of the action types listed in the documentation.",
+	"CH704": "Give a positive amount.",
+	"CH705": "Add an action that takes payment for the assets the transaction leaves unconstrained.",
+	"CH706": "See the errors of each action in the data.",
+	"CH708": "Build a new transaction.",
+	"CH730": "Send the transaction template returned by /build-transaction, signed.",
+	"CH734": "Fix the transaction according to the reason in the data, then build and submit it again.",
+	"CH735": "Build and submit the transaction again; its inputs may have been spent.",
+	"CH736": "Sign the template with allow_additional_actions false before submitting it.",
+	"CH738": "Submit the transaction at normal priority, or raise the access token's quota.",
+	"CH739": "Have a second operator approve the transaction.",
+	"CH741": "Approve the transaction with a different access token.",
+	"CH745": "Build and sign the transaction again.",
+	"CH750": "Build the transaction on this blockchain.",
+	"CH752": "Submit the transaction as it was built and signed, or build it again.",
+	"CH753": "Wait for the unconfirmed transactions it spends to be confirmed.",
+	"CH754": "Use an access token of the default tenant.",
+	"CH760": "Fund the account, or spend less.",
+	"CH761": "Retry after outstanding transactions are confirmed or their reservations expire.",
+	"CH762": "Spend less, or raise the account's spending limit.",
+	"CH764": "Spend from an account that holds its keys in this core.",
+	"CH768": "Choose a parent account of the same tenant that is not the account or one of its descendants.",
+	"CH769": "Use an access token of the default tenant.",
+	"CH804": "Check the passphrase.",
+	"CH806": "Check that the key-encryption key is the one used for the backup.",
+	"CH811": "Import the reference data key.",
+	"CH820": "Wait for the asset to be unfrozen.",
+	"CH833": "Ask an operator to enable signing requests.",
+	"CH840": "Ask an operator to enable risk scoring.",
+	"CH850": "Use error, info, or debug.",
+	"CH851": "Use one of the subsystems listed in /get-log-level.",
+}
+
+// A catalogEntry describes one error code in the
+// error catalog.
+type catalogEntry struct {
+	errorInfo
+	HTTPStatus int    `json:"status"`
+	Temporary  bool   `json:"temporary"`
+	Hint       string `json:"hint,omitempty"`
+}
+
+// GET /error-codes.json
+//
+// errorCatalog lists every error code the API responds with,
+// in order, so clients can match on codes, which are stable
+// across releases, and not on messages, which are not.
+func errorCatalog() []catalogEntry {
+	seen := map[string]bool{infoInternal.ChainCode: true}
+	a := []catalogEntry{newCatalogEntry(infoInternal)}
+	for _, info := range errorInfoTab {
+		if seen[info.ChainCode] {
+			continue
+		}
+		seen[info.ChainCode] = true
+		a = append(a, newCatalogEntry(info))
+	}
+	sort.Sort(byCode(a))
+	return a
+}
+
+func newCatalogEntry(info errorInfo) catalogEntry {
+	return catalogEntry{
+		errorInfo:  info,
+		HTTPStatus: info.HTTPStatus,
+		Temporary:  isTemporary(info, nil),
+		Hint:       errorHints[info.ChainCode],
+	}
+}
+
+type byCode []catalogEntry
+
+func (a byCode) Len() int           { return len(a) }
+func (a byCode) Less(i, j int) bool { return a[i].ChainCode < a[j].ChainCode }
+func (a byCode) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -5,20 +5,34 @@ import (
 
 	"chain/core/accesstoken"
 	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/asset"
 	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/contract"
+	"chain/core/deadletter"
+	"chain/core/freeze"
+	"chain/core/htlc"
 	"chain/core/mockhsm"
 	"chain/core/query"
 	"chain/core/query/filter"
+	"chain/core/refdata"
 	"chain/core/rpc"
+	"chain/core/schedule"
 	"chain/core/signers"
+	"chain/core/signreq"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/core/txfeed"
+	"chain/crypto/keystore"
 	"chain/database/pg"
 	"chain/errors"
+	"chain/log"
 	"chain/net/http/httpjson"
 	"chain/protocol"
+	"chain/protocol/mempool"
+	"chain/protocol/standard"
+	"chain/protocol/validation"
 )
 
 // errorInfo contains a set of error codes to send to the user.
@@ -33,6 +47,7 @@ type detailedError struct {
 	Detail    string                 `json:"detail,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 	Temporary bool                   `json:"temporary"`
+	Hint      string                 `json:"hint,omitempty"`
 }
 
 func isTemporary(info errorInfo, err error) bool {
@@ -41,11 +56,17 @@ func isTemporary(info errorInfo, err error) bool {
 		return true
 	case "CH001": // request timed out
 		return true
+	case txbuilder.OverloadedCode: // generator overloaded
+		return true
 	case "CH761": // outputs currently reserved
 		return true
+	case "CH014": // idempotent request in progress
+		return true
+	case "CH739": // transaction awaits approval
+		return true
 	case "CH706": // 1 or more action errors
-		errs := errors.Data(err)["actions"].([]detailedError)
-		temp := true
+		errs, _ := errors.Data(err)["actions"].([]detailedError)
+		temp := len(errs) > 0
 		for _, actionErr := range errs {
 			temp = temp && isTemporary(actionErr.errorInfo, nil)
 		}
@@ -74,10 +95,17 @@ var (
 		errLeaderElection:            errorInfo{503, "CH008", "Electing a new leader for the core; try again soon"},
 		errNotAuthenticated:          errorInfo{401, "CH009", "Request could not be authenticated"},
 		txbuilder.ErrMissingFields:   errorInfo{400, "CH010", "One or more fields are missing"},
+		txbuilder.ErrOverloaded:      errorInfo{503, txbuilder.OverloadedCode, "The generator has too many pending transactions; retry later"},
+		txbuilder.ErrHalted:          errorInfo{503, txbuilder.HaltedCode, "The generator has been halted by its operator"},
+		errIdempotencyKeyReused:      errorInfo{422, "CH013", "Idempotency key was already used with a different request"},
+		errIdempotentInProgress:      errorInfo{409, "CH014", "A request with this idempotency key is still in progress; retry later"},
 		asset.ErrDuplicateAlias:      errorInfo{400, "CH050", "Alias already exists"},
 		account.ErrDuplicateAlias:    errorInfo{400, "CH050", "Alias already exists"},
 		txfeed.ErrDuplicateAlias:     errorInfo{400, "CH050", "Alias already exists"},
 		mockhsm.ErrDuplicateKeyAlias: errorInfo{400, "CH050", "Alias already exists"},
+		asset.ErrBadCosigner:         errorInfo{400, "CH051", "Invalid asset cosigner"},
+		asset.ErrBadDefinition:       errorInfo{400, "CH052", "Invalid asset definition"},
+		asset.ErrBadAmount:           errorInfo{400, "CH053", "Invalid display amount"},
 
 		// Core error namespace
 		errUnconfigured:                errorInfo{400, "CH100", "This core still needs to be configured"},
@@ -90,8 +118,19 @@ var (
 		config.ErrBadSignerPubkey:      errorInfo{400, "CH107", "Block signer pubkey is invalid"},
 		config.ErrBadQuorum:            errorInfo{400, "CH108", "Quorum must be greater than 0 if there are signers"},
 		errProdReset:                   errorInfo{400, "CH110", "Reset can only be called in a development system"},
+		errSandboxNotGenerator:         errorInfo{400, "CH111", "Sandbox reset can only be called on a generator"},
+		errSuppliesUntracked:           errorInfo{400, "CH112", "This core doesn't track asset supplies"},
+		errNotGenerator:                errorInfo{400, "CH113", "Only a generator can be halted or resumed"},
 		errNoClientTokens:              errorInfo{400, "CH120", "Cannot enable client authentication with no client tokens"},
+		rpc.ErrBadSignature:            errorInfo{401, "CH121", "Request signature from peer core is invalid"},
 		blocksigner.ErrConsensusChange: errorInfo{400, "CH150", "Refuse to sign block with consensus change"},
+		blocksigner.ErrUnknownSoftFork: errorInfo{400, "CH151", "Refuse to sign block signaling an unknown soft fork"},
+		blocksigner.ErrDoubleSign:      errorInfo{400, "CH152", "Refuse to sign a second block at the same height"},
+		blocksigner.ErrStaleBlock:      errorInfo{400, "CH153", "Refuse to sign a block before the last signed block"},
+		protocol.ErrClockSkew:          errorInfo{400, "CH154", "Block timestamp is too far from this core's clock"},
+		protocol.ErrBadBlock:           errorInfo{400, "CH155", "Block violates the consensus rules"},
+		blocksigner.ErrNonCanonical:    errorInfo{400, "CH156", "Refuse to sign a block whose transactions are not in canonical order"},
+		blocksigner.ErrPolicy:          errorInfo{400, "CH157", "Refuse to sign a block that violates this signer's policy"},
 
 		// Signers error namespace (2xx)
 		signers.ErrBadQuorum: errorInfo{400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
@@ -99,12 +138,19 @@ var (
 		signers.ErrNoXPubs:   errorInfo{400, "CH202", "At least one xpub is required"},
 		signers.ErrBadType:   errorInfo{400, "CH203", "Retrieved type does not match expected type"},
 		signers.ErrDupeXPub:  errorInfo{400, "CH204", "Root XPubs cannot contain the same key more than once"},
+		signers.ErrDupeKeys:  errorInfo{400, "CH205", "Another signer already has the same root xpubs and quorum"},
 
 		// Access token error namespace (3xx)
-		accesstoken.ErrBadID:       errorInfo{400, "CH300", "Malformed or empty access token id"},
-		accesstoken.ErrBadType:     errorInfo{400, "CH301", "Access tokens must be type client or network"},
-		accesstoken.ErrDuplicateID: errorInfo{400, "CH302", "Access token id is already in use"},
-		errCurrentToken:            errorInfo{400, "CH310", "The access token used to authenticate this request cannot be deleted"},
+		accesstoken.ErrBadID:        errorInfo{400, "CH300", "Malformed or empty access token id"},
+		accesstoken.ErrBadType:      errorInfo{400, "CH301", "Access tokens must be type client or network"},
+		accesstoken.ErrDuplicateID:  errorInfo{400, "CH302", "Access token id is already in use"},
+		accesstoken.ErrBadQuota:     errorInfo{400, "CH303", "Priority quota must not be negative"},
+		accesstoken.ErrBadTenant:    errorInfo{400, "CH304", "Malformed tenant id"},
+		accesstoken.ErrBadKey:       errorInfo{400, "CH305", "Invalid or already registered peer public key"},
+		accesstoken.ErrBadRateLimit: errorInfo{400, "CH306", "Rate limit must not be negative"},
+		errCurrentToken:             errorInfo{400, "CH310", "The access token used to authenticate this request cannot be deleted"},
+		errOtherTenant:              errorInfo{403, "CH311", "Access tokens may only act for their own tenant"},
+		errCurrentTokenQuota:        errorInfo{400, "CH312", "The access token used to authenticate this request cannot set its own priority quota"},
 
 		// Query error namespace (6xx)
 		query.ErrBadAfter:               errorInfo{400, "CH600", "Malformed pagination parameter `after`"},
@@ -113,29 +159,98 @@ var (
 
 		// Transaction error namespace (7xx)
 		// Build error namespace (70x)
-		txbuilder.ErrBadRefData: errorInfo{400, "CH700", "Reference data does not match previous transaction's reference data"},
-		errBadActionType:        errorInfo{400, "CH701", "Invalid action type"},
-		errBadAlias:             errorInfo{400, "CH702", "Invalid alias on action"},
-		errBadAction:            errorInfo{400, "CH703", "Invalid action object"},
-		txbuilder.ErrBadAmount:  errorInfo{400, "CH704", "Invalid asset amount"},
-		txbuilder.ErrBlankCheck: errorInfo{400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
-		txbuilder.ErrAction:     errorInfo{400, "CH706", "One or more actions had an error: see attached data"},
+		txbuilder.ErrBadRefData:   errorInfo{400, "CH700", "Reference data does not match previous transaction's reference data"},
+		errBadActionType:          errorInfo{400, "CH701", "Invalid action type"},
+		errBadAlias:               errorInfo{400, "CH702", "Invalid alias on action"},
+		errBadAction:              errorInfo{400, "CH703", "Invalid action object"},
+		txbuilder.ErrBadAmount:    errorInfo{400, "CH704", "Invalid asset amount"},
+		txbuilder.ErrBlankCheck:   errorInfo{400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
+		txbuilder.ErrAction:       errorInfo{400, "CH706", "One or more actions had an error: see attached data"},
+		txbuilder.ErrBadAmendment: errorInfo{400, "CH707", "Invalid template amendment"},
+		errAmendSubmitted:         errorInfo{400, "CH708", "Transaction was already submitted and can't be amended"},
 
 		// Submit error namespace (73x)
-		txbuilder.ErrMissingRawTx:          errorInfo{400, "CH730", "Missing raw transaction"},
-		txbuilder.ErrBadInstructionCount:   errorInfo{400, "CH731", "Too many signing instructions in template for transaction"},
-		txbuilder.ErrBadTxInputIdx:         errorInfo{400, "CH732", "Invalid transaction input index"},
-		txbuilder.ErrBadWitnessComponent:   errorInfo{400, "CH733", "Invalid witness component"},
+		signing.ErrMissingRawTx:            errorInfo{400, "CH730", "Missing raw transaction"},
+		signing.ErrBadInstructionCount:     errorInfo{400, "CH731", "Too many signing instructions in template for transaction"},
+		signing.ErrBadTxInputIdx:           errorInfo{400, "CH732", "Invalid transaction input index"},
+		signing.ErrBadWitnessComponent:     errorInfo{400, "CH733", "Invalid witness component"},
+		validation.ErrBadTx:                errorInfo{400, txbuilder.InvalidTxCode, "Invalid transaction"},
 		txbuilder.ErrRejected:              errorInfo{400, "CH735", "Transaction rejected"},
 		txbuilder.ErrNoTxSighashCommitment: errorInfo{400, "CH736", "Transaction is not final, additional actions still allowed"},
+		mempool.ErrBadPriority:             errorInfo{400, "CH737", "Invalid transaction priority"},
+		errNoPriorityQuota:                 errorInfo{400, "CH738", "Access token has no quota for high-priority transactions"},
+		approval.ErrPending:                errorInfo{400, "CH739", "Transaction awaits approval by a second operator"},
+		approval.ErrRejected:               errorInfo{400, "CH740", "Transaction was rejected by an approver"},
+		approval.ErrSameOperator:           errorInfo{403, "CH741", "Transactions must be approved with a second access token"},
+		approval.ErrDecided:                errorInfo{400, "CH742", "Transaction has already been approved or rejected"},
+		approval.ErrBadThreshold:           errorInfo{400, "CH743", "Invalid approval threshold"},
+		txbuilder.ErrCosign:                errorInfo{400, "CH744", "Transaction was not cosigned by an asset's cosigner"},
+		txbuilder.ErrTxExpired:             errorInfo{400, "CH745", "Transaction has expired"},
+		protocol.ErrDuplicateIssuance:      errorInfo{400, "CH746", "Transaction repeats an issuance already on the blockchain"},
+		deadletter.ErrClosed:               errorInfo{400, "CH747", "Submission failure has already been resolved or dismissed"},
+		standard.ErrNonstandard:            errorInfo{400, "CH748", "Transaction violates this network's standardness policy"},
+		standard.ErrDust:                   errorInfo{400, "CH749", "Transaction output is below the minimum amount for its asset"},
+		txbuilder.ErrWrongBlockchain:       errorInfo{400, "CH750", "Transaction is for a different blockchain network"},
+		signing.ErrNoCommitment:            errorInfo{400, "CH751", "Transaction template has no build commitment for a signature"},
+		signing.ErrTemplateAltered:         errorInfo{400, "CH752", "Transaction was altered after it was built"},
+		mempool.ErrChainTooDeep:            errorInfo{400, "CH753", "Transaction has too many unconfirmed ancestors"},
+		approval.ErrLoosenThreshold:        errorInfo{403, "CH754", "Only the default tenant may raise or remove an approval threshold"},
 
 		// account action error namespace (76x)
-		account.ErrInsufficient: errorInfo{400, "CH760", "Insufficient funds for tx"},
-		account.ErrReserved:     errorInfo{400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrInsufficient:    errorInfo{400, "CH760", "Insufficient funds for tx"},
+		account.ErrReserved:        errorInfo{400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrOverLimit:       errorInfo{400, "CH762", "Transaction exceeds an account spending limit"},
+		account.ErrBadLimit:        errorInfo{400, "CH763", "Invalid account spending limit"},
+		account.ErrWatchOnly:       errorInfo{400, "CH764", "Watch-only accounts cannot spend"},
+		account.ErrBadImport:       errorInfo{400, "CH765", "Invalid control program import"},
+		account.ErrBadPayment:      errorInfo{400, "CH766", "Invalid payment in transfer batch"},
+		account.ErrBadSubscription: errorInfo{400, "CH767", "Invalid balance subscription"},
+		account.ErrBadParent:       errorInfo{400, "CH768", "Invalid parent account"},
+		account.ErrLoosenLimit:     errorInfo{403, "CH769", "Only the default tenant may raise or remove an account spending limit"},
+
+		// HTLC error namespace (77x)
+		htlc.ErrBadContract: errorInfo{400, "CH770", "Invalid hash-timelock contract"},
+		htlc.ErrBadPreimage: errorInfo{400, "CH771", "Preimage is the wrong size or does not match the contract's hash"},
+
+		// Contract source error namespace (78x)
+		contract.ErrUnknownCompiler: errorInfo{400, "CH780", "Unknown contract compiler"},
+		contract.ErrMismatch:        errorInfo{400, "CH781", "Contract source does not compile to the program"},
+
+		// Scheduled payment error namespace (79x)
+		schedule.ErrBadSchedule: errorInfo{400, "CH790", "Invalid scheduled payment"},
+		schedule.ErrNotActive:   errorInfo{400, "CH791", "Scheduled payment is not active"},
 
 		// Mock HSM error namespace (80x)
 		mockhsm.ErrInvalidAfter:         errorInfo{400, "CH801", "Invalid `after` in query"},
 		mockhsm.ErrTooManyAliasesToList: errorInfo{400, "CH802", "Too many aliases to list"},
+		mockhsm.ErrDuplicateKey:         errorInfo{400, "CH803", "Key already exists"},
+		keystore.ErrBadPassphrase:       errorInfo{400, "CH804", "Wrong passphrase for key file"},
+		keystore.ErrBadKeyFile:          errorInfo{400, "CH805", "Invalid key file"},
+		mockhsm.ErrBadKEK:               errorInfo{400, "CH806", "Invalid key-encryption key"},
+		mockhsm.ErrBadBackup:            errorInfo{400, "CH807", "Invalid key backup"},
+
+		// Encrypted reference data error namespace (81x)
+		refdata.ErrBadEnvelope: errorInfo{400, "CH810", "Invalid encrypted reference data"},
+		refdata.ErrNoKey:       errorInfo{404, "CH811", "No key for encrypted reference data"},
+		refdata.ErrBadKey:      errorInfo{400, "CH812", "Reference data key does not match its ID"},
+
+		// Freeze error namespace (82x)
+		freeze.ErrFrozen:    errorInfo{400, "CH820", "Transaction moves a frozen asset"},
+		freeze.ErrBadFreeze: errorInfo{400, "CH821", "Invalid asset freeze"},
+		freeze.ErrNotFrozen: errorInfo{400, "CH822", "Asset is not frozen"},
+
+		// Signing request error namespace (83x)
+		signreq.ErrBadTemplate: errorInfo{400, "CH830", "Invalid template for signing request"},
+		signreq.ErrNotSigner:   errorInfo{400, "CH831", "Key does not sign the transaction"},
+		signreq.ErrClosed:      errorInfo{400, "CH832", "Signing request is no longer pending"},
+		errNoSigningRequests:   errorInfo{400, "CH833", "This core doesn't track signing requests"},
+
+		// Risk scoring error namespace (84x)
+		errNoRiskScores: errorInfo{400, "CH840", "This core doesn't score transactions"},
+
+		// Logging error namespace (85x)
+		log.ErrBadLevel:     errorInfo{400, "CH850", "Invalid log level"},
+		log.ErrBadSubsystem: errorInfo{400, "CH851", "Invalid log subsystem"},
 	}
 )
 
@@ -151,7 +266,7 @@ func errInfo(err error) (body detailedError, info errorInfo) {
 	defer func() {
 		if err := recover(); err != nil {
 			info = infoInternal
-			body = detailedError{infoInternal, "", nil, true}
+			body = detailedError{infoInternal, "", nil, true, errorHints[infoInternal.ChainCode]}
 		}
 	}()
 	info, ok := errorInfoTab[root]
@@ -164,6 +279,7 @@ func errInfo(err error) (body detailedError, info errorInfo) {
 		Detail:    errors.Detail(err),
 		Data:      errors.Data(err),
 		Temporary: isTemporary(info, err),
+		Hint:      errorHints[info.ChainCode],
 	}
 	return body, info
 }
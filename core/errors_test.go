@@ -34,3 +34,30 @@ func TestErrInfo(t *testing.T) {
 type sliceError []int
 
 func (err sliceError) Error() string { return "slice error" }
+
+func TestErrorCatalog(t *testing.T) {
+	byCode := make(map[string]errorInfo)
+	for err, info := range errorInfoTab {
+		if prev, ok := byCode[info.ChainCode]; ok && prev != info {
+			t.Errorf("%s (%v) is %+v, but also %+v", info.ChainCode, err, info, prev)
+		}
+		byCode[info.ChainCode] = info
+	}
+
+	catalog := errorCatalog()
+	if len(catalog) != len(byCode)+1 {
+		t.Errorf("catalog has %d codes, want %d", len(catalog), len(byCode)+1)
+	}
+	listed := make(map[string]bool)
+	for i, e := range catalog {
+		if i > 0 && catalog[i-1].ChainCode >= e.ChainCode {
+			t.Errorf("catalog code %s follows %s", e.ChainCode, catalog[i-1].ChainCode)
+		}
+		listed[e.ChainCode] = true
+	}
+	for code := range errorHints {
+		if !listed[code] {
+			t.Errorf("hint for %s, which is not in the catalog", code)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package events publishes confirmed blocks and transactions
+// to a message bus, such as Kafka or NATS.
+//
+// Events are published by a mirror.Hook, so each block's events
+// are published in order of height, and at least once: after a
+// failure, a block's events are published again from the start.
+// Consumers should use the IDs in events to discard duplicates.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/core/mirror"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// SchemaVersion is the version of the event payloads.
+// It's incremented whenever a field is removed or its meaning
+// changes. Fields may be added without changing it.
+const SchemaVersion = 1
+
+// ErrBadPartition is returned by Hook for an unknown
+// Config.PartitionBy.
+var ErrBadPartition = errors.New("bad event partition")
+
+// Ways to partition transaction events.
+const (
+	ByAsset   = "asset"
+	ByAccount = "account"
+)
+
+// A Publisher sends a payload to a topic of a message bus.
+// Payloads with the same key must be delivered in the order
+// they're published; the bus may use the key to choose a
+// partition. Publish must not return until the bus has
+// accepted the payload.
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, payload []byte) error
+}
+
+// Config says where events are published.
+type Config struct {
+	// BlockTopic and TxTopic are the topics
+	// for block and transaction events.
+	BlockTopic string
+	TxTopic    string
+
+	// PartitionBy is ByAsset or ByAccount. A transaction event is
+	// published once with each asset ID, or each account ID, in
+	// the transaction as its key, so a consumer following an asset
+	// or account sees all its transactions in order. Transactions
+	// with no accounts in this core are published with their own
+	// ID as the key.
+	PartitionBy string
+
+	// AccountIDs returns the IDs of the accounts controlling
+	// programs, keyed by string(program). It's required
+	// when PartitionBy is ByAccount.
+	AccountIDs func(ctx context.Context, programs [][]byte) (map[string]string, error)
+}
+
+// BlockEvent is the payload of a block event.
+type BlockEvent struct {
+	SchemaVersion  int       `json:"schema_version"`
+	Type           string    `json:"type"`
+	ID             bc.Hash   `json:"id"`
+	Height         uint64    `json:"height"`
+	Timestamp      time.Time `json:"timestamp"`
+	TransactionIDs []bc.Hash `json:"transaction_ids"`
+}
+
+// TxEvent is the payload of a transaction event.
+type TxEvent struct {
+	SchemaVersion int        `json:"schema_version"`
+	Type          string     `json:"type"`
+	ID            bc.Hash    `json:"id"`
+	BlockID       bc.Hash    `json:"block_id"`
+	BlockHeight   uint64     `json:"block_height"`
+	Position      int        `json:"position"`
+	Timestamp     time.Time  `json:"timestamp"`
+	Inputs        []TxAction `json:"inputs"`
+	Outputs       []TxAction `json:"outputs"`
+}
+
+// TxAction describes an input or output in a TxEvent.
+// Type is "issue" or "spend" for an input, and
+// "control" or "retire" for an output.
+type TxAction struct {
+	Type      string     `json:"type"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Amount    uint64     `json:"amount"`
+	AccountID string     `json:"account_id,omitempty"`
+}
+
+// Hook returns a mirror.Hook publishing the events
+// for each block to pub, as configured by conf.
+func Hook(pub Publisher, conf Config) (mirror.Hook, error) {
+	switch conf.PartitionBy {
+	case ByAsset:
+	case ByAccount:
+		if conf.AccountIDs == nil {
+			return nil, errors.WithDetail(ErrBadPartition, "partitioning by account needs account lookup")
+		}
+	default:
+		return nil, errors.WithDetailf(ErrBadPartition, "unknown partition %q", conf.PartitionBy)
+	}
+	return func(ctx context.Context, b *bc.Block) error {
+		return publishBlock(ctx, pub, conf, b)
+	}, nil
+}
+
+func publishBlock(ctx context.Context, pub Publisher, conf Config, b *bc.Block) error {
+	var accounts map[string]string
+	if conf.PartitionBy == ByAccount {
+		var progs [][]byte
+		for _, tx := range b.Transactions {
+			for _, in := range tx.Inputs {
+				if !in.IsIssuance() {
+					progs = append(progs, in.ControlProgram())
+				}
+			}
+			for _, out := range tx.Outputs {
+				progs = append(progs, out.ControlProgram)
+			}
+		}
+		var err error
+		accounts, err = conf.AccountIDs(ctx, progs)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Publish the transactions before the block, so that a
+	// consumer seeing a block event has seen all its transactions.
+	for i, tx := range b.Transactions {
+		ev := txEvent(b, i, accounts)
+		payload, err := json.Marshal(ev)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		for _, key := range partitionKeys(ev, conf.PartitionBy) {
+			err = pub.Publish(ctx, conf.TxTopic, key, payload)
+			if err != nil {
+				return errors.Wrapf(err, "publishing tx %s", tx.Hash)
+			}
+		}
+	}
+
+	ev := BlockEvent{
+		SchemaVersion:  SchemaVersion,
+		Type:           "block",
+		ID:             b.Hash(),
+		Height:         b.Height,
+		Timestamp:      b.Time(),
+		TransactionIDs: make([]bc.Hash, 0, len(b.Transactions)),
+	}
+	for _, tx := range b.Transactions {
+		ev.TransactionIDs = append(ev.TransactionIDs, tx.Hash)
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	// Block events all go to one partition, to stay in order.
+	err = pub.Publish(ctx, conf.BlockTopic, "blocks", payload)
+	return errors.Wrapf(err, "publishing block %d", b.Height)
+}
+
+// txEvent returns the event for the transaction at position pos
+// in b. Accounts, if non-nil, maps control programs to account IDs.
+func txEvent(b *bc.Block, pos int, accounts map[string]string) *TxEvent {
+	tx := b.Transactions[pos]
+	ev := &TxEvent{
+		SchemaVersion: SchemaVersion,
+		Type:          "transaction",
+		ID:            tx.Hash,
+		BlockID:       b.Hash(),
+		BlockHeight:   b.Height,
+		Position:      pos,
+		Timestamp:     b.Time(),
+		Inputs:        make([]TxAction, 0, len(tx.Inputs)),
+		Outputs:       make([]TxAction, 0, len(tx.Outputs)),
+	}
+	for _, in := range tx.Inputs {
+		a := TxAction{Type: "issue", AssetID: in.AssetID(), Amount: in.Amount()}
+		if !in.IsIssuance() {
+			a.Type = "spend"
+			a.AccountID = accounts[string(in.ControlProgram())]
+		}
+		ev.Inputs = append(ev.Inputs, a)
+	}
+	for _, out := range tx.Outputs {
+		a := TxAction{Type: "control", AssetID: out.AssetID, Amount: out.Amount}
+		if vmutil.IsUnspendable(out.ControlProgram) {
+			a.Type = "retire"
+		} else {
+			a.AccountID = accounts[string(out.ControlProgram)]
+		}
+		ev.Outputs = append(ev.Outputs, a)
+	}
+	return ev
+}
+
+// partitionKeys returns the distinct asset or account IDs
+// in ev, in the order they first appear.
+func partitionKeys(ev *TxEvent, by string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(a TxAction) {
+		key := a.AssetID.String()
+		if by == ByAccount {
+			key = a.AccountID
+		}
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, a := range ev.Inputs {
+		add(a)
+	}
+	for _, a := range ev.Outputs {
+		add(a)
+	}
+	if len(keys) == 0 {
+		keys = append(keys, ev.ID.String())
+	}
+	return keys
+}
@@ -0,0 +1,234 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+type published struct {
+	topic, key string
+	payload    []byte
+}
+
+type recorder []published
+
+func (r *recorder) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	*r = append(*r, published{topic, key, payload})
+	return nil
+}
+
+func testBlock() *bc.Block {
+	issuance := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{1}, 10, nil, bc.Hash{}, []byte{byte(vm.OP_TRUE)}, nil)},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(bc.AssetID{1}, 7, []byte("alice"), nil),
+			bc.NewTxOutput(bc.AssetID{1}, 3, []byte{byte(vm.OP_FAIL)}, nil),
+		},
+	})
+	transfer := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(issuance.Hash, 0, nil, bc.AssetID{1}, 7, []byte("alice"), nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{1}, 7, []byte("bob"), nil)},
+	})
+	return &bc.Block{
+		BlockHeader:  bc.BlockHeader{Version: 1, Height: 2, TimestampMS: 1000},
+		Transactions: []*bc.Tx{issuance, transfer},
+	}
+}
+
+func TestPublishBlock(t *testing.T) {
+	ctx := context.Background()
+	b := testBlock()
+	accountIDs := func(ctx context.Context, progs [][]byte) (map[string]string, error) {
+		return map[string]string{"alice": "acc1", "bob": "acc2"}, nil
+	}
+
+	cases := []struct {
+		by       string
+		wantKeys []string
+	}{
+		{ByAsset, []string{
+			b.Transactions[0].Inputs[0].AssetID().String(),
+			bc.AssetID{1}.String(),
+			bc.AssetID{1}.String(),
+			"blocks",
+		}},
+		{ByAccount, []string{"acc1", "acc1", "acc2", "blocks"}},
+	}
+	for _, c := range cases {
+		var r recorder
+		hook, err := Hook(&r, Config{BlockTopic: "blocks", TxTopic: "txs", PartitionBy: c.by, AccountIDs: accountIDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = hook(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		for _, p := range r {
+			keys = append(keys, p.key)
+		}
+		if !reflect.DeepEqual(keys, c.wantKeys) {
+			t.Errorf("partition by %s: got keys %v, want %v", c.by, keys, c.wantKeys)
+		}
+
+		var blockEv BlockEvent
+		last := r[len(r)-1]
+		err = json.Unmarshal(last.payload, &blockEv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.topic != "blocks" || blockEv.Height != 2 || len(blockEv.TransactionIDs) != 2 {
+			t.Errorf("partition by %s: got block event %s to %s", c.by, last.payload, last.topic)
+		}
+	}
+
+	var r recorder
+	hook, err := Hook(&r, Config{BlockTopic: "blocks", TxTopic: "txs", PartitionBy: ByAccount, AccountIDs: accountIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txEv TxEvent
+	err = json.Unmarshal(r[2].payload, &txEv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TxEvent{
+		SchemaVersion: SchemaVersion,
+		Type:          "transaction",
+		ID:            b.Transactions[1].Hash,
+		BlockID:       b.Hash(),
+		BlockHeight:   2,
+		Position:      1,
+		Timestamp:     b.Time(),
+		Inputs:        []TxAction{{Type: "spend", AssetID: bc.AssetID{1}, Amount: 7, AccountID: "acc1"}},
+		Outputs:       []TxAction{{Type: "control", AssetID: bc.AssetID{1}, Amount: 7, AccountID: "acc2"}},
+	}
+	if !reflect.DeepEqual(txEv, want) {
+		t.Errorf("got tx event %+v, want %+v", txEv, want)
+	}
+}
+
+func TestHookBadPartition(t *testing.T) {
+	_, err := Hook(new(recorder), Config{PartitionBy: "color"})
+	if errors.Root(err) != ErrBadPartition {
+		t.Errorf("got error %v, want ErrBadPartition", err)
+	}
+	_, err = Hook(new(recorder), Config{PartitionBy: ByAccount})
+	if errors.Root(err) != ErrBadPartition {
+		t.Errorf("got error %v, want ErrBadPartition", err)
+	}
+}
+
+func TestKafkaREST(t *testing.T) {
+	var got struct {
+		path, contentType string
+		body              string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		got.path, got.contentType, got.body = req.URL.Path, req.Header.Get("Content-Type"), string(b)
+		if strings.Contains(got.body, "fail") {
+			io.WriteString(w, `{"offsets":[{"error_code":50003,"error":"broker unavailable"}]}`)
+			return
+		}
+		io.WriteString(w, `{"offsets":[{"partition":0,"offset":1}]}`)
+	}))
+	defer srv.Close()
+
+	k := &KafkaREST{URL: srv.URL + "/"}
+	err := k.Publish(context.Background(), "txs", "acc1", []byte(`{"id":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/topics/txs" {
+		t.Errorf("got path %s, want /topics/txs", got.path)
+	}
+	if got.contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("got content type %s", got.contentType)
+	}
+	const wantBody = `{"records":[{"key":"acc1","value":{"id":"x"}}]}`
+	if got.body != wantBody {
+		t.Errorf("got body %s, want %s", got.body, wantBody)
+	}
+
+	err = k.Publish(context.Background(), "txs", "acc1", []byte(`"fail"`))
+	if err == nil {
+		t.Error("got no error for a record the proxy failed to write")
+	}
+}
+
+func TestNATS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB":
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				io.ReadFull(r, payload)
+				msgs <- fields[1] + " " + string(payload[:n])
+			}
+		}
+	}()
+
+	n, err := NewNATS("nats://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Publish(context.Background(), "txs", "acc1", []byte(`{"id":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `txs.acc1 {"id":"x"}`
+	if got := <-msgs; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, err = NewNATS("http://example.com")
+	if err == nil {
+		t.Error("got no error for a URL that isn't nats://")
+	}
+}
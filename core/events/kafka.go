@@ -0,0 +1,77 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"chain/errors"
+)
+
+// KafkaREST publishes to Kafka through a Kafka REST proxy,
+// using its v2 API with JSON records. Kafka chooses each
+// record's partition by hashing its key.
+type KafkaREST struct {
+	// URL is the base URL of the REST proxy.
+	URL string
+
+	// Client is the HTTP client to use.
+	// If it's nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Publish implements Publisher.
+func (k *KafkaREST) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	type record struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	body, err := json.Marshal(struct {
+		Records []record `json:"records"`
+	}{[]record{{key, payload}}})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	u := strings.TrimRight(k.URL, "/") + "/topics/" + topic
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "posting to Kafka REST proxy")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Kafka REST proxy responded %s: %s", resp.Status, result.Message)
+	}
+	if err != nil {
+		return errors.Wrap(err, "decoding Kafka REST proxy response")
+	}
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("Kafka error %d: %s", *o.ErrorCode, o.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"chain/errors"
+)
+
+// NATS publishes to a NATS server. NATS subjects have no
+// partitions, so each payload is published to the subject
+// topic.key, and consumers subscribe to topic.> or to the
+// subjects for particular keys.
+//
+// After each payload, NATS waits for the server to answer a
+// ping, which it does only once it's processed the payload.
+type NATS struct {
+	addr string
+	user *url.Userinfo
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewNATS returns a NATS publisher for the server at rawurl,
+// of the form nats://[user:password@]host:port. It connects
+// on first use, and again after any error.
+func NewNATS(rawurl string) (*NATS, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing NATS URL")
+	}
+	if u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("bad NATS URL %q", rawurl)
+	}
+	return &NATS{addr: u.Host, user: u.User}, nil
+}
+
+// Publish implements Publisher.
+func (n *NATS) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	err := n.publish(ctx, topic+"."+key, payload)
+	if err != nil && n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	return err
+}
+
+func (n *NATS) publish(ctx context.Context, subject string, payload []byte) error {
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("bad NATS subject %q", subject)
+	}
+	if n.conn == nil {
+		err := n.connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		n.conn.SetDeadline(deadline)
+	} else {
+		n.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	_, err := fmt.Fprintf(n.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload)
+	if err != nil {
+		return errors.Wrap(err, "writing to NATS")
+	}
+	return n.awaitPong()
+}
+
+func (n *NATS) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return errors.Wrap(err, "connecting to NATS")
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	n.conn, n.r = conn, bufio.NewReader(conn)
+
+	// The server starts with an INFO line.
+	line, err := n.r.ReadString('\n')
+	if err != nil {
+		return errors.Wrap(err, "reading NATS server info")
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "chain-core"}
+	if n.user != nil {
+		opts["user"] = n.user.Username()
+		if pw, ok := n.user.Password(); ok {
+			opts["pass"] = pw
+		}
+	}
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	_, err = fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", b)
+	if err != nil {
+		return errors.Wrap(err, "writing to NATS")
+	}
+	return n.awaitPong()
+}
+
+// awaitPong reads from the server until it gets a PONG,
+// answering the server's own pings along the way.
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return errors.Wrap(err, "reading from NATS")
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			_, err = fmt.Fprint(n.conn, "PONG\r\n")
+			if err != nil {
+				return errors.Wrap(err, "writing to NATS")
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// Ignore anything else, such as +OK and INFO.
+	}
+}
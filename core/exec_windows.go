@@ -8,17 +8,19 @@ import "os"
 const (
 	WinCodeResetBlockchain = 101
 	WinCodeResetEverything = 102
+	WinCodeResetSandbox    = 103
 )
 
 var WinResetCodeToEnv = map[uint32]string{
 	WinCodeResetBlockchain: "blockchain",
 	WinCodeResetEverything: "everything",
+	WinCodeResetSandbox:    "sandbox",
 }
 
 // execSelf assumes the current process is a child of another cored process,
 // which is serving as a monitor.
 // We simply exit forcefully, and the monitor will restart this program.
-// dataToReset should be "blockchain" or "everything" or "".
+// dataToReset should be "blockchain", "everything", "sandbox", or "".
 // (Any other value is treated like "").
 func execSelf(dataToReset string) {
 	switch dataToReset {
@@ -26,6 +28,8 @@ func execSelf(dataToReset string) {
 		os.Exit(WinCodeResetBlockchain)
 	case "everything":
 		os.Exit(WinCodeResetEverything)
+	case "sandbox":
+		os.Exit(WinCodeResetSandbox)
 	}
 	os.Exit(0)
 }
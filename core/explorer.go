@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/explorer"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// explorerPrefix is the path prefix of the block explorer
+// endpoints. If Handler.PublicExplorer is set, they can be
+// used without an access token.
+const explorerPrefix = "/explorer/"
+
+// POST /explorer/get-block
+//
+// The block may be given by height or ID.
+func (h *Handler) explorerGetBlock(ctx context.Context, in struct {
+	Height *uint64  `json:"height"`
+	ID     *bc.Hash `json:"id"`
+}) (*explorer.Block, error) {
+	switch {
+	case in.ID != nil:
+		return h.Explorer.BlockByID(ctx, *in.ID)
+	case in.Height != nil:
+		return h.Explorer.Block(ctx, *in.Height)
+	}
+	return nil, errors.WithDetail(httpjson.ErrBadRequest, "height or id is required")
+}
+
+// POST /explorer/get-transaction
+func (h *Handler) explorerGetTransaction(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) (map[string]interface{}, error) {
+	tx, err := h.Explorer.Transaction(ctx, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	tx["confirmations"], tx["final"] = h.finality().of(tx["block_height"])
+	return tx, nil
+}
+
+// POST /explorer/get-asset
+//
+// Responds with the asset's definition and issuance program
+// and, if the core tracks them, its issued, retired and
+// circulating amounts.
+func (h *Handler) explorerGetAsset(ctx context.Context, in struct {
+	ID bc.AssetID `json:"id"`
+}) (map[string]interface{}, error) {
+	asset, err := h.Explorer.Asset(ctx, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, snapshot := h.Chain.State()
+	if snapshot != nil && snapshot.Supplies != nil {
+		s := snapshot.Supplies[in.ID]
+		asset["issued"] = s.Issued
+		asset["retired"] = s.Retired
+		asset["circulating"] = s.Circulating()
+	}
+	return asset, nil
+}
+
+// POST /explorer/list-program-history
+//
+// Responds with the outputs paid to a control program,
+// newest first.
+func (h *Handler) explorerListProgramHistory(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	outputs, after, err := h.Explorer.ProgramHistory(ctx, query.ControlProgram, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(outputs),
+		LastPage: len(outputs) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /explorer/get-stats
+func (h *Handler) explorerGetStats(ctx context.Context) (*explorer.Stats, error) {
+	return h.Explorer.Stats(ctx)
+}
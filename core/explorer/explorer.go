@@ -0,0 +1,261 @@
+// Package explorer answers the read-only queries of a public
+// block explorer: blocks, transactions, assets, the history of a
+// control program, and summary statistics about the blockchain.
+//
+// It reads the blocks table and the query indexes, using only
+// the indexes dedicated to it, and leaves out everything a core
+// knows that isn't on the blockchain, such as account and asset
+// aliases and tags, so its results are safe to serve to anyone.
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"chain/core/query"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// recentBlocks is the number of blocks Stats averages over.
+const recentBlocks = 100
+
+// Explorer queries the blockchain data stored in DB.
+type Explorer struct {
+	DB pg.DB
+}
+
+// Block is a summary of a block.
+type Block struct {
+	ID               bc.Hash            `json:"id"`
+	Height           uint64             `json:"height"`
+	Timestamp        time.Time          `json:"timestamp"`
+	PreviousBlockID  bc.Hash            `json:"previous_block_id"`
+	ConsensusProgram chainjson.HexBytes `json:"consensus_program"`
+	Size             uint64             `json:"size"`
+	TransactionCount int                `json:"transaction_count"`
+	TransactionIDs   []bc.Hash          `json:"transaction_ids"`
+}
+
+// Block returns the block at height.
+func (e *Explorer) Block(ctx context.Context, height uint64) (*Block, error) {
+	const q = `SELECT data, octet_length(data) FROM blocks WHERE height=$1`
+	b, err := e.block(ctx, q, height)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "block height %d", height)
+	}
+	return b, err
+}
+
+// BlockByID returns the block with hash id.
+func (e *Explorer) BlockByID(ctx context.Context, id bc.Hash) (*Block, error) {
+	const q = `SELECT data, octet_length(data) FROM blocks WHERE block_hash=$1`
+	b, err := e.block(ctx, q, id)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "block id %s", id)
+	}
+	return b, err
+}
+
+func (e *Explorer) block(ctx context.Context, q string, arg interface{}) (*Block, error) {
+	var (
+		block bc.Block
+		size  uint64
+	)
+	err := e.DB.QueryRow(ctx, q, arg).Scan(&block, &size)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "querying block")
+	}
+	ids := make([]bc.Hash, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		ids = append(ids, tx.Hash)
+	}
+	return &Block{
+		ID:               block.Hash(),
+		Height:           block.Height,
+		Timestamp:        block.Time(),
+		PreviousBlockID:  block.PreviousBlockHash,
+		ConsensusProgram: block.ConsensusProgram,
+		Size:             size,
+		TransactionCount: len(ids),
+		TransactionIDs:   ids,
+	}, nil
+}
+
+// Transaction returns the annotated transaction with hash id,
+// without its local annotations.
+func (e *Explorer) Transaction(ctx context.Context, id bc.Hash) (map[string]interface{}, error) {
+	const q = `SELECT data FROM annotated_txs WHERE tx_hash=$1`
+	var data []byte
+	err := e.DB.QueryRow(ctx, q, id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction id %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "querying transaction")
+	}
+	return publicObject(data)
+}
+
+// Asset returns the definition and issuance program of
+// the asset with the given ID.
+func (e *Explorer) Asset(ctx context.Context, id bc.AssetID) (map[string]interface{}, error) {
+	const q = `
+		SELECT jsonb_build_object(
+			'id', id,
+			'definition', data->'definition',
+			'issuance_program', data->'issuance_program'
+		) FROM annotated_assets WHERE id=$1
+	`
+	var data []byte
+	err := e.DB.QueryRow(ctx, q, id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "asset id %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "querying asset")
+	}
+	var asset map[string]interface{}
+	err = json.Unmarshal(data, &asset)
+	return asset, errors.Wrap(err)
+}
+
+// ProgramHistory returns the outputs ever paid to control
+// program prog, newest first, without their local annotations.
+// Each has a field "spent" telling whether it has been spent.
+// After is the cursor returned by a previous call, or empty to
+// start with the newest output.
+func (e *Explorer) ProgramHistory(ctx context.Context, prog []byte, after string, limit int) ([]map[string]interface{}, string, error) {
+	height, pos, index := uint64(math.MaxInt64), uint64(math.MaxInt32), uint64(math.MaxInt32)
+	if after != "" {
+		_, err := fmt.Sscanf(after, "%d:%d:%d", &height, &pos, &index)
+		if err != nil || height > math.MaxInt64 || pos > math.MaxInt32 || index > math.MaxInt32 {
+			return nil, "", errors.WithDetailf(query.ErrBadAfter, "%q", after)
+		}
+	}
+
+	const q = `
+		SELECT block_height, tx_pos, output_index, data, NOT upper_inf(timespan)
+		FROM annotated_outputs
+		WHERE data->>'control_program'=$1 AND (block_height, tx_pos, output_index) < ($2, $3, $4)
+		ORDER BY block_height DESC, tx_pos DESC, output_index DESC
+		LIMIT $5
+	`
+	var outputs []map[string]interface{}
+	err := pg.ForQueryRows(ctx, e.DB, q, hex.EncodeToString(prog), height, pos, index, limit,
+		func(h, p, i uint64, data []byte, spent bool) error {
+			out, err := publicObject(data)
+			if err != nil {
+				return err
+			}
+			out["spent"] = spent
+			outputs = append(outputs, out)
+			height, pos, index = h, p, i
+			return nil
+		})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "querying program history")
+	}
+	return outputs, fmt.Sprintf("%d:%d:%d", height, pos, index), nil
+}
+
+// Stats summarizes the blockchain.
+type Stats struct {
+	BlockHeight    uint64    `json:"block_height"`
+	BlockTimestamp time.Time `json:"block_timestamp"`
+	AssetCount     uint64    `json:"asset_count"`
+
+	// The average time between blocks, and the number of
+	// transactions, over the most recent blocks.
+	RecentBlocks           uint64 `json:"recent_blocks"`
+	AverageBlockIntervalMS uint64 `json:"average_block_interval_ms"`
+	RecentTransactionCount uint64 `json:"recent_transaction_count"`
+}
+
+// Stats returns a summary of the blockchain as indexed so far.
+func (e *Explorer) Stats(ctx context.Context) (*Stats, error) {
+	const blocksQ = `
+		SELECT COALESCE(MAX(height), 0), COALESCE(MIN(height), 0),
+			COALESCE(MAX(timestamp), 0), COALESCE(MIN(timestamp), 0)
+		FROM (SELECT height, timestamp FROM query_blocks ORDER BY height DESC LIMIT $1) recent
+	`
+	var maxHeight, minHeight, maxTime, minTime uint64
+	err := e.DB.QueryRow(ctx, blocksQ, recentBlocks).Scan(&maxHeight, &minHeight, &maxTime, &minTime)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying recent blocks")
+	}
+
+	s := &Stats{
+		BlockHeight:    maxHeight,
+		BlockTimestamp: time.Unix(0, int64(maxTime)*int64(time.Millisecond)).UTC(),
+	}
+	if maxHeight == 0 {
+		return s, nil
+	}
+	s.RecentBlocks = maxHeight - minHeight + 1
+	if maxHeight > minHeight {
+		s.AverageBlockIntervalMS = (maxTime - minTime) / (maxHeight - minHeight)
+	}
+
+	const txsQ = `SELECT COUNT(*) FROM annotated_txs WHERE block_height >= $1`
+	err = e.DB.QueryRow(ctx, txsQ, minHeight).Scan(&s.RecentTransactionCount)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting recent transactions")
+	}
+
+	const assetsQ = `SELECT COUNT(*) FROM annotated_assets`
+	err = e.DB.QueryRow(ctx, assetsQ).Scan(&s.AssetCount)
+	return s, errors.Wrap(err, "counting assets")
+}
+
+// localFields are the annotations that come from this core's
+// own records rather than from the blockchain.
+var localFields = []string{"asset_alias", "asset_tags", "asset_is_local", "is_local", "purpose"}
+
+// publicObject decodes the annotated transaction or output
+// in data and removes its local annotations.
+func publicObject(data []byte) (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding annotated object")
+	}
+	stripLocal(obj)
+	for _, k := range []string{"inputs", "outputs"} {
+		items, _ := obj[k].([]interface{})
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				stripLocal(m)
+			}
+		}
+	}
+	return obj, nil
+}
+
+func stripLocal(m map[string]interface{}) {
+	// Reference data this core decrypted is shown
+	// as it is on the blockchain, still encrypted.
+	if enc, ok := m["encrypted_reference_data"]; ok {
+		m["reference_data"] = enc
+		delete(m, "encrypted_reference_data")
+	}
+	for k := range m {
+		if strings.HasPrefix(k, "account_") {
+			delete(m, k)
+		}
+	}
+	for _, k := range localFields {
+		delete(m, k)
+	}
+}
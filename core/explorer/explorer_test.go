@@ -0,0 +1,147 @@
+package explorer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"chain/core/query"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestPublicObject(t *testing.T) {
+	data := []byte(`{
+		"id": "a",
+		"is_local": "yes",
+		"inputs": [{"asset_id": "b", "asset_alias": "gold", "asset_tags": {}, "account_id": "acc1", "account_alias": "alice"}],
+		"outputs": [
+			{"asset_id": "b", "asset_is_local": "yes", "account_tags": {}, "purpose": "change", "control_program": "51"},
+			{"asset_id": "b", "reference_data": {"memo": "secret"}, "encrypted_reference_data": {"encrypted": {"key_id": "k"}}}
+		]
+	}`)
+	got, err := publicObject(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":     "a",
+		"inputs": []interface{}{map[string]interface{}{"asset_id": "b"}},
+		"outputs": []interface{}{
+			map[string]interface{}{"asset_id": "b", "control_program": "51"},
+			map[string]interface{}{"asset_id": "b", "reference_data": map[string]interface{}{
+				"encrypted": map[string]interface{}{"key_id": "k"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("publicObject = %v want %v", got, want)
+	}
+}
+
+func TestExplorer(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	e := &Explorer{DB: db}
+
+	tx := bc.NewTx(bc.TxData{Version: 1, Outputs: []*bc.TxOutput{
+		bc.NewTxOutput(bc.AssetID{1}, 5, []byte{0x51}, nil),
+	}})
+	block := &bc.Block{
+		BlockHeader: bc.BlockHeader{
+			Version:     1,
+			Height:      2,
+			TimestampMS: 2000,
+		},
+		Transactions: []*bc.Tx{tx},
+	}
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO blocks (block_hash, height, data, header) VALUES ($1, $2, $3, $4)
+	`, block.Hash(), block.Height, block, &block.BlockHeader)
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO annotated_assets (id, data, sort_id) VALUES ($1, '{"definition": {"name": "gold"}, "alias": "gold"}', '')
+	`, bc.AssetID{1}.String())
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO annotated_txs (block_height, tx_pos, tx_hash, data)
+		VALUES (2, 0, $1, '{"id": "tx", "outputs": [{"control_program": "51", "account_id": "acc1"}]}')
+	`, tx.Hash.String())
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO query_blocks (height, timestamp) VALUES (1, 1000), (2, 2000);
+		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash, data, timespan) VALUES
+			(1, 0, 0, 'a', '{"control_program": "51", "amount": 1}', int8range(1000, 2000)),
+			(2, 0, 0, 'b', '{"control_program": "51", "amount": 5, "account_id": "acc1"}', int8range(2000, NULL)),
+			(2, 0, 1, 'b', '{"control_program": "52", "amount": 6}', int8range(2000, NULL));
+	`)
+
+	byHeight, err := e.Block(ctx, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	byID, err := e.BlockByID(ctx, block.Hash())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(byHeight, byID) {
+		t.Errorf("Block(2) = %+v, BlockByID = %+v", byHeight, byID)
+	}
+	if byID.ID != block.Hash() || byID.TransactionCount != 1 || byID.TransactionIDs[0] != tx.Hash {
+		t.Errorf("BlockByID = %+v", byID)
+	}
+	_, err = e.Block(ctx, 3)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Block(3) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	gotTx, err := e.Transaction(ctx, tx.Hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantTx := map[string]interface{}{
+		"id":      "tx",
+		"outputs": []interface{}{map[string]interface{}{"control_program": "51"}},
+	}
+	if !reflect.DeepEqual(gotTx, wantTx) {
+		t.Errorf("Transaction = %v want %v", gotTx, wantTx)
+	}
+
+	asset, err := e.Asset(ctx, bc.AssetID{1})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if _, ok := asset["alias"]; ok {
+		t.Errorf("Asset = %v, includes alias", asset)
+	}
+
+	outs, after, err := e.ProgramHistory(ctx, []byte{0x51}, "", 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantOut := map[string]interface{}{"control_program": "51", "amount": float64(5), "spent": false}
+	if len(outs) != 1 || !reflect.DeepEqual(outs[0], wantOut) {
+		t.Errorf("ProgramHistory page 1 = %v want [%v]", outs, wantOut)
+	}
+	outs, _, err = e.ProgramHistory(ctx, []byte{0x51}, after, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantOut = map[string]interface{}{"control_program": "51", "amount": float64(1), "spent": true}
+	if len(outs) != 1 || !reflect.DeepEqual(outs[0], wantOut) {
+		t.Errorf("ProgramHistory page 2 = %v want [%v]", outs, wantOut)
+	}
+	_, _, err = e.ProgramHistory(ctx, []byte{0x51}, "x", 1)
+	if errors.Root(err) != query.ErrBadAfter {
+		t.Errorf("ProgramHistory(bad after) error = %v want %v", err, query.ErrBadAfter)
+	}
+
+	stats, err := e.Stats(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if stats.BlockHeight != 2 || stats.RecentBlocks != 2 || stats.AverageBlockIntervalMS != 1000 ||
+		stats.RecentTransactionCount != 1 || stats.AssetCount != 1 {
+		t.Errorf("Stats = %+v", stats)
+	}
+}
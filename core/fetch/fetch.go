@@ -119,6 +119,7 @@ func Fetch(ctx context.Context, c *protocol.Chain, peer *rpc.Client, health func
 			height++
 			health(nil)
 			nfailures = 0
+			observeGeneratorHeight(height)
 		}
 	}
 }
@@ -196,7 +197,21 @@ func updateGeneratorHeight(ctx context.Context, peer *rpc.Client) {
 	generatorHeightFetchedAt = time.Now()
 }
 
+// observeGeneratorHeight records that the generator has
+// reached at least height. Blocks arrive as soon as the generator
+// makes them, since get-block waits for them, so this keeps
+// GeneratorHeight current between polls.
+func observeGeneratorHeight(height uint64) {
+	generatorLock.Lock()
+	defer generatorLock.Unlock()
+	if height > generatorHeight {
+		generatorHeight = height
+		generatorHeightFetchedAt = time.Now()
+	}
+}
+
 func applyBlock(ctx context.Context, c *protocol.Chain, prevSnap *state.Snapshot, prev *bc.Block, block *bc.Block) (*state.Snapshot, *bc.Block, error) {
+	ctx = log.AddFields(ctx, log.KeyBlockHeight, block.Height)
 	snap, err := c.ValidateBlock(ctx, prevSnap, prev, block)
 	if err != nil {
 		return prevSnap, prev, err
@@ -334,6 +349,8 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, atte
 	// snapshot cannot guarantee uniqueness of issuances until the max
 	// issuance window has elapsed.
 	snapshot.PruneIssuances(math.MaxUint64)
+	// Asset supplies aren't committed to in the block either;
+	// the core takes the generator's.
 
 	// Next, get the initial block.
 	initialBlock, err := getBlock(ctx, peer, 1, getBlockTimeout)
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chain/core/rpc"
+	"chain/protocol"
+	"chain/protocol/mempool"
+	"chain/protocol/memstore"
+	"chain/protocol/prottest"
+	"chain/protocol/state"
+)
+
+func TestGeneratorHeightFromBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	gen := prottest.NewChain(t)
+	b1, err := gen.GetBlock(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, gen)
+
+	// The generator reports a stale height, so GeneratorHeight
+	// can only reach 2 by way of the block fetched at that height.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rpc/block-height":
+			json.NewEncoder(w).Encode(map[string]uint64{"block_height": 1})
+		case "/rpc/get-block":
+			var height uint64
+			json.NewDecoder(req.Body).Decode(&height)
+			if height > gen.Height() {
+				<-req.Context().Done() // wait for a block that never comes
+				return
+			}
+			b, err := gen.GetBlock(req.Context(), height)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			json.NewEncoder(w).Encode(b)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	defer cancel()
+
+	c, err := protocol.NewChain(ctx, b1.Hash(), memstore.New(), mempool.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CommitBlock(ctx, b1, state.Empty())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generatorLock.Lock()
+	generatorHeight = 0
+	generatorLock.Unlock()
+
+	go Fetch(ctx, c, &rpc.Client{BaseURL: server.URL}, func(error) {})
+
+	// Well within heightPollingPeriod.
+	deadline := time.Now().Add(heightPollingPeriod / 2)
+	for {
+		h, _ := GeneratorHeight()
+		if h == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GeneratorHeight() = %d, want 2", h)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c.Height() != 2 {
+		t.Errorf("c.Height() = %d, want 2", c.Height())
+	}
+}
@@ -0,0 +1,197 @@
+// Package forward relays transactions submitted to a Chain Core
+// that isn't the generator on to the generator.
+//
+// A Forwarder saves each transaction before sending it, so that
+// if the generator can't be reached, or answers that it failed
+// only for the moment, the transaction isn't lost: the Forwarder
+// keeps retrying it, backing off, until the generator accepts
+// it, refuses it, or the transaction expires.
+package forward
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/core/rpc"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/mempool"
+)
+
+const (
+	StatusForwarding = "forwarding" // saved, awaiting the generator
+	StatusForwarded  = "forwarded"  // accepted by the generator
+	StatusFailed     = "failed"     // refused by the generator, or expired
+)
+
+// maxBackoff caps the time between attempts
+// to forward a transaction.
+const maxBackoff = 5 * time.Minute
+
+// A Record tracks the forwarding of a transaction.
+type Record struct {
+	TxID      bc.Hash   `json:"transaction_id"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A Forwarder forwards transactions to the generator.
+type Forwarder struct {
+	DB    pg.DB
+	Chain *protocol.Chain
+}
+
+// Submit sends tx to the generator, saving it first. If the
+// generator can't be reached, Submit returns nil and tx is sent
+// again later by Retry. If the generator refuses tx, Submit
+// returns the generator's error.
+func (f *Forwarder) Submit(ctx context.Context, tx *bc.Tx) error {
+	priority := mempool.PriorityNormal
+	if sub, ok := mempool.FromContext(ctx); ok {
+		priority = sub.Priority
+	}
+	const q = `
+		INSERT INTO forwarded_txs (tx_hash, data, priority, status)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+	res, err := f.DB.Exec(ctx, q, tx.Hash.String(), &tx.TxData, priority.String(), StatusForwarding)
+	if err != nil {
+		return errors.Wrap(err, "saving forwarded tx")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	// If tx was already saved, keep it even if the generator
+	// tells this client to back off.
+	return f.attempt(ctx, tx, n == 0)
+}
+
+// attempt sends tx to the generator once, and records the
+// outcome. It returns nil if the generator accepted tx or may yet
+// accept it. If the generator is overloaded or halted, attempt
+// keeps tx to send again if retrying is true; otherwise it returns
+// the generator's error and forgets tx, so the client, told to
+// back off, can submit it again later.
+func (f *Forwarder) attempt(ctx context.Context, tx *bc.Tx, retrying bool) error {
+	err := txbuilder.FinalizeTx(ctx, f.Chain, tx)
+	root := errors.Root(err)
+	refused := root == txbuilder.ErrOverloaded || root == txbuilder.ErrHalted
+	switch {
+	case err == nil:
+		return f.update(ctx, tx.Hash, StatusForwarded, "")
+	case refused && !retrying:
+		const q = `DELETE FROM forwarded_txs WHERE tx_hash=$1 AND status=$2`
+		_, err2 := f.DB.Exec(ctx, q, tx.Hash.String(), StatusForwarding)
+		if err2 != nil {
+			return errors.Wrap(err2, "deleting forwarded tx")
+		}
+		return err
+	case refused || rpc.IsTemporary(err):
+		log.Error(ctx, err, "forwarding tx", tx.Hash)
+		const q = `
+			UPDATE forwarded_txs
+			SET attempts=attempts+1, error=$2, updated_at=now(),
+				next_attempt_at=now() + least(interval '1 second' * (2 ^ attempts), $3 * interval '1 second')
+			WHERE tx_hash=$1 AND status=$4
+		`
+		_, err2 := f.DB.Exec(ctx, q, tx.Hash.String(), err.Error(), int64(maxBackoff/time.Second), StatusForwarding)
+		return errors.Wrap(err2, "recording forwarding attempt")
+	default:
+		err2 := f.update(ctx, tx.Hash, StatusFailed, err.Error())
+		if err2 != nil {
+			return err2
+		}
+		return err
+	}
+}
+
+func (f *Forwarder) update(ctx context.Context, txID bc.Hash, status, msg string) error {
+	const q = `
+		UPDATE forwarded_txs
+		SET status=$2, error=$3, attempts=attempts+1, updated_at=now()
+		WHERE tx_hash=$1
+	`
+	_, err := f.DB.Exec(ctx, q, txID.String(), status, msg)
+	return errors.Wrap(err, "updating forwarded tx")
+}
+
+// Find returns the forwarding record of transaction txID,
+// or nil if it wasn't forwarded by this core.
+func (f *Forwarder) Find(ctx context.Context, txID bc.Hash) (*Record, error) {
+	const q = `
+		SELECT status, attempts, COALESCE(error, ''), created_at, updated_at
+		FROM forwarded_txs WHERE tx_hash=$1
+	`
+	r := &Record{TxID: txID}
+	err := f.DB.QueryRow(ctx, q, txID.String()).Scan(&r.Status, &r.Attempts, &r.Error, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, errors.Wrap(err)
+}
+
+// Retry forwards again, every period, the transactions whose
+// next attempt is due. It returns when its context is canceled.
+// It should run only in the leader process.
+func (f *Forwarder) Retry(ctx context.Context, period time.Duration) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, Retry exiting")
+			return
+		case <-ticks:
+			err := f.retryDue(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+func (f *Forwarder) retryDue(ctx context.Context) error {
+	const q = `
+		SELECT data, priority FROM forwarded_txs
+		WHERE status=$1 AND next_attempt_at <= now()
+		ORDER BY next_attempt_at LIMIT 100
+	`
+	type due struct {
+		tx       *bc.Tx
+		priority string
+	}
+	var txs []due
+	err := pg.ForQueryRows(ctx, f.DB, q, StatusForwarding, func(data bc.TxData, priority string) {
+		txs = append(txs, due{bc.NewTx(data), priority})
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading txs to forward")
+	}
+
+	now := time.Now()
+	for _, d := range txs {
+		err = txbuilder.CheckExpiry(&d.tx.TxData, now)
+		if err != nil {
+			err = f.update(ctx, d.tx.Hash, StatusFailed, err.Error())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		p, _ := mempool.ParsePriority(d.priority)
+		txctx := mempool.NewContext(ctx, mempool.Submission{Priority: p, Quota: mempool.Unlimited})
+		// If the generator refuses the transaction
+		// for good, its record tells why.
+		f.attempt(txctx, d.tx, true)
+	}
+	return nil
+}
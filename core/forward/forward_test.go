@@ -0,0 +1,44 @@
+package forward
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestRetryExpired(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	f := &Forwarder{DB: db}
+
+	tx := bc.NewTx(bc.TxData{Version: 1, MinTime: 1, MaxTime: 2})
+	rec, err := f.Find(ctx, tx.Hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if rec != nil {
+		t.Fatalf("Find(unknown) = %+v, want nil", rec)
+	}
+
+	const q = `INSERT INTO forwarded_txs (tx_hash, data, priority, status) VALUES ($1, $2, 'normal', $3)`
+	_, err = db.Exec(ctx, q, tx.Hash.String(), &tx.TxData, StatusForwarding)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// An expired transaction fails without reaching the generator.
+	err = f.retryDue(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	rec, err = f.Find(ctx, tx.Hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if rec == nil || rec.Status != StatusFailed || rec.Attempts != 1 || rec.Error == "" {
+		t.Errorf("Find = %+v, want a failed record", rec)
+	}
+}
@@ -0,0 +1,266 @@
+// Package freeze implements administrative freezes of assets.
+//
+// An operator can freeze an asset outright, so that no transaction
+// built or submitted through Chain Core may issue or spend it, or
+// freeze it in one account only, so that the account can no longer
+// spend it. Freezes are meant for incident response, such as when
+// an issuer's key is compromised. Every freeze and unfreeze is
+// recorded, with the access token that made it, in an audit log.
+package freeze
+
+import (
+	"context"
+	stdsql "database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+const (
+	ActionFreeze   = "freeze"
+	ActionUnfreeze = "unfreeze"
+)
+
+const defaultLimit = 100
+
+var (
+	// ErrFrozen is returned for transactions that move
+	// a frozen asset.
+	ErrFrozen = errors.New("asset is frozen")
+
+	// ErrBadFreeze is returned for freezes that
+	// cannot be stored.
+	ErrBadFreeze = errors.New("invalid freeze")
+
+	// ErrNotFrozen is returned when unfreezing an asset,
+	// or an asset in an account, that isn't frozen.
+	ErrNotFrozen = errors.New("asset is not frozen")
+)
+
+// Controller keeps track of the freezes in effect,
+// and of the audit log of changes to them.
+type Controller struct {
+	DB *sql.DB
+}
+
+// A Freeze stops the movement of an asset. If AccountID is
+// empty, the freeze applies to the asset everywhere; otherwise
+// it applies only to spends from that account.
+type Freeze struct {
+	AssetID   bc.AssetID `json:"asset_id"`
+	AccountID string     `json:"account_id,omitempty"`
+	Reason    string     `json:"reason"`
+	FrozenBy  string     `json:"frozen_by"`
+	FrozenAt  time.Time  `json:"frozen_at"`
+}
+
+// An Event is an entry in the audit log of freezes.
+type Event struct {
+	ID        string     `json:"id"`
+	Action    string     `json:"action"` // ActionFreeze or ActionUnfreeze
+	AssetID   bc.AssetID `json:"asset_id"`
+	AccountID string     `json:"account_id,omitempty"`
+	Reason    string     `json:"reason"`
+	Operator  string     `json:"operator"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// A Holding is an asset, and optionally the account, that a
+// transaction moves. An empty AccountID means an account that
+// doesn't belong to this Core, or an issuance.
+type Holding struct {
+	AssetID   bc.AssetID
+	AccountID string
+}
+
+// Freeze freezes assetID, in accountID only if it isn't empty,
+// on behalf of the access token operator. Freezing an asset that
+// is already frozen replaces the reason.
+func (c *Controller) Freeze(ctx context.Context, assetID bc.AssetID, accountID, reason, operator string) (*Freeze, error) {
+	if assetID == (bc.AssetID{}) {
+		return nil, errors.WithDetail(ErrBadFreeze, "asset_id is required")
+	}
+	if reason == "" {
+		return nil, errors.WithDetail(ErrBadFreeze, "reason is required")
+	}
+
+	dbtx, err := c.DB.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer dbtx.Rollback(ctx)
+
+	const q = `
+		INSERT INTO freezes (asset_id, account_id, reason, frozen_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (asset_id, account_id) DO UPDATE
+		SET reason=$3, frozen_by=$4, frozen_at=now()
+		RETURNING frozen_at
+	`
+	f := &Freeze{
+		AssetID:   assetID,
+		AccountID: accountID,
+		Reason:    reason,
+		FrozenBy:  operator,
+	}
+	err = dbtx.QueryRow(ctx, q, assetID, accountID, reason, operator).Scan(&f.FrozenAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "saving freeze")
+	}
+	err = recordEvent(ctx, dbtx, ActionFreeze, assetID, accountID, reason, operator)
+	if err != nil {
+		return nil, err
+	}
+	return f, errors.Wrap(dbtx.Commit(ctx))
+}
+
+// Unfreeze lifts the freeze of assetID, or of assetID in
+// accountID if it isn't empty, on behalf of the access token
+// operator. It doesn't lift an asset-wide freeze when
+// accountID is given, nor freezes in particular accounts
+// when it isn't.
+func (c *Controller) Unfreeze(ctx context.Context, assetID bc.AssetID, accountID, reason, operator string) error {
+	if assetID == (bc.AssetID{}) {
+		return errors.WithDetail(ErrBadFreeze, "asset_id is required")
+	}
+
+	dbtx, err := c.DB.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer dbtx.Rollback(ctx)
+
+	const q = `DELETE FROM freezes WHERE asset_id=$1 AND account_id=$2`
+	res, err := dbtx.Exec(ctx, q, assetID, accountID)
+	if err != nil {
+		return errors.Wrap(err, "deleting freeze")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		return errors.WithDetailf(ErrNotFrozen, "asset %s", assetID)
+	}
+	err = recordEvent(ctx, dbtx, ActionUnfreeze, assetID, accountID, reason, operator)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(dbtx.Commit(ctx))
+}
+
+func recordEvent(ctx context.Context, db pg.DB, action string, assetID bc.AssetID, accountID, reason, operator string) error {
+	const q = `
+		INSERT INTO freeze_events (action, asset_id, account_id, reason, operator)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := db.Exec(ctx, q, action, assetID, accountID, reason, operator)
+	if err != nil {
+		return errors.Wrap(err, "recording freeze event")
+	}
+	log.Write(ctx, "freeze", action, "asset", assetID, "account", accountID, "operator", operator, "reason", reason)
+	return nil
+}
+
+// List returns the freezes in effect, ordered by asset
+// and account.
+func (c *Controller) List(ctx context.Context) ([]*Freeze, error) {
+	const q = `
+		SELECT asset_id, account_id, reason, frozen_by, frozen_at
+		FROM freezes ORDER BY asset_id, account_id
+	`
+	var freezes []*Freeze
+	err := pg.ForQueryRows(ctx, c.DB, q, func(assetID bc.AssetID, accountID, reason, frozenBy string, frozenAt time.Time) {
+		freezes = append(freezes, &Freeze{
+			AssetID:   assetID,
+			AccountID: accountID,
+			Reason:    reason,
+			FrozenBy:  frozenBy,
+			FrozenAt:  frozenAt,
+		})
+	})
+	return freezes, errors.Wrap(err)
+}
+
+// Events returns the audit log of freezes and unfreezes,
+// newest first, in pages. If assetID isn't zero, only the
+// events for that asset are returned.
+func (c *Controller) Events(ctx context.Context, assetID bc.AssetID, after string, limit int) ([]*Event, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	var asset string
+	if assetID != (bc.AssetID{}) {
+		asset = assetID.String()
+	}
+	const q = `
+		SELECT id, action, asset_id, account_id, reason, operator, created_at
+		FROM freeze_events
+		WHERE ($1='' OR asset_id=$1) AND ($2='' OR id<$2)
+		ORDER BY id DESC LIMIT $3
+	`
+	var events []*Event
+	err := pg.ForQueryRows(ctx, c.DB, q, asset, after, limit, func(id, action string, assetID bc.AssetID, accountID, reason, operator string, ts time.Time) {
+		events = append(events, &Event{
+			ID:        id,
+			Action:    action,
+			AssetID:   assetID,
+			AccountID: accountID,
+			Reason:    reason,
+			Operator:  operator,
+			Timestamp: ts,
+		})
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err)
+	}
+	if len(events) > 0 {
+		after = events[len(events)-1].ID
+	}
+	return events, after, nil
+}
+
+// Check returns ErrFrozen if any of holdings is frozen: if its
+// asset is frozen outright, or, when it names an account, if
+// its asset is frozen in that account.
+func (c *Controller) Check(ctx context.Context, holdings []Holding) error {
+	if len(holdings) == 0 {
+		return nil
+	}
+	var assetIDs, accountIDs pq.StringArray
+	for _, h := range holdings {
+		assetIDs = append(assetIDs, h.AssetID.String())
+		accountIDs = append(accountIDs, h.AccountID)
+		if h.AccountID != "" {
+			assetIDs = append(assetIDs, h.AssetID.String())
+			accountIDs = append(accountIDs, "")
+		}
+	}
+	const q = `
+		SELECT asset_id, account_id, reason FROM freezes
+		WHERE (asset_id, account_id) IN (SELECT unnest($1::text[]), unnest($2::text[]))
+		ORDER BY asset_id, account_id LIMIT 1
+	`
+	var (
+		assetID   bc.AssetID
+		accountID string
+		reason    string
+	)
+	err := c.DB.QueryRow(ctx, q, assetIDs, accountIDs).Scan(&assetID, &accountID, &reason)
+	if err == stdsql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "checking freezes")
+	}
+	if accountID == "" {
+		return errors.WithDetailf(ErrFrozen, "asset %s is frozen: %s", assetID, reason)
+	}
+	return errors.WithDetailf(ErrFrozen, "asset %s is frozen in account %s: %s", assetID, accountID, reason)
+}
@@ -0,0 +1,74 @@
+package freeze
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestFreeze(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := &Controller{DB: db}
+
+	assetA, assetB := bc.AssetID{1}, bc.AssetID{2}
+	_, err := c.Freeze(ctx, assetA, "acc1", "stolen key", "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = c.Freeze(ctx, assetB, "", "issuer compromised", "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	cases := []struct {
+		holdings []Holding
+		frozen   bool
+	}{
+		{[]Holding{{AssetID: assetA}}, false},
+		{[]Holding{{AssetID: assetA, AccountID: "acc2"}}, false},
+		{[]Holding{{AssetID: assetA, AccountID: "acc1"}}, true},
+		{[]Holding{{AssetID: assetB}}, true},
+		{[]Holding{{AssetID: assetB, AccountID: "acc2"}}, true},
+		{[]Holding{{AssetID: bc.AssetID{3}}, {AssetID: assetB}}, true},
+	}
+	for i, tc := range cases {
+		err := c.Check(ctx, tc.holdings)
+		if got := errors.Root(err) == ErrFrozen; got != tc.frozen {
+			t.Errorf("case %d: Check = %v, want frozen %t", i, err, tc.frozen)
+		}
+	}
+
+	err = c.Unfreeze(ctx, assetB, "acc2", "", "bob")
+	if errors.Root(err) != ErrNotFrozen {
+		t.Errorf("Unfreeze(not frozen in account) = %v, want %v", err, ErrNotFrozen)
+	}
+	err = c.Unfreeze(ctx, assetB, "", "key rotated", "bob")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.Check(ctx, []Holding{{AssetID: assetB}})
+	if err != nil {
+		t.Errorf("Check(unfrozen) = %v, want nil", err)
+	}
+
+	freezes, err := c.List(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(freezes) != 1 || freezes[0].AssetID != assetA || freezes[0].AccountID != "acc1" {
+		t.Errorf("List = %+v, want one freeze of asset %s in acc1", freezes, assetA)
+	}
+
+	events, _, err := c.Events(ctx, assetB, "", 0)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 2 || events[0].Action != ActionUnfreeze || events[0].Operator != "bob" || events[1].Action != ActionFreeze {
+		t.Errorf("Events = %+v, want unfreeze by bob then freeze", events)
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/freeze"
+	"chain/core/tenant"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// checkFrozen returns freeze.ErrFrozen if tx issues or spends
+// an asset that is frozen, or spends an asset from an account
+// in which it is frozen.
+func (h *Handler) checkFrozen(ctx context.Context, txdata *bc.TxData) error {
+	if h.Freezes == nil || txdata == nil {
+		return nil
+	}
+	tx := bc.NewTx(*txdata)
+	var holdings []freeze.Holding
+	for _, in := range tx.Inputs {
+		holdings = append(holdings, freeze.Holding{AssetID: in.AssetID()})
+	}
+	spends, err := h.Accounts.Spends(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, s := range spends {
+		holdings = append(holdings, freeze.Holding{AssetID: s.AssetID, AccountID: s.AccountID})
+	}
+	return h.Freezes.Check(ctx, holdings)
+}
+
+type freezeRequest struct {
+	AssetID      bc.AssetID `json:"asset_id"`
+	AssetAlias   string     `json:"asset_alias"`
+	AccountID    string     `json:"account_id"`
+	AccountAlias string     `json:"account_alias"`
+	Reason       string     `json:"reason"`
+}
+
+// resolveFreeze fills in the IDs of the asset and account
+// named by alias, and checks that an account given by ID
+// is one the tenant of ctx can see.
+func (h *Handler) resolveFreeze(ctx context.Context, in *freezeRequest) error {
+	if in.AssetID == (bc.AssetID{}) && in.AssetAlias != "" {
+		a, err := h.Assets.FindByAlias(ctx, in.AssetAlias)
+		if err != nil {
+			return err
+		}
+		in.AssetID = a.AssetID
+	}
+	if in.AccountID == "" && in.AccountAlias != "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return err
+		}
+		in.AccountID = acc.ID
+	} else if in.AccountID != "" {
+		_, err := h.Accounts.FindByID(ctx, in.AccountID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// POST /freeze-asset
+//
+// Freezes the asset everywhere, or, if an account is given,
+// only in that account. Freezes apply to the whole core, so
+// only the default tenant may set or lift them.
+func (h *Handler) freezeAsset(ctx context.Context, in freezeRequest) (*freeze.Freeze, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	err := h.resolveFreeze(ctx, &in)
+	if err != nil {
+		return nil, err
+	}
+	return h.Freezes.Freeze(ctx, in.AssetID, in.AccountID, in.Reason, accessTokenID(ctx))
+}
+
+// POST /unfreeze-asset
+func (h *Handler) unfreezeAsset(ctx context.Context, in freezeRequest) error {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
+	err := h.resolveFreeze(ctx, &in)
+	if err != nil {
+		return err
+	}
+	return h.Freezes.Unfreeze(ctx, in.AssetID, in.AccountID, in.Reason, accessTokenID(ctx))
+}
+
+// POST /list-freezes
+func (h *Handler) listFreezes(ctx context.Context) ([]*freeze.Freeze, error) {
+	freezes, err := h.Freezes.List(ctx)
+	if freezes == nil {
+		freezes = []*freeze.Freeze{}
+	}
+	return freezes, err
+}
+
+// POST /list-freeze-events
+//
+// Lists the audit log of freezes and unfreezes, newest first,
+// of the asset given by asset_id, or of every asset.
+func (h *Handler) listFreezeEvents(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	var assetID bc.AssetID
+	if query.AssetID != "" {
+		err := assetID.UnmarshalText([]byte(query.AssetID))
+		if err != nil {
+			return nil, errors.WithDetail(httpjson.ErrBadRequest, err.Error())
+		}
+	}
+	events, after, err := h.Freezes.Events(ctx, assetID, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(events),
+		LastPage: len(events) < limit,
+		Next:     query,
+	}, nil
+}
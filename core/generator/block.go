@@ -58,6 +58,7 @@ func (g *generator) makeBlock(ctx context.Context) error {
 }
 
 func (g *generator) commitBlock(ctx context.Context, b *bc.Block, s *state.Snapshot) error {
+	ctx = log.AddFields(ctx, log.KeyBlockHeight, b.Height)
 	err := g.getAndAddBlockSignatures(ctx, b, g.latestBlock)
 	if err != nil {
 		return errors.Wrap(err, "sign")
@@ -109,13 +110,15 @@ func (g *generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 			goodSigs[k] = sig
 			nready++
 		} else if k < 0 {
-			log.Write(ctx, "error", "invalid signature", "block", b.Hash(), "signature", sig)
+			log.Write(ctx, log.KeyError, "invalid signature", "block", b.Hash(), "signature", sig)
 		}
 	}
 
 	if nready < quorum {
 		return fmt.Errorf("got %d of %d needed signatures", nready, quorum)
 	}
+	// The witness has one signature per signing key,
+	// in the order of the keys in the consensus program.
 	b.Witness = nonNilSigs(goodSigs)
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"chain/database/pg"
+	"chain/errors"
 	"chain/log"
 	"chain/protocol"
 	"chain/protocol/bc"
@@ -44,6 +45,8 @@ type generator struct {
 // is canceled.
 // After each attempt to make a block, it calls health
 // to report either an error or nil to indicate success.
+// While an operator has halted block generation (see SetHalt),
+// it makes no blocks and reports ErrHalted.
 func Generate(
 	ctx context.Context,
 	c *protocol.Chain,
@@ -95,7 +98,17 @@ func Generate(
 			log.Messagef(ctx, "Deposed, Generate exiting")
 			return
 		case <-ticks:
-			err := g.makeBlock(ctx)
+			halt, err := GetHalt(ctx, g.db)
+			if err == nil && halt != nil {
+				health(errors.WithDetail(ErrHalted, halt.Reason))
+				continue
+			}
+			if err != nil {
+				health(err)
+				log.Error(ctx, err)
+				continue
+			}
+			err = g.makeBlock(ctx)
 			health(err)
 			if err != nil {
 				log.Error(ctx, err)
@@ -7,6 +7,7 @@ import (
 
 	"chain/crypto/ed25519"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
@@ -127,3 +128,56 @@ func (s testSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error)
 func (s testSigner) String() string {
 	return "test-signer"
 }
+
+func TestHalt(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+
+	_, err := SetHalt(ctx, dbtx, false, "maintenance", "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = Resume(ctx, dbtx, "done", "bob")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	halt, err := GetHalt(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if halt != nil {
+		t.Fatalf("GetHalt after Resume = %+v, want nil", halt)
+	}
+
+	_, err = SetHalt(ctx, dbtx, true, "incident", "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	halt, err = GetHalt(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if halt == nil || !halt.Pool || halt.Reason != "incident" || halt.Operator != "alice" {
+		t.Fatalf("GetHalt = %+v, want pool halt for incident by alice", halt)
+	}
+
+	// Generate must make no blocks while halted.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	health := make(chan error, 1)
+	height := c.Height()
+	go Generate(ctx, c, nil, dbtx, 10*time.Millisecond, func(err error) {
+		select {
+		case health <- err:
+		default:
+		}
+	})
+	err = <-health
+	if errors.Root(err) != ErrHalted {
+		t.Errorf("health = %v, want %v", err, ErrHalted)
+	}
+	if c.Height() != height {
+		t.Errorf("height = %d, want %d while halted", c.Height(), height)
+	}
+}
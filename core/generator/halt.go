@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+)
+
+// ErrHalted is reported to the health callback of Generate
+// while block generation is halted.
+var ErrHalted = errors.New("block generation halted")
+
+// A Halt records that an operator stopped block generation.
+// If Pool is true, the generator also refuses new transactions.
+type Halt struct {
+	Pool     bool      `json:"halt_pool"`
+	Reason   string    `json:"reason"`
+	Operator string    `json:"operator"`
+	HaltedAt time.Time `json:"halted_at"`
+}
+
+// GetHalt returns the halt in effect, or nil if block
+// generation isn't halted. Halts are stored in the database,
+// so they persist across restarts and changes of leader.
+func GetHalt(ctx context.Context, db pg.DB) (*Halt, error) {
+	const q = `SELECT halt_pool, reason, operator, halted_at FROM generator_halt`
+	h := new(Halt)
+	err := db.QueryRow(ctx, q).Scan(&h.Pool, &h.Reason, &h.Operator, &h.HaltedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving generator halt")
+	}
+	return h, nil
+}
+
+// SetHalt halts block generation, and pool admission too if
+// pool is true, on behalf of the access token operator. The
+// generator makes no more blocks, from its next block period,
+// until Resume is called. Halting again replaces the halt.
+func SetHalt(ctx context.Context, db pg.DB, pool bool, reason, operator string) (*Halt, error) {
+	const q = `
+		INSERT INTO generator_halt (halt_pool, reason, operator) VALUES ($1, $2, $3)
+		ON CONFLICT (singleton) DO UPDATE
+		SET halt_pool=$1, reason=$2, operator=$3, halted_at=now()
+		RETURNING halted_at
+	`
+	h := &Halt{Pool: pool, Reason: reason, Operator: operator}
+	err := db.QueryRow(ctx, q, pool, reason, operator).Scan(&h.HaltedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "saving generator halt")
+	}
+	log.Write(ctx, log.KeyMessage, "generator halted", "halt_pool", pool, "operator", operator, "reason", reason)
+	return h, nil
+}
+
+// Resume lifts the halt, if any, on behalf of the access
+// token operator, and logs the reason.
+func Resume(ctx context.Context, db pg.DB, reason, operator string) error {
+	const q = `DELETE FROM generator_halt`
+	_, err := db.Exec(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "deleting generator halt")
+	}
+	log.Write(ctx, log.KeyMessage, "generator resumed", "operator", operator, "reason", reason)
+	return nil
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"chain/core/graphql"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// Arguments of GraphQL fields that list things, as for the
+// corresponding REST endpoints.
+var (
+	txsArgs      = []string{"filter", "filter_params", "after", "start_time", "end_time"}
+	outputsArgs  = []string{"filter", "filter_params", "after", "timestamp"}
+	balancesArgs = []string{"filter", "filter_params", "sum_by", "timestamp"}
+	listArgs     = []string{"filter", "filter_params", "after"}
+)
+
+// POST /graphql
+//
+// Executes a GraphQL query over the query indexes. Its
+// transactions, unspent_outputs, accounts, assets, and balances
+// fields take the same arguments as /list-transactions and the
+// other list endpoints, and give the same objects, in pages.
+// Unlike those endpoints, a query can follow the relations
+// between objects, such as from an output to its account or
+// from an account to its transactions, and select only some
+// of their fields. See buildGraphQLSchema.
+func (h *Handler) graphQL(ctx context.Context, req graphql.Request) *graphql.Response {
+	resp := h.graphQLSchema.Execute(ctx, &req)
+	for _, e := range resp.Errors {
+		if e.Err != nil {
+			logHTTPError(ctx, e.Err)
+			body, _ := errInfo(e.Err)
+			e.Message = body.Message
+			e.Extensions = body
+		}
+	}
+	return resp
+}
+
+// buildGraphQLSchema returns the schema of /graphql. Its object
+// types have the fields of the objects the REST API gives,
+// plus fields for related objects.
+func (h *Handler) buildGraphQLSchema() *graphql.Schema {
+	var (
+		transaction = &graphql.Object{Name: "Transaction"}
+		input       = &graphql.Object{Name: "Input"}
+		output      = &graphql.Object{Name: "Output"}
+		account     = &graphql.Object{Name: "Account"}
+		asset       = &graphql.Object{Name: "Asset"}
+
+		transactionPage = pageObject("TransactionPage", transaction)
+		outputPage      = pageObject("OutputPage", output)
+		accountPage     = pageObject("AccountPage", account)
+		assetPage       = pageObject("AssetPage", asset)
+		balancePage     = pageObject("BalancePage", nil)
+	)
+
+	// related returns a field whose value is the
+	// object of typ whose ID is the parent's idKey.
+	related := func(typ *graphql.Object, idKey string, list func(context.Context, requestQuery) (page, error)) *graphql.Field {
+		return &graphql.Field{Type: typ, Resolve: func(ctx context.Context, parent, _ map[string]interface{}) (interface{}, error) {
+			id, ok := parent[idKey].(string)
+			if !ok {
+				return nil, nil
+			}
+			return first(list(ctx, requestQuery{Filter: "id=$1", FilterParams: []interface{}{id}}))
+		}}
+	}
+
+	// byParent returns a field listing the objects for which
+	// filter, with its first parameter the parent's ID, holds.
+	// Any filter argument is ANDed with it.
+	byParent := func(typ *graphql.Object, args []string, filter string, list func(context.Context, requestQuery) (page, error)) *graphql.Field {
+		return &graphql.Field{Type: typ, Args: args, Resolve: func(ctx context.Context, parent, args map[string]interface{}) (interface{}, error) {
+			q, err := graphQLRequestQuery(args)
+			if err != nil {
+				return nil, err
+			}
+			q.FilterParams = append([]interface{}{parent["id"]}, q.FilterParams...)
+			if q.Filter == "" {
+				q.Filter = filter
+			} else {
+				q.Filter = "(" + filter + ") AND (" + shiftPlaceholders(q.Filter) + ")"
+			}
+			if len(q.SumBy) == 0 && typ == balancePage {
+				q.SumBy = []string{"asset_alias", "asset_id"}
+			}
+			return list(ctx, q)
+		}}
+	}
+
+	transaction.Fields = leafFields("id", "timestamp", "block_id", "block_height", "position",
+		"confirmations", "final", "reference_data", "is_local")
+	transaction.Fields["inputs"] = &graphql.Field{Type: input}
+	transaction.Fields["outputs"] = &graphql.Field{Type: output}
+
+	input.Fields = leafFields("type", "asset_id", "asset_alias", "asset_definition", "asset_tags",
+		"asset_is_local", "amount", "amount_display", "issuance_program", "spent_output", "account_id",
+		"account_alias", "account_tags", "reference_data", "is_local")
+	input.Fields["account"] = related(account, "account_id", h.listAccounts)
+	input.Fields["asset"] = related(asset, "asset_id", h.listAssets)
+
+	output.Fields = leafFields("type", "purpose", "transaction_id", "position", "asset_id",
+		"asset_alias", "asset_definition", "asset_tags", "asset_is_local", "amount", "amount_display",
+		"account_id", "account_alias", "account_tags", "control_program", "reference_data", "is_local")
+	output.Fields["account"] = related(account, "account_id", h.listAccounts)
+	output.Fields["asset"] = related(asset, "asset_id", h.listAssets)
+	output.Fields["transaction"] = related(transaction, "transaction_id", h.listTransactions)
+
+	account.Fields = leafFields("id", "alias", "keys", "quorum", "tags", "watch_only", "parent_id")
+	account.Fields["parent"] = related(account, "parent_id", h.listAccounts)
+	account.Fields["transactions"] = byParent(transactionPage, txsArgs, "inputs(account_id=$1) OR outputs(account_id=$1)", h.listTransactions)
+	account.Fields["unspent_outputs"] = byParent(outputPage, outputsArgs, "account_id=$1", h.listUnspentOutputs)
+	account.Fields["balances"] = byParent(balancePage, balancesArgs, "account_id=$1", h.listBalances)
+
+	asset.Fields = leafFields("id", "alias", "issuance_program", "keys", "quorum", "definition", "decimals", "symbol", "tags", "is_local")
+	asset.Fields["transactions"] = byParent(transactionPage, txsArgs, "inputs(asset_id=$1) OR outputs(asset_id=$1)", h.listTransactions)
+	asset.Fields["unspent_outputs"] = byParent(outputPage, outputsArgs, "asset_id=$1", h.listUnspentOutputs)
+
+	return &graphql.Schema{Query: &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"transactions":    listField(transactionPage, txsArgs, h.listTransactions),
+			"unspent_outputs": listField(outputPage, outputsArgs, h.listUnspentOutputs),
+			"accounts":        listField(accountPage, listArgs, h.listAccounts),
+			"assets":          listField(assetPage, listArgs, h.listAssets),
+			"balances":        listField(balancePage, balancesArgs, h.listBalances),
+			"transaction":     getField(transaction, []string{"id"}, h.listTransactions),
+			"account":         getField(account, []string{"id", "alias"}, h.listAccounts),
+			"asset":           getField(asset, []string{"id", "alias"}, h.listAssets),
+		},
+	}}
+}
+
+// pageObject returns the type of a page of items of typ.
+func pageObject(name string, typ *graphql.Object) *graphql.Object {
+	return &graphql.Object{Name: name, Fields: map[string]*graphql.Field{
+		"items":     {Type: typ},
+		"next":      {},
+		"last_page": {},
+	}}
+}
+
+func leafFields(names ...string) map[string]*graphql.Field {
+	fields := make(map[string]*graphql.Field)
+	for _, name := range names {
+		fields[name] = new(graphql.Field)
+	}
+	return fields
+}
+
+// listField returns a field giving a page of
+// list's results for the field's arguments.
+func listField(typ *graphql.Object, args []string, list func(context.Context, requestQuery) (page, error)) *graphql.Field {
+	return &graphql.Field{Type: typ, Args: args, Resolve: func(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+		q, err := graphQLRequestQuery(args)
+		if err != nil {
+			return nil, err
+		}
+		return list(ctx, q)
+	}}
+}
+
+// getField returns a field giving the one object of typ with
+// the ID, or alias, given as an argument, or null if none has.
+func getField(typ *graphql.Object, args []string, list func(context.Context, requestQuery) (page, error)) *graphql.Field {
+	return &graphql.Field{Type: typ, Args: args, Resolve: func(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+		if id, ok := args["id"].(string); ok {
+			return first(list(ctx, requestQuery{Filter: "id=$1", FilterParams: []interface{}{id}}))
+		}
+		if alias, ok := args["alias"].(string); ok {
+			return first(list(ctx, requestQuery{Filter: "alias=$1", FilterParams: []interface{}{alias}}))
+		}
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "id or alias is required")
+	}}
+}
+
+// first returns the first item of p, or nil.
+func first(p page, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := reflect.ValueOf(p.Items)
+	if items.Kind() != reflect.Slice || items.Len() == 0 {
+		return nil, nil
+	}
+	return items.Index(0).Interface(), nil
+}
+
+// graphQLRequestQuery converts the arguments of a GraphQL
+// field to the request of the corresponding REST endpoint.
+func graphQLRequestQuery(args map[string]interface{}) (requestQuery, error) {
+	var q requestQuery
+	b, err := json.Marshal(args)
+	if err != nil {
+		return q, errors.Wrap(err)
+	}
+	err = json.Unmarshal(b, &q)
+	if err != nil {
+		return q, errors.WithDetail(httpjson.ErrBadRequest, err.Error())
+	}
+	return q, nil
+}
+
+// shiftPlaceholders renumbers the placeholders in filter,
+// $1 to $2 and so on, making room for another parameter.
+func shiftPlaceholders(filter string) string {
+	var out []byte
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		out = append(out, c)
+		if c == '\'' {
+			// Copy string literals unchanged.
+			for i++; i < len(filter); i++ {
+				out = append(out, filter[i])
+				if filter[i] == '\'' {
+					break
+				}
+			}
+			continue
+		}
+		if c != '$' {
+			continue
+		}
+		j := i + 1
+		for j < len(filter) && '0' <= filter[j] && filter[j] <= '9' {
+			j++
+		}
+		if j > i+1 {
+			n, _ := strconv.Atoi(filter[i+1 : j])
+			out = append(out, strconv.Itoa(n+1)...)
+			i = j - 1
+		}
+	}
+	return string(out)
+}
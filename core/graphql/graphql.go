@@ -0,0 +1,442 @@
+// Package graphql executes GraphQL queries against a schema of
+// resolver functions.
+//
+// It implements the query language of the GraphQL spec: operations,
+// variables, aliases, fragments, and the @include and @skip
+// directives. It doesn't implement mutations, subscriptions, or
+// introspection beyond __typename; and since values are JSON, a
+// schema declares only the fields of its object types and which of
+// those are other object types, not scalar types.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"chain/errors"
+)
+
+// A Schema is the set of queries a client can make.
+type Schema struct {
+	Query *Object
+}
+
+// An Object is an object type: a named set of fields.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// A Field is a field of an Object.
+type Field struct {
+	// Type is the type of the field's value, or of its elements
+	// if it's a list. If Type is nil, the value is any JSON value,
+	// and a query may select keys from it if it's an object.
+	Type *Object
+
+	// Args are the names of the arguments the field takes.
+	Args []string
+
+	// Resolve computes the field's value from its parent,
+	// which is nil for fields of the schema's query type.
+	// The value is converted to JSON before fields are
+	// selected from it. If Resolve is nil, the field's value
+	// is the parent's value of the same name.
+	Resolve func(ctx context.Context, parent map[string]interface{}, args map[string]interface{}) (interface{}, error)
+}
+
+// A Request is a GraphQL request, as POSTed by clients.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// A Response is the result of executing a Request.
+// If the request is invalid, Data is nil.
+type Response struct {
+	Data   *OrderedMap `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// An Error is an error executing a request. If it's from a
+// field's resolver, Path gives the field and Err the error.
+type Error struct {
+	Message    string        `json:"message"`
+	Locations  []Location    `json:"locations,omitempty"`
+	Path       []interface{} `json:"path,omitempty"`
+	Extensions interface{}   `json:"extensions,omitempty"`
+	Err        error         `json:"-"`
+}
+
+// A Location is a line and column in a query, counting from 1.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// An OrderedMap is a JSON object whose keys
+// are in the order they were selected.
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+func (m *OrderedMap) set(k string, v interface{}) {
+	if _, ok := m.Values[k]; !ok {
+		m.Keys = append(m.Keys, k)
+	}
+	m.Values[k] = v
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(m.Values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Execute executes the query in req. A field whose resolver
+// fails is null in the response, and the error is one of
+// the response's Errors; the rest of the query still runs.
+//
+// Execution stops, with an error, when ctx is done or the
+// query exceeds MaxFields or MaxResolves. A query that nests
+// fields more than MaxDepth deep, or whose fragments spread
+// themselves, isn't executed at all.
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	doc, err := parse(req.Query)
+	if perr, ok := err.(parseError); ok {
+		line, col := location(req.Query, perr.pos)
+		return &Response{Errors: []*Error{{
+			Message:   "syntax error: " + perr.msg,
+			Locations: []Location{{line, col}},
+		}}}
+	}
+	if ferr, ok := validate(doc).(fieldError); ok {
+		line, col := location(req.Query, ferr.pos)
+		return &Response{Errors: []*Error{{
+			Message:   ferr.msg,
+			Locations: []Location{{line, col}},
+		}}}
+	}
+
+	var op *operation
+	if req.OperationName == "" {
+		if len(doc.ops) > 1 {
+			return requestError("operationName is required for a query with more than one operation")
+		}
+		op = doc.ops[0]
+	}
+	for _, o := range doc.ops {
+		if req.OperationName != "" && o.name == req.OperationName {
+			op = o
+		}
+	}
+	if op == nil {
+		return requestError(fmt.Sprintf("no operation named %q", req.OperationName))
+	}
+	if op.kind != "query" {
+		return requestError(op.kind + " operations are not supported")
+	}
+
+	vars := make(map[string]interface{})
+	for _, d := range op.vars {
+		v, ok := req.Variables[d.name]
+		if !ok && d.hasDef {
+			v, ok = d.def, true
+		}
+		if d.nonNull && v == nil {
+			return requestError(fmt.Sprintf("variable $%s is required", d.name))
+		}
+		if ok {
+			vars[d.name] = v
+		}
+	}
+
+	e := &executor{doc: doc, vars: vars, src: req.Query}
+	data := e.selectFields(ctx, s.Query, nil, op.sel, nil)
+	return &Response{Data: data, Errors: e.errs}
+}
+
+func requestError(msg string) *Response {
+	return &Response{Errors: []*Error{{Message: msg}}}
+}
+
+// executor is the state of the execution of one query.
+type executor struct {
+	doc  *document
+	vars map[string]interface{}
+	src  string
+	errs []*Error
+
+	fields, resolves int  // counted against MaxFields and MaxResolves
+	halted           bool // no more fields are resolved
+}
+
+// fieldError is a query error found during execution.
+type fieldError struct {
+	msg string
+	pos int
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	gqlErr := &Error{Message: err.Error(), Path: append([]interface{}{}, path...), Err: err}
+	if ferr, ok := err.(fieldError); ok {
+		line, col := location(e.src, ferr.pos)
+		gqlErr.Locations = []Location{{line, col}}
+		gqlErr.Err = nil
+	}
+	e.errs = append(e.errs, gqlErr)
+}
+
+func (e fieldError) Error() string { return e.msg }
+
+// halt stops the execution because of err,
+// which the caller reports, and returns err.
+func (e *executor) halt(err error) error {
+	e.halted = true
+	return err
+}
+
+// selectFields evaluates sel on a value of type typ, or on a plain
+// JSON object if typ is nil. The value is nil for the query type.
+func (e *executor) selectFields(ctx context.Context, typ *Object, val map[string]interface{}, sel []selection, path []interface{}) *OrderedMap {
+	out := &OrderedMap{Values: make(map[string]interface{})}
+	e.collect(ctx, typ, val, sel, path, out, make(map[string]bool))
+	return out
+}
+
+func (e *executor) collect(ctx context.Context, typ *Object, val map[string]interface{}, sel []selection, path []interface{}, out *OrderedMap, visited map[string]bool) {
+	typeName := ""
+	if typ != nil {
+		typeName = typ.Name
+	}
+	for _, s := range sel {
+		if e.halted {
+			return
+		}
+		switch s := s.(type) {
+		case *fragmentSpread:
+			if !e.included(s.directives, path) {
+				continue
+			}
+			f := e.doc.fragments[s.name]
+			if f == nil {
+				e.fail(path, fieldError{fmt.Sprintf("unknown fragment %q", s.name), s.pos})
+				continue
+			}
+			if visited[s.name] || f.typeCond != typeName || !e.included(f.directives, path) {
+				continue
+			}
+			visited[s.name] = true
+			e.collect(ctx, typ, val, f.sel, path, out, visited)
+		case *inlineFragment:
+			if (s.typeCond == "" || s.typeCond == typeName) && e.included(s.directives, path) {
+				e.collect(ctx, typ, val, s.sel, path, out, visited)
+			}
+		case *field:
+			if !e.included(s.directives, path) {
+				continue
+			}
+			key := s.alias
+			if key == "" {
+				key = s.name
+			}
+			fieldPath := append(path[:len(path):len(path)], key)
+			e.fields++
+			if err := ctx.Err(); err != nil {
+				e.fail(fieldPath, e.halt(err))
+				return
+			}
+			if e.fields > MaxFields {
+				e.fail(fieldPath, e.halt(fieldError{fmt.Sprintf("query selects more than %d fields", MaxFields), s.pos}))
+				return
+			}
+			v, err := e.resolve(ctx, typ, val, s, fieldPath)
+			if err != nil {
+				e.fail(fieldPath, err)
+				v = nil
+			}
+			if prev, ok := out.Values[key].(*OrderedMap); ok {
+				// Selections of the same field are merged.
+				if next, ok := v.(*OrderedMap); ok {
+					for _, k := range next.Keys {
+						prev.set(k, next.Values[k])
+					}
+					continue
+				}
+			}
+			out.set(key, v)
+		}
+	}
+}
+
+func (e *executor) resolve(ctx context.Context, typ *Object, val map[string]interface{}, f *field, path []interface{}) (interface{}, error) {
+	if f.name == "__typename" {
+		if typ == nil {
+			return nil, fieldError{"__typename is not defined on JSON values", f.pos}
+		}
+		return typ.Name, nil
+	}
+	if typ == nil {
+		v := val[f.name]
+		if len(f.args) > 0 {
+			return nil, fieldError{fmt.Sprintf("field %q takes no arguments", f.name), f.pos}
+		}
+		return e.complete(ctx, nil, v, f, path)
+	}
+
+	def := typ.Fields[f.name]
+	if def == nil {
+		return nil, fieldError{fmt.Sprintf("no field %q on type %s", f.name, typ.Name), f.pos}
+	}
+	args := make(map[string]interface{})
+	for _, a := range f.args {
+		if !contains(def.Args, a.name) {
+			return nil, fieldError{fmt.Sprintf("no argument %q on field %s.%s", a.name, typ.Name, f.name), f.pos}
+		}
+		args[a.name] = e.value(a.val)
+	}
+	if def.Type != nil && f.sel == nil {
+		return nil, fieldError{fmt.Sprintf("field %q of type %s must have a selection of subfields", f.name, def.Type.Name), f.pos}
+	}
+
+	if def.Resolve == nil {
+		return e.complete(ctx, def.Type, val[f.name], f, path)
+	}
+	e.resolves++
+	if e.resolves > MaxResolves {
+		return nil, e.halt(fieldError{fmt.Sprintf("query makes more than %d lookups", MaxResolves), f.pos})
+	}
+	v, err := def.Resolve(ctx, val, args)
+	if err != nil {
+		return nil, err
+	}
+	v, err = toJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.complete(ctx, def.Type, v, f, path)
+}
+
+// complete selects f's subfields from v,
+// a JSON value of type typ or a list of them.
+func (e *executor) complete(ctx context.Context, typ *Object, v interface{}, f *field, path []interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		if f.sel == nil {
+			return v, nil
+		}
+		l := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			if e.halted {
+				break
+			}
+			item, err := e.complete(ctx, typ, item, f, append(path[:len(path):len(path)], i))
+			if err != nil {
+				e.fail(append(path[:len(path):len(path)], i), err)
+			}
+			l = append(l, item)
+		}
+		return l, nil
+	case map[string]interface{}:
+		if f.sel == nil {
+			return v, nil
+		}
+		return e.selectFields(ctx, typ, v, f.sel, path), nil
+	}
+	if f.sel != nil {
+		return nil, fieldError{fmt.Sprintf("field %q is a scalar and has no subfields", f.name), f.pos}
+	}
+	return v, nil
+}
+
+// included evaluates the @include and @skip directives in dirs.
+func (e *executor) included(dirs []*directive, path []interface{}) bool {
+	for _, d := range dirs {
+		if d.name != "include" && d.name != "skip" {
+			e.fail(path, fieldError{fmt.Sprintf("unknown directive @%s", d.name), d.pos})
+			return false
+		}
+		var cond interface{}
+		for _, a := range d.args {
+			if a.name == "if" {
+				cond = e.value(a.val)
+			}
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			e.fail(path, fieldError{fmt.Sprintf("@%s requires a boolean argument \"if\"", d.name), d.pos})
+			return false
+		}
+		if b == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// value substitutes variables in the parsed value v.
+func (e *executor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case variable:
+		return e.vars[string(v)]
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, x := range v {
+			l[i] = e.value(x)
+		}
+		return l
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			m[k] = e.value(x)
+		}
+		return m
+	}
+	return v
+}
+
+// toJSON converts v to the value it has as decoded JSON.
+func toJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out interface{}
+	err = dec.Decode(&out)
+	return out, errors.Wrap(err)
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
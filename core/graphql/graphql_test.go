@@ -0,0 +1,199 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+func testSchema() *Schema {
+	book := &Object{Name: "Book"}
+	author := &Object{Name: "Author"}
+	books := []map[string]interface{}{
+		{"id": "b1", "title": "Go", "author_id": "a1", "tags": map[string]interface{}{"lang": "en", "pages": 300}},
+		{"id": "b2", "title": "Chain", "author_id": "a2"},
+	}
+	authors := map[string]interface{}{
+		"a1": map[string]string{"id": "a1", "name": "Ann"},
+	}
+	book.Fields = map[string]*Field{
+		"id":    {},
+		"title": {},
+		"tags":  {},
+		"author": {Type: author, Resolve: func(ctx context.Context, parent, args map[string]interface{}) (interface{}, error) {
+			return authors[parent["author_id"].(string)], nil
+		}},
+		"broken": {Resolve: func(ctx context.Context, parent, args map[string]interface{}) (interface{}, error) {
+			return nil, errBoom
+		}},
+	}
+	author.Fields = map[string]*Field{"id": {}, "name": {}}
+	return &Schema{Query: &Object{
+		Name: "Query",
+		Fields: map[string]*Field{
+			"books": {Type: book, Args: []string{"title"}, Resolve: func(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+				if t, ok := args["title"].(string); ok {
+					for _, b := range books {
+						if b["title"] == t {
+							return []interface{}{b}, nil
+						}
+					}
+					return []interface{}{}, nil
+				}
+				return books, nil
+			}},
+		},
+	}}
+}
+
+func TestExecute(t *testing.T) {
+	cases := []struct {
+		query string
+		vars  string
+		want  string
+	}{{
+		query: `{ books { id title } }`,
+		want:  `{"data":{"books":[{"id":"b1","title":"Go"},{"id":"b2","title":"Chain"}]}}`,
+	}, {
+		query: `query Q($t: String = "Go") { books(title: $t) { name: title, author { name } } }`,
+		want:  `{"data":{"books":[{"name":"Go","author":{"name":"Ann"}}]}}`,
+	}, {
+		query: `query Q($t: String!) { books(title: $t) { id } }`,
+		vars:  `{"t": "Chain"}`,
+		want:  `{"data":{"books":[{"id":"b2"}]}}`,
+	}, {
+		query: `{ books(title: "Go") { ...f tags { pages } } } fragment f on Book { __typename id }`,
+		want:  `{"data":{"books":[{"__typename":"Book","id":"b1","tags":{"pages":300}}]}}`,
+	}, {
+		query: `query($yes: Boolean) { books(title: "Go") { id @skip(if: $yes) title @include(if: $yes) ... on Author { name } } }`,
+		vars:  `{"yes": true}`,
+		want:  `{"data":{"books":[{"title":"Go"}]}}`,
+	}, {
+		query: `{ books(title: "Go") { id broken } }`,
+		want:  `{"data":{"books":[{"id":"b1","broken":null}]},"errors":[{"message":"boom","path":["books",0,"broken"]}]}`,
+	}, {
+		query: `{ books { nope } }`,
+		want:  `{"data":{"books":[{"nope":null},{"nope":null}]},"errors":[{"message":"no field \"nope\" on type Book","locations":[{"line":1,"column":11}],"path":["books",0,"nope"]},{"message":"no field \"nope\" on type Book","locations":[{"line":1,"column":11}],"path":["books",1,"nope"]}]}`,
+	}, {
+		query: `{ books { author } }`,
+		want:  `{"data":{"books":[{"author":null},{"author":null}]},"errors":[{"message":"field \"author\" of type Author must have a selection of subfields","locations":[{"line":1,"column":11}],"path":["books",0,"author"]},{"message":"field \"author\" of type Author must have a selection of subfields","locations":[{"line":1,"column":11}],"path":["books",1,"author"]}]}`,
+	}, {
+		query: "{\n  books(title: \"Go\") {\n    id(\n",
+		want:  `{"errors":[{"message":"syntax error: expected name, found end of query","locations":[{"line":4,"column":1}]}]}`,
+	}, {
+		query: `mutation { books { id } }`,
+		want:  `{"errors":[{"message":"mutation operations are not supported"}]}`,
+	}, {
+		query: `query A { books { id } } query B { books { title } }`,
+		want:  `{"errors":[{"message":"operationName is required for a query with more than one operation"}]}`,
+	}}
+
+	s := testSchema()
+	for _, c := range cases {
+		req := &Request{Query: c.query}
+		if c.vars != "" {
+			err := json.Unmarshal([]byte(c.vars), &req.Variables)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp := s.Execute(context.Background(), req)
+		got, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != c.want {
+			t.Errorf("Execute(%q)\ngot  %s\nwant %s", c.query, got, c.want)
+		}
+	}
+}
+
+func TestExecuteResolverError(t *testing.T) {
+	resp := testSchema().Execute(context.Background(), &Request{Query: `{ books { broken } }`})
+	if len(resp.Errors) != 2 || resp.Errors[0].Err != errBoom {
+		t.Errorf("errors = %+v, want 2 with Err = %v", resp.Errors, errBoom)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`{ f(a: -1.5e3, b: "x\"é", c: [1 2], d: {k: null, e: ENUM}, v: $v) { g } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.ops[0].sel[0].(*field).args
+	want := []string{`-1.5e3`, `"x\"é"`, `[1,2]`, `{"e":"ENUM","k":null}`, `"v"`}
+	for i, a := range args {
+		got, _ := json.Marshal(a.val)
+		if string(got) != want[i] {
+			t.Errorf("arg %s = %s want %s", a.name, got, want[i])
+		}
+	}
+	if _, ok := args[4].val.(variable); !ok {
+		t.Errorf("arg v = %#v, want variable", args[4].val)
+	}
+
+	for _, bad := range []string{`{ f(a: 01) }`, `{ f(a: "x) }`, `{ }`, `{ f(a: 1.) }`, `fragment on on T { f }`} {
+		_, err := parse(bad)
+		if err == nil {
+			t.Errorf("parse(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	var calls int
+	node := &Object{Name: "Node"}
+	node.Fields = map[string]*Field{
+		"id": {},
+		"self": {Type: node, Resolve: func(ctx context.Context, parent, args map[string]interface{}) (interface{}, error) {
+			calls++
+			return parent, nil
+		}},
+		"many": {Type: node, Resolve: func(ctx context.Context, parent, args map[string]interface{}) (interface{}, error) {
+			calls++
+			return []interface{}{parent, parent, parent, parent, parent, parent, parent, parent, parent, parent}, nil
+		}},
+	}
+	s := &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"node": {Type: node, Resolve: func(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{"id": "n1"}, nil
+		}},
+	}}}
+
+	cases := []struct {
+		query string
+		want  string
+	}{{
+		query: `{ node { ...F } } fragment F on Node { id self { ...F } }`,
+		want:  `fragment "F" spreads itself`,
+	}, {
+		query: `{ node { ...F } } fragment F on Node { self { ...G } } fragment G on Node { id ... on Node { self { ...F } } }`,
+		want:  `fragment "F" spreads itself`,
+	}, {
+		query: `{ node { self { self { self { self { self { self { self { self { self { self { self { id } } } } } } } } } } } } }`,
+		want:  `fields are nested more than 12 deep`,
+	}, {
+		query: `{ node { many { many { many { many { id } } } } } }`,
+		want:  `query makes more than 1000 lookups`,
+	}}
+	for _, c := range cases {
+		calls = 0
+		resp := s.Execute(context.Background(), &Request{Query: c.query})
+		if len(resp.Errors) != 1 || resp.Errors[0].Message != c.want {
+			t.Errorf("Execute(%q) errors = %+v, want one: %s", c.query, resp.Errors, c.want)
+		}
+		if calls > MaxResolves {
+			t.Errorf("Execute(%q) made %d calls, want at most %d", c.query, calls, MaxResolves)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp := s.Execute(ctx, &Request{Query: `{ node { id } }`})
+	if len(resp.Errors) != 1 || resp.Errors[0].Err != context.Canceled {
+		t.Errorf("errors = %+v, want one with Err = %v", resp.Errors, context.Canceled)
+	}
+}
@@ -0,0 +1,516 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type parseError struct {
+	msg string
+	pos int
+}
+
+func (e parseError) Error() string { return e.msg }
+
+type (
+	document struct {
+		ops       []*operation
+		fragments map[string]*fragment
+	}
+
+	operation struct {
+		kind string // query, mutation, or subscription
+		name string
+		vars []*varDef
+		sel  []selection
+	}
+
+	varDef struct {
+		name    string
+		nonNull bool
+		def     interface{} // default value, or nil
+		hasDef  bool
+	}
+
+	fragment struct {
+		name, typeCond string
+		directives     []*directive
+		sel            []selection
+	}
+
+	// A selection is a *field, *fragmentSpread, or *inlineFragment.
+	selection interface{}
+
+	field struct {
+		alias, name string
+		args        []*argument
+		directives  []*directive
+		sel         []selection
+		pos         int
+	}
+
+	fragmentSpread struct {
+		name       string
+		directives []*directive
+		pos        int
+	}
+
+	inlineFragment struct {
+		typeCond   string
+		directives []*directive
+		sel        []selection
+	}
+
+	argument struct {
+		name string
+		val  interface{}
+	}
+
+	directive struct {
+		name string
+		args []*argument
+		pos  int
+	}
+)
+
+// Parsed values are strings, json.Numbers, bools, nil,
+// []interface{}, map[string]interface{}, and variables.
+type variable string
+
+// parse parses a query document.
+// It supports everything but type system definitions.
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		r := recover()
+		if perr, ok := r.(parseError); ok {
+			err = perr
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+	p := &parser{src: src}
+	p.next()
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.kind != tokEOF {
+		switch {
+		case p.is(tokPunct, "{"):
+			doc.ops = append(doc.ops, &operation{kind: "query", sel: p.selectionSet()})
+		case p.is(tokName, "fragment"):
+			p.next()
+			f := &fragment{name: p.name()}
+			if f.name == "on" {
+				p.errorf("unexpected name %q", f.name)
+			}
+			p.expectName("on")
+			f.typeCond = p.name()
+			f.directives = p.directives()
+			f.sel = p.selectionSet()
+			if doc.fragments[f.name] != nil {
+				p.errorf("duplicate fragment %q", f.name)
+			}
+			doc.fragments[f.name] = f
+		case p.is(tokName, "query"), p.is(tokName, "mutation"), p.is(tokName, "subscription"):
+			op := &operation{kind: p.val}
+			p.next()
+			if p.kind == tokName {
+				op.name = p.name()
+			}
+			if p.is(tokPunct, "(") {
+				op.vars = p.varDefs()
+			}
+			p.directives()
+			op.sel = p.selectionSet()
+			doc.ops = append(doc.ops, op)
+		default:
+			p.errorf("unexpected %s", p.describe())
+		}
+	}
+	if len(doc.ops) == 0 {
+		p.errorf("no operations")
+	}
+	return doc, nil
+}
+
+// The parser holds the parser's internal state.
+// It reads one token ahead.
+type parser struct {
+	src string
+	off int // offset of the next unscanned byte
+
+	kind tokenKind
+	val  string
+	pos  int // offset of the current token
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(parseError{msg: fmt.Sprintf(format, args...), pos: p.pos})
+}
+
+func (p *parser) is(kind tokenKind, val string) bool {
+	return p.kind == kind && p.val == val
+}
+
+func (p *parser) describe() string {
+	switch p.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return "string"
+	}
+	return strconv.Quote(p.val)
+}
+
+func (p *parser) expect(punct string) {
+	if !p.is(tokPunct, punct) {
+		p.errorf("expected %q, found %s", punct, p.describe())
+	}
+	p.next()
+}
+
+func (p *parser) expectName(name string) {
+	if !p.is(tokName, name) {
+		p.errorf("expected %q, found %s", name, p.describe())
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	if p.kind != tokName {
+		p.errorf("expected name, found %s", p.describe())
+	}
+	s := p.val
+	p.next()
+	return s
+}
+
+func (p *parser) varDefs() []*varDef {
+	var defs []*varDef
+	p.expect("(")
+	for !p.is(tokPunct, ")") {
+		p.expect("$")
+		d := &varDef{name: p.name()}
+		p.expect(":")
+		d.nonNull = p.typeRef()
+		if p.is(tokPunct, "=") {
+			p.next()
+			d.def, d.hasDef = p.value(true), true
+		}
+		p.directives()
+		defs = append(defs, d)
+	}
+	p.next()
+	return defs
+}
+
+// typeRef parses a type reference, which this package
+// doesn't check, and reports whether it's non-null.
+func (p *parser) typeRef() (nonNull bool) {
+	if p.is(tokPunct, "[") {
+		p.next()
+		p.typeRef()
+		p.expect("]")
+	} else {
+		p.name()
+	}
+	if p.is(tokPunct, "!") {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) directives() []*directive {
+	var dirs []*directive
+	for p.is(tokPunct, "@") {
+		d := &directive{pos: p.pos}
+		p.next()
+		d.name = p.name()
+		d.args = p.arguments()
+		dirs = append(dirs, d)
+	}
+	return dirs
+}
+
+func (p *parser) arguments() []*argument {
+	if !p.is(tokPunct, "(") {
+		return nil
+	}
+	p.next()
+	var args []*argument
+	for !p.is(tokPunct, ")") {
+		a := &argument{name: p.name()}
+		p.expect(":")
+		a.val = p.value(false)
+		args = append(args, a)
+	}
+	p.next()
+	return args
+}
+
+func (p *parser) selectionSet() []selection {
+	var sel []selection
+	p.expect("{")
+	for !p.is(tokPunct, "}") {
+		if p.is(tokPunct, "...") {
+			pos := p.pos
+			p.next()
+			if p.kind == tokName && p.val != "on" {
+				sel = append(sel, &fragmentSpread{name: p.name(), directives: p.directives(), pos: pos})
+				continue
+			}
+			f := new(inlineFragment)
+			if p.is(tokName, "on") {
+				p.next()
+				f.typeCond = p.name()
+			}
+			f.directives = p.directives()
+			f.sel = p.selectionSet()
+			sel = append(sel, f)
+			continue
+		}
+
+		f := &field{pos: p.pos}
+		f.name = p.name()
+		if p.is(tokPunct, ":") {
+			p.next()
+			f.alias, f.name = f.name, p.name()
+		}
+		f.args = p.arguments()
+		f.directives = p.directives()
+		if p.is(tokPunct, "{") {
+			f.sel = p.selectionSet()
+		}
+		sel = append(sel, f)
+	}
+	p.next()
+	if len(sel) == 0 {
+		p.errorf("empty selection set")
+	}
+	return sel
+}
+
+func (p *parser) value(isConst bool) interface{} {
+	switch p.kind {
+	case tokInt, tokFloat:
+		v := json.Number(p.val)
+		p.next()
+		return v
+	case tokString:
+		v := p.val
+		p.next()
+		return v
+	case tokName:
+		var v interface{}
+		switch p.val {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = p.val // enum values are their names
+		}
+		p.next()
+		return v
+	}
+
+	switch {
+	case p.is(tokPunct, "$") && !isConst:
+		p.next()
+		return variable(p.name())
+	case p.is(tokPunct, "["):
+		p.next()
+		l := []interface{}{}
+		for !p.is(tokPunct, "]") {
+			l = append(l, p.value(isConst))
+		}
+		p.next()
+		return l
+	case p.is(tokPunct, "{"):
+		p.next()
+		m := make(map[string]interface{})
+		for !p.is(tokPunct, "}") {
+			k := p.name()
+			p.expect(":")
+			m[k] = p.value(isConst)
+		}
+		p.next()
+		return m
+	}
+	p.errorf("expected value, found %s", p.describe())
+	return nil
+}
+
+// next scans the next token. Commas, like
+// whitespace and comments, are insignificant.
+func (p *parser) next() {
+	for p.off < len(p.src) {
+		c := p.src[p.off]
+		if c == '#' {
+			for p.off < len(p.src) && p.src[p.off] != '\n' && p.src[p.off] != '\r' {
+				p.off++
+			}
+		} else if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.off++
+		} else if strings.HasPrefix(p.src[p.off:], "\ufeff") {
+			p.off += len("\ufeff")
+		} else {
+			break
+		}
+	}
+	p.pos = p.off
+	if p.off == len(p.src) {
+		p.kind, p.val = tokEOF, ""
+		return
+	}
+
+	c := p.src[p.off]
+	switch {
+	case strings.HasPrefix(p.src[p.off:], "..."):
+		p.kind, p.val = tokPunct, "..."
+		p.off += 3
+	case strings.IndexByte("!$():=@[]{|}", c) >= 0:
+		p.kind, p.val = tokPunct, string(c)
+		p.off++
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		end := p.off + 1
+		for end < len(p.src) && isNameByte(p.src[end]) {
+			end++
+		}
+		p.kind, p.val = tokName, p.src[p.off:end]
+		p.off = end
+	case c == '-' || '0' <= c && c <= '9':
+		p.number()
+	case c == '"':
+		p.kind, p.val = tokString, p.str()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.off:])
+		p.errorf("unexpected character %q", r)
+	}
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func (p *parser) number() {
+	end := p.off
+	digits := func() {
+		start := end
+		for end < len(p.src) && '0' <= p.src[end] && p.src[end] <= '9' {
+			end++
+		}
+		if end == start {
+			p.errorf("malformed number")
+		}
+	}
+	if p.src[end] == '-' {
+		end++
+	}
+	intStart := end
+	digits()
+	if p.src[intStart] == '0' && end-intStart > 1 {
+		p.errorf("malformed number %q", p.src[p.off:end])
+	}
+	p.kind = tokInt
+	if end < len(p.src) && p.src[end] == '.' {
+		end++
+		digits()
+		p.kind = tokFloat
+	}
+	if end < len(p.src) && (p.src[end] == 'e' || p.src[end] == 'E') {
+		end++
+		if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+			end++
+		}
+		digits()
+		p.kind = tokFloat
+	}
+	if end < len(p.src) && (isNameByte(p.src[end]) || p.src[end] == '.') {
+		p.errorf("malformed number %q", p.src[p.off:end+1])
+	}
+	p.val = p.src[p.off:end]
+	p.off = end
+}
+
+// str scans a string, but not a block string.
+func (p *parser) str() string {
+	var b []byte
+	i := p.off + 1
+	for {
+		if i >= len(p.src) || p.src[i] == '\n' || p.src[i] == '\r' {
+			p.errorf("unterminated string")
+		}
+		c := p.src[i]
+		if c == '"' {
+			if i == p.off+1 && strings.HasPrefix(p.src[i:], `""`) {
+				p.errorf("block strings are not supported")
+			}
+			p.off = i + 1
+			return string(b)
+		}
+		if c != '\\' {
+			b = append(b, c)
+			i++
+			continue
+		}
+		if i+1 >= len(p.src) {
+			p.errorf("unterminated string")
+		}
+		switch e := p.src[i+1]; e {
+		case '"', '\\', '/':
+			b = append(b, e)
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			if i+6 > len(p.src) {
+				p.errorf("bad unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[i+2:i+6], 16, 16)
+			if err != nil {
+				p.errorf("bad unicode escape %q", p.src[i:i+6])
+			}
+			b = append(b, string(rune(r))...)
+			i += 4
+		default:
+			p.errorf("bad escape %q", p.src[i:i+2])
+		}
+		i += 2
+	}
+}
+
+// location returns the 1-based line and column of pos in src.
+func location(src string, pos int) (line, col int) {
+	line, col = 1, 1
+	for i, r := range src[:pos] {
+		if r == '\n' || r == '\r' && !strings.HasPrefix(src[i+1:], "\n") {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
@@ -0,0 +1,162 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Limits on the work one query may make the executor do, so
+// that a client can't load the core with a query that nests
+// related objects without bound.
+const (
+	// MaxDepth is the deepest a query may nest fields,
+	// counting those in its fragments.
+	MaxDepth = 12
+
+	// MaxFields is the most fields, including the fields
+	// of each element of a list, a query may select.
+	MaxFields = 100000
+
+	// MaxResolves is the most calls to Field.Resolve
+	// a query may make.
+	MaxResolves = 1000
+)
+
+// validate checks, before doc is executed, that its fragments
+// don't spread themselves, directly or through other fragments,
+// and that its operations nest fields at most MaxDepth deep.
+func validate(doc *document) error {
+	v := &validator{
+		doc:    doc,
+		state:  make(map[string]int),
+		depths: make(map[string]int),
+	}
+	var names []string
+	for name := range doc.fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names) // report the same error each time
+	for _, name := range names {
+		err := v.checkCycles(name)
+		if err != nil {
+			return err
+		}
+	}
+	for _, op := range doc.ops {
+		if pos := v.deepField(op.sel, 0); pos >= 0 {
+			return fieldError{fmt.Sprintf("fields are nested more than %d deep", MaxDepth), pos}
+		}
+	}
+	return nil
+}
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type validator struct {
+	doc    *document
+	state  map[string]int // of each fragment, in checkCycles
+	depths map[string]int // of each fragment's selection
+}
+
+// checkCycles returns an error if the fragment named
+// name spreads a fragment that spreads it in turn.
+func (v *validator) checkCycles(name string) error {
+	f := v.doc.fragments[name]
+	if f == nil || v.state[name] == visited {
+		return nil
+	}
+	v.state[name] = visiting
+	for _, s := range spreads(f.sel, nil) {
+		if v.state[s.name] == visiting {
+			return fieldError{fmt.Sprintf("fragment %q spreads itself", s.name), s.pos}
+		}
+		err := v.checkCycles(s.name)
+		if err != nil {
+			return err
+		}
+	}
+	v.state[name] = visited
+	return nil
+}
+
+// spreads appends to a the fragment spreads in sel,
+// including those in its fields and inline fragments.
+func spreads(sel []selection, a []*fragmentSpread) []*fragmentSpread {
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *fragmentSpread:
+			a = append(a, s)
+		case *field:
+			a = spreads(s.sel, a)
+		case *inlineFragment:
+			a = spreads(s.sel, a)
+		}
+	}
+	return a
+}
+
+// depth returns how deep sel nests fields. Fragments
+// must already have been checked for cycles.
+func (v *validator) depth(sel []selection) int {
+	max := 0
+	for _, s := range sel {
+		var d int
+		switch s := s.(type) {
+		case *field:
+			d = 1 + v.depth(s.sel)
+		case *fragmentSpread:
+			d = v.fragmentDepth(s.name)
+		case *inlineFragment:
+			d = v.depth(s.sel)
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// fragmentDepth is depth of the selection of the fragment named
+// name, computed once however often the fragment is spread.
+func (v *validator) fragmentDepth(name string) int {
+	if d, ok := v.depths[name]; ok {
+		return d
+	}
+	f := v.doc.fragments[name]
+	if f == nil {
+		return 0
+	}
+	d := v.depth(f.sel)
+	v.depths[name] = d
+	return d
+}
+
+// deepField returns the position of a field in sel, which is
+// nested depth fields deep, that is nested more than MaxDepth
+// deep, or -1 if there is none.
+func (v *validator) deepField(sel []selection, depth int) int {
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *field:
+			if depth+1 > MaxDepth {
+				return s.pos
+			}
+			if depth+1+v.depth(s.sel) > MaxDepth {
+				return v.deepField(s.sel, depth+1)
+			}
+		case *fragmentSpread:
+			if depth+v.fragmentDepth(s.name) > MaxDepth {
+				return v.deepField(v.doc.fragments[s.name].sel, depth)
+			}
+		case *inlineFragment:
+			if depth+v.depth(s.sel) > MaxDepth {
+				return v.deepField(s.sel, depth)
+			}
+		}
+	}
+	return -1
+}
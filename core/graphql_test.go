@@ -0,0 +1,17 @@
+package core
+
+import "testing"
+
+func TestShiftPlaceholders(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"", ""},
+		{"asset_alias=$1", "asset_alias=$2"},
+		{"amount > $9 AND tags.x=$10", "amount > $10 AND tags.x=$11"},
+		{"reference_data.note='$1' OR id=$2", "reference_data.note='$1' OR id=$3"},
+	}
+	for _, c := range cases {
+		if got := shiftPlaceholders(c.in); got != c.want {
+			t.Errorf("shiftPlaceholders(%q) = %q want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/generator"
+	"chain/core/tenant"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// errNotGenerator is returned when halting or resuming
+// a core that is not a generator.
+var errNotGenerator = errors.New("core is not a generator")
+
+// checkHalted returns txbuilder.ErrHalted if this core is a
+// generator whose operator has halted pool admission.
+func (h *Handler) checkHalted(ctx context.Context) error {
+	if h.Config == nil || !h.Config.IsGenerator {
+		return nil
+	}
+	halt, err := generator.GetHalt(ctx, h.DB)
+	if err != nil {
+		return err
+	}
+	if halt == nil || !halt.Pool {
+		return nil
+	}
+	return errors.WithDetail(txbuilder.ErrHalted, halt.Reason)
+}
+
+// POST /halt-generator
+//
+// Stops block generation at once, and, if halt_pool is true,
+// refuses new transactions too. The halt persists across
+// restarts until /resume-generator is called.
+func (h *Handler) haltGenerator(ctx context.Context, in struct {
+	HaltPool bool   `json:"halt_pool"`
+	Reason   string `json:"reason"`
+}) (*generator.Halt, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	if h.Config == nil || !h.Config.IsGenerator {
+		return nil, errNotGenerator
+	}
+	if in.Reason == "" {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "reason is required")
+	}
+	return generator.SetHalt(ctx, h.DB, in.HaltPool, in.Reason, accessTokenID(ctx))
+}
+
+// POST /resume-generator
+func (h *Handler) resumeGenerator(ctx context.Context, in struct {
+	Reason string `json:"reason"`
+}) error {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
+	if h.Config == nil || !h.Config.IsGenerator {
+		return errNotGenerator
+	}
+	if in.Reason == "" {
+		return errors.WithDetail(httpjson.ErrBadRequest, "reason is required")
+	}
+	return generator.Resume(ctx, h.DB, in.Reason, accessTokenID(ctx))
+}
@@ -2,15 +2,24 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 
 	"chain/core/mockhsm"
+	"chain/core/signers"
+	"chain/core/tenant"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/keystore"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/net/http/httpjson"
 )
 
 func (h *Handler) mockhsmCreateKey(ctx context.Context, in struct{ Alias string }) (result *mockhsm.XPub, err error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
 	result, err = h.HSM.XCreate(ctx, in.Alias)
 	if err != nil {
 		return result, err
@@ -19,6 +28,9 @@ func (h *Handler) mockhsmCreateKey(ctx context.Context, in struct{ Alias string
 }
 
 func (h *Handler) mockhsmListKeys(ctx context.Context, query requestQuery) (page, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return page{}, errOtherTenant
+	}
 	limit := defGenericPageSize
 
 	xpubs, after, err := h.HSM.ListKeys(ctx, query.Aliases, query.After, limit)
@@ -41,26 +53,173 @@ func (h *Handler) mockhsmListKeys(ctx context.Context, query requestQuery) (page
 }
 
 func (h *Handler) mockhsmDelKey(ctx context.Context, xpub chainkd.XPub) error {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
 	return h.HSM.DeleteChainKDKey(ctx, xpub)
 }
 
+// POST /mockhsm/export-key
+//
+// Returns the xprv of an xpub as a key file encrypted
+// under passphrase. See package keystore.
+func (h *Handler) mockhsmExportKey(ctx context.Context, in struct {
+	XPub       chainkd.XPub `json:"xpub"`
+	Passphrase string       `json:"passphrase"`
+}) (*keystore.Key, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	if in.Passphrase == "" {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "passphrase is required")
+	}
+	return h.HSM.XExport(ctx, in.XPub, []byte(in.Passphrase))
+}
+
+// POST /mockhsm/import-key
+//
+// Stores the xprv of a key file, such as one from
+// /mockhsm/export-key, under alias, if given.
+func (h *Handler) mockhsmImportKey(ctx context.Context, in struct {
+	Alias      string        `json:"alias"`
+	Key        *keystore.Key `json:"key"`
+	Passphrase string        `json:"passphrase"`
+}) (*mockhsm.XPub, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	if in.Key == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "key is required")
+	}
+	return h.HSM.XImport(ctx, in.Alias, in.Key, []byte(in.Passphrase))
+}
+
+// POST /mockhsm/backup-keys
+//
+// Returns the keys with the given aliases, or all keys, wrapped
+// under the operator's key-encryption key, kek, a 32-byte hex
+// string, for /mockhsm/restore-keys on another core. The metadata
+// set for each key with /set-key-metadata goes with it.
+func (h *Handler) mockhsmBackupKeys(ctx context.Context, in struct {
+	KEK     chainjson.HexBytes `json:"kek"`
+	Aliases []string           `json:"aliases"`
+}) (*mockhsm.Backup, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	metadata := make(map[string]json.RawMessage)
+	var after string
+	for {
+		const limit = 1000
+		mds, next, err := signers.ListKeyMetadata(ctx, h.DB, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, md := range mds {
+			b, err := json.Marshal(md)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			metadata[md.XPub.String()] = b
+		}
+		if len(mds) < limit {
+			break
+		}
+		after = next
+	}
+	return h.HSM.Backup(ctx, in.KEK, in.Aliases, metadata)
+}
+
+// POST /mockhsm/restore-keys
+//
+// Restores the keys of a backup from /mockhsm/backup-keys,
+// after checking its integrity, and the metadata of keys that
+// don't have any on this core.
+func (h *Handler) mockhsmRestoreKeys(ctx context.Context, in struct {
+	KEK    chainjson.HexBytes `json:"kek"`
+	Backup *mockhsm.Backup    `json:"backup"`
+}) ([]*mockhsm.RestoredKey, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	if in.Backup == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "backup is required")
+	}
+	keys, err := h.HSM.Restore(ctx, in.KEK, in.Backup)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.Type != "chain_kd" || len(k.Metadata) == 0 {
+			continue
+		}
+		var md signers.KeyMetadata
+		err = json.Unmarshal(k.Metadata, &md)
+		if err != nil {
+			return keys, errors.Wrap(err, "decoding key metadata")
+		}
+		copy(md.XPub[:], k.Pub)
+		existing, err := signers.GetKeyMetadata(ctx, h.DB, []string{md.XPub.String()})
+		if err != nil {
+			return keys, err
+		}
+		if existing[md.XPub.String()] != nil {
+			continue
+		}
+		err = signers.SetKeyMetadata(ctx, h.DB, &md)
+		if err != nil {
+			return keys, err
+		}
+	}
+	return keys, nil
+}
+
 func (h *Handler) mockhsmSignTemplates(ctx context.Context, x struct {
-	Txs   []*txbuilder.Template `json:"transactions"`
-	XPubs []string              `json:"xpubs"`
+	Txs   []*signing.Template `json:"transactions"`
+	XPubs []string            `json:"xpubs"`
 }) []interface{} {
 	resp := make([]interface{}, 0, len(x.Txs))
 	for _, tx := range x.Txs {
-		err := txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate)
+		err := h.mockhsmSign(ctx, tx, x.XPubs)
 		if err != nil {
 			info, _ := errInfo(err)
 			resp = append(resp, info)
 		} else {
+			h.updateSigningRequest(ctx, tx)
 			resp = append(resp, tx)
 		}
 	}
 	return resp
 }
 
+// mockhsmSign signs tpl with the MockHSM's keys among xpubs,
+// after checking that the tenant ctx acts for may use them, that
+// tpl is for this blockchain and approved, and that this core
+// built the witnesses they sign.
+func (h *Handler) mockhsmSign(ctx context.Context, tpl *signing.Template, xpubs []string) error {
+	if tenant.FromContext(ctx) != tenant.Default {
+		// The MockHSM's keys belong to the default tenant.
+		return errOtherTenant
+	}
+	if tpl.Transaction != nil {
+		err := txbuilder.CheckBlockchain(tpl.Transaction, h.Chain.InitialBlockHash)
+		if err != nil {
+			return err
+		}
+	}
+	err := h.requireApproval(ctx, tpl.Transaction)
+	if err != nil {
+		return err
+	}
+	err = signing.RequireCommitments(tpl, xpubs, func(c []byte) (bool, error) {
+		return builtCommitment(ctx, h.DB, c)
+	})
+	if err != nil {
+		return err
+	}
+	return signing.Sign(ctx, tpl, xpubs, h.mockhsmSignTemplate)
+}
+
 func (h *Handler) mockhsmSignTemplate(ctx context.Context, xpubstr string, path [][]byte, data [32]byte) ([]byte, error) {
 	var xpub chainkd.XPub
 	err := xpub.UnmarshalText([]byte(xpubstr))
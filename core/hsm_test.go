@@ -12,6 +12,7 @@ import (
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
 	"chain/protocol/bc"
@@ -35,7 +36,7 @@ func TestMockHSM(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	acct1, err := accounts.Create(ctx, []string{xpub1.XPub.String()}, 1, "", nil, nil)
+	acct1, err := accounts.Create(ctx, []string{xpub1.XPub.String()}, 1, "", nil, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,7 +45,7 @@ func TestMockHSM(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	acct2, err := accounts.Create(ctx, []string{xpub2.String()}, 1, "", nil, nil)
+	acct2, err := accounts.Create(ctx, []string{xpub2.String()}, 1, "", nil, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -81,18 +82,22 @@ func TestMockHSM(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = recordCommitments(ctx, db, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	h := &Handler{HSM: mockhsm}
+	h := &Handler{HSM: mockhsm, DB: db}
 	outTmpls := h.mockhsmSignTemplates(ctx, struct {
-		Txs   []*txbuilder.Template `json:"transactions"`
-		XPubs []string              `json:"xpubs"`
-	}{[]*txbuilder.Template{tmpl}, []string{xpub1.XPub.String()}})
+		Txs   []*signing.Template `json:"transactions"`
+		XPubs []string            `json:"xpubs"`
+	}{[]*signing.Template{tmpl}, []string{xpub1.XPub.String()}})
 	if len(outTmpls) != 1 {
 		t.Fatalf("expected 1 output template, got %d", len(outTmpls))
 	}
-	outTmpl, ok := outTmpls[0].(*txbuilder.Template)
+	outTmpl, ok := outTmpls[0].(*signing.Template)
 	if !ok {
-		t.Fatalf("expected a *txbuilder.Template, got %T (%v)", outTmpls[0], outTmpls[0])
+		t.Fatalf("expected a *signing.Template, got %T (%v)", outTmpls[0], outTmpls[0])
 	}
 	if len(outTmpl.SigningInstructions) != 2 {
 		t.Fatalf("expected 2 signing instructions, got %d", len(outTmpl.SigningInstructions))
@@ -102,13 +107,13 @@ func TestMockHSM(t *testing.T) {
 	inspectSigInst(t, outTmpl.SigningInstructions[1], false)
 }
 
-func inspectSigInst(t *testing.T, si *txbuilder.SigningInstruction, expectSig bool) {
+func inspectSigInst(t *testing.T, si *signing.SigningInstruction, expectSig bool) {
 	if len(si.WitnessComponents) != 1 {
 		t.Fatalf("len(si.WitnessComponents) is %d, want 1", len(si.WitnessComponents))
 	}
-	s, ok := si.WitnessComponents[0].(*txbuilder.SignatureWitness)
+	s, ok := si.WitnessComponents[0].(*signing.SignatureWitness)
 	if !ok {
-		t.Fatalf("si.WitnessComponents[0] has type %T, want *txbuilder.SignatureWitness", si.WitnessComponents[0])
+		t.Fatalf("si.WitnessComponents[0] has type %T, want *signing.SignatureWitness", si.WitnessComponents[0])
 	}
 	if len(s.Sigs) != 1 {
 		t.Fatalf("len(s.Sigs) is %d, want 1", len(s.Sigs))
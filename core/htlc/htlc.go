@@ -0,0 +1,586 @@
+// Package htlc coordinates hash-timelock contracts, for
+// atomic swaps with other blockchains.
+//
+// An operator records each contract Chain Core takes part in,
+// either as the recipient, who claims the locked output by
+// revealing a preimage of its hash, or as the refunder, who
+// reclaims it once the contract expires. Chain Core watches the
+// blockchain for outputs locked by tracked contracts and for
+// preimages revealed when any contract is claimed, and when it
+// can, builds, signs, and submits the claim or refund itself.
+package htlc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/ripemd160"
+
+	"chain/core/mockhsm"
+	"chain/core/pin"
+	"chain/core/tenant"
+	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+// PinName is used to identify the pin associated
+// with the HTLC block processor.
+const PinName = "htlc"
+
+const (
+	// RoleRecipient is the role of the party that
+	// claims the output by revealing the preimage.
+	RoleRecipient = "recipient"
+
+	// RoleRefund is the role of the party that
+	// reclaims the output once the contract expires.
+	RoleRefund = "refund"
+)
+
+const (
+	StatusPending  = "pending"  // no output locked by the contract yet
+	StatusLocked   = "locked"   // an output is locked by the contract
+	StatusClaimed  = "claimed"  // the recipient spent the output
+	StatusRefunded = "refunded" // the refunder spent the output
+)
+
+const defaultLimit = 100
+
+// resubmitDelay is how long settle waits before submitting
+// a contract's claim or refund again, if the one it
+// submitted hasn't spent the contract's output.
+const resubmitDelay = time.Hour
+
+var (
+	// ErrBadContract is returned by Track for
+	// incomplete or malformed contracts.
+	ErrBadContract = errors.New("invalid hash-timelock contract")
+
+	// ErrBadPreimage is returned when a preimage is not
+	// txbuilder.HTLCPreimageSize bytes or does not hash
+	// to its contract's hash.
+	ErrBadPreimage = errors.New("preimage does not match hash")
+)
+
+// hashOps maps the hash functions contracts
+// may use to the opcodes that compute them.
+var hashOps = map[string]vm.Op{
+	"sha256":    vm.OP_SHA256,
+	"sha3":      vm.OP_SHA3,
+	"ripemd160": vm.OP_RIPEMD160,
+}
+
+// Contract is a hash-timelock contract Chain Core takes part in.
+// Chain Core signs with XPub, at DerivationPath, for its own side
+// of the contract, and pays the output it claims or reclaims to
+// Destination.
+type Contract struct {
+	ID              string               `json:"id"`
+	HashFunction    string               `json:"hash_function"`
+	Hash            chainjson.HexBytes   `json:"hash"`
+	Expiry          time.Time            `json:"expiry"`
+	Role            string               `json:"role"`
+	XPub            string               `json:"xpub"`
+	DerivationPath  []chainjson.HexBytes `json:"derivation_path"`
+	CounterpartyKey chainjson.HexBytes   `json:"counterparty_key"`
+	Destination     chainjson.HexBytes   `json:"destination"`
+	ControlProgram  chainjson.HexBytes   `json:"control_program"`
+	Status          string               `json:"status"`
+	PreimageKnown   bool                 `json:"preimage_known"`
+	TxID            *bc.Hash             `json:"transaction_id"`
+	Position        *uint32              `json:"position"`
+	AssetID         *bc.AssetID          `json:"asset_id"`
+	Amount          *uint64              `json:"amount"`
+	SpendTxID       *bc.Hash             `json:"spend_transaction_id"`
+}
+
+// SubmitFunc submits a signed claim or refund
+// transaction to the blockchain.
+type SubmitFunc func(context.Context, *signing.Template) error
+
+// Coordinator tracks contracts, and claims
+// or refunds them when it can.
+type Coordinator struct {
+	db       pg.DB
+	chain    *protocol.Chain
+	pinStore *pin.Store
+	hsm      *mockhsm.HSM
+}
+
+// NewCoordinator returns a Coordinator that signs with keys in hsm.
+func NewCoordinator(db pg.DB, chain *protocol.Chain, pinStore *pin.Store, hsm *mockhsm.HSM) *Coordinator {
+	return &Coordinator{
+		db:       db,
+		chain:    chain,
+		pinStore: pinStore,
+		hsm:      hsm,
+	}
+}
+
+// Track records contract c, filling in its control program,
+// and returns it. Outputs already locked by the contract are
+// not found; fund the contract only after tracking it.
+// If the preimage is known, it may be given; otherwise it is
+// learned when revealed on the blockchain or to RevealPreimage.
+func (co *Coordinator) Track(ctx context.Context, c *Contract, preimage []byte) (*Contract, error) {
+	hashOp, ok := hashOps[c.HashFunction]
+	if !ok {
+		return nil, errors.WithDetailf(ErrBadContract, "unsupported hash function %q", c.HashFunction)
+	}
+	if c.Role != RoleRecipient && c.Role != RoleRefund {
+		return nil, errors.WithDetailf(ErrBadContract, "role must be %q or %q", RoleRecipient, RoleRefund)
+	}
+	if c.Expiry.IsZero() {
+		return nil, errors.WithDetail(ErrBadContract, "expiry is required")
+	}
+	if len(c.Destination) == 0 {
+		return nil, errors.WithDetail(ErrBadContract, "destination is required")
+	}
+	if len(c.CounterpartyKey) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrBadContract, "bad counterparty key")
+	}
+	var xpub chainkd.XPub
+	err := xpub.UnmarshalText([]byte(c.XPub))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadContract, "bad xpub")
+	}
+	if preimage != nil && len(preimage) != txbuilder.HTLCPreimageSize {
+		return nil, errors.WithDetailf(ErrBadPreimage, "preimage is %d bytes, want %d", len(preimage), txbuilder.HTLCPreimageSize)
+	}
+	if preimage != nil && !bytes.Equal(hashPreimage(c.HashFunction, preimage), c.Hash) {
+		return nil, errors.WithDetail(ErrBadPreimage, "preimage does not match the contract's hash")
+	}
+
+	path := make([][]byte, 0, len(c.DerivationPath))
+	for _, p := range c.DerivationPath {
+		path = append(path, p)
+	}
+	ours := xpub.Derive(path).PublicKey()
+	recipient, refund := ed25519.PublicKey(c.CounterpartyKey), ours
+	if c.Role == RoleRecipient {
+		recipient, refund = ours, ed25519.PublicKey(c.CounterpartyKey)
+	}
+	prog, err := txbuilder.HTLC(hashOp, c.Hash, recipient, refund, c.Expiry)
+	if errors.Root(err) == txbuilder.ErrBadHTLC {
+		return nil, errors.WithDetail(ErrBadContract, errors.Detail(err))
+	} else if err != nil {
+		return nil, err
+	}
+
+	var preimageArg interface{} // NULL, not an empty bytea, if unknown
+	if preimage != nil {
+		preimageArg = preimage
+	}
+	const q = `
+		INSERT INTO htlcs (hash_function, hash, expiry_ms, role, xpub, derivation_path,
+			counterparty_key, destination, control_program, preimage, status, tenant)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`
+	err = co.db.QueryRow(ctx, q, c.HashFunction, []byte(c.Hash), bc.Millis(c.Expiry), c.Role,
+		c.XPub, pq.ByteaArray(path), []byte(c.CounterpartyKey), []byte(c.Destination),
+		prog, preimageArg, StatusPending, tenant.FromContext(ctx)).Scan(&c.ID)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetail(ErrBadContract, "contract is already tracked")
+	} else if err != nil {
+		return nil, errors.Wrap(err, "inserting contract")
+	}
+	c.ControlProgram = prog
+	c.Status = StatusPending
+	c.PreimageKnown = preimage != nil
+	return c, nil
+}
+
+// RevealPreimage records preimage for every tracked contract
+// whose hash it matches, so Chain Core can claim those it is
+// the recipient of. It returns the number of contracts updated.
+func (co *Coordinator) RevealPreimage(ctx context.Context, preimage []byte) (int, error) {
+	if len(preimage) != txbuilder.HTLCPreimageSize {
+		return 0, errors.WithDetailf(ErrBadPreimage, "preimage is %d bytes, want %d", len(preimage), txbuilder.HTLCPreimageSize)
+	}
+	var (
+		fns    pq.StringArray
+		hashes pq.ByteaArray
+	)
+	for fn := range hashOps {
+		fns = append(fns, fn)
+		hashes = append(hashes, hashPreimage(fn, preimage))
+	}
+	const q = `
+		UPDATE htlcs SET preimage=$1
+		FROM (SELECT unnest($2::text[]) AS hash_function, unnest($3::bytea[]) AS hash) h
+		WHERE htlcs.hash_function=h.hash_function AND htlcs.hash=h.hash
+			AND htlcs.preimage IS NULL AND ($4='' OR htlcs.tenant=$4)
+	`
+	res, err := co.db.Exec(ctx, q, preimage, fns, hashes, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, errors.Wrap(err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), errors.Wrap(err)
+}
+
+// Find returns the contract with the given ID.
+func (co *Coordinator) Find(ctx context.Context, id string) (*Contract, error) {
+	contracts, err := co.list(ctx, `id=$2`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(contracts) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "contract %s", id)
+	}
+	return contracts[0], nil
+}
+
+// List returns contracts with the given status, or with
+// any status if status is empty, in pages ordered by ID.
+func (co *Coordinator) List(ctx context.Context, status, after string, limit int) ([]*Contract, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	const where = `($2='' OR status=$2) AND ($3='' OR id<$3) ORDER BY id DESC LIMIT $4`
+	contracts, err := co.list(ctx, where, status, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(contracts) > 0 {
+		after = contracts[len(contracts)-1].ID
+	}
+	return contracts, after, nil
+}
+
+// list returns the contracts visible to the tenant that ctx acts
+// for that match where. The tenant is parameter $1 of the query,
+// and args are parameters $2 and up.
+func (co *Coordinator) list(ctx context.Context, where string, args ...interface{}) ([]*Contract, error) {
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+	q := `
+		SELECT id, hash_function, hash, expiry_ms, role, xpub, derivation_path,
+			counterparty_key, destination, control_program, status, preimage IS NOT NULL,
+			tx_hash, index, asset_id, amount, spend_tx_hash
+		FROM htlcs
+		WHERE ($1='' OR tenant=$1) AND ` + where
+	rows, err := co.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	var contracts []*Contract
+	for rows.Next() {
+		var (
+			c           Contract
+			expiryMS    uint64
+			path        pq.ByteaArray
+			txHash      *bc.Hash
+			index       sql.NullInt64
+			assetID     *bc.AssetID
+			amount      sql.NullInt64
+			spendTxHash *bc.Hash
+		)
+		err := rows.Scan(&c.ID, &c.HashFunction, (*[]byte)(&c.Hash), &expiryMS, &c.Role,
+			&c.XPub, &path, (*[]byte)(&c.CounterpartyKey), (*[]byte)(&c.Destination),
+			(*[]byte)(&c.ControlProgram), &c.Status, &c.PreimageKnown,
+			&txHash, &index, &assetID, &amount, &spendTxHash)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		c.Expiry = time.Unix(0, int64(expiryMS)*int64(time.Millisecond)).UTC()
+		for _, p := range path {
+			c.DerivationPath = append(c.DerivationPath, p)
+		}
+		c.TxID, c.AssetID, c.SpendTxID = txHash, assetID, spendTxHash
+		if index.Valid {
+			pos := uint32(index.Int64)
+			c.Position = &pos
+		}
+		if amount.Valid {
+			amt := uint64(amount.Int64)
+			c.Amount = &amt
+		}
+		contracts = append(contracts, &c)
+	}
+	return contracts, errors.Wrap(rows.Err())
+}
+
+// ProcessBlocks follows the blockchain, recording outputs locked
+// by tracked contracts, their spends, and the preimages revealed
+// by claims. After each block, it claims or refunds the contracts
+// Chain Core can, passing each signed transaction to submit.
+// It returns when ctx is done.
+func (co *Coordinator) ProcessBlocks(ctx context.Context, submit SubmitFunc) {
+	if co.pinStore == nil {
+		return
+	}
+	go co.settleBlocks(ctx, submit)
+	co.pinStore.ProcessBlocks(ctx, co.chain, PinName, co.indexBlock)
+}
+
+func (co *Coordinator) indexBlock(ctx context.Context, b *bc.Block) error {
+	var (
+		outProgs    pq.ByteaArray
+		outTxHashes pq.StringArray
+		outIndexes  pq.Int64Array
+		outAssets   pq.StringArray
+		outAmounts  pq.Int64Array
+
+		spendProgs    pq.ByteaArray
+		spendStatuses pq.StringArray
+		spendTxHashes pq.StringArray
+
+		revealFns       pq.StringArray
+		revealHashes    pq.ByteaArray
+		revealPreimages pq.ByteaArray
+	)
+	for _, tx := range b.Transactions {
+		for i, out := range tx.Outputs {
+			outProgs = append(outProgs, out.ControlProgram)
+			outTxHashes = append(outTxHashes, tx.Hash.String())
+			outIndexes = append(outIndexes, int64(i))
+			outAssets = append(outAssets, out.AssetID.String())
+			outAmounts = append(outAmounts, int64(out.Amount))
+		}
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			// Contracts are claimed with arguments
+			// [PREIMAGE 1 ...] and refunded with [0 ...].
+			// Any spend whose first argument might be a
+			// preimage is checked against tracked hashes.
+			args := in.Arguments()
+			status := StatusRefunded
+			if len(args) > 1 && len(args[0]) > 0 {
+				status = StatusClaimed
+			}
+			if status == StatusClaimed && len(args[0]) == txbuilder.HTLCPreimageSize {
+				for fn := range hashOps {
+					revealFns = append(revealFns, fn)
+					revealHashes = append(revealHashes, hashPreimage(fn, args[0]))
+					revealPreimages = append(revealPreimages, args[0])
+				}
+			}
+			spendProgs = append(spendProgs, in.ControlProgram())
+			spendStatuses = append(spendStatuses, status)
+			spendTxHashes = append(spendTxHashes, tx.Hash.String())
+		}
+	}
+
+	const revealQ = `
+		UPDATE htlcs SET preimage=r.preimage
+		FROM (
+			SELECT unnest($1::text[]) AS hash_function,
+				unnest($2::bytea[]) AS hash,
+				unnest($3::bytea[]) AS preimage
+		) r
+		WHERE htlcs.hash_function=r.hash_function AND htlcs.hash=r.hash
+			AND htlcs.preimage IS NULL
+	`
+	_, err := co.db.Exec(ctx, revealQ, revealFns, revealHashes, revealPreimages)
+	if err != nil {
+		return errors.Wrap(err, "recording revealed preimages")
+	}
+
+	// Spends are recorded before new outputs, and outputs only
+	// update pending contracts, so a contract whose output is
+	// both created and spent in b ends up spent.
+	const spendQ = `
+		UPDATE htlcs SET status=s.status, spend_tx_hash=s.spend_tx_hash
+		FROM (
+			SELECT unnest($1::bytea[]) AS control_program,
+				unnest($2::text[]) AS status,
+				unnest($3::text[]) AS spend_tx_hash
+		) s
+		WHERE htlcs.control_program=s.control_program AND htlcs.status IN ($4, $5)
+	`
+	_, err = co.db.Exec(ctx, spendQ, spendProgs, spendStatuses, spendTxHashes, StatusPending, StatusLocked)
+	if err != nil {
+		return errors.Wrap(err, "recording contract spends")
+	}
+
+	const lockQ = `
+		UPDATE htlcs SET status=$6, tx_hash=o.tx_hash, index=o.index,
+			asset_id=o.asset_id, amount=o.amount
+		FROM (
+			SELECT unnest($1::bytea[]) AS control_program,
+				unnest($2::text[]) AS tx_hash,
+				unnest($3::integer[]) AS index,
+				unnest($4::text[]) AS asset_id,
+				unnest($5::bigint[]) AS amount
+		) o
+		WHERE htlcs.control_program=o.control_program AND htlcs.status=$7
+	`
+	_, err = co.db.Exec(ctx, lockQ, outProgs, outTxHashes, outIndexes, outAssets, outAmounts, StatusLocked, StatusPending)
+	return errors.Wrap(err, "recording contract outputs")
+}
+
+// settleBlocks calls settle each time the
+// HTLC block processor finishes a block.
+func (co *Coordinator) settleBlocks(ctx context.Context, submit SubmitFunc) {
+	height := co.pinStore.Height(PinName)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-co.pinStore.PinWaiter(PinName, height+1):
+		}
+		height = co.pinStore.Height(PinName)
+		b, err := co.chain.GetBlock(ctx, height)
+		if err != nil {
+			log.Error(ctx, err, "getting block", height)
+			continue
+		}
+		err = co.settle(ctx, b.Time(), submit)
+		if err != nil {
+			log.Error(ctx, err)
+		}
+	}
+}
+
+// settle claims each locked contract Chain Core is the recipient
+// of whose preimage it knows, and refunds each locked contract it
+// is the refunder of that has expired by now. A transaction that
+// fails is retried after the next block; one that was submitted
+// is not submitted again until resubmitDelay has passed.
+func (co *Coordinator) settle(ctx context.Context, now time.Time, submit SubmitFunc) error {
+	const q = `
+		SELECT id, xpub, derivation_path, destination, control_program, preimage,
+			expiry_ms, tx_hash, index, asset_id, amount
+		FROM htlcs
+		WHERE status=$1 AND ((role=$2 AND preimage IS NOT NULL) OR (role=$3 AND expiry_ms<=$4))
+			AND (settle_submitted_at IS NULL OR settle_submitted_at < $5)
+	`
+	var spends []*spend
+	err := pg.ForQueryRows(ctx, co.db, q, StatusLocked, RoleRecipient, RoleRefund, bc.Millis(now), time.Now().Add(-resubmitDelay),
+		func(id, xpub string, path pq.ByteaArray, dest, prog, preimage []byte, expiryMS uint64, txHash bc.Hash, index uint32, assetID bc.AssetID, amount uint64) {
+			spends = append(spends, &spend{
+				id:       id,
+				xpub:     xpub,
+				path:     path,
+				dest:     dest,
+				prog:     prog,
+				preimage: preimage,
+				expiryMS: expiryMS,
+				outpoint: bc.Outpoint{Hash: txHash, Index: index},
+				assetAmt: bc.AssetAmount{AssetID: assetID, Amount: amount},
+			})
+		})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	for _, s := range spends {
+		err = co.submit(ctx, s, submit)
+		if err != nil {
+			log.Error(ctx, err, "settling contract output", s.outpoint)
+			continue
+		}
+		_, err = co.db.Exec(ctx, `UPDATE htlcs SET settle_submitted_at=now() WHERE id=$1`, s.id)
+		if err != nil {
+			log.Error(ctx, err, "recording settlement of contract", s.id)
+		}
+	}
+	return nil
+}
+
+// spend describes a transaction
+// claiming or refunding a contract.
+type spend struct {
+	id       string
+	xpub     string
+	path     [][]byte
+	dest     []byte
+	prog     []byte
+	preimage []byte // nil for refunds
+	expiryMS uint64
+	outpoint bc.Outpoint
+	assetAmt bc.AssetAmount
+}
+
+// template returns an unsigned template for s. It depends
+// only on s, so a retried claim or refund is the same
+// transaction as before.
+func (s *spend) template() *signing.Template {
+	tx := &bc.TxData{
+		Version: bc.CurrentTransactionVersion,
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(s.outpoint.Hash, s.outpoint.Index, nil, s.assetAmt.AssetID, s.assetAmt.Amount, s.prog, nil),
+		},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(s.assetAmt.AssetID, s.assetAmt.Amount, s.dest, nil),
+		},
+	}
+	if s.preimage == nil {
+		// The refund path checks the transaction's mintime.
+		tx.MinTime = s.expiryMS
+	}
+	keyPath := make([]chainjson.HexBytes, 0, len(s.path))
+	for _, p := range s.path {
+		keyPath = append(keyPath, p)
+	}
+	return &signing.Template{
+		Transaction: tx,
+		SigningInstructions: []*signing.SigningInstruction{{
+			Position:    0,
+			AssetAmount: s.assetAmt,
+			WitnessComponents: []signing.WitnessComponent{
+				&signing.HTLCWitness{Preimage: s.preimage},
+				&signing.SignatureWitness{
+					Quorum: 1,
+					Keys:   []signing.KeyID{{XPub: s.xpub, DerivationPath: keyPath}},
+				},
+			},
+		}},
+		Local: true,
+	}
+}
+
+func (co *Coordinator) submit(ctx context.Context, s *spend, submit SubmitFunc) error {
+	tpl := s.template()
+	err := signing.Sign(ctx, tpl, []string{s.xpub}, co.sign)
+	if err != nil {
+		return errors.Wrap(err, "signing")
+	}
+	return submit(ctx, tpl)
+}
+
+func (co *Coordinator) sign(ctx context.Context, xpubstr string, path [][]byte, data [32]byte) ([]byte, error) {
+	var xpub chainkd.XPub
+	err := xpub.UnmarshalText([]byte(xpubstr))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing xpub")
+	}
+	return co.hsm.XSign(ctx, xpub, path, data[:])
+}
+
+// hashPreimage returns the hash of preimage under the
+// hash function fn, which must be a key of hashOps.
+func hashPreimage(fn string, preimage []byte) []byte {
+	switch fn {
+	case "sha256":
+		h := sha256.Sum256(preimage)
+		return h[:]
+	case "sha3":
+		var h [32]byte
+		sha3pool.Sum256(h[:], preimage)
+		return h[:]
+	case "ripemd160":
+		h := ripemd160.New()
+		h.Write(preimage)
+		return h.Sum(nil)
+	}
+	panic("unknown hash function " + fn)
+}
@@ -0,0 +1,120 @@
+package htlc
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/testutil"
+)
+
+func TestTrackAndIndex(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	co := NewCoordinator(db, nil, nil, nil)
+
+	preimage := []byte("the atomic swap secret, 32 bytes")
+	hash := sha256.Sum256(preimage)
+	newContract := func(role string) *Contract {
+		return &Contract{
+			HashFunction:    "sha256",
+			Hash:            hash[:],
+			Expiry:          time.Now().Add(time.Hour),
+			Role:            role,
+			XPub:            testutil.TestXPub.String(),
+			CounterpartyKey: []byte(testutil.TestPub),
+			Destination:     []byte{0x51},
+		}
+	}
+
+	_, err := co.Track(ctx, newContract(RoleRecipient), []byte("wrong"))
+	if errors.Root(err) != ErrBadPreimage {
+		t.Errorf("Track(wrong preimage) = %v, want %v", err, ErrBadPreimage)
+	}
+	short := []byte("swap secret")
+	shortHash := sha256.Sum256(short)
+	bad := newContract(RoleRecipient)
+	bad.Hash = shortHash[:]
+	_, err = co.Track(ctx, bad, short)
+	if errors.Root(err) != ErrBadPreimage {
+		t.Errorf("Track(wrong-length preimage) = %v, want %v", err, ErrBadPreimage)
+	}
+	bad = newContract(RoleRecipient)
+	bad.HashFunction = "md5"
+	_, err = co.Track(ctx, bad, nil)
+	if errors.Root(err) != ErrBadContract {
+		t.Errorf("Track(md5) = %v, want %v", err, ErrBadContract)
+	}
+
+	ours, err := co.Track(ctx, newContract(RoleRecipient), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	refund := newContract(RoleRefund)
+	refund.DerivationPath = []chainjson.HexBytes{{1}} // a different key than the counterparty's
+	theirs, err := co.Track(ctx, refund, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Both contracts are funded in one block...
+	fund := bc.NewTx(bc.TxData{
+		Version: 1,
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(bc.AssetID{1}, 5, ours.ControlProgram, nil),
+			bc.NewTxOutput(bc.AssetID{1}, 7, theirs.ControlProgram, nil),
+		},
+	})
+	err = co.indexBlock(ctx, &bc.Block{Transactions: []*bc.Tx{fund}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := co.Find(ctx, ours.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Status != StatusLocked || got.TxID == nil || *got.TxID != fund.Hash || got.Amount == nil || *got.Amount != 5 {
+		t.Errorf("after funding, contract = %+v, want locked with amount 5", got)
+	}
+
+	// ...and the counterparty claims the one we fund,
+	// revealing the preimage for the one we receive.
+	claim := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(fund.Hash, 1, [][]byte{preimage, vm.BoolBytes(true), {}, {}}, bc.AssetID{1}, 7, theirs.ControlProgram, nil),
+		},
+	})
+	err = co.indexBlock(ctx, &bc.Block{Transactions: []*bc.Tx{claim}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = co.Find(ctx, theirs.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Status != StatusClaimed || got.SpendTxID == nil || *got.SpendTxID != claim.Hash {
+		t.Errorf("after claim, contract = %+v, want claimed by %s", got, claim.Hash)
+	}
+	got, err = co.Find(ctx, ours.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Status != StatusLocked || !got.PreimageKnown {
+		t.Errorf("after claim, contract = %+v, want locked with preimage known", got)
+	}
+
+	n, err := co.RevealPreimage(ctx, preimage)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 0 {
+		t.Errorf("RevealPreimage(known preimage) = %d, want 0", n)
+	}
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"chain/core/htlc"
+	"chain/core/tenant"
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// POST /create-htlc
+//
+// The preimage is optional; a recipient that doesn't know it
+// yet learns it from the blockchain or /reveal-htlc-preimage.
+// Chain Core signs for its side of the contract with the
+// MockHSM, so xpub must be a MockHSM key, and only the default
+// tenant, which owns those keys, may create contracts.
+func (h *Handler) createHTLC(ctx context.Context, in struct {
+	HashFunction    string               `json:"hash_function"`
+	Hash            chainjson.HexBytes   `json:"hash"`
+	Expiry          time.Time            `json:"expiry"`
+	Role            string               `json:"role"`
+	XPub            string               `json:"xpub"`
+	DerivationPath  []chainjson.HexBytes `json:"derivation_path"`
+	CounterpartyKey chainjson.HexBytes   `json:"counterparty_key"`
+	Destination     chainjson.HexBytes   `json:"destination"`
+	Preimage        chainjson.HexBytes   `json:"preimage"`
+}) (*htlc.Contract, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	var xpub chainkd.XPub
+	err := xpub.UnmarshalText([]byte(in.XPub))
+	if err != nil {
+		return nil, errors.WithDetail(htlc.ErrBadContract, "bad xpub")
+	}
+	ok, err := h.HSM.HasChainKDKey(ctx, xpub)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.WithDetailf(htlc.ErrBadContract, "xpub %s is not in the MockHSM", in.XPub)
+	}
+
+	c := &htlc.Contract{
+		HashFunction:    in.HashFunction,
+		Hash:            in.Hash,
+		Expiry:          in.Expiry,
+		Role:            in.Role,
+		XPub:            in.XPub,
+		DerivationPath:  in.DerivationPath,
+		CounterpartyKey: in.CounterpartyKey,
+		Destination:     in.Destination,
+	}
+	var preimage []byte
+	if len(in.Preimage) > 0 {
+		preimage = in.Preimage
+	}
+	return h.HTLCs.Track(ctx, c, preimage)
+}
+
+// ProcessHTLCs claims and refunds tracked contracts as the
+// blockchain advances, until ctx is done. The claims and refunds
+// are submitted the way /submit-transaction submits transactions.
+// It must run only in the leader process.
+func (h *Handler) ProcessHTLCs(ctx context.Context) {
+	h.HTLCs.ProcessBlocks(ctx, h.submitHTLCSpend)
+}
+
+func (h *Handler) submitHTLCSpend(ctx context.Context, tpl *signing.Template) error {
+	err := h.checkHalted(ctx)
+	if err != nil {
+		return err
+	}
+	return h.finalizeTxWait(ctx, tpl, "none")
+}
+
+// POST /list-htlcs
+func (h *Handler) listHTLCs(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	contracts, after, err := h.HTLCs.List(ctx, query.Status, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(contracts),
+		LastPage: len(contracts) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /reveal-htlc-preimage
+func (h *Handler) revealHTLCPreimage(ctx context.Context, in struct {
+	Preimage chainjson.HexBytes `json:"preimage"`
+}) (map[string]int, error) {
+	n, err := h.HTLCs.RevealPreimage(ctx, in.Preimage)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"updated": n}, nil
+}
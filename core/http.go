@@ -23,6 +23,16 @@ func jsonHandler(f interface{}) http.Handler {
 	return h
 }
 
+// strictJSONHandler is like jsonHandler,
+// but see httpjson.StrictHandler.
+func strictJSONHandler(f interface{}) http.Handler {
+	h, err := httpjson.StrictHandler(f, WriteHTTPError)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
 // WriteHTTPError writes a json encoded detailedError
 // to the ResponseWriter. It uses the status code
 // associated with the error.
@@ -0,0 +1,267 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"chain/core/tenant"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/net/http/reqid"
+)
+
+const (
+	// idempotencyKeyHeader names the request header with which
+	// a client marks retries of the same request.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayHeader marks a response replayed from
+	// the first request with its idempotency key.
+	idempotentReplayHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+)
+
+var (
+	errIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+	errIdempotentInProgress = errors.New("request with idempotency key in progress")
+)
+
+// idempotentPaths lists the mutating endpoints that
+// accept an Idempotency-Key header. Endpoints whose responses
+// hold secrets, such as /create-access-token, are left out,
+// since saved responses are stored in the database in the clear.
+var idempotentPaths = map[string]bool{
+	"/create-account":              true,
+	"/create-asset":                true,
+	"/create-control-program":      true,
+	"/submit-transaction":          true,
+	"/create-transaction-feed":     true,
+	"/create-balance-subscription": true,
+	"/create-htlc":                 true,
+	"/create-scheduled-payment":    true,
+	"/cancel-scheduled-payment":    true,
+	"/import-control-programs":     true,
+	"/set-account-limit":           true,
+	"/approve-transaction":         true,
+	"/reject-transaction":          true,
+	"/freeze-asset":                true,
+	"/unfreeze-asset":              true,
+	"/mockhsm/create-key":          true,
+}
+
+// idempotent serves requests to path with next, except that
+// a request with an Idempotency-Key header is served at most
+// once for each tenant and key. A retry, with the same body,
+// gets the response to the first request, saved in the
+// database until CleanupIdempotentRequests removes it.
+//
+// A response the client could retry anyway, such as a server
+// error or a temporary error, isn't saved, so a retry of its
+// request is served again.
+func (h *Handler) idempotent(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ctx := req.Context()
+		if len(key) > maxIdempotencyKeyLen {
+			WriteHTTPError(ctx, w, errors.WithDetailf(errBadReqHeader, "%s is longer than %d bytes", idempotencyKeyHeader, maxIdempotencyKeyLen))
+			return
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			WriteHTTPError(ctx, w, errors.WithDetail(httpjson.ErrBadRequest, err.Error()))
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(body)
+		r := &idempotentRequest{
+			tenant: tenant.FromContext(ctx),
+			path:   path,
+			key:    key,
+			hash:   hash[:],
+		}
+		saved, err := r.begin(ctx, h.DB)
+		if err != nil {
+			WriteHTTPError(ctx, w, err)
+			return
+		}
+		if saved != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set(idempotentReplayHeader, "true")
+			w.WriteHeader(saved.status)
+			w.Write(saved.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			// The client may be gone, as when a gateway times
+			// out and retries, but its retry needs this response.
+			ctx := reqid.NewContext(context.Background(), reqid.FromContext(ctx))
+			err := r.finish(ctx, h.DB, rec)
+			if err != nil {
+				log.Error(ctx, err, "saving response to idempotent request")
+			}
+		}()
+		next.ServeHTTP(rec, req)
+	})
+}
+
+type idempotentRequest struct {
+	tenant, path, key string
+	hash              []byte
+}
+
+type savedResponse struct {
+	status int
+	body   []byte
+}
+
+// begin records r as in progress and returns nil, unless a
+// request with its key was already made. Then it returns the
+// saved response to that request, or errIdempotentInProgress
+// if there is none yet, or errIdempotencyKeyReused if that
+// request had a different body.
+func (r *idempotentRequest) begin(ctx context.Context, db pg.DB) (*savedResponse, error) {
+	const insertQ = `
+		INSERT INTO idempotent_requests (tenant, path, key, request_hash)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant, path, key) DO NOTHING
+	`
+	res, err := db.Exec(ctx, insertQ, r.tenant, r.path, r.key, r.hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "recording idempotent request")
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "recording idempotent request")
+	}
+	if inserted == 1 {
+		return nil, nil
+	}
+
+	const selectQ = `
+		SELECT request_hash, status, response FROM idempotent_requests
+		WHERE tenant=$1 AND path=$2 AND key=$3
+	`
+	var (
+		hash   []byte
+		status sql.NullInt64
+		body   []byte
+	)
+	err = db.QueryRow(ctx, selectQ, r.tenant, r.path, r.key).Scan(&hash, &status, &body)
+	if err == sql.ErrNoRows {
+		// The first request failed and was forgotten
+		// since the insert above.
+		return nil, errors.Wrap(errIdempotentInProgress)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "loading idempotent request")
+	}
+	if !bytes.Equal(hash, r.hash) {
+		return nil, errors.WithDetailf(errIdempotencyKeyReused, "key %q", r.key)
+	}
+	if !status.Valid {
+		return nil, errors.WithDetailf(errIdempotentInProgress, "key %q", r.key)
+	}
+	return &savedResponse{status: int(status.Int64), body: body}, nil
+}
+
+// finish saves the response in rec to r, or, if the client
+// could retry it anyway, forgets r.
+func (r *idempotentRequest) finish(ctx context.Context, db pg.DB, rec *responseRecorder) error {
+	if !rec.final() {
+		const q = `DELETE FROM idempotent_requests WHERE tenant=$1 AND path=$2 AND key=$3`
+		_, err := db.Exec(ctx, q, r.tenant, r.path, r.key)
+		return errors.Wrap(err)
+	}
+	const q = `
+		UPDATE idempotent_requests SET status=$4, response=$5
+		WHERE tenant=$1 AND path=$2 AND key=$3
+	`
+	_, err := db.Exec(ctx, q, r.tenant, r.path, r.key, rec.status, rec.body.Bytes())
+	return errors.Wrap(err)
+}
+
+// responseRecorder passes a response through to its
+// ResponseWriter, keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// final reports whether the recorded response is final:
+// written, neither a server error nor a timeout, and not
+// an error the response says is temporary. Batch endpoints
+// respond 200 with an array holding each item's result or
+// error, so neither may any item be a temporary error.
+func (rec *responseRecorder) final() bool {
+	switch {
+	case rec.status == 0:
+		return false // the handler panicked
+	case rec.status >= 500 || rec.status == http.StatusRequestTimeout:
+		return false
+	case rec.status >= 400:
+		var body struct {
+			Temporary bool `json:"temporary"`
+		}
+		json.Unmarshal(rec.body.Bytes(), &body) // best effort
+		return !body.Temporary
+	}
+	var items []struct {
+		Temporary bool `json:"temporary"`
+	}
+	json.Unmarshal(rec.body.Bytes(), &items) // fails for non-arrays
+	for _, item := range items {
+		if item.Temporary {
+			return false
+		}
+	}
+	return true
+}
+
+// CleanupIdempotentRequests periodically deletes saved responses
+// to idempotent requests older than retention. This function
+// blocks and only exits when its context is cancelled.
+func CleanupIdempotentRequests(ctx context.Context, db pg.DB, retention time.Duration) {
+	ticker := time.NewTicker(15 * time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			const q = `DELETE FROM idempotent_requests WHERE created_at < $1`
+			_, err := db.Exec(ctx, q, time.Now().Add(-retention))
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/core/account"
+	"chain/core/approval"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestResponseRecorderFinal(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{0, "", false},
+		{200, `{"id":"acc1"}`, true},
+		{400, `{"code":"CH050","temporary":false}`, true},
+		{400, `{"code":"CH761","temporary":true}`, false},
+		{408, `{"code":"CH001","temporary":true}`, false},
+		{500, `{"code":"CH000","temporary":true}`, false},
+		{200, `[{"id":"tx1"},{"code":"CH050","temporary":false}]`, true},
+		{200, `[{"id":"tx1"},{"code":"CH739","temporary":true}]`, false},
+	}
+	for _, c := range cases {
+		rec := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+		if c.status != 0 {
+			rec.WriteHeader(c.status)
+			rec.Write([]byte(c.body))
+		}
+		if got := rec.final(); got != c.want {
+			t.Errorf("final() for %d %s = %v want %v", c.status, c.body, got, c.want)
+		}
+	}
+}
+
+func TestIdempotentApproval(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	h := &Handler{DB: db, Approvals: &approval.Controller{DB: db}}
+
+	assetID := bc.AssetID{1}
+	threshold := uint64(100)
+	err := h.Approvals.SetThreshold(ctx, "", assetID, &threshold)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A stand-in for /submit-transaction: a batch
+	// of one transaction needing approval.
+	txID := bc.Hash{1}
+	spends := []account.Spend{{AccountID: "acc1", AssetID: assetID, Amount: 101}}
+	submit := strictJSONHandler(func(ctx context.Context) []interface{} {
+		responses := make([]interface{}, 1)
+		func() {
+			defer batchRecover(ctx, &responses[0])
+			err := h.Approvals.Require(ctx, txID, spends, "alice")
+			if err != nil {
+				responses[0] = err
+			} else {
+				responses[0] = map[string]string{"id": txID.String()}
+			}
+		}()
+		return responses
+	})
+	handler := h.idempotent("/submit-transaction", submit)
+
+	post := func() []map[string]interface{} {
+		req := httptest.NewRequest("POST", "/submit-transaction", bytes.NewReader([]byte("{}")))
+		req.Header.Set(idempotencyKeyHeader, "key1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+		}
+		var resp []map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &resp)
+		if err != nil || len(resp) != 1 {
+			t.Fatalf("response %s, want one item", rec.Body)
+		}
+		return resp
+	}
+
+	resp := post()
+	if resp[0]["code"] != "CH739" {
+		t.Fatalf("first response = %v, want CH739", resp[0])
+	}
+
+	_, err = h.Approvals.Decide(ctx, txID, "bob", true)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	resp = post()
+	if resp[0]["id"] != txID.String() {
+		t.Errorf("retry after approval = %v, want id %s", resp[0], txID)
+	}
+}
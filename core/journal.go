@@ -0,0 +1,84 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chain/core/query"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+// journalHeader is the header row of /export-journal.
+var journalHeader = []string{
+	"journal_id", "date", "timestamp", "line", "ledger_account", "account_id",
+	"asset_id", "asset_alias", "debit", "credit", "memo", "line_memo",
+}
+
+// POST /export-journal
+//
+// Responds with a CSV file of double-entry journal lines for the
+// confirmed transactions in blocks from start_time through
+// end_time, or only those spending from or paying to account_id.
+// Each transaction is a journal entry whose debits and credits
+// balance for each asset; see query.JournalLine. Amounts are in
+// the asset's base units. A zero end_time means now.
+func (h *Handler) exportJournal(rw http.ResponseWriter, req *http.Request) {
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	var in struct {
+		AccountID   string `json:"account_id"`
+		StartTimeMS uint64 `json:"start_time"`
+		EndTimeMS   uint64 `json:"end_time"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&in)
+	if err != nil {
+		WriteHTTPError(ctx, rw, errors.WithDetail(httpjson.ErrBadRequest, err.Error()))
+		return
+	}
+	if in.EndTimeMS == 0 {
+		in.EndTimeMS = math.MaxInt64
+	}
+	if in.StartTimeMS > math.MaxInt64 || in.EndTimeMS > math.MaxInt64 {
+		WriteHTTPError(ctx, rw, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large"))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/csv")
+	w := csv.NewWriter(rw)
+	w.Write(journalHeader)
+	err = h.Indexer.Journal(ctx, in.AccountID, in.StartTimeMS, in.EndTimeMS, func(l *query.JournalLine) error {
+		return w.Write([]string{
+			l.JournalID,
+			l.Timestamp.UTC().Format("2006-01-02"),
+			l.Timestamp.UTC().Format(time.RFC3339),
+			strconv.Itoa(l.Line),
+			l.LedgerAccount,
+			l.AccountID,
+			l.AssetID,
+			l.AssetAlias,
+			strconv.FormatUint(l.Debit, 10),
+			strconv.FormatUint(l.Credit, 10),
+			l.Memo,
+			l.LineMemo,
+		})
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		// The response has begun; the client will find the file
+		// truncated.
+		log.Error(ctx, err)
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"chain/core/signers"
+	"chain/core/tenant"
+	"chain/crypto/ed25519/chainkd"
+	"chain/net/http/httpjson"
+)
+
+// POST /set-key-metadata
+//
+// Labels a root xpub with a description of the key and who its
+// custodian is and how to reach them. The metadata appears on the
+// keys of every account and asset using the xpub. Setting every
+// field empty removes it.
+func (h *Handler) setKeyMetadata(ctx context.Context, in struct {
+	RootXPub    chainkd.XPub `json:"root_xpub"`
+	Label       string       `json:"label"`
+	Description string       `json:"description"`
+	Custodian   string       `json:"custodian"`
+	Contact     string       `json:"contact"`
+}) (*signers.KeyMetadata, error) {
+	// Keys may be shared by the accounts and assets
+	// of several tenants.
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	md := &signers.KeyMetadata{
+		XPub:        in.RootXPub,
+		Label:       in.Label,
+		Description: in.Description,
+		Custodian:   in.Custodian,
+		Contact:     in.Contact,
+	}
+	err := signers.SetKeyMetadata(ctx, h.DB, md)
+	return md, err
+}
+
+// POST /list-key-metadata
+func (h *Handler) listKeyMetadata(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	mds, after, err := signers.ListKeyMetadata(ctx, h.DB, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(mds),
+		LastPage: len(mds) < limit,
+		Next:     query,
+	}, nil
+}
+
+// addAccountKeyMetadata adds the metadata of
+// their keys to the accounts in accounts.
+func (h *Handler) addAccountKeyMetadata(ctx context.Context, accounts []*accountResponse) error {
+	var xpubs []string
+	for _, acc := range accounts {
+		for _, k := range acc.Keys.([]accountKey) {
+			xpubs = append(xpubs, fmt.Sprint(k.RootXPub))
+		}
+	}
+	mds, err := signers.GetKeyMetadata(ctx, h.DB, xpubs)
+	if err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		keys := acc.Keys.([]accountKey)
+		for i := range keys {
+			keys[i].Metadata = mds[fmt.Sprint(keys[i].RootXPub)]
+		}
+	}
+	return nil
+}
+
+// addAssetKeyMetadata adds the metadata of
+// their keys to the assets in assets.
+func (h *Handler) addAssetKeyMetadata(ctx context.Context, assets []*assetResponse) error {
+	var xpubs []string
+	for _, a := range assets {
+		for _, k := range a.Keys.([]assetKey) {
+			xpubs = append(xpubs, fmt.Sprint(k.RootXPub))
+		}
+	}
+	mds, err := signers.GetKeyMetadata(ctx, h.DB, xpubs)
+	if err != nil {
+		return err
+	}
+	for _, a := range assets {
+		keys := a.Keys.([]assetKey)
+		for i := range keys {
+			keys[i].Metadata = mds[fmt.Sprint(keys[i].RootXPub)]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/tenant"
+	"chain/log"
+)
+
+// logLevel describes the verbosity of this core's logging.
+type logLevel struct {
+	Level           string   `json:"level"`
+	DebugSubsystems []string `json:"debug_subsystems"`
+	Subsystems      []string `json:"subsystems"`
+}
+
+func currentLogLevel() *logLevel {
+	return &logLevel{
+		Level:           log.GetLevel().String(),
+		DebugSubsystems: log.DebugSubsystems(),
+		Subsystems:      log.Subsystems,
+	}
+}
+
+// POST /get-log-level
+func (h *Handler) getLogLevel(ctx context.Context) (*logLevel, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	return currentLogLevel(), nil
+}
+
+// POST /set-log-level
+//
+// Changes the verbosity of logging, error, info, or debug,
+// and turns debug logging of subsystems on or off, at once
+// and until the core restarts. Omitted settings are left as
+// they are.
+func (h *Handler) setLogLevel(ctx context.Context, in struct {
+	Level           string          `json:"level"`
+	DebugSubsystems map[string]bool `json:"debug_subsystems"`
+}) (*logLevel, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	level := log.GetLevel()
+	if in.Level != "" {
+		var err error
+		level, err = log.ParseLevel(in.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := log.SetDebug(in.DebugSubsystems)
+	if err != nil {
+		return nil, err
+	}
+	log.SetLevel(level)
+	log.Write(ctx, log.KeyMessage, "changed log level", "level", log.GetLevel(), "debug_subsystems", log.DebugSubsystems())
+	return currentLogLevel(), nil
+}
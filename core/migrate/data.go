@@ -72,4 +72,379 @@ var migrations = []migration{
 			ALTER COLUMN tx_id SET DATA TYPE bytea USING decode(tx_id,'hex');
 		ALTER TABLE submitted_txs RENAME COLUMN tx_id TO tx_hash;
 	`},
+	{Name: "2016-12-01.0.core.access-token-priority-quota.sql", SQL: `
+		ALTER TABLE access_tokens ADD COLUMN priority_quota integer DEFAULT 0 NOT NULL;
+	`},
+	{Name: "2016-12-02.0.query.reference-data-search.sql", SQL: `
+		CREATE FUNCTION reference_data_tsvector(jsonb) RETURNS tsvector
+			LANGUAGE sql IMMUTABLE
+			AS $$
+			SELECT to_tsvector('simple', COALESCE(string_agg(r::text, ' '), ''))
+			FROM (
+				SELECT $1->'reference_data' AS r
+				UNION ALL SELECT jsonb_array_elements($1->'inputs')->'reference_data'
+				UNION ALL SELECT jsonb_array_elements($1->'outputs')->'reference_data'
+			) refdata
+		$$;
+		CREATE INDEX annotated_txs_reference_data_idx ON annotated_txs USING gin (reference_data_tsvector(data));
+	`},
+	{Name: "2016-12-05.0.core.tenants.sql", SQL: `
+		ALTER TABLE access_tokens ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		ALTER TABLE accounts ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		ALTER TABLE assets ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		ALTER TABLE annotated_accounts ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		ALTER TABLE annotated_assets ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		CREATE INDEX annotated_accounts_tenant_idx ON annotated_accounts USING btree (tenant);
+	`},
+	{Name: "2016-12-06.0.core.account-limits.sql", SQL: `
+		CREATE TABLE account_limits (
+			account_id text NOT NULL,
+			asset_id text NOT NULL,
+			max_per_transaction bigint,
+			max_per_day bigint,
+			PRIMARY KEY (account_id, asset_id)
+		);
+		CREATE TABLE account_spends (
+			tx_hash text NOT NULL,
+			account_id text NOT NULL,
+			asset_id text NOT NULL,
+			amount bigint NOT NULL,
+			spent_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (tx_hash, account_id, asset_id)
+		);
+		CREATE INDEX account_spends_account_id_asset_id_spent_at_idx ON account_spends USING btree (account_id, asset_id, spent_at);
+	`},
+	{Name: "2016-12-07.0.core.approvals.sql", SQL: `
+		CREATE TABLE approval_thresholds (
+			asset_id text PRIMARY KEY,
+			amount bigint NOT NULL
+		);
+		CREATE TABLE approvals (
+			tx_hash text PRIMARY KEY,
+			status text NOT NULL,
+			spends jsonb NOT NULL,
+			requested_by text NOT NULL,
+			decided_by text,
+			tenant text DEFAULT '' NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			decided_at timestamp with time zone
+		);
+	`},
+	{Name: "2016-12-08.0.account.watch-only.sql", SQL: `
+		ALTER TABLE accounts ADD COLUMN watch_only boolean DEFAULT false NOT NULL;
+	`},
+	{Name: "2016-12-09.0.core.htlcs.sql", SQL: `
+		CREATE TABLE htlcs (
+			id text DEFAULT next_chain_id('htlc') PRIMARY KEY,
+			hash_function text NOT NULL,
+			hash bytea NOT NULL,
+			expiry_ms bigint NOT NULL,
+			role text NOT NULL,
+			xpub text NOT NULL,
+			derivation_path bytea[] NOT NULL,
+			counterparty_key bytea NOT NULL,
+			destination bytea NOT NULL,
+			control_program bytea NOT NULL UNIQUE,
+			preimage bytea,
+			status text NOT NULL,
+			tx_hash text,
+			index integer,
+			asset_id text,
+			amount bigint,
+			spend_tx_hash text,
+			tenant text DEFAULT '' NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX htlcs_hash_idx ON htlcs (hash) WHERE preimage IS NULL;
+	`},
+	{Name: "2016-12-10.0.core.access-token-keys.sql", SQL: `
+		ALTER TABLE access_tokens ADD COLUMN public_key bytea UNIQUE;
+	`},
+	{Name: "2016-12-11.0.core.signed-blocks-journal.sql", SQL: `
+		ALTER TABLE signed_blocks ADD COLUMN signed_at timestamp with time zone DEFAULT now() NOT NULL;
+	`},
+	{Name: "2016-12-12.0.core.balance-snapshots.sql", SQL: `
+		CREATE TABLE balance_snapshots (
+			date date NOT NULL,
+			account_id text NOT NULL,
+			asset_id text NOT NULL,
+			amount bigint NOT NULL,
+			PRIMARY KEY (date, account_id, asset_id)
+		);
+		CREATE INDEX ON balance_snapshots (account_id, date);
+	`},
+	{Name: "2016-12-13.0.core.asset-cosigners.sql", SQL: `
+		ALTER TABLE assets ADD COLUMN cosigner_url text;
+		ALTER TABLE assets ADD COLUMN cosigner_pubkey bytea;
+	`},
+	{Name: "2016-12-14.0.core.mirror-outbox.sql", SQL: `
+		CREATE TABLE mirror_outbox (
+		    height bigint NOT NULL PRIMARY KEY,
+		    block_hash text NOT NULL,
+		    data bytea NOT NULL,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-15.0.core.contract-sources.sql", SQL: `
+		CREATE TABLE contract_sources (
+		    program_hash text NOT NULL PRIMARY KEY,
+		    program bytea NOT NULL,
+		    vm_version bigint NOT NULL,
+		    source text NOT NULL,
+		    compiler text NOT NULL,
+		    compiler_version text NOT NULL,
+		    constructor_args jsonb NOT NULL,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-16.0.core.explorer-indexes.sql", SQL: `
+		CREATE INDEX blocks_height_idx ON blocks (height);
+		CREATE INDEX annotated_txs_tx_hash_idx ON annotated_txs (tx_hash);
+		CREATE INDEX annotated_outputs_control_program_idx ON annotated_outputs
+		    ((data->>'control_program'), block_height, tx_pos, output_index);
+	`},
+	{Name: "2016-12-17.0.core.scheduled-payments.sql", SQL: `
+		CREATE TABLE scheduled_payments (
+		    id text DEFAULT next_chain_id('sched') PRIMARY KEY,
+		    payments jsonb NOT NULL,
+		    xpubs text[] NOT NULL,
+		    due_ms bigint NOT NULL,
+		    next_attempt_ms bigint NOT NULL,
+		    interval_ms bigint NOT NULL,
+		    max_attempts integer NOT NULL,
+		    retry_delay_ms bigint NOT NULL,
+		    attempts integer DEFAULT 0 NOT NULL,
+		    pending_tx jsonb,
+		    status text NOT NULL,
+		    tenant text DEFAULT '' NOT NULL,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX scheduled_payments_next_attempt_ms_idx ON scheduled_payments (next_attempt_ms)
+		    WHERE status='active';
+		CREATE TABLE scheduled_payment_runs (
+		    schedule_id text NOT NULL,
+		    due_ms bigint NOT NULL,
+		    attempt integer NOT NULL,
+		    tx_hash text,
+		    error text,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL,
+		    PRIMARY KEY (schedule_id, due_ms, attempt)
+		);
+	`},
+	{Name: "2016-12-18.0.core.submission-failures.sql", SQL: `
+		CREATE TABLE submission_failures (
+		    id text DEFAULT next_chain_id('subf') PRIMARY KEY,
+		    tx_hash text NOT NULL,
+		    template jsonb NOT NULL,
+		    code text NOT NULL,
+		    message text NOT NULL,
+		    detail text NOT NULL,
+		    data jsonb NOT NULL,
+		    submitter text NOT NULL,
+		    attempts integer DEFAULT 1 NOT NULL,
+		    status text NOT NULL,
+		    tenant text DEFAULT '' NOT NULL,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL,
+		    updated_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE UNIQUE INDEX submission_failures_tx_hash_idx ON submission_failures (tx_hash)
+		    WHERE status='failed';
+	`},
+	{Name: "2016-12-19.0.core.balance-subscriptions.sql", SQL: `
+		CREATE TABLE balance_subscriptions (
+		    id text DEFAULT next_chain_id('bsub') PRIMARY KEY,
+		    account_id text NOT NULL,
+		    url text NOT NULL,
+		    secret bytea NOT NULL,
+		    status text NOT NULL,
+		    failures integer DEFAULT 0 NOT NULL,
+		    last_error text,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX balance_subscriptions_account_id_idx ON balance_subscriptions (account_id)
+		    WHERE status='active';
+	`},
+	{Name: "2016-12-20.0.core.notifications.sql", SQL: `
+		CREATE TABLE notifications (
+		    channel text PRIMARY KEY,
+		    payload text NOT NULL
+		);
+	`},
+	{Name: "2016-12-21.0.core.reference-data-keys.sql", SQL: `
+		CREATE TABLE reference_data_keys (
+		    key_id text PRIMARY KEY,
+		    secret bytea NOT NULL,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-22.0.account.control-program-uses.sql", SQL: `
+		ALTER TABLE account_control_programs ADD COLUMN single_use boolean DEFAULT false NOT NULL;
+		ALTER TABLE account_control_programs ADD COLUMN use_count integer DEFAULT 0 NOT NULL;
+		ALTER TABLE account_control_programs ADD COLUMN last_used_height bigint;
+	`},
+	{Name: "2016-12-23.0.core.freezes.sql", SQL: `
+		CREATE TABLE freezes (
+			asset_id text NOT NULL,
+			account_id text DEFAULT '' NOT NULL,
+			reason text NOT NULL,
+			frozen_by text NOT NULL,
+			frozen_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (asset_id, account_id)
+		);
+		CREATE TABLE freeze_events (
+			id text DEFAULT next_chain_id('frz') PRIMARY KEY,
+			action text NOT NULL,
+			asset_id text NOT NULL,
+			account_id text DEFAULT '' NOT NULL,
+			reason text NOT NULL,
+			operator text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-23.1.generator.halt.sql", SQL: `
+		CREATE TABLE generator_halt (
+			singleton boolean DEFAULT true PRIMARY KEY,
+			halt_pool boolean NOT NULL,
+			reason text NOT NULL,
+			operator text NOT NULL,
+			halted_at timestamp with time zone DEFAULT now() NOT NULL,
+			CONSTRAINT generator_halt_singleton CHECK (singleton)
+		);
+	`},
+	{Name: "2016-12-23.2.core.access-token-peers.sql", SQL: `
+		ALTER TABLE access_tokens ADD COLUMN rate_limit integer DEFAULT 0 NOT NULL;
+		ALTER TABLE access_tokens ADD COLUMN last_seen_at timestamp with time zone;
+		ALTER TABLE access_tokens ADD COLUMN last_seen_addr text;
+	`},
+	{Name: "2016-12-23.3.core.forwarded-txs.sql", SQL: `
+		CREATE TABLE forwarded_txs (
+			tx_hash text PRIMARY KEY,
+			data bytea NOT NULL,
+			priority text NOT NULL,
+			status text NOT NULL,
+			attempts integer DEFAULT 0 NOT NULL,
+			error text,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			updated_at timestamp with time zone DEFAULT now() NOT NULL,
+			next_attempt_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX forwarded_txs_next_attempt_at_idx ON forwarded_txs (next_attempt_at)
+			WHERE status='forwarding';
+	`},
+	{Name: "2016-12-23.4.core.signer-key-metadata.sql", SQL: `
+		CREATE TABLE signer_key_metadata (
+			xpub text PRIMARY KEY,
+			label text DEFAULT ''::text NOT NULL,
+			description text DEFAULT ''::text NOT NULL,
+			custodian text DEFAULT ''::text NOT NULL,
+			contact text DEFAULT ''::text NOT NULL,
+			updated_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-23.5.core.signing-requests.sql", SQL: `
+		CREATE TABLE signing_requests (
+			tx_hash text PRIMARY KEY,
+			template jsonb NOT NULL,
+			status text NOT NULL,
+			tenant text DEFAULT ''::text NOT NULL,
+			created_by text DEFAULT ''::text NOT NULL,
+			expires_at timestamp with time zone,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			updated_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX signing_requests_created_at_idx ON signing_requests (created_at);
+		CREATE TABLE signing_declines (
+			tx_hash text NOT NULL,
+			xpub text NOT NULL,
+			declined_by text DEFAULT ''::text NOT NULL,
+			reason text DEFAULT ''::text NOT NULL,
+			declined_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (tx_hash, xpub)
+		);
+		CREATE TABLE signing_events (
+			id text DEFAULT next_chain_id('sev'::text) PRIMARY KEY,
+			tx_hash text NOT NULL,
+			type text NOT NULL,
+			xpub text DEFAULT ''::text NOT NULL,
+			"position" integer,
+			operator text DEFAULT ''::text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-23.6.query.output-spends.sql", SQL: `
+		ALTER TABLE annotated_outputs
+			ADD COLUMN spent_by_tx_hash text,
+			ADD COLUMN spent_by_input integer,
+			ADD COLUMN spent_at_height bigint;
+	`},
+	{Name: "2016-12-23.7.query.output-spender-idx.sql", SQL: `
+		CREATE INDEX annotated_outputs_spent_by_idx ON annotated_outputs USING btree (spent_by_tx_hash);
+	`},
+	{Name: "2016-12-23.8.core.risk-scores.sql", SQL: `
+		CREATE TABLE risk_scores (
+			tx_hash text NOT NULL,
+			stage text NOT NULL,
+			score double precision NOT NULL,
+			reasons jsonb NOT NULL,
+			scored_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (tx_hash, stage)
+		);
+	`},
+	{Name: "2016-12-23.9.core.idempotent-requests.sql", SQL: `
+		CREATE TABLE idempotent_requests (
+			tenant text NOT NULL,
+			path text NOT NULL,
+			key text NOT NULL,
+			request_hash bytea NOT NULL,
+			status integer,
+			response bytea,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (tenant, path, key)
+		);
+		CREATE INDEX idempotent_requests_created_at_idx ON idempotent_requests USING btree (created_at);
+	`},
+	{Name: "2016-12-24.0.core.account-parents.sql", SQL: `
+		ALTER TABLE accounts ADD COLUMN parent_id text;
+		CREATE INDEX accounts_parent_id_idx ON accounts USING btree (parent_id);
+		CREATE INDEX annotated_accounts_parent_id_idx ON annotated_accounts USING btree (((data ->> 'parent_id'::text)));
+	`},
+	{Name: "2016-12-24.1.core.build-commitments.sql", SQL: `
+		CREATE TABLE build_commitments (
+			hash bytea PRIMARY KEY,
+			expires_at timestamp with time zone NOT NULL
+		);
+		CREATE INDEX build_commitments_expires_at_idx ON build_commitments USING btree (expires_at);
+	`},
+	{Name: "2016-12-24.2.core.txfeed-tenants.sql", SQL: `
+		ALTER TABLE txfeeds ADD COLUMN tenant text DEFAULT '' NOT NULL;
+	`},
+	{Name: "2016-12-24.3.core.approval-threshold-tenants.sql", SQL: `
+		ALTER TABLE approval_thresholds ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		ALTER TABLE approval_thresholds DROP CONSTRAINT approval_thresholds_pkey;
+		ALTER TABLE approval_thresholds ADD PRIMARY KEY (tenant, asset_id);
+	`},
+	{Name: "2016-12-24.4.core.htlc-settle-submitted.sql", SQL: `
+		ALTER TABLE htlcs ADD COLUMN settle_submitted_at timestamp with time zone;
+	`},
+	{Name: "2016-12-24.5.core.reference-data-key-tenants.sql", SQL: `
+		ALTER TABLE reference_data_keys ADD COLUMN tenant text DEFAULT '' NOT NULL;
+		ALTER TABLE reference_data_keys DROP CONSTRAINT reference_data_keys_pkey;
+		ALTER TABLE reference_data_keys ADD PRIMARY KEY (tenant, key_id);
+	`},
+	{Name: "2016-12-24.6.core.account-rescans.sql", SQL: `
+		CREATE TABLE account_rescans (
+		    id text DEFAULT next_chain_id('rscn') PRIMARY KEY,
+		    account_id text NOT NULL,
+		    control_programs bytea[] NOT NULL,
+		    from_height bigint NOT NULL,
+		    scanned_height bigint NOT NULL,
+		    status text NOT NULL,
+		    error text,
+		    tenant text DEFAULT '' NOT NULL,
+		    created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2016-12-24.7.core.balance-subscription-progress.sql", SQL: `
+		ALTER TABLE balance_subscriptions ADD COLUMN delivered_height bigint DEFAULT 0 NOT NULL;
+	`},
 }
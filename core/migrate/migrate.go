@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"chain/database/pg"
@@ -56,6 +57,39 @@ func Run(db pg.DB) error {
 	return nil
 }
 
+// ErrSchemaMismatch is returned by Check for a database
+// whose schema doesn't match this build's migrations.
+var ErrSchemaMismatch = errors.New("database schema does not match this version of Chain Core")
+
+// Check returns ErrSchemaMismatch if any built-in migration
+// is not applied to db, or if db has had migrations applied
+// that this build doesn't know, as when a newer version of
+// Chain Core has run against it.
+func Check(db pg.DB) error {
+	err := loadStatus(db, migrations)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.AppliedAt.IsZero() {
+			return errors.WithDetailf(ErrSchemaMismatch, "migration %s is not applied", m.Name)
+		}
+	}
+	var unknown []string
+	err = pg.ForQueryRows(context.Background(), db, `SELECT filename FROM migrations ORDER BY filename`, func(name string) {
+		if find(name, migrations) == nil {
+			unknown = append(unknown, name)
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "listing applied migrations")
+	}
+	if len(unknown) > 0 {
+		return errors.WithDetailf(ErrSchemaMismatch, "unknown migrations applied: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
 // PrintStatus prints the status of each built-in migration.
 func PrintStatus(db pg.DB) error {
 	err := loadStatus(db, migrations)
@@ -0,0 +1,137 @@
+// Package mirror delivers each confirmed block, in order of height,
+// to hooks that mirror the blockchain into an external system, such
+// as a message queue or an ERP.
+//
+// Integrators register hooks with Register, usually from the init
+// function of a package linked into cored. Each hook has its own
+// block processor pin, so a slow or failing hook holds up only
+// itself, and picks up where it left off when cored restarts.
+package mirror
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"chain/core/pin"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/bc"
+)
+
+// A Hook receives confirmed blocks. It's called for one block at a
+// time, in order of height, and is called again with the same block
+// until it returns nil.
+//
+// A block is delivered at least once: if cored stops after a hook
+// returns but before its progress is saved, the hook gets the block
+// again on restart. Hooks that must see each block exactly once
+// should record the blocks they've seen in the same transaction as
+// their own effects, as Outbox does.
+type Hook func(ctx context.Context, b *bc.Block) error
+
+var (
+	hooksMu sync.Mutex
+	hooks   = make(map[string]Hook)
+)
+
+// Register makes hook available under name. It panics if name is
+// already registered. Hooks must be registered before cored starts
+// processing blocks.
+func Register(name string, hook Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	if hook == nil {
+		panic("mirror: Register hook is nil")
+	}
+	if _, dup := hooks[name]; dup {
+		panic("mirror: Register called twice for hook " + name)
+	}
+	hooks[name] = hook
+}
+
+// PinName returns the name of the block processor pin
+// tracking the progress of the hook registered as name.
+func PinName(name string) string {
+	return "mirror-" + name
+}
+
+// CreatePins creates a pin for each registered hook that doesn't
+// have one. New hooks start with the block after height.
+func CreatePins(ctx context.Context, s *pin.Store, height uint64) error {
+	for _, name := range names() {
+		err := s.CreatePin(ctx, PinName(name), height)
+		if err != nil {
+			return errors.Wrapf(err, "creating pin for mirror hook %s", name)
+		}
+	}
+	return nil
+}
+
+// ProcessBlocks runs each registered hook on the blocks after its
+// pin's height, until ctx is canceled. It must be called only in
+// the leader process, as for other block processors.
+func ProcessBlocks(ctx context.Context, c *protocol.Chain, s *pin.Store) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	for name, hook := range hooks {
+		go s.ProcessBlocksInOrder(ctx, c, PinName(name), hook)
+	}
+}
+
+func names() []string {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	var a []string
+	for name := range hooks {
+		a = append(a, name)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// Outbox returns a Hook that writes each block to the mirror_outbox
+// table, for an external relay to read with ReadOutbox. Each block
+// is written only once, however often it's delivered, so the relay
+// sees every block exactly once, in order of height, as long as it
+// deletes blocks with TrimOutbox only after it's passed them on.
+func Outbox(db pg.DB) Hook {
+	return func(ctx context.Context, b *bc.Block) error {
+		// TrimOutbox leaves the latest block in place,
+		// so a block delivered again after being trimmed
+		// is still recognized as written.
+		const q = `
+			INSERT INTO mirror_outbox (height, block_hash, data)
+			SELECT $1, $2, $3
+			WHERE NOT EXISTS (SELECT 1 FROM mirror_outbox WHERE height >= $1)
+		`
+		_, err := db.Exec(ctx, q, b.Height, b.Hash(), b)
+		return errors.Wrap(err, "writing block to mirror outbox")
+	}
+}
+
+// ReadOutbox returns up to limit blocks from the mirror_outbox
+// table with heights greater than after, in order of height.
+func ReadOutbox(ctx context.Context, db pg.DB, after uint64, limit int) ([]*bc.Block, error) {
+	const q = `
+		SELECT data FROM mirror_outbox
+		WHERE height > $1 ORDER BY height LIMIT $2
+	`
+	var blocks []*bc.Block
+	err := pg.ForQueryRows(ctx, db, q, after, limit, func(b bc.Block) {
+		blocks = append(blocks, &b)
+	})
+	return blocks, errors.Wrap(err, "reading mirror outbox")
+}
+
+// TrimOutbox deletes the blocks through height from the
+// mirror_outbox table, except for the latest block written.
+func TrimOutbox(ctx context.Context, db pg.DB, height uint64) error {
+	const q = `
+		DELETE FROM mirror_outbox
+		WHERE height <= $1 AND height < (SELECT max(height) FROM mirror_outbox)
+	`
+	_, err := db.Exec(ctx, q, height)
+	return errors.Wrap(err, "trimming mirror outbox")
+}
@@ -0,0 +1,70 @@
+package mirror
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	hook := Outbox(db)
+
+	var blocks []*bc.Block
+	for h := uint64(1); h <= 3; h++ {
+		b := &bc.Block{BlockHeader: bc.BlockHeader{Version: 1, Height: h}}
+		blocks = append(blocks, b)
+		err := hook(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	// Delivering a block again doesn't write it again.
+	err := hook(ctx, blocks[1])
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := ReadOutbox(ctx, db, 0, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d blocks, want 3", len(got))
+	}
+	for i, b := range got {
+		if b.Hash() != blocks[i].Hash() {
+			t.Errorf("block %d: got hash %s want %s", i, b.Hash(), blocks[i].Hash())
+		}
+	}
+
+	got, err = ReadOutbox(ctx, db, 1, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || got[0].Height != 2 {
+		t.Errorf("ReadOutbox(after 1, limit 1) = %v, want block 2", got)
+	}
+
+	// Trimming leaves the latest block, so it's
+	// still not written again if it's redelivered.
+	err = TrimOutbox(ctx, db, 3)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = hook(ctx, blocks[2])
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = ReadOutbox(ctx, db, 0, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || got[0].Height != 3 {
+		t.Errorf("after trimming, got %v, want only block 3", got)
+	}
+}
@@ -0,0 +1,271 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// BackupVersion is the version of the backup format.
+const BackupVersion = 1
+
+// KEKSize is the size of the key-encryption keys
+// that wrap the keys in a backup.
+const KEKSize = 32
+
+var (
+	// ErrBadKEK is returned for key-encryption keys that
+	// are the wrong size, or don't match a backup's.
+	ErrBadKEK = errors.New("invalid key-encryption key")
+
+	// ErrBadBackup is returned for backups that are
+	// malformed or fail their integrity checks.
+	ErrBadBackup = errors.New("invalid key backup")
+)
+
+// A Backup holds keys of an HSM wrapped under a key-encryption
+// key (KEK) supplied by the operator, so they can be moved to
+// another HSM. Each key is encrypted with NaCl secretbox. MAC
+// authenticates the whole backup, including the keys' aliases
+// and metadata, so keys can't be dropped, swapped, or relabeled
+// undetected. KEKID identifies the KEK without revealing it.
+type Backup struct {
+	Version   int                `json:"version"`
+	KEKID     chainjson.HexBytes `json:"kek_id"`
+	CreatedAt time.Time          `json:"created_at"`
+	Keys      []*BackupKey       `json:"keys"`
+	MAC       chainjson.HexBytes `json:"mac"`
+}
+
+// A BackupKey is a wrapped key in a Backup. Metadata is
+// whatever the caller of HSM.Backup attached to the key.
+type BackupKey struct {
+	Type     string             `json:"type"`
+	Pub      chainjson.HexBytes `json:"pub"`
+	Alias    *string            `json:"alias"`
+	Metadata json.RawMessage    `json:"metadata,omitempty"`
+	Nonce    chainjson.HexBytes `json:"nonce"`
+	Wrapped  chainjson.HexBytes `json:"wrapped"`
+}
+
+// A RestoredKey is a key restored from a Backup.
+// Existed is true if the HSM already had it.
+type RestoredKey struct {
+	Type     string             `json:"type"`
+	Pub      chainjson.HexBytes `json:"pub"`
+	Alias    *string            `json:"alias"`
+	Metadata json.RawMessage    `json:"metadata,omitempty"`
+	Existed  bool               `json:"existed"`
+}
+
+// Backup returns the keys with the given aliases, or all keys
+// if there are none, wrapped under kek. Metadata, keyed by the
+// hex of keys' public keys, is attached to the keys it names.
+func (h *HSM) Backup(ctx context.Context, kek []byte, aliases []string, metadata map[string]json.RawMessage) (*Backup, error) {
+	if len(kek) != KEKSize {
+		return nil, errors.WithDetailf(ErrBadKEK, "must be %d bytes", KEKSize)
+	}
+	wrapKey, macKey := kekSubkeys(kek)
+	b := &Backup{
+		Version:   BackupVersion,
+		KEKID:     kekID(kek),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Keys:      []*BackupKey{},
+	}
+
+	q := `SELECT key_type, pub, prv, alias FROM mockhsm`
+	var params []interface{}
+	if len(aliases) > 0 {
+		q += ` WHERE alias = ANY($1)`
+		params = append(params, pq.StringArray(aliases))
+	}
+	q += ` ORDER BY sort_id`
+	params = append(params, func(typ string, pub, prv []byte, alias sql.NullString) error {
+		var nonce [24]byte
+		_, err := rand.Read(nonce[:])
+		if err != nil {
+			return errors.Wrap(err, "generating nonce")
+		}
+		k := &BackupKey{
+			Type:    typ,
+			Pub:     pub,
+			Nonce:   nonce[:],
+			Wrapped: secretbox.Seal(nil, prv, &nonce, wrapKey),
+		}
+		if alias.Valid {
+			k.Alias = &alias.String
+		}
+		k.Metadata = metadata[hex.EncodeToString(pub)]
+		b.Keys = append(b.Keys, k)
+		return nil
+	})
+	err := pg.ForQueryRows(ctx, h.db, q, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading keys")
+	}
+	b.MAC = b.mac(macKey)
+	return b, nil
+}
+
+// Restore checks the integrity of b and unwraps its keys with
+// kek, then stores those the HSM doesn't already have. No key
+// is stored unless every key in b checks out. Restoring a
+// backup again is harmless.
+func (h *HSM) Restore(ctx context.Context, kek []byte, b *Backup) ([]*RestoredKey, error) {
+	if len(kek) != KEKSize {
+		return nil, errors.WithDetailf(ErrBadKEK, "must be %d bytes", KEKSize)
+	}
+	if b.Version != BackupVersion {
+		return nil, errors.WithDetailf(ErrBadBackup, "unsupported version %d", b.Version)
+	}
+	if !bytes.Equal(b.KEKID, kekID(kek)) {
+		return nil, errors.WithDetail(ErrBadKEK, "backup was made with another key-encryption key")
+	}
+	wrapKey, macKey := kekSubkeys(kek)
+	if !hmac.Equal(b.MAC, b.mac(macKey)) {
+		return nil, errors.WithDetail(ErrBadBackup, "integrity check failed")
+	}
+
+	prvs := make([][]byte, 0, len(b.Keys))
+	for i, k := range b.Keys {
+		if len(k.Nonce) != 24 {
+			return nil, errors.WithDetailf(ErrBadBackup, "key %d: nonce must be 24 bytes", i)
+		}
+		var nonce [24]byte
+		copy(nonce[:], k.Nonce)
+		prv, ok := secretbox.Open(nil, k.Wrapped, &nonce, wrapKey)
+		if !ok {
+			return nil, errors.WithDetailf(ErrBadBackup, "key %d: cannot unwrap", i)
+		}
+		if !matchesPub(k.Type, k.Pub, prv) {
+			return nil, errors.WithDetailf(ErrBadBackup, "key %d: private key does not match public key", i)
+		}
+		prvs = append(prvs, prv)
+	}
+
+	const q = `
+		INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pub) DO NOTHING
+	`
+	var restored []*RestoredKey
+	for i, k := range b.Keys {
+		alias := sql.NullString{Valid: k.Alias != nil}
+		if k.Alias != nil {
+			alias.String = *k.Alias
+		}
+		res, err := h.db.Exec(ctx, q, []byte(k.Pub), prvs[i], alias, k.Type)
+		if pg.IsUniqueViolation(err) {
+			return restored, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias.String)
+		}
+		if err != nil {
+			return restored, errors.Wrap(err, "storing restored key")
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return restored, errors.Wrap(err)
+		}
+		restored = append(restored, &RestoredKey{
+			Type:     k.Type,
+			Pub:      k.Pub,
+			Alias:    k.Alias,
+			Metadata: k.Metadata,
+			Existed:  n == 0,
+		})
+	}
+	return restored, nil
+}
+
+// mac returns the MAC of b under macKey. It covers every
+// field of b but MAC itself.
+func (b *Backup) mac(macKey []byte) []byte {
+	m := hmac.New(sha256.New, macKey)
+	writeUint := func(n uint64) {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], n)
+		m.Write(buf[:])
+	}
+	writeBytes := func(p []byte) {
+		writeUint(uint64(len(p)))
+		m.Write(p)
+	}
+	writeUint(uint64(b.Version))
+	writeBytes(b.KEKID)
+	writeUint(uint64(b.CreatedAt.Unix()))
+	writeUint(uint64(len(b.Keys)))
+	for _, k := range b.Keys {
+		writeBytes([]byte(k.Type))
+		writeBytes(k.Pub)
+		if k.Alias == nil {
+			writeUint(0)
+		} else {
+			writeUint(1)
+			writeBytes([]byte(*k.Alias))
+		}
+		// Metadata is compacted, so that reformatting
+		// the backup doesn't fail its integrity check.
+		var md bytes.Buffer
+		if len(k.Metadata) > 0 {
+			err := json.Compact(&md, k.Metadata)
+			if err != nil {
+				md.Reset()
+				md.Write(k.Metadata)
+			}
+		}
+		writeBytes(md.Bytes())
+		writeBytes(k.Nonce)
+		writeBytes(k.Wrapped)
+	}
+	return m.Sum(nil)
+}
+
+func matchesPub(typ string, pub, prv []byte) bool {
+	switch typ {
+	case "chain_kd":
+		var xprv chainkd.XPrv
+		if len(prv) != len(xprv) {
+			return false
+		}
+		copy(xprv[:], prv)
+		return bytes.Equal(xprv.XPub().Bytes(), pub)
+	case "ed25519":
+		if len(prv) != ed25519.PrivateKeySize {
+			return false
+		}
+		return bytes.Equal(ed25519.PrivateKey(prv).Public().(ed25519.PublicKey), pub)
+	}
+	return false
+}
+
+// kekSubkeys derives from kek separate keys
+// for wrapping keys and for the backup's MAC.
+func kekSubkeys(kek []byte) (wrapKey *[32]byte, macKey []byte) {
+	wrapKey = new([32]byte)
+	copy(wrapKey[:], kekHash(kek, "wrap"))
+	return wrapKey, kekHash(kek, "mac")
+}
+
+func kekID(kek []byte) []byte {
+	return kekHash(kek, "id")[:8]
+}
+
+func kekHash(kek []byte, purpose string) []byte {
+	m := hmac.New(sha256.New, kek)
+	m.Write([]byte("chain mockhsm backup " + purpose))
+	return m.Sum(nil)
+}
@@ -0,0 +1,75 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestBackupRestore(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+	a, err := hsm.XCreate(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hsm.XCreate(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kek := bytes.Repeat([]byte{1}, KEKSize)
+	md := map[string]json.RawMessage{a.XPub.String(): json.RawMessage(`{"label": "a"}`)}
+	b, err := hsm.Backup(ctx, kek, []string{"a"}, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Keys) != 1 || string(b.Keys[0].Metadata) != `{"label": "a"}` {
+		t.Fatalf("backup keys = %+v, want a with its metadata", b.Keys)
+	}
+
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	hsm2 := New(db2)
+	_, err = hsm2.Restore(ctx, bytes.Repeat([]byte{2}, KEKSize), b)
+	if errors.Root(err) != ErrBadKEK {
+		t.Errorf("restore with wrong kek err = %v, want ErrBadKEK", err)
+	}
+
+	tampered := *b
+	tampered.Keys = []*BackupKey{new(BackupKey)}
+	*tampered.Keys[0] = *b.Keys[0]
+	alias := "c"
+	tampered.Keys[0].Alias = &alias
+	_, err = hsm2.Restore(ctx, kek, &tampered)
+	if errors.Root(err) != ErrBadBackup {
+		t.Errorf("restore of relabeled backup err = %v, want ErrBadBackup", err)
+	}
+
+	got, err := hsm2.Restore(ctx, kek, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Existed {
+		t.Fatalf("restored %+v, want one new key", got)
+	}
+	msg := []byte("msg")
+	sig, err := hsm2.XSign(ctx, a.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.XPub.Verify(msg, sig) {
+		t.Error("restored key's signature doesn't verify")
+	}
+
+	got, err = hsm2.Restore(ctx, kek, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !got[0].Existed {
+		t.Errorf("restored again %+v, want the key to exist", got)
+	}
+}
@@ -0,0 +1,55 @@
+package mockhsm
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/keystore"
+	"chain/errors"
+)
+
+// ErrDuplicateKey is returned when importing
+// a key the HSM already has.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// exportParams are the scrypt parameters of exported key files.
+var exportParams = keystore.StandardParams
+
+// XExport returns the xprv of xpub as a key file
+// encrypted under passphrase. See package keystore.
+func (h *HSM) XExport(ctx context.Context, xpub chainkd.XPub, passphrase []byte) (*keystore.Key, error) {
+	xprv, err := h.loadChainKDKey(ctx, xpub)
+	if err != nil {
+		return nil, err
+	}
+	return keystore.Create(xprv, passphrase, exportParams)
+}
+
+// XImport unlocks the key file k with passphrase and stores
+// its xprv with the given alias, if any.
+func (h *HSM) XImport(ctx context.Context, alias string, k *keystore.Key, passphrase []byte) (*XPub, error) {
+	xprv, err := k.Unlock(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	xpub := xprv.XPub()
+	var ptrAlias *string
+	if alias != "" {
+		ptrAlias = &alias
+	}
+	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, 'chain_kd')`
+	_, err = h.db.Exec(ctx, q, xpub.Bytes(), xprv.Bytes(), sql.NullString{String: alias, Valid: alias != ""})
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+		if pqErr.Constraint == "mockhsm_alias_key" {
+			return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
+		}
+		return nil, errors.WithDetailf(ErrDuplicateKey, "xpub: %s", xpub)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "storing imported xpub")
+	}
+	return &XPub{XPub: xpub, Alias: ptrAlias}, nil
+}
@@ -0,0 +1,56 @@
+package mockhsm
+
+import (
+	"context"
+	"testing"
+
+	"chain/crypto/keystore"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func init() {
+	exportParams = keystore.Params{N: 1 << 10, R: 8, P: 1}
+}
+
+func TestExportImport(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+	xpub, err := hsm.XCreate(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := hsm.XExport(ctx, xpub.XPub, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Importing into a fresh HSM gives back the same key.
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	hsm2 := New(db2)
+	_, err = hsm2.XImport(ctx, "b", k, []byte("wrong"))
+	if errors.Root(err) != keystore.ErrBadPassphrase {
+		t.Errorf("import with wrong passphrase err = %v, want ErrBadPassphrase", err)
+	}
+	got, err := hsm2.XImport(ctx, "b", k, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.XPub != xpub.XPub || *got.Alias != "b" {
+		t.Errorf("imported %s %s, want %s b", got.XPub, *got.Alias, xpub.XPub)
+	}
+	msg := []byte("msg")
+	sig, err := hsm2.XSign(ctx, got.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("imported key's signature doesn't verify")
+	}
+
+	_, err = hsm2.XImport(ctx, "c", k, []byte("pass"))
+	if errors.Root(err) != ErrDuplicateKey {
+		t.Errorf("second import err = %v, want ErrDuplicateKey", err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/kms"
 	"chain/database/pg"
 	"chain/errors"
 )
@@ -34,6 +35,8 @@ type HSM struct {
 	cacheMu sync.Mutex
 	kdCache map[chainkd.XPub]chainkd.XPrv
 	edCache map[string]ed25519.PrivateKey // ed25519.PublicKeys must be turned into strings before being used as map keys
+
+	kmsSigners map[string]kms.Signer // keyed like edCache; see AddSigner
 }
 
 type XPub struct {
@@ -51,6 +54,8 @@ func New(db pg.DB) *HSM {
 		db:      db,
 		kdCache: make(map[chainkd.XPub]chainkd.XPrv),
 		edCache: make(map[string]ed25519.PrivateKey),
+
+		kmsSigners: make(map[string]kms.Signer),
 	}
 }
 
@@ -215,6 +220,15 @@ func (h *HSM) loadChainKDKey(ctx context.Context, xpub chainkd.XPub) (xprv chain
 	return xprv, nil
 }
 
+// HasChainKDKey reports whether h holds the xprv for xpub.
+func (h *HSM) HasChainKDKey(ctx context.Context, xpub chainkd.XPub) (bool, error) {
+	_, err := h.loadChainKDKey(ctx, xpub)
+	if err == ErrNoKey {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // XSign looks up the xprv given the xpub, optionally derives a new
 // xprv with the given path (but does not store the new xprv), and
 // signs the given msg.
@@ -258,8 +272,25 @@ func (h *HSM) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (prv ed
 	return prv, nil
 }
 
+// AddSigner has h sign for the public key of s by delegating to
+// s, in place of a key of its own, so that ed25519 keys can be
+// held in a cloud KMS. See package kms.
+func (h *HSM) AddSigner(s kms.Signer) {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	h.kmsSigners[string(s.Public())] = s
+}
+
 // Sign looks up the prv given the pub and signs the given msg.
+// Keys added with AddSigner sign with their KMS signer.
 func (h *HSM) Sign(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error) {
+	h.cacheMu.Lock()
+	s, ok := h.kmsSigners[string(pub)]
+	h.cacheMu.Unlock()
+	if ok {
+		return s.Sign(ctx, msg)
+	}
+
 	prv, err := h.loadEd25519Key(ctx, pub)
 	if err != nil {
 		return nil, err
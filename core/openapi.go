@@ -0,0 +1,26 @@
+package core
+
+import (
+	"expvar"
+	"reflect"
+	"strconv"
+
+	"chain/net/http/httpjson"
+)
+
+// GET /openapi.json
+//
+// openAPI describes the client API in an OpenAPI 3 document,
+// generated from the request and response types of its handlers.
+// Requests to those handlers are validated against the same
+// types; see httpjson.StrictHandler.
+func (h *Handler) openAPI() map[string]interface{} {
+	version := "dev"
+	if v := expvar.Get("buildtag"); v != nil {
+		if s, err := strconv.Unquote(v.String()); err == nil {
+			version = s
+		}
+	}
+	errSchema := httpjson.Schema(reflect.TypeOf(detailedError{}))
+	return httpjson.OpenAPI("Chain Core API", version, h.apiRoutes, errSchema)
+}
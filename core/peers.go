@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"chain/core/accesstoken"
+	"chain/core/tenant"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+const (
+	// peerSeenInterval is how often the last-seen time
+	// of a peer core is written to the database.
+	peerSeenInterval = 10 * time.Second
+
+	// peerConnectedWindow is how recently a peer core must have
+	// made a request to be reported as connected.
+	peerConnectedWindow = time.Minute
+)
+
+// peerTracker applies the rate limits of network access tokens
+// and records when each token was last used.
+type peerTracker struct {
+	tokens *accesstoken.CredentialStore
+
+	mu    sync.Mutex // protects peers
+	peers map[string]*peer
+}
+
+type peer struct {
+	limit      int
+	limiter    *rate.Limiter
+	limitAt    time.Time // when limit was loaded
+	recordedAt time.Time // when last-seen was last saved
+}
+
+// admit returns errRateLimited if the peer core using network
+// access token id has exceeded its rate limit. Otherwise it
+// notes that the peer was seen at addr.
+func (t *peerTracker) admit(ctx context.Context, id, addr string) error {
+	now := time.Now()
+	t.mu.Lock()
+	p := t.peers[id]
+	if p == nil {
+		p = new(peer)
+		t.peers[id] = p
+	}
+	reload := now.Sub(p.limitAt) > tokenExpiry
+	record := now.Sub(p.recordedAt) > peerSeenInterval
+	if record {
+		p.recordedAt = now
+	}
+	t.mu.Unlock()
+
+	if reload {
+		limit, err := t.tokens.RateLimit(ctx, id)
+		if err != nil {
+			return err
+		}
+		t.mu.Lock()
+		if limit != p.limit || p.limiter == nil {
+			p.limit = limit
+			p.limiter = rate.NewLimiter(rate.Limit(limit), limit)
+		}
+		p.limitAt = now
+		t.mu.Unlock()
+	}
+
+	if record {
+		err := t.tokens.RecordSeen(ctx, id, addr, now)
+		if err != nil {
+			log.Error(ctx, err, "recording peer last seen")
+		}
+	}
+
+	t.mu.Lock()
+	allow := p.limit == 0 || p.limiter.AllowN(now, 1)
+	t.mu.Unlock()
+	if !allow {
+		return errRateLimited
+	}
+	return nil
+}
+
+// forget discards what t knows about access token id,
+// so a changed rate limit takes effect at once.
+func (t *peerTracker) forget(id string) {
+	t.mu.Lock()
+	delete(t.peers, id)
+	t.mu.Unlock()
+}
+
+func (t *peerTracker) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if id := accessTokenID(ctx); id != "" && strings.HasPrefix(req.URL.Path, networkRPCPrefix) {
+			err := t.admit(ctx, id, req.RemoteAddr)
+			if err != nil {
+				WriteHTTPError(ctx, rw, err)
+				return
+			}
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+type peerStatus struct {
+	*accesstoken.Token
+	Connected bool `json:"connected"`
+}
+
+// POST /network-status
+//
+// Lists the peer cores with network access tokens, when and from
+// where each last made a request, and whether it is connected:
+// whether it did so in the last minute.
+func (h *Handler) networkStatus(ctx context.Context) (map[string]interface{}, error) {
+	var (
+		peers []*peerStatus
+		after string
+		now   = time.Now()
+	)
+	for {
+		tokens, next, err := h.AccessTokens.List(ctx, "network", after, defGenericPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, tok := range tokens {
+			connected := tok.LastSeenAt != nil && now.Sub(*tok.LastSeenAt) < peerConnectedWindow
+			peers = append(peers, &peerStatus{Token: tok, Connected: connected})
+		}
+		if len(tokens) < defGenericPageSize {
+			break
+		}
+		after = next
+	}
+
+	var connected int
+	for _, p := range peers {
+		if p.Connected {
+			connected++
+		}
+	}
+	return map[string]interface{}{
+		"peers":           httpjson.Array(peers),
+		"connected_peers": connected,
+	}, nil
+}
+
+// POST /set-peer-rate-limit
+//
+// Sets the number of requests per second the peer core using a
+// network access token may make. A rate_limit of 0 removes it.
+func (h *Handler) setPeerRateLimit(ctx context.Context, x struct {
+	ID        string `json:"id"`
+	RateLimit int    `json:"rate_limit"`
+}) error {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return errOtherTenant
+	}
+	err := h.AccessTokens.SetRateLimit(ctx, x.ID, x.RateLimit)
+	if err != nil {
+		return err
+	}
+	h.peers.forget(x.ID)
+	return nil
+}
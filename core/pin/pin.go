@@ -53,6 +53,25 @@ func (s *Store) ProcessBlocks(ctx context.Context, c *protocol.Chain, pinName st
 	}
 }
 
+// ProcessBlocksInOrder is like ProcessBlocks, but calls cb for
+// one block at a time, in order of height. It doesn't start a block
+// until cb has succeeded for the one before it.
+func (s *Store) ProcessBlocksInOrder(ctx context.Context, c *protocol.Chain, pinName string, cb func(context.Context, *bc.Block) error) {
+	p := <-s.pin(pinName)
+	height := p.getHeight()
+	for {
+		select {
+		case <-ctx.Done(): // leader deposed
+			log.Error(ctx, ctx.Err())
+			return
+		case <-c.BlockWaiter(height + 1):
+			p.sem <- true
+			p.processBlock(ctx, c, height+1, cb)
+			height++
+		}
+	}
+}
+
 func (s *Store) CreatePin(ctx context.Context, name string, height uint64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -72,6 +91,15 @@ func (s *Store) CreatePin(ctx context.Context, name string, height uint64) error
 	return nil
 }
 
+// Rewind sets the height of the pin called name in db, so that
+// its block processor starts again after height. It must only be
+// called while no process is running the pin.
+func Rewind(ctx context.Context, db pg.DB, name string, height uint64) error {
+	const q = `UPDATE block_processors SET height=$2 WHERE name=$1`
+	_, err := db.Exec(ctx, q, name, height)
+	return errors.Wrap(err)
+}
+
 func (s *Store) Height(name string) uint64 {
 	p := <-s.pin(name)
 	return p.getHeight()
@@ -254,8 +282,7 @@ func (p *pin) complete(ctx context.Context, height uint64) error {
 		return err
 	}
 
-	const notifyQ = `SELECT pg_notify($1, $2)`
-	_, err = p.db.Exec(ctx, notifyQ, "pin-"+p.name, max)
+	err = pg.Notify(ctx, p.db, "pin-"+p.name, strconv.FormatUint(max, 10))
 	if err != nil {
 		return err
 	}
@@ -1,15 +1,20 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"time"
 
+	"chain/core/asset"
 	"chain/core/query"
 	"chain/core/query/filter"
 	"chain/errors"
 	"chain/net/http/httpjson"
+	"chain/protocol/bc"
 )
 
 // These types enforce the ordering of JSON fields in API output.
@@ -22,6 +27,7 @@ type (
 		AssetTags       interface{} `json:"asset_tags,omitempty"`
 		AssetIsLocal    interface{} `json:"asset_is_local"`
 		Amount          interface{} `json:"amount"`
+		AmountDisplay   interface{} `json:"amount_display,omitempty"`
 		IssuanceProgram interface{} `json:"issuance_program,omitempty"`
 		SpentOutput     interface{} `json:"spent_output,omitempty"`
 		*txAccount
@@ -38,6 +44,7 @@ type (
 		AssetTags       interface{} `json:"asset_tags"`
 		AssetIsLocal    interface{} `json:"asset_is_local"`
 		Amount          interface{} `json:"amount"`
+		AmountDisplay   interface{} `json:"amount_display,omitempty"`
 		*txAccount
 		ControlProgram interface{} `json:"control_program"`
 		ReferenceData  interface{} `json:"reference_data"`
@@ -49,6 +56,8 @@ type (
 		BlockID       interface{} `json:"block_id"`
 		BlockHeight   interface{} `json:"block_height"`
 		Position      interface{} `json:"position"`
+		Confirmations uint64      `json:"confirmations"`
+		Final         bool        `json:"final"`
 		ReferenceData interface{} `json:"reference_data"`
 		IsLocal       interface{} `json:"is_local"`
 		Inputs        interface{} `json:"inputs"`
@@ -82,6 +91,10 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 	if err != nil {
 		return result, err
 	}
+	ctx, err = h.rollUp(ctx, in)
+	if err != nil {
+		return result, err
+	}
 
 	endTimeMS := in.EndTimeMS
 	if endTimeMS == 0 {
@@ -103,11 +116,12 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 	}
 
 	limit := defGenericPageSize
-	txns, nextAfter, err := h.Indexer.Transactions(ctx, p, in.FilterParams, after, limit, in.AscLongPoll)
+	txns, nextAfter, err := h.Indexer.Transactions(ctx, p, in.FilterParams, in.Search, after, limit, in.AscLongPoll)
 	if err != nil {
 		return result, errors.Wrap(err, "running tx query")
 	}
 
+	fin := h.finality()
 	resp := make([]*txResp, 0, len(txns))
 	for _, t := range txns {
 		tjson, ok := t.(*json.RawMessage)
@@ -118,7 +132,7 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 			return result, fmt.Errorf("unexpected nil in Indexer.Transactions output")
 		}
 		var tx map[string]interface{}
-		err = json.Unmarshal(*tjson, &tx)
+		tx, err = decodeAnnotated(*tjson)
 		if err != nil {
 			return result, errors.Wrap(err, "decoding Indexer.Transactions output")
 		}
@@ -161,6 +175,7 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 				AssetTags:       in["asset_tags"],
 				AssetIsLocal:    in["asset_is_local"],
 				Amount:          in["amount"],
+				AmountDisplay:   displayAmount(in["amount"], in["asset_definition"]),
 				IssuanceProgram: in["issuance_program"],
 				SpentOutput:     in["spent_output"],
 				txAccount:       txAccountFromMap(in),
@@ -181,6 +196,7 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 				AssetTags:       out["asset_tags"],
 				AssetIsLocal:    out["asset_is_local"],
 				Amount:          out["amount"],
+				AmountDisplay:   displayAmount(out["amount"], out["asset_definition"]),
 				txAccount:       txAccountFromMap(out),
 				ControlProgram:  out["control_program"],
 				ReferenceData:   out["reference_data"],
@@ -188,12 +204,15 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 			}
 			outResps = append(outResps, r)
 		}
+		confs, final := fin.of(tx["block_height"])
 		r := &txResp{
 			ID:            tx["id"],
 			Timestamp:     tx["timestamp"],
 			BlockID:       tx["block_id"],
 			BlockHeight:   tx["block_height"],
 			Position:      tx["position"],
+			Confirmations: confs,
+			Final:         final,
 			ReferenceData: tx["reference_data"],
 			IsLocal:       tx["is_local"],
 			Inputs:        inResps,
@@ -211,6 +230,84 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 	}, nil
 }
 
+// rollUp returns ctx, for queries restricted to the account in
+// in.RollUpAccountID and its descendants, if it's set.
+func (h *Handler) rollUp(ctx context.Context, in requestQuery) (context.Context, error) {
+	if in.RollUpAccountID == "" {
+		return ctx, nil
+	}
+	_, err := h.Accounts.FindByID(ctx, in.RollUpAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return query.NewRollUpContext(ctx, in.RollUpAccountID), nil
+}
+
+// finality is what's needed to tell how many confirmations
+// a transaction has, and whether it's final: the heights of
+// the blockchain and of its latest final block. Computing
+// these when a transaction is read, rather than storing them
+// in the query indexes, spares rewriting every indexed
+// transaction with each new block.
+type finality struct {
+	height, final uint64
+}
+
+func (h *Handler) finality() finality {
+	return finality{height: h.Chain.Height(), final: h.Chain.FinalHeight()}
+}
+
+// of returns the number of confirmations of a transaction
+// in the block at blockHeight, counting that block, and
+// whether it's final. BlockHeight is as decoded from an
+// annotated transaction.
+func (f finality) of(blockHeight interface{}) (confirmations uint64, final bool) {
+	bh, ok := annotatedUint(blockHeight)
+	if !ok || bh < 1 || bh > f.height {
+		return 0, false
+	}
+	return f.height - bh + 1, bh <= f.final
+}
+
+// decodeAnnotated decodes an annotated object from the query
+// indexes, keeping its numbers, such as amounts, exact.
+func decodeAnnotated(b []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&m)
+	return m, err
+}
+
+// annotatedUint returns v, a number decoded from an annotated
+// object with or without decodeAnnotated, as a uint64.
+func annotatedUint(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		n, err := strconv.ParseUint(string(v), 10, 64)
+		return n, err == nil
+	case float64:
+		return uint64(v), v >= 0 && v == math.Trunc(v)
+	}
+	return 0, false
+}
+
+// displayAmount returns amount, in base units, in the display
+// units of the asset with definition def, or nil if def declares
+// no decimals. Both are as decoded from an annotated object.
+func displayAmount(amount, def interface{}) interface{} {
+	d, _ := def.(map[string]interface{})
+	decimals, ok := asset.Decimals(d)
+	if !ok {
+		return nil
+	}
+	n, ok := annotatedUint(amount)
+	if !ok {
+		return nil
+	}
+	return asset.FormatAmount(n, decimals)
+}
+
 // listAccounts is an http handler for listing accounts matching
 // an index or an ad-hoc filter.
 //
@@ -249,14 +346,20 @@ func (h *Handler) listAccounts(ctx context.Context, in requestQuery) (page, erro
 			}
 		}
 		r := &accountResponse{
-			ID:     a["id"],
-			Alias:  a["alias"],
-			Keys:   orderedKeys,
-			Quorum: a["quorum"],
-			Tags:   a["tags"],
+			ID:        a["id"],
+			Alias:     a["alias"],
+			Keys:      orderedKeys,
+			Quorum:    a["quorum"],
+			Tags:      a["tags"],
+			WatchOnly: a["watch_only"] == true,
+			ParentID:  a["parent_id"],
 		}
 		result = append(result, r)
 	}
+	err = h.addAccountKeyMetadata(ctx, result)
+	if err != nil {
+		return page{}, err
+	}
 
 	// Pull in the accounts by the IDs
 	out := in
@@ -276,6 +379,10 @@ func (h *Handler) listBalances(ctx context.Context, in requestQuery) (result pag
 	if err != nil {
 		return result, err
 	}
+	ctx, err = h.rollUp(ctx, in)
+	if err != nil {
+		return result, err
+	}
 
 	// Since an empty SumBy yields a meaningless result, we'll provide a
 	// sensible default here.
@@ -310,6 +417,80 @@ func (h *Handler) listBalances(ctx context.Context, in requestQuery) (result pag
 	return result, nil
 }
 
+// POST /list-balance-snapshots
+//
+// Lists end-of-day balances of accounts for dates from start_time
+// through end_time, inclusive, optionally for a single account_id
+// or asset_id. Balances are recorded for each day, in UTC, once the
+// core has indexed a block from the following day.
+func (h *Handler) listBalanceSnapshots(ctx context.Context, in requestQuery) (result page, err error) {
+	var after *query.BalanceSnapshotsAfter
+	if in.After != "" {
+		after, err = query.DecodeBalanceSnapshotsAfter(in.After)
+		if err != nil {
+			return result, errors.Wrap(err, "decoding `after`")
+		}
+	}
+
+	if in.StartTimeMS > math.MaxInt64 || in.EndTimeMS > math.MaxInt64 {
+		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
+	}
+	start := time.Unix(int64(in.StartTimeMS/1000), int64(in.StartTimeMS%1000)*int64(time.Millisecond))
+	end := time.Now()
+	if in.EndTimeMS != 0 {
+		end = time.Unix(int64(in.EndTimeMS/1000), int64(in.EndTimeMS%1000)*int64(time.Millisecond))
+	}
+
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	snapshots, nextAfter, err := h.Indexer.BalanceSnapshots(ctx, in.AccountID, in.AssetID, start, end, after, limit)
+	if err != nil {
+		return result, err
+	}
+
+	in.After = nextAfter.String()
+	result.Items = httpjson.Array(snapshots)
+	result.LastPage = len(snapshots) < limit
+	result.Next = in
+	return result, nil
+}
+
+// POST /list-asset-flows
+//
+// Gives, for each asset, or only for asset_id, a graph of the
+// flow of the asset between clusters of accounts in the blocks
+// from start_time through end_time: what each cluster received
+// and sent, its top counterparties, and what each cluster sent
+// each other. Clusters are the values of the input and output
+// field cluster_by, account_id by default. See query.AssetFlows.
+func (h *Handler) listAssetFlows(ctx context.Context, in struct {
+	AssetID           string `json:"asset_id"`
+	ClusterBy         string `json:"cluster_by"`
+	StartTimeMS       uint64 `json:"start_time"`
+	EndTimeMS         uint64 `json:"end_time"`
+	TopCounterparties int    `json:"top_counterparties"`
+}) ([]*query.FlowGraph, error) {
+	if in.ClusterBy == "" {
+		in.ClusterBy = "account_id"
+	}
+	clusterBy, err := filter.ParseField(in.ClusterBy)
+	if err != nil {
+		return nil, err
+	}
+	if in.EndTimeMS == 0 {
+		in.EndTimeMS = math.MaxInt64
+	}
+	if in.StartTimeMS > math.MaxInt64 || in.EndTimeMS > math.MaxInt64 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
+	}
+	if in.TopCounterparties <= 0 {
+		in.TopCounterparties = 5
+	}
+	return h.Indexer.AssetFlows(ctx, in.AssetID, clusterBy, in.StartTimeMS, in.EndTimeMS, in.TopCounterparties)
+}
+
 // This type enforces the ordering of JSON fields in API output.
 type utxoResp struct {
 	Type            interface{} `json:"type"`
@@ -322,6 +503,7 @@ type utxoResp struct {
 	AssetTags       interface{} `json:"asset_tags"`
 	AssetIsLocal    interface{} `json:"asset_is_local"`
 	Amount          interface{} `json:"amount"`
+	AmountDisplay   interface{} `json:"amount_display,omitempty"`
 	AccountID       interface{} `json:"account_id"`
 	AccountAlias    interface{} `json:"account_alias"`
 	AccountTags     interface{} `json:"account_tags"`
@@ -337,6 +519,10 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 	if err != nil {
 		return result, err
 	}
+	ctx, err = h.rollUp(ctx, in)
+	if err != nil {
+		return result, err
+	}
 
 	var after *query.OutputsAfter
 	if in.After != "" {
@@ -368,7 +554,7 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 			return result, fmt.Errorf("unexpected nil in Indexer.Outputs output")
 		}
 		var out map[string]interface{}
-		err = json.Unmarshal(*ojson, &out)
+		out, err = decodeAnnotated(*ojson)
 		if err != nil {
 			return result, errors.Wrap(err, "decoding Indexer.Outputs output")
 		}
@@ -383,6 +569,7 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 			AssetTags:       out["asset_tags"],
 			AssetIsLocal:    out["asset_is_local"],
 			Amount:          out["amount"],
+			AmountDisplay:   displayAmount(out["amount"], out["asset_definition"]),
 			AccountID:       out["account_id"],
 			AccountAlias:    out["account_alias"],
 			AccountTags:     out["account_tags"],
@@ -402,6 +589,71 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 	}, nil
 }
 
+// defSpendChainDepth and maxSpendChainDepth bound the depth of
+// the spend chains returned by /get-output.
+const (
+	defSpendChainDepth = 10
+	maxSpendChainDepth = 100
+)
+
+// POST /get-output
+//
+// Returns an output, whether it's spent and by which transaction
+// input, and its spend chain: the transactions that, directly or
+// through their own outputs, spent it, up to depth transactions
+// deep, with the status of their outputs.
+func (h *Handler) getOutput(ctx context.Context, in struct {
+	TransactionID bc.Hash `json:"transaction_id"`
+	Position      uint32  `json:"position"`
+	Depth         int     `json:"depth"`
+}) (*query.OutputDetail, error) {
+	if in.Depth == 0 {
+		in.Depth = defSpendChainDepth
+	}
+	if in.Depth < 0 || in.Depth > maxSpendChainDepth {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "depth must be from 1 to %d", maxSpendChainDepth)
+	}
+	op := bc.Outpoint{Hash: in.TransactionID, Index: in.Position}
+	return h.Indexer.OutputDetail(ctx, op, in.Depth)
+}
+
+// defTraceHops and maxTraceHops bound the number of
+// transactions /trace-output walks in each direction.
+const (
+	defTraceHops = 5
+	maxTraceHops = 50
+)
+
+// POST /trace-output
+//
+// Returns the provenance tree of an output, for source-of-funds
+// review: up to hops transactions backward, through transfers of
+// the output's asset, to the outputs it came from and where it
+// was issued, and forward to the outputs it went to. Direction is
+// backward, forward, or both, the default.
+func (h *Handler) traceOutput(ctx context.Context, in struct {
+	TransactionID bc.Hash `json:"transaction_id"`
+	Position      uint32  `json:"position"`
+	Direction     string  `json:"direction"`
+	Hops          int     `json:"hops"`
+}) (*query.Trace, error) {
+	switch in.Direction {
+	case "":
+		in.Direction = query.TraceBoth
+	case query.TraceBackward, query.TraceForward, query.TraceBoth:
+	default:
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "unknown direction %q", in.Direction)
+	}
+	if in.Hops == 0 {
+		in.Hops = defTraceHops
+	}
+	if in.Hops < 0 || in.Hops > maxTraceHops {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "hops must be from 1 to %d", maxTraceHops)
+	}
+	op := bc.Outpoint{Hash: in.TransactionID, Index: in.Position}
+	return h.Indexer.Trace(ctx, op, in.Direction, in.Hops)
+}
+
 // listAssets is an http handler for listing assets matching
 // an index or an ad-hoc filter.
 //
@@ -446,6 +698,8 @@ func (h *Handler) listAssets(ctx context.Context, in requestQuery) (page, error)
 			Keys:            orderedKeys,
 			Quorum:          a["quorum"],
 			Definition:      a["definition"],
+			Decimals:        a["decimals"],
+			Symbol:          a["symbol"],
 			Tags:            a["tags"],
 			IsLocal:         a["is_local"],
 		}
@@ -454,6 +708,10 @@ func (h *Handler) listAssets(ctx context.Context, in requestQuery) (page, error)
 		}
 		result = append(result, r)
 	}
+	err = h.addAssetKeyMetadata(ctx, result)
+	if err != nil {
+		return page{}, err
+	}
 
 	out := in
 	out.After = after
@@ -8,10 +8,12 @@ import (
 	"strconv"
 
 	"chain/core/query/filter"
+	"chain/core/tenant"
 	"chain/errors"
 )
 
 // SaveAnnotatedAccount saves an annotated account to the query indexes.
+// A newly saved account belongs to the tenant that ctx acts for.
 func (ind *Indexer) SaveAnnotatedAccount(ctx context.Context, accountID string, account map[string]interface{}) error {
 	b, err := json.Marshal(account)
 	if err != nil {
@@ -19,10 +21,10 @@ func (ind *Indexer) SaveAnnotatedAccount(ctx context.Context, accountID string,
 	}
 
 	const q = `
-		INSERT INTO annotated_accounts (id, data) VALUES($1, $2)
+		INSERT INTO annotated_accounts (id, data, tenant) VALUES($1, $2, $3)
 		ON CONFLICT (id) DO UPDATE SET data = $2
 	`
-	_, err = ind.db.Exec(ctx, q, accountID, b)
+	_, err = ind.db.Exec(ctx, q, accountID, b, tenant.FromContext(ctx))
 	return errors.Wrap(err, "saving annotated account")
 }
 
@@ -35,6 +37,7 @@ func (ind *Indexer) Accounts(ctx context.Context, p filter.Predicate, vals []int
 	if err != nil {
 		return nil, "", errors.Wrap(err, "converting to SQL")
 	}
+	expr = restrictToTenant(ctx, expr, tenantRows)
 
 	queryStr, queryArgs := constructAccountsQuery(expr, after, limit)
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
@@ -13,15 +13,20 @@ import (
 	"chain/protocol/vmutil"
 )
 
+// transactionObject returns the annotated transaction object
+// of orig, at position indexInBlock of b. If b is nil, orig
+// isn't yet in a block, and the object has no block fields.
 func transactionObject(orig *bc.Tx, b *bc.Block, indexInBlock uint32) map[string]interface{} {
 	m := map[string]interface{}{
 		"id":             orig.Hash.String(),
-		"timestamp":      b.Time().Format(time.RFC3339),
-		"block_id":       b.Hash().String(),
-		"block_height":   b.Height,
-		"position":       indexInBlock,
 		"reference_data": unmarshalReferenceData(orig.ReferenceData),
 	}
+	if b != nil {
+		m["timestamp"] = b.Time().Format(time.RFC3339)
+		m["block_id"] = b.Hash().String()
+		m["block_height"] = b.Height
+		m["position"] = indexInBlock
+	}
 
 	inputs := make([]interface{}, 0, len(orig.Inputs))
 	for _, in := range orig.Inputs {
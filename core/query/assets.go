@@ -8,11 +8,13 @@ import (
 	"strconv"
 
 	"chain/core/query/filter"
+	"chain/core/tenant"
 	"chain/errors"
 	"chain/protocol/bc"
 )
 
 // SaveAnnotatedAsset saves an annotated asset to the query indexes.
+// A newly saved asset belongs to the tenant that ctx acts for.
 func (ind *Indexer) SaveAnnotatedAsset(ctx context.Context, assetID bc.AssetID, asset map[string]interface{}, sortID string) error {
 	b, err := json.Marshal(asset)
 	if err != nil {
@@ -20,10 +22,10 @@ func (ind *Indexer) SaveAnnotatedAsset(ctx context.Context, assetID bc.AssetID,
 	}
 
 	const q = `
-		INSERT INTO annotated_assets (id, data, sort_id) VALUES($1, $2, $3)
+		INSERT INTO annotated_assets (id, data, sort_id, tenant) VALUES($1, $2, $3, $4)
 		ON CONFLICT (id) DO UPDATE SET data = $2, sort_id = $3
 	`
-	_, err = ind.db.Exec(ctx, q, assetID.String(), b, sortID)
+	_, err = ind.db.Exec(ctx, q, assetID.String(), b, sortID, tenant.FromContext(ctx))
 	return errors.Wrap(err, "saving annotated asset")
 }
 
@@ -36,6 +38,7 @@ func (ind *Indexer) Assets(ctx context.Context, p filter.Predicate, vals []inter
 	if err != nil {
 		return nil, "", errors.Wrap(err, "converting to SQL")
 	}
+	expr = restrictToTenant(ctx, expr, tenantRows)
 
 	queryStr, queryArgs := constructAssetsQuery(expr, after, limit)
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
@@ -8,7 +8,9 @@ import (
 
 	"github.com/lib/pq"
 
+	"chain/core/asset"
 	"chain/core/query/filter"
+	"chain/database/pg"
 	"chain/errors"
 )
 
@@ -21,6 +23,8 @@ func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []int
 	if err != nil {
 		return nil, err
 	}
+	expr = restrictToTenant(ctx, expr, tenantOutputs)
+	expr = restrictToRollUp(ctx, expr, rollUpOutputs)
 	queryStr, queryArgs := constructBalancesQuery(expr, sumBy, timestampMS)
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
@@ -28,7 +32,11 @@ func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []int
 	}
 	defer rows.Close()
 
-	var balances []interface{}
+	var (
+		balances []interface{}
+		items    []*balanceItem
+		assetIDs []string // of each item, if summed by asset_id
+	)
 	for rows.Next() {
 		// balance and groupings will hold the output of the row scan
 		var balance uint64
@@ -44,22 +52,66 @@ func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []int
 		}
 
 		sumByValues := map[string]interface{}{}
+		var assetID string
 		for i, f := range sumBy {
-			sumByValues[f.String()] = scanArguments[i+1]
-		}
-		// This struct enforces JSON field ordering in API output.
-		item := struct {
-			SumBy  map[string]interface{} `json:"sum_by,omitempty"`
-			Amount uint64                 `json:"amount"`
-		}{
-			Amount: balance,
+			v := scanArguments[i+1].(**string)
+			sumByValues[f.String()] = v
+			if f.String() == "asset_id" && *v != nil {
+				assetID = **v
+			}
 		}
+		item := &balanceItem{Amount: balance}
 		if len(sumByValues) > 0 {
 			item.SumBy = sumByValues
 		}
 		balances = append(balances, item)
+		items = append(items, item)
+		assetIDs = append(assetIDs, assetID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	err = ind.addDisplayAmounts(ctx, items, assetIDs)
+	return balances, err
+}
+
+// This type enforces JSON field ordering in API output.
+type balanceItem struct {
+	SumBy         map[string]interface{} `json:"sum_by,omitempty"`
+	Amount        uint64                 `json:"amount"`
+	AmountDisplay string                 `json:"amount_display,omitempty"`
+}
+
+// addDisplayAmounts sets the display amount of each item whose
+// asset, given in assetIDs, declares decimals. Balances summed
+// by asset_id are each of a single asset.
+func (ind *Indexer) addDisplayAmounts(ctx context.Context, items []*balanceItem, assetIDs []string) error {
+	var ids pq.StringArray
+	for _, id := range assetIDs {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	const q = `
+		SELECT id, (data->>'decimals')::integer FROM annotated_assets
+		WHERE id IN (SELECT unnest($1::text[])) AND data ? 'decimals'
+	`
+	decimals := make(map[string]int)
+	err := pg.ForQueryRows(ctx, ind.db, q, ids, func(id string, d int) {
+		decimals[id] = d
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading asset decimals")
+	}
+	for i, item := range items {
+		if d, ok := decimals[assetIDs[i]]; ok {
+			item.AmountDisplay = asset.FormatAmount(item.Amount, d)
+		}
 	}
-	return balances, errors.Wrap(rows.Err())
+	return nil
 }
 
 func constructBalancesQuery(expr filter.SQLExpr, sumBy []filter.Field, timestampMS uint64) (string, []interface{}) {
@@ -0,0 +1,351 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"chain/core/query/filter"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// A FlowGraph is the flow of one asset between clusters
+// of accounts over a time window.
+type FlowGraph struct {
+	AssetID string      `json:"asset_id"`
+	Nodes   []*FlowNode `json:"nodes"`
+	Edges   []*FlowEdge `json:"edges"`
+}
+
+// A FlowNode is a cluster of accounts: those for which the
+// cluster field has the same value. The node whose Cluster is nil
+// is everything else: issuances, retirements, and the accounts of
+// other cores, as well as accounts without the field.
+//
+// In and Out are the amounts the cluster received and sent.
+// Within each transaction they are netted, so that change
+// paid back to the cluster doesn't count.
+type FlowNode struct {
+	Cluster           *string         `json:"cluster"`
+	In                uint64          `json:"in"`
+	Out               uint64          `json:"out"`
+	TopCounterparties []*Counterparty `json:"top_counterparties"`
+}
+
+// A Counterparty is a cluster that another cluster received
+// an amount (In) from, or sent an amount (Out) to.
+type Counterparty struct {
+	Cluster *string `json:"cluster"`
+	In      uint64  `json:"in"`
+	Out     uint64  `json:"out"`
+}
+
+// A FlowEdge is the amount one cluster sent another,
+// and the number of transactions that sent it.
+type FlowEdge struct {
+	From         *string `json:"from"`
+	To           *string `json:"to"`
+	Amount       uint64  `json:"amount"`
+	Transactions uint64  `json:"transaction_count"`
+}
+
+// AssetFlows returns, for each asset, or only for assetID if it's
+// not empty, the flow of the asset between clusters of accounts in
+// the transactions of blocks with timestamps from startMS through
+// endMS. The clusters are the values of clusterBy, a field of
+// the transactions' inputs and outputs such as account_id or
+// account_tags.region. Each node lists its top counterparties,
+// by amount in both directions, up to top of them.
+//
+// When a transaction has several senders and receivers of an
+// asset, it doesn't say who paid whom. AssetFlows matches them
+// in order of cluster, which keeps the totals right but may
+// split the transaction's edges differently than its parties
+// would.
+func (ind *Indexer) AssetFlows(ctx context.Context, assetID string, clusterBy filter.Field, startMS, endMS uint64, top int) ([]*FlowGraph, error) {
+	expr := restrictToTenant(ctx, filter.SQLExpr{}, tenantTxs)
+	n := len(expr.Values)
+	where := fmt.Sprintf("block_height IN (SELECT height FROM query_blocks WHERE timestamp BETWEEN $%d AND $%d)", n+1, n+2)
+	if expr.SQL != "" {
+		where = "(" + expr.SQL + ") AND " + where
+	}
+	q := fmt.Sprintf(`
+		SELECT block_height, tx_pos, side, e->>'asset_id', COALESCE((e->>'amount')::bigint, 0), %s
+		FROM (
+			SELECT block_height, tx_pos, 'in' AS side, jsonb_array_elements(data->'inputs') AS e
+			FROM annotated_txs WHERE %s
+			UNION ALL
+			SELECT block_height, tx_pos, 'out', jsonb_array_elements(data->'outputs')
+			FROM annotated_txs WHERE %s
+		) x
+		WHERE $%d = '' OR e->>'asset_id' = $%d
+		ORDER BY block_height, tx_pos
+	`, filter.FieldAsSQL("e", clusterBy), where, where, n+3, n+3)
+	vals := append(expr.Values, startMS, endMS, assetID)
+
+	b := newFlowBuilder()
+	var (
+		tx         []flowEntry
+		prevHeight uint64
+		prevPos    uint32
+	)
+	vals = append(vals, func(height uint64, pos uint32, side, assetID string, amount uint64, c *string) {
+		if len(tx) > 0 && (height != prevHeight || pos != prevPos) {
+			b.addTx(tx)
+			tx = tx[:0]
+		}
+		prevHeight, prevPos = height, pos
+		e := flowEntry{assetID: assetID, amount: amount, spend: side == "in"}
+		if c != nil {
+			e.cluster = cluster{name: *c, ok: true}
+		}
+		tx = append(tx, e)
+	})
+	err := pg.ForQueryRows(ctx, ind.db, q, vals...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying asset flows")
+	}
+	b.addTx(tx)
+	return b.graphs(top), nil
+}
+
+// A cluster identifies a FlowNode. The zero cluster
+// is the node for everything outside the clusters.
+type cluster struct {
+	name string
+	ok   bool
+}
+
+func (c cluster) ptr() *string {
+	if !c.ok {
+		return nil
+	}
+	name := c.name
+	return &name
+}
+
+func (c cluster) less(d cluster) bool {
+	if c.ok != d.ok {
+		return d.ok
+	}
+	return c.name < d.name
+}
+
+// A flowEntry is an input (spend) or output
+// of a transaction, as AssetFlows sees it.
+type flowEntry struct {
+	assetID string
+	amount  uint64
+	spend   bool
+	cluster cluster
+}
+
+type clusterAmount struct {
+	cluster cluster
+	amount  uint64
+}
+
+type byCluster []clusterAmount
+
+func (a byCluster) Len() int           { return len(a) }
+func (a byCluster) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCluster) Less(i, j int) bool { return a[i].cluster.less(a[j].cluster) }
+
+type edgeKey struct {
+	from, to cluster
+}
+
+type assetFlows struct {
+	nodes map[cluster]*FlowNode
+	edges map[edgeKey]*FlowEdge
+}
+
+type flowBuilder struct {
+	assets map[string]*assetFlows
+}
+
+func newFlowBuilder() *flowBuilder {
+	return &flowBuilder{assets: make(map[string]*assetFlows)}
+}
+
+func (b *flowBuilder) flows(assetID string) *assetFlows {
+	f := b.assets[assetID]
+	if f == nil {
+		f = &assetFlows{
+			nodes: make(map[cluster]*FlowNode),
+			edges: make(map[edgeKey]*FlowEdge),
+		}
+		b.assets[assetID] = f
+	}
+	return f
+}
+
+func (f *assetFlows) node(c cluster) *FlowNode {
+	n := f.nodes[c]
+	if n == nil {
+		n = &FlowNode{Cluster: c.ptr()}
+		f.nodes[c] = n
+	}
+	return n
+}
+
+// addTx adds the inputs and outputs of one transaction.
+func (b *flowBuilder) addTx(entries []flowEntry) {
+	// The net amount of each asset each cluster received.
+	// Validation keeps these within int64.
+	deltas := make(map[string]map[cluster]int64)
+	for _, e := range entries {
+		d := deltas[e.assetID]
+		if d == nil {
+			d = make(map[cluster]int64)
+			deltas[e.assetID] = d
+		}
+		if e.spend {
+			d[e.cluster] -= int64(e.amount)
+		} else {
+			d[e.cluster] += int64(e.amount)
+		}
+	}
+
+	for assetID, d := range deltas {
+		var senders, receivers []clusterAmount
+		for c, v := range d {
+			if v < 0 {
+				senders = append(senders, clusterAmount{c, uint64(-v)})
+			} else if v > 0 {
+				receivers = append(receivers, clusterAmount{c, uint64(v)})
+			}
+		}
+		if len(senders) == 0 && len(receivers) == 0 {
+			continue
+		}
+		sort.Sort(byCluster(senders))
+		sort.Sort(byCluster(receivers))
+
+		f := b.flows(assetID)
+		for _, s := range senders {
+			f.node(s.cluster).Out += s.amount
+		}
+		for _, r := range receivers {
+			f.node(r.cluster).In += r.amount
+		}
+		for i, j := 0, 0; i < len(senders) && j < len(receivers); {
+			s, r := &senders[i], &receivers[j]
+			amount := s.amount
+			if r.amount < amount {
+				amount = r.amount
+			}
+			k := edgeKey{s.cluster, r.cluster}
+			e := f.edges[k]
+			if e == nil {
+				e = &FlowEdge{From: s.cluster.ptr(), To: r.cluster.ptr()}
+				f.edges[k] = e
+			}
+			e.Amount += amount
+			e.Transactions++
+			s.amount -= amount
+			r.amount -= amount
+			if s.amount == 0 {
+				i++
+			}
+			if r.amount == 0 {
+				j++
+			}
+		}
+	}
+}
+
+// graphs returns the flow graphs, sorted by asset ID,
+// with each node's top counterparties, up to top of them.
+func (b *flowBuilder) graphs(top int) []*FlowGraph {
+	graphs := []*FlowGraph{}
+	for assetID, f := range b.assets {
+		g := &FlowGraph{AssetID: assetID, Nodes: []*FlowNode{}, Edges: []*FlowEdge{}}
+
+		counterparties := make(map[cluster]map[cluster]*Counterparty)
+		counterparty := func(c, other cluster) *Counterparty {
+			m := counterparties[c]
+			if m == nil {
+				m = make(map[cluster]*Counterparty)
+				counterparties[c] = m
+			}
+			cp := m[other]
+			if cp == nil {
+				cp = &Counterparty{Cluster: other.ptr()}
+				m[other] = cp
+			}
+			return cp
+		}
+		var keys []edgeKey
+		for k, e := range f.edges {
+			counterparty(k.from, k.to).Out += e.Amount
+			counterparty(k.to, k.from).In += e.Amount
+			keys = append(keys, k)
+		}
+		sort.Sort(byEdgeAmount{keys, f.edges})
+		for _, k := range keys {
+			g.Edges = append(g.Edges, f.edges[k])
+		}
+
+		var clusters []clusterAmount
+		for c := range f.nodes {
+			clusters = append(clusters, clusterAmount{cluster: c})
+		}
+		sort.Sort(byCluster(clusters))
+		for _, c := range clusters {
+			n := f.nodes[c.cluster]
+			var cps []clusterAmount
+			for other, cp := range counterparties[c.cluster] {
+				cps = append(cps, clusterAmount{other, cp.In + cp.Out})
+			}
+			sort.Sort(byAmount(cps))
+			n.TopCounterparties = []*Counterparty{}
+			for i := 0; i < len(cps) && i < top; i++ {
+				n.TopCounterparties = append(n.TopCounterparties, counterparties[c.cluster][cps[i].cluster])
+			}
+			g.Nodes = append(g.Nodes, n)
+		}
+		graphs = append(graphs, g)
+	}
+	sort.Sort(byAssetID(graphs))
+	return graphs
+}
+
+// byAmount sorts by amount, largest first, then by cluster.
+type byAmount []clusterAmount
+
+func (a byAmount) Len() int      { return len(a) }
+func (a byAmount) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAmount) Less(i, j int) bool {
+	if a[i].amount != a[j].amount {
+		return a[i].amount > a[j].amount
+	}
+	return a[i].cluster.less(a[j].cluster)
+}
+
+// byEdgeAmount sorts edges by amount, largest first,
+// then by their clusters.
+type byEdgeAmount struct {
+	keys  []edgeKey
+	edges map[edgeKey]*FlowEdge
+}
+
+func (a byEdgeAmount) Len() int      { return len(a.keys) }
+func (a byEdgeAmount) Swap(i, j int) { a.keys[i], a.keys[j] = a.keys[j], a.keys[i] }
+func (a byEdgeAmount) Less(i, j int) bool {
+	ki, kj := a.keys[i], a.keys[j]
+	if ai, aj := a.edges[ki].Amount, a.edges[kj].Amount; ai != aj {
+		return ai > aj
+	}
+	if ki.from != kj.from {
+		return ki.from.less(kj.from)
+	}
+	return ki.to.less(kj.to)
+}
+
+type byAssetID []*FlowGraph
+
+func (a byAssetID) Len() int           { return len(a) }
+func (a byAssetID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAssetID) Less(i, j int) bool { return a[i].AssetID < a[j].AssetID }
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFlowBuilder(t *testing.T) {
+	c := func(name string) cluster { return cluster{name: name, ok: true} }
+	b := newFlowBuilder()
+
+	// Issue 100 to east.
+	b.addTx([]flowEntry{
+		{assetID: "a", amount: 100, spend: true},
+		{assetID: "a", amount: 100, cluster: c("east")},
+	})
+	// East pays west 30 and north 10, with change of 60.
+	b.addTx([]flowEntry{
+		{assetID: "a", amount: 100, spend: true, cluster: c("east")},
+		{assetID: "a", amount: 30, cluster: c("west")},
+		{assetID: "a", amount: 10, cluster: c("north")},
+		{assetID: "a", amount: 60, cluster: c("east")},
+	})
+	// East pays west 5 more; west retires 20.
+	b.addTx([]flowEntry{
+		{assetID: "a", amount: 60, spend: true, cluster: c("east")},
+		{assetID: "a", amount: 5, cluster: c("west")},
+		{assetID: "a", amount: 55, cluster: c("east")},
+	})
+	b.addTx([]flowEntry{
+		{assetID: "a", amount: 30, spend: true, cluster: c("west")},
+		{assetID: "a", amount: 20},
+		{assetID: "a", amount: 10, cluster: c("west")},
+	})
+
+	got, err := json.Marshal(b.graphs(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"asset_id":"a",` +
+		`"nodes":[` +
+		`{"cluster":null,"in":20,"out":100,"top_counterparties":[{"cluster":"east","in":0,"out":100}]},` +
+		`{"cluster":"east","in":100,"out":45,"top_counterparties":[{"cluster":null,"in":100,"out":0}]},` +
+		`{"cluster":"north","in":10,"out":0,"top_counterparties":[{"cluster":"east","in":10,"out":0}]},` +
+		`{"cluster":"west","in":35,"out":20,"top_counterparties":[{"cluster":"east","in":35,"out":0}]}],` +
+		`"edges":[` +
+		`{"from":null,"to":"east","amount":100,"transaction_count":1},` +
+		`{"from":"east","to":"west","amount":35,"transaction_count":2},` +
+		`{"from":"west","to":null,"amount":20,"transaction_count":1},` +
+		`{"from":"east","to":"north","amount":10,"transaction_count":1}]}]`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/lib/pq"
 
 	"chain/core/asset"
+	"chain/core/pin"
 	"chain/database/pg"
 	"chain/errors"
+	"chain/log"
 	"chain/protocol/bc"
 )
 
@@ -36,11 +39,31 @@ func (ind *Indexer) ProcessBlocks(ctx context.Context) {
 	ind.pinStore.ProcessBlocks(ctx, ind.c, TxPinName, ind.IndexTransactions)
 }
 
+// Reindex deletes the annotated transactions and outputs and
+// the balance snapshots in db and rewinds their pins, so that
+// the core annotates the whole blockchain again when it starts.
+// It must only be called while the core is not running.
+func Reindex(ctx context.Context, db pg.DB) error {
+	for _, table := range []string{"annotated_txs", "annotated_outputs", "balance_snapshots"} {
+		_, err := db.Exec(ctx, `DELETE FROM `+table)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	err := pin.Rewind(ctx, db, TxPinName, 0)
+	if err != nil {
+		return err
+	}
+	return pin.Rewind(ctx, db, BalanceSnapshotPinName, 0)
+}
+
 // IndexTransactions is registered as a block callback on the Chain. It
 // saves all annotated transactions to the database.
 func (ind *Indexer) IndexTransactions(ctx context.Context, b *bc.Block) error {
+	ctx = log.AddFields(ctx, log.KeyBlockHeight, b.Height)
 	<-ind.pinStore.PinWaiter(asset.PinName, b.Height)
 
+	start := time.Now()
 	err := ind.insertBlock(ctx, b)
 	if err != nil {
 		return err
@@ -51,6 +74,31 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *bc.Block) error {
 		return err
 	}
 
+	err = ind.insertAnnotatedOutputs(ctx, b, txs)
+	if err != nil {
+		return err
+	}
+	log.Debug(ctx, log.SubsystemIndexer, "txs", len(txs), "duration", time.Since(start))
+	return nil
+}
+
+// ReindexBlock replaces the annotated transactions and outputs
+// of block b, to pick up annotations that have changed since
+// it was indexed, such as control programs imported into an
+// account. Blocks must be reindexed in order.
+func (ind *Indexer) ReindexBlock(ctx context.Context, b *bc.Block) error {
+	_, err := ind.db.Exec(ctx, `DELETE FROM annotated_txs WHERE block_height=$1`, b.Height)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	_, err = ind.db.Exec(ctx, `DELETE FROM annotated_outputs WHERE block_height=$1`, b.Height)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	txs, err := ind.insertAnnotatedTxs(ctx, b)
+	if err != nil {
+		return err
+	}
 	return ind.insertAnnotatedOutputs(ctx, b, txs)
 }
 
@@ -63,6 +111,21 @@ func (ind *Indexer) insertBlock(ctx context.Context, b *bc.Block) error {
 	return errors.Wrap(err, "inserting block timestamp")
 }
 
+// AnnotateUnconfirmed returns the annotated transaction object of
+// tx, which isn't yet in a block, adding the annotations of the
+// registered annotators. The object has no block fields.
+func (ind *Indexer) AnnotateUnconfirmed(ctx context.Context, tx *bc.Tx) (map[string]interface{}, error) {
+	txs := []map[string]interface{}{transactionObject(tx, nil, 0)}
+	for _, annotator := range ind.annotators {
+		err := annotator(ctx, txs)
+		if err != nil {
+			return nil, errors.Wrap(err, "adding external annotations")
+		}
+	}
+	localAnnotator(ctx, txs)
+	return txs[0], nil
+}
+
 func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *bc.Block) ([]map[string]interface{}, error) {
 	var (
 		hashes              = pq.StringArray(make([]string, 0, len(b.Transactions)))
@@ -93,12 +156,16 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *bc.Block) ([]map[
 	}
 
 	// Save the annotated txs to the database.
-	const insertQ = `
-		INSERT INTO annotated_txs(block_height, tx_pos, tx_hash, data)
+	table, err := ind.txs.Table(ctx, ind.db, b.Height)
+	if err != nil {
+		return nil, err
+	}
+	insertQ := `
+		INSERT INTO ` + table + `(block_height, tx_pos, tx_hash, data)
 		SELECT $1, unnest($2::integer[]), unnest($3::text[]), unnest($4::jsonb[])
 		ON CONFLICT (block_height, tx_pos) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, b.Height, positions, hashes, annotatedTxs)
+	_, err = ind.db.Exec(ctx, insertQ, b.Height, positions, hashes, annotatedTxs)
 	if err != nil {
 		return nil, errors.Wrap(err, "inserting annotated_txs to db")
 	}
@@ -113,13 +180,17 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 		outputData        pq.StringArray
 		prevoutHashes     pq.StringArray
 		prevoutIndexes    pg.Uint32s
+		spenderHashes     pq.StringArray
+		spenderInputs     pg.Uint32s
 	)
 
 	for pos, tx := range b.Transactions {
-		for _, in := range tx.Inputs {
+		for inIndex, in := range tx.Inputs {
 			if !in.IsIssuance() {
 				prevoutHashes = append(prevoutHashes, in.Outpoint().Hash.String())
 				prevoutIndexes = append(prevoutIndexes, in.Outpoint().Index)
+				spenderHashes = append(spenderHashes, tx.Hash.String())
+				spenderInputs = append(spenderInputs, uint32(inIndex))
 			}
 		}
 
@@ -158,22 +229,34 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 	}
 
 	// Insert all of the block's outputs at once.
-	const insertQ = `
-		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash, data, timespan)
+	table, err := ind.outputs.Table(ctx, ind.db, b.Height)
+	if err != nil {
+		return err
+	}
+	insertQ := `
+		INSERT INTO ` + table + ` (block_height, tx_pos, output_index, tx_hash, data, timespan)
 		SELECT $1, unnest($2::integer[]), unnest($3::integer[]), unnest($4::text[]),
 		           unnest($5::jsonb[]),   int8range($6, NULL)
 		ON CONFLICT (block_height, tx_pos, output_index) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, b.Height, outputTxPositions,
+	_, err = ind.db.Exec(ctx, insertQ, b.Height, outputTxPositions,
 		outputIndexes, outputTxHashes, outputData, b.TimestampMS)
 	if err != nil {
 		return errors.Wrap(err, "batch inserting annotated outputs")
 	}
 
+	// Mark the outputs spent by the block's inputs,
+	// recording the inputs that spent them.
 	const updateQ = `
-		UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), $1)
-		WHERE (tx_hash, output_index) IN (SELECT unnest($2::text[]), unnest($3::integer[]))
+		UPDATE annotated_outputs o SET timespan = INT8RANGE(LOWER(o.timespan), $1),
+			spent_by_tx_hash = s.spender, spent_by_input = s.input, spent_at_height = $2
+		FROM (
+			SELECT unnest($3::text[]) AS tx_hash, unnest($4::integer[]) AS output_index,
+				unnest($5::text[]) AS spender, unnest($6::integer[]) AS input
+		) s
+		WHERE (o.tx_hash, o.output_index) = (s.tx_hash, s.output_index)
 	`
-	_, err = ind.db.Exec(ctx, updateQ, b.TimestampMS, prevoutHashes, prevoutIndexes)
+	_, err = ind.db.Exec(ctx, updateQ, b.TimestampMS, b.Height,
+		prevoutHashes, prevoutIndexes, spenderHashes, spenderInputs)
 	return errors.Wrap(err, "updating spent annotated outputs")
 }
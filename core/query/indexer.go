@@ -12,6 +12,8 @@ func NewIndexer(db pg.DB, c *protocol.Chain, pinStore *pin.Store) *Indexer {
 		db:       db,
 		c:        c,
 		pinStore: pinStore,
+		txs:      &pg.Partitions{Parent: "annotated_txs", Column: "block_height"},
+		outputs:  &pg.Partitions{Parent: "annotated_outputs", Column: "block_height"},
 	}
 	return indexer
 }
@@ -22,4 +24,25 @@ type Indexer struct {
 	c          *protocol.Chain
 	pinStore   *pin.Store
 	annotators []Annotator
+	txs        *pg.Partitions
+	outputs    *pg.Partitions
+	retention  Retention
+}
+
+// Partition stores the annotated transactions and outputs of new
+// blocks in partitions of size blocks each, starting at the first
+// partition boundary above height, which must be no lower than the
+// height of the last indexed block. It must be called before the
+// Indexer is used. See pg.Partitions.
+func (ind *Indexer) Partition(size, height uint64) {
+	ind.txs.Size = size
+	ind.txs.StartAbove(height)
+	ind.outputs.Size = size
+	ind.outputs.StartAbove(height)
+}
+
+// Retain sets how long the indexer keeps index entries; see
+// Retention. It must be called before the Indexer is used.
+func (ind *Indexer) Retain(r Retention) {
+	ind.retention = r
 }
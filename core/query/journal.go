@@ -0,0 +1,193 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"chain/core/query/filter"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// Ledger accounts for the sides of transactions
+// that aren't accounts of this core.
+const (
+	LedgerIssuance   = "issuance"
+	LedgerRetirement = "retirement"
+	LedgerExternal   = "external"
+)
+
+// A JournalLine is a debit or credit of an asset to a ledger
+// account, one line of the double-entry journal entry for a
+// transaction. The ledger account of an account of this core is
+// its alias, or its ID if it has none. Other parties to the
+// transaction are LedgerIssuance, LedgerRetirement, or
+// LedgerExternal.
+//
+// The lines of each journal entry balance: for each asset, the
+// debits equal the credits.
+type JournalLine struct {
+	JournalID     string // the transaction ID
+	Timestamp     time.Time
+	Line          int // 1-based, within the journal entry
+	LedgerAccount string
+	AccountID     string // empty for other parties
+	AssetID       string
+	AssetAlias    string
+	Debit         uint64
+	Credit        uint64
+
+	// Memo is the transaction's reference data and LineMemo
+	// the reference data of the inputs and outputs of the line,
+	// as JSON. Empty reference data gives an empty memo.
+	Memo     string
+	LineMemo string
+}
+
+type journalTx struct {
+	ID            string          `json:"id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	ReferenceData json.RawMessage `json:"reference_data"`
+	Inputs        []journalEntry  `json:"inputs"`
+	Outputs       []journalEntry  `json:"outputs"`
+}
+
+type journalEntry struct {
+	Type          string          `json:"type"`
+	AssetID       string          `json:"asset_id"`
+	AssetAlias    string          `json:"asset_alias"`
+	Amount        uint64          `json:"amount"`
+	AccountID     string          `json:"account_id"`
+	AccountAlias  string          `json:"account_alias"`
+	ReferenceData json.RawMessage `json:"reference_data"`
+}
+
+// Journal calls fn with the journal lines of the transactions in
+// blocks with timestamps from startMS through endMS, in order,
+// or only of those that spend from or pay to accountID, if it's
+// not empty. It stops at the first error from fn.
+func (ind *Indexer) Journal(ctx context.Context, accountID string, startMS, endMS uint64, fn func(*JournalLine) error) error {
+	expr := restrictToTenant(ctx, filter.SQLExpr{}, tenantTxs)
+	n := len(expr.Values)
+	where := fmt.Sprintf(`block_height IN (SELECT height FROM query_blocks WHERE timestamp BETWEEN $%d AND $%d)
+		AND ($%d='' OR
+			data @> jsonb_build_object('inputs', jsonb_build_array(jsonb_build_object('account_id', $%d::text))) OR
+			data @> jsonb_build_object('outputs', jsonb_build_array(jsonb_build_object('account_id', $%d::text))))`,
+		n+1, n+2, n+3, n+3, n+3)
+	if expr.SQL != "" {
+		where = "(" + expr.SQL + ") AND " + where
+	}
+	q := "SELECT data FROM annotated_txs WHERE " + where + " ORDER BY block_height, tx_pos"
+	vals := append(expr.Values, startMS, endMS, accountID)
+
+	var fnErr error
+	vals = append(vals, func(data []byte) {
+		if fnErr != nil {
+			return
+		}
+		var tx journalTx
+		fnErr = json.Unmarshal(data, &tx)
+		if fnErr != nil {
+			fnErr = errors.Wrap(fnErr, "decoding annotated transaction")
+			return
+		}
+		for _, l := range journalLines(&tx) {
+			fnErr = fn(l)
+			if fnErr != nil {
+				return
+			}
+		}
+	})
+	err := pg.ForQueryRows(ctx, ind.db, q, vals...)
+	if fnErr != nil {
+		return fnErr
+	}
+	return errors.Wrap(err, "querying journal")
+}
+
+// journalLines returns the journal lines of tx. It nets the
+// inputs and outputs of each ledger account and asset, so that
+// change paid back to an account doesn't appear.
+func journalLines(tx *journalTx) []*JournalLine {
+	type key struct {
+		ledger, accountID, assetID string
+	}
+	var (
+		order []key
+		net   = make(map[key]int64) // validation keeps these within int64
+		lines = make(map[key]*JournalLine)
+		memos = make(map[key][]string)
+	)
+	add := func(e journalEntry, ledger string, sign int64) {
+		k := key{ledger, e.AccountID, e.AssetID}
+		if lines[k] == nil {
+			order = append(order, k)
+			lines[k] = &JournalLine{
+				JournalID:     tx.ID,
+				Timestamp:     tx.Timestamp,
+				LedgerAccount: ledger,
+				AccountID:     e.AccountID,
+				AssetID:       e.AssetID,
+				AssetAlias:    e.AssetAlias,
+				Memo:          memo(tx.ReferenceData),
+			}
+		}
+		net[k] += sign * int64(e.Amount)
+		if m := memo(e.ReferenceData); m != "" {
+			memos[k] = append(memos[k], m)
+		}
+	}
+	for _, in := range tx.Inputs {
+		add(in, ledgerAccount(in, LedgerIssuance), -1)
+	}
+	for _, out := range tx.Outputs {
+		add(out, ledgerAccount(out, LedgerRetirement), 1)
+	}
+
+	var result []*JournalLine
+	for _, k := range order {
+		l := lines[k]
+		switch n := net[k]; {
+		case n > 0:
+			l.Debit = uint64(n)
+		case n < 0:
+			l.Credit = uint64(-n)
+		default:
+			continue
+		}
+		l.Line = len(result) + 1
+		l.LineMemo = strings.Join(memos[k], "; ")
+		result = append(result, l)
+	}
+	return result
+}
+
+// ledgerAccount returns the ledger account of e.
+// If e is not of an account of this core, it is
+// nonlocal for an issuance or retirement,
+// or LedgerExternal.
+func ledgerAccount(e journalEntry, nonlocal string) string {
+	switch {
+	case e.AccountAlias != "":
+		return e.AccountAlias
+	case e.AccountID != "":
+		return e.AccountID
+	case e.Type == "issue" || e.Type == "retire":
+		return nonlocal
+	}
+	return LedgerExternal
+}
+
+// memo returns reference data as compact JSON,
+// or "" if it is empty.
+func memo(refdata json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, refdata) != nil || buf.String() == "{}" || buf.String() == "null" {
+		return ""
+	}
+	return buf.String()
+}
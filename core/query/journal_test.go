@@ -0,0 +1,62 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJournalLines(t *testing.T) {
+	const data = `{
+		"id": "tx1",
+		"timestamp": "2016-12-22T10:00:00Z",
+		"reference_data": {"invoice": 7},
+		"inputs": [
+			{"type": "spend", "asset_id": "a1", "asset_alias": "usd", "amount": 100, "account_id": "acc1", "account_alias": "alice", "reference_data": {}},
+			{"type": "issue", "asset_id": "a2", "amount": 5, "reference_data": {}}
+		],
+		"outputs": [
+			{"type": "control", "asset_id": "a1", "asset_alias": "usd", "amount": 30, "account_id": "acc2", "reference_data": {"note": "rent"}},
+			{"type": "control", "asset_id": "a1", "asset_alias": "usd", "amount": 70, "account_id": "acc1", "account_alias": "alice", "reference_data": {}},
+			{"type": "retire", "asset_id": "a2", "amount": 2, "reference_data": {}},
+			{"type": "control", "asset_id": "a2", "amount": 3, "reference_data": {}}
+		]
+	}`
+	var tx journalTx
+	err := json.Unmarshal([]byte(data), &tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2016, 12, 22, 10, 0, 0, 0, time.UTC)
+	line := func(n int, ledger, accountID, assetID, alias string, debit, credit uint64, lineMemo string) *JournalLine {
+		return &JournalLine{
+			JournalID:     "tx1",
+			Timestamp:     ts,
+			Line:          n,
+			LedgerAccount: ledger,
+			AccountID:     accountID,
+			AssetID:       assetID,
+			AssetAlias:    alias,
+			Debit:         debit,
+			Credit:        credit,
+			Memo:          `{"invoice":7}`,
+			LineMemo:      lineMemo,
+		}
+	}
+	want := []*JournalLine{
+		line(1, "alice", "acc1", "a1", "usd", 0, 30, ""),
+		line(2, LedgerIssuance, "", "a2", "", 0, 5, ""),
+		line(3, "acc2", "acc2", "a1", "usd", 30, 0, `{"note":"rent"}`),
+		line(4, LedgerRetirement, "", "a2", "", 2, 0, ""),
+		line(5, LedgerExternal, "", "a2", "", 3, 0, ""),
+	}
+	got := journalLines(&tx)
+	if !reflect.DeepEqual(got, want) {
+		for _, l := range got {
+			t.Logf("got %+v", l)
+		}
+		t.Error("journalLines gave wrong lines")
+	}
+}
@@ -56,6 +56,8 @@ func (ind *Indexer) Outputs(ctx context.Context, p filter.Predicate, vals []inte
 	if err != nil {
 		return nil, nil, err
 	}
+	expr = restrictToTenant(ctx, expr, tenantOutputs)
+	expr = restrictToRollUp(ctx, expr, rollUpOutputs)
 	queryStr, queryArgs := constructOutputsQuery(expr, timestampMS, after, limit)
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
@@ -0,0 +1,172 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/errors"
+	"chain/log"
+)
+
+// pruneBatchSize is the number of index
+// entries deleted at a time.
+const pruneBatchSize = 10000
+
+// Retention says how long the indexer keeps entries in the
+// query index tables, by the timestamp of their blocks. A zero
+// duration keeps entries forever. Retention applies only to the
+// index, never to the blockchain itself.
+//
+// Unspent outputs are always kept. Spent outputs and transactions
+// are only pruned once they are covered by the daily balance
+// snapshots, which remain as their rollup.
+type Retention struct {
+	Transactions time.Duration
+	SpentOutputs time.Duration
+}
+
+// PruneIndex deletes index entries older than the indexer's
+// retention allows, every period, until ctx is canceled.
+func (ind *Indexer) PruneIndex(ctx context.Context, period time.Duration) {
+	r := ind.retention
+	if r.Transactions == 0 && r.SpentOutputs == 0 {
+		return
+	}
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, PruneIndex exiting")
+			return
+		case <-ticks:
+			err := ind.prune(ctx, r, time.Now())
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+func (ind *Indexer) prune(ctx context.Context, r Retention, now time.Time) error {
+	txCutoff, outputCutoff, err := ind.cutoffs(ctx, r, now)
+	if err != nil {
+		return err
+	}
+
+	if txCutoff > 0 {
+		// Whole partitions of old transactions are dropped,
+		// rather than deleted row by row.
+		var height uint64
+		err = ind.db.QueryRow(ctx, `SELECT COALESCE(MAX(height), 0) FROM query_blocks WHERE timestamp < $1`, txCutoff).Scan(&height)
+		if err != nil {
+			return errors.Wrap(err, "getting pruning height")
+		}
+		_, err = ind.txs.DropBefore(ctx, ind.db, height+1)
+		if err != nil {
+			return err
+		}
+
+		const q = `
+			DELETE FROM annotated_txs WHERE (block_height, tx_pos) IN (
+				SELECT block_height, tx_pos FROM annotated_txs
+				WHERE block_height <= (SELECT MAX(height) FROM query_blocks WHERE timestamp < $1)
+				LIMIT $2
+			)
+		`
+		err = ind.pruneBatches(ctx, q, txCutoff)
+		if err != nil {
+			return errors.Wrap(err, "pruning annotated transactions")
+		}
+	}
+	if outputCutoff > 0 {
+		const q = `
+			DELETE FROM annotated_outputs WHERE (block_height, tx_pos, output_index) IN (
+				SELECT block_height, tx_pos, output_index FROM annotated_outputs
+				WHERE timespan << int8range($1, NULL)
+				LIMIT $2
+			)
+		`
+		err = ind.pruneBatches(ctx, q, outputCutoff)
+		if err != nil {
+			return errors.Wrap(err, "pruning spent outputs")
+		}
+	}
+	return nil
+}
+
+// cutoffs returns the block timestamps, in milliseconds, before
+// which r lets the entries of transactions and of spent outputs be
+// pruned at time now, or 0 for entries r keeps.
+func (ind *Indexer) cutoffs(ctx context.Context, r Retention, now time.Time) (txs, outputs uint64, err error) {
+	if r.Transactions == 0 && r.SpentOutputs == 0 {
+		return 0, 0, nil
+	}
+
+	// Balance snapshots are complete for the days
+	// before that of the last block they've processed.
+	var snapshotMS uint64
+	const snapshotQ = `SELECT timestamp FROM query_blocks WHERE height=$1`
+	err = ind.db.QueryRow(ctx, snapshotQ, ind.pinStore.Height(BalanceSnapshotPinName)).Scan(&snapshotMS)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, errors.Wrap(err, "getting balance snapshot time")
+	}
+	snapshotMS -= snapshotMS % msPerDay
+
+	cutoff := func(d time.Duration) uint64 {
+		if d == 0 {
+			return 0
+		}
+		ms := uint64(now.Add(-d).UnixNano() / int64(time.Millisecond))
+		if ms > snapshotMS {
+			ms = snapshotMS
+		}
+		return ms
+	}
+	return cutoff(r.Transactions), cutoff(r.SpentOutputs), nil
+}
+
+// ReindexRange returns the heights of the first and last blocks
+// whose entries ReindexBlock may replace. The last is the last
+// block the indexer has processed. The first follows the blocks
+// old enough for the indexer's retention to prune, whose pruned
+// entries reindexing would restore.
+func (ind *Indexer) ReindexRange(ctx context.Context) (first, last uint64, err error) {
+	txCutoff, outputCutoff, err := ind.cutoffs(ctx, ind.retention, time.Now())
+	if err != nil {
+		return 0, 0, err
+	}
+	cutoff := txCutoff
+	if outputCutoff > cutoff {
+		cutoff = outputCutoff
+	}
+	var height uint64
+	if cutoff > 0 {
+		err = ind.db.QueryRow(ctx, `SELECT COALESCE(MAX(height), 0) FROM query_blocks WHERE timestamp < $1`, cutoff).Scan(&height)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "getting pruning height")
+		}
+	}
+	return height + 1, ind.pinStore.Height(TxPinName), nil
+}
+
+// pruneBatches runs the deletion q, which takes the cutoff
+// time as $1 and deletes at most $2 rows, until it deletes
+// fewer than a full batch.
+func (ind *Indexer) pruneBatches(ctx context.Context, q string, cutoffMS uint64) error {
+	for {
+		res, err := ind.db.Exec(ctx, q, cutoffMS, pruneBatchSize)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if n < pruneBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package query
+
+import (
+	"context"
+
+	"chain/core/query/filter"
+)
+
+// accountTree selects the IDs of an account and its descendants,
+// following the parent_id of annotated accounts. UNION, unlike
+// UNION ALL, ends the recursion even if the parents form a cycle.
+const accountTree = `WITH RECURSIVE tree(id) AS (
+		SELECT $%d::text
+		UNION
+		SELECT a.id FROM annotated_accounts a JOIN tree ON a.data->>'parent_id'=tree.id
+	) SELECT id FROM tree`
+
+const (
+	// rollUpOutputs restricts annotated_outputs to the outputs
+	// controlled by an account and its descendants.
+	rollUpOutputs = "data->>'account_id' IN (" + accountTree + ")"
+
+	// rollUpTxs restricts annotated_txs to the transactions that
+	// spend from or pay to an account and its descendants.
+	rollUpTxs = `EXISTS (SELECT 1 FROM (` + accountTree + `) t WHERE
+		data @> jsonb_build_object('inputs', jsonb_build_array(jsonb_build_object('account_id', t.id))) OR
+		data @> jsonb_build_object('outputs', jsonb_build_array(jsonb_build_object('account_id', t.id))))`
+)
+
+type rollUpKey int
+
+// NewRollUpContext returns a context for queries that roll up
+// account accountID: balances, outputs, and transactions queries
+// made with it include only those of accountID and its
+// descendants, as set by account.Manager.SetParent.
+func NewRollUpContext(ctx context.Context, accountID string) context.Context {
+	return context.WithValue(ctx, rollUpKey(0), accountID)
+}
+
+// restrictToRollUp returns expr with cond ANDed in, if ctx rolls
+// up an account. Cond must contain a single %d verb, which is
+// replaced with the index of the account ID parameter.
+func restrictToRollUp(ctx context.Context, expr filter.SQLExpr, cond string) filter.SQLExpr {
+	id, _ := ctx.Value(rollUpKey(0)).(string)
+	if id == "" {
+		return expr
+	}
+	return restrict(expr, cond, id)
+}
@@ -0,0 +1,32 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"chain/core/query/filter"
+	"chain/core/tenant"
+)
+
+func TestRestrictToRollUp(t *testing.T) {
+	expr := filter.SQLExpr{SQL: "data->>'asset_id' = $1", Values: []interface{}{"a"}}
+
+	got := restrictToRollUp(context.Background(), expr, rollUpOutputs)
+	if !reflect.DeepEqual(got, expr) {
+		t.Errorf("no roll-up: got %#v, want %#v", got, expr)
+	}
+
+	// The tenant and roll-up restrictions compose.
+	ctx := tenant.NewContext(context.Background(), "t1")
+	ctx = NewRollUpContext(ctx, "acc1")
+	got = restrictToRollUp(ctx, restrictToTenant(ctx, expr, tenantOutputs), rollUpOutputs)
+	want := filter.SQLExpr{
+		SQL:    fmt.Sprintf("((data->>'asset_id' = $1) AND %s) AND %s", fmt.Sprintf(tenantOutputs, 2), fmt.Sprintf(rollUpOutputs, 3)),
+		Values: []interface{}{"a", "t1", "acc1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"chain/core/tenant"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// BalanceSnapshotPinName is used to identify the pin associated
+// with the balance snapshot block processor.
+const BalanceSnapshotPinName = "balance-snapshot"
+
+const msPerDay = uint64(24 * time.Hour / time.Millisecond)
+
+// A BalanceSnapshot is the balance of an asset in an account
+// at the end of a day, in UTC, by block timestamps.
+type BalanceSnapshot struct {
+	Date      string `json:"date"` // YYYY-MM-DD
+	AccountID string `json:"account_id"`
+	AssetID   string `json:"asset_id"`
+	Amount    uint64 `json:"amount"`
+}
+
+// BalanceSnapshotsAfter is a cursor into a list of balance snapshots.
+type BalanceSnapshotsAfter struct {
+	date      string
+	accountID string
+	assetID   string
+}
+
+func (cur BalanceSnapshotsAfter) String() string {
+	return fmt.Sprintf("%s:%s:%s", cur.date, cur.accountID, cur.assetID)
+}
+
+// DecodeBalanceSnapshotsAfter decodes a cursor
+// produced by BalanceSnapshotsAfter.String.
+func DecodeBalanceSnapshotsAfter(str string) (*BalanceSnapshotsAfter, error) {
+	parts := strings.SplitN(str, ":", 3)
+	if len(parts) != 3 {
+		return nil, errors.Wrap(ErrBadAfter)
+	}
+	_, err := time.Parse("2006-01-02", parts[0])
+	if err != nil {
+		return nil, errors.Wrap(ErrBadAfter, err.Error())
+	}
+	return &BalanceSnapshotsAfter{date: parts[0], accountID: parts[1], assetID: parts[2]}, nil
+}
+
+// ProcessBalanceSnapshots writes the balance of every asset in every
+// account at the end of each day, once the indexer has indexed the
+// blocks of that day. It must only be run when the indexer is also
+// processing blocks.
+func (ind *Indexer) ProcessBalanceSnapshots(ctx context.Context) {
+	if ind.pinStore == nil {
+		return
+	}
+	ind.pinStore.ProcessBlocks(ctx, ind.c, BalanceSnapshotPinName, ind.snapshotBalances)
+}
+
+// snapshotBalances writes balance snapshots for the days that
+// end between the previous block and b. It relies on the
+// timestamp of the previous block recorded by the indexer,
+// and writes nothing for a block the indexer didn't index.
+func (ind *Indexer) snapshotBalances(ctx context.Context, b *bc.Block) error {
+	if b.Height <= 1 {
+		return nil
+	}
+	<-ind.pinStore.PinWaiter(TxPinName, b.Height-1)
+
+	var prevTimestampMS uint64
+	err := ind.db.QueryRow(ctx, `SELECT timestamp FROM query_blocks WHERE height=$1`, b.Height-1).Scan(&prevTimestampMS)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "getting previous block timestamp")
+	}
+
+	const q = `
+		INSERT INTO balance_snapshots (date, account_id, asset_id, amount)
+		SELECT $1::date, data->>'account_id', data->>'asset_id', SUM((data->>'amount')::bigint)
+			FROM annotated_outputs
+			WHERE timespan @> $2::int8 AND data->>'account_id' IS NOT NULL
+			GROUP BY 2, 3
+		ON CONFLICT (date, account_id, asset_id) DO NOTHING
+	`
+	for day := prevTimestampMS - prevTimestampMS%msPerDay; day+msPerDay <= b.TimestampMS; day += msPerDay {
+		date := time.Unix(int64(day/1000), 0).UTC().Format("2006-01-02")
+		_, err := ind.db.Exec(ctx, q, date, day+msPerDay-1)
+		if err != nil {
+			return errors.Wrap(err, "writing balance snapshots for "+date)
+		}
+	}
+	return nil
+}
+
+// BalanceSnapshots returns up to limit balance snapshots for dates
+// from start through end, inclusive, ordered by date, account ID, and
+// asset ID. Empty accountID and assetID match any account or asset.
+// Only snapshots of accounts belonging to the tenant that ctx acts
+// for are returned.
+func (ind *Indexer) BalanceSnapshots(ctx context.Context, accountID, assetID string, start, end time.Time, after *BalanceSnapshotsAfter, limit int) ([]*BalanceSnapshot, *BalanceSnapshotsAfter, error) {
+	if after == nil {
+		after = &BalanceSnapshotsAfter{date: "0001-01-01"}
+	}
+	const q = `
+		SELECT date, account_id, asset_id, amount FROM balance_snapshots
+		WHERE ($1='' OR account_id IN (SELECT id FROM annotated_accounts WHERE tenant=$1))
+			AND ($2='' OR account_id=$2) AND ($3='' OR asset_id=$3)
+			AND date BETWEEN $4::date AND $5::date
+			AND (date, account_id, asset_id) > ($6::date, $7, $8)
+		ORDER BY date, account_id, asset_id
+		LIMIT $9
+	`
+	var snapshots []*BalanceSnapshot
+	err := pg.ForQueryRows(ctx, ind.db, q, tenant.FromContext(ctx), accountID, assetID,
+		start.UTC().Format("2006-01-02"), end.UTC().Format("2006-01-02"),
+		after.date, after.accountID, after.assetID, limit,
+		func(date time.Time, accountID, assetID string, amount uint64) {
+			snapshots = append(snapshots, &BalanceSnapshot{
+				Date:      date.Format("2006-01-02"),
+				AccountID: accountID,
+				AssetID:   assetID,
+				Amount:    amount,
+			})
+		})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "listing balance snapshots")
+	}
+	if len(snapshots) > 0 {
+		last := snapshots[len(snapshots)-1]
+		after = &BalanceSnapshotsAfter{date: last.Date, accountID: last.AccountID, assetID: last.AssetID}
+	}
+	return snapshots, after, nil
+}
@@ -0,0 +1,85 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/core/pin"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestDecodeBalanceSnapshotsAfter(t *testing.T) {
+	testCases := []struct {
+		str string
+		cur *BalanceSnapshotsAfter
+	}{
+		{str: "2016-12-01:acc1:a1", cur: &BalanceSnapshotsAfter{date: "2016-12-01", accountID: "acc1", assetID: "a1"}},
+		{str: "0001-01-01::", cur: &BalanceSnapshotsAfter{date: "0001-01-01"}},
+		{str: "2016-12-01:acc1", cur: nil},
+		{str: "12/01/2016:acc1:a1", cur: nil},
+	}
+
+	for _, tc := range testCases {
+		decoded, err := DecodeBalanceSnapshotsAfter(tc.str)
+		if tc.cur == nil {
+			if err == nil {
+				t.Errorf("DecodeBalanceSnapshotsAfter(%q) = %#v, want error", tc.str, decoded)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, tc.cur) {
+			t.Errorf("got %#v, want %#v", decoded, tc.cur)
+		}
+		if decoded.String() != tc.str {
+			t.Errorf("re-encode: got %s, want %s", decoded.String(), tc.str)
+		}
+	}
+}
+
+func TestSnapshotBalances(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	pinStore := pin.NewStore(db)
+	err := pinStore.CreatePin(ctx, TxPinName, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	ind := NewIndexer(db, nil, pinStore)
+
+	day1 := uint64(time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond))
+	pgtest.Exec(ctx, db, t, `INSERT INTO query_blocks (height, timestamp) VALUES (1, $1)`, day1+3600000)
+	const q = `
+		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash, data, timespan) VALUES
+			(1, 0, 0, 'a', '{"account_id": "acc1", "asset_id": "a1", "amount": 5}', int8range($1, NULL)),
+			(1, 0, 1, 'a', '{"account_id": "acc1", "asset_id": "a1", "amount": 7}', int8range($1, $1+1000)),
+			(1, 0, 2, 'a', '{"asset_id": "a1", "amount": 11}', int8range($1, NULL))
+	`
+	pgtest.Exec(ctx, db, t, q, day1+3600000)
+
+	// A block two days later completes two days.
+	b := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: day1 + 2*msPerDay + 1}}
+	err = ind.snapshotBalances(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	got, _, err := ind.BalanceSnapshots(ctx, "", "", time.Unix(0, 0), time.Now(), nil, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := []*BalanceSnapshot{
+		{Date: "2016-12-01", AccountID: "acc1", AssetID: "a1", Amount: 5},
+		{Date: "2016-12-02", AccountID: "acc1", AssetID: "a1", Amount: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BalanceSnapshots = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"chain/core/tenant"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Output statuses.
+const (
+	OutputUnspent = "unspent"
+	OutputSpent   = "spent"
+)
+
+// maxSpendChainOutputs caps the number of outputs
+// OutputDetail visits following a spend chain.
+const maxSpendChainOutputs = 1000
+
+// An OutputStatus says whether an indexed output is spent,
+// and if so, by which transaction. SpentBy is nil for outputs
+// spent before the index recorded spending transactions.
+// Output is the annotated output, if the tenant asking may
+// see it.
+type OutputStatus struct {
+	TransactionID bc.Hash          `json:"transaction_id"`
+	Position      uint32           `json:"position"`
+	BlockHeight   uint64           `json:"block_height"`
+	Status        string           `json:"status"`
+	SpentBy       *Spend           `json:"spent_by,omitempty"`
+	Output        *json.RawMessage `json:"output,omitempty"`
+}
+
+// A Spend is the input of a transaction that spent an output.
+type Spend struct {
+	TransactionID bc.Hash `json:"transaction_id"`
+	InputPosition uint32  `json:"input_position"`
+	BlockHeight   uint64  `json:"block_height"`
+}
+
+// A SpendStep is a transaction in a spend chain, Depth
+// spends from the output the chain starts at, and the
+// status of its outputs.
+type SpendStep struct {
+	Depth         int             `json:"depth"`
+	TransactionID bc.Hash         `json:"transaction_id"`
+	BlockHeight   uint64          `json:"block_height"`
+	Outputs       []*OutputStatus `json:"outputs"`
+}
+
+// OutputDetail is an output and where its funds went.
+type OutputDetail struct {
+	*OutputStatus
+	SpendChain []*SpendStep `json:"spend_chain"`
+
+	// Truncated is true if the spend chain goes on
+	// beyond the depth or number of outputs visited.
+	Truncated bool `json:"truncated"`
+}
+
+// OutputDetail returns the status of the output at op and its
+// spend chain: the transaction that spent it and the status of
+// that transaction's outputs, then the transactions that spent
+// those, and so on, up to maxDepth transactions deep. Outputs
+// pruned from the index end the chain.
+func (ind *Indexer) OutputDetail(ctx context.Context, op bc.Outpoint, maxDepth int) (*OutputDetail, error) {
+	outs, err := ind.outputStatuses(ctx, `tx_hash=$2 AND output_index=$3`, op.Hash.String(), op.Index)
+	if err != nil {
+		return nil, err
+	}
+	if len(outs) == 0 || outs[0].Output == nil {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "output %s", op.String())
+	}
+	d := &OutputDetail{OutputStatus: outs[0], SpendChain: []*SpendStep{}}
+
+	var (
+		visited = 1
+		next    = []*OutputStatus{outs[0]}
+		seen    = make(map[bc.Hash]bool)
+	)
+	for depth := 1; len(next) > 0; depth++ {
+		var spends []*Spend
+		for _, o := range next {
+			if o.SpentBy != nil && !seen[o.SpentBy.TransactionID] {
+				seen[o.SpentBy.TransactionID] = true
+				spends = append(spends, o.SpentBy)
+			}
+		}
+		if len(spends) > 0 && depth > maxDepth {
+			d.Truncated = true
+			break
+		}
+		next = nil
+		for _, s := range spends {
+			if visited >= maxSpendChainOutputs {
+				d.Truncated = true
+				return d, nil
+			}
+			outs, err := ind.outputStatuses(ctx, `tx_hash=$2 ORDER BY output_index`, s.TransactionID.String())
+			if err != nil {
+				return nil, err
+			}
+			visited += len(outs)
+			d.SpendChain = append(d.SpendChain, &SpendStep{
+				Depth:         depth,
+				TransactionID: s.TransactionID,
+				BlockHeight:   s.BlockHeight,
+				Outputs:       outs,
+			})
+			next = append(next, outs...)
+		}
+	}
+	return d, nil
+}
+
+// outputStatuses returns the statuses of the indexed outputs
+// matching where, whose parameters are $2 and up. The tenant
+// ctx acts for is $1.
+func (ind *Indexer) outputStatuses(ctx context.Context, where string, args ...interface{}) ([]*OutputStatus, error) {
+	q := fmt.Sprintf(`
+		SELECT tx_hash, output_index, block_height, data, NOT upper_inf(timespan),
+			spent_by_tx_hash, spent_by_input, spent_at_height,
+			$1='' OR %s
+		FROM annotated_outputs
+		WHERE %s
+	`, fmt.Sprintf(tenantOutputs, 1), where)
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+
+	var outs []*OutputStatus
+	args = append(args, func(txHash bc.Hash, index uint32, height uint64, data []byte, spent bool, spender sql.NullString, input, spentAt sql.NullInt64, visible bool) error {
+		o := &OutputStatus{
+			TransactionID: txHash,
+			Position:      index,
+			BlockHeight:   height,
+			Status:        OutputUnspent,
+		}
+		if visible {
+			o.Output = (*json.RawMessage)(&data)
+		}
+		if spent {
+			o.Status = OutputSpent
+		}
+		if spender.Valid {
+			o.SpentBy = &Spend{InputPosition: uint32(input.Int64), BlockHeight: uint64(spentAt.Int64)}
+			err := o.SpentBy.TransactionID.UnmarshalText([]byte(spender.String))
+			if err != nil {
+				return errors.Wrap(err)
+			}
+		}
+		outs = append(outs, o)
+		return nil
+	})
+	err := pg.ForQueryRows(ctx, ind.db, q, args...)
+	return outs, errors.Wrap(err, "querying output statuses")
+}
@@ -0,0 +1,64 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/bc"
+)
+
+func TestOutputDetail(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+
+	// Output a/0 is spent by b, whose output 0 is spent by c.
+	a, b, c := bc.Hash{1}, bc.Hash{2}, bc.Hash{3}
+	_, err := db.Exec(ctx, `
+		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash, data, timespan,
+			spent_by_tx_hash, spent_by_input, spent_at_height)
+		VALUES
+			(1, 0, 0, $1, '{}', int8range(1, 2), $2, 0, 2),
+			(2, 0, 0, $2, '{}', int8range(2, 3), $3, 1, 3),
+			(2, 0, 1, $2, '{}', int8range(2, NULL), NULL, NULL, NULL),
+			(3, 0, 0, $3, '{}', int8range(3, NULL), NULL, NULL, NULL);
+	`, a.String(), b.String(), c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ind := NewIndexer(db, &protocol.Chain{}, nil)
+
+	d, err := ind.OutputDetail(ctx, bc.Outpoint{Hash: a}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != OutputSpent || d.SpentBy == nil || d.SpentBy.TransactionID != b || d.SpentBy.BlockHeight != 2 {
+		t.Fatalf("got status %s spent by %+v, want spent by b at height 2", d.Status, d.SpentBy)
+	}
+	if len(d.SpendChain) != 2 || d.Truncated {
+		t.Fatalf("got %d steps (truncated %t), want 2", len(d.SpendChain), d.Truncated)
+	}
+	step := d.SpendChain[0]
+	if step.TransactionID != b || len(step.Outputs) != 2 || step.Outputs[1].Status != OutputUnspent {
+		t.Errorf("step 1 = %+v, want b with its second output unspent", step)
+	}
+	if step := d.SpendChain[1]; step.Depth != 2 || step.TransactionID != c {
+		t.Errorf("step 2 = %+v, want c at depth 2", step)
+	}
+
+	d, err = ind.OutputDetail(ctx, bc.Outpoint{Hash: a}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.SpendChain) != 1 || !d.Truncated {
+		t.Errorf("depth 1: got %d steps (truncated %t), want 1 truncated", len(d.SpendChain), d.Truncated)
+	}
+
+	_, err = ind.OutputDetail(ctx, bc.Outpoint{Hash: a, Index: 5}, 1)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("missing output: err = %v, want ErrUserInputNotFound", err)
+	}
+}
@@ -0,0 +1,48 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"chain/core/query/filter"
+	"chain/core/tenant"
+)
+
+const (
+	// tenantRows restricts annotated_accounts and annotated_assets
+	// to the rows of a tenant.
+	tenantRows = "tenant=$%d"
+
+	// tenantOutputs restricts annotated_outputs to the outputs
+	// controlled by the accounts of a tenant.
+	tenantOutputs = "data->>'account_id' IN (SELECT id FROM annotated_accounts WHERE tenant=$%d)"
+
+	// tenantTxs restricts annotated_txs to the transactions that
+	// spend from or pay to the accounts of a tenant.
+	tenantTxs = `EXISTS (SELECT 1 FROM annotated_accounts a WHERE a.tenant=$%d AND (
+		data @> jsonb_build_object('inputs', jsonb_build_array(jsonb_build_object('account_id', a.id))) OR
+		data @> jsonb_build_object('outputs', jsonb_build_array(jsonb_build_object('account_id', a.id)))))`
+)
+
+// restrictToTenant returns expr with cond ANDed in, if ctx acts for a
+// tenant other than the default tenant. Cond must contain a single %d
+// verb, which is replaced with the index of the tenant parameter.
+func restrictToTenant(ctx context.Context, expr filter.SQLExpr, cond string) filter.SQLExpr {
+	id := tenant.FromContext(ctx)
+	if id == tenant.Default {
+		return expr
+	}
+	return restrict(expr, cond, id)
+}
+
+// restrict returns expr with cond ANDed in. Cond must contain a
+// single %d verb, which is replaced with the index of val among
+// the parameters.
+func restrict(expr filter.SQLExpr, cond string, val interface{}) filter.SQLExpr {
+	vals := append(expr.Values[:len(expr.Values):len(expr.Values)], val)
+	sql := fmt.Sprintf(cond, len(vals))
+	if expr.SQL != "" {
+		sql = "(" + expr.SQL + ") AND " + sql
+	}
+	return filter.SQLExpr{SQL: sql, Values: vals}
+}
@@ -0,0 +1,38 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"chain/core/query/filter"
+	"chain/core/tenant"
+)
+
+func TestRestrictToTenant(t *testing.T) {
+	expr := filter.SQLExpr{SQL: "data->>'alias' = $1", Values: []interface{}{"a"}}
+
+	got := restrictToTenant(context.Background(), expr, tenantRows)
+	if !reflect.DeepEqual(got, expr) {
+		t.Errorf("default tenant: got %#v, want %#v", got, expr)
+	}
+
+	ctx := tenant.NewContext(context.Background(), "t1")
+	got = restrictToTenant(ctx, expr, tenantRows)
+	want := filter.SQLExpr{
+		SQL:    "(data->>'alias' = $1) AND tenant=$2",
+		Values: []interface{}{"a", "t1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if len(expr.Values) != 1 {
+		t.Errorf("restrictToTenant modified its argument: %#v", expr)
+	}
+
+	got = restrictToTenant(ctx, filter.SQLExpr{}, tenantRows)
+	want = filter.SQLExpr{SQL: "tenant=$1", Values: []interface{}{"t1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("empty expr: got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Trace directions.
+const (
+	TraceBackward = "backward"
+	TraceForward  = "forward"
+	TraceBoth     = "both"
+)
+
+// maxTraceNodes caps the number of outputs in a trace.
+const maxTraceNodes = 1000
+
+// A TraceNode is an output in a provenance tree. Sources are
+// the outputs of the same asset spent by the transaction that
+// created it; Destinations are the outputs of the same asset
+// created by the transaction that spent it. Issued is true if
+// the transaction that created it issued the asset.
+//
+// The sources and destinations of a transaction appear in the
+// tree only once, under the first of its outputs traced.
+type TraceNode struct {
+	*OutputStatus
+	Issued       bool         `json:"issued"`
+	Sources      []*TraceNode `json:"sources,omitempty"`
+	Destinations []*TraceNode `json:"destinations,omitempty"`
+}
+
+// A Trace is the provenance tree of an output.
+type Trace struct {
+	Root *TraceNode `json:"root"`
+
+	// Truncated is true if the tree goes on beyond
+	// the hops or number of outputs traced.
+	Truncated bool `json:"truncated"`
+}
+
+// Trace walks the transaction graph from the output at op,
+// following transfers of its asset, up to hops transactions
+// backward to the outputs the asset came from, forward to the
+// outputs it went to, or both, depending on dir.
+func (ind *Indexer) Trace(ctx context.Context, op bc.Outpoint, dir string, hops int) (*Trace, error) {
+	outs, err := ind.outputStatuses(ctx, `tx_hash=$2 AND output_index=$3`, op.Hash.String(), op.Index)
+	if err != nil {
+		return nil, err
+	}
+	if len(outs) == 0 || outs[0].Output == nil {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "output %s", op.String())
+	}
+	var out struct {
+		AssetID *json.RawMessage `json:"asset_id"`
+	}
+	err = json.Unmarshal(*outs[0].Output, &out)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding output")
+	}
+	t := &tracer{
+		ind:   ind,
+		asset: "null",
+		nodes: 1,
+		seen:  make(map[bc.Hash]bool),
+	}
+	if out.AssetID != nil {
+		t.asset = string(*out.AssetID)
+	}
+
+	root := &TraceNode{OutputStatus: outs[0]}
+	root.Issued, err = t.issued(ctx, root.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if dir != TraceForward {
+		err = t.walk(ctx, root, hops, t.sources)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dir != TraceBackward {
+		t.seen = make(map[bc.Hash]bool)
+		err = t.walk(ctx, root, hops, t.destinations)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Trace{Root: root, Truncated: t.truncated}, nil
+}
+
+type tracer struct {
+	ind       *Indexer
+	asset     string // JSON asset ID, or null if confidential
+	nodes     int
+	seen      map[bc.Hash]bool
+	truncated bool
+}
+
+// walk expands the tree breadth-first from root, up to hops
+// levels deep, using expand to find and attach each node's
+// children.
+func (t *tracer) walk(ctx context.Context, root *TraceNode, hops int, expand func(context.Context, *TraceNode, bool) ([]*TraceNode, error)) error {
+	level := []*TraceNode{root}
+	for hop := 1; len(level) > 0; hop++ {
+		var next []*TraceNode
+		for _, n := range level {
+			if t.nodes >= maxTraceNodes {
+				t.truncated = true
+				return nil
+			}
+			children, err := expand(ctx, n, hop <= hops)
+			if err != nil {
+				return err
+			}
+			if hop > hops && len(children) > 0 {
+				t.truncated = true
+				return nil
+			}
+			t.nodes += len(children)
+			next = append(next, children...)
+		}
+		level = next
+	}
+	return nil
+}
+
+// sources finds the outputs of t's asset spent by the
+// transaction that created n, attaching them if attach.
+func (t *tracer) sources(ctx context.Context, n *TraceNode, attach bool) ([]*TraceNode, error) {
+	if t.seen[n.TransactionID] {
+		return nil, nil
+	}
+	t.seen[n.TransactionID] = true
+	outs, err := t.ind.outputStatuses(ctx, `
+		spent_by_tx_hash=$2 AND COALESCE(data->'asset_id', 'null')=$3::jsonb
+		ORDER BY block_height, tx_pos, output_index
+	`, n.TransactionID.String(), t.asset)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*TraceNode
+	for _, o := range outs {
+		c := &TraceNode{OutputStatus: o}
+		c.Issued, err = t.issued(ctx, o.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, c)
+	}
+	if attach {
+		n.Sources = nodes
+	}
+	return nodes, nil
+}
+
+// destinations finds the outputs of t's asset created by the
+// transaction that spent n, attaching them if attach.
+func (t *tracer) destinations(ctx context.Context, n *TraceNode, attach bool) ([]*TraceNode, error) {
+	if n.SpentBy == nil || t.seen[n.SpentBy.TransactionID] {
+		return nil, nil
+	}
+	t.seen[n.SpentBy.TransactionID] = true
+	outs, err := t.ind.outputStatuses(ctx, `
+		tx_hash=$2 AND COALESCE(data->'asset_id', 'null')=$3::jsonb
+		ORDER BY output_index
+	`, n.SpentBy.TransactionID.String(), t.asset)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*TraceNode
+	for _, o := range outs {
+		nodes = append(nodes, &TraceNode{OutputStatus: o})
+	}
+	if attach {
+		n.Destinations = nodes
+	}
+	return nodes, nil
+}
+
+// issued returns whether the transaction with hash h
+// issued t's asset.
+func (t *tracer) issued(ctx context.Context, h bc.Hash) (bool, error) {
+	const q = `
+		SELECT EXISTS(SELECT 1 FROM annotated_txs WHERE tx_hash=$1 AND
+			data->'inputs' @> jsonb_build_array(jsonb_build_object('type', 'issue', 'asset_id', $2::jsonb)))
+	`
+	var issued bool
+	err := t.ind.db.QueryRow(ctx, q, h.String(), t.asset).Scan(&issued)
+	return issued, errors.Wrap(err, "checking issuance")
+}
@@ -0,0 +1,60 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol"
+	"chain/protocol/bc"
+)
+
+func TestTrace(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+
+	// Transaction a issues asset x. Transaction b spends a/0,
+	// and an output of asset y, paying b/0 in x and b/1 in y.
+	// Transaction c spends b/0.
+	a, b, c, y := bc.Hash{1}, bc.Hash{2}, bc.Hash{3}, bc.Hash{4}
+	_, err := db.Exec(ctx, `
+		INSERT INTO annotated_txs (block_height, tx_pos, tx_hash, data)
+		VALUES (1, 0, $1, '{"inputs": [{"type": "issue", "asset_id": "x"}]}');
+		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash, data, timespan,
+			spent_by_tx_hash, spent_by_input, spent_at_height)
+		VALUES
+			(1, 0, 0, $1, '{"asset_id": "x"}', int8range(1, 2), $2, 0, 2),
+			(1, 1, 0, $4, '{"asset_id": "y"}', int8range(1, 2), $2, 1, 2),
+			(2, 0, 0, $2, '{"asset_id": "x"}', int8range(2, 3), $3, 0, 3),
+			(2, 0, 1, $2, '{"asset_id": "y"}', int8range(2, NULL), NULL, NULL, NULL),
+			(3, 0, 0, $3, '{"asset_id": "x"}', int8range(3, NULL), NULL, NULL, NULL);
+	`, a.String(), b.String(), c.String(), y.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ind := NewIndexer(db, &protocol.Chain{}, nil)
+
+	tr, err := ind.Trace(ctx, bc.Outpoint{Hash: b}, TraceBoth, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := tr.Root.Sources
+	if len(src) != 1 || src[0].TransactionID != a || !src[0].Issued {
+		t.Errorf("sources = %+v, want only a/0, issued", src)
+	}
+	dst := tr.Root.Destinations
+	if len(dst) != 1 || dst[0].TransactionID != c {
+		t.Errorf("destinations = %+v, want only c/0", dst)
+	}
+	if tr.Truncated {
+		t.Error("trace truncated")
+	}
+
+	tr, err = ind.Trace(ctx, bc.Outpoint{Hash: c}, TraceBackward, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Root.Sources) != 1 || tr.Root.Destinations != nil || !tr.Truncated {
+		t.Errorf("backward 1 hop: got %+v (truncated %t), want b/0 truncated", tr.Root, tr.Truncated)
+	}
+}
@@ -70,8 +70,10 @@ func (ind *Indexer) LookupTxAfter(ctx context.Context, begin, end uint64) (TxAft
 }
 
 // Transactions queries the blockchain for transactions matching the
-// filter predicate `p`.
-func (ind *Indexer) Transactions(ctx context.Context, p filter.Predicate, vals []interface{}, after TxAfter, limit int, asc bool) ([]interface{}, *TxAfter, error) {
+// filter predicate `p`. If search is not empty, it returns only
+// transactions whose reference data, or the reference data of
+// any of their inputs or outputs, contains every word in search.
+func (ind *Indexer) Transactions(ctx context.Context, p filter.Predicate, vals []interface{}, search string, after TxAfter, limit int, asc bool) ([]interface{}, *TxAfter, error) {
 	if len(vals) != p.Parameters {
 		return nil, nil, ErrParameterCountMismatch
 	}
@@ -79,8 +81,10 @@ func (ind *Indexer) Transactions(ctx context.Context, p filter.Predicate, vals [
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "converting to SQL")
 	}
+	expr = restrictToTenant(ctx, expr, tenantTxs)
+	expr = restrictToRollUp(ctx, expr, rollUpTxs)
 
-	queryStr, queryArgs := constructTransactionsQuery(expr, after, asc, limit)
+	queryStr, queryArgs := constructTransactionsQuery(expr, search, after, asc, limit)
 
 	if asc {
 		return ind.waitForAndFetchTransactions(ctx, queryStr, queryArgs, after, limit)
@@ -91,7 +95,7 @@ func (ind *Indexer) Transactions(ctx context.Context, p filter.Predicate, vals [
 // If asc is true, the transactions will be returned from "in front" of the `after`
 // param (e.g., the oldest transaction immediately after the `after` param,
 // followed by the second oldest, etc) in ascending order.
-func constructTransactionsQuery(expr filter.SQLExpr, after TxAfter, asc bool, limit int) (string, []interface{}) {
+func constructTransactionsQuery(expr filter.SQLExpr, search string, after TxAfter, asc bool, limit int) (string, []interface{}) {
 	var buf bytes.Buffer
 	var vals []interface{}
 
@@ -105,6 +109,12 @@ func constructTransactionsQuery(expr filter.SQLExpr, after TxAfter, asc bool, li
 		buf.WriteString(" AND ")
 	}
 
+	// add full-text search over reference data
+	if search != "" {
+		vals = append(vals, search)
+		buf.WriteString(fmt.Sprintf("reference_data_tsvector(data) @@ plainto_tsquery('simple', $%d) AND ", len(vals)))
+	}
+
 	if asc {
 		// add time range & after conditions
 		buf.WriteString(fmt.Sprintf("(block_height, tx_pos) > ($%d, $%d) AND ", len(vals)+1, len(vals)+2))
@@ -69,6 +69,7 @@ func TestConstructTransactionsQuery(t *testing.T) {
 	testCases := []struct {
 		filter     string
 		values     []interface{}
+		search     string
 		after      TxAfter
 		asc        bool
 		wantQuery  string
@@ -109,6 +110,18 @@ func TestConstructTransactionsQuery(t *testing.T) {
 				uint64(2), uint32(20), uint64(1),
 			},
 		},
+		{
+			filter:    `inputs(type='issue')`,
+			search:    "INV-1234",
+			after:     TxAfter{FromBlockHeight: 2, FromPosition: 20, StopBlockHeight: 1},
+			asc:       false,
+			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs WHERE (data @> $1::jsonb) AND reference_data_tsvector(data) @@ plainto_tsquery('simple', $2) AND (block_height, tx_pos) < ($3, $4) AND block_height >= $5 ORDER BY block_height DESC, tx_pos DESC LIMIT 100`,
+			wantValues: []interface{}{
+				`{"inputs":[{"type":"issue"}]}`,
+				"INV-1234",
+				uint64(2), uint32(20), uint64(1),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -121,7 +134,7 @@ func TestConstructTransactionsQuery(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		query, values := constructTransactionsQuery(expr, tc.after, tc.asc, 100)
+		query, values := constructTransactionsQuery(expr, tc.search, tc.after, tc.asc, 100)
 		if query != tc.wantQuery {
 			t.Errorf("got\n%s\nwant\n%s", query, tc.wantQuery)
 		}
@@ -6,13 +6,15 @@ import (
 	"fmt"
 	"strconv"
 
+	"chain/core/tenant"
 	"chain/core/txfeed"
 	"chain/errors"
 )
 
 // TxFeeds queries the blockchain for txfeeds matching the query.
+// Txfeeds of tenants other than the one ctx acts for are left out.
 func (ind *Indexer) TxFeeds(ctx context.Context, after string, limit int) ([]*txfeed.TxFeed, string, error) {
-	queryStr, queryArgs := constructTxFeedsQuery(after, limit)
+	queryStr, queryArgs := constructTxFeedsQuery(tenant.FromContext(ctx), after, limit)
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, "", errors.Wrap(err, "executing txfeeds query")
@@ -45,10 +47,12 @@ func (ind *Indexer) TxFeeds(ctx context.Context, after string, limit int) ([]*tx
 	return txfeeds, after, nil
 }
 
-func constructTxFeedsQuery(after string, limit int) (string, []interface{}) {
+func constructTxFeedsQuery(tenantID, after string, limit int) (string, []interface{}) {
 	var vals []interface{}
 
 	q := "SELECT id, alias, filter, after FROM txfeeds WHERE "
+	q += fmt.Sprintf("($%d='' OR tenant=$%d) AND ", len(vals)+1, len(vals)+1)
+	vals = append(vals, tenantID)
 	// add after conditions
 	q += fmt.Sprintf("($%d='' OR id < $%d) ", len(vals)+1, len(vals)+1)
 	vals = append(vals, after)
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -49,3 +50,50 @@ func TestQueryWithClockSkew(t *testing.T) {
 		t.Errorf("got=%d txs, want %d", count, 1)
 	}
 }
+
+func TestFinalityOf(t *testing.T) {
+	f := finality{height: 10, final: 7}
+	cases := []struct {
+		blockHeight interface{}
+		confs       uint64
+		final       bool
+	}{
+		{float64(10), 1, false},
+		{float64(8), 3, false},
+		{float64(7), 4, true},
+		{float64(1), 10, true},
+		{float64(11), 0, false}, // not yet in this core's view of the chain
+		{json.Number("8"), 3, false},
+		{json.Number("7"), 4, true},
+		{nil, 0, false},
+	}
+	for _, c := range cases {
+		confs, final := f.of(c.blockHeight)
+		if confs != c.confs || final != c.final {
+			t.Errorf("of(%v) = %d, %t want %d, %t", c.blockHeight, confs, final, c.confs, c.final)
+		}
+	}
+}
+
+func TestDisplayAmount(t *testing.T) {
+	def, err := decodeAnnotated([]byte(`{"decimals": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		amount, def interface{}
+		want        interface{}
+	}{
+		{json.Number("12345"), def, "123.45"},
+		{json.Number("9007199254740993"), def, "90071992547409.93"},
+		{float64(12345), def, "123.45"},
+		{json.Number("12345"), map[string]interface{}{}, nil},
+		{json.Number("12345"), nil, nil},
+	}
+	for _, c := range cases {
+		got := displayAmount(c.amount, c.def)
+		if got != c.want {
+			t.Errorf("displayAmount(%v, %v) = %v, want %v", c.amount, c.def, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Package refdata encrypts reference data, so that only the
+// parties to a transaction can read it.
+//
+// Reference data encrypted with Encrypt is an envelope,
+//
+//	{"encrypted": {"key_id": ..., "nonce": ..., "ciphertext": ...}}
+//
+// holding the original object sealed with AES-256-GCM under a
+// new random key. Each key encrypts the reference data of one
+// transaction, or of one of its inputs or outputs, so sharing it
+// with a counterparty discloses only that. The Keyring stores
+// the keys Chain Core made or imported, each belonging to the
+// tenant that made or imported it. As an annotator it replaces
+// the envelopes the default tenant's keys open in the query
+// indexes with their contents, so filters can select on them;
+// other tenants' keys are used only to decrypt for them.
+package refdata
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/lib/pq"
+
+	"chain/core/tenant"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+var (
+	// ErrBadEnvelope is returned for reference data that
+	// isn't a well-formed encrypted envelope.
+	ErrBadEnvelope = errors.New("invalid encrypted reference data")
+
+	// ErrNoKey is returned when the key of an envelope is
+	// neither made by nor imported into this core by the
+	// tenant asking for it.
+	ErrNoKey = errors.New("reference data key not found")
+
+	// ErrBadKey is returned for imported keys
+	// that don't match their IDs.
+	ErrBadKey = errors.New("invalid reference data key")
+)
+
+const keySize = 32
+
+// A Key is the key of one piece of encrypted reference data.
+type Key struct {
+	ID     string             `json:"key_id"`
+	Secret chainjson.HexBytes `json:"key"`
+}
+
+type envelope struct {
+	KeyID      string             `json:"key_id"`
+	Nonce      chainjson.HexBytes `json:"nonce"`
+	Ciphertext chainjson.HexBytes `json:"ciphertext"`
+}
+
+// keyID returns the ID of the key secret, which is
+// a hash of it, so the ID can't be forged for another key.
+func keyID(secret []byte) string {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write([]byte("ChainRefDataKey"))
+	h.Write(secret)
+	var id [16]byte
+	h.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// A Keyring stores the keys of encrypted reference data.
+type Keyring struct {
+	db pg.DB
+}
+
+// NewKeyring returns a new Keyring using db.
+func NewKeyring(db pg.DB) *Keyring {
+	return &Keyring{db: db}
+}
+
+// Encrypt encrypts the JSON object data to a new key, which it
+// saves, and returns the envelope, to be used as reference data.
+func (k *Keyring) Encrypt(ctx context.Context, data chainjson.Map) (chainjson.Map, *Key, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil || obj == nil {
+		return nil, nil, errors.WithDetail(ErrBadEnvelope, "reference data to encrypt must be a JSON object")
+	}
+
+	secret := make([]byte, keySize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	key := &Key{ID: keyID(secret), Secret: secret}
+	err = k.save(ctx, []*Key{key})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	env := envelope{KeyID: key.ID, Nonce: make([]byte, aead.NonceSize())}
+	_, err = rand.Read(env.Nonce)
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, data, []byte(key.ID))
+
+	b, err := json.Marshal(map[string]envelope{"encrypted": env})
+	return b, key, errors.Wrap(err)
+}
+
+// Decrypt returns the contents of the envelope data.
+func (k *Keyring) Decrypt(ctx context.Context, data chainjson.Map) (chainjson.Map, error) {
+	var obj map[string]interface{}
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadEnvelope, err.Error())
+	}
+	env, ok := parseEnvelope(obj)
+	if !ok {
+		return nil, errors.Wrap(ErrBadEnvelope)
+	}
+	keys, err := k.lookup(ctx, tenant.FromContext(ctx), []string{env.KeyID})
+	if err != nil {
+		return nil, err
+	}
+	secret, ok := keys[env.KeyID]
+	if !ok {
+		return nil, errors.WithDetailf(ErrNoKey, "key %s", env.KeyID)
+	}
+	return open(secret, env)
+}
+
+// Export returns the keys with the given IDs,
+// to share with counterparties.
+func (k *Keyring) Export(ctx context.Context, ids []string) ([]*Key, error) {
+	secrets, err := k.lookup(ctx, tenant.FromContext(ctx), ids)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Key, 0, len(ids))
+	for _, id := range ids {
+		secret, ok := secrets[id]
+		if !ok {
+			return nil, errors.WithDetailf(ErrNoKey, "key %s", id)
+		}
+		keys = append(keys, &Key{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// Import saves keys shared by counterparties. Keys imported
+// for the default tenant also annotate indexed transactions
+// whose reference data they decrypt.
+func (k *Keyring) Import(ctx context.Context, keys []*Key) error {
+	for _, key := range keys {
+		if len(key.Secret) != keySize || keyID(key.Secret) != key.ID {
+			return errors.WithDetailf(ErrBadKey, "key %s", key.ID)
+		}
+	}
+	err := k.save(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil
+	}
+	for _, key := range keys {
+		err = k.reannotate(ctx, key.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) save(ctx context.Context, keys []*Key) error {
+	var (
+		ids     []string
+		secrets pq.ByteaArray
+	)
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+		secrets = append(secrets, key.Secret)
+	}
+	const q = `
+		INSERT INTO reference_data_keys (key_id, secret, tenant)
+		SELECT unnest($1::text[]), unnest($2::bytea[]), $3
+		ON CONFLICT (tenant, key_id) DO NOTHING
+	`
+	_, err := k.db.Exec(ctx, q, pq.StringArray(ids), secrets, tenant.FromContext(ctx))
+	return errors.Wrap(err, "saving reference data keys")
+}
+
+// lookup returns the secrets of the keys with the
+// given IDs that belong to the tenant tenantID.
+func (k *Keyring) lookup(ctx context.Context, tenantID string, ids []string) (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	const q = `SELECT key_id, secret FROM reference_data_keys WHERE tenant=$1 AND key_id=ANY($2)`
+	err := pg.ForQueryRows(ctx, k.db, q, tenantID, pq.StringArray(ids), func(id string, secret []byte) {
+		secrets[id] = secret
+	})
+	return secrets, errors.Wrap(err, "looking up reference data keys")
+}
+
+// AnnotateTxs replaces the encrypted reference data of
+// transactions, and of their inputs and outputs, with its
+// contents, where the default tenant has the key. The envelope
+// is kept as encrypted_reference_data.
+func (k *Keyring) AnnotateTxs(ctx context.Context, txs []map[string]interface{}) error {
+	var objs []map[string]interface{}
+	for _, tx := range txs {
+		objs = append(objs, tx)
+		for _, field := range []string{"inputs", "outputs"} {
+			l, _ := tx[field].([]interface{})
+			for _, x := range l {
+				if m, ok := x.(map[string]interface{}); ok {
+					objs = append(objs, m)
+				}
+			}
+		}
+	}
+	return k.annotate(ctx, objs)
+}
+
+// annotate decrypts the reference data of objs in place,
+// with the default tenant's keys. The indexes it annotates
+// are shared by all tenants, so it never uses theirs.
+func (k *Keyring) annotate(ctx context.Context, objs []map[string]interface{}) error {
+	var (
+		encrypted []map[string]interface{}
+		envs      []*envelope
+		ids       []string
+	)
+	for _, obj := range objs {
+		refdata, _ := obj["reference_data"].(map[string]interface{})
+		if env, ok := parseEnvelope(refdata); ok {
+			encrypted = append(encrypted, obj)
+			envs = append(envs, env)
+			ids = append(ids, env.KeyID)
+		}
+	}
+	if len(encrypted) == 0 {
+		return nil
+	}
+
+	secrets, err := k.lookup(ctx, tenant.Default, ids)
+	if err != nil {
+		return err
+	}
+	for i, obj := range encrypted {
+		secret, ok := secrets[envs[i].KeyID]
+		if !ok {
+			continue
+		}
+		plain, err := open(secret, envs[i])
+		if err != nil {
+			continue // leave undecryptable data as it is
+		}
+		var refdata map[string]interface{}
+		if json.Unmarshal(plain, &refdata) != nil {
+			continue
+		}
+		obj["encrypted_reference_data"] = obj["reference_data"]
+		obj["reference_data"] = refdata
+	}
+	return nil
+}
+
+// reannotate decrypts the indexed reference data encrypted to the
+// key with the given ID, for keys imported after it was indexed.
+func (k *Keyring) reannotate(ctx context.Context, id string) error {
+	enc := map[string]interface{}{"reference_data": map[string]interface{}{
+		"encrypted": map[string]interface{}{"key_id": id},
+	}}
+	inList := func(field string) string {
+		b, _ := json.Marshal(map[string]interface{}{field: []interface{}{enc}})
+		return string(b)
+	}
+	b, _ := json.Marshal(enc)
+
+	const txWhere = `data @> $1::jsonb OR data @> $2::jsonb OR data @> $3::jsonb`
+	err := k.reannotateTable(ctx, "annotated_txs", "t.tx_hash", txWhere, string(b), inList("inputs"), inList("outputs"))
+	if err != nil {
+		return err
+	}
+	return k.reannotateTable(ctx, "annotated_outputs", "t.tx_hash || ':' || t.output_index", `data @> $1::jsonb`, string(b))
+}
+
+// reannotateTable decrypts the reference data in the rows of
+// table for which where holds. Rows are identified by idExpr,
+// in which the table is named t.
+func (k *Keyring) reannotateTable(ctx context.Context, table, idExpr, where string, args ...interface{}) error {
+	var (
+		ids  []string
+		rows []map[string]interface{}
+	)
+	q := `SELECT ` + idExpr + `, t.data FROM ` + table + ` t WHERE ` + where
+	err := pg.ForQueryRows(ctx, k.db, q, append(args, func(id string, data []byte) error {
+		var m map[string]interface{}
+		err := json.Unmarshal(data, &m)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		ids = append(ids, id)
+		rows = append(rows, m)
+		return nil
+	})...)
+	if err != nil {
+		return errors.Wrap(err, "finding encrypted reference data in "+table)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if table == "annotated_txs" {
+		err = k.AnnotateTxs(ctx, rows)
+	} else {
+		err = k.annotate(ctx, rows)
+	}
+	if err != nil {
+		return err
+	}
+
+	var data pq.StringArray
+	for _, row := range rows {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		data = append(data, string(b))
+	}
+	updateQ := `
+		UPDATE ` + table + ` t SET data=u.data
+		FROM (SELECT unnest($1::text[]) AS id, unnest($2::jsonb[]) AS data) u
+		WHERE ` + idExpr + `=u.id
+	`
+	_, err = k.db.Exec(ctx, updateQ, pq.StringArray(ids), data)
+	return errors.Wrap(err, "updating "+table)
+}
+
+func parseEnvelope(refdata map[string]interface{}) (*envelope, bool) {
+	if len(refdata) != 1 {
+		return nil, false
+	}
+	m, ok := refdata["encrypted"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, false
+	}
+	env := new(envelope)
+	err = json.Unmarshal(b, env)
+	if err != nil || env.KeyID == "" || len(env.Nonce) == 0 {
+		return nil, false
+	}
+	return env, true
+}
+
+func open(secret []byte, env *envelope) ([]byte, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, errors.WithDetail(ErrBadEnvelope, "bad nonce size")
+	}
+	plain, err := aead.Open(nil, env.Nonce, env.Ciphertext, []byte(env.KeyID))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadEnvelope, "ciphertext does not match key")
+	}
+	return plain, nil
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.Wrap(err)
+}
@@ -0,0 +1,147 @@
+package refdata
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"chain/core/tenant"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeyring(pgtest.NewTx(t))
+
+	plain := []byte(`{"memo":"invoice 17"}`)
+	env, key, err := k.Encrypt(ctx, plain)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if string(env) == string(plain) {
+		t.Fatal("envelope is plaintext")
+	}
+	got, err := k.Decrypt(ctx, env)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if string(got) != string(plain) {
+		t.Errorf("Decrypt = %s want %s", got, plain)
+	}
+
+	// A counterparty can't decrypt until it imports the key.
+	other := NewKeyring(pgtest.NewTx(t))
+	_, err = other.Decrypt(ctx, env)
+	if errors.Root(err) != ErrNoKey {
+		t.Errorf("Decrypt without key = %v want %v", err, ErrNoKey)
+	}
+	keys, err := k.Export(ctx, []string{key.ID})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = other.Import(ctx, keys)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = other.Decrypt(ctx, env)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if string(got) != string(plain) {
+		t.Errorf("Decrypt after import = %s want %s", got, plain)
+	}
+
+	keys[0].Secret[0] ^= 1
+	err = other.Import(ctx, keys)
+	if errors.Root(err) != ErrBadKey {
+		t.Errorf("Import(tampered key) = %v want %v", err, ErrBadKey)
+	}
+}
+
+func TestAnnotateTxs(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeyring(pgtest.NewTx(t))
+
+	env, _, err := k.Encrypt(ctx, []byte(`{"memo":"secret"}`))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var encrypted map[string]interface{}
+	err = json.Unmarshal(env, &encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := map[string]interface{}{"encrypted": map[string]interface{}{
+		"key_id": "00", "nonce": "00", "ciphertext": "00",
+	}}
+
+	txs := []map[string]interface{}{{
+		"reference_data": map[string]interface{}{"memo": "public"},
+		"outputs": []interface{}{
+			map[string]interface{}{"reference_data": encrypted},
+			map[string]interface{}{"reference_data": unknown},
+		},
+	}}
+	err = k.AnnotateTxs(ctx, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	outs := txs[0]["outputs"].([]interface{})
+	want := []interface{}{
+		map[string]interface{}{
+			"reference_data":           map[string]interface{}{"memo": "secret"},
+			"encrypted_reference_data": encrypted,
+		},
+		map[string]interface{}{"reference_data": unknown},
+	}
+	if !reflect.DeepEqual(outs, want) {
+		t.Errorf("outputs = %v want %v", outs, want)
+	}
+	if !reflect.DeepEqual(txs[0]["reference_data"], map[string]interface{}{"memo": "public"}) {
+		t.Errorf("plain reference data changed: %v", txs[0]["reference_data"])
+	}
+}
+
+func TestKeyTenants(t *testing.T) {
+	ctx := context.Background()
+	t1 := tenant.NewContext(ctx, "t1")
+	k := NewKeyring(pgtest.NewTx(t))
+
+	env, key, err := k.Encrypt(t1, []byte(`{"memo":"secret"}`))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = k.Decrypt(t1, env)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Another tenant can't read or export the key,
+	// and it doesn't decrypt the shared indexes.
+	for _, ctx := range []context.Context{ctx, tenant.NewContext(ctx, "t2")} {
+		_, err = k.Decrypt(ctx, env)
+		if errors.Root(err) != ErrNoKey {
+			t.Errorf("Decrypt(tenant %q) = %v want %v", tenant.FromContext(ctx), err, ErrNoKey)
+		}
+		_, err = k.Export(ctx, []string{key.ID})
+		if errors.Root(err) != ErrNoKey {
+			t.Errorf("Export(tenant %q) = %v want %v", tenant.FromContext(ctx), err, ErrNoKey)
+		}
+	}
+	var encrypted map[string]interface{}
+	err = json.Unmarshal(env, &encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := []map[string]interface{}{{"reference_data": encrypted}}
+	err = k.AnnotateTxs(t1, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(txs[0], map[string]interface{}{"reference_data": encrypted}) {
+		t.Errorf("AnnotateTxs with a tenant's key = %v, want unchanged", txs[0])
+	}
+}
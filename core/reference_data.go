@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/refdata"
+	chainjson "chain/encoding/json"
+)
+
+// POST /encrypt-reference-data
+//
+// Encrypts reference_data to a new key, for use as the reference
+// data of a transaction or one of its actions. The key stays on
+// this core, which can share it with /export-reference-data-keys.
+// Keys belong to the tenant that made or imported them; only
+// the default tenant's keys decrypt reference data in the
+// query indexes.
+func (h *Handler) encryptReferenceData(ctx context.Context, in struct {
+	ReferenceData chainjson.Map `json:"reference_data"`
+}) (map[string]interface{}, error) {
+	env, key, err := h.ReferenceDataKeys.Encrypt(ctx, in.ReferenceData)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"reference_data": env, "key_id": key.ID}, nil
+}
+
+// POST /decrypt-reference-data
+func (h *Handler) decryptReferenceData(ctx context.Context, in struct {
+	ReferenceData chainjson.Map `json:"reference_data"`
+}) (map[string]interface{}, error) {
+	data, err := h.ReferenceDataKeys.Decrypt(ctx, in.ReferenceData)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"reference_data": data}, nil
+}
+
+// POST /export-reference-data-keys
+func (h *Handler) exportReferenceDataKeys(ctx context.Context, in struct {
+	KeyIDs []string `json:"key_ids"`
+}) ([]*refdata.Key, error) {
+	return h.ReferenceDataKeys.Export(ctx, in.KeyIDs)
+}
+
+// POST /import-reference-data-keys
+func (h *Handler) importReferenceDataKeys(ctx context.Context, in struct {
+	Keys []*refdata.Key `json:"keys"`
+}) error {
+	return h.ReferenceDataKeys.Import(ctx, in.Keys)
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 
+	"chain/core/asset"
 	"chain/encoding/json"
 	"chain/errors"
 	"chain/protocol/bc"
@@ -44,3 +45,50 @@ func (h *Handler) filterAliases(ctx context.Context, br *buildRequest) error {
 	}
 	return nil
 }
+
+// convertDisplayAmounts replaces the amount_display of each action
+// of br, an amount in the display units of the action's asset, with
+// the same amount in base units, as amount. The asset must declare
+// decimals in its definition.
+func (h *Handler) convertDisplayAmounts(ctx context.Context, br *buildRequest) error {
+	for i, m := range br.Actions {
+		v, ok := m["amount_display"]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return errors.WithDetailf(errBadAction, "amount_display must be a string on action %d", i)
+		}
+		if _, ok := m["amount"]; ok {
+			return errors.WithDetailf(errBadAction, "both amount and amount_display on action %d", i)
+		}
+		var assetID bc.AssetID
+		switch id := m["asset_id"].(type) {
+		case bc.AssetID:
+			assetID = id
+		case string:
+			err := assetID.UnmarshalText([]byte(id))
+			if err != nil {
+				return errors.WithDetailf(errBadAction, "invalid asset_id on action %d", i)
+			}
+		default:
+			return errors.WithDetailf(errBadAction, "amount_display without asset_id or asset_alias on action %d", i)
+		}
+		a, err := h.Assets.FindByID(ctx, assetID)
+		if err != nil {
+			return errors.WithDetailf(err, "asset %s on action %d", assetID, i)
+		}
+		decimals, ok := asset.Decimals(a.Definition)
+		if !ok {
+			return errors.WithDetailf(asset.ErrBadAmount, "asset %s declares no decimals on action %d", assetID, i)
+		}
+		amount, err := asset.ParseAmount(s, decimals)
+		if err != nil {
+			return errors.WithDetailf(err, "on action %d", i)
+		}
+		m["amount"] = amount
+		delete(m, "amount_display")
+	}
+	return nil
+}
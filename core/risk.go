@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/risk"
+	"chain/core/tenant"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// errNoRiskScores is returned by /get-risk-scores
+// on a core that doesn't score transactions.
+var errNoRiskScores = errors.New("transactions are not scored")
+
+// scoreSubmission scores tx, which was just submitted, if the
+// core scores submissions. Annotating tx runs the indexer's risk
+// annotator, which saves the score. Failing to score tx doesn't
+// fail its submission.
+func (h *Handler) scoreSubmission(ctx context.Context, tx *bc.Tx) {
+	if h.RiskScores == nil || !h.ScoreSubmissions {
+		return
+	}
+	_, err := h.Indexer.AnnotateUnconfirmed(ctx, tx)
+	if err != nil {
+		log.Error(ctx, err, "scoring submitted tx")
+	}
+}
+
+// POST /get-risk-scores
+//
+// Returns the risk scores of a transaction: the score it got
+// when submitted, if submissions are scored, and the score it
+// got once confirmed. Scores of confirmed transactions are also
+// included in their annotations, as risk_score.
+func (h *Handler) getRiskScores(ctx context.Context, in struct {
+	TransactionID bc.Hash `json:"transaction_id"`
+}) ([]*risk.Record, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	if h.RiskScores == nil {
+		return nil, errNoRiskScores
+	}
+	return h.RiskScores.Find(ctx, in.TransactionID.String())
+}
@@ -0,0 +1,79 @@
+package risk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chain/errors"
+)
+
+// defaultHTTPTimeout bounds each request of an HTTPScorer
+// whose client has no timeout of its own.
+const defaultHTTPTimeout = 10 * time.Second
+
+// HTTPScorer scores transactions by posting them to a scoring
+// service, as {"transactions": [...]}. The service must respond
+// 200 OK with {"scores": [...]}, holding a score object, or null,
+// for each transaction, in order.
+type HTTPScorer struct {
+	URL string
+
+	// Token, if set, is sent as a bearer token.
+	Token string
+
+	// Client is the HTTP client to use.
+	// If it's nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// ScoreTxs implements Scorer.
+func (s *HTTPScorer) ScoreTxs(ctx context.Context, txs []map[string]interface{}) ([]*Score, error) {
+	body, err := json.Marshal(struct {
+		Transactions []map[string]interface{} `json:"transactions"`
+	}{txs})
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if client.Timeout == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultHTTPTimeout)
+		defer cancel()
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "posting to scoring service")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scoring service responded %s", resp.Status)
+	}
+
+	var result struct {
+		Scores []*Score `json:"scores"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding scoring service response")
+	}
+	if len(result.Scores) != len(txs) {
+		return nil, fmt.Errorf("scoring service returned %d scores for %d transactions", len(result.Scores), len(txs))
+	}
+	return result.Scores, nil
+}
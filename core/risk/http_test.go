@@ -0,0 +1,52 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPScorer(t *testing.T) {
+	var ids []interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("Authorization = %q, want Bearer tok", got)
+		}
+		var req struct {
+			Transactions []map[string]interface{} `json:"transactions"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ids = nil
+		for _, tx := range req.Transactions {
+			ids = append(ids, tx["id"])
+		}
+		w.Write([]byte(`{"scores": [{"score": 80, "reasons": ["mixer"]}, null]}`))
+	}))
+	defer srv.Close()
+
+	s := &HTTPScorer{URL: srv.URL, Token: "tok"}
+	txs := []map[string]interface{}{{"id": "a"}, {"id": "b"}}
+	got, err := s.ScoreTxs(context.Background(), txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []interface{}{"a", "b"}) {
+		t.Errorf("scorer got transactions %v, want a and b", ids)
+	}
+	want := []*Score{{Score: 80, Reasons: []string{"mixer"}}, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	_, err = s.ScoreTxs(context.Background(), txs[:1])
+	if err == nil {
+		t.Error("expected error for mismatched score count")
+	}
+}
@@ -0,0 +1,153 @@
+// Package risk attaches risk scores to transactions as
+// the core indexes them, and optionally as they are
+// submitted, using a pluggable Scorer such as a compliance
+// service reached over HTTP.
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+)
+
+// Stages at which transactions are scored.
+const (
+	StageSubmitted = "submitted"
+	StageConfirmed = "confirmed"
+)
+
+// A Score is the risk assessment of a transaction.
+// What the score means, including its range, is up to
+// the Scorer.
+type Score struct {
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// A Scorer assesses the risk of transactions. Txs are
+// annotated transaction objects, as returned by
+// /list-transactions; those not yet in a block have no
+// block_id. ScoreTxs returns a score for each transaction,
+// in order, or nil for those it doesn't score.
+type Scorer interface {
+	ScoreTxs(ctx context.Context, txs []map[string]interface{}) ([]*Score, error)
+}
+
+// Nop is a Scorer that scores nothing.
+var Nop Scorer = nop{}
+
+type nop struct{}
+
+func (nop) ScoreTxs(ctx context.Context, txs []map[string]interface{}) ([]*Score, error) {
+	return make([]*Score, len(txs)), nil
+}
+
+// A Record is a score persisted for a transaction.
+type Record struct {
+	TxID     string    `json:"transaction_id"`
+	Stage    string    `json:"stage"`
+	Score    float64   `json:"score"`
+	Reasons  []string  `json:"reasons"`
+	ScoredAt time.Time `json:"scored_at"`
+}
+
+// Store scores transactions using Scorer, and persists
+// their scores in DB.
+type Store struct {
+	DB     pg.DB
+	Scorer Scorer
+}
+
+// AnnotateTxs is a query.Annotator. It scores txs, saves their
+// scores, and adds each to its transaction as risk_score.
+//
+// A failure to score doesn't fail indexing, which would retry
+// the whole block without end while the scorer is down: the
+// error is logged and the transactions are left unscored.
+func (s *Store) AnnotateTxs(ctx context.Context, txs []map[string]interface{}) error {
+	if s.Scorer == nil || len(txs) == 0 {
+		return nil
+	}
+	scores, err := s.Scorer.ScoreTxs(ctx, txs)
+	if err == nil && len(scores) != len(txs) {
+		err = fmt.Errorf("got %d scores for %d transactions", len(scores), len(txs))
+	}
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "scoring transactions"))
+		return nil
+	}
+
+	var (
+		hashes  pq.StringArray
+		stages  pq.StringArray
+		values  pq.Float64Array
+		reasons pq.StringArray
+	)
+	for i, tx := range txs {
+		sc := scores[i]
+		if sc == nil {
+			continue
+		}
+		tx["risk_score"] = sc
+		id, _ := tx["id"].(string)
+		stage := StageConfirmed
+		if _, ok := tx["block_id"]; !ok {
+			stage = StageSubmitted
+		}
+		rs := sc.Reasons
+		if rs == nil {
+			rs = []string{}
+		}
+		rsJSON, err := json.Marshal(rs)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		hashes = append(hashes, id)
+		stages = append(stages, stage)
+		values = append(values, sc.Score)
+		reasons = append(reasons, string(rsJSON))
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	const q = `
+		INSERT INTO risk_scores (tx_hash, stage, score, reasons)
+		SELECT unnest($1::text[]), unnest($2::text[]), unnest($3::float8[]), unnest($4::jsonb[])
+		ON CONFLICT (tx_hash, stage) DO UPDATE
+		SET score = excluded.score, reasons = excluded.reasons, scored_at = now()
+	`
+	_, err = s.DB.Exec(ctx, q, hashes, stages, values, reasons)
+	return errors.Wrap(err, "saving risk scores")
+}
+
+// Find returns the scores of the transaction with the
+// given hash, oldest first.
+func (s *Store) Find(ctx context.Context, txHash string) ([]*Record, error) {
+	const q = `
+		SELECT tx_hash, stage, score, reasons, scored_at FROM risk_scores
+		WHERE tx_hash=$1 ORDER BY scored_at
+	`
+	recs := []*Record{}
+	err := pg.ForQueryRows(ctx, s.DB, q, txHash, func(txHash, stage string, score float64, reasons []byte, scoredAt time.Time) error {
+		r := &Record{
+			TxID:     txHash,
+			Stage:    stage,
+			Score:    score,
+			ScoredAt: scoredAt,
+		}
+		err := json.Unmarshal(reasons, &r.Reasons)
+		if err != nil {
+			return errors.Wrap(err, "decoding reasons")
+		}
+		recs = append(recs, r)
+		return nil
+	})
+	return recs, errors.Wrap(err, "querying risk scores")
+}
@@ -0,0 +1,62 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/testutil"
+)
+
+type scorerFunc func(txs []map[string]interface{}) ([]*Score, error)
+
+func (f scorerFunc) ScoreTxs(ctx context.Context, txs []map[string]interface{}) ([]*Score, error) {
+	return f(txs)
+}
+
+func TestAnnotateTxs(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	s := &Store{DB: db, Scorer: scorerFunc(func(txs []map[string]interface{}) ([]*Score, error) {
+		return []*Score{{Score: 90, Reasons: []string{"sanctioned"}}, nil}, nil
+	})}
+
+	// Tx a is scored when submitted and when confirmed.
+	err := s.AnnotateTxs(ctx, []map[string]interface{}{{"id": "a"}, {"id": "b"}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	txs := []map[string]interface{}{{"id": "a", "block_id": "x"}, {"id": "b", "block_id": "x"}}
+	err = s.AnnotateTxs(ctx, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if sc, ok := txs[0]["risk_score"].(*Score); !ok || sc.Score != 90 {
+		t.Errorf("tx a risk_score = %v, want 90", txs[0]["risk_score"])
+	}
+	if _, ok := txs[1]["risk_score"]; ok {
+		t.Error("unscored tx b has a risk_score")
+	}
+
+	recs, err := s.Find(ctx, "a")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(recs) != 2 || recs[0].Stage != StageSubmitted || recs[1].Stage != StageConfirmed {
+		t.Fatalf("got records %v, want submitted and confirmed", recs)
+	}
+	if len(recs[1].Reasons) != 1 || recs[1].Reasons[0] != "sanctioned" {
+		t.Errorf("reasons = %v, want [sanctioned]", recs[1].Reasons)
+	}
+
+	// A failing scorer leaves transactions unscored.
+	s.Scorer = scorerFunc(func([]map[string]interface{}) ([]*Score, error) {
+		return nil, errors.New("unavailable")
+	})
+	txs = []map[string]interface{}{{"id": "c", "block_id": "x"}}
+	err = s.AnnotateTxs(ctx, txs)
+	if err != nil || txs[0]["risk_score"] != nil {
+		t.Errorf("AnnotateTxs with failing scorer = %v, risk_score %v", err, txs[0]["risk_score"])
+	}
+}
@@ -5,12 +5,38 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"chain/core/rpc"
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 )
 
+// submitRPC adds tx to the pool of pending transactions.
+// Peers name the tx's priority lane in the Chain-Tx-Priority
+// header; the quota of their network token caps how many
+// transactions they can place in the high-priority lane.
+func (h *Handler) submitRPC(ctx context.Context, tx *bc.Tx) error {
+	priority := httpjson.Request(ctx).Header.Get(rpc.HeaderTxPriority)
+	ctx, err := h.submissionContext(ctx, priority)
+	if err != nil {
+		return err
+	}
+	err = h.checkHalted(ctx)
+	if err != nil {
+		return err
+	}
+	err = h.checkBackpressure(ctx)
+	if err != nil {
+		return err
+	}
+	err = h.checkFrozen(ctx, &tx.TxData)
+	if err != nil {
+		return err
+	}
+	return h.Chain.AddTx(ctx, tx)
+}
+
 // getBlockRPC returns the block at the requested height.
 // If successful, it always returns at least one block,
 // waiting if necessary until one is created.
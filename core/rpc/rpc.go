@@ -7,12 +7,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"chain/crypto/ed25519"
 	"chain/errors"
+	"chain/log"
 	"chain/net/http/reqid"
 )
 
@@ -21,6 +24,7 @@ const (
 	HeaderBlockchainID = "Blockchain-ID"
 	HeaderCoreID       = "Chain-Core-ID"
 	HeaderTimeout      = "RPC-Timeout"
+	HeaderTxPriority   = "Chain-Tx-Priority"
 )
 
 // ErrWrongNetwork is returned when a peer's blockchain ID differs from
@@ -29,7 +33,7 @@ var ErrWrongNetwork = errors.New("connected to a peer on a different network")
 
 // A Client is a Chain RPC client. It performs RPCs over HTTP using JSON
 // request and responses. A Client must be configured with a secret token
-// to authenticate with other Cores on the network.
+// or a signing key to authenticate with other Cores on the network.
 type Client struct {
 	BaseURL      string
 	AccessToken  string
@@ -37,6 +41,11 @@ type Client struct {
 	BuildTag     string
 	BlockchainID string
 	CoreID       string
+
+	// Key, if set, is the public key identifying this core
+	// to its peers. Each request is signed by calling Sign.
+	Key  ed25519.PublicKey
+	Sign SignFunc
 }
 
 func (c Client) userAgent() string {
@@ -44,18 +53,85 @@ func (c Client) userAgent() string {
 		c.Username, c.BuildTag, c.BlockchainID)
 }
 
+type headerKey struct{}
+
+// WithHeader returns a context that causes RPCs made with it
+// to carry the given header field, in addition to the
+// fields set on every call.
+func WithHeader(ctx context.Context, key, value string) context.Context {
+	h := make(http.Header)
+	if prev, ok := ctx.Value(headerKey{}).(http.Header); ok {
+		for k, v := range prev {
+			h[k] = v
+		}
+	}
+	h.Set(key, value)
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
 // errStatusCode is an error returned when an rpc fails with a non-200
 // response code.
 type errStatusCode struct {
 	URL        string
 	StatusCode int
+
+	// Code, Message, Detail, Data, and Temporary are from the
+	// response body, if the peer said why the call failed.
+	Code      string
+	Message   string
+	Detail    string
+	Data      map[string]interface{}
+	Temporary bool
 }
 
 func (e errStatusCode) Error() string {
-	return fmt.Sprintf("Request to `%s` responded with %d %s",
+	s := fmt.Sprintf("Request to `%s` responded with %d %s",
 		e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		s += fmt.Sprintf(": %s %s", e.Code, e.Message)
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// ErrorCode returns the error code a peer gave in its response
+// to a failed call, or "" if err isn't from an error response.
+func ErrorCode(err error) string {
+	if e, ok := errors.Root(err).(errStatusCode); ok {
+		return e.Code
+	}
+	return ""
+}
+
+// ErrorData returns the data a peer gave in its response
+// to a failed call, or nil if err isn't from an error response.
+func ErrorData(err error) map[string]interface{} {
+	if e, ok := errors.Root(err).(errStatusCode); ok {
+		return e.Data
+	}
+	return nil
+}
+
+// IsTemporary reports whether a call that failed with err
+// might succeed if made again: either the peer couldn't be
+// reached over the network, or it answered that the failure
+// is temporary or with a server error it gave no reason for.
+func IsTemporary(err error) bool {
+	switch e := errors.Root(err).(type) {
+	case errStatusCode:
+		return e.Temporary || (e.Code == "" && e.StatusCode >= 500)
+	case net.Error:
+		return true
+	}
+	return false
 }
 
+// maxErrorBody limits how much of an error response
+// is read to find out what the error was.
+const maxErrorBody = 64 << 10
+
 // Call calls a remote procedure on another node, specified by the path.
 func (c *Client) Call(ctx context.Context, path string, request, response interface{}) error {
 	r, err := c.CallRaw(ctx, path, request)
@@ -78,12 +154,16 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 	}
 	u.Path = path
 
-	var bodyReader io.Reader
+	var (
+		body       []byte
+		bodyReader io.Reader
+	)
 	if request != nil {
 		var jsonBody bytes.Buffer
 		if err := json.NewEncoder(&jsonBody).Encode(request); err != nil {
 			return nil, errors.Wrap(err)
 		}
+		body = jsonBody.Bytes()
 		bodyReader = &jsonBody
 	}
 
@@ -110,6 +190,18 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 	req.Header.Set("User-Agent", c.userAgent())
 	req.Header.Set(HeaderBlockchainID, c.BlockchainID)
 	req.Header.Set(HeaderCoreID, c.CoreID)
+	if h, ok := ctx.Value(headerKey{}).(http.Header); ok {
+		for k, v := range h {
+			req.Header[k] = v
+		}
+	}
+
+	if c.Key != nil {
+		err = c.sign(ctx, req, body)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Propagate our deadline if we have one.
 	deadline, ok := ctx.Deadline()
@@ -117,7 +209,13 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 		req.Header.Set(HeaderTimeout, deadline.Sub(time.Now()).String())
 	}
 
+	start := time.Now()
 	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		log.Debug(ctx, log.SubsystemRPC, "url", cleanedURLString(u), "duration", time.Since(start), log.KeyError, err.Error())
+	} else {
+		log.Debug(ctx, log.SubsystemRPC, "url", cleanedURLString(u), "status", resp.StatusCode, "duration", time.Since(start))
+	}
 	if err != nil && ctx.Err() != nil { // check if it timed out
 		return nil, errors.Wrap(ctx.Err())
 	} else if err != nil {
@@ -130,10 +228,23 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		resp.Body.Close()
+		defer resp.Body.Close()
+		var body struct {
+			Code      string                 `json:"code"`
+			Message   string                 `json:"message"`
+			Detail    string                 `json:"detail"`
+			Data      map[string]interface{} `json:"data"`
+			Temporary bool                   `json:"temporary"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body) // best effort
 		return nil, errStatusCode{
 			URL:        cleanedURLString(u),
 			StatusCode: resp.StatusCode,
+			Code:       body.Code,
+			Message:    body.Message,
+			Detail:     body.Detail,
+			Data:       body.Data,
+			Temporary:  body.Temporary,
 		}
 	}
 	return resp.Body, nil
@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Header fields of signed requests
+const (
+	HeaderSigKey       = "Chain-Signature-Key"
+	HeaderSigTimestamp = "Chain-Signature-Timestamp"
+	HeaderSignature    = "Chain-Signature"
+)
+
+// DefaultMaxSkew is the default for Verifier.MaxSkew.
+const DefaultMaxSkew = 5 * time.Minute
+
+// ErrBadSignature is returned by Verify for requests
+// whose signature is missing, invalid, stale, or replayed.
+var ErrBadSignature = errors.New("invalid request signature")
+
+// A SignFunc signs msg with the private key
+// belonging to a Client's Key.
+type SignFunc func(ctx context.Context, msg []byte) ([]byte, error)
+
+// signingMessage returns the message signed for a request to path
+// on host, for blockchainID, at timestamp (in milliseconds since
+// 1970) with the given body. Binding the host keeps a request meant
+// for one core from being replayed to another. The body is
+// canonicalized by removing insignificant whitespace, so it may be
+// re-encoded in transit.
+func signingMessage(host, path, blockchainID string, timestamp uint64, body []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if len(body) > 0 {
+		err := json.Compact(&compact, body)
+		if err != nil {
+			return nil, errors.Wrap(err, "canonicalizing body")
+		}
+	}
+	var bodyHash [32]byte
+	sha3pool.Sum256(bodyHash[:], compact.Bytes())
+
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write([]byte("chain rpc request\n"))
+	h.Write([]byte(host + "\n"))
+	h.Write([]byte(path + "\n"))
+	h.Write([]byte(blockchainID + "\n"))
+	var ts [8]byte
+	binary.LittleEndian.PutUint64(ts[:], timestamp)
+	h.Write(ts[:])
+	h.Write(bodyHash[:])
+	var msg [32]byte
+	h.Read(msg[:])
+	return msg[:], nil
+}
+
+// sign sets the signature header fields of req,
+// which has the given body, using c's key.
+func (c *Client) sign(ctx context.Context, req *http.Request, body []byte) error {
+	timestamp := bc.Millis(time.Now())
+	msg, err := signingMessage(req.Host, req.URL.Path, c.BlockchainID, timestamp, body)
+	if err != nil {
+		return err
+	}
+	sig, err := c.Sign(ctx, msg)
+	if err != nil {
+		return errors.Wrap(err, "signing request")
+	}
+	req.Header.Set(HeaderSigKey, hex.EncodeToString(c.Key))
+	req.Header.Set(HeaderSigTimestamp, strconv.FormatUint(timestamp, 10))
+	req.Header.Set(HeaderSignature, hex.EncodeToString(sig))
+	return nil
+}
+
+// IsSigned returns whether req carries a request signature.
+func IsSigned(req *http.Request) bool {
+	return req.Header.Get(HeaderSignature) != ""
+}
+
+// A Verifier checks the signatures of requests from other cores.
+// It rejects requests signed more than MaxSkew before or after
+// the current time, and requests it has already seen, so a request
+// intercepted in transit cannot be replayed. Requests are told
+// apart by signing key, timestamp, and signed message, not by
+// signature, since a request's signature can be altered without
+// invalidating it. They are remembered in memory, for each process.
+type Verifier struct {
+	BlockchainID string
+	MaxSkew      time.Duration // if zero, DefaultMaxSkew
+
+	mu      sync.Mutex
+	seen    map[replayKey]bool
+	expires expiryHeap // of the keys in seen
+}
+
+// replayKey identifies a signed request.
+type replayKey struct {
+	key       [ed25519.PublicKeySize]byte
+	timestamp uint64
+	msg       [32]byte
+}
+
+type expiring struct {
+	key replayKey
+	exp time.Time
+}
+
+// expiryHeap orders replay keys by expiry, soonest first,
+// so expired ones can be forgotten without scanning the rest.
+type expiryHeap []expiring
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].exp.Before(h[j].exp) }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(expiring)) }
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Verify checks the signature of req, which has the given body,
+// and returns the public key that signed it.
+func (v *Verifier) Verify(req *http.Request, body []byte) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(req.Header.Get(HeaderSigKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrBadSignature, "bad signature key")
+	}
+	sig, err := hex.DecodeString(req.Header.Get(HeaderSignature))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadSignature, "bad signature encoding")
+	}
+	timestamp, err := strconv.ParseUint(req.Header.Get(HeaderSigTimestamp), 10, 64)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadSignature, "bad signature timestamp")
+	}
+
+	maxSkew := v.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	now := time.Now()
+	signedAt := time.Unix(0, int64(timestamp)*int64(time.Millisecond))
+	if signedAt.Before(now.Add(-maxSkew)) || signedAt.After(now.Add(maxSkew)) {
+		return nil, errors.WithDetailf(ErrBadSignature, "request signed at %s, outside allowed clock skew", signedAt.UTC())
+	}
+
+	msg, err := signingMessage(req.Host, req.URL.Path, v.BlockchainID, timestamp, body)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadSignature, errors.Detail(err))
+	}
+	if !ed25519.Verify(key, msg, sig) {
+		return nil, errors.WithDetail(ErrBadSignature, "signature does not match request")
+	}
+
+	rk := replayKey{timestamp: timestamp}
+	copy(rk.key[:], key)
+	copy(rk.msg[:], msg)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.seen == nil {
+		v.seen = make(map[replayKey]bool)
+	}
+	for len(v.expires) > 0 && now.After(v.expires[0].exp) {
+		delete(v.seen, heap.Pop(&v.expires).(expiring).key)
+	}
+	if v.seen[rk] {
+		return nil, errors.WithDetail(ErrBadSignature, "request was replayed")
+	}
+	v.seen[rk] = true
+	heap.Push(&v.expires, expiring{rk, signedAt.Add(maxSkew)})
+	return ed25519.PublicKey(key), nil
+}
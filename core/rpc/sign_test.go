@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestSignedRequest(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{BlockchainID: "chain1"}
+
+	var reqs []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+		bodies = append(bodies, body)
+		rw.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		BaseURL:      server.URL,
+		BlockchainID: "chain1",
+		Key:          pub,
+		Sign: func(ctx context.Context, msg []byte) ([]byte, error) {
+			return ed25519.Sign(prv, msg), nil
+		},
+	}
+	err = client.Call(context.Background(), "/rpc/get-block", map[string]uint64{"height": 7}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, body := reqs[0], bodies[0]
+	if !IsSigned(req) {
+		t.Fatal("request is not signed")
+	}
+
+	// The body may be re-encoded in transit.
+	reencoded := append([]byte("  "), bytes.Replace(body, []byte(":"), []byte(": "), -1)...)
+	got, err := v.Verify(req, reencoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, pub) {
+		t.Errorf("Verify() = %x, want %x", got, pub)
+	}
+
+	_, err = v.Verify(req, body)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(replayed) = %v, want %v", err, ErrBadSignature)
+	}
+
+	// A replay with S+L in place of S is still a valid
+	// signature of the same request.
+	sig, err := hex.DecodeString(req.Header.Get(HeaderSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	malleated := addL(sig)
+	if !ed25519.Verify(pub, mustSigningMessage(t, req, body), malleated) {
+		t.Fatal("malleated signature does not verify")
+	}
+	req.Header.Set(HeaderSignature, hex.EncodeToString(malleated))
+	_, err = v.Verify(req, body)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(replayed, malleated signature) = %v, want %v", err, ErrBadSignature)
+	}
+	req.Header.Set(HeaderSignature, hex.EncodeToString(sig))
+
+	req.Host = "other.example.com"
+	_, err = (&Verifier{BlockchainID: "chain1"}).Verify(req, body)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(other host) = %v, want %v", err, ErrBadSignature)
+	}
+	req.Host = req.URL.Host
+
+	tampered := bytes.Replace(body, []byte("7"), []byte("8"), -1)
+	_, err = (&Verifier{BlockchainID: "chain1"}).Verify(req, tampered)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(tampered body) = %v, want %v", err, ErrBadSignature)
+	}
+
+	_, err = (&Verifier{BlockchainID: "chain2"}).Verify(req, body)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(other blockchain) = %v, want %v", err, ErrBadSignature)
+	}
+
+	stale := bc.Millis(time.Now().Add(-time.Hour))
+	req.Header.Set(HeaderSigTimestamp, strconv.FormatUint(stale, 10))
+	_, err = (&Verifier{BlockchainID: "chain1"}).Verify(req, body)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(stale) = %v, want %v", err, ErrBadSignature)
+	}
+}
+
+func TestVerifierExpiry(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{BlockchainID: "chain1", MaxSkew: time.Minute}
+	for i := 0; i < 3; i++ {
+		signedAt := time.Now().Add(time.Duration(i-1) * 50 * time.Second)
+		req := signedRequest(t, pub, prv, signedAt, "/rpc/get-block", "{}")
+		_, err = v.Verify(req, []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(v.seen) != 3 || len(v.expires) != 3 {
+		t.Fatalf("remembered %d requests (%d expiries), want 3", len(v.seen), len(v.expires))
+	}
+
+	// Expire the oldest request, and verify another
+	// so the verifier forgets it.
+	v.expires[0].exp = time.Now().Add(-time.Second)
+	req := signedRequest(t, pub, prv, time.Now(), "/rpc/submit", "{}")
+	_, err = v.Verify(req, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.seen) != 3 || len(v.expires) != 3 {
+		t.Errorf("remembered %d requests (%d expiries), want 3", len(v.seen), len(v.expires))
+	}
+}
+
+// signedRequest returns a request to path on example.com with
+// the given body, signed at signedAt with prv for chain1.
+func signedRequest(t *testing.T, pub ed25519.PublicKey, prv ed25519.PrivateKey, signedAt time.Time, path, body string) *http.Request {
+	req, err := http.NewRequest("POST", "http://example.com"+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := bc.Millis(signedAt)
+	msg, err := signingMessage(req.Host, path, "chain1", timestamp, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(HeaderSigKey, hex.EncodeToString(pub))
+	req.Header.Set(HeaderSigTimestamp, strconv.FormatUint(timestamp, 10))
+	req.Header.Set(HeaderSignature, hex.EncodeToString(ed25519.Sign(prv, msg)))
+	return req
+}
+
+func mustSigningMessage(t *testing.T, req *http.Request, body []byte) []byte {
+	timestamp, err := strconv.ParseUint(req.Header.Get(HeaderSigTimestamp), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := signingMessage(req.Host, req.URL.Path, "chain1", timestamp, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// addL returns sig with the group order L added to its S,
+// which ed25519.Verify accepts as the same signature.
+func addL(sig []byte) []byte {
+	l, _ := new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	le := func(b []byte) []byte { // reverses the byte order
+		r := make([]byte, len(b))
+		for i := range b {
+			r[len(b)-1-i] = b[i]
+		}
+		return r
+	}
+	s := new(big.Int).SetBytes(le(sig[32:]))
+	s.Add(s, l)
+	sb := s.Bytes()
+	out := append([]byte(nil), sig[:32]...)
+	return append(out, le(append(make([]byte, 32-len(sb)), sb...))...)
+}
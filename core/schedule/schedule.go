@@ -0,0 +1,431 @@
+// Package schedule makes payments at a future time,
+// once or repeatedly, as standing orders.
+//
+// A schedule names the payments to make, the keys to sign them
+// with, when to make them first, and how often to repeat them.
+// When a schedule is due, the leader process builds one
+// transaction making all its payments, signs it, submits it, and
+// waits for it to be confirmed. Each attempt is recorded as a run.
+// A failed attempt is retried after the schedule's retry delay,
+// up to its maximum number of attempts; after that, a one-time
+// schedule fails, and a recurring one skips to its next occurrence.
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/account"
+	"chain/core/tenant"
+	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+const (
+	StatusActive   = "active"   // waiting for its next attempt
+	StatusDone     = "done"     // a one-time schedule that succeeded
+	StatusFailed   = "failed"   // a one-time schedule that used up its attempts
+	StatusCanceled = "canceled" // canceled before it finished
+)
+
+const (
+	defaultLimit       = 100
+	defaultMaxAttempts = 3
+	defaultRetryDelay  = time.Minute
+
+	// minInterval is the shortest interval
+	// a recurring schedule may have.
+	minInterval = time.Minute
+
+	// maxRuns is the number of recent runs Find returns.
+	maxRuns = 100
+)
+
+var (
+	// ErrBadSchedule is returned by Create
+	// for incomplete or malformed schedules.
+	ErrBadSchedule = errors.New("invalid schedule")
+
+	// ErrNotActive is returned by Cancel for a
+	// schedule that is done, failed, or canceled.
+	ErrNotActive = errors.New("schedule is not active")
+)
+
+// Schedule is a set of payments to make at Due, and again
+// every Interval after that if Interval is nonzero.
+type Schedule struct {
+	ID          string             `json:"id"`
+	Payments    []account.Payment  `json:"payments"`
+	XPubs       []string           `json:"xpubs"`
+	Due         time.Time          `json:"due"`
+	NextAttempt time.Time          `json:"next_attempt"`
+	Interval    chainjson.Duration `json:"interval"`
+	MaxAttempts int                `json:"max_attempts"`
+	RetryDelay  chainjson.Duration `json:"retry_delay"`
+	Attempts    int                `json:"attempts"`
+	Status      string             `json:"status"`
+	Runs        []*Run             `json:"runs,omitempty"`
+
+	tenant  string
+	pending *signing.Template
+}
+
+// Run is the outcome of one attempt to make
+// the payments of a schedule's occurrence.
+type Run struct {
+	Due     time.Time `json:"due"`
+	Attempt int       `json:"attempt"`
+	TxID    *bc.Hash  `json:"transaction_id"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// BuildFunc builds a transaction making payments
+// and signs it with the keys xpubs.
+type BuildFunc func(ctx context.Context, payments []account.Payment, xpubs []string) (*signing.Template, error)
+
+// SubmitFunc submits a signed transaction and waits for it to
+// be confirmed. It returns an error whose root is
+// txbuilder.ErrRejected if the transaction can no longer be
+// confirmed.
+type SubmitFunc func(ctx context.Context, tpl *signing.Template) error
+
+// Scheduler stores schedules and makes their payments.
+type Scheduler struct {
+	DB pg.DB
+}
+
+// Create records schedule sch, filling in its defaults,
+// and returns it.
+func (s *Scheduler) Create(ctx context.Context, sch *Schedule) (*Schedule, error) {
+	if len(sch.Payments) == 0 {
+		return nil, errors.WithDetail(ErrBadSchedule, "payments are required")
+	}
+	if len(sch.XPubs) == 0 {
+		return nil, errors.WithDetail(ErrBadSchedule, "xpubs are required")
+	}
+	if sch.Due.IsZero() {
+		return nil, errors.WithDetail(ErrBadSchedule, "due is required")
+	}
+	if sch.Interval.Duration != 0 && sch.Interval.Duration < minInterval {
+		return nil, errors.WithDetailf(ErrBadSchedule, "interval must be 0 or at least %s", minInterval)
+	}
+	if sch.RetryDelay.Duration < 0 {
+		return nil, errors.WithDetail(ErrBadSchedule, "retry_delay must not be negative")
+	}
+	if sch.MaxAttempts < 0 {
+		return nil, errors.WithDetail(ErrBadSchedule, "max_attempts must not be negative")
+	}
+	if sch.MaxAttempts == 0 {
+		sch.MaxAttempts = defaultMaxAttempts
+	}
+	if sch.RetryDelay.Duration == 0 {
+		sch.RetryDelay.Duration = defaultRetryDelay
+	}
+	payments, err := json.Marshal(sch.Payments)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	sch.NextAttempt = sch.Due
+	sch.Status = StatusActive
+	const q = `
+		INSERT INTO scheduled_payments (payments, xpubs, due_ms, next_attempt_ms,
+			interval_ms, max_attempts, retry_delay_ms, status, tenant)
+		VALUES ($1, $2, $3, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	err = s.DB.QueryRow(ctx, q, payments, pq.StringArray(sch.XPubs), bc.Millis(sch.Due),
+		millis(sch.Interval.Duration), sch.MaxAttempts, millis(sch.RetryDelay.Duration),
+		sch.Status, tenant.FromContext(ctx)).Scan(&sch.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting schedule")
+	}
+	return sch, nil
+}
+
+// Find returns the schedule with the given ID,
+// with its most recent runs.
+func (s *Scheduler) Find(ctx context.Context, id string) (*Schedule, error) {
+	schedules, err := s.list(ctx, `id=$2`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "schedule %s", id)
+	}
+	sch := schedules[0]
+
+	const q = `
+		SELECT due_ms, attempt, tx_hash, error, created_at
+		FROM scheduled_payment_runs
+		WHERE schedule_id=$1
+		ORDER BY created_at DESC, attempt DESC
+		LIMIT $2
+	`
+	err = pg.ForQueryRows(ctx, s.DB, q, id, maxRuns, func(dueMS uint64, attempt int, txHash *bc.Hash, errStr sql.NullString, createdAt time.Time) {
+		sch.Runs = append(sch.Runs, &Run{
+			Due:     fromMillis(dueMS),
+			Attempt: attempt,
+			TxID:    txHash,
+			Error:   errStr.String,
+			Time:    createdAt,
+		})
+	})
+	return sch, errors.Wrap(err, "listing runs")
+}
+
+// List returns schedules with the given status, or with
+// any status if status is empty, in pages ordered by ID.
+func (s *Scheduler) List(ctx context.Context, status, after string, limit int) ([]*Schedule, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	const where = `($2='' OR status=$2) AND ($3='' OR id<$3) ORDER BY id DESC LIMIT $4`
+	schedules, err := s.list(ctx, where, status, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(schedules) > 0 {
+		after = schedules[len(schedules)-1].ID
+	}
+	return schedules, after, nil
+}
+
+// Cancel stops the active schedule with the given ID.
+// A transaction already submitted for it may still be
+// confirmed.
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	const q = `
+		UPDATE scheduled_payments SET status=$3, pending_tx=NULL
+		WHERE id=$1 AND ($2='' OR tenant=$2) AND status=$4
+	`
+	res, err := s.DB.Exec(ctx, q, id, tenant.FromContext(ctx), StatusCanceled, StatusActive)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		sch, err := s.Find(ctx, id)
+		if err != nil {
+			return err
+		}
+		return errors.WithDetailf(ErrNotActive, "schedule %s is %s", id, sch.Status)
+	}
+	return nil
+}
+
+// list returns the schedules visible to the tenant that ctx acts
+// for that match where. The tenant is parameter $1 of the query,
+// and args are parameters $2 and up.
+func (s *Scheduler) list(ctx context.Context, where string, args ...interface{}) ([]*Schedule, error) {
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+	q := `
+		SELECT id, payments, xpubs, due_ms, next_attempt_ms, interval_ms,
+			max_attempts, retry_delay_ms, attempts, status, tenant, pending_tx
+		FROM scheduled_payments
+		WHERE ($1='' OR tenant=$1) AND ` + where
+	rows, err := s.DB.Query(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		var (
+			sch                                Schedule
+			payments, pending                  []byte
+			xpubs                              pq.StringArray
+			dueMS, nextMS, intervalMS, retryMS uint64
+		)
+		err := rows.Scan(&sch.ID, &payments, &xpubs, &dueMS, &nextMS, &intervalMS,
+			&sch.MaxAttempts, &retryMS, &sch.Attempts, &sch.Status, &sch.tenant, &pending)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		err = json.Unmarshal(payments, &sch.Payments)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding payments")
+		}
+		if pending != nil {
+			sch.pending = new(signing.Template)
+			err = json.Unmarshal(pending, sch.pending)
+			if err != nil {
+				return nil, errors.Wrap(err, "decoding pending transaction")
+			}
+		}
+		sch.XPubs = xpubs
+		sch.Due, sch.NextAttempt = fromMillis(dueMS), fromMillis(nextMS)
+		sch.Interval.Duration = time.Duration(intervalMS) * time.Millisecond
+		sch.RetryDelay.Duration = time.Duration(retryMS) * time.Millisecond
+		schedules = append(schedules, &sch)
+	}
+	return schedules, errors.Wrap(rows.Err())
+}
+
+// Process attempts each due schedule every period,
+// until ctx is done.
+func (s *Scheduler) Process(ctx context.Context, period time.Duration, build BuildFunc, submit SubmitFunc) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, Process exiting")
+			return
+		case <-ticks:
+			err := s.processDue(ctx, time.Now(), build, submit)
+			if err != nil && ctx.Err() == nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+// processDue attempts each active schedule
+// whose next attempt is at or before now.
+func (s *Scheduler) processDue(ctx context.Context, now time.Time, build BuildFunc, submit SubmitFunc) error {
+	const where = `status=$2 AND next_attempt_ms<=$3 ORDER BY next_attempt_ms, id`
+	schedules, err := s.list(ctx, where, StatusActive, bc.Millis(now))
+	if err != nil {
+		return err
+	}
+	for _, sch := range schedules {
+		err = s.attempt(ctx, sch, now, build, submit)
+		if err != nil {
+			return errors.Wrapf(err, "schedule %s", sch.ID)
+		}
+	}
+	return nil
+}
+
+// attempt makes the payments of sch's current occurrence and
+// records the outcome. The signed transaction is stored before
+// it is submitted, so a retry submits the same transaction
+// again, and can't pay twice, until the transaction is
+// rejected.
+func (s *Scheduler) attempt(ctx context.Context, sch *Schedule, now time.Time, build BuildFunc, submit SubmitFunc) error {
+	tenantCtx := tenant.NewContext(ctx, sch.tenant)
+	tpl := sch.pending
+	var err error
+	if tpl == nil {
+		tpl, err = build(tenantCtx, sch.Payments, sch.XPubs)
+		if err == nil {
+			err = s.setPending(ctx, sch.ID, tpl)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err == nil {
+		err = submit(tenantCtx, tpl)
+	}
+	if ctx.Err() != nil {
+		// Leadership was lost; the next
+		// leader will try again.
+		return ctx.Err()
+	}
+	if err != nil {
+		log.Error(ctx, err, "schedule", sch.ID)
+	}
+	return s.record(ctx, sch, tpl, err, now)
+}
+
+func (s *Scheduler) setPending(ctx context.Context, id string, tpl *signing.Template) error {
+	data, err := json.Marshal(tpl)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	_, err = s.DB.Exec(ctx, `UPDATE scheduled_payments SET pending_tx=$2 WHERE id=$1`, id, data)
+	return errors.Wrap(err, "storing pending transaction")
+}
+
+// record stores the run for an attempt of sch that ended with
+// runErr, and moves sch on to its next attempt.
+func (s *Scheduler) record(ctx context.Context, sch *Schedule, tpl *signing.Template, runErr error, now time.Time) error {
+	var (
+		attempt = sch.Attempts + 1
+		txHash  *string
+		errStr  *string
+		pending interface{} // NULL, unless the transaction may still be confirmed
+	)
+	if tpl != nil {
+		h := tpl.Transaction.Hash().String()
+		txHash = &h
+	}
+	if runErr != nil {
+		e := runErr.Error()
+		errStr = &e
+	}
+
+	next := *sch
+	switch {
+	case runErr == nil:
+		next.advance(now)
+	case attempt < sch.MaxAttempts:
+		next.Attempts = attempt
+		next.NextAttempt = now.Add(sch.RetryDelay.Duration)
+		if tpl != nil && errors.Root(runErr) != txbuilder.ErrRejected {
+			data, err := json.Marshal(tpl)
+			if err != nil {
+				return errors.Wrap(err)
+			}
+			pending = data
+		}
+	case sch.Interval.Duration > 0:
+		// Skip this occurrence.
+		next.advance(now)
+	default:
+		next.Status = StatusFailed
+	}
+
+	const q = `
+		WITH run AS (
+			INSERT INTO scheduled_payment_runs (schedule_id, due_ms, attempt, tx_hash, error)
+			VALUES ($1, $2, $3, $4, $5)
+		)
+		UPDATE scheduled_payments
+		SET due_ms=$6, next_attempt_ms=$7, attempts=$8, status=$9, pending_tx=$10
+		WHERE id=$1 AND status=$11
+	`
+	_, err := s.DB.Exec(ctx, q, sch.ID, bc.Millis(sch.Due), attempt, txHash, errStr,
+		bc.Millis(next.Due), bc.Millis(next.NextAttempt), next.Attempts, next.Status,
+		pending, StatusActive)
+	return errors.Wrap(err, "recording run")
+}
+
+// advance moves sch past its current occurrence: to the
+// first later occurrence after now, or, for a one-time
+// schedule, to done.
+func (sch *Schedule) advance(now time.Time) {
+	sch.Attempts = 0
+	if sch.Interval.Duration <= 0 {
+		sch.Status = StatusDone
+		return
+	}
+	if !sch.Due.After(now) {
+		n := now.Sub(sch.Due)/sch.Interval.Duration + 1
+		sch.Due = sch.Due.Add(n * sch.Interval.Duration)
+	}
+	sch.NextAttempt = sch.Due
+}
+
+func millis(d time.Duration) uint64 {
+	return uint64(d / time.Millisecond)
+}
+
+func fromMillis(ms uint64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+}
@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	chainerrors "chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestSchedule(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	s := &Scheduler{DB: db}
+
+	payments := []account.Payment{{
+		AssetAmount:     bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 5},
+		SourceAccountID: "acc1",
+		AccountID:       "acc2",
+	}}
+	xpubs := []string{testutil.TestXPub.String()}
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := s.Create(ctx, &Schedule{XPubs: xpubs, Due: start})
+	if chainerrors.Root(err) != ErrBadSchedule {
+		t.Errorf("Create(no payments) = %v want %v", err, ErrBadSchedule)
+	}
+	_, err = s.Create(ctx, &Schedule{
+		Payments: payments,
+		XPubs:    xpubs,
+		Due:      start,
+		Interval: chainjson.Duration{Duration: time.Millisecond},
+	})
+	if chainerrors.Root(err) != ErrBadSchedule {
+		t.Errorf("Create(1ms interval) = %v want %v", err, ErrBadSchedule)
+	}
+
+	once, err := s.Create(ctx, &Schedule{Payments: payments, XPubs: xpubs, Due: start, MaxAttempts: 2})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	monthly, err := s.Create(ctx, &Schedule{
+		Payments: payments,
+		XPubs:    xpubs,
+		Due:      start,
+		Interval: chainjson.Duration{Duration: 30 * 24 * time.Hour},
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	var (
+		builds  int
+		submits []bc.Hash
+		fail    error
+	)
+	build := func(ctx context.Context, p []account.Payment, x []string) (*signing.Template, error) {
+		builds++
+		return &signing.Template{Transaction: &bc.TxData{Version: 1, MinTime: uint64(builds)}}, nil
+	}
+	submit := func(ctx context.Context, tpl *signing.Template) error {
+		submits = append(submits, tpl.Transaction.Hash())
+		return fail
+	}
+
+	// Nothing is due before the start.
+	err = s.processDue(ctx, start.Add(-time.Second), build, submit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if builds != 0 {
+		t.Fatalf("built %d transactions before the start", builds)
+	}
+
+	// Both fail, and are retried with the same transactions.
+	fail = errors.New("submit failed")
+	err = s.processDue(ctx, start, build, submit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = s.processDue(ctx, start.Add(time.Minute), build, submit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if builds != 2 || len(submits) != 4 || submits[0] != submits[2] || submits[1] != submits[3] {
+		t.Errorf("after retries, built %d and submitted %v, want 2 built and each resubmitted", builds, submits)
+	}
+
+	// The one-time schedule has used up its attempts.
+	got, err := s.Find(ctx, once.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Status != StatusFailed || len(got.Runs) != 2 || got.Runs[0].Error == "" {
+		t.Errorf("one-time schedule = %+v, want failed with 2 runs", got)
+	}
+
+	// The monthly one succeeds on its third attempt,
+	// and moves on to next month.
+	fail = nil
+	err = s.processDue(ctx, start.Add(2*time.Minute), build, submit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = s.Find(ctx, monthly.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantDue := start.Add(30 * 24 * time.Hour)
+	if got.Status != StatusActive || !got.Due.Equal(wantDue) || got.Attempts != 0 || got.pending != nil {
+		t.Errorf("monthly schedule = %+v, want active and due %s", got, wantDue)
+	}
+	if len(got.Runs) != 3 || got.Runs[0].Error != "" || got.Runs[0].TxID == nil || *got.Runs[0].TxID != submits[len(submits)-1] {
+		t.Errorf("monthly runs = %+v, want 3 ending in success", got.Runs)
+	}
+
+	// A rejected transaction is rebuilt.
+	fail = chainerrors.Wrap(txbuilder.ErrRejected)
+	err = s.processDue(ctx, wantDue, build, submit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = s.processDue(ctx, wantDue.Add(time.Minute), build, submit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if builds != 4 {
+		t.Errorf("built %d transactions, want 4", builds)
+	}
+
+	err = s.Cancel(ctx, monthly.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = s.Cancel(ctx, monthly.ID)
+	if chainerrors.Root(err) != ErrNotActive {
+		t.Errorf("Cancel(canceled) = %v want %v", err, ErrNotActive)
+	}
+	list, _, err := s.List(ctx, StatusActive, "", 0)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(list) != 0 {
+		t.Errorf("got %d active schedules, want 0", len(list))
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{start.Add(-time.Second), start},
+		{start, start.Add(time.Hour)},
+		{start.Add(time.Hour - 1), start.Add(time.Hour)},
+		{start.Add(100000*time.Hour + 1), start.Add(100001 * time.Hour)},
+	}
+	for _, c := range cases {
+		sch := &Schedule{Due: start, Interval: chainjson.Duration{Duration: time.Hour}, Attempts: 2}
+		sch.advance(c.now)
+		if !sch.Due.Equal(c.want) || !sch.NextAttempt.Equal(c.want) || sch.Attempts != 0 {
+			t.Errorf("advance(%s) = due %s, next %s, attempts %d, want %s", c.now, sch.Due, sch.NextAttempt, sch.Attempts, c.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"chain/core/account"
+	"chain/core/schedule"
+	"chain/core/tenant"
+	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// POST /create-scheduled-payment
+//
+// The payments are made at due, and again every interval after
+// that if interval is given, in one transaction signed with the
+// Core's keys among xpubs. A failed attempt is retried after
+// retry_delay (default 1 minute), up to max_attempts (default 3)
+// times. The payments are signed with the MockHSM, so only the
+// default tenant, which owns its keys, may schedule them.
+func (h *Handler) createScheduledPayment(ctx context.Context, in struct {
+	Payments    []account.Payment  `json:"payments"`
+	XPubs       []string           `json:"xpubs"`
+	Due         time.Time          `json:"due"`
+	Interval    chainjson.Duration `json:"interval"`
+	MaxAttempts int                `json:"max_attempts"`
+	RetryDelay  chainjson.Duration `json:"retry_delay"`
+}) (*schedule.Schedule, error) {
+	if tenant.FromContext(ctx) != tenant.Default {
+		return nil, errOtherTenant
+	}
+	// Check the payments now, rather than when they're due.
+	batches, err := h.Accounts.BatchPayments(in.Payments, account.BatchLimits{})
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) > 1 {
+		return nil, errors.WithDetail(schedule.ErrBadSchedule, "payments don't fit in one transaction")
+	}
+	return h.Schedules.Create(ctx, &schedule.Schedule{
+		Payments:    in.Payments,
+		XPubs:       in.XPubs,
+		Due:         in.Due,
+		Interval:    in.Interval,
+		MaxAttempts: in.MaxAttempts,
+		RetryDelay:  in.RetryDelay,
+	})
+}
+
+// POST /list-scheduled-payments
+func (h *Handler) listScheduledPayments(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	schedules, after, err := h.Schedules.List(ctx, query.Status, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(schedules),
+		LastPage: len(schedules) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /get-scheduled-payment
+//
+// Responds with the schedule and the outcomes of its most
+// recent attempts.
+func (h *Handler) getScheduledPayment(ctx context.Context, in struct {
+	ID string `json:"id"`
+}) (*schedule.Schedule, error) {
+	return h.Schedules.Find(ctx, in.ID)
+}
+
+// POST /cancel-scheduled-payment
+func (h *Handler) cancelScheduledPayment(ctx context.Context, in struct {
+	ID string `json:"id"`
+}) error {
+	return h.Schedules.Cancel(ctx, in.ID)
+}
+
+// ProcessScheduledPayments makes scheduled payments as they
+// come due, checking every period, until ctx is done. It must
+// run only in the leader process, which holds the reservations
+// of unspent outputs.
+func (h *Handler) ProcessScheduledPayments(ctx context.Context, period time.Duration) {
+	h.Schedules.Process(ctx, period, h.buildScheduledPayment, h.submitScheduledPayment)
+}
+
+func (h *Handler) buildScheduledPayment(ctx context.Context, payments []account.Payment, xpubs []string) (*signing.Template, error) {
+	batches, err := h.Accounts.BatchPayments(payments, account.BatchLimits{})
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) != 1 {
+		return nil, errors.WithDetail(schedule.ErrBadSchedule, "payments don't fit in one transaction")
+	}
+	tpl, err := h.buildActions(ctx, nil, batches[0].Actions, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = h.mockhsmSign(ctx, tpl, xpubs)
+	return tpl, errors.Wrap(err, "signing")
+}
+
+// submitScheduledPayment submits tpl and waits for it to be
+// confirmed. If tpl has expired since an earlier attempt
+// submitted it, it can't be submitted again, but it may have
+// been confirmed before it expired, so the blocks since that
+// attempt are searched for it instead.
+func (h *Handler) submitScheduledPayment(ctx context.Context, tpl *signing.Template) error {
+	if txbuilder.CheckExpiry(tpl.Transaction, time.Now()) == nil {
+		err := h.checkHalted(ctx)
+		if err != nil {
+			return err
+		}
+		return h.finalizeTxWait(ctx, tpl, "confirmed")
+	}
+	tx := bc.NewTx(*tpl.Transaction)
+	height, err := recordSubmittedTx(ctx, h.DB, tx.Hash, h.Chain.Height())
+	if err != nil {
+		return errors.Wrap(err, "looking up tx submitted height")
+	}
+	_, err = waitForTxInBlock(ctx, h.Chain, tx, height)
+	return err
+}
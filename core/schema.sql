@@ -141,6 +141,22 @@ END;
 $$;
 
 
+--
+-- Name: reference_data_tsvector(jsonb); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION reference_data_tsvector(jsonb) RETURNS tsvector
+    LANGUAGE sql IMMUTABLE
+    AS $_$
+	SELECT to_tsvector('simple', COALESCE(string_agg(r::text, ' '), ''))
+	FROM (
+		SELECT $1->'reference_data' AS r
+		UNION ALL SELECT jsonb_array_elements($1->'inputs')->'reference_data'
+		UNION ALL SELECT jsonb_array_elements($1->'outputs')->'reference_data'
+	) refdata
+$_$;
+
+
 SET default_tablespace = '';
 
 SET default_with_oids = false;
@@ -154,7 +170,13 @@ CREATE TABLE access_tokens (
     sort_id text DEFAULT next_chain_id('at'::text),
     type access_token_type NOT NULL,
     hashed_secret bytea NOT NULL,
-    created timestamp with time zone DEFAULT now() NOT NULL
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    priority_quota integer DEFAULT 0 NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL,
+    public_key bytea,
+    rate_limit integer DEFAULT 0 NOT NULL,
+    last_seen_at timestamp with time zone,
+    last_seen_addr text
 );
 
 
@@ -178,7 +200,52 @@ CREATE TABLE account_control_programs (
     signer_id text NOT NULL,
     key_index bigint NOT NULL,
     control_program bytea NOT NULL,
-    change boolean NOT NULL
+    change boolean NOT NULL,
+    single_use boolean DEFAULT false NOT NULL,
+    use_count integer DEFAULT 0 NOT NULL,
+    last_used_height bigint
+);
+
+
+--
+-- Name: account_limits; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_limits (
+    account_id text NOT NULL,
+    asset_id text NOT NULL,
+    max_per_transaction bigint,
+    max_per_day bigint
+);
+
+
+--
+-- Name: account_rescans; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_rescans (
+    id text DEFAULT next_chain_id('rscn'::text) NOT NULL,
+    account_id text NOT NULL,
+    control_programs bytea[] NOT NULL,
+    from_height bigint NOT NULL,
+    scanned_height bigint NOT NULL,
+    status text NOT NULL,
+    error text,
+    tenant text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: account_spends; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_spends (
+    tx_hash text NOT NULL,
+    account_id text NOT NULL,
+    asset_id text NOT NULL,
+    amount bigint NOT NULL,
+    spent_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
@@ -205,7 +272,10 @@ CREATE TABLE account_utxos (
 CREATE TABLE accounts (
     account_id text NOT NULL,
     tags jsonb,
-    alias text
+    alias text,
+    tenant text DEFAULT ''::text NOT NULL,
+    watch_only boolean DEFAULT false NOT NULL,
+    parent_id text
 );
 
 
@@ -215,7 +285,8 @@ CREATE TABLE accounts (
 
 CREATE TABLE annotated_accounts (
     id text NOT NULL,
-    data jsonb NOT NULL
+    data jsonb NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL
 );
 
 
@@ -226,7 +297,8 @@ CREATE TABLE annotated_accounts (
 CREATE TABLE annotated_assets (
     id text NOT NULL,
     data jsonb NOT NULL,
-    sort_id text NOT NULL
+    sort_id text NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL
 );
 
 
@@ -240,7 +312,10 @@ CREATE TABLE annotated_outputs (
     output_index integer NOT NULL,
     tx_hash text NOT NULL,
     data jsonb NOT NULL,
-    timespan int8range NOT NULL
+    timespan int8range NOT NULL,
+    spent_by_tx_hash text,
+    spent_by_input integer,
+    spent_at_height bigint
 );
 
 
@@ -256,6 +331,33 @@ CREATE TABLE annotated_txs (
 );
 
 
+--
+-- Name: approval_thresholds; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE approval_thresholds (
+    asset_id text NOT NULL,
+    amount bigint NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL
+);
+
+
+--
+-- Name: approvals; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE approvals (
+    tx_hash text NOT NULL,
+    status text NOT NULL,
+    spends jsonb NOT NULL,
+    requested_by text NOT NULL,
+    decided_by text,
+    tenant text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    decided_at timestamp with time zone
+);
+
+
 --
 -- Name: asset_tags; Type: TABLE; Schema: public; Owner: -
 --
@@ -281,7 +383,10 @@ CREATE TABLE assets (
     signer_id text,
     definition jsonb,
     alias text,
-    first_block_height bigint
+    first_block_height bigint,
+    tenant text DEFAULT ''::text NOT NULL,
+    cosigner_url text,
+    cosigner_pubkey bytea
 );
 
 
@@ -297,6 +402,35 @@ CREATE SEQUENCE assets_key_index_seq
     CACHE 1;
 
 
+--
+-- Name: balance_snapshots; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE balance_snapshots (
+    date date NOT NULL,
+    account_id text NOT NULL,
+    asset_id text NOT NULL,
+    amount bigint NOT NULL
+);
+
+
+--
+-- Name: balance_subscriptions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE balance_subscriptions (
+    id text DEFAULT next_chain_id('bsub'::text) NOT NULL,
+    account_id text NOT NULL,
+    url text NOT NULL,
+    secret bytea NOT NULL,
+    status text NOT NULL,
+    failures integer DEFAULT 0 NOT NULL,
+    last_error text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    delivered_height bigint DEFAULT 0 NOT NULL
+);
+
+
 --
 -- Name: block_processors; Type: TABLE; Schema: public; Owner: -
 --
@@ -331,6 +465,16 @@ CREATE SEQUENCE chain_id_seq
     CACHE 1;
 
 
+--
+-- Name: build_commitments; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE build_commitments (
+    hash bytea NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
 --
 -- Name: config; Type: TABLE; Schema: public; Owner: -
 --
@@ -351,6 +495,81 @@ CREATE TABLE config (
 );
 
 
+--
+-- Name: contract_sources; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE contract_sources (
+    program_hash text NOT NULL,
+    program bytea NOT NULL,
+    vm_version bigint NOT NULL,
+    source text NOT NULL,
+    compiler text NOT NULL,
+    compiler_version text NOT NULL,
+    constructor_args jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: forwarded_txs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE forwarded_txs (
+    tx_hash text NOT NULL,
+    data bytea NOT NULL,
+    priority text NOT NULL,
+    status text NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    error text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: freeze_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE freeze_events (
+    id text DEFAULT next_chain_id('frz'::text) NOT NULL,
+    action text NOT NULL,
+    asset_id text NOT NULL,
+    account_id text DEFAULT ''::text NOT NULL,
+    reason text NOT NULL,
+    operator text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: freezes; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE freezes (
+    asset_id text NOT NULL,
+    account_id text DEFAULT ''::text NOT NULL,
+    reason text NOT NULL,
+    frozen_by text NOT NULL,
+    frozen_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: generator_halt; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE generator_halt (
+    singleton boolean DEFAULT true NOT NULL,
+    halt_pool boolean NOT NULL,
+    reason text NOT NULL,
+    operator text NOT NULL,
+    halted_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT generator_halt_singleton CHECK (singleton)
+);
+
+
 --
 -- Name: generator_pending_block; Type: TABLE; Schema: public; Owner: -
 --
@@ -362,6 +581,49 @@ CREATE TABLE generator_pending_block (
 );
 
 
+--
+-- Name: htlcs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE htlcs (
+    id text DEFAULT next_chain_id('htlc'::text) NOT NULL,
+    hash_function text NOT NULL,
+    hash bytea NOT NULL,
+    expiry_ms bigint NOT NULL,
+    role text NOT NULL,
+    xpub text NOT NULL,
+    derivation_path bytea[] NOT NULL,
+    counterparty_key bytea NOT NULL,
+    destination bytea NOT NULL,
+    control_program bytea NOT NULL,
+    preimage bytea,
+    status text NOT NULL,
+    tx_hash text,
+    index integer,
+    asset_id text,
+    amount bigint,
+    spend_tx_hash text,
+    tenant text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    settle_submitted_at timestamp with time zone
+);
+
+
+--
+-- Name: idempotent_requests; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE idempotent_requests (
+    tenant text NOT NULL,
+    path text NOT NULL,
+    key text NOT NULL,
+    request_hash bytea NOT NULL,
+    status integer,
+    response bytea,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: leader; Type: TABLE; Schema: public; Owner: -
 --
@@ -386,6 +648,18 @@ CREATE TABLE migrations (
 );
 
 
+--
+-- Name: mirror_outbox; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE mirror_outbox (
+    height bigint NOT NULL,
+    block_hash text NOT NULL,
+    data bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: mockhsm_sort_id_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
@@ -423,6 +697,16 @@ CREATE SEQUENCE pool_tx_sort_id_seq
     CACHE 1;
 
 
+--
+-- Name: notifications; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE notifications (
+    channel text NOT NULL,
+    payload text NOT NULL
+);
+
+
 --
 -- Name: query_blocks; Type: TABLE; Schema: public; Owner: -
 --
@@ -445,13 +729,88 @@ CREATE SEQUENCE reservation_seq
     CACHE 1;
 
 
+--
+-- Name: reference_data_keys; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE reference_data_keys (
+    key_id text NOT NULL,
+    secret bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL
+);
+
+
+--
+-- Name: risk_scores; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE risk_scores (
+    tx_hash text NOT NULL,
+    stage text NOT NULL,
+    score double precision NOT NULL,
+    reasons jsonb NOT NULL,
+    scored_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: scheduled_payment_runs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE scheduled_payment_runs (
+    schedule_id text NOT NULL,
+    due_ms bigint NOT NULL,
+    attempt integer NOT NULL,
+    tx_hash text,
+    error text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: scheduled_payments; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE scheduled_payments (
+    id text DEFAULT next_chain_id('sched'::text) NOT NULL,
+    payments jsonb NOT NULL,
+    xpubs text[] NOT NULL,
+    due_ms bigint NOT NULL,
+    next_attempt_ms bigint NOT NULL,
+    interval_ms bigint NOT NULL,
+    max_attempts integer NOT NULL,
+    retry_delay_ms bigint NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    pending_tx jsonb,
+    status text NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: signed_blocks; Type: TABLE; Schema: public; Owner: -
 --
 
 CREATE TABLE signed_blocks (
     block_height bigint NOT NULL,
-    block_hash text NOT NULL
+    block_hash text NOT NULL,
+    signed_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: signer_key_metadata; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signer_key_metadata (
+    xpub text NOT NULL,
+    label text DEFAULT ''::text NOT NULL,
+    description text DEFAULT ''::text NOT NULL,
+    custodian text DEFAULT ''::text NOT NULL,
+    contact text DEFAULT ''::text NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
@@ -488,6 +847,50 @@ CREATE SEQUENCE signers_key_index_seq
 ALTER SEQUENCE signers_key_index_seq OWNED BY signers.key_index;
 
 
+--
+-- Name: signing_declines; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signing_declines (
+    tx_hash text NOT NULL,
+    xpub text NOT NULL,
+    declined_by text DEFAULT ''::text NOT NULL,
+    reason text DEFAULT ''::text NOT NULL,
+    declined_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: signing_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signing_events (
+    id text DEFAULT next_chain_id('sev'::text) NOT NULL,
+    tx_hash text NOT NULL,
+    type text NOT NULL,
+    xpub text DEFAULT ''::text NOT NULL,
+    "position" integer,
+    operator text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: signing_requests; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signing_requests (
+    tx_hash text NOT NULL,
+    template jsonb NOT NULL,
+    status text NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL,
+    created_by text DEFAULT ''::text NOT NULL,
+    expires_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: snapshots; Type: TABLE; Schema: public; Owner: -
 --
@@ -498,6 +901,27 @@ CREATE TABLE snapshots (
 );
 
 
+--
+-- Name: submission_failures; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE submission_failures (
+    id text DEFAULT next_chain_id('subf'::text) NOT NULL,
+    tx_hash text NOT NULL,
+    template jsonb NOT NULL,
+    code text NOT NULL,
+    message text NOT NULL,
+    detail text NOT NULL,
+    data jsonb NOT NULL,
+    submitter text NOT NULL,
+    attempts integer DEFAULT 1 NOT NULL,
+    status text NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: submitted_txs; Type: TABLE; Schema: public; Owner: -
 --
@@ -518,7 +942,8 @@ CREATE TABLE txfeeds (
     alias text,
     filter text,
     after text,
-    client_token text
+    client_token text,
+    tenant text DEFAULT ''::text NOT NULL
 );
 
 
@@ -537,6 +962,14 @@ ALTER TABLE ONLY access_tokens
     ADD CONSTRAINT access_tokens_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: access_tokens_public_key_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY access_tokens
+    ADD CONSTRAINT access_tokens_public_key_key UNIQUE (public_key);
+
+
 --
 -- Name: account_control_programs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -545,6 +978,30 @@ ALTER TABLE ONLY account_control_programs
     ADD CONSTRAINT account_control_programs_pkey PRIMARY KEY (control_program);
 
 
+--
+-- Name: account_limits_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_limits
+    ADD CONSTRAINT account_limits_pkey PRIMARY KEY (account_id, asset_id);
+
+
+--
+-- Name: account_rescans_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_rescans
+    ADD CONSTRAINT account_rescans_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: account_spends_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_spends
+    ADD CONSTRAINT account_spends_pkey PRIMARY KEY (tx_hash, account_id, asset_id);
+
+
 --
 -- Name: account_tags_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -601,6 +1058,22 @@ ALTER TABLE ONLY annotated_txs
     ADD CONSTRAINT annotated_txs_pkey PRIMARY KEY (block_height, tx_pos);
 
 
+--
+-- Name: approval_thresholds_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY approval_thresholds
+    ADD CONSTRAINT approval_thresholds_pkey PRIMARY KEY (tenant, asset_id);
+
+
+--
+-- Name: approvals_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY approvals
+    ADD CONSTRAINT approvals_pkey PRIMARY KEY (tx_hash);
+
+
 --
 -- Name: asset_tags_asset_id_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -633,6 +1106,22 @@ ALTER TABLE ONLY assets
     ADD CONSTRAINT assets_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: balance_snapshots_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY balance_snapshots
+    ADD CONSTRAINT balance_snapshots_pkey PRIMARY KEY (date, account_id, asset_id);
+
+
+--
+-- Name: balance_subscriptions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY balance_subscriptions
+    ADD CONSTRAINT balance_subscriptions_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: block_processors_name_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -657,6 +1146,14 @@ ALTER TABLE ONLY blocks
     ADD CONSTRAINT blocks_pkey PRIMARY KEY (block_hash);
 
 
+--
+-- Name: build_commitments_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY build_commitments
+    ADD CONSTRAINT build_commitments_pkey PRIMARY KEY (hash);
+
+
 --
 -- Name: config_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -665,6 +1162,46 @@ ALTER TABLE ONLY config
     ADD CONSTRAINT config_pkey PRIMARY KEY (singleton);
 
 
+--
+-- Name: contract_sources_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY contract_sources
+    ADD CONSTRAINT contract_sources_pkey PRIMARY KEY (program_hash);
+
+
+--
+-- Name: forwarded_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY forwarded_txs
+    ADD CONSTRAINT forwarded_txs_pkey PRIMARY KEY (tx_hash);
+
+
+--
+-- Name: freeze_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY freeze_events
+    ADD CONSTRAINT freeze_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: freezes_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY freezes
+    ADD CONSTRAINT freezes_pkey PRIMARY KEY (asset_id, account_id);
+
+
+--
+-- Name: generator_halt_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY generator_halt
+    ADD CONSTRAINT generator_halt_pkey PRIMARY KEY (singleton);
+
+
 --
 -- Name: generator_pending_block_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -673,6 +1210,30 @@ ALTER TABLE ONLY generator_pending_block
     ADD CONSTRAINT generator_pending_block_pkey PRIMARY KEY (singleton);
 
 
+--
+-- Name: htlcs_control_program_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY htlcs
+    ADD CONSTRAINT htlcs_control_program_key UNIQUE (control_program);
+
+
+--
+-- Name: htlcs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY htlcs
+    ADD CONSTRAINT htlcs_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: idempotent_requests_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY idempotent_requests
+    ADD CONSTRAINT idempotent_requests_pkey PRIMARY KEY (tenant, path, key);
+
+
 --
 -- Name: leader_singleton_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -689,6 +1250,14 @@ ALTER TABLE ONLY migrations
     ADD CONSTRAINT migrations_pkey PRIMARY KEY (filename);
 
 
+--
+-- Name: mirror_outbox_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mirror_outbox
+    ADD CONSTRAINT mirror_outbox_pkey PRIMARY KEY (height);
+
+
 --
 -- Name: mockhsm_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -705,6 +1274,14 @@ ALTER TABLE ONLY mockhsm
     ADD CONSTRAINT mockhsm_pkey PRIMARY KEY (pub);
 
 
+--
+-- Name: notifications_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY notifications
+    ADD CONSTRAINT notifications_pkey PRIMARY KEY (channel);
+
+
 --
 -- Name: query_blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -713,6 +1290,46 @@ ALTER TABLE ONLY query_blocks
     ADD CONSTRAINT query_blocks_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: reference_data_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reference_data_keys
+    ADD CONSTRAINT reference_data_keys_pkey PRIMARY KEY (tenant, key_id);
+
+
+--
+-- Name: risk_scores_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY risk_scores
+    ADD CONSTRAINT risk_scores_pkey PRIMARY KEY (tx_hash, stage);
+
+
+--
+-- Name: scheduled_payment_runs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY scheduled_payment_runs
+    ADD CONSTRAINT scheduled_payment_runs_pkey PRIMARY KEY (schedule_id, due_ms, attempt);
+
+
+--
+-- Name: scheduled_payments_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY scheduled_payments
+    ADD CONSTRAINT scheduled_payments_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: signer_key_metadata_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signer_key_metadata
+    ADD CONSTRAINT signer_key_metadata_pkey PRIMARY KEY (xpub);
+
+
 --
 -- Name: signers_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -729,6 +1346,30 @@ ALTER TABLE ONLY signers
     ADD CONSTRAINT signers_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: signing_declines_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signing_declines
+    ADD CONSTRAINT signing_declines_pkey PRIMARY KEY (tx_hash, xpub);
+
+
+--
+-- Name: signing_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signing_events
+    ADD CONSTRAINT signing_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: signing_requests_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signing_requests
+    ADD CONSTRAINT signing_requests_pkey PRIMARY KEY (tx_hash);
+
+
 --
 -- Name: sort_id_index; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -745,6 +1386,14 @@ ALTER TABLE ONLY snapshots
     ADD CONSTRAINT state_trees_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: submission_failures_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY submission_failures
+    ADD CONSTRAINT submission_failures_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: submitted_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -777,6 +1426,13 @@ ALTER TABLE ONLY txfeeds
     ADD CONSTRAINT txfeeds_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: account_spends_account_id_asset_id_spent_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_spends_account_id_asset_id_spent_at_idx ON account_spends USING btree (account_id, asset_id, spent_at);
+
+
 --
 -- Name: account_utxos_asset_id_account_id_confirmed_in_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -784,6 +1440,13 @@ ALTER TABLE ONLY txfeeds
 CREATE INDEX account_utxos_asset_id_account_id_confirmed_in_idx ON account_utxos USING btree (asset_id, account_id, confirmed_in);
 
 
+--
+-- Name: accounts_parent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX accounts_parent_id_idx ON accounts USING btree (parent_id);
+
+
 --
 -- Name: annotated_accounts_jsondata_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -791,6 +1454,20 @@ CREATE INDEX account_utxos_asset_id_account_id_confirmed_in_idx ON account_utxos
 CREATE INDEX annotated_accounts_jsondata_idx ON annotated_accounts USING gin (data jsonb_path_ops);
 
 
+--
+-- Name: annotated_accounts_parent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_accounts_parent_id_idx ON annotated_accounts USING btree (((data ->> 'parent_id'::text)));
+
+
+--
+-- Name: annotated_accounts_tenant_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_accounts_tenant_idx ON annotated_accounts USING btree (tenant);
+
+
 --
 -- Name: annotated_assets_jsondata_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -805,6 +1482,13 @@ CREATE INDEX annotated_assets_jsondata_idx ON annotated_assets USING gin (data j
 CREATE INDEX annotated_assets_sort_id ON annotated_assets USING btree (sort_id);
 
 
+--
+-- Name: annotated_outputs_control_program_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_outputs_control_program_idx ON annotated_outputs USING btree (((data ->> 'control_program'::text)), block_height, tx_pos, output_index);
+
+
 --
 -- Name: annotated_outputs_jsondata_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -819,6 +1503,13 @@ CREATE INDEX annotated_outputs_jsondata_idx ON annotated_outputs USING gin (data
 CREATE INDEX annotated_outputs_outpoint_idx ON annotated_outputs USING btree (tx_hash, output_index);
 
 
+--
+-- Name: annotated_outputs_spent_by_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_outputs_spent_by_idx ON annotated_outputs USING btree (spent_by_tx_hash);
+
+
 --
 -- Name: annotated_outputs_timespan_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -833,6 +1524,20 @@ CREATE INDEX annotated_outputs_timespan_idx ON annotated_outputs USING gist (tim
 CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_ops);
 
 
+--
+-- Name: annotated_txs_reference_data_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_txs_reference_data_idx ON annotated_txs USING gin (reference_data_tsvector(data));
+
+
+--
+-- Name: annotated_txs_tx_hash_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_txs_tx_hash_idx ON annotated_txs USING btree (tx_hash);
+
+
 --
 -- Name: assets_sort_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -840,6 +1545,55 @@ CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_
 CREATE INDEX assets_sort_id ON assets USING btree (sort_id);
 
 
+--
+-- Name: balance_snapshots_account_id_date_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX balance_snapshots_account_id_date_idx ON balance_snapshots USING btree (account_id, date);
+
+
+--
+-- Name: balance_subscriptions_account_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX balance_subscriptions_account_id_idx ON balance_subscriptions USING btree (account_id) WHERE (status = 'active'::text);
+
+
+--
+-- Name: blocks_height_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX blocks_height_idx ON blocks USING btree (height);
+
+
+--
+-- Name: build_commitments_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX build_commitments_expires_at_idx ON build_commitments USING btree (expires_at);
+
+
+--
+-- Name: forwarded_txs_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX forwarded_txs_next_attempt_at_idx ON forwarded_txs USING btree (next_attempt_at) WHERE (status = 'forwarding'::text);
+
+
+--
+-- Name: htlcs_hash_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX htlcs_hash_idx ON htlcs USING btree (hash) WHERE (preimage IS NULL);
+
+
+--
+-- Name: idempotent_requests_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idempotent_requests_created_at_idx ON idempotent_requests USING btree (created_at);
+
+
 --
 -- Name: query_blocks_timestamp_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -847,6 +1601,13 @@ CREATE INDEX assets_sort_id ON assets USING btree (sort_id);
 CREATE INDEX query_blocks_timestamp_idx ON query_blocks USING btree ("timestamp");
 
 
+--
+-- Name: scheduled_payments_next_attempt_ms_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX scheduled_payments_next_attempt_ms_idx ON scheduled_payments USING btree (next_attempt_ms) WHERE (status = 'active'::text);
+
+
 --
 -- Name: signed_blocks_block_height_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -861,6 +1622,20 @@ CREATE UNIQUE INDEX signed_blocks_block_height_idx ON signed_blocks USING btree
 CREATE INDEX signers_type_id_idx ON signers USING btree (type, id);
 
 
+--
+-- Name: signing_requests_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX signing_requests_created_at_idx ON signing_requests USING btree (created_at);
+
+
+--
+-- Name: submission_failures_tx_hash_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX submission_failures_tx_hash_idx ON submission_failures USING btree (tx_hash) WHERE (status = 'failed'::text);
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -876,3 +1651,41 @@ insert into migrations (filename, hash) values ('2016-11-18.0.account.confirmed-
 insert into migrations (filename, hash) values ('2016-11-22.0.account.utxos-indexes.sql', 'f3ea43f592cb06a36b040f0b0b9626ee9174d26d36abef44e68114d0c0aace98');
 insert into migrations (filename, hash) values ('2016-11-23.0.query.jsonb-path-ops.sql', 'adb15b9a6b7b223a17dbfd5f669e44c500b343568a563f87e1ae67ba0f938d55');
 insert into migrations (filename, hash) values ('2016-11-28.0.core.submitted-txs-hash.sql', 'cabbd7fd79a2b672b2d3c854783bde3b8245fe666c50261c3335a0c0501ff2ea');
+insert into migrations (filename, hash) values ('2016-12-01.0.core.access-token-priority-quota.sql', '483f398ab7624fc3ee1e43fc4558f2447d3e10f98bef11aa6d4a13a185eaf831');
+insert into migrations (filename, hash) values ('2016-12-02.0.query.reference-data-search.sql', 'b5a32b99d8c7448d33931d7c86db8b5d4bafff4925cd8c2b8b1a50478b71ee6d');
+insert into migrations (filename, hash) values ('2016-12-05.0.core.tenants.sql', '4933361fec76718613ee0311da302dec59a879272bf7389ea30a1150b4846898');
+insert into migrations (filename, hash) values ('2016-12-06.0.core.account-limits.sql', '1d10d5f631ee9e65531dc897679c470725aaf6a37aa787fa375deac1ce6ebf4c');
+insert into migrations (filename, hash) values ('2016-12-07.0.core.approvals.sql', '9953f3fb060ec42128d7f50afe73567045f0cf4eeebe1ad6c39d983932ce8fb0');
+insert into migrations (filename, hash) values ('2016-12-08.0.account.watch-only.sql', 'b857854e54e6fb6f639eb28e4b149af7bd1dc11421126ceb9c608c75155f9365');
+insert into migrations (filename, hash) values ('2016-12-09.0.core.htlcs.sql', '9f39dbaf0ddd1aaf1e61c20d15020a8fb1549eb05b7758d7859379fb78304757');
+insert into migrations (filename, hash) values ('2016-12-10.0.core.access-token-keys.sql', '2885b3d472eccabc2dd1f578afb39a08753bf64f8d22b7a8048457d021e94288');
+insert into migrations (filename, hash) values ('2016-12-11.0.core.signed-blocks-journal.sql', '1ab4295d543aa599a69ad93576fa56489bfa4509ca8187c950f91ab9e2813362');
+insert into migrations (filename, hash) values ('2016-12-12.0.core.balance-snapshots.sql', '30fcb8cfbb19eee82795e207bb9f028c11c3409dae5c6ad06400f64e3c0a2e45');
+insert into migrations (filename, hash) values ('2016-12-13.0.core.asset-cosigners.sql', 'd2b96a11aab178cf86a32df579ef0e1ff61547d4496b4759354013d76f7de38c');
+insert into migrations (filename, hash) values ('2016-12-14.0.core.mirror-outbox.sql', '241c3f345f68beb85dcdea0722d7caf4fae318a81fbf85c89bd590cccb37c6b8');
+insert into migrations (filename, hash) values ('2016-12-15.0.core.contract-sources.sql', '7f41aa49725a971d798e202aea1b4d99ad1d1ab3887f66d2d010bd395a710045');
+insert into migrations (filename, hash) values ('2016-12-16.0.core.explorer-indexes.sql', 'f8d17aebe8e87766a6d62ed27b7f0c26be70ffa54dbb66312656b07280b45918');
+insert into migrations (filename, hash) values ('2016-12-17.0.core.scheduled-payments.sql', 'ad1558c77b04b3f0dfcd04c942710225f32483b908291ba4d374fce55f200345');
+insert into migrations (filename, hash) values ('2016-12-18.0.core.submission-failures.sql', 'a802f9e3de58c260eabc4eb89089dd8f03a5d2479f1667fb316a9c75c9884858');
+insert into migrations (filename, hash) values ('2016-12-19.0.core.balance-subscriptions.sql', '295684f601d6241500bb4ab2f75c27b5f25df4360869ed0597d8dddadbed1cb2');
+insert into migrations (filename, hash) values ('2016-12-20.0.core.notifications.sql', '3d53709c247c8e07d55d3e385e77858134fce4537e0a6896204fc9d713387892');
+insert into migrations (filename, hash) values ('2016-12-21.0.core.reference-data-keys.sql', '0cddc84ac848446ab243e62793340d7927c68376ac3a87bd32a1c63bb8c37358');
+insert into migrations (filename, hash) values ('2016-12-22.0.account.control-program-uses.sql', '7aac534c4f699610ac740cb34113990746a678d494b55ffc91b8c44c70ff0fa3');
+insert into migrations (filename, hash) values ('2016-12-23.0.core.freezes.sql', '74d58e32cb2cbda6dc9adc0efc9f375dbf1f06487eb8cd7ba40845e181b91a4e');
+insert into migrations (filename, hash) values ('2016-12-23.1.generator.halt.sql', 'b91119ca886d082704962295f4789a09ece10af96fa63b95051868c818614549');
+insert into migrations (filename, hash) values ('2016-12-23.2.core.access-token-peers.sql', '2230bca86a930dee61539b3ab50191f78c95e2b2157478ea15171eb863a30a42');
+insert into migrations (filename, hash) values ('2016-12-23.3.core.forwarded-txs.sql', '0876cdd48c69691df880957a85c96ab3b1b652e560b145817b33d5d6f04abdde');
+insert into migrations (filename, hash) values ('2016-12-23.4.core.signer-key-metadata.sql', 'fe5c4da438e4b01338198e6b3c9df6231204bdc6af054fb7bc9fb98ae4dae429');
+insert into migrations (filename, hash) values ('2016-12-23.5.core.signing-requests.sql', '7da81f10972d2fbd1cc270500e69cbc78c5d5142a5f537c0b5ce5aecfd0cec1d');
+insert into migrations (filename, hash) values ('2016-12-23.6.query.output-spends.sql', '235dc53c2d9bb3df8e0a8bfc0ca5761d05eb026da801824cbb2c284d7e5b9ede');
+insert into migrations (filename, hash) values ('2016-12-23.7.query.output-spender-idx.sql', '97cce8516f794fe47052957f055f4d416bcc056f79c92bcd1d08fe4a2b1ac9b1');
+insert into migrations (filename, hash) values ('2016-12-23.8.core.risk-scores.sql', '1a76e5078321e736dd12945f912c6235481622d92b1e966f7671a290f6293d91');
+insert into migrations (filename, hash) values ('2016-12-23.9.core.idempotent-requests.sql', '67fbcc84e6f36c1aff1d7f57ae9db4bdeef332550702977db7d3a08c6e564d84');
+insert into migrations (filename, hash) values ('2016-12-24.0.core.account-parents.sql', 'e97f70fbfb4f5836a6715493b21a45ec8e6bb98e3542f06d322b69376131736c');
+insert into migrations (filename, hash) values ('2016-12-24.1.core.build-commitments.sql', '119783d2f0dd32a000288224a84056b7ebeedef5e2820b8844637e06e1efa5c9');
+insert into migrations (filename, hash) values ('2016-12-24.2.core.txfeed-tenants.sql', '0df28dfa8946a63b4854dee6412ff9f470f22e93ce4c1a848611937ed82cfdd4');
+insert into migrations (filename, hash) values ('2016-12-24.3.core.approval-threshold-tenants.sql', '719296c503f3ea4bfcccc080a2b37394250bff94a51de6acf4d80b77b5ba0082');
+insert into migrations (filename, hash) values ('2016-12-24.4.core.htlc-settle-submitted.sql', 'fe2b6760903cee47c7f1e15fe33dd8ded36b4393281c63858a241575b7423cce');
+insert into migrations (filename, hash) values ('2016-12-24.5.core.reference-data-key-tenants.sql', '9b9573eebd594bb802ed81de34eed9a75f588d0b9e8231be5572bbb60ab64a1a');
+insert into migrations (filename, hash) values ('2016-12-24.6.core.account-rescans.sql', '943017c5e127699f4304b500930f2391a8ec79fc6c9a994eb96096459e3c1a6a');
+insert into migrations (filename, hash) values ('2016-12-24.7.core.balance-subscription-progress.sql', '35c2b863b230166ba7922a0c88936d66796d18ebdc4ecedfdb9e13e12a01ba5b');
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// Types of /search matches.
+const (
+	matchBlock          = "block"
+	matchTransaction    = "transaction"
+	matchAsset          = "asset"
+	matchAccount        = "account"
+	matchControlProgram = "control_program"
+)
+
+type searchMatch struct {
+	Type   string      `json:"type"`
+	Object interface{} `json:"object"`
+}
+
+// POST /search
+//
+// Responds with everything the query string could identify:
+// a block, by height or ID; a transaction, by ID; an asset or
+// an account, by ID or alias; or a control program that has
+// been paid to. Each match has a type and the object matched,
+// as /explorer/get-block, /list-transactions, /list-assets,
+// /list-accounts and /explorer/list-program-history give it.
+// A query can match more than one object, or none.
+func (h *Handler) search(ctx context.Context, in struct {
+	Query string `json:"query"`
+}) ([]searchMatch, error) {
+	q := strings.TrimSpace(in.Query)
+	if q == "" {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "query is required")
+	}
+
+	matches := []searchMatch{}
+	add := func(typ string, obj interface{}, err error) error {
+		if errors.Root(err) == pg.ErrUserInputNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		matches = append(matches, searchMatch{Type: typ, Object: obj})
+		return nil
+	}
+
+	if height, err := strconv.ParseUint(q, 10, 64); err == nil {
+		b, err := h.Explorer.Block(ctx, height)
+		err = add(matchBlock, b, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if prog, err := hex.DecodeString(q); err == nil && len(prog) > 0 {
+		var hash bc.Hash
+		if len(prog) == len(hash) {
+			copy(hash[:], prog)
+			b, err := h.Explorer.BlockByID(ctx, hash)
+			err = add(matchBlock, b, err)
+			if err != nil {
+				return nil, err
+			}
+
+			txs, err := h.listTransactions(ctx, requestQuery{
+				Filter:       "id=$1",
+				FilterParams: []interface{}{hash.String()},
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, tx := range txs.Items.([]*txResp) {
+				matches = append(matches, searchMatch{Type: matchTransaction, Object: tx})
+			}
+		}
+
+		outs, _, err := h.Explorer.ProgramHistory(ctx, prog, "", 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(outs) > 0 {
+			matches = append(matches, searchMatch{
+				Type:   matchControlProgram,
+				Object: map[string]string{"control_program": hex.EncodeToString(prog)},
+			})
+		}
+	}
+
+	byIDOrAlias := requestQuery{
+		Filter:       "id=$1 OR alias=$1",
+		FilterParams: []interface{}{q},
+	}
+	assets, err := h.listAssets(ctx, byIDOrAlias)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range assets.Items.([]*assetResponse) {
+		matches = append(matches, searchMatch{Type: matchAsset, Object: a})
+	}
+	accounts, err := h.listAccounts(ctx, byIDOrAlias)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts.Items.([]*accountResponse) {
+		matches = append(matches, searchMatch{Type: matchAccount, Object: a})
+	}
+
+	return matches, nil
+}
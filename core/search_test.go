@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/explorer"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/txdb"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	c := prottest.NewChain(t)
+	indexer := query.NewIndexer(db, c, pin.NewStore(db))
+	h := &Handler{DB: db, Chain: c, Indexer: indexer, Explorer: &explorer.Explorer{DB: db}}
+
+	tx := bc.NewTx(bc.TxData{Version: 1})
+	block := &bc.Block{
+		BlockHeader:  bc.BlockHeader{Version: 1, Height: 7},
+		Transactions: []*bc.Tx{tx},
+	}
+	err := txdb.NewStore(db).SaveBlock(ctx, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexer.IndexTransactions(ctx, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"7", []string{matchBlock}},
+		{block.Hash().String(), []string{matchBlock}},
+		{tx.Hash.String(), []string{matchTransaction}},
+		{"nothing", nil},
+	}
+	for _, c := range cases {
+		got, err := h.search(ctx, struct {
+			Query string `json:"query"`
+		}{c.query})
+		if err != nil {
+			t.Errorf("search(%q) error %s", c.query, err)
+			continue
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("search(%q) = %+v want types %v", c.query, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Type != c.want[i] {
+				t.Errorf("search(%q)[%d].Type = %s want %s", c.query, i, got[i].Type, c.want[i])
+			}
+		}
+	}
+}
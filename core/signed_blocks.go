@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"strconv"
+
+	"chain/core/blocksigner"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// POST /list-signed-blocks
+//
+// Lists the blocks this core has signed as a block signer,
+// by ascending height, for audits.
+func (h *Handler) listSignedBlocks(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	var after uint64
+	if query.After != "" {
+		var err error
+		after, err = strconv.ParseUint(query.After, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(httpjson.ErrBadRequest, "bad after %q", query.After)
+		}
+	}
+
+	entries, last, err := blocksigner.Journal(ctx, h.DB, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = strconv.FormatUint(last, 10)
+	return &page{
+		Items:    httpjson.Array(entries),
+		LastPage: len(entries) < limit,
+		Next:     query,
+	}, nil
+}
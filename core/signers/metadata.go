@@ -0,0 +1,91 @@
+package signers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// KeyMetadata describes who holds a root key, so that operators
+// can tell whose signature a transaction is waiting for. It is
+// stored by xpub, and applies to every signer using the key.
+type KeyMetadata struct {
+	XPub        chainkd.XPub `json:"root_xpub"`
+	Label       string       `json:"label"`
+	Description string       `json:"description"`
+	Custodian   string       `json:"custodian"`
+	Contact     string       `json:"contact"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// SetKeyMetadata saves md, replacing any metadata of the same key.
+// Metadata with nothing but the key deletes the key's metadata.
+func SetKeyMetadata(ctx context.Context, db pg.DB, md *KeyMetadata) error {
+	if md.Label == "" && md.Description == "" && md.Custodian == "" && md.Contact == "" {
+		const q = `DELETE FROM signer_key_metadata WHERE xpub=$1`
+		_, err := db.Exec(ctx, q, md.XPub.String())
+		return errors.Wrap(err, "deleting key metadata")
+	}
+	const q = `
+		INSERT INTO signer_key_metadata (xpub, label, description, custodian, contact)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (xpub) DO UPDATE
+		SET label=$2, description=$3, custodian=$4, contact=$5, updated_at=now()
+		RETURNING updated_at
+	`
+	err := db.QueryRow(ctx, q, md.XPub.String(), md.Label, md.Description, md.Custodian, md.Contact).Scan(&md.UpdatedAt)
+	return errors.Wrap(err, "saving key metadata")
+}
+
+// GetKeyMetadata returns the metadata of those of xpubs
+// that have any, keyed by the xpubs' string form.
+func GetKeyMetadata(ctx context.Context, db pg.DB, xpubs []string) (map[string]*KeyMetadata, error) {
+	res := make(map[string]*KeyMetadata)
+	if len(xpubs) == 0 {
+		return res, nil
+	}
+	const q = `
+		SELECT xpub, label, description, custodian, contact, updated_at
+		FROM signer_key_metadata WHERE xpub=ANY($1)
+	`
+	err := pg.ForQueryRows(ctx, db, q, pq.StringArray(xpubs), func(xpub string, label, desc, custodian, contact string, updatedAt time.Time) error {
+		md := &KeyMetadata{Label: label, Description: desc, Custodian: custodian, Contact: contact, UpdatedAt: updatedAt}
+		err := md.XPub.UnmarshalText([]byte(xpub))
+		if err != nil {
+			return errors.Wrap(err, "bad xpub in database")
+		}
+		res[xpub] = md
+		return nil
+	})
+	return res, errors.Wrap(err, "loading key metadata")
+}
+
+// ListKeyMetadata returns a paginated list of key metadata,
+// in order of xpub, after the xpub prev.
+func ListKeyMetadata(ctx context.Context, db pg.DB, prev string, limit int) ([]*KeyMetadata, string, error) {
+	const q = `
+		SELECT xpub, label, description, custodian, contact, updated_at
+		FROM signer_key_metadata WHERE ($1='' OR $1<xpub)
+		ORDER BY xpub ASC LIMIT $2
+	`
+	var mds []*KeyMetadata
+	err := pg.ForQueryRows(ctx, db, q, prev, limit, func(xpub string, label, desc, custodian, contact string, updatedAt time.Time) error {
+		md := &KeyMetadata{Label: label, Description: desc, Custodian: custodian, Contact: contact, UpdatedAt: updatedAt}
+		err := md.XPub.UnmarshalText([]byte(xpub))
+		if err != nil {
+			return errors.Wrap(err, "bad xpub in database")
+		}
+		mds = append(mds, md)
+		prev = xpub
+		return nil
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing key metadata")
+	}
+	return mds, prev, nil
+}
@@ -4,12 +4,16 @@ package signers
 import (
 	"context"
 	"database/sql"
+	"encoding/base32"
 	"encoding/binary"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/lib/pq"
 
 	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
 	"chain/database/pg"
 	"chain/errors"
 )
@@ -47,6 +51,11 @@ var (
 	// ErrDupeXPub is returned by create when the same xpub
 	// appears twice in a single call.
 	ErrDupeXPub = errors.New("xpubs cannot contain the same key more than once")
+
+	// ErrDupeKeys is returned by CreateDeterministic when
+	// a signer of the same type already has the same xpubs
+	// and quorum.
+	ErrDupeKeys = errors.New("a signer with the same xpubs and quorum already exists")
 )
 
 // Signer is the abstract concept of a signer,
@@ -76,41 +85,51 @@ func Path(s *Signer, ks keySpace, itemIndexes ...uint64) [][]byte {
 
 // Create creates and stores a Signer in the database
 func Create(ctx context.Context, db pg.DB, typ string, xpubs []string, quorum int, clientToken *string) (*Signer, error) {
-	if len(xpubs) == 0 {
-		return nil, errors.Wrap(ErrNoXPubs)
-	}
+	return create(ctx, db, typ, xpubs, quorum, clientToken, false)
+}
 
-	sort.Strings(xpubs) // this transforms the input slice
-	for i := 1; i < len(xpubs); i++ {
-		if xpubs[i] == xpubs[i-1] {
-			return nil, errors.WithDetailf(ErrDupeXPub, "duplicated key=%s", xpubs[i])
-		}
-	}
+// CreateDeterministic is like Create, but derives the signer's ID
+// and key index from its type, xpubs, and quorum instead of
+// allocating new ones. Cores that create signers with the same
+// keys get the same IDs and derive the same keys from them.
+// It returns ErrDupeKeys if such a signer already exists
+// with a different client token.
+func CreateDeterministic(ctx context.Context, db pg.DB, typ string, xpubs []string, quorum int, clientToken *string) (*Signer, error) {
+	return create(ctx, db, typ, xpubs, quorum, clientToken, true)
+}
 
-	keys, err := ConvertKeys(xpubs)
+func create(ctx context.Context, db pg.DB, typ string, xpubs []string, quorum int, clientToken *string, deterministic bool) (*Signer, error) {
+	keys, err := checkKeys(xpubs, quorum)
 	if err != nil {
 		return nil, err
 	}
 
-	if quorum == 0 || quorum > len(xpubs) {
-		return nil, errors.Wrap(ErrBadQuorum)
-	}
-
 	const q = `
-		INSERT INTO signers (id, type, xpubs, quorum, client_token)
-		VALUES (next_chain_id($1::text), $2, $3, $4, $5)
+		INSERT INTO signers (id, type, xpubs, quorum, client_token, key_index)
+		VALUES (COALESCE($6, next_chain_id($1::text)), $2, $3, $4, $5,
+			COALESCE($7, nextval('signers_key_index_seq')))
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING id, key_index
   `
 	var (
 		id       string
 		keyIndex uint64
+		detID    *string
+		detIndex *int64
 	)
-	err = db.QueryRow(ctx, q, typeIDMap[typ], typ, pq.StringArray(xpubs), quorum, clientToken).
+	if deterministic {
+		suffix, idx := deterministicKey(typ, keys, quorum)
+		s, i := typeIDMap[typ]+suffix, int64(idx)
+		detID, detIndex = &s, &i
+	}
+	err = db.QueryRow(ctx, q, typeIDMap[typ], typ, pq.StringArray(xpubs), quorum, clientToken, detID, detIndex).
 		Scan(&id, &keyIndex)
 	if err == sql.ErrNoRows && clientToken != nil {
 		return findByClientToken(ctx, db, clientToken)
 	}
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.Wrap(ErrDupeKeys)
+	}
 	if err != nil && err != sql.ErrNoRows {
 		return nil, errors.Wrap(err)
 	}
@@ -124,6 +143,107 @@ func Create(ctx context.Context, db pg.DB, typ string, xpubs []string, quorum in
 	}, nil
 }
 
+// Restore stores a Signer that Create or CreateDeterministic
+// gave the ID id and key index keyIndex on a core whose database
+// has been lost. If id is empty, it is derived from typ, xpubs,
+// and quorum as in CreateDeterministic, and so is keyIndex if it
+// is 0. Restoring a signer that already exists has no effect.
+func Restore(ctx context.Context, db pg.DB, typ, id string, xpubs []string, quorum int, keyIndex uint64) (*Signer, error) {
+	keys, err := checkKeys(xpubs, quorum)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		detID, detIndex := deterministicKey(typ, keys, quorum)
+		id = typeIDMap[typ] + detID
+		if keyIndex == 0 {
+			keyIndex = detIndex
+		}
+	}
+
+	const q = `
+		INSERT INTO signers (id, type, xpubs, quorum, key_index)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
+	`
+	_, err = db.Exec(ctx, q, id, typ, pq.StringArray(xpubs), quorum, keyIndex)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	// Keep new signers from reusing the key index.
+	if keyIndex < deterministicIndexBase {
+		const seqQ = `
+			SELECT setval('signers_key_index_seq', GREATEST($1, last_value))
+			FROM signers_key_index_seq
+		`
+		_, err = db.Exec(ctx, seqQ, keyIndex)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
+	return Find(ctx, db, typ, id)
+}
+
+// checkKeys sorts xpubs, which is modified in place,
+// checks them and quorum, and returns the parsed keys.
+func checkKeys(xpubs []string, quorum int) ([]chainkd.XPub, error) {
+	if len(xpubs) == 0 {
+		return nil, errors.Wrap(ErrNoXPubs)
+	}
+
+	sort.Strings(xpubs) // this transforms the input slice
+	for i := 1; i < len(xpubs); i++ {
+		if xpubs[i] == xpubs[i-1] {
+			return nil, errors.WithDetailf(ErrDupeXPub, "duplicated key=%s", xpubs[i])
+		}
+	}
+
+	keys, err := ConvertKeys(xpubs)
+	if err != nil {
+		return nil, err
+	}
+
+	if quorum == 0 || quorum > len(xpubs) {
+		return nil, errors.Wrap(ErrBadQuorum)
+	}
+	return keys, nil
+}
+
+// crockford is the base32 alphabet used by
+// the b32enc_crockford SQL function.
+var crockford = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ")
+
+// deterministicIndexBase is the least key index that
+// deterministicKey derives. Indexes from signers_key_index_seq
+// stay below it, so they can't collide with derived ones.
+const deterministicIndexBase = 1 << 62
+
+// deterministicKey derives a signer ID and key index from the
+// SHA3-256 hash of typ, quorum, and keys, in byte order. The ID
+// encodes the first 16 bytes of the hash, and the key index is
+// the next 8, in [deterministicIndexBase, 2^63).
+func deterministicKey(typ string, keys []chainkd.XPub, quorum int) (id string, keyIndex uint64) {
+	sorted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		sorted = append(sorted, string(k[:]))
+	}
+	sort.Strings(sorted)
+
+	b := []byte(typ)
+	b = append(b, 0)
+	b = strconv.AppendInt(b, int64(quorum), 10)
+	for _, k := range sorted {
+		b = append(b, k...)
+	}
+	var h [32]byte
+	sha3pool.Sum256(h[:], b)
+	id = strings.TrimRight(crockford.EncodeToString(h[:16]), "=")
+	keyIndex = binary.LittleEndian.Uint64(h[16:24])%deterministicIndexBase + deterministicIndexBase
+	return id, keyIndex
+}
+
 func New(id, typ string, xpubs []string, quorum int, keyIndex uint64) (*Signer, error) {
 	keys, err := ConvertKeys(xpubs)
 	if err != nil {
@@ -248,3 +248,35 @@ func createCounter() <-chan int {
 	}()
 	return result
 }
+
+func TestKeyMetadata(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	xpub := testutil.TestXPub.String()
+
+	md := &KeyMetadata{XPub: testutil.TestXPub, Label: "treasury 1", Custodian: "Alice", Contact: "alice@example.com"}
+	err := SetKeyMetadata(ctx, db, md)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := GetKeyMetadata(ctx, db, []string{xpub, dummyXPub})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[xpub], md) {
+		t.Errorf("GetKeyMetadata = %v, want %s: %+v", got, xpub, md)
+	}
+
+	// Clearing every field deletes the metadata.
+	err = SetKeyMetadata(ctx, db, &KeyMetadata{XPub: testutil.TestXPub})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	list, _, err := ListKeyMetadata(ctx, db, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(list) != 0 {
+		t.Errorf("ListKeyMetadata = %v, want none", list)
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/signreq"
+	"chain/core/txbuilder/signing"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// errNoSigningRequests is returned by the signing request
+// endpoints of a core that doesn't track them.
+var errNoSigningRequests = errors.New("signing requests are not tracked")
+
+// updateSigningRequest merges the signatures in tpl into
+// its signing request, if it has one. Failing to do so
+// doesn't fail the signing or submission of tpl.
+func (h *Handler) updateSigningRequest(ctx context.Context, tpl *signing.Template) {
+	if h.SigningRequests == nil {
+		return
+	}
+	_, err := h.SigningRequests.Update(ctx, tpl, accessTokenID(ctx))
+	if err != nil {
+		log.Error(ctx, err, "updating signing request")
+	}
+}
+
+// POST /track-signing-request
+//
+// Starts tracking the signatures of a template that must be
+// signed by several parties, or, if its transaction is already
+// tracked, adds the template's new signatures. Signatures added
+// by /mockhsm/sign-transaction, or present in templates passed
+// to /submit-transaction, are added too.
+func (h *Handler) trackSigningRequest(ctx context.Context, tpl *signing.Template) (*signreq.Request, error) {
+	if h.SigningRequests == nil {
+		return nil, errNoSigningRequests
+	}
+	return h.SigningRequests.Track(ctx, tpl, accessTokenID(ctx))
+}
+
+// POST /decline-signing-request
+//
+// Records that the holder of a key won't sign a transaction.
+// If too few keys remain to sign an input, the request
+// is declined.
+func (h *Handler) declineSigningRequest(ctx context.Context, in struct {
+	ID     bc.Hash `json:"id"`
+	XPub   string  `json:"xpub"`
+	Reason string  `json:"reason"`
+}) (*signreq.Request, error) {
+	if h.SigningRequests == nil {
+		return nil, errNoSigningRequests
+	}
+	if in.XPub == "" {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "xpub is required")
+	}
+	return h.SigningRequests.Decline(ctx, in.ID, in.XPub, in.Reason, accessTokenID(ctx))
+}
+
+// POST /get-signing-request
+func (h *Handler) getSigningRequest(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) (*signreq.Request, error) {
+	if h.SigningRequests == nil {
+		return nil, errNoSigningRequests
+	}
+	return h.SigningRequests.Find(ctx, in.ID)
+}
+
+// POST /list-signing-requests
+//
+// Lists signing requests, newest first, optionally
+// only those with the given status.
+func (h *Handler) listSigningRequests(ctx context.Context, query requestQuery) (*page, error) {
+	if h.SigningRequests == nil {
+		return nil, errNoSigningRequests
+	}
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	switch query.Status {
+	case "", signreq.StatusPending, signreq.StatusSigned, signreq.StatusDeclined, signreq.StatusExpired:
+	default:
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "unknown status %q", query.Status)
+	}
+	reqs, after, err := h.SigningRequests.List(ctx, query.Status, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(reqs),
+		LastPage: len(reqs) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /list-signing-events
+//
+// Lists the signatures, declines, and expiries of signing
+// requests, newest first.
+func (h *Handler) listSigningEvents(ctx context.Context, query requestQuery) (*page, error) {
+	if h.SigningRequests == nil {
+		return nil, errNoSigningRequests
+	}
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	events, after, err := h.SigningRequests.Events(ctx, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(events),
+		LastPage: len(events) < limit,
+		Next:     query,
+	}, nil
+}
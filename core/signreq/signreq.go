@@ -0,0 +1,718 @@
+// Package signreq tracks transaction templates awaiting
+// signatures from several parties.
+//
+// A client registers a template as a signing request. Whenever
+// the template comes back to Chain Core with more signatures,
+// they're checked and merged into the stored template, so the
+// request shows which keys have signed, which are still needed,
+// and which of their holders declined to sign. Each signature,
+// decline, and expiry is recorded as an event, and published to
+// a message bus if one is configured.
+package signreq
+
+import (
+	"bytes"
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"time"
+
+	"chain/core/events"
+	"chain/core/query"
+	"chain/core/signers"
+	"chain/core/tenant"
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	"chain/database/sql"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// Request statuses.
+const (
+	StatusPending  = "pending"  // some input still needs signatures
+	StatusSigned   = "signed"   // every input has its quorum
+	StatusDeclined = "declined" // too many declines for some input's quorum
+	StatusExpired  = "expired"  // the transaction's maxtime passed first
+)
+
+// Key statuses.
+const (
+	KeySigned   = "signed"
+	KeyPending  = "pending"
+	KeyDeclined = "declined"
+)
+
+// Event types.
+const (
+	EventCreated  = "created"
+	EventSigned   = "signed"
+	EventDeclined = "declined"
+	EventExpired  = "expired"
+)
+
+const defaultLimit = 100
+
+var (
+	// ErrBadTemplate is returned when tracking a template
+	// without a transaction or signatures to collect.
+	ErrBadTemplate = errors.New("invalid template for signing request")
+
+	// ErrNotSigner is returned when declining on behalf of
+	// a key that doesn't sign the transaction.
+	ErrNotSigner = errors.New("key does not sign the transaction")
+
+	// ErrClosed is returned when declining a
+	// request that is no longer pending.
+	ErrClosed = errors.New("signing request is closed")
+)
+
+// Tracker stores signing requests.
+type Tracker struct {
+	DB *sql.DB
+
+	// Publisher and Topic, if set, are the message
+	// bus and topic to publish signing events to.
+	Publisher events.Publisher
+	Topic     string
+}
+
+// A Request is a transaction template awaiting signatures.
+type Request struct {
+	TxID      bc.Hash           `json:"transaction_id"`
+	Status    string            `json:"status"`
+	Template  *signing.Template `json:"template"`
+	Inputs    []*InputStatus    `json:"inputs"`
+	Keys      []*KeyStatus      `json:"keys"`
+	CreatedBy string            `json:"created_by"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+}
+
+// InputStatus counts the signatures of an input.
+type InputStatus struct {
+	Position   int `json:"position"`
+	Quorum     int `json:"quorum"`
+	Signatures int `json:"signatures"`
+}
+
+// KeyStatus says whether a key has signed. A pending key is
+// needed if an input it signs lacks its quorum.
+type KeyStatus struct {
+	XPub       string               `json:"xpub"`
+	Status     string               `json:"status"`
+	Needed     bool                 `json:"needed"`
+	Positions  []int                `json:"input_positions"`
+	DeclinedBy string               `json:"declined_by,omitempty"`
+	Reason     string               `json:"reason,omitempty"`
+	Metadata   *signers.KeyMetadata `json:"metadata,omitempty"`
+}
+
+// An Event records a change to a signing request. Position
+// is the input a signature is for; XPub is the key that
+// signed or declined.
+type Event struct {
+	ID        string    `json:"id"`
+	TxID      bc.Hash   `json:"transaction_id"`
+	Type      string    `json:"type"`
+	XPub      string    `json:"xpub,omitempty"`
+	Position  *int      `json:"position,omitempty"`
+	Operator  string    `json:"operator,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type decline struct {
+	by, reason string
+}
+
+// Track records tpl as a signing request on behalf of access
+// token operator, or, if its transaction is already tracked,
+// merges its new signatures into the stored template. Only
+// signatures that verify are kept.
+func (t *Tracker) Track(ctx context.Context, tpl *signing.Template, operator string) (*Request, error) {
+	if tpl.Transaction == nil {
+		return nil, errors.WithDetail(ErrBadTemplate, "template has no transaction")
+	}
+	if len(signatureWitnesses(tpl)) == 0 {
+		return nil, errors.WithDetail(ErrBadTemplate, "template needs no signatures")
+	}
+	return t.merge(ctx, tpl, operator, true)
+}
+
+// Update merges the new signatures of tpl into its signing
+// request, if its transaction is tracked. It returns nil
+// if it isn't.
+func (t *Tracker) Update(ctx context.Context, tpl *signing.Template, operator string) (*Request, error) {
+	if tpl.Transaction == nil {
+		return nil, nil
+	}
+	return t.merge(ctx, tpl, operator, false)
+}
+
+func (t *Tracker) merge(ctx context.Context, tpl *signing.Template, operator string, create bool) (*Request, error) {
+	txID := tpl.Transaction.Hash()
+
+	dbtx, err := t.DB.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer dbtx.Rollback(ctx)
+
+	var (
+		stored  []byte
+		status  string
+		reqTen  string
+		created bool
+		evs     []*Event
+	)
+	const selectQ = `SELECT template, status, tenant FROM signing_requests WHERE tx_hash=$1 FOR UPDATE`
+	err = dbtx.QueryRow(ctx, selectQ, txID.String()).Scan(&stored, &status, &reqTen)
+	if err == stdsql.ErrNoRows {
+		if !create {
+			return nil, nil
+		}
+		created = true
+	} else if err != nil {
+		return nil, errors.Wrap(err, "loading signing request")
+	} else if !tenant.CanSee(ctx, reqTen) {
+		if !create {
+			return nil, nil
+		}
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %s", txID)
+	}
+
+	current := new(signing.Template)
+	if created {
+		// Start from the template without its signatures,
+		// and add those that verify like any others.
+		b, err := json.Marshal(tpl)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		err = json.Unmarshal(b, current)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		for _, w := range signatureWitnesses(current) {
+			w.Sigs = nil
+		}
+		evs = append(evs, &Event{TxID: txID, Type: EventCreated, Operator: operator})
+	} else {
+		err = json.Unmarshal(stored, current)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding stored template")
+		}
+	}
+	for _, s := range mergeSigs(current, tpl) {
+		evs = append(evs, &Event{TxID: txID, Type: EventSigned, XPub: s.xpub, Position: &s.pos, Operator: operator})
+	}
+	if !created && len(evs) == 0 {
+		return t.Find(ctx, txID)
+	}
+
+	b, err := json.Marshal(current)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if status != StatusExpired {
+		declines, err := loadDeclines(ctx, dbtx, txID)
+		if err != nil {
+			return nil, err
+		}
+		status, _, _ = summarize(current, declines)
+	}
+	if created {
+		const q = `
+			INSERT INTO signing_requests (tx_hash, template, status, tenant, created_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`
+		_, err = dbtx.Exec(ctx, q, txID.String(), b, status, tenant.FromContext(ctx), operator, expiry(current.Transaction))
+	} else {
+		const q = `UPDATE signing_requests SET template=$2, status=$3, updated_at=now() WHERE tx_hash=$1`
+		_, err = dbtx.Exec(ctx, q, txID.String(), b, status)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "saving signing request")
+	}
+	err = t.recordEvents(ctx, dbtx, evs)
+	if err != nil {
+		return nil, err
+	}
+	err = dbtx.Commit(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	t.publish(ctx, evs)
+	return t.Find(ctx, txID)
+}
+
+// Decline records that the holder of key xpub won't sign
+// transaction txID, on behalf of access token operator.
+func (t *Tracker) Decline(ctx context.Context, txID bc.Hash, xpub, reason, operator string) (*Request, error) {
+	req, err := t.Find(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != StatusPending {
+		return nil, errors.WithDetailf(ErrClosed, "request is %s", req.Status)
+	}
+	var key *KeyStatus
+	for _, k := range req.Keys {
+		if k.XPub == xpub {
+			key = k
+		}
+	}
+	if key == nil {
+		return nil, errors.WithDetailf(ErrNotSigner, "xpub %s", xpub)
+	}
+	if key.Status != KeyPending {
+		return nil, errors.WithDetailf(ErrClosed, "key has %s", key.Status)
+	}
+
+	dbtx, err := t.DB.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer dbtx.Rollback(ctx)
+
+	const insertQ = `
+		INSERT INTO signing_declines (tx_hash, xpub, declined_by, reason)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tx_hash, xpub) DO NOTHING
+	`
+	_, err = dbtx.Exec(ctx, insertQ, txID.String(), xpub, operator, reason)
+	if err != nil {
+		return nil, errors.Wrap(err, "saving decline")
+	}
+	declines, err := loadDeclines(ctx, dbtx, txID)
+	if err != nil {
+		return nil, err
+	}
+	status, _, _ := summarize(req.Template, declines)
+	const updateQ = `UPDATE signing_requests SET status=$2, updated_at=now() WHERE tx_hash=$1 AND status=$3`
+	_, err = dbtx.Exec(ctx, updateQ, txID.String(), status, StatusPending)
+	if err != nil {
+		return nil, errors.Wrap(err, "updating signing request")
+	}
+	evs := []*Event{{TxID: txID, Type: EventDeclined, XPub: xpub, Operator: operator}}
+	err = t.recordEvents(ctx, dbtx, evs)
+	if err != nil {
+		return nil, err
+	}
+	err = dbtx.Commit(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	t.publish(ctx, evs)
+	return t.Find(ctx, txID)
+}
+
+// Find returns the signing request of transaction txID.
+func (t *Tracker) Find(ctx context.Context, txID bc.Hash) (*Request, error) {
+	reqs, err := t.list(ctx, `tx_hash=$2`, txID.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(reqs) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %s", txID)
+	}
+	return reqs[0], nil
+}
+
+// List returns the signing requests with the given status, or
+// with any status if status is empty, newest first, in pages.
+func (t *Tracker) List(ctx context.Context, status, after string, limit int) ([]*Request, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	var afterTime time.Time
+	if after != "" {
+		var err error
+		afterTime, err = time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			return nil, "", errors.WithDetailf(query.ErrBadAfter, "%q", after)
+		}
+	}
+	const where = `($2='' OR status=$2) AND ($3 OR created_at<$4) ORDER BY created_at DESC LIMIT $5`
+	reqs, err := t.list(ctx, where, status, after == "", afterTime, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(reqs) > 0 {
+		after = reqs[len(reqs)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+	return reqs, after, nil
+}
+
+// list returns the signing requests visible to the tenant that ctx
+// acts for that match where, with the metadata of their keys. The
+// tenant is parameter $1 of the query, and args are $2 and up.
+func (t *Tracker) list(ctx context.Context, where string, args ...interface{}) ([]*Request, error) {
+	args = append([]interface{}{tenant.FromContext(ctx)}, args...)
+	q := `
+		SELECT tx_hash, template, status, created_by, created_at, updated_at, expires_at
+		FROM signing_requests
+		WHERE ($1='' OR tenant=$1) AND ` + where
+	var reqs []*Request
+	args = append(args, func(txID bc.Hash, tpl []byte, status, createdBy string, createdAt, updatedAt time.Time, expiresAt *time.Time) error {
+		r := &Request{
+			TxID:      txID,
+			Status:    status,
+			Template:  new(signing.Template),
+			CreatedBy: createdBy,
+			CreatedAt: createdAt,
+			UpdatedAt: updatedAt,
+			ExpiresAt: expiresAt,
+		}
+		err := json.Unmarshal(tpl, r.Template)
+		if err != nil {
+			return errors.Wrap(err, "decoding stored template")
+		}
+		reqs = append(reqs, r)
+		return nil
+	})
+	err := pg.ForQueryRows(ctx, t.DB, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing signing requests")
+	}
+
+	var xpubs []string
+	for _, r := range reqs {
+		declines, err := loadDeclines(ctx, t.DB, r.TxID)
+		if err != nil {
+			return nil, err
+		}
+		_, r.Inputs, r.Keys = summarize(r.Template, declines)
+		for _, k := range r.Keys {
+			xpubs = append(xpubs, k.XPub)
+		}
+	}
+	mds, err := signers.GetKeyMetadata(ctx, t.DB, xpubs)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reqs {
+		for _, k := range r.Keys {
+			k.Metadata = mds[k.XPub]
+		}
+	}
+	return reqs, nil
+}
+
+// Events returns the events of signing requests
+// visible to the tenant ctx acts for, newest first.
+func (t *Tracker) Events(ctx context.Context, after string, limit int) ([]*Event, string, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	const q = `
+		SELECT e.id, e.tx_hash, e.type, e.xpub, e.position, e.operator, e.created_at
+		FROM signing_events e JOIN signing_requests r ON r.tx_hash=e.tx_hash
+		WHERE ($1='' OR r.tenant=$1) AND ($2='' OR e.id<$2)
+		ORDER BY e.id DESC LIMIT $3
+	`
+	var evs []*Event
+	err := pg.ForQueryRows(ctx, t.DB, q, tenant.FromContext(ctx), after, limit, func(id string, txID bc.Hash, typ, xpub string, pos *int, operator string, ts time.Time) {
+		evs = append(evs, &Event{
+			ID:        id,
+			TxID:      txID,
+			Type:      typ,
+			XPub:      xpub,
+			Position:  pos,
+			Operator:  operator,
+			Timestamp: ts,
+		})
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing signing events")
+	}
+	if len(evs) > 0 {
+		after = evs[len(evs)-1].ID
+	}
+	return evs, after, nil
+}
+
+// ExpireRequests marks, every period, the pending signing
+// requests whose transactions' maxtime has passed as expired.
+// It returns when its context is canceled. It should run only
+// in the leader process.
+func (t *Tracker) ExpireRequests(ctx context.Context, period time.Duration) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, ExpireRequests exiting")
+			return
+		case <-ticks:
+			err := t.expire(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+func (t *Tracker) expire(ctx context.Context) error {
+	dbtx, err := t.DB.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer dbtx.Rollback(ctx)
+
+	const q = `
+		UPDATE signing_requests SET status=$1, updated_at=now()
+		WHERE status=$2 AND expires_at < now()
+		RETURNING tx_hash
+	`
+	var evs []*Event
+	err = pg.ForQueryRows(ctx, dbtx, q, StatusExpired, StatusPending, func(txID bc.Hash) {
+		evs = append(evs, &Event{TxID: txID, Type: EventExpired})
+	})
+	if err != nil {
+		return errors.Wrap(err, "expiring signing requests")
+	}
+	err = t.recordEvents(ctx, dbtx, evs)
+	if err != nil {
+		return err
+	}
+	err = dbtx.Commit(ctx)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	t.publish(ctx, evs)
+	return nil
+}
+
+func (t *Tracker) recordEvents(ctx context.Context, db pg.DB, evs []*Event) error {
+	const q = `
+		INSERT INTO signing_events (tx_hash, type, xpub, position, operator)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	for _, e := range evs {
+		err := db.QueryRow(ctx, q, e.TxID.String(), e.Type, e.XPub, e.Position, e.Operator).Scan(&e.ID, &e.Timestamp)
+		if err != nil {
+			return errors.Wrap(err, "recording signing event")
+		}
+	}
+	return nil
+}
+
+// publish logs evs and sends them to the message bus, if any.
+// Events that can't be published are still in the database.
+func (t *Tracker) publish(ctx context.Context, evs []*Event) {
+	for _, e := range evs {
+		log.Write(ctx, log.KeyMessage, "signing request "+e.Type, log.KeyTxHash, e.TxID, "xpub", e.XPub, "operator", e.Operator)
+		if t.Publisher == nil {
+			continue
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Error(ctx, err)
+			continue
+		}
+		err = t.Publisher.Publish(ctx, t.Topic, e.TxID.String(), b)
+		if err != nil {
+			log.Error(ctx, err, "publishing signing event", e.ID)
+		}
+	}
+}
+
+func loadDeclines(ctx context.Context, db pg.DB, txID bc.Hash) (map[string]decline, error) {
+	const q = `SELECT xpub, declined_by, reason FROM signing_declines WHERE tx_hash=$1`
+	declines := make(map[string]decline)
+	err := pg.ForQueryRows(ctx, db, q, txID.String(), func(xpub, by, reason string) {
+		declines[xpub] = decline{by, reason}
+	})
+	return declines, errors.Wrap(err, "loading declines")
+}
+
+type newSig struct {
+	xpub string
+	pos  int
+}
+
+// A witness is a signature witness of the input at pos.
+type witness struct {
+	pos int
+	*signing.SignatureWitness
+}
+
+func signatureWitnesses(tpl *signing.Template) []witness {
+	var ws []witness
+	for _, si := range tpl.SigningInstructions {
+		for _, c := range si.WitnessComponents {
+			if sw, ok := c.(*signing.SignatureWitness); ok {
+				ws = append(ws, witness{si.Position, sw})
+			}
+		}
+	}
+	return ws
+}
+
+// mergeSigs copies into the signature witnesses of dst the
+// signatures in src that dst lacks and that verify, and
+// returns the keys and inputs of the signatures copied.
+func mergeSigs(dst, src *signing.Template) []newSig {
+	var added []newSig
+	srcWits := signatureWitnesses(src)
+	for _, dw := range signatureWitnesses(dst) {
+		for _, sw := range srcWits {
+			if sw.pos != dw.pos {
+				continue
+			}
+			if len(dw.Program) > 0 && !bytes.Equal(dw.Program, sw.Program) {
+				continue
+			}
+			for i, key := range dw.Keys {
+				if signed(dw, i) {
+					continue
+				}
+				sig := findSig(sw, key)
+				if len(sig) == 0 || !verify(key, sw.Program, sig) {
+					continue
+				}
+				if len(dw.Sigs) < len(dw.Keys) {
+					sigs := make([]chainjson.HexBytes, len(dw.Keys))
+					copy(sigs, dw.Sigs)
+					dw.Sigs = sigs
+				}
+				dw.Program = sw.Program
+				dw.Sigs[i] = sig
+				added = append(added, newSig{key.XPub, dw.pos})
+			}
+		}
+	}
+	return added
+}
+
+func signed(w witness, i int) bool {
+	return i < len(w.Sigs) && len(w.Sigs[i]) > 0
+}
+
+// findSig returns the signature in w made with key,
+// if there is one.
+func findSig(w witness, key signing.KeyID) []byte {
+	for i, k := range w.Keys {
+		if k.XPub == key.XPub && samePath(k.DerivationPath, key.DerivationPath) && signed(w, i) {
+			return w.Sigs[i]
+		}
+	}
+	return nil
+}
+
+func samePath(a, b []chainjson.HexBytes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// verify reports whether sig is a signature of prog
+// by the key derived from key's xpub along its path.
+func verify(key signing.KeyID, prog, sig []byte) bool {
+	if len(prog) == 0 {
+		return false
+	}
+	var xpub chainkd.XPub
+	err := xpub.UnmarshalText([]byte(key.XPub))
+	if err != nil {
+		return false
+	}
+	var path [][]byte
+	for _, p := range key.DerivationPath {
+		path = append(path, p)
+	}
+	var h [32]byte
+	sha3pool.Sum256(h[:], prog)
+	return xpub.Derive(path).Verify(h[:], sig)
+}
+
+// summarize computes the status of a signing request for
+// tpl, and of its inputs and keys, given the declines.
+func summarize(tpl *signing.Template, declines map[string]decline) (string, []*InputStatus, []*KeyStatus) {
+	var (
+		inputs   []*InputStatus
+		keys     []*KeyStatus
+		byXPub   = make(map[string]*KeyStatus)
+		unsigned = make(map[string]bool)
+		status   = StatusSigned
+	)
+	ws := signatureWitnesses(tpl)
+	for _, w := range ws {
+		in := &InputStatus{Position: w.pos, Quorum: w.Quorum}
+		var available int
+		for i, key := range w.Keys {
+			k := byXPub[key.XPub]
+			if k == nil {
+				k = &KeyStatus{XPub: key.XPub, Status: KeySigned}
+				if d, ok := declines[key.XPub]; ok {
+					k.Status, k.DeclinedBy, k.Reason = KeyDeclined, d.by, d.reason
+				}
+				byXPub[key.XPub] = k
+				keys = append(keys, k)
+			}
+			k.Positions = appendPos(k.Positions, w.pos)
+			switch {
+			case signed(w, i):
+				in.Signatures++
+			case k.Status != KeyDeclined:
+				k.Status = KeyPending
+				unsigned[key.XPub] = true
+				available++
+			}
+		}
+		inputs = append(inputs, in)
+		if in.Signatures+available < in.Quorum {
+			status = StatusDeclined
+		} else if in.Signatures < in.Quorum && status == StatusSigned {
+			status = StatusPending
+		}
+	}
+	for _, w := range ws {
+		if status != StatusPending {
+			break
+		}
+		var sigs int
+		for i := range w.Keys {
+			if signed(w, i) {
+				sigs++
+			}
+		}
+		if sigs >= w.Quorum {
+			continue
+		}
+		for i, key := range w.Keys {
+			if !signed(w, i) && unsigned[key.XPub] {
+				byXPub[key.XPub].Needed = true
+			}
+		}
+	}
+	return status, inputs, keys
+}
+
+func appendPos(ps []int, pos int) []int {
+	if len(ps) > 0 && ps[len(ps)-1] == pos {
+		return ps
+	}
+	return append(ps, pos)
+}
+
+// expiry returns when the maxtime of tx passes, if it has one.
+func expiry(tx *bc.TxData) *time.Time {
+	if tx.MaxTime == 0 {
+		return nil
+	}
+	t := time.Unix(0, int64(tx.MaxTime)*int64(time.Millisecond)).UTC()
+	return &t
+}
@@ -0,0 +1,154 @@
+package signreq
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestSigningProgress(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	tr := &Tracker{DB: db}
+
+	xprv1, xpub1 := newKey(t)
+	xprv2, xpub2 := newKey(t)
+	_, xpub3 := newKey(t)
+	path := []chainjson.HexBytes{{1}, {2}}
+	prog := chainjson.HexBytes{0x51}
+
+	// Input 0 needs two of the three keys.
+	sw := &signing.SignatureWitness{
+		Quorum:  2,
+		Program: prog,
+		Keys: []signing.KeyID{
+			{XPub: xpub1.String(), DerivationPath: path},
+			{XPub: xpub2.String(), DerivationPath: path},
+			{XPub: xpub3.String(), DerivationPath: path},
+		},
+		Sigs: []chainjson.HexBytes{sign(xprv1, path, prog), nil, {1, 2, 3}},
+	}
+	tpl := &signing.Template{
+		Transaction: &bc.TxData{Version: 1, MaxTime: 1 << 62},
+		SigningInstructions: []*signing.SigningInstruction{{
+			WitnessComponents: []signing.WitnessComponent{sw},
+		}},
+	}
+	txID := tpl.Transaction.Hash()
+
+	// The bad signature of key 3 isn't kept.
+	req, err := tr.Track(ctx, tpl, "tok1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if req.Status != StatusPending || req.Inputs[0].Signatures != 1 {
+		t.Fatalf("Track: status %s with %d signatures, want pending with 1", req.Status, req.Inputs[0].Signatures)
+	}
+	want := []string{KeySigned, KeyPending, KeyPending}
+	for i, k := range req.Keys {
+		if k.Status != want[i] || k.Needed != (i > 0) {
+			t.Errorf("key %d: status %s needed %t, want %s needed %t", i, k.Status, k.Needed, want[i], i > 0)
+		}
+	}
+
+	// Holder of key 3 declines; key 2 is still enough.
+	req, err = tr.Decline(ctx, txID, xpub3.String(), "not mine", "tok3")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if req.Status != StatusPending || req.Keys[2].Status != KeyDeclined || req.Keys[2].Reason != "not mine" {
+		t.Fatalf("Decline: status %s, key 3 %+v", req.Status, req.Keys[2])
+	}
+
+	// Key 2 signs in another copy of the template.
+	sw.Sigs = []chainjson.HexBytes{nil, sign(xprv2, path, prog), nil}
+	req, err = tr.Update(ctx, tpl, "tok2")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if req.Status != StatusSigned || req.Inputs[0].Signatures != 2 {
+		t.Fatalf("Update: status %s with %d signatures, want signed with 2", req.Status, req.Inputs[0].Signatures)
+	}
+	got := req.Template.SigningInstructions[0].WitnessComponents[0].(*signing.SignatureWitness)
+	if len(got.Sigs[0]) == 0 || len(got.Sigs[1]) == 0 {
+		t.Errorf("stored template lacks signatures: %v", got.Sigs)
+	}
+
+	evs, _, err := tr.Events(ctx, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantEvs := []string{EventSigned, EventDeclined, EventSigned, EventCreated}
+	if len(evs) != len(wantEvs) {
+		t.Fatalf("got %d events, want %d", len(evs), len(wantEvs))
+	}
+	for i, e := range evs {
+		if e.Type != wantEvs[i] {
+			t.Errorf("event %d = %s, want %s", i, e.Type, wantEvs[i])
+		}
+	}
+
+	// Untracked transactions are left alone.
+	other := &signing.Template{Transaction: &bc.TxData{Version: 1, MinTime: 1}}
+	req, err = tr.Update(ctx, other, "tok2")
+	if err != nil || req != nil {
+		t.Errorf("Update(untracked) = %v, %v, want nil", req, err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	tr := &Tracker{DB: db}
+
+	_, xpub := newKey(t)
+	tpl := &signing.Template{
+		Transaction: &bc.TxData{Version: 1, MaxTime: 2},
+		SigningInstructions: []*signing.SigningInstruction{{
+			WitnessComponents: []signing.WitnessComponent{&signing.SignatureWitness{
+				Quorum: 1,
+				Keys:   []signing.KeyID{{XPub: xpub.String()}},
+			}},
+		}},
+	}
+	_, err := tr.Track(ctx, tpl, "tok1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = tr.expire(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	reqs, _, err := tr.List(ctx, StatusExpired, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(reqs) != 1 || reqs[0].TxID != tpl.Transaction.Hash() {
+		t.Errorf("List(expired) = %v, want the expired request", reqs)
+	}
+}
+
+func newKey(t *testing.T) (chainkd.XPrv, chainkd.XPub) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	return xprv, xpub
+}
+
+func sign(xprv chainkd.XPrv, path []chainjson.HexBytes, prog []byte) chainjson.HexBytes {
+	var p [][]byte
+	for _, b := range path {
+		p = append(p, b)
+	}
+	var h [32]byte
+	sha3pool.Sum256(h[:], prog)
+	return xprv.Derive(p).Sign(h[:])
+}
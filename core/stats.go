@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+const (
+	// statsTTL is how long /stats responds
+	// with the same, cached statistics.
+	statsTTL = 10 * time.Second
+
+	// Transaction throughput is counted over
+	// statsBuckets buckets of statsBucketSize.
+	statsBuckets    = 12
+	statsBucketSize = 5 * time.Minute
+)
+
+type statsResp struct {
+	Time        time.Time `json:"time"`
+	BlockHeight uint64    `json:"block_height"`
+
+	// Throughput counts the blocks and transactions in each
+	// recent period of time, oldest first.
+	Throughput []*throughputBucket `json:"transaction_throughput"`
+
+	// PendingTransactions is the size of the pending
+	// transaction pool. Only the generator has one.
+	PendingTransactions *int `json:"pending_transactions,omitempty"`
+
+	BlockIntervals *blockIntervals `json:"block_intervals"`
+
+	AssetCount      uint64 `json:"asset_count"`
+	LocalAssetCount uint64 `json:"local_asset_count"`
+
+	// IndexLag is the number of blocks each block
+	// processor has yet to process.
+	IndexLag map[string]uint64 `json:"index_lag"`
+
+	// Health is as /health reports it.
+	Health map[string]interface{} `json:"health"`
+}
+
+type throughputBucket struct {
+	Start        time.Time `json:"start"`
+	Blocks       uint64    `json:"blocks"`
+	Transactions uint64    `json:"transactions"`
+}
+
+// blockIntervals describes the time between the blocks
+// in the throughput buckets, and since the latest block.
+type blockIntervals struct {
+	AverageMS   uint64 `json:"average_ms"`
+	MaxMS       uint64 `json:"max_ms"`
+	SinceLastMS uint64 `json:"since_last_ms"`
+}
+
+// POST /stats
+//
+// Responds with statistics for a dashboard, computed at most
+// once every 10 seconds: transaction throughput over the last
+// hour, the pending transaction pool, block intervals, asset
+// counts, index lag, and health.
+func (h *Handler) stats(ctx context.Context) (*statsResp, error) {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	if h.cachedStats != nil && time.Since(h.cachedStats.Time) < statsTTL {
+		return h.cachedStats, nil
+	}
+	s, err := h.computeStats(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	h.cachedStats = s
+	return s, nil
+}
+
+func (h *Handler) computeStats(ctx context.Context, now time.Time) (*statsResp, error) {
+	s := &statsResp{
+		Time:           now,
+		BlockIntervals: new(blockIntervals),
+		IndexLag:       make(map[string]uint64),
+		Health:         h.health().Errors,
+	}
+	if h.Chain != nil {
+		s.BlockHeight = h.Chain.Height()
+	}
+	if h.Pool != nil {
+		n := h.Pool.Len()
+		s.PendingTransactions = &n
+	}
+
+	start := now.Truncate(statsBucketSize).Add(-(statsBuckets - 1) * statsBucketSize)
+	for i := 0; i < statsBuckets; i++ {
+		s.Throughput = append(s.Throughput, &throughputBucket{
+			Start: start.Add(time.Duration(i) * statsBucketSize).UTC(),
+		})
+	}
+	const bucketsQ = `
+		SELECT (b.timestamp - $1) / $2, COUNT(DISTINCT b.height), COUNT(t.tx_pos)
+		FROM query_blocks b LEFT JOIN annotated_txs t ON t.block_height=b.height
+		WHERE b.timestamp >= $1
+		GROUP BY 1
+	`
+	err := pg.ForQueryRows(ctx, h.DB, bucketsQ, bc.Millis(start), bc.DurationMillis(statsBucketSize), func(i int, blocks, txs uint64) {
+		if i >= 0 && i < statsBuckets {
+			s.Throughput[i].Blocks = blocks
+			s.Throughput[i].Transactions = txs
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "counting transactions")
+	}
+
+	const intervalsQ = `
+		SELECT COALESCE(MAX(timestamp), 0), COALESCE(MIN(timestamp), 0),
+			COUNT(*), COALESCE(MAX(interval), 0)
+		FROM (
+			SELECT timestamp, timestamp - LAG(timestamp) OVER (ORDER BY height) AS interval
+			FROM query_blocks WHERE timestamp >= $1
+		) b
+	`
+	var latest, earliest, n uint64
+	err = h.DB.QueryRow(ctx, intervalsQ, bc.Millis(start)).Scan(&latest, &earliest, &n, &s.BlockIntervals.MaxMS)
+	if err != nil {
+		return nil, errors.Wrap(err, "measuring block intervals")
+	}
+	if n > 1 {
+		s.BlockIntervals.AverageMS = (latest - earliest) / (n - 1)
+	}
+	if latest > 0 && bc.Millis(now) > latest {
+		s.BlockIntervals.SinceLastMS = bc.Millis(now) - latest
+	}
+
+	const assetsQ = `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE data->>'is_local'='yes')
+		FROM annotated_assets
+	`
+	err = h.DB.QueryRow(ctx, assetsQ).Scan(&s.AssetCount, &s.LocalAssetCount)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting assets")
+	}
+
+	const pinsQ = `SELECT name, height FROM block_processors`
+	err = pg.ForQueryRows(ctx, h.DB, pinsQ, func(name string, height uint64) {
+		var lag uint64
+		if s.BlockHeight > height {
+			lag = s.BlockHeight - height
+		}
+		s.IndexLag[name] = lag
+	})
+	return s, errors.Wrap(err, "measuring index lag")
+}
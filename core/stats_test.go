@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/mempool"
+	"chain/protocol/prottest"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	c := prottest.NewChain(t)
+	indexer := query.NewIndexer(db, c, pin.NewStore(db))
+	pool := mempool.New()
+	h := &Handler{DB: db, Chain: c, Indexer: indexer, Pool: pool}
+
+	now := time.Now()
+	for i, ago := range []time.Duration{10 * time.Minute, 9 * time.Minute, time.Minute} {
+		block := &bc.Block{
+			BlockHeader: bc.BlockHeader{
+				Version:     1,
+				Height:      uint64(i + 1),
+				TimestampMS: bc.Millis(now.Add(-ago)),
+			},
+			Transactions: []*bc.Tx{bc.NewTx(bc.TxData{Version: 1, MinTime: uint64(i)})},
+		}
+		err := indexer.IndexTransactions(ctx, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := pool.Insert(ctx, bc.NewTx(bc.TxData{Version: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := h.computeStats(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks, txs uint64
+	for _, b := range s.Throughput {
+		blocks += b.Blocks
+		txs += b.Transactions
+	}
+	if len(s.Throughput) != statsBuckets || blocks != 3 || txs != 3 {
+		t.Errorf("throughput = %d buckets, %d blocks, %d txs, want %d, 3, 3", len(s.Throughput), blocks, txs, statsBuckets)
+	}
+	if s.PendingTransactions == nil || *s.PendingTransactions != 1 {
+		t.Errorf("pending transactions = %v want 1", s.PendingTransactions)
+	}
+	wantMax := bc.DurationMillis(8 * time.Minute)
+	if s.BlockIntervals.MaxMS != wantMax {
+		t.Errorf("max block interval = %dms want %dms", s.BlockIntervals.MaxMS, wantMax)
+	}
+	if s.BlockIntervals.SinceLastMS != bc.DurationMillis(time.Minute) {
+		t.Errorf("time since last block = %dms want 60000ms", s.BlockIntervals.SinceLastMS)
+	}
+}
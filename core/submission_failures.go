@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"chain/core/approval"
+	"chain/core/deadletter"
+	"chain/core/leader"
+	"chain/core/txbuilder/signing"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+// recordSubmission records a failed submission of tpl, ending
+// with err, in the dead-letter queue, or resolves the queued
+// failure of tpl if err is nil. Transactions awaiting approval,
+// and requests that timed out while waiting for confirmation,
+// haven't failed, and aren't recorded.
+func (h *Handler) recordSubmission(ctx context.Context, tpl *signing.Template, err error) {
+	if h.SubmissionFailures == nil || tpl.Transaction == nil {
+		return
+	}
+	if err == nil {
+		err = h.SubmissionFailures.Resolve(ctx, tpl.Transaction.Hash())
+		if err != nil {
+			log.Error(ctx, err)
+		}
+		return
+	}
+	switch errors.Root(err) {
+	case approval.ErrPending, context.Canceled, context.DeadlineExceeded:
+		return
+	}
+
+	// Unlike the response to the request, the failure keeps the
+	// full message of an error with no detail, such as an
+	// unreachable generator, for the operator to see.
+	body, _ := errInfo(err)
+	if body.Detail == "" {
+		body.Detail = err.Error()
+	}
+	f := &deadletter.Failure{
+		Template: tpl,
+		Code:     body.ChainCode,
+		Message:  body.Message,
+		Detail:   body.Detail,
+		Data:     body.Data,
+	}
+	if user, _, ok := httpjson.Request(ctx).BasicAuth(); ok {
+		f.Submitter = user
+	}
+	err = h.SubmissionFailures.Record(ctx, f)
+	if err != nil {
+		log.Error(ctx, err)
+	}
+}
+
+// POST /list-submission-failures
+func (h *Handler) listSubmissionFailures(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	failures, after, err := h.SubmissionFailures.List(ctx, query.Status, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(failures),
+		LastPage: len(failures) < limit,
+		Next:     query,
+	}, nil
+}
+
+// POST /retry-submission-failure
+//
+// Submits the failed transaction again, responding
+// as /submit-transaction would for it.
+func (h *Handler) retrySubmissionFailure(ctx context.Context, in struct {
+	ID        string `json:"id"`
+	WaitUntil string `json:"wait_until"`
+}) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp interface{}
+		err := h.forwardToLeader(ctx, "/retry-submission-failure", in, &resp)
+		return resp, err
+	}
+
+	f, err := h.SubmissionFailures.Find(ctx, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	if f.Status != deadletter.StatusFailed {
+		return nil, errors.WithDetailf(deadletter.ErrClosed, "submission failure %s is %s", f.ID, f.Status)
+	}
+
+	ctx, err = h.submissionContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return h.submitSingle(ctx, f.Template, in.WaitUntil)
+}
+
+// POST /dismiss-submission-failure
+func (h *Handler) dismissSubmissionFailure(ctx context.Context, in struct {
+	ID string `json:"id"`
+}) error {
+	return h.SubmissionFailures.Dismiss(ctx, in.ID)
+}
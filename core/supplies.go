@@ -0,0 +1,69 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// errSuppliesUntracked is returned when the core's state
+// doesn't include asset supplies, as when it was bootstrapped
+// from a snapshot that predates them.
+var errSuppliesUntracked = errors.New("asset supplies aren't tracked")
+
+type assetSupply struct {
+	AssetID     bc.AssetID `json:"asset_id"`
+	Issued      uint64     `json:"issued"`
+	Retired     uint64     `json:"retired"`
+	Circulating uint64     `json:"circulating"`
+}
+
+// POST /get-asset-supplies
+//
+// Responds with the cumulative issued, retired and circulating
+// amounts of the given assets, or of every asset ever issued if
+// none are given, as of the latest block.
+func (h *Handler) getAssetSupplies(ctx context.Context, in struct {
+	AssetIDs []bc.AssetID `json:"asset_ids"`
+}) (interface{}, error) {
+	block, snapshot := h.Chain.State()
+	if snapshot == nil || snapshot.Supplies == nil {
+		return nil, errSuppliesUntracked
+	}
+
+	ids := in.AssetIDs
+	if len(ids) == 0 {
+		for id := range snapshot.Supplies {
+			ids = append(ids, id)
+		}
+		sort.Sort(byAssetID(ids))
+	}
+	supplies := make([]assetSupply, 0, len(ids))
+	for _, id := range ids {
+		s := snapshot.Supplies[id]
+		supplies = append(supplies, assetSupply{
+			AssetID:     id,
+			Issued:      s.Issued,
+			Retired:     s.Retired,
+			Circulating: s.Circulating(),
+		})
+	}
+
+	var height uint64
+	if block != nil {
+		height = block.Height
+	}
+	return map[string]interface{}{
+		"block_height": height,
+		"supplies":     supplies,
+	}, nil
+}
+
+type byAssetID []bc.AssetID
+
+func (a byAssetID) Len() int           { return len(a) }
+func (a byAssetID) Less(i, j int) bool { return bytes.Compare(a[i][:], a[j][:]) < 0 }
+func (a byAssetID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,37 @@
+// Package tenant identifies the tenant on whose behalf a request
+// to Chain Core is made.
+//
+// Each access token, account, and asset belongs to one tenant.
+// A request authenticated with a tenant's access token can see
+// only that tenant's objects. The default tenant, named by the
+// empty string, is the operator of the Core: requests made on its
+// behalf, including those authenticated other than by access
+// token, can see every tenant's objects.
+package tenant
+
+import "context"
+
+// Default is the ID of the default tenant.
+const Default = ""
+
+type key struct{}
+
+// NewContext returns a context for requests made on behalf of
+// tenant id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the ID of the tenant that ctx acts for.
+// It returns Default if ctx carries no tenant.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// CanSee reports whether the tenant that ctx acts for
+// may see an object belonging to tenant owner.
+func CanSee(ctx context.Context, owner string) bool {
+	id := FromContext(ctx)
+	return id == Default || id == owner
+}
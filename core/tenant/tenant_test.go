@@ -0,0 +1,28 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCanSee(t *testing.T) {
+	ctx := context.Background()
+	acme := NewContext(ctx, "acme")
+
+	cases := []struct {
+		ctx   context.Context
+		owner string
+		want  bool
+	}{
+		{ctx, Default, true},
+		{ctx, "acme", true},
+		{acme, "acme", true},
+		{acme, Default, false},
+		{acme, "globex", false},
+	}
+	for _, c := range cases {
+		if got := CanSee(c.ctx, c.owner); got != c.want {
+			t.Errorf("CanSee(%q, %q) = %v want %v", FromContext(c.ctx), c.owner, got, c.want)
+		}
+	}
+}
@@ -3,28 +3,52 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 	"sync"
 	"time"
 
+	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/fetch"
 	"chain/core/leader"
 	"chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/database/pg"
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
 )
 
 var defaultTxTTL = 5 * time.Minute
 
-func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Template, error) {
+var (
+	errNoPriorityQuota = errors.New("no high-priority quota")
+	errAmendSubmitted  = errors.New("transaction already submitted")
+)
+
+func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*signing.Template, error) {
+	actions, err := h.decodeActions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return h.buildActions(ctx, req.Tx, actions, req.TTL.Duration)
+}
+
+// decodeActions decodes the actions of req.
+func (h *Handler) decodeActions(ctx context.Context, req *buildRequest) ([]txbuilder.Action, error) {
 	err := h.filterAliases(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	err = h.convertDisplayAmounts(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	actions := make([]txbuilder.Action, 0, len(req.Actions))
 	for i, act := range req.Actions {
 		typ, ok := act["type"].(string)
@@ -48,25 +72,69 @@ func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*txbuilde
 		}
 		actions = append(actions, a)
 	}
+	return actions, nil
+}
+
+// buildActions builds a transaction template from actions,
+// on top of tx, if it's not nil, expiring after ttl, or after
+// the default TTL if ttl is zero.
+func (h *Handler) buildActions(ctx context.Context, tx *bc.TxData, actions []txbuilder.Action, ttl time.Duration) (*signing.Template, error) {
+	tpl, err := txbuilder.Build(ctx, tx, actions, h.maxTime(ttl))
+	if err != nil {
+		return nil, buildError(err)
+	}
+	err = h.checkBuilt(ctx, tpl)
+	if err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
 
-	ttl := req.TTL.Duration
+// maxTime returns when a transaction built now should
+// expire, given the ttl of its build request.
+func (h *Handler) maxTime(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		ttl = h.TxTTL
+	}
 	if ttl == 0 {
 		ttl = defaultTxTTL
 	}
-	maxTime := time.Now().Add(ttl)
-	tpl, err := txbuilder.Build(ctx, req.Tx, actions, maxTime)
+	return time.Now().Add(ttl)
+}
+
+// buildError attaches the errors of the
+// individual actions to err, if it has them.
+func buildError(err error) error {
 	if errors.Root(err) == txbuilder.ErrAction {
 		err = errors.WithData(err, "actions", errInfoBodyList(errors.Data(err)["actions"].([]error)))
 	}
+	return err
+}
+
+// checkBuilt checks a newly built or amended template against
+// asset freezes and, if it's complete, asks for the approval of
+// large transfers. Then it records the template's build
+// commitments for the MockHSM.
+func (h *Handler) checkBuilt(ctx context.Context, tpl *signing.Template) error {
+	err := h.checkFrozen(ctx, tpl.Transaction)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	// Ask for approval of large transfers as soon as they're built.
+	// Only a complete transaction has a final ID to approve.
+	if tpl.Local {
+		err = h.requireApproval(ctx, tpl.Transaction)
+		if err != nil && errors.Root(err) != approval.ErrPending {
+			return err
+		}
 	}
 
 	// ensure null is never returned for signing instructions
 	if tpl.SigningInstructions == nil {
-		tpl.SigningInstructions = []*txbuilder.SigningInstruction{}
+		tpl.SigningInstructions = []*signing.SigningInstruction{}
 	}
-	return tpl, nil
+	return recordCommitments(ctx, h.DB, tpl)
 }
 
 // POST /build-transaction
@@ -103,11 +171,140 @@ func (h *Handler) build(ctx context.Context, buildReqs []*buildRequest) (interfa
 	return responses, nil
 }
 
-func (h *Handler) submitSingle(ctx context.Context, tpl *txbuilder.Template, waitUntil string) (interface{}, error) {
+// POST /amend-transaction
+//
+// Amends a template that hasn't been submitted: it sets the
+// amounts of outputs given in output_amounts, then builds actions
+// on top of the transaction. Signatures the amended transaction
+// still satisfies are kept; the response lists the positions
+// of the inputs that must be signed again.
+func (h *Handler) amendTransaction(ctx context.Context, req struct {
+	Template      *signing.Template        `json:"template"`
+	OutputAmounts []txbuilder.OutputAmount `json:"output_amounts"`
+	Actions       []map[string]interface{} `json:"actions"`
+	TTL           chainjson.Duration       `json:"ttl"`
+}) (interface{}, error) {
+	// Actions may reserve outputs, which only the leader tracks.
+	if !leader.IsLeading() {
+		var resp map[string]interface{}
+		err := h.forwardToLeader(ctx, "/amend-transaction", req, &resp)
+		return resp, err
+	}
+	if req.Template == nil || req.Template.Transaction == nil {
+		return nil, errors.WithDetail(txbuilder.ErrBadAmendment, "template has no transaction")
+	}
+	submitted, err := txSubmitted(ctx, h.DB, req.Template.Transaction.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if submitted {
+		return nil, errors.WithDetailf(errAmendSubmitted, "transaction %s", req.Template.Transaction.Hash())
+	}
+
+	actions, err := h.decodeActions(ctx, &buildRequest{Actions: req.Actions})
+	if err != nil {
+		return nil, err
+	}
+	built := func(c []byte) (bool, error) {
+		return builtCommitment(ctx, h.DB, c)
+	}
+	tpl, resign, err := txbuilder.Amend(ctx, req.Template, req.OutputAmounts, actions, h.maxTime(req.TTL.Duration), built)
+	if err != nil {
+		return nil, buildError(err)
+	}
+	err = h.checkBuilt(ctx, tpl)
+	if err != nil {
+		return nil, err
+	}
+	if resign == nil {
+		resign = []int{}
+	}
+	return map[string]interface{}{
+		"template":         tpl,
+		"resign_positions": resign,
+	}, nil
+}
+
+// POST /build-transfer-batch
+//
+// It groups many payments into as few transactions as the
+// limits allow (see account.BatchPayments) and builds each.
+// It responds with the transactions, each with the indexes of
+// the payments it makes and its template, or the error
+// building it.
+func (h *Handler) buildTransferBatch(ctx context.Context, req struct {
+	Payments []account.Payment `json:"payments"`
+	account.BatchLimits
+	TTL chainjson.Duration `json:"ttl"`
+}) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp interface{}
+		err := h.forwardToLeader(ctx, "/build-transfer-batch", req, &resp)
+		return resp, err
+	}
+
+	batches, err := h.Accounts.BatchPayments(req.Payments, req.BatchLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]map[string]interface{}, 0, len(batches))
+	for _, b := range batches {
+		var tpl interface{}
+		func() {
+			subctx := reqid.NewSubContext(ctx, reqid.New())
+			defer batchRecover(subctx, &tpl)
+
+			tpl, err = h.buildActions(subctx, nil, b.Actions, req.TTL.Duration)
+			if err != nil {
+				tpl = err
+			}
+		}()
+		responses = append(responses, map[string]interface{}{
+			"payments":    b.Payments,
+			"transaction": tpl,
+		})
+	}
+	return map[string]interface{}{"transactions": responses}, nil
+}
+
+// POST /describe-transaction
+//
+// It explains a transaction template for review before signing:
+// the accounts of this core it spends from and pays to, the
+// signatures still missing, and the totals of each asset.
+// See txbuilder.DescribeTemplate.
+func (h *Handler) describeTransaction(ctx context.Context, tpl *signing.Template) (*txbuilder.Description, error) {
+	if tpl.Transaction == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "missing raw_transaction")
+	}
+	var progs [][]byte
+	for _, in := range tpl.Transaction.Inputs {
+		if !in.IsIssuance() {
+			progs = append(progs, in.ControlProgram())
+		}
+	}
+	for _, out := range tpl.Transaction.Outputs {
+		progs = append(progs, out.ControlProgram)
+	}
+	accounts, err := h.Accounts.ControlProgramAccounts(ctx, progs)
+	if err != nil {
+		return nil, err
+	}
+	return txbuilder.DescribeTemplate(tpl, accounts)
+}
+
+func (h *Handler) submitSingle(ctx context.Context, tpl *signing.Template, waitUntil string) (interface{}, error) {
+	if tpl.Transaction != nil {
+		ctx = log.AddFields(ctx, log.KeyTxHash, tpl.Transaction.Hash())
+	}
+	h.updateSigningRequest(ctx, tpl)
 	err := h.finalizeTxWait(ctx, tpl, waitUntil)
+	h.recordSubmission(ctx, tpl, err)
 	if err != nil {
 		return nil, errors.Wrapf(err, "tx %s", tpl.Transaction.Hash())
 	}
+	h.scoreSubmission(ctx, bc.NewTx(*tpl.Transaction))
 
 	return map[string]string{"id": tpl.Transaction.Hash().String()}, nil
 }
@@ -146,6 +343,34 @@ func recordSubmittedTx(ctx context.Context, db pg.DB, txHash bc.Hash, currentHei
 	return height, err
 }
 
+// checkIssuanceReplay returns protocol.ErrDuplicateIssuance if an
+// issuance in tx is already confirmed, unless tx itself was
+// submitted before. Submitting a transaction again is allowed,
+// and waits for the confirmation of the original.
+func (h *Handler) checkIssuanceReplay(ctx context.Context, tx *bc.Tx) error {
+	err := h.Chain.CheckIssuanceReplay(tx)
+	if errors.Root(err) != protocol.ErrDuplicateIssuance {
+		return err
+	}
+	submitted, err2 := txSubmitted(ctx, h.DB, tx.Hash)
+	if err2 != nil {
+		return err2
+	}
+	if submitted {
+		return nil
+	}
+	return err
+}
+
+// txSubmitted reports whether transaction txHash
+// was submitted to this core in the last day.
+func txSubmitted(ctx context.Context, db pg.DB, txHash bc.Hash) (bool, error) {
+	const q = `SELECT EXISTS(SELECT 1 FROM submitted_txs WHERE tx_hash = $1)`
+	var submitted bool
+	err := db.QueryRow(ctx, q, txHash[:]).Scan(&submitted)
+	return submitted, errors.Wrap(err, "checking for earlier submission")
+}
+
 // CleanupSubmittedTxs will periodically delete records of submitted txs
 // older than a day. This function blocks and only exits when its context
 // is cancelled.
@@ -178,9 +403,28 @@ func CleanupSubmittedTxs(ctx context.Context, db pg.DB) {
 // confirmed on the blockchain.  ErrRejected means a conflicting tx is
 // on the blockchain.  context.DeadlineExceeded means ctx is an
 // expiring context that timed out.
-func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template, waitUntil string) error {
+func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *signing.Template, waitUntil string) error {
 	if txTemplate.Transaction == nil {
-		return errors.Wrap(txbuilder.ErrMissingRawTx)
+		return errors.Wrap(signing.ErrMissingRawTx)
+	}
+
+	// Signed transactions left unsubmitted past their maxtime
+	// can never be confirmed.
+	err := txbuilder.CheckExpiry(txTemplate.Transaction, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = txbuilder.CheckBlockchain(txTemplate.Transaction, h.Chain.InitialBlockHash)
+	if err != nil {
+		return err
+	}
+
+	// Issuances of cosigned assets need the cosigners'
+	// signatures before they're valid.
+	err = txbuilder.RequestCosignatures(ctx, txTemplate)
+	if err != nil {
+		return err
 	}
 
 	// Use the current generator height as the lower bound of the block height
@@ -191,14 +435,38 @@ func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Temp
 		generatorHeight = localHeight
 	}
 
-	// Remember this height in case we retry this submit call.
 	tx := bc.NewTx(*txTemplate.Transaction)
+	err = h.checkIssuanceReplay(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	// Remember this height in case we retry this submit call.
 	height, err := recordSubmittedTx(ctx, h.DB, tx.Hash, generatorHeight)
 	if err != nil {
 		return errors.Wrap(err, "saving tx submitted height")
 	}
 
-	err = txbuilder.FinalizeTx(ctx, h.Chain, tx)
+	err = h.checkFrozen(ctx, txTemplate.Transaction)
+	if err != nil {
+		return err
+	}
+
+	err = h.requireApproval(ctx, txTemplate.Transaction)
+	if err != nil {
+		return err
+	}
+
+	err = h.Accounts.EnforceLimits(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if h.Forwarder != nil {
+		err = h.Forwarder.Submit(ctx, tx)
+	} else {
+		err = txbuilder.FinalizeTx(ctx, h.Chain, tx)
+	}
 	if err != nil {
 		return err
 	}
@@ -262,9 +530,37 @@ func waitForTxInBlock(ctx context.Context, c *protocol.Chain, tx *bc.Tx, height
 }
 
 type submitArg struct {
-	Transactions []txbuilder.Template
+	// Each of Transactions may be given as a template object
+	// or, as from an offline signer, a string holding its
+	// compact encoding (see signing.EncodeCompact).
+	Transactions []signing.Template
 	wait         chainjson.Duration
 	WaitUntil    string `json:"wait_until"` // values none, confirmed, processed. default: processed
+	Priority     string // values low, normal, high. default: normal
+}
+
+// submissionContext returns a context telling the tx pool
+// which lane to use for transactions submitted in this request,
+// and how many of them the requesting access token
+// may have in the high-priority lane of each block.
+// Requests authenticated other than by access token
+// (see Handler.AltAuth) have no limit.
+func (h *Handler) submissionContext(ctx context.Context, priority string) (context.Context, error) {
+	p, err := mempool.ParsePriority(priority)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := mempool.Submission{Priority: p, Quota: mempool.Unlimited}
+	if user, _, ok := httpjson.Request(ctx).BasicAuth(); ok {
+		quota, err := h.AccessTokens.PriorityQuota(ctx, user)
+		if err != nil {
+			return nil, errors.Wrap(err, "looking up priority quota")
+		}
+		sub.Submitter = user
+		sub.Quota = quota
+	}
+	return mempool.NewContext(ctx, sub), nil
 }
 
 // POST /submit-transaction
@@ -275,6 +571,22 @@ func (h *Handler) submit(ctx context.Context, x submitArg) (interface{}, error)
 		return resp, err
 	}
 
+	ctx, err := h.submissionContext(ctx, x.Priority)
+	if err != nil {
+		return nil, err
+	}
+	if sub, _ := mempool.FromContext(ctx); sub.Priority == mempool.PriorityHigh && sub.Quota == 0 {
+		return nil, errors.WithDetailf(errNoPriorityQuota, "access token %s", sub.Submitter)
+	}
+	err = h.checkHalted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = h.checkBackpressure(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Setup a timeout for the provided wait duration.
 	timeout := x.wait.Duration
 	if timeout <= 0 {
@@ -302,5 +614,50 @@ func (h *Handler) submit(ctx context.Context, x submitArg) (interface{}, error)
 	}
 
 	wg.Wait()
+
+	// If the generator refused every transaction, refuse the
+	// request as a whole so the client backs off.
+	overloaded := len(responses) > 0
+	for _, resp := range responses {
+		err, ok := resp.(error)
+		overloaded = overloaded && ok && errors.Root(err) == txbuilder.ErrOverloaded
+	}
+	if overloaded {
+		setRetryAfter(ctx, h.submitRetryAfter())
+		return nil, responses[0].(error)
+	}
 	return responses, nil
 }
+
+// checkBackpressure returns txbuilder.ErrOverloaded, with the
+// number of pending transactions and when to retry, if this core
+// is a generator with at least h.MaxPendingTxs transactions in
+// its pool. It sets the Retry-After header of the response.
+func (h *Handler) checkBackpressure(ctx context.Context) error {
+	if h.MaxPendingTxs <= 0 || h.Pool == nil || h.Config == nil || !h.Config.IsGenerator {
+		return nil
+	}
+	n := h.Pool.Len()
+	if n < h.MaxPendingTxs {
+		return nil
+	}
+	retry := h.submitRetryAfter()
+	setRetryAfter(ctx, retry)
+	err := errors.WithData(txbuilder.ErrOverloaded,
+		"pending_transactions", n,
+		"max_pending_transactions", h.MaxPendingTxs,
+		"retry_after_seconds", int(retry/time.Second),
+	)
+	return errors.WithDetailf(err, "%d transactions pending, limit %d", n, h.MaxPendingTxs)
+}
+
+func (h *Handler) submitRetryAfter() time.Duration {
+	if h.SubmitRetryAfter < time.Second {
+		return 5 * time.Second
+	}
+	return h.SubmitRetryAfter
+}
+
+func setRetryAfter(ctx context.Context, d time.Duration) {
+	httpjson.ResponseWriter(ctx).Header().Set("Retry-After", strconv.Itoa(int(d/time.Second)))
+}
@@ -9,6 +9,14 @@ import (
 	"chain/protocol/bc"
 )
 
+func NewControlProgramAction(amt bc.AssetAmount, program []byte, refData json.Map) Action {
+	return &controlProgramAction{
+		AssetAmount:   amt,
+		Program:       program,
+		ReferenceData: refData,
+	}
+}
+
 func DecodeControlProgramAction(data []byte) (Action, error) {
 	a := new(controlProgramAction)
 	err := stdjson.Unmarshal(data, a)
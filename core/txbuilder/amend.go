@@ -0,0 +1,189 @@
+package txbuilder
+
+import (
+	"context"
+	"time"
+
+	"chain/core/txbuilder/signing"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+// ErrBadAmendment is returned when an amendment
+// can't be applied to a template.
+var ErrBadAmendment = errors.New("invalid template amendment")
+
+// OutputAmount sets the amount of the output
+// at Position of a template's transaction.
+type OutputAmount struct {
+	Position int    `json:"position"`
+	Amount   uint64 `json:"amount"`
+}
+
+// Amend changes the transaction of tpl, which must not yet have
+// been submitted. It sets the amounts of outputs given in amounts,
+// then builds actions on top of the transaction.
+//
+// Signatures already collected are kept for each input whose
+// signature program the amended transaction still satisfies:
+// typically inputs signed with AllowAdditional whose outputs
+// weren't changed. Signatures of other inputs are dropped, along
+// with their programs, so that only those inputs need signing
+// again. Amend returns the amended template and the positions of
+// the inputs to sign again. It doesn't change tpl.
+//
+// Amend commits the amended witnesses to the amended transaction,
+// so it first checks that each build commitment in tpl is one
+// built reports a trusted builder computed, and that tpl's
+// transaction still satisfies it; otherwise it returns
+// signing.ErrTemplateAltered.
+func Amend(ctx context.Context, tpl *signing.Template, amounts []OutputAmount, actions []Action, maxTime time.Time, built func(commitment []byte) (bool, error)) (*signing.Template, []int, error) {
+	if tpl.Transaction == nil {
+		return nil, nil, errors.WithDetail(ErrBadAmendment, "template has no transaction")
+	}
+	err := checkCommitments(tpl, built)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx := *tpl.Transaction
+	tx.Inputs = append([]*bc.TxInput(nil), tx.Inputs...)
+	tx.Outputs = make([]*bc.TxOutput, 0, len(tpl.Transaction.Outputs))
+	for _, out := range tpl.Transaction.Outputs {
+		o := *out
+		tx.Outputs = append(tx.Outputs, &o)
+	}
+	for _, a := range amounts {
+		if a.Position < 0 || a.Position >= len(tx.Outputs) {
+			return nil, nil, errors.WithDetailf(ErrBadAmendment, "transaction has no output %d", a.Position)
+		}
+		out := tx.Outputs[a.Position]
+		if out.Confidential != nil {
+			return nil, nil, errors.WithDetailf(ErrBadAmendment, "output %d has a confidential amount", a.Position)
+		}
+		if a.Amount == 0 || a.Amount > bc.MaxAmount {
+			return nil, nil, errors.WithDetailf(ErrBadAmount, "output %d: amount %d", a.Position, a.Amount)
+		}
+		out.Amount = a.Amount
+	}
+
+	amended := &signing.Template{
+		Transaction:     &tx,
+		Local:           tpl.Local,
+		AllowAdditional: tpl.AllowAdditional,
+	}
+	for _, si := range tpl.SigningInstructions {
+		amended.SigningInstructions = append(amended.SigningInstructions, copyInstruction(si))
+	}
+	if len(actions) > 0 {
+		b, err := Build(ctx, &tx, actions, maxTime)
+		if err != nil {
+			return nil, nil, err
+		}
+		amended.SigningInstructions = append(amended.SigningInstructions, b.SigningInstructions...)
+	} else {
+		err := checkBlankCheck(&tx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	resign := dropStaleSigs(amended, len(tpl.SigningInstructions))
+	amended.SetExpiry(time.Now())
+	return amended, resign, nil
+}
+
+// checkCommitments returns signing.ErrTemplateAltered if a build
+// commitment in tpl isn't one built reports, or if tpl's
+// transaction doesn't satisfy it.
+func checkCommitments(tpl *signing.Template, built func(commitment []byte) (bool, error)) error {
+	tx := bc.NewTx(*tpl.Transaction)
+	for _, si := range tpl.SigningInstructions {
+		for j, c := range si.WitnessComponents {
+			sw, ok := c.(*signing.SignatureWitness)
+			if !ok || len(sw.Commitment) == 0 {
+				continue
+			}
+			ok, err := built(sw.Commitment)
+			if err != nil {
+				return err
+			}
+			if !ok || !satisfies(tx, si.Position, sw.Commitment) {
+				return errors.WithDetailf(signing.ErrTemplateAltered, "build commitment of witness component %d of input %d", j, si.Position)
+			}
+		}
+	}
+	return nil
+}
+
+// dropStaleSigs drops the signatures of the first n signing
+// instructions of tpl whose programs its transaction no longer
+// satisfies, and returns the positions of their inputs. It
+// recommits to the transaction each witness whose build
+// commitment the transaction no longer satisfies.
+func dropStaleSigs(tpl *signing.Template, n int) []int {
+	var (
+		tx     = bc.NewTx(*tpl.Transaction)
+		resign []int
+	)
+	for _, si := range tpl.SigningInstructions[:n] {
+		var dropped bool
+		for _, c := range si.WitnessComponents {
+			sw, ok := c.(*signing.SignatureWitness)
+			if !ok {
+				continue
+			}
+			if len(sw.Commitment) > 0 && !satisfies(tx, si.Position, sw.Commitment) {
+				sw.Commitment = signing.Commitment(tpl, si.Position)
+			}
+			if len(sw.Program) == 0 || satisfies(tx, si.Position, sw.Program) {
+				continue
+			}
+			sw.Program, sw.Sigs = nil, nil
+			dropped = true
+		}
+		if !dropped {
+			continue
+		}
+		// A cosigner signs the program of the
+		// input's signature witness, so it must
+		// sign again too.
+		for _, c := range si.WitnessComponents {
+			if cw, ok := c.(*signing.CosignWitness); ok {
+				cw.Sig = nil
+			}
+		}
+		resign = append(resign, si.Position)
+	}
+	return resign
+}
+
+func satisfies(tx *bc.Tx, pos int, prog []byte) bool {
+	ok, err := vm.VerifyPredicate(tx, pos, prog)
+	return err == nil && ok
+}
+
+// copyInstruction copies si and its witness components,
+// so changing their signatures doesn't change si.
+func copyInstruction(si *signing.SigningInstruction) *signing.SigningInstruction {
+	cp := &signing.SigningInstruction{
+		Position:          si.Position,
+		AssetAmount:       si.AssetAmount,
+		WitnessComponents: make([]signing.WitnessComponent, 0, len(si.WitnessComponents)),
+	}
+	for _, c := range si.WitnessComponents {
+		switch c := c.(type) {
+		case *signing.SignatureWitness:
+			sw := *c
+			sw.Sigs = append([]chainjson.HexBytes(nil), c.Sigs...)
+			cp.WitnessComponents = append(cp.WitnessComponents, &sw)
+		case *signing.CosignWitness:
+			cw := *c
+			cp.WitnessComponents = append(cp.WitnessComponents, &cw)
+		default:
+			cp.WitnessComponents = append(cp.WitnessComponents, c)
+		}
+	}
+	return cp
+}
@@ -0,0 +1,115 @@
+package txbuilder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder/signing"
+	"chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/testutil"
+)
+
+func TestAmend(t *testing.T) {
+	ctx := context.Background()
+	issuanceProg := []byte{byte(vm.OP_TRUE)}
+	assetID := bc.ComputeAssetID(issuanceProg, bc.Hash{}, 1)
+	issue := func(nonce byte) *bc.TxInput {
+		return bc.NewIssuanceInput([]byte{nonce}, 5, nil, bc.Hash{}, issuanceProg, nil)
+	}
+	tpl := &signing.Template{
+		Transaction: &bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{issue(1), issue(2)},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(assetID, 5, []byte{1}, nil),
+				bc.NewTxOutput(assetID, 5, []byte{2}, nil),
+			},
+			MaxTime: bc.Millis(time.Now().Add(time.Hour)),
+		},
+	}
+	// Input 0 is signed allowing additional actions,
+	// input 1 committing to the whole transaction.
+	tpl.AllowAdditional = true
+	prog0 := signing.SigProgram(tpl, 0)
+	tpl.AllowAdditional = false
+	prog1 := signing.SigProgram(tpl, 1)
+	for i, prog := range [][]byte{prog0, prog1} {
+		tpl.SigningInstructions = append(tpl.SigningInstructions, &signing.SigningInstruction{
+			Position: i,
+			WitnessComponents: []signing.WitnessComponent{&signing.SignatureWitness{
+				Quorum:  1,
+				Keys:    []signing.KeyID{{XPub: "x"}},
+				Program: prog,
+				Sigs:    []json.HexBytes{{1}},
+			}},
+		})
+	}
+	maxTime := time.Now().Add(2 * time.Hour)
+	built := func([]byte) (bool, error) { return true, nil }
+
+	// Adding an output invalidates only the signature
+	// committing to the whole transaction.
+	extra := newControlProgramAction(bc.AssetAmount{AssetID: assetID, Amount: 1}, []byte{3})
+	amended, resign, err := Amend(ctx, tpl, nil, []Action{extra}, maxTime, built)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(amended.Transaction.Outputs) != 3 {
+		t.Errorf("got %d outputs, want 3", len(amended.Transaction.Outputs))
+	}
+	if !reflect.DeepEqual(resign, []int{1}) {
+		t.Errorf("add output: resign = %v, want [1]", resign)
+	}
+	if sigs := witness(amended, 0).Sigs; len(sigs) != 1 {
+		t.Errorf("add output: input 0 sigs = %v, want kept", sigs)
+	}
+	if sw := witness(amended, 1); len(sw.Sigs) != 0 || len(sw.Program) != 0 {
+		t.Errorf("add output: input 1 = %+v, want no program or sigs", sw)
+	}
+	if sigs := witness(tpl, 1).Sigs; len(sigs) != 1 || len(tpl.Transaction.Outputs) != 2 {
+		t.Error("Amend changed its template")
+	}
+
+	// Changing outputs' amounts invalidates both.
+	_, resign, err = Amend(ctx, tpl, []OutputAmount{{Position: 0, Amount: 6}, {Position: 1, Amount: 4}}, nil, maxTime, built)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(resign, []int{0, 1}) {
+		t.Errorf("change amount: resign = %v, want [0 1]", resign)
+	}
+
+	// Lowering it would leave assets free to take.
+	_, _, err = Amend(ctx, tpl, []OutputAmount{{Position: 0, Amount: 4}}, nil, maxTime, built)
+	if errors.Root(err) != ErrBlankCheck {
+		t.Errorf("lower amount: err = %v, want ErrBlankCheck", err)
+	}
+	_, _, err = Amend(ctx, tpl, []OutputAmount{{Position: 2, Amount: 4}}, nil, maxTime, built)
+	if errors.Root(err) != ErrBadAmendment {
+		t.Errorf("bad position: err = %v, want ErrBadAmendment", err)
+	}
+
+	// A commitment this core didn't build isn't recommitted.
+	witness(tpl, 0).Commitment = signing.Commitment(tpl, 0)
+	notBuilt := func([]byte) (bool, error) { return false, nil }
+	_, _, err = Amend(ctx, tpl, nil, []Action{extra}, maxTime, notBuilt)
+	if errors.Root(err) != signing.ErrTemplateAltered {
+		t.Errorf("unbuilt commitment: err = %v, want ErrTemplateAltered", err)
+	}
+
+	// Nor is one the transaction no longer satisfies.
+	tpl.Transaction.Outputs[0].ControlProgram = []byte{4}
+	_, _, err = Amend(ctx, tpl, nil, []Action{extra}, maxTime, built)
+	if errors.Root(err) != signing.ErrTemplateAltered {
+		t.Errorf("altered template: err = %v, want ErrTemplateAltered", err)
+	}
+}
+
+func witness(tpl *signing.Template, i int) *signing.SignatureWitness {
+	return tpl.SigningInstructions[i].WitnessComponents[0].(*signing.SignatureWitness)
+}
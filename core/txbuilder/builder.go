@@ -2,9 +2,9 @@ package txbuilder
 
 import (
 	"bytes"
-	"math"
 	"time"
 
+	"chain/core/txbuilder/signing"
 	"chain/errors"
 	"chain/protocol/bc"
 )
@@ -14,7 +14,7 @@ type TemplateBuilder struct {
 	maxTime             time.Time
 	inputs              []*bc.TxInput
 	outputs             []*bc.TxOutput
-	signingInstructions []*SigningInstruction
+	signingInstructions []*signing.SigningInstruction
 	minTimeMS           uint64
 	referenceData       []byte
 	rollbacks           []func()
@@ -22,8 +22,8 @@ type TemplateBuilder struct {
 	values              map[interface{}]interface{}
 }
 
-func (b *TemplateBuilder) AddInput(in *bc.TxInput, sigInstruction *SigningInstruction) error {
-	if in.Amount() > math.MaxInt64 {
+func (b *TemplateBuilder) AddInput(in *bc.TxInput, sigInstruction *signing.SigningInstruction) error {
+	if in.Amount() > bc.MaxAmount {
 		return errors.WithDetailf(ErrBadAmount, "amount %d exceeds maximum value 2^63", in.Amount())
 	}
 	b.inputs = append(b.inputs, in)
@@ -32,7 +32,7 @@ func (b *TemplateBuilder) AddInput(in *bc.TxInput, sigInstruction *SigningInstru
 }
 
 func (b *TemplateBuilder) AddOutput(o *bc.TxOutput) error {
-	if o.Amount > math.MaxInt64 {
+	if o.Amount > bc.MaxAmount {
 		return errors.WithDetailf(ErrBadAmount, "amount %d exceeds maximum value 2^63", o.Amount)
 	}
 	b.outputs = append(b.outputs, o)
@@ -79,7 +79,7 @@ func (b *TemplateBuilder) rollback() {
 	}
 }
 
-func (b *TemplateBuilder) Build() (*Template, error) {
+func (b *TemplateBuilder) Build() (*signing.Template, error) {
 	// Run any building callbacks.
 	for _, cb := range b.callbacks {
 		err := cb()
@@ -88,7 +88,7 @@ func (b *TemplateBuilder) Build() (*Template, error) {
 		}
 	}
 
-	tpl := &Template{Transaction: b.base}
+	tpl := &signing.Template{Transaction: b.base}
 	if tpl.Transaction == nil {
 		tpl.Transaction = &bc.TxData{
 			Version: bc.CurrentTransactionVersion,
@@ -119,10 +119,21 @@ func (b *TemplateBuilder) Build() (*Template, error) {
 
 		// Empty signature arrays should be serialized as empty arrays, not null.
 		if instruction.WitnessComponents == nil {
-			instruction.WitnessComponents = []WitnessComponent{}
+			instruction.WitnessComponents = []signing.WitnessComponent{}
 		}
 		tpl.SigningInstructions = append(tpl.SigningInstructions, instruction)
 		tpl.Transaction.Inputs = append(tpl.Transaction.Inputs, in)
 	}
+
+	// Commit each new signature witness to the transaction as
+	// built, so a signer can tell if it's altered before signing.
+	for _, instruction := range tpl.SigningInstructions {
+		for _, c := range instruction.WitnessComponents {
+			if sw, ok := c.(*signing.SignatureWitness); ok && len(sw.Commitment) == 0 {
+				sw.Commitment = signing.Commitment(tpl, instruction.Position)
+			}
+		}
+	}
+	tpl.SetExpiry(time.Now())
 	return tpl, nil
 }
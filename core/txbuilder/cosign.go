@@ -0,0 +1,134 @@
+package txbuilder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+// ErrCosign is returned by RequestCosignatures when a
+// cosigner refuses a transaction or doesn't sign it validly.
+var ErrCosign = errors.New("transaction not cosigned")
+
+// CosignProgram returns prog, which must end with a P2SP multisig
+// check (see vmutil.P2SPMultiSigProgram), preceded by a check for a
+// signature of the same predicate by cosigner. Inputs using the
+// program are signed with a SignatureWitness followed by a
+// CosignWitness, whose signature an external service supplies.
+func CosignProgram(cosigner ed25519.PublicKey, prog []byte) []byte {
+	// Expected stack: [... NARGS SIG SIG PREDICATE COSIG]
+	builder := vmutil.NewBuilder()
+	builder.AddOp(vm.OP_TOALTSTACK)                     // stash the cosignature
+	builder.AddOp(vm.OP_DUP).AddOp(vm.OP_SHA3)          // stack is now [... PREDICATE PREDICATEHASH]
+	builder.AddOp(vm.OP_FROMALTSTACK).AddOp(vm.OP_SWAP) // stack is now [... PREDICATE COSIG PREDICATEHASH]
+	builder.AddData(cosigner).AddOp(vm.OP_CHECKSIG).AddOp(vm.OP_VERIFY)
+	builder.AddRawBytes(prog) // stack is now [... NARGS SIG SIG PREDICATE]
+	return builder.Program
+}
+
+// RequestCosignatures gets a signature for each CosignWitness in
+// tpl that lacks one. It sends tpl, as JSON, to each cosigner's URL,
+// which must respond with the template with its CosignWitnesses
+// signed. The cosigner signs the SHA3-256 hash of the predicate of
+// the input's signature program, the last witness argument of the
+// input in the template's transaction. Only the signatures are
+// taken from the response; each is checked against the witness's
+// public key before it's added to tpl and its transaction.
+func RequestCosignatures(ctx context.Context, tpl *signing.Template) error {
+	type pending struct {
+		witness *signing.CosignWitness
+		inst    int
+		comp    int
+		hash    [32]byte
+	}
+	byURL := make(map[string][]pending)
+	var urls []string
+	for i, sigInst := range tpl.SigningInstructions {
+		var predicate []byte
+		for j, c := range sigInst.WitnessComponents {
+			switch w := c.(type) {
+			case *signing.SignatureWitness:
+				if len(w.Program) == 0 {
+					w.Program = signing.SigProgram(tpl, sigInst.Position)
+				}
+				predicate = w.Program
+			case *signing.CosignWitness:
+				if len(w.Sig) > 0 {
+					continue
+				}
+				if predicate == nil {
+					return errors.WithDetailf(signing.ErrBadWitnessComponent, "cosign witness %d of input %d has no signature witness before it", j, i)
+				}
+				p := pending{witness: w, inst: i, comp: j}
+				sha3pool.Sum256(p.hash[:], predicate)
+				if byURL[w.URL] == nil {
+					urls = append(urls, w.URL)
+				}
+				byURL[w.URL] = append(byURL[w.URL], p)
+			}
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	for _, u := range urls {
+		resp, err := requestCosignature(ctx, u, tpl)
+		if err != nil {
+			return err
+		}
+		for _, p := range byURL[u] {
+			var sig []byte
+			if p.inst < len(resp.SigningInstructions) {
+				comps := resp.SigningInstructions[p.inst].WitnessComponents
+				if p.comp < len(comps) {
+					if w, ok := comps[p.comp].(*signing.CosignWitness); ok {
+						sig = w.Sig
+					}
+				}
+			}
+			if len(sig) == 0 {
+				return errors.WithDetailf(ErrCosign, "cosigner %s didn't sign input %d", u, p.inst)
+			}
+			if len(p.witness.PubKey) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(p.witness.PubKey), p.hash[:], sig) {
+				return errors.WithDetailf(ErrCosign, "bad signature from cosigner %s for input %d", u, p.inst)
+			}
+			p.witness.Sig = sig
+		}
+	}
+	return signing.Materialize(tpl)
+}
+
+func requestCosignature(ctx context.Context, url string, tpl *signing.Template) (*signing.Template, error) {
+	body, err := json.Marshal(tpl)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithDetailf(ErrCosign, "bad cosigner URL %s", url)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting cosignature from %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.WithDetailf(ErrCosign, "cosigner %s responded with status %s", url, resp.Status)
+	}
+	var signed signing.Template
+	err = json.NewDecoder(resp.Body).Decode(&signed)
+	if err != nil {
+		return nil, errors.WithDetailf(ErrCosign, "decoding response from cosigner %s: %s", url, err)
+	}
+	return &signed, nil
+}
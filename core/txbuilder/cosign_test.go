@@ -0,0 +1,123 @@
+package txbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+// cosigner returns a test cosigning service that signs
+// every CosignWitness in the templates it's sent with key.
+func cosigner(t *testing.T, key ed25519.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var tpl signing.Template
+		err := json.NewDecoder(req.Body).Decode(&tpl)
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		for _, si := range tpl.SigningInstructions {
+			args := tpl.Transaction.Inputs[si.Position].Arguments()
+			var h [32]byte
+			sha3pool.Sum256(h[:], args[len(args)-1])
+			for _, c := range si.WitnessComponents {
+				if cw, ok := c.(*signing.CosignWitness); ok {
+					cw.Sig = ed25519.Sign(key, h[:])
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(&tpl)
+	}))
+}
+
+func TestCosign(t *testing.T) {
+	signerPub, signerPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosignerPub, cosignerPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multisig, err := vmutil.P2SPMultiSigProgram([]ed25519.PublicKey{signerPub}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := CosignProgram(cosignerPub, multisig)
+
+	cases := []struct {
+		desc    string
+		key     ed25519.PrivateKey
+		wantErr error
+	}{
+		{"cosigned", cosignerPriv, nil},
+		{"signed by another key", otherPriv, ErrCosign},
+	}
+	for _, c := range cases {
+		srv := cosigner(t, c.key)
+		defer srv.Close()
+
+		tpl := &signing.Template{
+			Transaction: &bc.TxData{
+				Version: 1,
+				Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil)},
+				Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, []byte{byte(vm.OP_TRUE)}, nil)},
+			},
+			SigningInstructions: []*signing.SigningInstruction{{
+				WitnessComponents: []signing.WitnessComponent{
+					&signing.SignatureWitness{Quorum: 1, Keys: []signing.KeyID{{XPub: "key"}}},
+					&signing.CosignWitness{URL: srv.URL, PubKey: []byte(cosignerPub)},
+				},
+			}},
+		}
+		signFn := func(_ context.Context, _ string, _ [][]byte, h [32]byte) ([]byte, error) {
+			return ed25519.Sign(signerPriv, h[:]), nil
+		}
+		err := signing.Sign(context.Background(), tpl, []string{"key"}, signFn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := vm.VerifyTxInput(bc.NewTx(*tpl.Transaction), 0)
+		if err == nil && ok {
+			t.Errorf("%s: input verified before it was cosigned", c.desc)
+		}
+
+		// Round-trip the template through JSON, as a client would.
+		b, err := json.Marshal(tpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var submitted signing.Template
+		err = json.Unmarshal(b, &submitted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = RequestCosignatures(context.Background(), &submitted)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("%s: RequestCosignatures() = %v want %v", c.desc, err, c.wantErr)
+		}
+		if c.wantErr != nil {
+			continue
+		}
+		ok, err = vm.VerifyTxInput(bc.NewTx(*submitted.Transaction), 0)
+		if err != nil || !ok {
+			t.Errorf("%s: VerifyTxInput() = %v, %v want true", c.desc, ok, err)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package txbuilder
+
+import (
+	"chain/core/txbuilder/signing"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// A Description explains a partial transaction for the people
+// who must review and sign it. See DescribeTemplate.
+type Description struct {
+	Inputs   []*InputDescription  `json:"inputs"`
+	Outputs  []*OutputDescription `json:"outputs"`
+	Totals   []*AssetTotal        `json:"totals"`
+	Accounts []*AccountChange     `json:"accounts"`
+
+	// MissingSignatures is the number of signatures (and HTLC
+	// preimages) still needed. Complete is true when it is zero
+	// and every asset is balanced.
+	MissingSignatures int  `json:"missing_signatures"`
+	Complete          bool `json:"complete"`
+}
+
+type InputDescription struct {
+	Position       int                `json:"position"`
+	Type           string             `json:"type"` // "issue" or "spend"
+	AssetID        bc.AssetID         `json:"asset_id"`
+	Amount         uint64             `json:"amount"`
+	Confidential   bool               `json:"confidential,omitempty"`
+	ControlProgram chainjson.HexBytes `json:"control_program,omitempty"`
+	AccountID      string             `json:"account_id,omitempty"`
+	Witness        []*WitnessStatus   `json:"witness_components,omitempty"`
+}
+
+type OutputDescription struct {
+	Position       int                `json:"position"`
+	Type           string             `json:"type"` // "control" or "retire"
+	AssetID        bc.AssetID         `json:"asset_id"`
+	Amount         uint64             `json:"amount"`
+	Confidential   bool               `json:"confidential,omitempty"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	AccountID      string             `json:"account_id,omitempty"`
+}
+
+// A WitnessStatus tells what a witness component of an input
+// still needs. For a signature component, Signed and Unsigned
+// are the xpubs of the keys that have and haven't signed.
+// For a cosign component, they hold the cosigner's URL.
+type WitnessStatus struct {
+	Type     string   `json:"type"` // "signature", "htlc", or "cosign"
+	Quorum   int      `json:"quorum,omitempty"`
+	Signed   []string `json:"signed,omitempty"`
+	Unsigned []string `json:"unsigned,omitempty"`
+	Needed   int      `json:"needed"`
+}
+
+// An AssetTotal is the sum of the inputs and outputs of an
+// asset. Inputs the outputs don't account for are left for a
+// later party to the transaction to assign, and are lost to
+// whoever controls them if the transaction is submitted as is;
+// they're reported as Fee. Outputs the inputs don't cover are
+// Unfunded, and the transaction is invalid until more inputs
+// are added. Confidential amounts are not counted.
+type AssetTotal struct {
+	AssetID  bc.AssetID `json:"asset_id"`
+	In       uint64     `json:"in"`
+	Out      uint64     `json:"out"`
+	Fee      uint64     `json:"fee"`
+	Unfunded uint64     `json:"unfunded"`
+}
+
+// An AccountChange is how much of an asset an account spends
+// in the transaction, net of change paid back to it, or
+// receives, if Received is positive.
+type AccountChange struct {
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Spent     uint64     `json:"spent"`
+	Received  uint64     `json:"received"`
+}
+
+// DescribeTemplate explains tpl: what each input spends and for
+// whom, what it still needs to be signed, where each output
+// goes, and the totals of each asset. Accounts maps control
+// programs, as strings, to the IDs of the accounts they belong
+// to, as account.Manager.ControlProgramAccounts returns; it
+// may be nil. It returns ErrBadAmount if the inputs or outputs
+// of an asset total more than bc.MaxAmount.
+func DescribeTemplate(tpl *signing.Template, accounts map[string]string) (*Description, error) {
+	d := &Description{
+		Inputs:   []*InputDescription{},
+		Outputs:  []*OutputDescription{},
+		Totals:   []*AssetTotal{},
+		Accounts: []*AccountChange{},
+	}
+	if tpl.Transaction == nil {
+		return d, nil
+	}
+
+	totals := make(map[bc.AssetID]*AssetTotal)
+	total := func(assetID bc.AssetID) *AssetTotal {
+		t := totals[assetID]
+		if t == nil {
+			t = &AssetTotal{AssetID: assetID}
+			totals[assetID] = t
+			d.Totals = append(d.Totals, t)
+		}
+		return t
+	}
+	type acctAsset struct {
+		accountID string
+		assetID   bc.AssetID
+	}
+	var (
+		order []acctAsset
+		net   = make(map[acctAsset]int64)
+	)
+	change := func(accountID string, assetID bc.AssetID, delta int64) {
+		k := acctAsset{accountID, assetID}
+		if _, ok := net[k]; !ok {
+			order = append(order, k)
+		}
+		net[k] += delta
+	}
+
+	for i, in := range tpl.Transaction.Inputs {
+		desc := &InputDescription{
+			Position: i,
+			Type:     "spend",
+			AssetID:  in.AssetID(),
+			Amount:   in.Amount(),
+		}
+		if in.IsIssuance() {
+			desc.Type = "issue"
+		} else {
+			desc.ControlProgram = in.ControlProgram()
+			desc.AccountID = accounts[string(desc.ControlProgram)]
+			if si, ok := in.TypedInput.(*bc.SpendInput); ok && si.Confidential != nil {
+				desc.Confidential = true
+			}
+		}
+		if !desc.Confidential {
+			t := total(desc.AssetID)
+			sum, err := bc.AddAmounts(t.In, desc.Amount)
+			if err != nil {
+				return nil, errors.WithDetailf(ErrBadAmount, "adding input %d overflows the allowed asset amount 2^63", i)
+			}
+			t.In = sum
+			if desc.AccountID != "" {
+				change(desc.AccountID, desc.AssetID, -int64(desc.Amount))
+			}
+		}
+		d.Inputs = append(d.Inputs, desc)
+	}
+
+	for i, out := range tpl.Transaction.Outputs {
+		desc := &OutputDescription{
+			Position:       i,
+			Type:           "control",
+			AssetID:        out.AssetID,
+			Amount:         out.Amount,
+			Confidential:   out.Confidential != nil,
+			ControlProgram: out.ControlProgram,
+			AccountID:      accounts[string(out.ControlProgram)],
+		}
+		if vmutil.IsUnspendable(out.ControlProgram) {
+			desc.Type = "retire"
+		}
+		if !desc.Confidential {
+			t := total(desc.AssetID)
+			sum, err := bc.AddAmounts(t.Out, desc.Amount)
+			if err != nil {
+				return nil, errors.WithDetailf(ErrBadAmount, "adding output %d overflows the allowed asset amount 2^63", i)
+			}
+			t.Out = sum
+			if desc.AccountID != "" {
+				change(desc.AccountID, desc.AssetID, int64(desc.Amount))
+			}
+		}
+		d.Outputs = append(d.Outputs, desc)
+	}
+
+	for _, sigInst := range tpl.SigningInstructions {
+		if sigInst.Position < 0 || sigInst.Position >= len(d.Inputs) {
+			continue
+		}
+		desc := d.Inputs[sigInst.Position]
+		for _, wc := range sigInst.WitnessComponents {
+			ws := witnessStatus(wc)
+			if ws == nil {
+				continue
+			}
+			desc.Witness = append(desc.Witness, ws)
+			d.MissingSignatures += ws.Needed
+		}
+	}
+
+	balanced := true
+	for _, t := range d.Totals {
+		if t.In >= t.Out {
+			t.Fee = t.In - t.Out
+		} else {
+			t.Unfunded = t.Out - t.In
+			balanced = false
+		}
+	}
+	for _, k := range order {
+		c := &AccountChange{AccountID: k.accountID, AssetID: k.assetID}
+		switch n := net[k]; {
+		case n > 0:
+			c.Received = uint64(n)
+		case n < 0:
+			c.Spent = uint64(-n)
+		default:
+			continue
+		}
+		d.Accounts = append(d.Accounts, c)
+	}
+	d.Complete = balanced && d.MissingSignatures == 0
+	return d, nil
+}
+
+func witnessStatus(wc signing.WitnessComponent) *WitnessStatus {
+	switch wc := wc.(type) {
+	case *signing.SignatureWitness:
+		ws := &WitnessStatus{Type: "signature", Quorum: wc.Quorum}
+		for i, k := range wc.Keys {
+			if i < len(wc.Sigs) && len(wc.Sigs[i]) > 0 {
+				ws.Signed = append(ws.Signed, k.XPub)
+			} else {
+				ws.Unsigned = append(ws.Unsigned, k.XPub)
+			}
+		}
+		if len(ws.Signed) < wc.Quorum {
+			ws.Needed = wc.Quorum - len(ws.Signed)
+		}
+		return ws
+	case *signing.HTLCWitness:
+		ws := &WitnessStatus{Type: "htlc"}
+		if len(wc.Preimage) == 0 {
+			ws.Needed = 1
+		}
+		return ws
+	case *signing.CosignWitness:
+		ws := &WitnessStatus{Type: "cosign", Quorum: 1}
+		if len(wc.Sig) > 0 {
+			ws.Signed = []string{wc.URL}
+		} else {
+			ws.Unsigned = []string{wc.URL}
+			ws.Needed = 1
+		}
+		return ws
+	}
+	return nil
+}
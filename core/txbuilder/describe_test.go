@@ -0,0 +1,101 @@
+package txbuilder
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/core/txbuilder/signing"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+func TestDescribeTemplate(t *testing.T) {
+	assetA := bc.AssetID{1}
+	issue := bc.NewIssuanceInput(nil, 10, nil, bc.Hash{}, []byte("issuer"), nil)
+	assetB := issue.AssetID()
+	retire := vmutil.NewBuilder().AddOp(vm.OP_FAIL).Program
+
+	tpl := &signing.Template{
+		Transaction: &bc.TxData{
+			Version: 1,
+			Inputs: []*bc.TxInput{
+				bc.NewSpendInput(bc.Hash{9}, 0, nil, assetA, 100, []byte("alice"), nil),
+				issue,
+			},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(assetA, 30, []byte("bob"), nil),
+				bc.NewTxOutput(assetA, 60, []byte("alice"), nil),
+				bc.NewTxOutput(assetB, 15, retire, nil),
+			},
+		},
+		SigningInstructions: []*signing.SigningInstruction{{
+			Position: 0,
+			WitnessComponents: []signing.WitnessComponent{
+				&signing.SignatureWitness{
+					Quorum: 2,
+					Keys:   []signing.KeyID{{XPub: "x1"}, {XPub: "x2"}, {XPub: "x3"}},
+					Sigs:   []chainjson.HexBytes{nil, {1}, nil},
+				},
+				&signing.CosignWitness{URL: "https://cosigner"},
+			},
+		}},
+	}
+
+	got, err := DescribeTemplate(tpl, map[string]string{"alice": "acc1", "bob": "acc2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantWitness := []*WitnessStatus{
+		{Type: "signature", Quorum: 2, Signed: []string{"x2"}, Unsigned: []string{"x1", "x3"}, Needed: 1},
+		{Type: "cosign", Quorum: 1, Unsigned: []string{"https://cosigner"}, Needed: 1},
+	}
+	if !reflect.DeepEqual(got.Inputs[0].Witness, wantWitness) {
+		t.Errorf("witness = %+v want %+v", got.Inputs[0].Witness, wantWitness)
+	}
+	if got.Inputs[0].AccountID != "acc1" || got.Inputs[1].Type != "issue" {
+		t.Errorf("inputs = %+v, %+v", got.Inputs[0], got.Inputs[1])
+	}
+	if got.Outputs[2].Type != "retire" {
+		t.Errorf("output 2 type = %s want retire", got.Outputs[2].Type)
+	}
+
+	wantTotals := []*AssetTotal{
+		{AssetID: assetA, In: 100, Out: 90, Fee: 10},
+		{AssetID: assetB, In: 10, Out: 15, Unfunded: 5},
+	}
+	if !reflect.DeepEqual(got.Totals, wantTotals) {
+		t.Errorf("totals = %+v want %+v", got.Totals, wantTotals)
+	}
+	wantAccounts := []*AccountChange{
+		{AccountID: "acc1", AssetID: assetA, Spent: 40},
+		{AccountID: "acc2", AssetID: assetA, Received: 30},
+	}
+	if !reflect.DeepEqual(got.Accounts, wantAccounts) {
+		t.Errorf("accounts = %+v want %+v", got.Accounts, wantAccounts)
+	}
+	if got.MissingSignatures != 2 || got.Complete {
+		t.Errorf("missing = %d complete = %t, want 2 and false", got.MissingSignatures, got.Complete)
+	}
+}
+
+func TestDescribeTemplateOverflow(t *testing.T) {
+	// The outputs' total would wrap around to 1.
+	tpl := &signing.Template{
+		Transaction: &bc.TxData{
+			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{1}, 1, nil, nil)},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(bc.AssetID{1}, bc.MaxAmount, nil, nil),
+				bc.NewTxOutput(bc.AssetID{1}, bc.MaxAmount, nil, nil),
+				bc.NewTxOutput(bc.AssetID{1}, 3, nil, nil),
+			},
+		},
+	}
+	_, err := DescribeTemplate(tpl, nil)
+	if errors.Root(err) != ErrBadAmount {
+		t.Errorf("err = %v want %v", err, ErrBadAmount)
+	}
+}
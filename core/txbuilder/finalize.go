@@ -9,6 +9,7 @@ import (
 	chainlog "chain/log"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
 	"chain/protocol/validation"
 	"chain/protocol/vm"
 )
@@ -17,8 +18,29 @@ var (
 	// ErrRejected means the network rejected a tx (as a double-spend)
 	ErrRejected = errors.New("transaction rejected")
 
-	ErrMissingRawTx        = errors.New("missing raw tx")
-	ErrBadInstructionCount = errors.New("too many signing instructions in template")
+	// ErrOverloaded means the generator has too many pending
+	// transactions to accept more; the submitter should retry
+	// later.
+	ErrOverloaded = errors.New("generator is overloaded")
+
+	// ErrHalted means an operator has halted the generator
+	// and it isn't accepting transactions.
+	ErrHalted = errors.New("generator is halted")
+)
+
+const (
+	// OverloadedCode is the error code a generator responds with
+	// when it refuses a transaction with ErrOverloaded.
+	OverloadedCode = "CH011"
+
+	// HaltedCode is the error code a generator responds with
+	// when it refuses a transaction with ErrHalted.
+	HaltedCode = "CH012"
+
+	// InvalidTxCode is the error code a generator responds with
+	// when it refuses a transaction that fails validation, with
+	// the validation.Reason as data.
+	InvalidTxCode = "CH734"
 )
 
 var Generator *rpc.Client
@@ -57,7 +79,24 @@ func publishTx(ctx context.Context, c *protocol.Chain, msg *bc.Tx) error {
 			return errors.Wrap(err, "tx rejected")
 		}
 
+		// Let the generator place the tx in the lane we chose for it.
+		// It applies its own quota for our network token.
+		if sub, ok := mempool.FromContext(ctx); ok {
+			ctx = rpc.WithHeader(ctx, rpc.HeaderTxPriority, sub.Priority.String())
+		}
 		err = Generator.Call(ctx, "/rpc/submit", msg, nil)
+		switch rpc.ErrorCode(err) {
+		case OverloadedCode:
+			return errors.WithDetail(ErrOverloaded, err.Error())
+		case HaltedCode:
+			return errors.WithDetail(ErrHalted, err.Error())
+		case InvalidTxCode:
+			data := rpc.ErrorData(err)
+			if r, ok := data["reason"].(string); ok {
+				data["reason"] = validation.Reason(r)
+			}
+			return rejected(err, err.Error(), data)
+		}
 		if err != nil {
 			err = errors.Wrap(err, "generator transaction notice")
 			chainlog.Error(ctx, err)
@@ -69,9 +108,7 @@ func publishTx(ctx context.Context, c *protocol.Chain, msg *bc.Tx) error {
 	} else {
 		err = c.AddTx(ctx, msg)
 		if errors.Root(err) == validation.ErrBadTx {
-			detail := errors.Detail(err)
-			err = errors.Wrap(ErrRejected, err)
-			return errors.WithDetail(err, detail)
+			return rejected(err, errors.Detail(err), errors.Data(err))
 		} else if err != nil {
 			return errors.Wrap(err, "add tx to blockchain")
 		}
@@ -79,6 +116,18 @@ func publishTx(ctx context.Context, c *protocol.Chain, msg *bc.Tx) error {
 	return nil
 }
 
+// rejected returns ErrRejected for err, a validation failure,
+// with detail and data, such as the validation.Reason, so
+// clients can tell why.
+func rejected(err error, detail string, data map[string]interface{}) error {
+	err = errors.WithDetail(errors.Wrap(ErrRejected, err), detail)
+	var keyval []interface{}
+	for k, v := range data {
+		keyval = append(keyval, k, v)
+	}
+	return errors.WithData(err, keyval...)
+}
+
 // To permit idempotence of transaction submission, we require at
 // least one input to commit to the complete transaction (what you get
 // when you build a transaction with allow_additional_actions=false).
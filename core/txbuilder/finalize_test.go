@@ -11,6 +11,7 @@ import (
 	"chain/core/pin"
 	"chain/core/query"
 	. "chain/core/txbuilder"
+	"chain/core/txbuilder/signing"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
 	"chain/database/pg/pgtest"
@@ -136,13 +137,13 @@ func TestConflictingTxsInPool(t *testing.T) {
 	// Slighly tweak the first tx so it has a different hash, but
 	// still consumes the same UTXOs.
 	unsignedTx.MaxTime++
-	secondTemplate := &Template{
+	secondTemplate := &signing.Template{
 		Transaction:         &unsignedTx,
 		SigningInstructions: firstTemplate.SigningInstructions,
 		Local:               true,
 	}
-	secondTemplate.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness).Program = nil
-	secondTemplate.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness).Sigs = nil
+	secondTemplate.SigningInstructions[0].WitnessComponents[0].(*signing.SignatureWitness).Program = nil
+	secondTemplate.SigningInstructions[0].WitnessComponents[0].(*signing.SignatureWitness).Sigs = nil
 	coretest.SignTxTemplate(t, ctx, secondTemplate, &info.privKeyAccounts)
 	err = FinalizeTx(ctx, info.Chain, bc.NewTx(*secondTemplate.Transaction))
 	if err != nil {
@@ -332,11 +333,11 @@ func bootdb(ctx context.Context, db *sql.DB, t testing.TB) (*testInfo, error) {
 		return nil, err
 	}
 
-	acctA, err := accounts.Create(ctx, []string{accPub.String()}, 1, "", nil, nil)
+	acctA, err := accounts.Create(ctx, []string{accPub.String()}, 1, "", nil, false, nil)
 	if err != nil {
 		return nil, err
 	}
-	acctB, err := accounts.Create(ctx, []string{accPub.String()}, 1, "", nil, nil)
+	acctB, err := accounts.Create(ctx, []string{accPub.String()}, 1, "", nil, false, nil)
 	if err != nil {
 		return nil, err
 	}
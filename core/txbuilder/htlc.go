@@ -0,0 +1,90 @@
+package txbuilder
+
+import (
+	"encoding/binary"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+// ErrBadHTLC is returned by HTLC when the contract's
+// hash function, hash, or keys are invalid.
+var ErrBadHTLC = errors.New("invalid hash-timelock contract")
+
+// HTLCPreimageSize is the size of the preimage that claims an HTLC.
+// Fixing it, as the contracts on other blockchains do, keeps a
+// preimage from claiming one side of a swap but not the other.
+const HTLCPreimageSize = 32
+
+// htlcHashSizes gives the size of the hash produced by each
+// hash opcode HTLC accepts. SHA256 and RIPEMD160 let the same
+// preimage unlock contracts on Bitcoin and Ethereum.
+var htlcHashSizes = map[vm.Op]int{
+	vm.OP_SHA256:    32,
+	vm.OP_SHA3:      32,
+	vm.OP_RIPEMD160: 20,
+}
+
+// HTLC returns a hash-timelock control program, for atomic swaps
+// with other blockchains. The recipient can claim the output by
+// revealing a HTLCPreimageSize-byte preimage of hash under hashOp,
+// which must be OP_SHA256, OP_SHA3, or OP_RIPEMD160. Once expiry
+// has passed, refund can reclaim the output instead.
+//
+// Either party spends the output with an HTLCWitness followed
+// by a SignatureWitness for its key.
+func HTLC(hashOp vm.Op, hash []byte, recipient, refund ed25519.PublicKey, expiry time.Time) ([]byte, error) {
+	size, ok := htlcHashSizes[hashOp]
+	if !ok {
+		return nil, errors.WithDetailf(ErrBadHTLC, "unsupported hash function %s", hashOp)
+	}
+	if len(hash) != size {
+		return nil, errors.WithDetailf(ErrBadHTLC, "hash is %d bytes, want %d", len(hash), size)
+	}
+	if len(recipient) != ed25519.PublicKeySize || len(refund) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrBadHTLC, "bad public key")
+	}
+
+	// Expected stack: [... PREIMAGE 1 NARGS SIG PREDICATE] to
+	// claim, or [... 0 NARGS SIG PREDICATE] for a refund.
+	// Each path leaves the public key to check SIG against.
+	refundPath := vmutil.NewBuilder()
+	refundPath.AddOp(vm.OP_MINTIME).AddInt64(int64(bc.Millis(expiry)))
+	refundPath.AddOp(vm.OP_GREATERTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	refundPath.AddData(refund)
+
+	claimPath := vmutil.NewBuilder()
+	claimPath.AddOp(vm.OP_SIZE).AddInt64(HTLCPreimageSize).AddOp(vm.OP_EQUALVERIFY)
+	claimPath.AddOp(hashOp).AddData(hash).AddOp(vm.OP_EQUALVERIFY)
+	claimPath.AddData(recipient)
+
+	// Each jump is an opcode followed by a 4-byte address.
+	const prefixLen = 3 + 5
+	claimAddr := prefixLen + len(refundPath.Program) + 5
+	checkAddr := claimAddr + len(claimPath.Program)
+
+	builder := vmutil.NewBuilder()
+	builder.AddOp(vm.OP_TOALTSTACK).AddOp(vm.OP_TOALTSTACK) // stash PREDICATE and SIG
+	builder.AddOp(vm.OP_DROP)                               // the predicate takes no arguments
+	builder.AddOp(vm.OP_JUMPIF).AddRawBytes(jumpAddr(claimAddr))
+	builder.AddRawBytes(refundPath.Program)
+	builder.AddOp(vm.OP_JUMP).AddRawBytes(jumpAddr(checkAddr))
+	builder.AddRawBytes(claimPath.Program)
+	builder.AddOp(vm.OP_FROMALTSTACK).AddOp(vm.OP_FROMALTSTACK) // stack is now [... PUB SIG PREDICATE]
+	builder.AddOp(vm.OP_DUP).AddOp(vm.OP_TOALTSTACK)            // stash a copy of the predicate
+	builder.AddOp(vm.OP_SHA3).AddOp(vm.OP_ROT)                  // stack is now [... SIG PREDICATEHASH PUB]
+	builder.AddOp(vm.OP_CHECKSIG).AddOp(vm.OP_VERIFY)
+	builder.AddInt64(0).AddOp(vm.OP_FROMALTSTACK)
+	builder.AddInt64(0).AddOp(vm.OP_CHECKPREDICATE)
+	return builder.Program, nil
+}
+
+func jumpAddr(addr int) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(addr))
+	return b[:]
+}
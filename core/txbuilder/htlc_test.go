@@ -0,0 +1,130 @@
+package txbuilder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder/signing"
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+func TestHTLC(t *testing.T) {
+	recipientPub, recipientPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refundPub, refundPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preimage := []byte("the atomic swap secret, 32 bytes")
+	hash := sha256.Sum256(preimage)
+	expiry := time.Unix(1000, 0)
+
+	prog, err := HTLC(vm.OP_SHA256, hash[:], recipientPub, refundPub, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A contract whose hash has a preimage of the wrong length.
+	short := []byte("swap secret")
+	shortHash := sha256.Sum256(short)
+	shortProg, err := HTLC(vm.OP_SHA256, shortHash[:], recipientPub, refundPub, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		desc     string
+		prog     []byte
+		key      ed25519.PrivateKey
+		preimage []byte
+		minTime  time.Time
+		want     bool
+	}{
+		{"claim", prog, recipientPriv, preimage, time.Time{}, true},
+		{"claim with wrong preimage", prog, recipientPriv, []byte("guess"), time.Time{}, false},
+		{"claim with wrong-length preimage", shortProg, recipientPriv, short, time.Time{}, false},
+		{"claim with refund key", prog, refundPriv, preimage, time.Time{}, false},
+		{"refund", prog, refundPriv, nil, expiry, true},
+		{"refund before expiry", prog, refundPriv, nil, expiry.Add(-time.Second), false},
+		{"refund with recipient key", prog, recipientPriv, nil, expiry, false},
+	}
+	for _, c := range cases {
+		tpl := &signing.Template{
+			Transaction: &bc.TxData{
+				Version: 1,
+				Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, c.prog, nil)},
+				Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, []byte{byte(vm.OP_TRUE)}, nil)},
+			},
+			SigningInstructions: []*signing.SigningInstruction{{
+				WitnessComponents: []signing.WitnessComponent{
+					&signing.HTLCWitness{Preimage: c.preimage},
+					&signing.SignatureWitness{Quorum: 1, Keys: []signing.KeyID{{XPub: "key"}}},
+				},
+			}},
+		}
+		if !c.minTime.IsZero() {
+			tpl.Transaction.MinTime = bc.Millis(c.minTime)
+		}
+		signFn := func(_ context.Context, _ string, _ [][]byte, h [32]byte) ([]byte, error) {
+			return ed25519.Sign(c.key, h[:]), nil
+		}
+		err := signing.Sign(context.Background(), tpl, []string{"key"}, signFn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = signing.Materialize(tpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := bc.NewTx(*tpl.Transaction)
+		err = checkTxSighashCommitment(tx)
+		if err != nil {
+			t.Fatalf("%s: %v", c.desc, err)
+		}
+		ok, err := vm.VerifyTxInput(tx, 0)
+		if got := err == nil && ok; got != c.want {
+			t.Errorf("%s: VerifyTxInput() = %v, %v want %v", c.desc, ok, err, c.want)
+		}
+	}
+}
+
+func TestHTLCBadHash(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = HTLC(vm.OP_RIPEMD160, make([]byte, 32), pub, pub, time.Now())
+	if err == nil {
+		t.Error("HTLC(RIPEMD160, 32-byte hash) err = nil, want error")
+	}
+	_, err = HTLC(vm.OP_SHA1, make([]byte, 20), pub, pub, time.Now())
+	if err == nil {
+		t.Error("HTLC(SHA1) err = nil, want error")
+	}
+}
+
+func TestHTLCWitnessJSON(t *testing.T) {
+	want := &signing.HTLCWitness{Preimage: chainjson.HexBytes("swap secret")}
+	si := &signing.SigningInstruction{WitnessComponents: []signing.WitnessComponent{want}}
+	b, err := json.Marshal(si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got signing.SigningInstruction
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.WitnessComponents) != 1 || !reflect.DeepEqual(got.WitnessComponents[0], want) {
+		t.Errorf("got %#v, want %#v; JSON was %s", got.WitnessComponents, want, b)
+	}
+}
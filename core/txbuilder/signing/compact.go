@@ -0,0 +1,364 @@
+package signing
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/encoding/base45"
+	"chain/encoding/blockchain"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Prefixes of the compact encodings of templates and
+// signature bundles, identifying what a scanned QR code holds.
+const (
+	CompactTemplatePrefix = "CTT1:"
+	CompactSigsPrefix     = "CTS1:"
+)
+
+// maxCompactSize limits the decompressed size of a compact
+// encoding, well beyond what a QR code could hold.
+const maxCompactSize = 1 << 20
+
+// ErrBadCompact is returned when decoding a malformed
+// compact template or signature bundle.
+var ErrBadCompact = errors.New("invalid compact encoding")
+
+const (
+	compactSignature byte = iota + 1
+	compactHTLC
+	compactCosign
+)
+
+// Flags of a compact template.
+const (
+	compactLocal           = 1
+	compactAllowAdditional = 2
+	compactCommitments     = 4 // signature witnesses carry build commitments
+)
+
+// EncodeCompact returns a compact encoding of tpl sized to fit
+// a QR code, for carrying a template to a signer that has no
+// network connection. It's a binary serialization, compressed
+// with zlib and encoded in Base45, the character set of the
+// alphanumeric mode of QR codes. It keeps the build commitments
+// of signature witnesses, so the signer can still refuse a
+// template altered after it was built.
+func EncodeCompact(tpl *Template) (string, error) {
+	if tpl.Transaction == nil {
+		return "", errors.Wrap(ErrMissingRawTx)
+	}
+	var buf bytes.Buffer
+	_, err := tpl.Transaction.WriteTo(&buf)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	raw := buf.Bytes()
+
+	w := new(bytes.Buffer)
+	writeBytes(w, raw)
+	flags := uint64(compactCommitments)
+	if tpl.Local {
+		flags |= compactLocal
+	}
+	if tpl.AllowAdditional {
+		flags |= compactAllowAdditional
+	}
+	blockchain.WriteVarint31(w, flags)
+	blockchain.WriteVarint31(w, uint64(len(tpl.SigningInstructions)))
+	for _, si := range tpl.SigningInstructions {
+		blockchain.WriteVarint31(w, uint64(si.Position))
+		w.Write(si.AssetID[:])
+		blockchain.WriteVarint63(w, si.Amount)
+		blockchain.WriteVarint31(w, uint64(len(si.WitnessComponents)))
+		for _, c := range si.WitnessComponents {
+			switch c := c.(type) {
+			case *SignatureWitness:
+				w.WriteByte(compactSignature)
+				blockchain.WriteVarint31(w, uint64(c.Quorum))
+				blockchain.WriteVarint31(w, uint64(len(c.Keys)))
+				for _, k := range c.Keys {
+					var xpub chainkd.XPub
+					err := xpub.UnmarshalText([]byte(k.XPub))
+					if err != nil {
+						return "", errors.WithDetailf(ErrBadWitnessComponent, "bad xpub %s", k.XPub)
+					}
+					w.Write(xpub[:])
+					blockchain.WriteVarint31(w, uint64(len(k.DerivationPath)))
+					for _, p := range k.DerivationPath {
+						writeBytes(w, p)
+					}
+				}
+				writeBytes(w, c.Program)
+				writeBytes(w, c.Commitment)
+				blockchain.WriteVarint31(w, uint64(len(c.Sigs)))
+				for _, sig := range c.Sigs {
+					writeBytes(w, sig)
+				}
+			case *HTLCWitness:
+				w.WriteByte(compactHTLC)
+				writeBytes(w, c.Preimage)
+			case *CosignWitness:
+				w.WriteByte(compactCosign)
+				writeBytes(w, []byte(c.URL))
+				writeBytes(w, c.PubKey)
+				writeBytes(w, c.Sig)
+			default:
+				return "", errors.WithDetailf(ErrBadWitnessComponent, "can't encode %T", c)
+			}
+		}
+	}
+	return compress(CompactTemplatePrefix, w.Bytes())
+}
+
+// DecodeCompact decodes a template encoded by EncodeCompact.
+func DecodeCompact(s string) (*Template, error) {
+	b, err := decompress(CompactTemplatePrefix, s)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(b)
+	d := &decoder{r: r}
+
+	raw := d.bytes()
+	flags := d.int()
+	if d.err != nil {
+		return nil, errors.Wrap(ErrBadCompact)
+	}
+	tx := new(bc.TxData)
+	err = tx.Scan(raw)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadCompact, err.Error())
+	}
+	tpl := &Template{
+		Transaction:     tx,
+		Local:           flags&compactLocal != 0,
+		AllowAdditional: flags&compactAllowAdditional != 0,
+	}
+
+	nsi := d.count()
+	for i := 0; i < nsi && d.err == nil; i++ {
+		si := &SigningInstruction{Position: d.int()}
+		d.read(si.AssetID[:])
+		if d.err == nil {
+			si.Amount, _, d.err = blockchain.ReadVarint63(r)
+		}
+		ncomp := d.count()
+		si.WitnessComponents = make([]WitnessComponent, 0, ncomp)
+		for j := 0; j < ncomp && d.err == nil; j++ {
+			var typ [1]byte
+			d.read(typ[:])
+			switch typ[0] {
+			case compactSignature:
+				sw := &SignatureWitness{Quorum: d.int()}
+				nkeys := d.count()
+				for k := 0; k < nkeys && d.err == nil; k++ {
+					var xpub chainkd.XPub
+					d.read(xpub[:])
+					key := KeyID{XPub: xpub.String()}
+					npath := d.count()
+					for p := 0; p < npath && d.err == nil; p++ {
+						key.DerivationPath = append(key.DerivationPath, d.bytes())
+					}
+					sw.Keys = append(sw.Keys, key)
+				}
+				sw.Program = d.bytes()
+				if flags&compactCommitments != 0 {
+					sw.Commitment = d.bytes()
+				}
+				nsigs := d.count()
+				for k := 0; k < nsigs && d.err == nil; k++ {
+					sw.Sigs = append(sw.Sigs, d.bytes())
+				}
+				si.WitnessComponents = append(si.WitnessComponents, sw)
+			case compactHTLC:
+				si.WitnessComponents = append(si.WitnessComponents, &HTLCWitness{Preimage: d.bytes()})
+			case compactCosign:
+				cw := &CosignWitness{URL: string(d.bytes())}
+				cw.PubKey = d.bytes()
+				cw.Sig = d.bytes()
+				si.WitnessComponents = append(si.WitnessComponents, cw)
+			default:
+				if d.err == nil {
+					return nil, errors.WithDetailf(ErrBadCompact, "unknown witness component type %d", typ[0])
+				}
+			}
+		}
+		tpl.SigningInstructions = append(tpl.SigningInstructions, si)
+	}
+	if d.err != nil || r.Len() > 0 {
+		return nil, errors.Wrap(ErrBadCompact)
+	}
+	return tpl, nil
+}
+
+// EncodeSigs returns a compact encoding, like that of
+// EncodeCompact, of just the signatures in tpl's signature
+// witnesses. A signer can return this much smaller bundle
+// to be added with ApplySigs to the template it signed.
+func EncodeSigs(tpl *Template) (string, error) {
+	w := new(bytes.Buffer)
+	var n uint64
+	forEachSig(tpl, func(i, j, k int, sig *chainjson.HexBytes) {
+		if len(*sig) > 0 {
+			n++
+		}
+	})
+	blockchain.WriteVarint31(w, n)
+	forEachSig(tpl, func(i, j, k int, sig *chainjson.HexBytes) {
+		if len(*sig) > 0 {
+			blockchain.WriteVarint31(w, uint64(i))
+			blockchain.WriteVarint31(w, uint64(j))
+			blockchain.WriteVarint31(w, uint64(k))
+			writeBytes(w, *sig)
+		}
+	})
+	return compress(CompactSigsPrefix, w.Bytes())
+}
+
+// ApplySigs adds the signatures in bundle, encoded by EncodeSigs
+// from a signed copy of tpl, to the same signature witnesses of
+// tpl, and updates the input witnesses of its transaction.
+// It's an error if the bundle names a signature witness key
+// tpl lacks.
+func ApplySigs(tpl *Template, bundle string) error {
+	if tpl.Transaction == nil {
+		return errors.Wrap(ErrMissingRawTx)
+	}
+	b, err := decompress(CompactSigsPrefix, bundle)
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(b)
+	d := &decoder{r: r}
+	n := d.count()
+	for x := 0; x < n && d.err == nil; x++ {
+		i, j, k := d.int(), d.int(), d.int()
+		sig := d.bytes()
+		if d.err != nil {
+			break
+		}
+		var sw *SignatureWitness
+		if i < len(tpl.SigningInstructions) && j < len(tpl.SigningInstructions[i].WitnessComponents) {
+			sw, _ = tpl.SigningInstructions[i].WitnessComponents[j].(*SignatureWitness)
+		}
+		if sw == nil || k >= len(sw.Keys) {
+			return errors.WithDetailf(ErrBadCompact, "no key %d in witness component %d of input %d", k, j, i)
+		}
+		if len(sw.Program) == 0 {
+			pos := tpl.SigningInstructions[i].Position
+			if pos >= len(tpl.Transaction.Inputs) {
+				return errors.WithDetailf(ErrBadTxInputIdx, "signing instruction %d references missing tx input %d", i, pos)
+			}
+			sw.Program = SigProgram(tpl, pos)
+		}
+		for len(sw.Sigs) < len(sw.Keys) {
+			sw.Sigs = append(sw.Sigs, nil)
+		}
+		sw.Sigs[k] = sig
+	}
+	if d.err != nil || r.Len() > 0 {
+		return errors.Wrap(ErrBadCompact)
+	}
+	return Materialize(tpl)
+}
+
+func forEachSig(tpl *Template, f func(i, j, k int, sig *chainjson.HexBytes)) {
+	for i, si := range tpl.SigningInstructions {
+		for j, c := range si.WitnessComponents {
+			if sw, ok := c.(*SignatureWitness); ok {
+				for k := range sw.Sigs {
+					f(i, j, k, &sw.Sigs[k])
+				}
+			}
+		}
+	}
+}
+
+func writeBytes(w io.Writer, b []byte) {
+	blockchain.WriteVarstr31(w, b)
+}
+
+func compress(prefix string, b []byte) (string, error) {
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	zw.Write(b)
+	err = zw.Close()
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return prefix + base45.EncodeToString(buf.Bytes()), nil
+}
+
+func decompress(prefix, s string) ([]byte, error) {
+	if !strings.HasPrefix(s, prefix) {
+		return nil, errors.WithDetailf(ErrBadCompact, "missing prefix %s", prefix)
+	}
+	z, err := base45.DecodeString(s[len(prefix):])
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadCompact, err.Error())
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(z))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadCompact, err.Error())
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(zr, maxCompactSize+1))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadCompact, err.Error())
+	}
+	if len(b) > maxCompactSize {
+		return nil, errors.WithDetail(ErrBadCompact, "too large")
+	}
+	return b, nil
+}
+
+// decoder reads the parts of a compact encoding from r,
+// recording the first error, after which it reads nothing.
+type decoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *decoder) read(b []byte) {
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+}
+
+func (d *decoder) int() int {
+	if d.err != nil {
+		return 0
+	}
+	n, _, err := blockchain.ReadVarint31(d.r)
+	d.err = err
+	return int(n)
+}
+
+// count reads a varint counting items that each take at
+// least a byte, so it rejects counts exceeding the bytes left.
+func (d *decoder) count() int {
+	n := d.int()
+	if d.err == nil && n > d.r.Len() {
+		d.err = ErrBadCompact
+	}
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	d.read(b)
+	return b
+}
@@ -0,0 +1,154 @@
+package signing
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/encoding/base45"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestCompactTemplate(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &Template{
+		Transaction: &bc.TxData{
+			Version: 1,
+			Inputs: []*bc.TxInput{
+				bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{2}, 5, []byte{3}, nil),
+				bc.NewSpendInput(bc.Hash{4}, 1, nil, bc.AssetID{2}, 6, []byte{3}, nil),
+			},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{2}, 11, []byte{1}, nil)},
+		},
+		Local: true,
+		SigningInstructions: []*SigningInstruction{{
+			Position:    0,
+			AssetAmount: bc.AssetAmount{AssetID: bc.AssetID{2}, Amount: 5},
+			WitnessComponents: []WitnessComponent{
+				&HTLCWitness{Preimage: chainjson.HexBytes("secret")},
+				&SignatureWitness{
+					Quorum: 1,
+					Keys:   []KeyID{{XPub: xpub.String(), DerivationPath: []chainjson.HexBytes{{1}, {2, 3}}}},
+				},
+				&CosignWitness{URL: "https://cosigner.example/sign", PubKey: chainjson.HexBytes{7, 8}},
+			},
+		}, {
+			Position:    1,
+			AssetAmount: bc.AssetAmount{AssetID: bc.AssetID{2}, Amount: 6},
+			WitnessComponents: []WitnessComponent{
+				&SignatureWitness{
+					Quorum: 1,
+					Keys:   []KeyID{{XPub: xpub.String(), DerivationPath: []chainjson.HexBytes{{9}}}},
+				},
+			},
+		}},
+	}
+
+	for _, si := range tpl.SigningInstructions {
+		for _, c := range si.WitnessComponents {
+			if sw, ok := c.(*SignatureWitness); ok {
+				sw.Commitment = Commitment(tpl, si.Position)
+			}
+		}
+	}
+
+	enc, err := EncodeCompact(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, CompactTemplatePrefix) {
+		t.Errorf("got encoding %s, want prefix %s", enc, CompactTemplatePrefix)
+	}
+	got, err := DecodeCompact(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, tpl) {
+		t.Errorf("got:\n%s\nwant:\n%s", spew.Sdump(got), spew.Sdump(tpl))
+	}
+
+	// A JSON string holding the compact
+	// encoding unmarshals as a template.
+	b, _ := json.Marshal(enc)
+	var fromJSON Template
+	err = json.Unmarshal(b, &fromJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&fromJSON, tpl) {
+		t.Errorf("got from JSON:\n%s\nwant:\n%s", spew.Sdump(&fromJSON), spew.Sdump(tpl))
+	}
+
+	// The decoded copy keeps the build commitments, so
+	// an offline signer refuses it if it's altered.
+	altered, err := DecodeCompact(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	altered.Transaction.Outputs[0] = bc.NewTxOutput(bc.AssetID{2}, 11, []byte{2}, nil)
+	err = SignWithXPrvs(context.Background(), altered, []chainkd.XPrv{xprv})
+	if errors.Root(err) != ErrTemplateAltered {
+		t.Errorf("signing altered template: got error %v, want %v", err, ErrTemplateAltered)
+	}
+
+	// Sign the decoded copy, as an offline signer would,
+	// and apply its signature bundle to the original.
+	err = SignWithXPrvs(context.Background(), got, []chainkd.XPrv{xprv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := EncodeSigs(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ApplySigs(tpl, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, in := range tpl.Transaction.Inputs {
+		if !reflect.DeepEqual(in.Arguments(), got.Transaction.Inputs[i].Arguments()) {
+			t.Errorf("input %d: got arguments %x, want %x", i, in.Arguments(), got.Transaction.Inputs[i].Arguments())
+		}
+	}
+}
+
+func TestCompactErrors(t *testing.T) {
+	tpl := &Template{Transaction: &bc.TxData{Version: 1}}
+	enc, err := EncodeCompact(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := EncodeSigs(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []string{
+		"",
+		strings.TrimPrefix(enc, CompactTemplatePrefix),
+		bundle,
+		CompactTemplatePrefix + "not base45",
+		CompactTemplatePrefix + base45.EncodeToString([]byte("not zlib")),
+		enc[:len(enc)-3],
+	}
+	for _, c := range cases {
+		_, err := DecodeCompact(c)
+		if errors.Root(err) != ErrBadCompact {
+			t.Errorf("DecodeCompact(%q) got error %v, want ErrBadCompact", c, err)
+		}
+	}
+	err = ApplySigs(tpl, enc)
+	if errors.Root(err) != ErrBadCompact {
+		t.Errorf("ApplySigs(template) got error %v, want ErrBadCompact", err)
+	}
+}
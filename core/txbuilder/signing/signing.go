@@ -0,0 +1,69 @@
+// Package signing signs transaction templates.
+//
+// It computes the predicates a template's signature witnesses
+// commit to, gathers signatures from a SignFunc, and assembles
+// them into the transaction's input witnesses. It needs no
+// connection to a core, so a client holding its own keys can
+// sign a template built by a core and submit the result.
+package signing
+
+import (
+	"context"
+	"time"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+var (
+	ErrMissingRawTx        = errors.New("missing raw tx")
+	ErrBadInstructionCount = errors.New("too many signing instructions in template")
+	ErrBadTxInputIdx       = errors.New("unsigned tx missing input")
+	ErrBadWitnessComponent = errors.New("invalid witness component")
+)
+
+// SignFunc is the function passed into Sign that produces
+// a signature for a given xpub, derivation path, and hash.
+// It returns a nil signature for an xpub whose key it lacks.
+type SignFunc func(context.Context, string, [][]byte, [32]byte) ([]byte, error)
+
+// Sign adds signatures to tpl for those of xpubs named by
+// its signing instructions, calling signFn to compute each,
+// and updates the input witnesses of tpl's transaction.
+func Sign(ctx context.Context, tpl *Template, xpubs []string, signFn SignFunc) error {
+	for i, sigInst := range tpl.SigningInstructions {
+		for j, c := range sigInst.WitnessComponents {
+			err := c.Sign(ctx, tpl, i, xpubs, signFn)
+			if err != nil {
+				return errors.WithDetailf(err, "adding signature(s) to witness component %d of input %d", j, i)
+			}
+		}
+	}
+	tpl.SetExpiry(time.Now())
+	return Materialize(tpl)
+}
+
+// SignWithXPrvs signs tpl with the given private keys.
+func SignWithXPrvs(ctx context.Context, tpl *Template, xprvs []chainkd.XPrv) error {
+	var xpubs []string
+	for _, xprv := range xprvs {
+		xpubs = append(xpubs, xprv.XPub().String())
+	}
+	return Sign(ctx, tpl, xpubs, XPrvSignFunc(xprvs))
+}
+
+// XPrvSignFunc returns a SignFunc that signs
+// with the given private keys.
+func XPrvSignFunc(xprvs []chainkd.XPrv) SignFunc {
+	byXPub := make(map[string]chainkd.XPrv, len(xprvs))
+	for _, xprv := range xprvs {
+		byXPub[xprv.XPub().String()] = xprv
+	}
+	return func(_ context.Context, xpub string, path [][]byte, data [32]byte) ([]byte, error) {
+		xprv, ok := byXPub[xpub]
+		if !ok {
+			return nil, nil
+		}
+		return xprv.Derive(path).Sign(data[:]), nil
+	}
+}
@@ -0,0 +1,156 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestSignWithXPrvs(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := [][]byte{{1}, {2, 3}}
+
+	sw := &SignatureWitness{
+		Quorum: 1,
+		Keys: []KeyID{{
+			XPub:           xpub.String(),
+			DerivationPath: []chainjson.HexBytes{path[0], path[1]},
+		}},
+	}
+	tpl := &Template{
+		Transaction: &bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{}, 5, nil, nil)},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 5, []byte{1}, nil)},
+		},
+		SigningInstructions: []*SigningInstruction{{
+			WitnessComponents: []WitnessComponent{sw},
+		}},
+	}
+
+	err = SignWithXPrvs(context.Background(), tpl, []chainkd.XPrv{other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sw.Sigs[0]) != 0 {
+		t.Errorf("got a signature from a key not in the signing instruction")
+	}
+
+	err = SignWithXPrvs(context.Background(), tpl, []chainkd.XPrv{other, xprv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h [32]byte
+	sha3pool.Sum256(h[:], sw.Program)
+	if !xpub.Derive(path).Verify(h[:], sw.Sigs[0]) {
+		t.Errorf("got signature %x, which doesn't verify", sw.Sigs[0])
+	}
+
+	want := [][]byte{nil, sw.Sigs[0], sw.Program}
+	got := tpl.Transaction.Inputs[0].Arguments()
+	if len(got) != len(want) {
+		t.Fatalf("got %d witness arguments, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("witness argument %d = %x, want %x", i, got[i], want[i])
+		}
+	}
+	if !bytes.Equal(sw.Program, SigProgram(tpl, 0)) {
+		t.Errorf("got program %x, want the transaction's sighash program", sw.Program)
+	}
+}
+
+func TestSignAlteredTemplate(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTemplate := func() (*Template, *SignatureWitness) {
+		sw := &SignatureWitness{Quorum: 1, Keys: []KeyID{{XPub: xpub.String()}}}
+		tpl := &Template{
+			Transaction: &bc.TxData{
+				Version: 1,
+				MaxTime: 1000,
+				Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{}, 5, nil, nil)},
+				Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 5, []byte("payee"), nil)},
+			},
+			SigningInstructions: []*SigningInstruction{{
+				WitnessComponents: []WitnessComponent{sw},
+			}},
+		}
+		sw.Commitment = Commitment(tpl, 0)
+		return tpl, sw
+	}
+	xpubs := []string{xpub.String()}
+
+	// The builder records each commitment it computes.
+	recorded := make(map[string]bool)
+	built := func(c []byte) (bool, error) { return recorded[string(c)], nil }
+	tpl, _ := newTemplate()
+	for _, c := range Commitments(tpl) {
+		recorded[string(c)] = true
+	}
+
+	// Adding an output and narrowing the time range
+	// leave the commitment satisfied.
+	tpl, sw := newTemplate()
+	tpl.Transaction.Outputs = append(tpl.Transaction.Outputs, bc.NewTxOutput(bc.AssetID{}, 0, []byte("other"), nil))
+	tpl.Transaction.MaxTime = 900
+	err = RequireCommitments(tpl, xpubs, built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SignWithXPrvs(context.Background(), tpl, []chainkd.XPrv{xprv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sw.Sigs[0]) == 0 {
+		t.Error("no signature")
+	}
+
+	// Redirecting the output breaks it.
+	tpl, sw = newTemplate()
+	tpl.Transaction.Outputs[0].ControlProgram = []byte("thief")
+	err = SignWithXPrvs(context.Background(), tpl, []chainkd.XPrv{xprv})
+	if errors.Root(err) != ErrTemplateAltered {
+		t.Errorf("sign altered template: err = %v want %v", err, ErrTemplateAltered)
+	}
+	if len(sw.Sigs) > 0 && len(sw.Sigs[0]) > 0 {
+		t.Error("signed an altered template")
+	}
+
+	// A signer that requires commitments won't sign without one.
+	tpl, sw = newTemplate()
+	sw.Commitment = nil
+	err = RequireCommitments(tpl, xpubs, built)
+	if errors.Root(err) != ErrNoCommitment {
+		t.Errorf("RequireCommitments err = %v want %v", err, ErrNoCommitment)
+	}
+	err = RequireCommitments(tpl, nil, built)
+	if err != nil {
+		t.Errorf("RequireCommitments(no xpubs) err = %v want nil", err)
+	}
+
+	// Nor with a commitment recomputed by whoever altered the template.
+	tpl, sw = newTemplate()
+	tpl.Transaction.Outputs[0].ControlProgram = []byte("thief")
+	sw.Commitment = Commitment(tpl, 0)
+	err = RequireCommitments(tpl, xpubs, built)
+	if errors.Root(err) != ErrTemplateAltered {
+		t.Errorf("RequireCommitments(recomputed) err = %v want %v", err, ErrTemplateAltered)
+	}
+}
@@ -0,0 +1,120 @@
+package signing
+
+import (
+	"encoding/json"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Template represents a partially- or fully-signed transaction.
+type Template struct {
+	Transaction         *bc.TxData            `json:"raw_transaction"`
+	SigningInstructions []*SigningInstruction `json:"signing_instructions"`
+
+	// Local indicates that all inputs to the transaction are signed
+	// exclusively by keys managed by this Core. Whenever accepting
+	// a template from an external Core, `Local` should be set to
+	// false.
+	Local bool `json:"local"`
+
+	// AllowAdditional affects whether Sign commits to the tx sighash or
+	// to individual details of the tx so far. When true, signatures
+	// commit to tx details, and new details may be added but existing
+	// ones cannot be changed. When false, signatures commit to the tx
+	// as a whole, and any change to the tx invalidates the signature.
+	AllowAdditional bool `json:"allow_additional_actions"`
+
+	// ExpiresAt is when the transaction's maxtime passes, and
+	// ExpiresIn how long remained until then when the template was
+	// last built or signed. They're for clients' information only;
+	// the transaction's maxtime is what's enforced.
+	ExpiresAt *time.Time          `json:"expires_at,omitempty"`
+	ExpiresIn *chainjson.Duration `json:"expires_in,omitempty"`
+
+	sigHasher *bc.SigHasher
+}
+
+func (t *Template) Hash(idx int) bc.Hash {
+	if t.sigHasher == nil {
+		t.sigHasher = bc.NewSigHasher(t.Transaction)
+	}
+	return t.sigHasher.Hash(idx)
+}
+
+// SetExpiry sets t.ExpiresAt and t.ExpiresIn
+// from the transaction's maxtime, as of now.
+func (t *Template) SetExpiry(now time.Time) {
+	t.ExpiresAt, t.ExpiresIn = nil, nil
+	if t.Transaction == nil || t.Transaction.MaxTime == 0 {
+		return
+	}
+	exp := time.Unix(0, int64(t.Transaction.MaxTime)*int64(time.Millisecond)).UTC()
+	remaining := exp.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	t.ExpiresAt = &exp
+	t.ExpiresIn = &chainjson.Duration{Duration: remaining}
+}
+
+// UnmarshalJSON decodes a template from its JSON object form
+// or from a JSON string holding its compact encoding.
+func (t *Template) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		dec, err := DecodeCompact(s)
+		if err != nil {
+			return err
+		}
+		*t = *dec
+		return nil
+	}
+	type plain Template // lacks UnmarshalJSON
+	return json.Unmarshal(b, (*plain)(t))
+}
+
+// SigningInstruction gives directions for signing inputs in a TxTemplate.
+type SigningInstruction struct {
+	Position int `json:"position"`
+	bc.AssetAmount
+	WitnessComponents []WitnessComponent `json:"witness_components,omitempty"`
+}
+
+func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
+	var pre struct {
+		bc.AssetAmount
+		Position          int `json:"position"`
+		WitnessComponents []struct {
+			Type string
+			SignatureWitness
+			Preimage chainjson.HexBytes `json:"preimage"`
+			URL      string             `json:"url"`
+			PubKey   chainjson.HexBytes `json:"pubkey"`
+			Sig      chainjson.HexBytes `json:"signature"`
+		} `json:"witness_components"`
+	}
+	err := json.Unmarshal(b, &pre)
+	if err != nil {
+		return err
+	}
+
+	si.AssetAmount = pre.AssetAmount
+	si.Position = pre.Position
+	si.WitnessComponents = make([]WitnessComponent, 0, len(pre.WitnessComponents))
+	for i, w := range pre.WitnessComponents {
+		switch w.Type {
+		case "signature":
+			si.WitnessComponents = append(si.WitnessComponents, &w.SignatureWitness)
+		case "htlc":
+			si.WitnessComponents = append(si.WitnessComponents, &HTLCWitness{Preimage: w.Preimage})
+		case "cosign":
+			si.WitnessComponents = append(si.WitnessComponents, &CosignWitness{URL: w.URL, PubKey: w.PubKey, Sig: w.Sig})
+		default:
+			return errors.WithDetailf(ErrBadWitnessComponent, "witness component %d has unknown type '%s'", i, w.Type)
+		}
+	}
+	return nil
+}
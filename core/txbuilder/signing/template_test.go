@@ -1,4 +1,4 @@
-package txbuilder
+package signing
 
 import "testing"
 
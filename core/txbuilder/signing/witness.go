@@ -0,0 +1,432 @@
+package signing
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+// WitnessComponent encodes instructions for finalizing a transaction
+// by populating its InputWitness fields. Each WitnessComponent object
+// produces zero or more items for the InputWitness of the txinput it
+// corresponds to.
+type WitnessComponent interface {
+	// Sign is called to add signatures. Actual signing is delegated to
+	// a callback function.
+	Sign(context.Context, *Template, int, []string, SignFunc) error
+
+	// Materialize is called to turn the component into a vector of
+	// arguments for the input witness.
+	Materialize(*Template, int, *[][]byte) error
+}
+
+// Materialize takes a filled in Template and "materializes"
+// each witness component, turning it into a vector of arguments for
+// the tx's input witness, creating a fully-signed transaction.
+func Materialize(txTemplate *Template) error {
+	msg := txTemplate.Transaction
+
+	if msg == nil {
+		return errors.Wrap(ErrMissingRawTx)
+	}
+
+	if len(txTemplate.SigningInstructions) > len(msg.Inputs) {
+		return errors.Wrap(ErrBadInstructionCount)
+	}
+
+	for i, sigInst := range txTemplate.SigningInstructions {
+		if msg.Inputs[sigInst.Position] == nil {
+			return errors.WithDetailf(ErrBadTxInputIdx, "signing instruction %d references missing tx input %d", i, sigInst.Position)
+		}
+
+		var witness [][]byte
+		for j, c := range sigInst.WitnessComponents {
+			err := c.Materialize(txTemplate, sigInst.Position, &witness)
+			if err != nil {
+				return errors.WithDetailf(err, "error in witness component %d of input %d", j, i)
+			}
+		}
+
+		msg.Inputs[sigInst.Position].SetArguments(witness)
+	}
+
+	return nil
+}
+
+type (
+	SignatureWitness struct {
+		// Quorum is the number of signatures required.
+		Quorum int `json:"quorum"`
+
+		// Keys are the identities of the keys to sign with.
+		Keys []KeyID `json:"keys"`
+
+		// Program is the predicate part of the signature program, whose hash is what gets
+		// signed. If empty, it is computed during Sign from the outputs
+		// and the current input of the transaction.
+		Program chainjson.HexBytes `json:"program"`
+
+		// Sigs are signatures of Program made from each of the Keys
+		// during Sign.
+		Sigs []chainjson.HexBytes `json:"signatures"`
+
+		// Commitment is a predicate the builder computed, with
+		// Commitment, from the transaction as it built it. Sign
+		// refuses to sign a transaction that doesn't satisfy it,
+		// so outputs can't be removed or changed between build
+		// and sign.
+		Commitment chainjson.HexBytes `json:"commitment"`
+	}
+
+	KeyID struct {
+		XPub           string               `json:"xpub"`
+		DerivationPath []chainjson.HexBytes `json:"derivation_path"`
+	}
+)
+
+var (
+	ErrEmptyProgram    = errors.New("empty signature program")
+	ErrNoCommitment    = errors.New("signature witness has no build commitment")
+	ErrTemplateAltered = errors.New("transaction was altered after it was built")
+)
+
+// Sign populates sw.Sigs with as many signatures of the predicate in
+// sw.Program as it can from the overlapping set of keys in sw.Keys
+// and xpubs.
+//
+// If sw.Program is empty, it is populated with an _inferred_ predicate:
+// a program committing to aspects of the current
+// transaction. Specifically, the program commits to:
+//  - the mintime and maxtime of the transaction (if non-zero)
+//  - the outpoint and (if non-empty) reference data of the current input
+//  - the assetID, amount, control program, and (if non-empty) reference data of each output.
+//
+// Before adding a signature, it returns ErrTemplateAltered if the
+// transaction doesn't satisfy sw.Commitment, if it has one, and
+// sw.Program.
+func (sw *SignatureWitness) Sign(ctx context.Context, tpl *Template, index int, xpubs []string, signFn SignFunc) error {
+	// Compute the predicate to sign. This is either a
+	// txsighash program if tpl.AllowAdditional is false (i.e., the tx is complete
+	// and no further changes are allowed) or a program enforcing
+	// constraints derived from the existing outputs and current input.
+	if len(sw.Program) == 0 {
+		sw.Program = SigProgram(tpl, tpl.SigningInstructions[index].Position)
+		if len(sw.Program) == 0 {
+			return ErrEmptyProgram
+		}
+	}
+	if len(sw.Sigs) < len(sw.Keys) {
+		// Each key in sw.Keys may produce a signature in sw.Sigs. Make
+		// sure there are enough slots in sw.Sigs and that we preserve any
+		// sigs already present.
+		newSigs := make([]chainjson.HexBytes, len(sw.Keys))
+		copy(newSigs, sw.Sigs)
+		sw.Sigs = newSigs
+	}
+	var (
+		h       [32]byte
+		checked bool
+	)
+	sha3pool.Sum256(h[:], sw.Program)
+	for i, keyID := range sw.Keys {
+		if len(sw.Sigs[i]) > 0 {
+			// Already have a signature for this key
+			continue
+		}
+		if !contains(xpubs, keyID.XPub) {
+			continue
+		}
+		if !checked {
+			err := sw.check(tpl, tpl.SigningInstructions[index].Position)
+			if err != nil {
+				return err
+			}
+			checked = true
+		}
+		var path [][]byte
+		for _, p := range keyID.DerivationPath {
+			path = append(path, p)
+		}
+		sigBytes, err := signFn(ctx, keyID.XPub, path, h)
+		if err != nil {
+			return errors.WithDetailf(err, "computing signature %d", i)
+		}
+		sw.Sigs[i] = sigBytes
+	}
+	return nil
+}
+
+// check reports ErrTemplateAltered if the transaction of tpl
+// doesn't satisfy sw's build commitment and signature program
+// for the input at pos.
+func (sw *SignatureWitness) check(tpl *Template, pos int) error {
+	tx := bc.NewTx(*tpl.Transaction)
+	if len(sw.Commitment) > 0 {
+		ok, err := vm.VerifyPredicate(tx, pos, sw.Commitment)
+		if err != nil || !ok {
+			return errors.WithDetailf(ErrTemplateAltered, "input %d does not satisfy its build commitment", pos)
+		}
+	}
+	ok, err := vm.VerifyPredicate(tx, pos, sw.Program)
+	if err != nil || !ok {
+		return errors.WithDetailf(ErrTemplateAltered, "input %d does not satisfy its signature program", pos)
+	}
+	return nil
+}
+
+// RequireCommitments returns ErrNoCommitment if a signature
+// witness in tpl that any of xpubs would sign has no build
+// commitment, and ErrTemplateAltered if built reports that a
+// trusted builder didn't compute its commitment. A signer that
+// must not sign templates altered after they were built calls
+// it before Sign. The commitment travels with the template, so
+// whoever alters the template could recompute it; built is what
+// tells a signer the commitment is the builder's own.
+func RequireCommitments(tpl *Template, xpubs []string, built func(commitment []byte) (bool, error)) error {
+	for i, sigInst := range tpl.SigningInstructions {
+		for j, c := range sigInst.WitnessComponents {
+			sw, ok := c.(*SignatureWitness)
+			if !ok || !sw.signs(xpubs) {
+				continue
+			}
+			if len(sw.Commitment) == 0 {
+				return errors.WithDetailf(ErrNoCommitment, "witness component %d of input %d", j, i)
+			}
+			ok, err := built(sw.Commitment)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.WithDetailf(ErrTemplateAltered, "build commitment of witness component %d of input %d is not the builder's", j, i)
+			}
+		}
+	}
+	return nil
+}
+
+// signs reports whether any of xpubs would add
+// a signature to sw.
+func (sw *SignatureWitness) signs(xpubs []string) bool {
+	for k, keyID := range sw.Keys {
+		if (k >= len(sw.Sigs) || len(sw.Sigs[k]) == 0) && contains(xpubs, keyID.XPub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Commitments returns the build commitments of the
+// signature witnesses in tpl, for the builder to
+// record; see RequireCommitments.
+func Commitments(tpl *Template) [][]byte {
+	var a [][]byte
+	for _, sigInst := range tpl.SigningInstructions {
+		for _, c := range sigInst.WitnessComponents {
+			if sw, ok := c.(*SignatureWitness); ok && len(sw.Commitment) > 0 {
+				a = append(a, sw.Commitment)
+			}
+		}
+	}
+	return a
+}
+
+// Commitment returns the predicate a builder stores in the
+// Commitment of each signature witness of the input at index of
+// tpl's transaction: a program committing to the transaction's
+// time range and outputs so far and the input's outpoint and
+// reference data. Later builders may add inputs and outputs
+// and narrow the time range without breaking it.
+func Commitment(tpl *Template, index int) []byte {
+	return constraintProgram(tpl.Transaction, index)
+}
+
+func contains(list []string, key string) bool {
+	for _, k := range list {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SigProgram returns the predicate a SignatureWitness signs
+// for the input at index of tpl's transaction: the transaction's
+// sighash if tpl.AllowAdditional is false, or else a program
+// committing to the transaction's time range and outputs and the
+// input's outpoint and reference data.
+func SigProgram(tpl *Template, index int) []byte {
+	if !tpl.AllowAdditional {
+		h := tpl.Hash(index)
+		builder := vmutil.NewBuilder()
+		builder.AddData(h[:])
+		builder.AddOp(vm.OP_TXSIGHASH).AddOp(vm.OP_EQUAL)
+		return builder.Program
+	}
+	return constraintProgram(tpl.Transaction, index)
+}
+
+func constraintProgram(tx *bc.TxData, index int) []byte {
+	constraints := make([]constraint, 0, 3+len(tx.Outputs))
+	constraints = append(constraints, &timeConstraint{
+		minTimeMS: tx.MinTime,
+		maxTimeMS: tx.MaxTime,
+	})
+	inp := tx.Inputs[index]
+	if !inp.IsIssuance() {
+		constraints = append(constraints, outpointConstraint(inp.Outpoint()))
+	}
+
+	// Commitment to the tx-level refdata is conditional on it being
+	// non-empty. Commitment to the input-level refdata is
+	// unconditional. Rationale: no one should be able to change "my"
+	// reference data; anyone should be able to set tx refdata but, once
+	// set, it should be immutable.
+	if len(tx.ReferenceData) > 0 {
+		constraints = append(constraints, refdataConstraint{tx.ReferenceData, true})
+	}
+	constraints = append(constraints, refdataConstraint{inp.ReferenceData, false})
+
+	for i, out := range tx.Outputs {
+		c := &payConstraint{
+			Index:       i,
+			AssetAmount: out.AssetAmount,
+			Program:     out.ControlProgram,
+		}
+		if len(out.ReferenceData) > 0 {
+			var h [32]byte
+			sha3pool.Sum256(h[:], out.ReferenceData)
+			c.RefDataHash = (*bc.Hash)(&h)
+		}
+		constraints = append(constraints, c)
+	}
+	var program []byte
+	for i, c := range constraints {
+		program = append(program, c.code()...)
+		if i < len(constraints)-1 { // leave the final bool on top of the stack
+			program = append(program, byte(vm.OP_VERIFY))
+		}
+	}
+	return program
+}
+
+func (sw SignatureWitness) Materialize(tpl *Template, index int, args *[][]byte) error {
+	// This is the value of N for the CHECKPREDICATE call. The code
+	// assumes that everything already in the arg list before this call
+	// to Materialize is input to the signature program, so N is
+	// len(*args).
+	*args = append(*args, vm.Int64Bytes(int64(len(*args))))
+
+	var nsigs int
+	for i := 0; i < len(sw.Sigs) && nsigs < sw.Quorum; i++ {
+		if len(sw.Sigs[i]) > 0 {
+			*args = append(*args, sw.Sigs[i])
+			nsigs++
+		}
+	}
+	*args = append(*args, sw.Program)
+	return nil
+}
+
+func (sw SignatureWitness) MarshalJSON() ([]byte, error) {
+	obj := struct {
+		Type       string               `json:"type"`
+		Quorum     int                  `json:"quorum"`
+		Keys       []KeyID              `json:"keys"`
+		Sigs       []chainjson.HexBytes `json:"signatures"`
+		Commitment chainjson.HexBytes   `json:"commitment,omitempty"`
+	}{
+		Type:       "signature",
+		Quorum:     sw.Quorum,
+		Keys:       sw.Keys,
+		Sigs:       sw.Sigs,
+		Commitment: sw.Commitment,
+	}
+	return json.Marshal(obj)
+}
+
+func (si *SigningInstruction) AddWitnessKeys(keys []KeyID, quorum int) {
+	sw := &SignatureWitness{
+		Quorum: quorum,
+		Keys:   keys,
+	}
+	si.WitnessComponents = append(si.WitnessComponents, sw)
+}
+
+// HTLCWitness supplies the arguments for spending an output
+// locked by txbuilder.HTLC. It must precede the SignatureWitness
+// of the same input. To claim the output, Preimage must be set;
+// to reclaim it after it expires, Preimage must be empty.
+type HTLCWitness struct {
+	Preimage chainjson.HexBytes `json:"preimage"`
+}
+
+// Sign does nothing; an HTLCWitness needs no signatures.
+func (hw *HTLCWitness) Sign(context.Context, *Template, int, []string, SignFunc) error {
+	return nil
+}
+
+func (hw HTLCWitness) Materialize(tpl *Template, index int, args *[][]byte) error {
+	if len(hw.Preimage) > 0 {
+		*args = append(*args, hw.Preimage, vm.BoolBytes(true))
+	} else {
+		*args = append(*args, vm.BoolBytes(false))
+	}
+	return nil
+}
+
+func (hw HTLCWitness) MarshalJSON() ([]byte, error) {
+	obj := struct {
+		Type     string             `json:"type"`
+		Preimage chainjson.HexBytes `json:"preimage"`
+	}{
+		Type:     "htlc",
+		Preimage: hw.Preimage,
+	}
+	return json.Marshal(obj)
+}
+
+// CosignWitness supplies the signature of an external cosigner,
+// such as a compliance service, for an input locked by
+// txbuilder.CosignProgram. It must follow the SignatureWitness of
+// the same input, whose predicate the cosigner signs. Sign leaves
+// it alone; txbuilder.RequestCosignatures gets its signature from
+// the cosigner at URL.
+type CosignWitness struct {
+	URL    string             `json:"url"`
+	PubKey chainjson.HexBytes `json:"pubkey"`
+	Sig    chainjson.HexBytes `json:"signature"`
+}
+
+// Sign does nothing; the cosigner signs in
+// txbuilder.RequestCosignatures.
+func (cw *CosignWitness) Sign(context.Context, *Template, int, []string, SignFunc) error {
+	return nil
+}
+
+func (cw CosignWitness) Materialize(tpl *Template, index int, args *[][]byte) error {
+	if len(cw.Sig) > 0 {
+		*args = append(*args, cw.Sig)
+	}
+	return nil
+}
+
+func (cw CosignWitness) MarshalJSON() ([]byte, error) {
+	obj := struct {
+		Type   string             `json:"type"`
+		URL    string             `json:"url"`
+		PubKey chainjson.HexBytes `json:"pubkey"`
+		Sig    chainjson.HexBytes `json:"signature"`
+	}{
+		Type:   "cosign",
+		URL:    cw.URL,
+		PubKey: cw.PubKey,
+		Sig:    cw.Sig,
+	}
+	return json.Marshal(obj)
+}
@@ -1,4 +1,4 @@
-package txbuilder
+package signing
 
 import (
 	"bytes"
@@ -27,7 +27,7 @@ func TestInferConstraints(t *testing.T) {
 		},
 		AllowAdditional: true,
 	}
-	prog := buildSigProgram(tpl, 0)
+	prog := SigProgram(tpl, 0)
 	want, err := vm.Assemble("MINTIME 1 GREATERTHANOREQUAL VERIFY MAXTIME 2 LESSTHANOREQUAL VERIFY 0x0000000000000000000000000000000000000000000000000000000000000000 1 OUTPOINT ROT NUMEQUAL VERIFY EQUAL VERIFY 0x2767f15c8af2f2c7225d5273fdd683edc714110a987d1054697c348aed4e6cc7 REFDATAHASH EQUAL VERIFY 0 0 123 0x0000000000000000000000000000000000000000000000000000000000000000 1 0x0a0b0c CHECKOUTPUT")
 	if err != nil {
 		t.Fatal(err)
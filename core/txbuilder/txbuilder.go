@@ -6,21 +6,22 @@ import (
 	"context"
 	"time"
 
+	"chain/core/txbuilder/signing"
 	"chain/crypto/ed25519/chainkd"
 	"chain/encoding/json"
 	"chain/errors"
-	"chain/math/checked"
 	"chain/protocol/bc"
 )
 
 var (
-	ErrBadRefData          = errors.New("transaction reference data does not match previous template's reference data")
-	ErrBadTxInputIdx       = errors.New("unsigned tx missing input")
-	ErrBadWitnessComponent = errors.New("invalid witness component")
-	ErrBadAmount           = errors.New("bad asset amount")
-	ErrBlankCheck          = errors.New("unsafe transaction: leaves assets free to control")
-	ErrAction              = errors.New("errors occurred in one or more actions")
-	ErrMissingFields       = errors.New("required field is missing")
+	ErrBadRefData    = errors.New("transaction reference data does not match previous template's reference data")
+	ErrBadAmount     = errors.New("bad asset amount")
+	ErrBlankCheck    = errors.New("unsafe transaction: leaves assets free to control")
+	ErrAction        = errors.New("errors occurred in one or more actions")
+	ErrMissingFields = errors.New("required field is missing")
+	ErrTxExpired     = errors.New("transaction expired")
+
+	ErrWrongBlockchain = errors.New("transaction is for a different blockchain")
 )
 
 // Build builds or adds on to a transaction.
@@ -28,7 +29,7 @@ var (
 // Build partners then satisfy and consume inputs and destinations.
 // The final party must ensure that the transaction is
 // balanced before calling finalize.
-func Build(ctx context.Context, tx *bc.TxData, actions []Action, maxTime time.Time) (*Template, error) {
+func Build(ctx context.Context, tx *bc.TxData, actions []Action, maxTime time.Time) (*signing.Template, error) {
 	builder := TemplateBuilder{
 		base:    tx,
 		maxTime: maxTime,
@@ -68,53 +69,63 @@ func Build(ctx context.Context, tx *bc.TxData, actions []Action, maxTime time.Ti
 
 // KeyIDs produces KeyIDs from a list of xpubs and a derivation path
 // (applied to all the xpubs).
-func KeyIDs(xpubs []chainkd.XPub, path [][]byte) []KeyID {
-	result := make([]KeyID, 0, len(xpubs))
+func KeyIDs(xpubs []chainkd.XPub, path [][]byte) []signing.KeyID {
+	result := make([]signing.KeyID, 0, len(xpubs))
 	var hexPath []json.HexBytes
 	for _, p := range path {
 		hexPath = append(hexPath, p)
 	}
 	for _, xpub := range xpubs {
-		result = append(result, KeyID{xpub.String(), hexPath})
+		result = append(result, signing.KeyID{XPub: xpub.String(), DerivationPath: hexPath})
 	}
 	return result
 }
 
-func Sign(ctx context.Context, tpl *Template, xpubs []string, signFn SignFunc) error {
-	for i, sigInst := range tpl.SigningInstructions {
-		for j, c := range sigInst.WitnessComponents {
-			err := c.Sign(ctx, tpl, i, xpubs, signFn)
-			if err != nil {
-				return errors.WithDetailf(err, "adding signature(s) to witness component %d of input %d", j, i)
-			}
+// CheckExpiry returns ErrTxExpired if tx's maxtime is before now.
+// A tx with no maxtime never expires.
+func CheckExpiry(tx *bc.TxData, now time.Time) error {
+	if tx.MaxTime > 0 && tx.MaxTime < bc.Millis(now) {
+		return errors.WithDetailf(ErrTxExpired, "maxtime %d is in the past", tx.MaxTime)
+	}
+	return nil
+}
+
+// CheckBlockchain returns ErrWrongBlockchain if an issuance in tx
+// is for a blockchain other than the one whose initial block hash
+// is initialBlock. Such a transaction can never be valid, and
+// catching it before it's signed keeps a template built on one
+// network, such as a test network, from being submitted to another.
+func CheckBlockchain(tx *bc.TxData, initialBlock bc.Hash) error {
+	for i, in := range tx.Inputs {
+		ii, ok := in.TypedInput.(*bc.IssuanceInput)
+		if !ok || in.AssetVersion != 1 || ii.InitialBlock == initialBlock {
+			continue
 		}
+		return errors.WithDetailf(ErrWrongBlockchain,
+			"input %d issues on blockchain %s, but this core is on blockchain %s",
+			i, ii.InitialBlock, initialBlock)
 	}
-	return materializeWitnesses(tpl)
+	return nil
 }
 
 func checkBlankCheck(tx *bc.TxData) error {
-	assetMap := make(map[bc.AssetID]int64)
-	var ok bool
-	for _, in := range tx.Inputs {
-		asset := in.AssetID() // AssetID() is calculated for IssuanceInputs, so grab once
-		assetMap[asset], ok = checked.AddInt64(assetMap[asset], int64(in.Amount()))
-		if !ok {
-			return errors.WithDetailf(ErrBadAmount, "cumulative amounts for asset %s overflow the allowed asset amount 2^63", asset)
-		}
+	ins, err := bc.SumInputs(tx.Inputs)
+	if err != nil {
+		return errors.WithDetailf(ErrBadAmount, "%s overflows the allowed asset amount 2^63", errors.Detail(err))
 	}
-	for _, out := range tx.Outputs {
-		assetMap[out.AssetID], ok = checked.SubInt64(assetMap[out.AssetID], int64(out.Amount))
-		if !ok {
-			return errors.WithDetailf(ErrBadAmount, "cumulative amounts for asset %s overflow the allowed asset amount 2^63", out.AssetID)
-		}
+	outs, err := bc.SumOutputs(tx.Outputs)
+	if err != nil {
+		return errors.WithDetailf(ErrBadAmount, "%s overflows the allowed asset amount 2^63", errors.Detail(err))
 	}
 
 	var requiresOutputs, requiresInputs bool
-	for _, amt := range assetMap {
-		if amt > 0 {
+	for asset, in := range ins {
+		if in > outs[asset] {
 			requiresOutputs = true
 		}
-		if amt < 0 {
+	}
+	for asset, out := range outs {
+		if out > ins[asset] {
 			requiresInputs = true
 		}
 	}
@@ -11,6 +11,7 @@ import (
 
 	"golang.org/x/crypto/sha3"
 
+	"chain/core/txbuilder/signing"
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
 	"chain/encoding/json"
@@ -26,7 +27,7 @@ type testAction bc.AssetAmount
 
 func (t testAction) Build(ctx context.Context, maxTime time.Time, b *TemplateBuilder) error {
 	in := bc.NewSpendInput([32]byte{255}, 0, nil, t.AssetID, t.Amount, nil, nil)
-	tplIn := &SigningInstruction{}
+	tplIn := &signing.SigningInstruction{}
 
 	err := b.AddInput(in, tplIn)
 	if err != nil {
@@ -70,7 +71,7 @@ func TestBuild(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := &Template{
+	want := &signing.Template{
 		Transaction: &bc.TxData{
 			Version: 1,
 			MaxTime: bc.Millis(expiryTime),
@@ -83,8 +84,8 @@ func TestBuild(t *testing.T) {
 			},
 			ReferenceData: []byte("xyz"),
 		},
-		SigningInstructions: []*SigningInstruction{{
-			WitnessComponents: []WitnessComponent{},
+		SigningInstructions: []*signing.SigningInstruction{{
+			WitnessComponents: []signing.WitnessComponent{},
 		}},
 	}
 
@@ -98,6 +99,13 @@ func TestBuild(t *testing.T) {
 		t.Errorf("got signing instructions:\n\t%#v\nwant signing instructions:\n\t%#v", got.SigningInstructions, want.SigningInstructions)
 	}
 
+	if got.ExpiresAt == nil || bc.Millis(*got.ExpiresAt) != bc.Millis(expiryTime) {
+		t.Errorf("got expires_at %v, want %v", got.ExpiresAt, expiryTime)
+	}
+	if got.ExpiresIn == nil || got.ExpiresIn.Duration <= 0 || got.ExpiresIn.Duration > time.Minute {
+		t.Errorf("got expires_in %v, want between 0 and 1m", got.ExpiresIn)
+	}
+
 	// setting tx refdata twice should fail
 	actions = append(actions, &setTxRefDataAction{Data: []byte("lmnop")})
 	_, err = Build(ctx, nil, actions, expiryTime)
@@ -139,13 +147,13 @@ func TestMaterializeWitnesses(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	tpl := &Template{
+	tpl := &signing.Template{
 		Transaction: unsigned,
-		SigningInstructions: []*SigningInstruction{{
-			WitnessComponents: []WitnessComponent{
-				&SignatureWitness{
+		SigningInstructions: []*signing.SigningInstruction{{
+			WitnessComponents: []signing.WitnessComponent{
+				&signing.SignatureWitness{
 					Quorum: 1,
-					Keys: []KeyID{{
+					Keys: []signing.KeyID{{
 						XPub:           pubkey.String(),
 						DerivationPath: []json.HexBytes{{0, 0, 0, 0}},
 					}},
@@ -162,7 +170,7 @@ func TestMaterializeWitnesses(t *testing.T) {
 		prog,
 	}
 
-	err = materializeWitnesses(tpl)
+	err = signing.Materialize(tpl)
 	if err != nil {
 		t.Fatal(withStack(err))
 	}
@@ -200,7 +208,7 @@ func TestSignatureWitnessMaterialize(t *testing.T) {
 		},
 	}
 
-	tpl := &Template{
+	tpl := &signing.Template{
 		Transaction: unsigned,
 	}
 	h := tpl.Hash(0)
@@ -220,11 +228,11 @@ func TestSignatureWitnessMaterialize(t *testing.T) {
 	}
 
 	// Test with more signatures than required, in correct order
-	tpl.SigningInstructions = []*SigningInstruction{{
-		WitnessComponents: []WitnessComponent{
-			&SignatureWitness{
+	tpl.SigningInstructions = []*signing.SigningInstruction{{
+		WitnessComponents: []signing.WitnessComponent{
+			&signing.SignatureWitness{
 				Quorum: 2,
-				Keys: []KeyID{
+				Keys: []signing.KeyID{
 					{
 						XPub:           pubkey1.String(),
 						DerivationPath: []json.HexBytes{{0, 0, 0, 0}},
@@ -243,7 +251,7 @@ func TestSignatureWitnessMaterialize(t *testing.T) {
 			},
 		},
 	}}
-	err = materializeWitnesses(tpl)
+	err = signing.Materialize(tpl)
 	if err != nil {
 		t.Fatal(withStack(err))
 	}
@@ -253,12 +261,12 @@ func TestSignatureWitnessMaterialize(t *testing.T) {
 	}
 
 	// Test with exact amount of signatures required, in correct order
-	component, ok := tpl.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness)
+	component, ok := tpl.SigningInstructions[0].WitnessComponents[0].(*signing.SignatureWitness)
 	if !ok {
-		t.Fatal("expecting WitnessComponent of type SignatureWitness")
+		t.Fatal("expecting signing.WitnessComponent of type signing.SignatureWitness")
 	}
 	component.Sigs = []json.HexBytes{sig1, sig2}
-	err = materializeWitnesses(tpl)
+	err = signing.Materialize(tpl)
 	if err != nil {
 		t.Fatal(withStack(err))
 	}
@@ -456,6 +464,16 @@ func TestCheckBlankCheck(t *testing.T) {
 			},
 		},
 		want: ErrBadAmount,
+	}, {
+		tx: &bc.TxData{
+			// outputs whose sum wraps around to the input amount
+			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{0}, 5, nil, nil)},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(bc.AssetID{0}, math.MaxUint64, nil, nil),
+				bc.NewTxOutput(bc.AssetID{0}, 6, nil, nil),
+			},
+		},
+		want: ErrBadAmount,
 	}, {
 		tx: &bc.TxData{
 			Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{0}, 5, nil, nil)},
@@ -482,3 +500,41 @@ func TestCheckBlankCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckExpiry(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		maxTime uint64
+		want    error
+	}{
+		{0, nil},
+		{bc.Millis(now.Add(time.Minute)), nil},
+		{bc.Millis(now), nil},
+		{bc.Millis(now.Add(-time.Minute)), ErrTxExpired},
+	}
+	for _, c := range cases {
+		err := CheckExpiry(&bc.TxData{MaxTime: c.maxTime}, now)
+		if errors.Root(err) != c.want {
+			t.Errorf("CheckExpiry(maxtime %d) = %v want %v", c.maxTime, err, c.want)
+		}
+	}
+}
+
+func TestCheckBlockchain(t *testing.T) {
+	prod := bc.Hash{1}
+	test := bc.Hash{2}
+	issue := func(initialBlock bc.Hash) *bc.TxData {
+		return &bc.TxData{Inputs: []*bc.TxInput{
+			bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, nil, nil),
+			bc.NewIssuanceInput(nil, 1, nil, initialBlock, []byte{1}, nil),
+		}}
+	}
+	err := CheckBlockchain(issue(prod), prod)
+	if err != nil {
+		t.Errorf("CheckBlockchain(same blockchain) = %v want nil", err)
+	}
+	err = CheckBlockchain(issue(test), prod)
+	if errors.Root(err) != ErrWrongBlockchain {
+		t.Errorf("CheckBlockchain(different blockchain) = %v want %v", err, ErrWrongBlockchain)
+	}
+}
@@ -29,7 +29,7 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 // Snapshot represents a snapshot of the blockchain, including the state
-// tree and issuance memory.
+// tree, issuance memory, soft fork activation heights and asset supplies.
 type Snapshot struct {
 	// Nodes contains every node within the state tree, including interior nodes.
 	// The nodes are ordered according to a pre-order traversal.
@@ -37,6 +37,14 @@ type Snapshot struct {
 	// Issuances contains the record of recent issuances for ensuring uniqueness
 	// of issuances.
 	Issuances []*Snapshot_Issuance `protobuf:"bytes,2,rep,name=issuances" json:"issuances,omitempty"`
+	// Activations contains the activation height of each soft fork
+	// signaled in the block version.
+	Activations []*Snapshot_Activation `protobuf:"bytes,3,rep,name=activations" json:"activations,omitempty"`
+	// Supplies contains the cumulative issued and retired amounts
+	// of each asset. It is only meaningful if supplies_tracked is set;
+	// snapshots stored by older versions don't have it.
+	Supplies        []*Snapshot_AssetSupply `protobuf:"bytes,4,rep,name=supplies" json:"supplies,omitempty"`
+	SuppliesTracked bool                    `protobuf:"varint,5,opt,name=supplies_tracked,json=suppliesTracked" json:"supplies_tracked,omitempty"`
 }
 
 func (m *Snapshot) Reset()                    { *m = Snapshot{} }
@@ -58,6 +66,20 @@ func (m *Snapshot) GetIssuances() []*Snapshot_Issuance {
 	return nil
 }
 
+func (m *Snapshot) GetActivations() []*Snapshot_Activation {
+	if m != nil {
+		return m.Activations
+	}
+	return nil
+}
+
+func (m *Snapshot) GetSupplies() []*Snapshot_AssetSupply {
+	if m != nil {
+		return m.Supplies
+	}
+	return nil
+}
+
 type Snapshot_Issuance struct {
 	Hash     []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
 	ExpiryMs uint64 `protobuf:"varint,2,opt,name=expiry_ms,json=expiryMs" json:"expiry_ms,omitempty"`
@@ -78,29 +100,60 @@ func (m *Snapshot_StateTreeNode) String() string            { return proto.Compa
 func (*Snapshot_StateTreeNode) ProtoMessage()               {}
 func (*Snapshot_StateTreeNode) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
 
+type Snapshot_Activation struct {
+	Bit    uint32 `protobuf:"varint,1,opt,name=bit" json:"bit,omitempty"`
+	Height uint64 `protobuf:"varint,2,opt,name=height" json:"height,omitempty"`
+}
+
+func (m *Snapshot_Activation) Reset()                    { *m = Snapshot_Activation{} }
+func (m *Snapshot_Activation) String() string            { return proto.CompactTextString(m) }
+func (*Snapshot_Activation) ProtoMessage()               {}
+func (*Snapshot_Activation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 2} }
+
+type Snapshot_AssetSupply struct {
+	AssetId []byte `protobuf:"bytes,1,opt,name=asset_id,json=assetId,proto3" json:"asset_id,omitempty"`
+	Issued  uint64 `protobuf:"varint,2,opt,name=issued" json:"issued,omitempty"`
+	Retired uint64 `protobuf:"varint,3,opt,name=retired" json:"retired,omitempty"`
+}
+
+func (m *Snapshot_AssetSupply) Reset()                    { *m = Snapshot_AssetSupply{} }
+func (m *Snapshot_AssetSupply) String() string            { return proto.CompactTextString(m) }
+func (*Snapshot_AssetSupply) ProtoMessage()               {}
+func (*Snapshot_AssetSupply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 3} }
+
 func init() {
 	proto.RegisterType((*Snapshot)(nil), "chain.core.txdb.internal.storage.Snapshot")
 	proto.RegisterType((*Snapshot_Issuance)(nil), "chain.core.txdb.internal.storage.Snapshot.Issuance")
 	proto.RegisterType((*Snapshot_StateTreeNode)(nil), "chain.core.txdb.internal.storage.Snapshot.StateTreeNode")
+	proto.RegisterType((*Snapshot_Activation)(nil), "chain.core.txdb.internal.storage.Snapshot.Activation")
+	proto.RegisterType((*Snapshot_AssetSupply)(nil), "chain.core.txdb.internal.storage.Snapshot.AssetSupply")
 }
 
 func init() { proto.RegisterFile("snapshot.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 227 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2b, 0xce, 0x4b, 0x2c,
-	0x28, 0xce, 0xc8, 0x2f, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x52, 0x48, 0xce, 0x48, 0xcc,
-	0xcc, 0xd3, 0x4b, 0xce, 0x2f, 0x4a, 0xd5, 0x2b, 0xa9, 0x48, 0x49, 0xd2, 0xcb, 0xcc, 0x2b, 0x49,
-	0x2d, 0xca, 0x4b, 0xcc, 0xd1, 0x2b, 0x2e, 0xc9, 0x2f, 0x4a, 0x4c, 0x4f, 0x55, 0x5a, 0xc8, 0xc4,
-	0xc5, 0x11, 0x0c, 0xd5, 0x24, 0xe4, 0xc7, 0xc5, 0x9a, 0x97, 0x9f, 0x92, 0x5a, 0x2c, 0xc1, 0xa8,
-	0xc0, 0xac, 0xc1, 0x6d, 0x64, 0xa1, 0x47, 0x48, 0xbb, 0x1e, 0x4c, 0xab, 0x5e, 0x70, 0x49, 0x62,
-	0x49, 0x6a, 0x48, 0x51, 0x6a, 0xaa, 0x1f, 0xd0, 0x80, 0x20, 0x88, 0x31, 0x42, 0x81, 0x5c, 0x9c,
-	0x99, 0xc5, 0xc5, 0xa5, 0x89, 0x79, 0xc9, 0x40, 0x33, 0x99, 0xc0, 0x66, 0x1a, 0x93, 0x60, 0xa6,
-	0x27, 0x54, 0x6f, 0x10, 0xc2, 0x14, 0x29, 0x6b, 0x2e, 0x0e, 0x98, 0xb0, 0x90, 0x10, 0x17, 0x4b,
-	0x46, 0x62, 0x71, 0x06, 0xd0, 0xb5, 0x8c, 0x1a, 0x3c, 0x41, 0x60, 0xb6, 0x90, 0x34, 0x17, 0x67,
-	0x6a, 0x45, 0x41, 0x66, 0x51, 0x65, 0x7c, 0x2e, 0xc8, 0x4a, 0x46, 0x0d, 0x96, 0x20, 0x0e, 0x88,
-	0x80, 0x6f, 0xb1, 0x94, 0x29, 0x17, 0x2f, 0x8a, 0x3b, 0x85, 0x04, 0xb8, 0x98, 0xb3, 0x53, 0x2b,
-	0xa1, 0x06, 0x80, 0x98, 0x70, 0x33, 0x99, 0x10, 0x66, 0x3a, 0x71, 0x46, 0xb1, 0x43, 0xdd, 0x96,
-	0xc4, 0x06, 0x0e, 0x57, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0x2c, 0x20, 0xad, 0x97, 0x69,
-	0x01, 0x00, 0x00,
+	// 360 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0x4f, 0xeb, 0xda, 0x30,
+	0x18, 0xc7, 0xe9, 0xaf, 0xfe, 0xa9, 0x8f, 0x73, 0x93, 0x1c, 0x46, 0xd6, 0x5d, 0xca, 0x4e, 0xdd,
+	0x25, 0x87, 0x89, 0x32, 0xd8, 0x69, 0xbb, 0x79, 0x98, 0xb0, 0x28, 0x0c, 0xbc, 0x48, 0x6c, 0x1e,
+	0x6c, 0xd0, 0x35, 0x25, 0x89, 0x43, 0x5f, 0xc4, 0xde, 0xf3, 0x48, 0xda, 0xaa, 0x3b, 0x0d, 0x6f,
+	0xcf, 0xf7, 0x29, 0x9f, 0x4f, 0xc8, 0xb7, 0x81, 0xd7, 0xb6, 0x12, 0xb5, 0x2d, 0xb5, 0x63, 0xb5,
+	0xd1, 0x4e, 0x93, 0xac, 0x28, 0x85, 0xaa, 0x58, 0xa1, 0x0d, 0x32, 0x77, 0x91, 0x7b, 0xa6, 0x2a,
+	0x87, 0xa6, 0x12, 0x27, 0x66, 0x9d, 0x36, 0xe2, 0x80, 0x1f, 0xfe, 0xf4, 0x21, 0x59, 0xb7, 0x10,
+	0x59, 0x41, 0xbf, 0xd2, 0x12, 0x2d, 0x8d, 0xb2, 0x38, 0x1f, 0x7f, 0xfa, 0xcc, 0xfe, 0x87, 0xb3,
+	0x0e, 0x65, 0x6b, 0x27, 0x1c, 0x6e, 0x0c, 0xe2, 0x4a, 0x4b, 0xe4, 0x8d, 0x86, 0xfc, 0x80, 0x91,
+	0xb2, 0xf6, 0x2c, 0xaa, 0x02, 0x2d, 0x7d, 0x09, 0xce, 0xd9, 0x13, 0xce, 0x65, 0xcb, 0xf2, 0xbb,
+	0x85, 0xfc, 0x84, 0xb1, 0x28, 0x9c, 0xfa, 0x2d, 0x9c, 0xd2, 0x95, 0xa5, 0x71, 0x90, 0xce, 0x9f,
+	0x90, 0x7e, 0xbd, 0xd1, 0xfc, 0xd1, 0x44, 0x38, 0x24, 0xf6, 0x5c, 0xd7, 0x27, 0x85, 0x96, 0xf6,
+	0x82, 0x75, 0xf1, 0x8c, 0xd5, 0x5a, 0x74, 0x6b, 0xcf, 0x5f, 0xf9, 0xcd, 0x43, 0x3e, 0xc2, 0xb4,
+	0x9b, 0x77, 0xce, 0x88, 0xe2, 0x88, 0x92, 0xf6, 0xb3, 0x28, 0x4f, 0xf8, 0x9b, 0x6e, 0xbf, 0x69,
+	0xd6, 0xe9, 0x17, 0x48, 0xba, 0xeb, 0x12, 0x02, 0xbd, 0x52, 0xd8, 0x92, 0x46, 0x59, 0x94, 0xbf,
+	0xe2, 0x61, 0x26, 0xef, 0x61, 0x84, 0x97, 0x5a, 0x99, 0xeb, 0xee, 0x97, 0xaf, 0x32, 0xca, 0x7b,
+	0x3c, 0x69, 0x16, 0xdf, 0x6d, 0x3a, 0x87, 0xc9, 0x3f, 0xfd, 0x93, 0x29, 0xc4, 0x47, 0xbc, 0xb6,
+	0x02, 0x3f, 0xde, 0x9c, 0x2f, 0x77, 0x67, 0xba, 0x00, 0xb8, 0xb7, 0xe1, 0x99, 0xbd, 0x72, 0x81,
+	0x99, 0x70, 0x3f, 0x92, 0xb7, 0x30, 0x28, 0x51, 0x1d, 0x4a, 0xd7, 0x1e, 0xd8, 0xa6, 0x74, 0x0b,
+	0xe3, 0x87, 0xfb, 0x92, 0x77, 0x90, 0x08, 0x1f, 0x77, 0x4a, 0xb6, 0x27, 0x0e, 0x43, 0x5e, 0x4a,
+	0x6f, 0xf0, 0xbf, 0x0e, 0x65, 0x67, 0x68, 0x12, 0xa1, 0x30, 0x34, 0xe8, 0x94, 0x41, 0x49, 0xe3,
+	0xf0, 0xa1, 0x8b, 0xdf, 0x46, 0xdb, 0x61, 0x5b, 0xee, 0x7e, 0x10, 0xde, 0xf0, 0xec, 0xef, 0x00,
+	0x8c, 0x31, 0xc6, 0x07, 0xd5, 0x02, 0x00, 0x00,
 }
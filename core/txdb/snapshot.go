@@ -40,9 +40,26 @@ func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 		issuances[hash] = issuance.ExpiryMs
 	}
 
+	activations := make(state.Activations, len(storedSnapshot.Activations))
+	for _, a := range storedSnapshot.Activations {
+		activations[uint8(a.Bit)] = a.Height
+	}
+
+	var supplies state.Supplies
+	if storedSnapshot.SuppliesTracked {
+		supplies = make(state.Supplies, len(storedSnapshot.Supplies))
+		for _, a := range storedSnapshot.Supplies {
+			var assetID bc.AssetID
+			copy(assetID[:], a.AssetId)
+			supplies[assetID] = state.AssetSupply{Issued: a.Issued, Retired: a.Retired}
+		}
+	}
+
 	return &state.Snapshot{
-		Tree:      tree,
-		Issuances: issuances,
+		Tree:        tree,
+		Issuances:   issuances,
+		Activations: activations,
+		Supplies:    supplies,
 	}, nil
 }
 
@@ -68,6 +85,23 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 		})
 	}
 
+	for bit, height := range snapshot.Activations {
+		storedSnapshot.Activations = append(storedSnapshot.Activations, &storage.Snapshot_Activation{
+			Bit:    uint32(bit),
+			Height: height,
+		})
+	}
+
+	storedSnapshot.SuppliesTracked = snapshot.Supplies != nil
+	for k, v := range snapshot.Supplies {
+		assetID := k
+		storedSnapshot.Supplies = append(storedSnapshot.Supplies, &storage.Snapshot_AssetSupply{
+			AssetId: assetID[:],
+			Issued:  v.Issued,
+			Retired: v.Retired,
+		})
+	}
+
 	b, err := proto.Marshal(&storedSnapshot)
 	if err != nil {
 		return errors.Wrap(err, "marshaling state snapshot")
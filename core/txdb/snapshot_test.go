@@ -27,6 +27,8 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 		lookups          []pair
 		newIssuances     map[bc.Hash]uint64
 		deletedIssuances []bc.Hash
+		newActivations   state.Activations
+		newSupplies      state.Supplies
 	}{
 		{ // add a single k/v pair
 			inserts: []pair{
@@ -65,6 +67,12 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 			deletes:          []string{"sup"},
 			deletedIssuances: []bc.Hash{bc.Hash{0x02}},
 		},
+		{ // record a soft fork activation
+			newActivations: state.Activations{3: 10005},
+		},
+		{ // record an asset's supply
+			newSupplies: state.Supplies{bc.AssetID{0x05}: {Issued: 100, Retired: 20}},
+		},
 		{ // insert and delete at the same time
 			inserts: []pair{
 				{
@@ -91,6 +99,12 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 				t.Fatal(err)
 			}
 		}
+		for bit, height := range changeset.newActivations {
+			snapshot.Activations[bit] = height
+		}
+		for assetID, supply := range changeset.newSupplies {
+			snapshot.Supplies[assetID] = supply
+		}
 
 		err := storeStateSnapshot(ctx, dbtx, snapshot, uint64(i))
 		if err != nil {
@@ -117,6 +131,12 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 		if !reflect.DeepEqual(loadedSnapshot.Issuances, snapshot.Issuances) {
 			t.Fatalf("%d: Wrote %#v issuances to db, read %#v from db\n", i, snapshot.Issuances, loadedSnapshot.Issuances)
 		}
+		if !reflect.DeepEqual(loadedSnapshot.Activations, snapshot.Activations) {
+			t.Fatalf("%d: Wrote %#v activations to db, read %#v from db\n", i, snapshot.Activations, loadedSnapshot.Activations)
+		}
+		if !reflect.DeepEqual(loadedSnapshot.Supplies, snapshot.Supplies) {
+			t.Fatalf("%d: Wrote %#v supplies to db, read %#v from db\n", i, snapshot.Supplies, loadedSnapshot.Supplies)
+		}
 		snapshot = loadedSnapshot
 	}
 }
@@ -2,6 +2,7 @@ package txdb
 
 import (
 	"context"
+	"strconv"
 
 	"chain/database/pg"
 	"chain/errors"
@@ -14,7 +15,8 @@ import (
 // It satisfies the interface protocol.Store, and provides additional
 // methods for querying current data.
 type Store struct {
-	db pg.DB
+	db     pg.DB
+	blocks *pg.Partitions
 
 	cache blockCache
 }
@@ -28,7 +30,8 @@ var _ protocol.Store = (*Store)(nil)
 // instead.
 func NewStore(db pg.DB) *Store {
 	return &Store{
-		db: db,
+		db:     db,
+		blocks: &pg.Partitions{Parent: "blocks", Column: "height"},
 		cache: newBlockCache(func(height uint64) (*bc.Block, error) {
 			const q = `SELECT data FROM blocks WHERE height = $1`
 			var b bc.Block
@@ -79,14 +82,27 @@ func (s *Store) GetSnapshot(ctx context.Context, height uint64) ([]byte, error)
 	return getRawSnapshot(ctx, s.db, height)
 }
 
+// Partition stores new blocks in partitions of size blocks each,
+// starting at the first partition boundary above height, which
+// must be the current height of the blockchain. It must be called
+// before the Store is used. See pg.Partitions.
+func (s *Store) Partition(size, height uint64) {
+	s.blocks.Size = size
+	s.blocks.StartAbove(height)
+}
+
 // SaveBlock persists a new block in the database.
 func (s *Store) SaveBlock(ctx context.Context, block *bc.Block) error {
-	const q = `
-		INSERT INTO blocks (block_hash, height, data, header)
+	table, err := s.blocks.Table(ctx, s.db, block.Height)
+	if err != nil {
+		return err
+	}
+	q := `
+		INSERT INTO ` + table + ` (block_hash, height, data, header)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (block_hash) DO NOTHING
 	`
-	_, err := s.db.Exec(ctx, q, block.Hash(), block.Height, block, &block.BlockHeader)
+	_, err = s.db.Exec(ctx, q, block.Hash(), block.Height, block, &block.BlockHeader)
 	if err != nil {
 		return errors.Wrap(err, "insert block")
 	}
@@ -102,6 +118,5 @@ func (s *Store) SaveSnapshot(ctx context.Context, height uint64, snapshot *state
 }
 
 func (s *Store) FinalizeBlock(ctx context.Context, height uint64) error {
-	_, err := s.db.Exec(ctx, `SELECT pg_notify('newblock', $1)`, height)
-	return err
+	return pg.Notify(ctx, s.db, "newblock", strconv.FormatUint(height, 10))
 }
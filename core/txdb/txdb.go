@@ -11,6 +11,10 @@ import (
 	"chain/log"
 )
 
+// ListenBlocks returns a channel that receives the height of
+// each block as it's committed, by any process using the database.
+// Store.FinalizeBlock sends the heights with Postgres NOTIFY (see
+// pg.Notify), so processes learn of new blocks without polling.
 func ListenBlocks(ctx context.Context, dbURL string) (<-chan uint64, error) {
 	listener, err := pg.NewListener(ctx, dbURL, "newblock")
 	if err != nil {
@@ -98,3 +98,57 @@ func TestInsertBlock(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestInsertBlockPartitioned(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	store := NewStore(dbtx)
+	store.Partition(10, 12)
+
+	// Partitioning starts at the boundary above the current
+	// height, so block 15 stays in the parent table.
+	for _, height := range []uint64{15, 25} {
+		blk := &bc.Block{BlockHeader: bc.BlockHeader{Version: 1, Height: height}}
+		err := store.SaveBlock(ctx, blk)
+		if err != nil {
+			t.Log(errors.Stack(err))
+			t.Fatal(err)
+		}
+		_, err = getBlockByHash(ctx, dbtx, blk.Hash().String())
+		if err != nil {
+			t.Log(errors.Stack(err))
+			t.Fatal(err)
+		}
+	}
+
+	var n int
+	err := dbtx.QueryRow(ctx, `SELECT COUNT(*) FROM ONLY blocks WHERE height=15`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d blocks at height 15 in parent, want 1", n)
+	}
+	err = dbtx.QueryRow(ctx, `SELECT COUNT(*) FROM blocks_p2 WHERE height=25`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d blocks in partition, want 1", n)
+	}
+
+	dropped, err := store.blocks.DropBefore(ctx, dbtx, 29)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 0 {
+		t.Errorf("DropBefore(29) dropped %d partitions, want 0", dropped)
+	}
+	dropped, err = store.blocks.DropBefore(ctx, dbtx, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 1 {
+		t.Errorf("DropBefore(30) dropped %d partitions, want 1", dropped)
+	}
+}
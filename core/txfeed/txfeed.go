@@ -7,6 +7,7 @@ import (
 	"database/sql"
 
 	"chain/core/query/filter"
+	"chain/core/tenant"
 	"chain/database/pg"
 	"chain/errors"
 )
@@ -24,6 +25,7 @@ type TxFeed struct {
 	After  string  `json:"after,omitempty"`
 }
 
+// Create creates a txfeed belonging to the tenant that ctx acts for.
 func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientToken *string) (*TxFeed, error) {
 	// Validate the filter.
 	_, err := filter.Parse(fil)
@@ -49,8 +51,8 @@ func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientTo
 // lookup and return the existing txfeed instead.
 func insertTxFeed(ctx context.Context, db pg.DB, feed *TxFeed, clientToken *string) (*TxFeed, error) {
 	const q = `
-		INSERT INTO txfeeds (alias, filter, after, client_token)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO txfeeds (alias, filter, after, client_token, tenant)
+		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING id
 	`
@@ -62,7 +64,7 @@ func insertTxFeed(ctx context.Context, db pg.DB, feed *TxFeed, clientToken *stri
 
 	err := db.QueryRow(
 		ctx, q, alias, feed.Filter, feed.After,
-		clientToken).Scan(&feed.ID)
+		clientToken, tenant.FromContext(ctx)).Scan(&feed.ID)
 
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetail(ErrDuplicateAlias, "a transaction feed with the provided alias already exists")
@@ -84,14 +86,14 @@ func txfeedByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Tx
 	const q = `
 		SELECT id, alias, filter, after
 		FROM txfeeds
-		WHERE client_token=$1
+		WHERE client_token=$1 AND ($2='' OR tenant=$2)
 	`
 
 	var (
 		feed  TxFeed
 		alias sql.NullString
 	)
-	err := db.QueryRow(ctx, q, clientToken).Scan(&feed.ID, &alias, &feed.Filter, &feed.After)
+	err := db.QueryRow(ctx, q, clientToken, tenant.FromContext(ctx)).Scan(&feed.ID, &alias, &feed.Filter, &feed.After)
 	if err != nil {
 		return nil, err
 	}
@@ -103,13 +105,15 @@ func txfeedByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Tx
 	return &feed, nil
 }
 
+// Find returns the txfeed with the given ID or alias. Txfeeds
+// of tenants other than the one ctx acts for are not found.
 func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 	var q bytes.Buffer
 
 	q.WriteString(`
 		SELECT id, alias, filter, after
 		FROM txfeeds
-		WHERE
+		WHERE ($2='' OR tenant=$2) AND
 	`)
 
 	if id != "" {
@@ -124,7 +128,7 @@ func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 		sqlAlias sql.NullString
 	)
 
-	err := t.DB.QueryRow(ctx, q.String(), id).Scan(&feed.ID, &sqlAlias, &feed.Filter, &feed.After)
+	err := t.DB.QueryRow(ctx, q.String(), id, tenant.FromContext(ctx)).Scan(&feed.ID, &sqlAlias, &feed.Filter, &feed.After)
 	if err != nil {
 		return nil, err
 	}
@@ -136,10 +140,12 @@ func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 	return &feed, nil
 }
 
+// Delete deletes the txfeed with the given ID or alias,
+// if it belongs to the tenant that ctx acts for.
 func (t *Tracker) Delete(ctx context.Context, id, alias string) error {
 	var q bytes.Buffer
 
-	q.WriteString(`DELETE FROM txfeeds WHERE `)
+	q.WriteString(`DELETE FROM txfeeds WHERE ($2='' OR tenant=$2) AND `)
 
 	if id != "" {
 		q.WriteString(`id=$1`)
@@ -148,7 +154,7 @@ func (t *Tracker) Delete(ctx context.Context, id, alias string) error {
 		id = alias
 	}
 
-	res, err := t.DB.Exec(ctx, q.String(), id)
+	res, err := t.DB.Exec(ctx, q.String(), id, tenant.FromContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -165,10 +171,12 @@ func (t *Tracker) Delete(ctx context.Context, id, alias string) error {
 	return nil
 }
 
+// Update moves the txfeed with the given ID or alias from prev
+// to after, if it belongs to the tenant that ctx acts for.
 func (t *Tracker) Update(ctx context.Context, id, alias, after, prev string) (*TxFeed, error) {
 	var q bytes.Buffer
 
-	q.WriteString(`UPDATE txfeeds SET after=$1 WHERE `)
+	q.WriteString(`UPDATE txfeeds SET after=$1 WHERE ($4='' OR tenant=$4) AND `)
 
 	if id != "" {
 		q.WriteString(`id=$2`)
@@ -179,7 +187,7 @@ func (t *Tracker) Update(ctx context.Context, id, alias, after, prev string) (*T
 
 	q.WriteString(` AND after=$3`)
 
-	res, err := t.DB.Exec(ctx, q.String(), after, id, prev)
+	res, err := t.DB.Exec(ctx, q.String(), after, id, prev, tenant.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
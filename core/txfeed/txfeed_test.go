@@ -2,10 +2,13 @@ package txfeed
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"testing"
 
 	"chain/core/query/filter"
+	"chain/core/tenant"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 )
@@ -97,3 +100,27 @@ func TestCreateTxFeedBadFilter(t *testing.T) {
 		t.Errorf("expected ErrBadFilter, got %s", errors.Root(err))
 	}
 }
+
+func TestTxFeedTenants(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.NewContext(ctx, "acme")
+	tracker := &Tracker{DB: pgtest.NewTx(t)}
+
+	feed, err := tracker.Create(ctx, "operator_feed", "", "1:0-0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tracker.Find(acme, feed.ID, "")
+	if err != sql.ErrNoRows {
+		t.Errorf("Find(other tenant) error = %v want %v", err, sql.ErrNoRows)
+	}
+	err = tracker.Delete(acme, "", "operator_feed")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Delete(other tenant) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+	_, err = tracker.Find(ctx, "", "operator_feed")
+	if err != nil {
+		t.Errorf("Find(default tenant) error = %v", err)
+	}
+}
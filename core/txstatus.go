@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+
+	"chain/core/forward"
+	"chain/core/leader"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+const (
+	txStatusConfirmed  = "confirmed"  // in a block
+	txStatusPending    = "pending"    // waiting in the generator's pool
+	txStatusForwarding = "forwarding" // waiting to be forwarded to the generator
+	txStatusFailed     = "failed"     // refused by the generator
+)
+
+type txStatus struct {
+	ID          bc.Hash `json:"id"`
+	Status      string  `json:"status"`
+	BlockHeight *uint64 `json:"block_height,omitempty"`
+	Attempts    int     `json:"forwarding_attempts,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// POST /get-transaction-status
+//
+// Reports whether a transaction is confirmed, pending in the
+// generator's pool, waiting to be forwarded to the generator by
+// this core, or failed. A core that isn't the generator asks the
+// generator about transactions it knows nothing of, so the answer
+// is the same whichever core the transaction was submitted to.
+// Confirmation is known only to cores that index transactions.
+func (h *Handler) getTxStatus(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) (*txStatus, error) {
+	if !leader.IsLeading() {
+		var resp txStatus
+		err := h.forwardToLeader(ctx, "/get-transaction-status", in, &resp)
+		return &resp, err
+	}
+
+	s, err := h.localTxStatus(ctx, in.ID)
+	if err != nil || s != nil {
+		return s, err
+	}
+	if txbuilder.Generator != nil {
+		var resp txStatus
+		err = txbuilder.Generator.Call(ctx, "/rpc/get-transaction-status", in.ID, &resp)
+		if err == nil && resp.Status != "" {
+			return &resp, nil
+		}
+	}
+	return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %s", in.ID)
+}
+
+// getTxStatusRPC reports what the generator knows
+// of transaction id, for /get-transaction-status.
+func (h *Handler) getTxStatusRPC(ctx context.Context, id bc.Hash) (*txStatus, error) {
+	s, err := h.localTxStatus(ctx, id)
+	if s == nil && err == nil {
+		s = new(txStatus)
+	}
+	return s, err
+}
+
+// localTxStatus returns the status of transaction id as far
+// as this core knows, or nil if it knows nothing of it.
+func (h *Handler) localTxStatus(ctx context.Context, id bc.Hash) (*txStatus, error) {
+	s := &txStatus{ID: id}
+
+	const q = `SELECT block_height FROM annotated_txs WHERE tx_hash=$1`
+	var height uint64
+	err := h.DB.QueryRow(ctx, q, id.String()).Scan(&height)
+	if err == nil {
+		s.Status, s.BlockHeight = txStatusConfirmed, &height
+		return s, nil
+	} else if err != sql.ErrNoRows {
+		return nil, errors.Wrap(err)
+	}
+
+	if h.Forwarder != nil {
+		rec, err := h.Forwarder.Find(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			s.Attempts, s.Error = rec.Attempts, rec.Error
+			switch rec.Status {
+			case forward.StatusForwarding:
+				s.Status = txStatusForwarding
+			case forward.StatusForwarded:
+				s.Status = txStatusPending
+			default:
+				s.Status = txStatusFailed
+			}
+			return s, nil
+		}
+	}
+
+	if h.Config.IsGenerator {
+		submitted, err := txSubmitted(ctx, h.DB, id)
+		if err != nil {
+			return nil, err
+		}
+		if submitted || (h.Pool != nil && h.Pool.Contains(id)) {
+			s.Status = txStatusPending
+			return s, nil
+		}
+	}
+	return nil, nil
+}
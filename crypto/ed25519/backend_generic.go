@@ -0,0 +1,11 @@
+// +build !libsodium !cgo
+
+package ed25519
+
+func sign(privateKey PrivateKey, message []byte) []byte {
+	return signGeneric(privateKey, message)
+}
+
+func verify(publicKey PublicKey, message, sig []byte) bool {
+	return verifyGeneric(publicKey, message, sig)
+}
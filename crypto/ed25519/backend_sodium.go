@@ -0,0 +1,50 @@
+// +build libsodium,cgo
+
+package ed25519
+
+// #cgo pkg-config: libsodium
+// #include <sodium.h>
+import "C"
+
+import "unsafe"
+
+func init() {
+	if C.sodium_init() < 0 {
+		panic("ed25519: libsodium initialization failed")
+	}
+}
+
+func sign(privateKey PrivateKey, message []byte) []byte {
+	signature := make([]byte, SignatureSize)
+	C.crypto_sign_detached(
+		(*C.uchar)(unsafe.Pointer(&signature[0])),
+		nil,
+		bytePtr(message),
+		C.ulonglong(len(message)),
+		(*C.uchar)(unsafe.Pointer(&privateKey[0])),
+	)
+	return signature
+}
+
+func verify(publicKey PublicKey, message, sig []byte) bool {
+	if len(sig) != SignatureSize || !strict(publicKey, sig) {
+		// Libsodium may reject what ref10 accepts.
+		return verifyGeneric(publicKey, message, sig)
+	}
+	r := C.crypto_sign_verify_detached(
+		(*C.uchar)(unsafe.Pointer(&sig[0])),
+		bytePtr(message),
+		C.ulonglong(len(message)),
+		(*C.uchar)(unsafe.Pointer(&publicKey[0])),
+	)
+	return r == 0
+}
+
+// bytePtr returns a pointer to the first byte of b,
+// or nil if b is empty.
+func bytePtr(b []byte) *C.uchar {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.uchar)(unsafe.Pointer(&b[0]))
+}
@@ -0,0 +1,156 @@
+package ed25519
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"math/big"
+	"testing"
+
+	"chain/crypto/ed25519/internal/edwards25519"
+)
+
+// TestBackendConformance checks that Sign, whichever backend
+// provides it, agrees with the pure-Go implementation, and that
+// Verify accepts its signatures and rejects altered ones.
+// It is only interesting when built with a non-default backend
+// (e.g. go test -tags libsodium).
+func TestBackendConformance(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		pub, priv, err := GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, i)
+		rand.Read(msg)
+
+		sig := Sign(priv, msg)
+		if want := signGeneric(priv, msg); !bytes.Equal(sig, want) {
+			t.Fatalf("Sign(%x, %x) = %x want %x", priv, msg, sig, want)
+		}
+
+		cases := [][]byte{sig}
+		for _, j := range []int{0, 31, 32, 63} {
+			bad := append([]byte(nil), sig...)
+			bad[j] ^= 1
+			cases = append(cases, bad)
+		}
+		for k, s := range cases {
+			got := Verify(pub, msg, s)
+			if want := k == 0; got != want {
+				t.Errorf("Verify(%x, %x, %x) = %v want %v", pub, msg, s, got, want)
+			}
+		}
+	}
+}
+
+// TestVerifyEdgeCases checks that Verify agrees with the port of
+// ref10 on signatures that libsodium rejects but ref10 accepts,
+// and that strict singles them out. Like TestBackendConformance,
+// it is most interesting with a non-default backend.
+func TestVerifyEdgeCases(t *testing.T) {
+	pub, priv, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("edge case")
+	sig := Sign(priv, msg)
+
+	// S+l is less than 2^253, so ref10 accepts it in place of S.
+	var le [32]byte
+	copy(le[:], sig[32:])
+	s := new(big.Int).SetBytes(reverse(le[:]))
+	s.Add(s, new(big.Int).SetBytes(reverse(order[:])))
+	bigS := append([]byte(nil), sig[:32]...)
+	bigS = append(bigS, reverse(leftPad(s.Bytes()))...)
+
+	var (
+		identity    = make([]byte, 32) // y = 1
+		identityAlt = make([]byte, 32) // y = p+1, a non-canonical 1
+		negOne      = make([]byte, 32) // y = p-1, of order 2
+	)
+	identity[0] = 1
+	for i := range identityAlt {
+		identityAlt[i], negOne[i] = 0xff, 0xff
+	}
+	identityAlt[0], identityAlt[31] = 0xee, 0x7f
+	negOne[0], negOne[31] = 0xec, 0x7f
+
+	cases := []struct {
+		name   string
+		pub    PublicKey
+		msg    []byte
+		sig    []byte
+		strict bool
+	}{
+		{"valid", pub, msg, sig, true},
+		{"non-canonical S", pub, msg, bigS, false},
+		{"identity A", identity, msg, baseSig(identity, msg, 7), false},
+		{"non-canonical A", identityAlt, msg, baseSig(identityAlt, msg, 7), false},
+		{"identity A and R", identity, msg, baseSig(identity, msg, 0), false},
+		{"order 2 A", negOne, evenHashMsg(t, negOne, 7), baseSig(negOne, evenHashMsg(t, negOne, 7), 7), false},
+	}
+	for _, c := range cases {
+		if !verifyGeneric(c.pub, c.msg, c.sig) {
+			t.Fatalf("%s: verifyGeneric rejected the test vector", c.name)
+		}
+		if got := strict(c.pub, c.sig); got != c.strict {
+			t.Errorf("%s: strict = %v want %v", c.name, got, c.strict)
+		}
+		if !Verify(c.pub, c.msg, c.sig) {
+			t.Errorf("%s: Verify = false, want true", c.name)
+		}
+		other := append([]byte("not "), c.msg...)
+		if got, want := Verify(c.pub, other, c.sig), verifyGeneric(c.pub, other, c.sig); got != want {
+			t.Errorf("%s: Verify(other message) = %v want %v", c.name, got, want)
+		}
+	}
+}
+
+// baseSig returns the signature (R, S) with S = s and R = sB.
+// Ref10 accepts it for a key A of small order wherever hA is the
+// identity.
+func baseSig(pub PublicKey, msg []byte, s byte) []byte {
+	var S [32]byte
+	S[0] = s
+	var R edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&R, &S)
+	var enc [32]byte
+	R.ToBytes(&enc)
+	return append(enc[:], S[:]...)
+}
+
+// evenHashMsg returns a message whose hash h, signed with
+// baseSig(pub, msg, s), is even, so that hA is the identity for
+// a key A of order 2.
+func evenHashMsg(t *testing.T, pub PublicKey, s byte) []byte {
+	for i := 0; i < 256; i++ {
+		msg := []byte{byte(i)}
+		sig := baseSig(pub, msg, s)
+		h := sha512.New()
+		h.Write(sig[:32])
+		h.Write(pub)
+		h.Write(msg)
+		var digest [64]byte
+		h.Sum(digest[:0])
+		var reduced [32]byte
+		edwards25519.ScReduce(&reduced, &digest)
+		if reduced[0]&1 == 0 {
+			return msg
+		}
+	}
+	t.Fatal("no message with an even hash")
+	return nil
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	return r
+}
+
+func leftPad(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
@@ -0,0 +1,381 @@
+// Package confidential implements Pedersen commitments to asset
+// amounts, and range proofs for them, for experimental
+// confidential outputs.
+//
+// A commitment to an amount v of an asset, with blinding factor r,
+// is the point
+//
+//	C = v·H + r·G
+//
+// where G is the ed25519 base point and H is a point derived from
+// the asset ID, whose discrete log with respect to G nobody knows.
+// Commitments add: the sum of commitments to amounts of one asset
+// commits to the sum of the amounts. So the amounts of an asset
+// in a transaction's inputs and outputs balance when the input
+// commitments, less the output commitments, sum to the identity,
+// which the transaction's builder arranges by choosing blinding
+// factors that cancel. See Balanced and BalancingBlind.
+//
+// Because amounts are reduced modulo the group order, a commitment
+// alone could hide a "negative" amount. A range proof shows that a
+// commitment is to an amount less than 2^63 without revealing it:
+// it commits to each bit of the amount separately, and proves with
+// a two-key ring signature that each bit commitment is to either
+// zero or to the value of that bit.
+//
+// This package is experimental. Its range proofs are large, about
+// 8KB, and it computes with secret amounts in variable time.
+package confidential
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"chain/crypto/ed25519/internal/edwards25519"
+	"chain/crypto/sha3pool"
+)
+
+// RangeBits is the number of bits in the amounts range proofs cover.
+const RangeBits = 63
+
+const bitProofSize = 4 * 32
+
+// RangeProofSize is the size of a range proof.
+const RangeProofSize = RangeBits * bitProofSize
+
+// A Scalar is an integer modulo the order of the group,
+// encoded in 32 little-endian bytes.
+type Scalar [32]byte
+
+// A Commitment is an encoded point committing to an amount.
+type Commitment [32]byte
+
+var (
+	zero     Scalar
+	one      = Scalar{1}
+	minusOne = Scalar{
+		0xec, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+		0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0x10,
+	}
+)
+
+// RandomScalar returns a random scalar, for use as a blinding factor.
+func RandomScalar() (Scalar, error) {
+	var b [64]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return Scalar{}, err
+	}
+	var s Scalar
+	edwards25519.ScReduce((*[32]byte)(&s), &b)
+	return s, nil
+}
+
+// BalancingBlind returns the blinding factor that, for the last
+// output of an asset, makes the blinding factors of its inputs,
+// less those of its outputs, sum to zero. Plaintext amounts have
+// blinding factor zero.
+func BalancingBlind(inputs, otherOutputs []Scalar) Scalar {
+	var sum Scalar
+	for _, s := range inputs {
+		sum = scAdd(&sum, &s)
+	}
+	for _, s := range otherOutputs {
+		sum = scSub(&sum, &s)
+	}
+	return sum
+}
+
+// Commit returns the commitment to amount of the asset,
+// with blinding factor blind.
+func Commit(assetID [32]byte, amount uint64, blind Scalar) Commitment {
+	h := generator(assetID)
+	v := scalarUint64(amount)
+	var p edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&p, (*[32]byte)(&v), h, (*[32]byte)(&blind))
+	var c Commitment
+	p.ToBytes((*[32]byte)(&c))
+	return c
+}
+
+// Balanced reports whether the commitments in, less the
+// commitments out, plus excess units of the asset, sum to the
+// identity. Excess is the sum of the asset's plaintext input
+// amounts less the sum of its plaintext output amounts.
+func Balanced(assetID [32]byte, excess int64, in, out []Commitment) bool {
+	var sum edwards25519.ExtendedGroupElement
+	sum.Zero()
+	if excess != 0 {
+		v := scalarUint64(uint64(excess))
+		if excess < 0 {
+			v = scalarUint64(uint64(-excess))
+			v = scNeg(&v)
+		}
+		sum = *scalarMult(&v, generator(assetID))
+	}
+	for _, c := range in {
+		p, ok := decode(c)
+		if !ok {
+			return false
+		}
+		sum = *add(&sum, p)
+	}
+	for _, c := range out {
+		p, ok := decode(c)
+		if !ok {
+			return false
+		}
+		sum = *add(&sum, neg(p))
+	}
+	return isIdentity(&sum)
+}
+
+// ProveRange returns a proof that the commitment to amount of the
+// asset with blinding factor blind is to an amount less than 2^63.
+func ProveRange(assetID [32]byte, amount uint64, blind Scalar) ([]byte, error) {
+	if amount>>RangeBits != 0 {
+		return nil, errRange
+	}
+	h := generator(assetID)
+	c := Commit(assetID, amount, blind)
+
+	proof := make([]byte, 0, RangeProofSize)
+	rem := blind
+	for i := uint(0); i < RangeBits; i++ {
+		var r Scalar
+		if i == RangeBits-1 {
+			r = rem
+		} else {
+			var err error
+			r, err = RandomScalar()
+			if err != nil {
+				return nil, err
+			}
+			rem = scSub(&rem, &r)
+		}
+		bit := amount >> i & 1
+
+		// The bit commitment, and the keys of its ring:
+		// itself, and itself less the value of the bit.
+		ci := commitBit(h, i, bit, &r)
+		p0, _ := decode(ci)
+		p1 := add(p0, neg(bitValue(h, i)))
+		msg := bitMessage(assetID, c, i, ci)
+
+		k, err := RandomScalar()
+		if err != nil {
+			return nil, err
+		}
+		var kG edwards25519.ExtendedGroupElement
+		edwards25519.GeScalarMultBase(&kG, (*[32]byte)(&k))
+
+		var e0, s0, s1 Scalar
+		if bit == 0 {
+			e1 := challenge(msg, encode(&kG))
+			s1, err = RandomScalar()
+			if err != nil {
+				return nil, err
+			}
+			e0 = challenge(msg, sGminusEP(&s1, &e1, p1))
+			s0 = scMulAdd(&e0, &r, &k)
+		} else {
+			e0 = challenge(msg, encode(&kG))
+			s0, err = RandomScalar()
+			if err != nil {
+				return nil, err
+			}
+			e1 := challenge(msg, sGminusEP(&s0, &e0, p0))
+			s1 = scMulAdd(&e1, &r, &k)
+		}
+		proof = append(proof, ci[:]...)
+		proof = append(proof, e0[:]...)
+		proof = append(proof, s0[:]...)
+		proof = append(proof, s1[:]...)
+	}
+	return proof, nil
+}
+
+// VerifyRange reports whether proof shows that c
+// commits to an amount of the asset less than 2^63.
+func VerifyRange(assetID [32]byte, c Commitment, proof []byte) bool {
+	if len(proof) != RangeProofSize {
+		return false
+	}
+	h := generator(assetID)
+	var sum edwards25519.ExtendedGroupElement
+	sum.Zero()
+	for i := uint(0); i < RangeBits; i++ {
+		b := proof[i*bitProofSize:]
+		var ci Commitment
+		var e0, s0, s1 Scalar
+		copy(ci[:], b[0:32])
+		copy(e0[:], b[32:64])
+		copy(s0[:], b[64:96])
+		copy(s1[:], b[96:128])
+		if !reduced(&e0) || !reduced(&s0) || !reduced(&s1) {
+			return false
+		}
+		p0, ok := decode(ci)
+		if !ok {
+			return false
+		}
+		p1 := add(p0, neg(bitValue(h, i)))
+		msg := bitMessage(assetID, c, i, ci)
+		e1 := challenge(msg, sGminusEP(&s0, &e0, p0))
+		if challenge(msg, sGminusEP(&s1, &e1, p1)) != e0 {
+			return false
+		}
+		sum = *add(&sum, p0)
+	}
+	return encode(&sum) == [32]byte(c)
+}
+
+type rangeError struct{}
+
+func (rangeError) Error() string { return "amount out of range" }
+
+var errRange error = rangeError{}
+
+// generator returns the point H for the asset. It hashes the asset
+// ID until the hash encodes a point, and multiplies that by the
+// cofactor to get a point in the prime-order subgroup.
+func generator(assetID [32]byte) *edwards25519.ExtendedGroupElement {
+	var buf [4]byte
+	for ctr := uint32(0); ; ctr++ {
+		binary.LittleEndian.PutUint32(buf[:], ctr)
+		var b [32]byte
+		h := sha3pool.Get256()
+		h.Write([]byte("ChainConfidentialH"))
+		h.Write(assetID[:])
+		h.Write(buf[:])
+		h.Read(b[:])
+		sha3pool.Put256(h)
+
+		var p edwards25519.ExtendedGroupElement
+		if !p.FromBytes(&b) {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			var c edwards25519.CompletedGroupElement
+			p.Double(&c)
+			c.ToExtended(&p)
+		}
+		if !isIdentity(&p) {
+			return &p
+		}
+	}
+}
+
+// bitValue returns 2^i·H.
+func bitValue(h *edwards25519.ExtendedGroupElement, i uint) *edwards25519.ExtendedGroupElement {
+	v := scalarUint64(1 << i)
+	return scalarMult(&v, h)
+}
+
+func commitBit(h *edwards25519.ExtendedGroupElement, i uint, bit uint64, r *Scalar) Commitment {
+	v := scalarUint64(bit << i)
+	var p edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&p, (*[32]byte)(&v), h, (*[32]byte)(r))
+	var c Commitment
+	p.ToBytes((*[32]byte)(&c))
+	return c
+}
+
+func bitMessage(assetID [32]byte, c Commitment, i uint, ci Commitment) []byte {
+	msg := []byte("ChainConfidentialRange")
+	msg = append(msg, assetID[:]...)
+	msg = append(msg, c[:]...)
+	msg = append(msg, byte(i))
+	return append(msg, ci[:]...)
+}
+
+func challenge(msg []byte, r [32]byte) Scalar {
+	h := sha512.New()
+	h.Write(msg)
+	h.Write(r[:])
+	var digest [64]byte
+	h.Sum(digest[:0])
+	var s Scalar
+	edwards25519.ScReduce((*[32]byte)(&s), &digest)
+	return s
+}
+
+// sGminusEP returns the encoding of s·G − e·P.
+func sGminusEP(s, e *Scalar, p *edwards25519.ExtendedGroupElement) [32]byte {
+	var r edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&r, (*[32]byte)(e), neg(p), (*[32]byte)(s))
+	var b [32]byte
+	r.ToBytes(&b)
+	return b
+}
+
+func decode(c Commitment) (*edwards25519.ExtendedGroupElement, bool) {
+	var p edwards25519.ExtendedGroupElement
+	b := [32]byte(c)
+	ok := p.FromBytes(&b)
+	return &p, ok
+}
+
+func encode(p *edwards25519.ExtendedGroupElement) [32]byte {
+	var b [32]byte
+	p.ToBytes(&b)
+	return b
+}
+
+func isIdentity(p *edwards25519.ExtendedGroupElement) bool {
+	return encode(p) == [32]byte{1}
+}
+
+func add(p, q *edwards25519.ExtendedGroupElement) *edwards25519.ExtendedGroupElement {
+	var qc edwards25519.CachedGroupElement
+	q.ToCached(&qc)
+	var c edwards25519.CompletedGroupElement
+	edwards25519.GeAdd(&c, p, &qc)
+	r := new(edwards25519.ExtendedGroupElement)
+	c.ToExtended(r)
+	return r
+}
+
+func neg(p *edwards25519.ExtendedGroupElement) *edwards25519.ExtendedGroupElement {
+	r := *p
+	edwards25519.FeNeg(&r.X, &r.X)
+	edwards25519.FeNeg(&r.T, &r.T)
+	return &r
+}
+
+// scalarMult returns s·p, in variable time.
+func scalarMult(s *Scalar, p *edwards25519.ExtendedGroupElement) *edwards25519.ExtendedGroupElement {
+	var r edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&r, (*[32]byte)(s), p, (*[32]byte)(&zero))
+	var b [32]byte
+	r.ToBytes(&b)
+	q := new(edwards25519.ExtendedGroupElement)
+	q.FromBytes(&b)
+	return q
+}
+
+func scalarUint64(v uint64) Scalar {
+	var s Scalar
+	binary.LittleEndian.PutUint64(s[:], v)
+	return s
+}
+
+// scMulAdd returns a·b + c.
+func scMulAdd(a, b, c *Scalar) Scalar {
+	var s Scalar
+	edwards25519.ScMulAdd((*[32]byte)(&s), (*[32]byte)(a), (*[32]byte)(b), (*[32]byte)(c))
+	return s
+}
+
+func scAdd(a, b *Scalar) Scalar { return scMulAdd(a, &one, b) }
+func scSub(a, b *Scalar) Scalar { return scMulAdd(b, &minusOne, a) }
+func scNeg(a *Scalar) Scalar    { return scMulAdd(a, &minusOne, &zero) }
+
+// reduced reports whether s is small enough to be a reduced
+// scalar, as ed25519 checks its signatures' scalars.
+func reduced(s *Scalar) bool {
+	return s[31]&224 == 0
+}
@@ -0,0 +1,80 @@
+package confidential
+
+import "testing"
+
+var (
+	assetA = [32]byte{1}
+	assetB = [32]byte{2}
+)
+
+func mustRandom(t *testing.T) Scalar {
+	s, err := RandomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestBalanced(t *testing.T) {
+	r1, r2 := mustRandom(t), mustRandom(t)
+	in := []Commitment{Commit(assetA, 10, r1), Commit(assetA, 5, r2)}
+
+	r3 := mustRandom(t)
+	r4 := BalancingBlind([]Scalar{r1, r2}, []Scalar{r3})
+	out := []Commitment{Commit(assetA, 12, r3), Commit(assetA, 3, r4)}
+	if !Balanced(assetA, 0, in, out) {
+		t.Error("balanced commitments reported unbalanced")
+	}
+
+	// With a plaintext output of 3 units, whose blinding factor
+	// is zero, the one committed output balances the inputs.
+	r5 := BalancingBlind([]Scalar{r1, r2}, nil)
+	out = []Commitment{Commit(assetA, 12, r5)}
+	if Balanced(assetA, 0, in, out) {
+		t.Error("commitments short 3 units reported balanced")
+	}
+	if !Balanced(assetA, -3, in, out) {
+		t.Error("commitments with excess -3 reported unbalanced")
+	}
+
+	out = []Commitment{Commit(assetA, 12, r3), Commit(assetA, 4, r4)}
+	if Balanced(assetA, 0, in, out) {
+		t.Error("commitments to 16 units out of 15 reported balanced")
+	}
+	out = []Commitment{Commit(assetB, 12, r3), Commit(assetB, 3, r4)}
+	if Balanced(assetA, 0, in, out) {
+		t.Error("commitments to another asset reported balanced")
+	}
+}
+
+func TestRangeProof(t *testing.T) {
+	for _, amount := range []uint64{0, 1, 1000, 1<<63 - 1} {
+		blind := mustRandom(t)
+		proof, err := ProveRange(assetA, amount, blind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(proof) != RangeProofSize {
+			t.Errorf("len(proof) = %d want %d", len(proof), RangeProofSize)
+		}
+		c := Commit(assetA, amount, blind)
+		if !VerifyRange(assetA, c, proof) {
+			t.Errorf("VerifyRange(%d) = false want true", amount)
+		}
+		if VerifyRange(assetB, c, proof) {
+			t.Errorf("VerifyRange(%d) for another asset = true want false", amount)
+		}
+		if VerifyRange(assetA, Commit(assetA, amount+1, blind), proof) {
+			t.Errorf("VerifyRange(%d) of another commitment = true want false", amount)
+		}
+		proof[100] ^= 1
+		if VerifyRange(assetA, c, proof) {
+			t.Errorf("VerifyRange(%d) of altered proof = true want false", amount)
+		}
+	}
+
+	_, err := ProveRange(assetA, 1<<63, mustRandom(t))
+	if err == nil {
+		t.Error("ProveRange(2^63) succeeded, want error")
+	}
+}
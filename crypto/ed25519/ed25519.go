@@ -8,6 +8,15 @@
 //
 // These functions are also compatible with the “Ed25519” function defined in
 // https://tools.ietf.org/html/draft-irtf-cfrg-eddsa-05.
+//
+// By default Sign and Verify use a pure-Go port of ref10.
+// Building with the libsodium tag (and cgo enabled) makes them
+// call libsodium instead, which is considerably faster.
+// Libsodium is stricter than ref10: it rejects signatures with a
+// non-canonical S and keys or R values of small order, which ref10
+// accepts. Every core on a network must agree about the validity
+// of such signatures, so Verify passes them to the port of ref10
+// instead, and accepts exactly what ref10 does with either backend.
 package ed25519
 
 // This code is a port of the public domain, “ref10” implementation of ed25519
@@ -98,7 +107,11 @@ func Sign(privateKey PrivateKey, message []byte) []byte {
 	if l := len(privateKey); l != PrivateKeySize {
 		panic("ed25519: bad private key length: " + strconv.Itoa(l))
 	}
+	return sign(privateKey, message)
+}
 
+// signGeneric is the pure-Go implementation of Sign.
+func signGeneric(privateKey PrivateKey, message []byte) []byte {
 	h := sha512.New()
 	h.Write(privateKey[:32])
 
@@ -147,7 +160,11 @@ func Verify(publicKey PublicKey, message, sig []byte) bool {
 	if l := len(publicKey); l != PublicKeySize {
 		panic("ed25519: bad public key length: " + strconv.Itoa(l))
 	}
+	return verify(publicKey, message, sig)
+}
 
+// verifyGeneric is the pure-Go implementation of Verify.
+func verifyGeneric(publicKey PublicKey, message, sig []byte) bool {
 	if len(sig) != SignatureSize || sig[63]&224 != 0 {
 		return false
 	}
@@ -0,0 +1,70 @@
+package ed25519
+
+import "chain/crypto/ed25519/internal/edwards25519"
+
+// order is the order of the base point, l = 2^252 +
+// 27742317777372353535851937790883648493, little-endian.
+var order = [32]byte{
+	0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+	0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+}
+
+// strict reports whether the signature sig by publicKey avoids
+// every case in which libsodium is stricter than ref10: its S
+// is canonical (less than l), and its R and publicKey are
+// canonical encodings of points that are not of small order.
+// Ref10 and libsodium agree about the validity of such
+// signatures. The length of sig must be SignatureSize.
+func strict(publicKey PublicKey, sig []byte) bool {
+	return canonicalScalar(sig[32:]) && strictPoint(publicKey) && strictPoint(sig[:32])
+}
+
+// canonicalScalar reports whether the little-endian
+// 32-byte scalar s is less than l.
+func canonicalScalar(s []byte) bool {
+	for i := 31; i >= 0; i-- {
+		if s[i] != order[i] {
+			return s[i] < order[i]
+		}
+	}
+	return false // s == l
+}
+
+// strictPoint reports whether the 32 bytes b are the canonical
+// encoding of a point whose order is not a divisor of the
+// cofactor, 8.
+func strictPoint(b []byte) bool {
+	// The y coordinate must be less than p = 2^255 - 19.
+	if b[0] >= 0xed && b[31]&0x7f == 0x7f {
+		allOnes := true
+		for _, c := range b[1:31] {
+			allOnes = allOnes && c == 0xff
+		}
+		if allOnes {
+			return false
+		}
+	}
+
+	var (
+		buf [32]byte
+		A   edwards25519.ExtendedGroupElement
+		P   edwards25519.ProjectiveGroupElement
+		C   edwards25519.CompletedGroupElement
+	)
+	copy(buf[:], b)
+	if !A.FromBytes(&buf) {
+		return false
+	}
+	A.ToProjective(&P)
+	for i := 0; i < 3; i++ {
+		P.Double(&C)
+		C.ToProjective(&P)
+	}
+
+	// 8A is the identity if its X is 0 and its Y equals its Z.
+	var d edwards25519.FieldElement
+	edwards25519.FeSub(&d, &P.Y, &P.Z)
+	return edwards25519.FeIsNonZero(&P.X) == 1 || edwards25519.FeIsNonZero(&d) == 1
+}
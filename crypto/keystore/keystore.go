@@ -0,0 +1,222 @@
+// Package keystore implements an encrypted file format for
+// chainkd xprvs, so that keys can be kept at rest without
+// exposing them in plaintext.
+//
+// A key file is a JSON object holding the xprv encrypted with
+// NaCl secretbox (XSalsa20 and Poly1305), under a key derived
+// from a passphrase with scrypt, and the corresponding xpub,
+// so the file can be identified without unlocking it.
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// Version is the version of the key file format.
+const Version = 1
+
+// KDFScrypt is the name of the only supported key
+// derivation function.
+const KDFScrypt = "scrypt"
+
+const (
+	saltSize = 32
+
+	// maxN, maxR, maxP, and maxMemory bound the scrypt
+	// cost of files this package will unlock, so that a
+	// crafted file can't make unlocking it take unbounded
+	// time and memory. Scrypt uses 128·N·r bytes of memory,
+	// and its time grows with N·r·p.
+	maxN      = 1 << 20
+	maxR      = 32
+	maxP      = 16
+	maxMemory = 1 << 30
+)
+
+var (
+	// ErrBadPassphrase is returned when unlocking
+	// a key file with the wrong passphrase.
+	ErrBadPassphrase = errors.New("wrong passphrase")
+
+	// ErrBadKeyFile is returned for key files that are
+	// malformed or use an unsupported format.
+	ErrBadKeyFile = errors.New("invalid key file")
+)
+
+// Params are the scrypt parameters used to derive
+// a file's encryption key from its passphrase.
+type Params struct {
+	N    int                `json:"n"`
+	R    int                `json:"r"`
+	P    int                `json:"p"`
+	Salt chainjson.HexBytes `json:"salt,omitempty"`
+}
+
+var (
+	// StandardParams take about a second and 256MB of
+	// memory to derive a key on typical hardware.
+	StandardParams = Params{N: 1 << 18, R: 8, P: 1}
+
+	// LightParams take a fraction of the time and memory
+	// of StandardParams, at the cost of weaker protection
+	// against guessing the passphrase.
+	LightParams = Params{N: 1 << 12, R: 8, P: 6}
+)
+
+// A Key is the contents of a key file.
+type Key struct {
+	Version    int                `json:"version"`
+	XPub       chainkd.XPub       `json:"xpub"`
+	KDF        string             `json:"kdf"`
+	KDFParams  Params             `json:"kdf_params"`
+	Nonce      chainjson.HexBytes `json:"nonce"`
+	Ciphertext chainjson.HexBytes `json:"ciphertext"`
+}
+
+// Create encrypts xprv under passphrase, deriving the
+// encryption key with the scrypt parameters p and a
+// random salt.
+func Create(xprv chainkd.XPrv, passphrase []byte, p Params) (*Key, error) {
+	p.Salt = make([]byte, saltSize)
+	_, err := rand.Read(p.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating salt")
+	}
+	secret, err := deriveKey(passphrase, p)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "generating nonce")
+	}
+	return &Key{
+		Version:    Version,
+		XPub:       xprv.XPub(),
+		KDF:        KDFScrypt,
+		KDFParams:  p,
+		Nonce:      nonce[:],
+		Ciphertext: secretbox.Seal(nil, xprv[:], &nonce, secret),
+	}, nil
+}
+
+// Unlock decrypts the xprv in k using passphrase.
+func (k *Key) Unlock(passphrase []byte) (chainkd.XPrv, error) {
+	var xprv chainkd.XPrv
+	if k.Version != Version {
+		return xprv, errors.WithDetailf(ErrBadKeyFile, "unsupported version %d", k.Version)
+	}
+	if k.KDF != KDFScrypt {
+		return xprv, errors.WithDetailf(ErrBadKeyFile, "unsupported kdf %q", k.KDF)
+	}
+	if len(k.Nonce) != 24 {
+		return xprv, errors.WithDetail(ErrBadKeyFile, "nonce must be 24 bytes")
+	}
+	err := k.KDFParams.check()
+	if err != nil {
+		return xprv, err
+	}
+	secret, err := deriveKey(passphrase, k.KDFParams)
+	if err != nil {
+		return xprv, errors.WithDetail(ErrBadKeyFile, err.Error())
+	}
+	var nonce [24]byte
+	copy(nonce[:], k.Nonce)
+	b, ok := secretbox.Open(nil, k.Ciphertext, &nonce, secret)
+	if !ok {
+		return xprv, ErrBadPassphrase
+	}
+	if len(b) != len(xprv) {
+		return xprv, errors.WithDetail(ErrBadKeyFile, "encrypted key has the wrong length")
+	}
+	copy(xprv[:], b)
+	if xprv.XPub() != k.XPub {
+		return chainkd.XPrv{}, errors.WithDetail(ErrBadKeyFile, "xprv does not match xpub")
+	}
+	return xprv, nil
+}
+
+// Rotate returns k encrypted under passphrase newPass instead
+// of oldPass, with a fresh salt and nonce. It doesn't change k.
+func (k *Key) Rotate(oldPass, newPass []byte) (*Key, error) {
+	xprv, err := k.Unlock(oldPass)
+	if err != nil {
+		return nil, err
+	}
+	return Create(xprv, newPass, k.KDFParams)
+}
+
+// check returns ErrBadKeyFile if p is out of the bounds
+// on the cost of unlocking a file.
+func (p Params) check() error {
+	if p.N > maxN {
+		return errors.WithDetailf(ErrBadKeyFile, "scrypt n %d exceeds %d", p.N, maxN)
+	}
+	if p.R < 1 || p.R > maxR {
+		return errors.WithDetailf(ErrBadKeyFile, "scrypt r %d is not between 1 and %d", p.R, maxR)
+	}
+	if p.P < 1 || p.P > maxP {
+		return errors.WithDetailf(ErrBadKeyFile, "scrypt p %d is not between 1 and %d", p.P, maxP)
+	}
+	if mem := 128 * int64(p.N) * int64(p.R); mem > maxMemory {
+		return errors.WithDetailf(ErrBadKeyFile, "scrypt needs %d bytes of memory, more than %d", mem, maxMemory)
+	}
+	return nil
+}
+
+func deriveKey(passphrase []byte, p Params) (*[32]byte, error) {
+	if len(p.Salt) == 0 {
+		return nil, errors.WithDetail(ErrBadKeyFile, "missing salt")
+	}
+	b, err := scrypt.Key(passphrase, p.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving key")
+	}
+	var secret [32]byte
+	copy(secret[:], b)
+	return &secret, nil
+}
+
+// ReadFile reads the key file at path.
+func ReadFile(path string) (*Key, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	k := new(Key)
+	err = json.Unmarshal(b, k)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadKeyFile, err.Error())
+	}
+	return k, nil
+}
+
+// WriteFile writes k to a new key file at path, readable
+// only by its owner. It doesn't overwrite existing files.
+func WriteFile(path string, k *Key) error {
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(f.Close())
+}
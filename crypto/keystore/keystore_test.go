@@ -0,0 +1,141 @@
+package keystore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+var testParams = Params{N: 1 << 10, R: 8, P: 1}
+
+func TestCreateUnlockRotate(t *testing.T) {
+	xprv, _, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := Create(xprv, []byte("old"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.XPub != xprv.XPub() {
+		t.Errorf("xpub = %s, want %s", k.XPub, xprv.XPub())
+	}
+	got, err := k.Unlock([]byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != xprv {
+		t.Error("unlocked the wrong xprv")
+	}
+	_, err = k.Unlock([]byte("wrong"))
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Errorf("Unlock(wrong) err = %v, want ErrBadPassphrase", err)
+	}
+
+	rotated, err := k.Rotate([]byte("old"), []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rotated.Unlock([]byte("old")); errors.Root(err) != ErrBadPassphrase {
+		t.Errorf("rotated Unlock(old) err = %v, want ErrBadPassphrase", err)
+	}
+	got, err = rotated.Unlock([]byte("new"))
+	if err != nil || got != xprv {
+		t.Errorf("rotated Unlock(new) = %v, want the xprv", err)
+	}
+
+	// A file whose xpub doesn't match its xprv is rejected.
+	_, other, _ := chainkd.NewXKeys(nil)
+	k.XPub = other
+	if _, err = k.Unlock([]byte("old")); errors.Root(err) != ErrBadKeyFile {
+		t.Errorf("mismatched xpub err = %v, want ErrBadKeyFile", err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	xprv, _, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := Create(xprv, []byte("pass"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key.json")
+	err = WriteFile(path, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteFile(path, k); err == nil {
+		t.Error("WriteFile overwrote an existing file")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	read, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := read.Unlock([]byte("pass"))
+	if err != nil || got != xprv {
+		t.Errorf("Unlock after ReadFile = %v, want the xprv", err)
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"version": 2})
+	ioutil.WriteFile(filepath.Join(dir, "v2.json"), b, 0600)
+	read, err = ReadFile(filepath.Join(dir, "v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = read.Unlock([]byte("pass")); errors.Root(err) != ErrBadKeyFile {
+		t.Errorf("version 2 err = %v, want ErrBadKeyFile", err)
+	}
+}
+
+func TestUnlockCostBounds(t *testing.T) {
+	xprv, _, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := Create(xprv, []byte("pass"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		n, r, p int
+	}{
+		{maxN * 2, 1, 1},
+		{1 << 4, maxR + 1, 1},
+		{1 << 4, 1 << 20, 1},
+		{1 << 4, 0, 1},
+		{1 << 4, 1, maxP + 1},
+		{1 << 4, 1, 1 << 20},
+		{1 << 4, 1, 0},
+		{maxN, maxR, 1}, // 4GB of memory
+	}
+	for _, c := range cases {
+		bad := *k
+		bad.KDFParams.N, bad.KDFParams.R, bad.KDFParams.P = c.n, c.r, c.p
+		_, err := bad.Unlock([]byte("pass"))
+		if errors.Root(err) != ErrBadKeyFile {
+			t.Errorf("Unlock with n=%d r=%d p=%d: err = %v, want ErrBadKeyFile", c.n, c.r, c.p, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package kms
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awskms "github.com/aws/aws-sdk-go/service/kms"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+// AWS KMS operations the vendored SDK predates.
+const (
+	awsOpSign         = "Sign"
+	awsOpGetPublicKey = "GetPublicKey"
+
+	awsEd25519Spec = "ECC_NIST_EDWARDS25519"
+	awsEd25519Alg  = "ED25519_SHA_512"
+)
+
+type awsSignInput struct {
+	KeyID            *string `locationName:"KeyId" type:"string" required:"true"`
+	Message          []byte  `type:"blob" required:"true"`
+	MessageType      *string `type:"string"`
+	SigningAlgorithm *string `type:"string" required:"true"`
+}
+
+type awsSignOutput struct {
+	KeyID     *string `locationName:"KeyId" type:"string"`
+	Signature []byte  `type:"blob"`
+}
+
+type awsGetPublicKeyInput struct {
+	KeyID *string `locationName:"KeyId" type:"string" required:"true"`
+}
+
+type awsGetPublicKeyOutput struct {
+	KeyID     *string `locationName:"KeyId" type:"string"`
+	KeySpec   *string `type:"string"`
+	KeyUsage  *string `type:"string"`
+	PublicKey []byte  `type:"blob"`
+}
+
+// newAWSClient returns a client for the AWS KMS region of keyID,
+// if it is an ARN, or else the region configured in the
+// environment, with credentials found as aws.DefaultConfig does.
+func newAWSClient(keyID string) *awskms.KMS {
+	config := aws.NewConfig()
+	if parts := strings.Split(keyID, ":"); len(parts) >= 6 && parts[0] == "arn" {
+		config = config.WithRegion(parts[3])
+	}
+	return awskms.New(config)
+}
+
+// NewAWSSigner returns a Signer for the AWS KMS key keyID,
+// which must be an ed25519 signing key.
+func NewAWSSigner(ctx context.Context, client *awskms.KMS, keyID string) (Signer, error) {
+	var out awsGetPublicKeyOutput
+	err := awsCall(client, awsOpGetPublicKey, &awsGetPublicKeyInput{KeyID: aws.String(keyID)}, &out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting public key of %s", keyID)
+	}
+	if aws.StringValue(out.KeySpec) != awsEd25519Spec {
+		return nil, errors.WithDetailf(ErrNotEd25519, "key %s has key spec %s", keyID, aws.StringValue(out.KeySpec))
+	}
+	pub, err := parseSPKI(out.PublicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "key %s", keyID)
+	}
+	return &remoteSigner{
+		name: "awskms:" + keyID,
+		pub:  pub,
+		sign: func(ctx context.Context, msg []byte) ([]byte, error) {
+			in := &awsSignInput{
+				KeyID:            aws.String(keyID),
+				Message:          msg,
+				MessageType:      aws.String("RAW"),
+				SigningAlgorithm: aws.String(awsEd25519Alg),
+			}
+			var out awsSignOutput
+			err := awsCall(client, awsOpSign, in, &out)
+			if err != nil {
+				return nil, err
+			}
+			if len(out.Signature) != ed25519.SignatureSize {
+				return nil, errors.WithDetailf(ErrBadSignature, "signature is %d bytes", len(out.Signature))
+			}
+			return out.Signature, nil
+		},
+	}, nil
+}
+
+// awsCall sends the KMS request for op with params in
+// and decodes its response into out.
+func awsCall(client *awskms.KMS, op string, in, out interface{}) error {
+	req := aws.NewRequest(client.Service, &aws.Operation{Name: op, HTTPMethod: "POST", HTTPPath: "/"}, in, out)
+	return errors.Wrap(req.Send(), "aws kms "+op)
+}
+
+// AWSWrapper is a Wrapper using the AWS KMS
+// symmetric encryption key KeyID.
+type AWSWrapper struct {
+	Client *awskms.KMS
+	KeyID  string
+}
+
+// Wrap encrypts plaintext under w's key.
+func (w *AWSWrapper) Wrap(ctx context.Context, plaintext []byte) ([]byte, error) {
+	out, err := w.Client.Encrypt(&awskms.EncryptInput{KeyID: aws.String(w.KeyID), Plaintext: plaintext})
+	if err != nil {
+		return nil, errors.Wrapf(err, "aws kms Encrypt with %s", w.KeyID)
+	}
+	return out.CiphertextBlob, nil
+}
+
+// Unwrap decrypts ciphertext under w's key.
+func (w *AWSWrapper) Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	out, err := w.Client.Decrypt(&awskms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, errors.Wrapf(err, "aws kms Decrypt with %s", w.KeyID)
+	}
+	return out.Plaintext, nil
+}
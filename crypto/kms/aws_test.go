@@ -0,0 +1,86 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	awskms "github.com/aws/aws-sdk-go/service/kms"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+func TestAWSSigner(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySpec := awsEd25519Spec
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Error("request is not signed")
+		}
+		var in struct {
+			KeyId            string
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		json.NewDecoder(req.Body).Decode(&in)
+		if in.KeyId != "alias/block" {
+			t.Errorf("KeyId = %q, want alias/block", in.KeyId)
+		}
+		switch req.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeySpec":   keySpec,
+				"PublicKey": append(spkiPrefix[:len(spkiPrefix):len(spkiPrefix)], pub...),
+			})
+		case "TrentService.Sign":
+			if in.MessageType != "RAW" || in.SigningAlgorithm != awsEd25519Alg {
+				t.Errorf("signing %s with %s, want RAW with %s", in.MessageType, in.SigningAlgorithm, awsEd25519Alg)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Signature": ed25519.Sign(prv, in.Message),
+			})
+		default:
+			t.Errorf("unexpected target %s", req.Header.Get("X-Amz-Target"))
+		}
+	}))
+	defer srv.Close()
+
+	client := awskms.New(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")).
+		WithMaxRetries(0))
+	ctx := context.Background()
+	s, err := NewAWSSigner(ctx, client, "alias/block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.Public(), pub) {
+		t.Fatalf("public key = %x, want %x", s.Public(), pub)
+	}
+	msg := []byte("msg")
+	sig, err := s.Sign(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("signature doesn't verify")
+	}
+
+	keySpec = "ECC_NIST_P256"
+	_, err = NewAWSSigner(ctx, client, "alias/block")
+	if errors.Root(err) != ErrNotEd25519 {
+		t.Errorf("P-256 key err = %v, want ErrNotEd25519", err)
+	}
+}
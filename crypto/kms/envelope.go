@@ -0,0 +1,129 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// EnvelopeVersion is the version of the envelope file format.
+const EnvelopeVersion = 1
+
+// ErrBadEnvelope is returned when opening envelope files
+// that are malformed, or whose key can't be decrypted.
+var ErrBadEnvelope = errors.New("invalid envelope file")
+
+// An Envelope holds an ed25519 private key encrypted with
+// NaCl secretbox under a random data key, which is in turn
+// encrypted under the KMS key named by KEK, a key URI. Only
+// the KMS can unwrap the data key, but signing with the key
+// requires no further calls to the KMS.
+type Envelope struct {
+	Version    int                `json:"version"`
+	Pub        chainjson.HexBytes `json:"pub"`
+	KEK        string             `json:"kek"`
+	WrappedKey chainjson.HexBytes `json:"wrapped_key"`
+	Nonce      chainjson.HexBytes `json:"nonce"`
+	Ciphertext chainjson.HexBytes `json:"ciphertext"`
+}
+
+// Seal returns an Envelope holding prv, with its data
+// key wrapped by w, the Wrapper named by the URI kek.
+func Seal(ctx context.Context, prv ed25519.PrivateKey, kek string, w Wrapper) (*Envelope, error) {
+	if len(prv) != ed25519.PrivateKeySize {
+		return nil, errors.New("bad private key length")
+	}
+	var dek [32]byte
+	var nonce [24]byte
+	_, err := rand.Read(dek[:])
+	if err == nil {
+		_, err = rand.Read(nonce[:])
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "generating data key")
+	}
+	wrapped, err := w.Wrap(ctx, dek[:])
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Version:    EnvelopeVersion,
+		Pub:        chainjson.HexBytes(prv.Public().(ed25519.PublicKey)),
+		KEK:        kek,
+		WrappedKey: wrapped,
+		Nonce:      nonce[:],
+		Ciphertext: secretbox.Seal(nil, prv, &nonce, &dek),
+	}, nil
+}
+
+// Open unwraps e's data key with w, and returns
+// a Signer holding the private key it decrypts.
+func (e *Envelope) Open(ctx context.Context, w Wrapper) (Signer, error) {
+	if e.Version != EnvelopeVersion {
+		return nil, errors.WithDetailf(ErrBadEnvelope, "unsupported version %d", e.Version)
+	}
+	if len(e.Nonce) != 24 {
+		return nil, errors.WithDetail(ErrBadEnvelope, "nonce must be 24 bytes")
+	}
+	dek, err := w.Unwrap(ctx, e.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(dek) != 32 {
+		return nil, errors.WithDetail(ErrBadEnvelope, "data key must be 32 bytes")
+	}
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], dek)
+	copy(nonce[:], e.Nonce)
+	prv, ok := secretbox.Open(nil, e.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, errors.WithDetail(ErrBadEnvelope, "cannot decrypt private key")
+	}
+	if len(prv) != ed25519.PrivateKeySize || !bytes.Equal(ed25519.PrivateKey(prv).Public().(ed25519.PublicKey), e.Pub) {
+		return nil, errors.WithDetail(ErrBadEnvelope, "private key does not match public key")
+	}
+	return &localSigner{prv: prv}, nil
+}
+
+// ReadEnvelope reads the envelope file at path.
+func ReadEnvelope(path string) (*Envelope, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading envelope file")
+	}
+	e := new(Envelope)
+	err = json.Unmarshal(b, e)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadEnvelope, err.Error())
+	}
+	return e, nil
+}
+
+// WriteEnvelope writes e to a new file at path,
+// readable only by its owner. It fails if the
+// file exists.
+func WriteEnvelope(path string, e *Envelope) error {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.Wrap(err, "creating envelope file")
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Wrap(err, "writing envelope file")
+}
@@ -0,0 +1,84 @@
+package kms
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+// xorWrapper stands in for a KMS key.
+type xorWrapper byte
+
+func (w xorWrapper) Wrap(ctx context.Context, p []byte) ([]byte, error) {
+	c := make([]byte, len(p))
+	for i := range p {
+		c[i] = p[i] ^ byte(w)
+	}
+	return c, nil
+}
+
+func (w xorWrapper) Unwrap(ctx context.Context, c []byte) ([]byte, error) {
+	return w.Wrap(ctx, c)
+}
+
+func TestEnvelope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Seal(ctx, prv, "awskms:alias/kek", xorWrapper(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "block.key")
+	err = WriteEnvelope(path, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = ReadEnvelope(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.KEK != "awskms:alias/kek" {
+		t.Errorf("KEK = %q, want awskms:alias/kek", e.KEK)
+	}
+
+	s, err := e.Open(ctx, xorWrapper(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("msg")
+	sig, err := s.Sign(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("signature doesn't verify")
+	}
+
+	_, err = e.Open(ctx, xorWrapper(2))
+	if errors.Root(err) != ErrBadEnvelope {
+		t.Errorf("open with wrong key err = %v, want ErrBadEnvelope", err)
+	}
+}
+
+func TestOpenBadURI(t *testing.T) {
+	for _, uri := range []string{"", "awskms:", "vault:key", "/path/to/key"} {
+		_, err := Open(context.Background(), uri)
+		if errors.Root(err) != ErrBadURI {
+			t.Errorf("Open(%q) err = %v, want ErrBadURI", uri, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"chain/errors"
+)
+
+const (
+	gcpBaseURL     = "https://cloudkms.googleapis.com/v1/"
+	gcpEd25519Alg  = "EC_SIGN_ED25519"
+	gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// A GCPClient calls the Google Cloud KMS REST API.
+type GCPClient struct {
+	BaseURL    string // defaults to the public API endpoint
+	HTTPClient *http.Client
+
+	// Token returns an OAuth 2.0 access token
+	// authorizing each request.
+	Token func(context.Context) (string, error)
+}
+
+// DefaultGCPClient authorizes its requests with the access token
+// in the environment variable GOOGLE_OAUTH_ACCESS_TOKEN, if set,
+// or else with tokens for the default service account from the
+// Compute Engine metadata server.
+var DefaultGCPClient = &GCPClient{Token: envOrMetadataToken()}
+
+// NewGCPSigner returns a Signer for the Google Cloud KMS key
+// version name, which must be an EC_SIGN_ED25519 key.
+func NewGCPSigner(ctx context.Context, client *GCPClient, name string) (Signer, error) {
+	var out struct {
+		PEM       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}
+	err := client.call(ctx, "GET", name+"/publicKey", nil, &out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting public key of %s", name)
+	}
+	if out.Algorithm != gcpEd25519Alg {
+		return nil, errors.WithDetailf(ErrNotEd25519, "key %s has algorithm %s", name, out.Algorithm)
+	}
+	block, _ := pem.Decode([]byte(out.PEM))
+	if block == nil {
+		return nil, errors.WithDetailf(ErrNotEd25519, "key %s has no PEM public key", name)
+	}
+	pub, err := parseSPKI(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "key %s", name)
+	}
+	return &remoteSigner{
+		name: "gcpkms:" + name,
+		pub:  pub,
+		sign: func(ctx context.Context, msg []byte) ([]byte, error) {
+			var out struct {
+				Signature []byte `json:"signature"`
+			}
+			err := client.call(ctx, "POST", name+":asymmetricSign", map[string][]byte{"data": msg}, &out)
+			return out.Signature, err
+		},
+	}, nil
+}
+
+// GCPWrapper is a Wrapper using the Google Cloud KMS
+// symmetric encryption key Name, projects/.../cryptoKeys/K.
+type GCPWrapper struct {
+	Client *GCPClient
+	Name   string
+}
+
+// Wrap encrypts plaintext under w's key.
+func (w *GCPWrapper) Wrap(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := w.Client.call(ctx, "POST", w.Name+":encrypt", map[string][]byte{"plaintext": plaintext}, &out)
+	return out.Ciphertext, errors.Wrapf(err, "encrypting with %s", w.Name)
+}
+
+// Unwrap decrypts ciphertext under w's key.
+func (w *GCPWrapper) Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	err := w.Client.call(ctx, "POST", w.Name+":decrypt", map[string][]byte{"ciphertext": ciphertext}, &out)
+	return out.Plaintext, errors.Wrapf(err, "decrypting with %s", w.Name)
+}
+
+// call sends a request for the resource path with the JSON
+// encoding of in, if not nil, and decodes the response into out.
+func (c *GCPClient) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		err := json.NewEncoder(&body).Encode(in)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	base := c.BaseURL
+	if base == "" {
+		base = gcpBaseURL
+	}
+	req, err := http.NewRequest(method, base+path, &body)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	token, err := c.Token(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting access token")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "gcp kms request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("gcp kms: %s: %s %s", resp.Status, e.Error.Status, e.Error.Message)
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decoding gcp kms response")
+}
+
+// envOrMetadataToken returns a token source for DefaultGCPClient.
+func envOrMetadataToken() func(context.Context) (string, error) {
+	if t := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); t != "" {
+		return func(context.Context) (string, error) { return t, nil }
+	}
+	m := new(metadataToken)
+	return m.get
+}
+
+// metadataToken caches access tokens from
+// the Compute Engine metadata server.
+type metadataToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (m *metadataToken) get(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" && time.Now().Add(time.Minute).Before(m.expires) {
+		return m.token, nil
+	}
+	req, err := http.NewRequest("GET", gcpMetadataURL, nil)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "requesting token from metadata server")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("metadata server: " + resp.Status)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return "", errors.Wrap(err, "decoding metadata server token")
+	}
+	m.token = out.AccessToken
+	m.expires = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	return m.token, nil
+}
@@ -0,0 +1,67 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/crypto/ed25519"
+)
+
+func TestGCPSigner(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const name = "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %q, want Bearer token", req.Header.Get("Authorization"))
+		}
+		switch req.URL.Path {
+		case "/" + name + "/publicKey":
+			der := append(spkiPrefix[:len(spkiPrefix):len(spkiPrefix)], pub...)
+			json.NewEncoder(w).Encode(map[string]string{
+				"algorithm": gcpEd25519Alg,
+				"pem":       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			})
+		case "/" + name + ":asymmetricSign":
+			var in struct{ Data []byte }
+			json.NewDecoder(req.Body).Decode(&in)
+			json.NewEncoder(w).Encode(map[string][]byte{"signature": ed25519.Sign(prv, in.Data)})
+		default:
+			http.Error(w, `{"error": {"status": "NOT_FOUND"}}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := &GCPClient{
+		BaseURL: srv.URL + "/",
+		Token:   func(context.Context) (string, error) { return "token", nil },
+	}
+	ctx := context.Background()
+	s, err := NewGCPSigner(ctx, client, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.Public(), pub) {
+		t.Fatalf("public key = %x, want %x", s.Public(), pub)
+	}
+	msg := []byte("msg")
+	sig, err := s.Sign(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("signature doesn't verify")
+	}
+
+	_, err = NewGCPSigner(ctx, client, name+"x")
+	if err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
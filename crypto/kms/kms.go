@@ -0,0 +1,165 @@
+// Package kms implements ed25519 signers whose private keys are
+// kept in a cloud key management service (KMS), for deployments
+// that require cloud-managed keys.
+//
+// A key may be held by the KMS itself, which then computes every
+// signature, where the KMS supports ed25519 keys: AWS KMS keys
+// with key spec ECC_NIST_EDWARDS25519, and Google Cloud KMS keys
+// with algorithm EC_SIGN_ED25519. Otherwise, a key may be kept in
+// a local envelope file, encrypted under a data key that is itself
+// encrypted under a KMS key, and unwrapped by the KMS once, when
+// the file is opened. See Envelope.
+//
+// Signers are named by URIs:
+//
+//	awskms:KEY             an AWS KMS key, by id, alias, or ARN
+//	gcpkms:NAME            a Google Cloud KMS key version, by
+//	                       resource name, projects/.../cryptoKeyVersions/N
+//	envelope:PATH          an envelope file
+//
+// Envelope files name the KMS key wrapping their data key by
+// awskms: or gcpkms: URIs, where Google Cloud KMS keys are named
+// by the resource name of the key, not a key version.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+var (
+	// ErrBadURI is returned for key URIs that are malformed
+	// or of an unknown kind.
+	ErrBadURI = errors.New("invalid kms key uri")
+
+	// ErrNotEd25519 is returned when opening a KMS key
+	// that isn't an ed25519 signing key.
+	ErrNotEd25519 = errors.New("kms key is not an ed25519 key")
+
+	// ErrBadSignature is returned from Sign when the KMS
+	// returns a signature that doesn't verify with the
+	// signer's public key.
+	ErrBadSignature = errors.New("kms returned an invalid signature")
+)
+
+// A Signer signs messages with an ed25519 private key.
+type Signer interface {
+	Public() ed25519.PublicKey
+	Sign(ctx context.Context, msg []byte) ([]byte, error)
+}
+
+// A Wrapper encrypts and decrypts small secrets, such
+// as data keys, under a key held by a KMS.
+type Wrapper interface {
+	Wrap(ctx context.Context, plaintext []byte) ([]byte, error)
+	Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// Open returns the Signer named by uri.
+// See the package doc for the forms of uri.
+func Open(ctx context.Context, uri string) (Signer, error) {
+	kind, name, err := splitURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "awskms":
+		return NewAWSSigner(ctx, newAWSClient(name), name)
+	case "gcpkms":
+		return NewGCPSigner(ctx, DefaultGCPClient, name)
+	case "envelope":
+		e, err := ReadEnvelope(name)
+		if err != nil {
+			return nil, err
+		}
+		w, err := OpenWrapper(e.KEK)
+		if err != nil {
+			return nil, errors.Wrap(err, "envelope key")
+		}
+		return e.Open(ctx, w)
+	}
+	return nil, errors.WithDetailf(ErrBadURI, "unknown kind %q", kind)
+}
+
+// OpenWrapper returns the Wrapper named by uri, which
+// is an awskms: or gcpkms: URI naming an encryption key.
+func OpenWrapper(uri string) (Wrapper, error) {
+	kind, name, err := splitURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "awskms":
+		return &AWSWrapper{Client: newAWSClient(name), KeyID: name}, nil
+	case "gcpkms":
+		return &GCPWrapper{Client: DefaultGCPClient, Name: name}, nil
+	}
+	return nil, errors.WithDetailf(ErrBadURI, "%q keys can't wrap data keys", kind)
+}
+
+func splitURI(uri string) (kind, name string, err error) {
+	i := strings.Index(uri, ":")
+	if i < 0 || i == len(uri)-1 {
+		return "", "", errors.WithDetailf(ErrBadURI, "%q is not of the form KIND:NAME", uri)
+	}
+	return uri[:i], uri[i+1:], nil
+}
+
+// remoteSigner is a Signer whose signatures are computed
+// by a KMS. It verifies each signature before returning it,
+// so a misconfigured key is caught at the first signature.
+type remoteSigner struct {
+	name string
+	pub  ed25519.PublicKey
+	sign func(context.Context, []byte) ([]byte, error)
+}
+
+func (s *remoteSigner) Public() ed25519.PublicKey { return s.pub }
+
+func (s *remoteSigner) Sign(ctx context.Context, msg []byte) ([]byte, error) {
+	sig, err := s.sign(ctx, msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "signing with %s", s.name)
+	}
+	if !ed25519.Verify(s.pub, msg, sig) {
+		return nil, errors.WithDetailf(ErrBadSignature, "key %s", s.name)
+	}
+	return sig, nil
+}
+
+func (s *remoteSigner) String() string {
+	return fmt.Sprintf("kms key %s", s.name)
+}
+
+// localSigner is a Signer holding its private key,
+// as unwrapped from an envelope file.
+type localSigner struct {
+	prv ed25519.PrivateKey
+}
+
+func (s *localSigner) Public() ed25519.PublicKey {
+	return s.prv.Public().(ed25519.PublicKey)
+}
+
+func (s *localSigner) Sign(ctx context.Context, msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.prv, msg), nil
+}
+
+// spkiPrefix is the DER encoding of an ed25519
+// SubjectPublicKeyInfo up to the key itself.
+// See RFC 8410.
+var spkiPrefix = []byte{0x30, 0x2a, 0x30, 0x05, 0x06, 0x03, 0x2b, 0x65, 0x70, 0x03, 0x21, 0x00}
+
+// parseSPKI returns the ed25519 public key in der,
+// a DER-encoded SubjectPublicKeyInfo.
+func parseSPKI(der []byte) (ed25519.PublicKey, error) {
+	if len(der) != len(spkiPrefix)+ed25519.PublicKeySize || !bytes.HasPrefix(der, spkiPrefix) {
+		return nil, errors.Wrap(ErrNotEd25519)
+	}
+	return ed25519.PublicKey(der[len(spkiPrefix):]), nil
+}
@@ -2,22 +2,128 @@ package pg
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/lib/pq"
 
+	chainsql "chain/database/sql"
 	"chain/errors"
 	"chain/log"
 	"chain/net"
 )
 
-// NewListener creates a new pq.Listener and begins listening.
-func NewListener(ctx context.Context, dbURL, channel string) (*pq.Listener, error) {
+// PollNotifications makes Notify store payloads in the
+// notifications table, and Listeners poll it, instead of using
+// LISTEN and NOTIFY. Set it at startup, before opening any
+// database.
+//
+// It is not a CockroachDB mode; running the core on CockroachDB
+// is out of scope for now. Sequences and ON CONFLICT would carry
+// over, and the core takes no advisory locks, but the schema
+// (core/schema.sql) would need its PL/pgSQL functions, among them
+// next_chain_id, its tsvector and int8range columns, and its GIN
+// and GiST indexes replaced, and the queries using them changed.
+var PollNotifications bool
+
+// pollInterval is how often a Listener polls for
+// notifications when PollNotifications is set.
+const pollInterval = 250 * time.Millisecond
+
+// A Listener receives the notifications sent
+// with Notify on one channel.
+type Listener struct {
+	// Notify receives the notifications.
+	//
+	// With PollNotifications set, a channel holds only its
+	// latest payload, and the Listener polls for it, so a Listener may miss some of a burst of
+	// notifications. Callers must use payloads for which
+	// only the latest matters, such as heights.
+	Notify <-chan *pq.Notification
+
+	close func() error
+}
+
+// Close stops the listener.
+func (l *Listener) Close() error {
+	return l.close()
+}
+
+// NewListener creates a new Listener and begins listening.
+func NewListener(ctx context.Context, dbURL, channel string) (*Listener, error) {
+	if PollNotifications {
+		return newPollListener(ctx, dbURL, channel)
+	}
+
 	// We want etcd name lookups so we use our own Dialer.
 	d := new(net.Dialer)
 	result := pq.NewDialListener(d, dbURL, 1*time.Second, 10*time.Second, func(ev pq.ListenerEventType, err error) {
 		log.Error(ctx, errors.Wrapf(err, "event in %s listener: %v", channel, ev))
 	})
 	err := result.Listen(channel)
-	return result, errors.Wrap(err, "listening to channel")
+	return &Listener{Notify: result.Notify, close: result.Close}, errors.Wrap(err, "listening to channel")
+}
+
+func newPollListener(ctx context.Context, dbURL, channel string) (*Listener, error) {
+	db, err := chainsql.Open("hapg", dbURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening db")
+	}
+	db.SetMaxOpenConns(1)
+
+	const q = `SELECT payload FROM notifications WHERE channel=$1`
+	var last string
+	err = db.QueryRow(ctx, q, channel).Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
+		db.Close()
+		return nil, errors.Wrap(err, "polling channel")
+	}
+
+	c := make(chan *pq.Notification)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			var payload string
+			err := db.QueryRow(ctx, q, channel).Scan(&payload)
+			if err == sql.ErrNoRows || err == nil && payload == last {
+				continue
+			} else if err != nil {
+				log.Error(ctx, errors.Wrapf(err, "polling %s", channel))
+				continue
+			}
+			last = payload
+			select {
+			case c <- &pq.Notification{Channel: channel, Extra: payload}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	l := &Listener{Notify: c, close: func() error {
+		close(done)
+		return db.Close()
+	}}
+	return l, nil
+}
+
+// Notify sends payload to the listeners on channel.
+func Notify(ctx context.Context, db DB, channel, payload string) error {
+	if !PollNotifications {
+		_, err := db.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+		return errors.Wrap(err, "notifying")
+	}
+	const q = `
+		INSERT INTO notifications (channel, payload) VALUES ($1, $2)
+		ON CONFLICT (channel) DO UPDATE SET payload=excluded.payload
+	`
+	_, err := db.Exec(ctx, q, channel, payload)
+	return errors.Wrap(err, "notifying")
 }
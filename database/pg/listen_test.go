@@ -0,0 +1,38 @@
+package pg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+)
+
+func TestPollListener(t *testing.T) {
+	defer func(poll bool) { pg.PollNotifications = poll }(pg.PollNotifications)
+	pg.PollNotifications = true
+
+	ctx := context.Background()
+	dbURL, db := pgtest.NewDB(t, "testdata/notifications.sql")
+	l, err := pg.NewListener(ctx, dbURL, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, payload := range []string{"1", "2"} {
+		err = pg.Notify(ctx, db, "test", payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case n := <-l.Notify:
+			if n.Extra != payload {
+				t.Errorf("got payload %q, want %q", n.Extra, payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for payload %q", payload)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+
+	"chain/errors"
+)
+
+// Partitions splits an append-only table into partitions, each
+// holding the rows for a fixed-size range of values of a bigint
+// column, such as a block height. Small partitions keep vacuuming
+// and index maintenance cheap as the table grows, and old
+// partitions can be dropped whole.
+//
+// Postgres 9.5 has no declarative partitioning, so each partition
+// is a child table inheriting from the parent table, with the
+// parent's indexes and a CHECK constraint on its range. Queries
+// on the parent read all partitions, and skip those excluded by
+// their conditions on the column. Inserts must name the partition
+// directly; see Table.
+//
+// Partitioning starts at Start, which must be a partition boundary
+// above the column's current maximum; see StartAbove. Rows below
+// Start, including those written before partitioning was enabled,
+// stay in the parent table. Unique indexes hold per table only, so
+// a partition must never cover a value that already has rows in
+// the parent, or inserts relying on ON CONFLICT could duplicate
+// them.
+type Partitions struct {
+	Parent string
+	Column string
+	Size   uint64 // if zero, the table isn't partitioned
+	Start  uint64 // first value stored in a partition; a multiple of Size
+
+	mu      sync.Mutex
+	created map[uint64]bool // partition number -> exists
+}
+
+// name returns the name of partition n.
+func (p *Partitions) name(n uint64) string {
+	return fmt.Sprintf("%s_p%d", p.Parent, n)
+}
+
+// StartAbove sets p.Start to the first partition boundary
+// greater than v, the current maximum of the column.
+func (p *Partitions) StartAbove(v uint64) {
+	if p.Size == 0 {
+		return
+	}
+	p.Start = (v/p.Size + 1) * p.Size
+}
+
+// Table returns the name of the table to insert rows into whose
+// column has value v, creating the partition for v if it doesn't
+// yet exist. If p.Size is zero, or v is below p.Start, it returns
+// p.Parent.
+func (p *Partitions) Table(ctx context.Context, db DB, v uint64) (string, error) {
+	if p.Size == 0 || v < p.Start {
+		return p.Parent, nil
+	}
+	n := v / p.Size
+	name := p.name(n)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.created[n] {
+		return name, nil
+	}
+	q := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING INDEXES,
+			CHECK (%s >= %d AND %s < %d)
+		) INHERITS (%s)
+	`, pq.QuoteIdentifier(name), pq.QuoteIdentifier(p.Parent),
+		pq.QuoteIdentifier(p.Column), n*p.Size, pq.QuoteIdentifier(p.Column), (n+1)*p.Size,
+		pq.QuoteIdentifier(p.Parent))
+	_, err := db.Exec(ctx, q)
+	if err != nil {
+		return "", errors.Wrap(err, "creating partition "+name)
+	}
+	if p.created == nil {
+		p.created = make(map[uint64]bool)
+	}
+	p.created[n] = true
+	return name, nil
+}
+
+// DropBefore drops the partitions that only hold rows whose
+// column is less than v, and returns the number dropped.
+func (p *Partitions) DropBefore(ctx context.Context, db DB, v uint64) (int, error) {
+	if p.Size == 0 {
+		return 0, nil
+	}
+	const q = `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class parent ON parent.oid = i.inhparent
+		WHERE parent.relname = $1
+	`
+	var drop []uint64
+	prefix := p.Parent + "_p"
+	err := ForQueryRows(ctx, db, q, p.Parent, func(name string) {
+		if !strings.HasPrefix(name, prefix) {
+			return
+		}
+		n, err := strconv.ParseUint(strings.TrimPrefix(name, prefix), 10, 64)
+		if err == nil && (n+1)*p.Size <= v {
+			drop = append(drop, n)
+		}
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "listing partitions of "+p.Parent)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, n := range drop {
+		_, err := db.Exec(ctx, "DROP TABLE IF EXISTS "+pq.QuoteIdentifier(p.name(n)))
+		if err != nil {
+			return i, errors.Wrap(err, "dropping partition "+p.name(n))
+		}
+		delete(p.created, n)
+	}
+	return len(drop), nil
+}
@@ -0,0 +1,38 @@
+package pg_test
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg"
+)
+
+func TestPartitionsStartAbove(t *testing.T) {
+	cases := []struct {
+		height, want uint64
+	}{
+		{0, 10},
+		{9, 10},
+		{10, 20},
+		{15, 20},
+	}
+	for _, c := range cases {
+		p := &pg.Partitions{Parent: "blocks", Column: "height", Size: 10}
+		p.StartAbove(c.height)
+		if p.Start != c.want {
+			t.Errorf("StartAbove(%d): Start = %d, want %d", c.height, p.Start, c.want)
+		}
+
+		// Values up to the current height, and beyond
+		// it up to the next boundary, go to the parent.
+		for v := uint64(0); v < c.want; v++ {
+			table, err := p.Table(context.Background(), nil, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if table != p.Parent {
+				t.Errorf("StartAbove(%d): Table(%d) = %q, want %q", c.height, v, table, p.Parent)
+			}
+		}
+	}
+}
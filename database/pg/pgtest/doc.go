@@ -20,5 +20,19 @@ Prefer NewTx when the caller (usually a test function)
 can run in exactly one transaction.
 It's significantly faster than NewDB.
 
+Every database is cloned from a template database initialized
+with the schema, and no two tests share one, so tests may call
+t.Parallel, and sequences start afresh in each test.
+Databases are dropped once they are garbage collected,
+or a few minutes later by another test run. Templates are
+dropped when a test run makes one for a changed schema.
+
+To run tests against CockroachDB, set DB_URL_TEST to the URL
+of a CockroachDB cluster and DB_TEST_COCKROACHDB to any value.
+CockroachDB has no template databases, so there each database
+loads the schema itself, which is slower, and CloneDB fails.
+Only tests whose schema CockroachDB can load pass there;
+the core's schema, SchemaPath, needs Postgres.
+
 */
 package pgtest
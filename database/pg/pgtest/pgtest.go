@@ -2,13 +2,17 @@ package pgtest
 
 import (
 	"context"
+	"crypto/sha256"
 	stdsql "database/sql"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/url"
 	"os"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,14 +24,20 @@ import (
 )
 
 var (
+	randMu sync.Mutex // protects random
 	random = rand.New(rand.NewSource(time.Now().UnixNano()))
 
+	// templates maps schema file names to the names of
+	// template databases initialized with those schemas.
+	templatesMu sync.Mutex
+	templates   = make(map[string]string)
+
 	// dbpool contains initialized, pristine databases,
 	// as returned from open. It is the client's job to
 	// make sure a database is in this state
 	// (for example, by rolling back a transaction)
 	// before returning it to the pool.
-	dbpool = make(chan *sql.DB, 4)
+	dbpool = make(chan finaldb, 4)
 )
 
 // DefaultURL is used by NewTX and NewDB if DBURL is the empty string.
@@ -44,8 +54,19 @@ var (
 	// SchemaPath is a file containing a schema to initialize
 	// a database in NewTx.
 	SchemaPath = os.Getenv("CHAIN") + "/core/schema.sql"
+
+	// CockroachDB reports whether DBURL names a CockroachDB
+	// cluster instead of a Postgres server. It is set from
+	// DB_TEST_COCKROACHDB, and sets pg.PollNotifications.
+	CockroachDB = os.Getenv("DB_TEST_COCKROACHDB") != ""
 )
 
+func init() {
+	if CockroachDB {
+		pg.PollNotifications = true
+	}
+}
+
 const (
 	gcDur      = 3 * time.Minute
 	timeFormat = "20060102150405"
@@ -55,10 +76,14 @@ const (
 // with the schema in schemaPath.
 // It returns the resulting *sql.DB with its URL.
 //
+// Each call creates its own database, cloned from a template
+// database, so tests using NewDB may call t.Parallel.
+//
 // It also registers a finalizer for the DB, so callers
 // can discard it without closing it explicitly, and the
 // test program is nevertheless unlikely to run out of
-// connection slots in the server.
+// connection slots in the server. The finalizer drops
+// the database.
 //
 // Prefer NewTx whenever the caller can do its
 // work in exactly one transaction.
@@ -71,7 +96,10 @@ func NewDB(t testing.TB, schemaPath string) (url string, db *sql.DB) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	runtime.SetFinalizer(db, (*sql.DB).Close)
+	runtime.SetFinalizer(db, func(db *sql.DB) {
+		db.Close()
+		go drop(url)
+	})
 	return url, db
 }
 
@@ -91,7 +119,7 @@ func NewTx(t testing.TB) *sql.Tx {
 	if os.Getenv("CHAIN") == "" {
 		t.Log("warning: $CHAIN not set; probably can't find schema")
 	}
-	db, err := getdb(ctx, DBURL, SchemaPath)
+	url, db, err := getdb(ctx, DBURL, SchemaPath)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -102,13 +130,17 @@ func NewTx(t testing.TB) *sql.Tx {
 	}
 	// NOTE(kr): we do not set a finalizer on the DB.
 	// It is closed explicitly, if necessary, by finalizeTx.
-	runtime.SetFinalizer(tx, finaldb{db}.finalizeTx)
+	runtime.SetFinalizer(tx, finaldb{db, url}.finalizeTx)
 	return tx
 }
 
 // CloneDB creates a new database, using the database at the provided
 // URL as a template. It returns the URL of the database clone.
+// It fails under CockroachDB, which has no template databases.
 func CloneDB(ctx context.Context, baseURL string) (newURL string, err error) {
+	if CockroachDB {
+		return "", errors.New("pgtest: CloneDB is not supported on CockroachDB")
+	}
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", err
@@ -130,7 +162,8 @@ func CloneDB(ctx context.Context, baseURL string) (newURL string, err error) {
 }
 
 // open derives a new randomized test database name from baseURL,
-// initializes it with schemaFile, and opens it.
+// clones it from a template initialized with schemaFile,
+// and opens it.
 func open(ctx context.Context, baseURL, schemaFile string) (newurl string, db *sql.DB, err error) {
 	if baseURL == "" {
 		baseURL = DefaultURL
@@ -153,13 +186,40 @@ func open(ctx context.Context, baseURL, schemaFile string) (newurl string, db *s
 	}
 
 	dbname := pickName("db")
-	u.Path = "/" + dbname
-	_, err = ctldb.Exec("CREATE DATABASE " + pq.QuoteIdentifier(dbname))
+	if CockroachDB {
+		// CockroachDB has no template databases,
+		// so each database loads the schema itself.
+		err = loadSchema(ctx, ctldb, *u, dbname, schemaFile)
+		if err != nil {
+			return "", nil, err
+		}
+		u.Path = "/" + dbname
+		db, err = sql.Open("postgres", u.String())
+		if err != nil {
+			return "", nil, err
+		}
+		return u.String(), db, nil
+	}
+
+	tmpl, err := template(ctx, ctldb, *u, schemaFile)
 	if err != nil {
 		return "", nil, err
 	}
 
-	schema, err := ioutil.ReadFile(schemaFile)
+	u.Path = "/" + dbname
+	err = execExclusive(ctldb, "CREATE DATABASE "+pq.QuoteIdentifier(dbname)+" WITH TEMPLATE "+pq.QuoteIdentifier(tmpl))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "3D000" {
+		// Another test process, with a different schema,
+		// dropped the template as stale. Make it again.
+		templatesMu.Lock()
+		delete(templates, schemaFile)
+		templatesMu.Unlock()
+		tmpl, err = template(ctx, ctldb, *u, schemaFile)
+		if err != nil {
+			return "", nil, err
+		}
+		err = execExclusive(ctldb, "CREATE DATABASE "+pq.QuoteIdentifier(dbname)+" WITH TEMPLATE "+pq.QuoteIdentifier(tmpl))
+	}
 	if err != nil {
 		return "", nil, err
 	}
@@ -167,15 +227,179 @@ func open(ctx context.Context, baseURL, schemaFile string) (newurl string, db *s
 	if err != nil {
 		return "", nil, err
 	}
+	return u.String(), db, nil
+}
+
+// template returns the name of a template database initialized
+// with the schema in schemaFile, creating it if necessary.
+// The name is derived from the schema's contents, so concurrent
+// test processes share templates, and a changed schema gets a
+// new one.
+func template(ctx context.Context, ctldb *stdsql.DB, u url.URL, schemaFile string) (string, error) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if name, ok := templates[schemaFile]; ok {
+		return name, nil
+	}
+
+	schema, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(schema)
+	name := fmt.Sprintf("pgtest_template_%x", h[:8])
+
+	var exists bool
+	const q = `SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname=$1)`
+	err = ctldb.QueryRow(q, name).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		err = createTemplate(ctx, ctldb, u, name, schema)
+		if err != nil {
+			return "", err
+		}
+	}
+	templates[schemaFile] = name
+	return name, nil
+}
+
+// createTemplate loads schema into a database under a temporary
+// name and renames it to name when done, so that concurrent test
+// processes never clone a partially initialized template.
+func createTemplate(ctx context.Context, ctldb *stdsql.DB, u url.URL, name string, schema []byte) error {
+	tmp := pickName("db") // collected by gcdbs if we fail to rename it
+	_, err := ctldb.Exec("CREATE DATABASE " + pq.QuoteIdentifier(tmp))
+	if err != nil {
+		return err
+	}
+	u.Path = "/" + tmp
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		return err
+	}
 	_, err = db.Exec(ctx, string(schema))
+	db.Close()
 	if err != nil {
-		db.Close()
-		return "", nil, err
+		return err
 	}
-	return u.String(), db, nil
+
+	err = execExclusive(ctldb, "ALTER DATABASE "+pq.QuoteIdentifier(tmp)+" RENAME TO "+pq.QuoteIdentifier(name))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P04" {
+		// Another process created the template first.
+		go ctldb.Exec("DROP DATABASE " + pq.QuoteIdentifier(tmp))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dropTemplates(ctldb, name)
+	return nil
 }
 
-type finaldb struct{ db *sql.DB }
+// dropTemplates drops the template databases, other than keep,
+// left by earlier schemas, so they don't pile up as the schema
+// changes. Templates in use by another test process are skipped;
+// if one is dropped anyway, open makes it again.
+func dropTemplates(ctldb *stdsql.DB, keep string) {
+	const q = `
+		SELECT datname FROM pg_database
+		WHERE datname LIKE 'pgtest\_template\_%' AND datname<>$1
+	`
+	rows, err := ctldb.Query(q, keep)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			log.Println(err)
+			rows.Close()
+			return
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, name := range names {
+		_, err = ctldb.Exec("DROP DATABASE IF EXISTS " + pq.QuoteIdentifier(name))
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "55006" {
+			continue // in use
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// loadSchema creates the database name and
+// loads the schema in schemaFile into it.
+func loadSchema(ctx context.Context, ctldb *stdsql.DB, u url.URL, name, schemaFile string) error {
+	schema, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return err
+	}
+	_, err = ctldb.Exec("CREATE DATABASE " + pq.QuoteIdentifier(name))
+	if err != nil {
+		return err
+	}
+	u.Path = "/" + name
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec(ctx, string(schema))
+	return err
+}
+
+// execExclusive executes q, which needs exclusive access to a
+// database, retrying while other sessions are still connected
+// to it. Sessions linger briefly after their client closes them.
+func execExclusive(db *stdsql.DB, q string) error {
+	for i := 0; ; i++ {
+		_, err := db.Exec(q)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "55006" && i < 50 {
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		return err
+	}
+}
+
+// drop drops the database at dbURL.
+func drop(dbURL string) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	baseURL := DBURL
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	ctldb, err := stdsql.Open("postgres", baseURL)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer ctldb.Close()
+	err = execExclusive(ctldb, "DROP DATABASE IF EXISTS "+pq.QuoteIdentifier(u.Path[1:]))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type finaldb struct {
+	db  *sql.DB
+	url string
+}
 
 func (f finaldb) finalizeTx(tx *sql.Tx) {
 	ctx := context.Background()
@@ -185,23 +409,24 @@ func (f finaldb) finalizeTx(tx *sql.Tx) {
 			// If the tx has been committed (or if anything
 			// else goes wrong), we can't reuse db.
 			f.db.Close()
+			drop(f.url)
 			return
 		}
 		select {
-		case dbpool <- f.db:
+		case dbpool <- f:
 		default:
 			f.db.Close() // pool is full
+			drop(f.url)
 		}
 	}()
 }
 
-func getdb(ctx context.Context, url, path string) (*sql.DB, error) {
+func getdb(ctx context.Context, url, path string) (string, *sql.DB, error) {
 	select {
-	case db := <-dbpool:
-		return db, nil
+	case f := <-dbpool:
+		return f.url, f.db, nil
 	default:
-		_, db, err := open(ctx, url, path)
-		return db, err
+		return open(ctx, url, path)
 	}
 }
 
@@ -237,6 +462,8 @@ func gcdbs(db *stdsql.DB) error {
 }
 
 func pickName(prefix string) (s string) {
+	randMu.Lock()
+	defer randMu.Unlock()
 	const chars = "abcdefghijklmnopqrstuvwxyz"
 	for i := 0; i < 10; i++ {
 		s += string(chars[random.Intn(len(chars))])
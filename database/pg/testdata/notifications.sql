@@ -0,0 +1,7 @@
+-- The part of core/schema.sql that Listeners use,
+-- which loads on CockroachDB as well as Postgres.
+
+CREATE TABLE notifications (
+    channel text PRIMARY KEY,
+    payload text NOT NULL
+);
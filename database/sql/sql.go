@@ -40,13 +40,14 @@ const maxArgsLogLen = 20 // bytes
 var logQueries bool
 
 // EnableQueryLogging enables or disables log output for queries.
-// It must be called before Open.
+// It must be called before Open. Queries are also logged while
+// debug logging is enabled for log.SubsystemPG.
 func EnableQueryLogging(e bool) {
 	logQueries = e
 }
 
 func logQuery(ctx context.Context, query string, args interface{}) {
-	if logQueries {
+	if logQueries || log.DebugEnabled(log.SubsystemPG) {
 		s := fmt.Sprint(args)
 		if len(s) > maxArgsLogLen {
 			s = s[:maxArgsLogLen-3] + "..."
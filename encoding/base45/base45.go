@@ -0,0 +1,63 @@
+// Package base45 implements the Base45 encoding of RFC 9285.
+//
+// Base45 uses only the 45 characters of the alphanumeric mode
+// of QR codes, so binary data encoded with it packs into a QR
+// code more densely than with base64 or hex.
+package base45
+
+import (
+	"fmt"
+	"strings"
+)
+
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// EncodeToString returns the Base45 encoding of b.
+func EncodeToString(b []byte) string {
+	out := make([]byte, 0, (len(b)+1)/2*3)
+	for i := 0; i+1 < len(b); i += 2 {
+		n := int(b[i])<<8 | int(b[i+1])
+		out = append(out, alphabet[n%45], alphabet[n/45%45], alphabet[n/(45*45)])
+	}
+	if len(b)%2 == 1 {
+		n := int(b[len(b)-1])
+		out = append(out, alphabet[n%45], alphabet[n/45])
+	}
+	return string(out)
+}
+
+// DecodeString returns the bytes represented
+// by the Base45 string s.
+func DecodeString(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, fmt.Errorf("base45: invalid length %d", len(s))
+	}
+	out := make([]byte, 0, len(s)/3*2+1)
+	for i := 0; i < len(s); i += 3 {
+		var n, mul int = 0, 1
+		end := i + 3
+		if end > len(s) {
+			end = len(s)
+		}
+		for j := i; j < end; j++ {
+			v := strings.IndexByte(alphabet, s[j])
+			if v < 0 {
+				return nil, fmt.Errorf("base45: invalid character %q at %d", s[j], j)
+			}
+			n += v * mul
+			mul *= 45
+		}
+		if end-i == 3 {
+			if n > 0xffff {
+				return nil, fmt.Errorf("base45: invalid triplet at %d", i)
+			}
+			out = append(out, byte(n>>8), byte(n))
+		} else {
+			if n > 0xff {
+				return nil, fmt.Errorf("base45: invalid pair at %d", i)
+			}
+			out = append(out, byte(n))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package base45
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBase45(t *testing.T) {
+	// Examples from RFC 9285.
+	cases := []struct {
+		raw, enc string
+	}{
+		{"AB", "BB8"},
+		{"Hello!!", "%69 VD92EX0"},
+		{"base-45", "UJCLQE7W581"},
+		{"ietf!", "QED8WEX0"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		got := EncodeToString([]byte(c.raw))
+		if got != c.enc {
+			t.Errorf("EncodeToString(%q) = %q, want %q", c.raw, got, c.enc)
+		}
+		dec, err := DecodeString(c.enc)
+		if err != nil {
+			t.Errorf("DecodeString(%q) error %s", c.enc, err)
+			continue
+		}
+		if !bytes.Equal(dec, []byte(c.raw)) {
+			t.Errorf("DecodeString(%q) = %q, want %q", c.enc, dec, c.raw)
+		}
+	}
+
+	for _, bad := range []string{"GGW", "A", "ab1", "ZZ"} {
+		_, err := DecodeString(bad)
+		if err == nil {
+			t.Errorf("DecodeString(%q) got no error", bad)
+		}
+	}
+}
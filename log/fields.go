@@ -0,0 +1,36 @@
+package log
+
+import "context"
+
+type fieldsKey struct{}
+
+// AddFields returns a Context whose log entries, written by
+// Write and the other functions of this package, include
+// keyvals after the fields taken from the request ID.
+// Fields added later follow those added earlier, and a
+// key given again replaces the earlier value, as does a
+// key passed directly to Write.
+//
+// Subsystems add fields, such as KeyBlockHeight and
+// KeyTxHash, as they begin work on a block or transaction,
+// so that every entry about it, in any package it passes
+// through, can be found by the same key.
+func AddFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "")
+	}
+	old := fieldsFromContext(ctx)
+	fields := make([]interface{}, 0, len(old)+len(keyvals))
+	for i := 0; i < len(old); i += 2 {
+		if !hasKey(keyvals, old[i]) {
+			fields = append(fields, old[i], old[i+1])
+		}
+	}
+	fields = append(fields, keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func fieldsFromContext(ctx context.Context) []interface{} {
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"chain/errors"
+)
+
+// A Level is a verbosity of logging, changeable at
+// runtime with SetLevel.
+type Level int32
+
+const (
+	// LevelError writes only entries with an error.
+	LevelError Level = iota
+
+	// LevelInfo writes every entry but debug entries.
+	// It is the default.
+	LevelInfo
+
+	// LevelDebug writes every entry, including debug
+	// entries of every subsystem.
+	LevelDebug
+)
+
+// Subsystems with debug logging. See Debug.
+const (
+	SubsystemValidation = "validation" // transaction and block validation
+	SubsystemRPC        = "rpc"        // calls to other cores
+	SubsystemIndexer    = "indexer"    // query indexing of blocks
+	SubsystemPG         = "pg"         // database queries
+)
+
+// Subsystems lists the subsystems with debug logging.
+var Subsystems = []string{SubsystemValidation, SubsystemRPC, SubsystemIndexer, SubsystemPG}
+
+var (
+	// ErrBadLevel is returned by ParseLevel for unknown levels.
+	ErrBadLevel = errors.New("invalid log level")
+
+	// ErrBadSubsystem is returned by SetDebug for
+	// subsystems not in Subsystems.
+	ErrBadSubsystem = errors.New("invalid log subsystem")
+)
+
+var levelNames = map[Level]string{
+	LevelError: "error",
+	LevelInfo:  "info",
+	LevelDebug: "debug",
+}
+
+var (
+	level = int32(LevelInfo) // accessed atomically
+
+	debugMu sync.RWMutex
+	debug   = make(map[string]bool)
+)
+
+func (l Level) String() string {
+	if s, ok := levelNames[l]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// ParseLevel returns the level named s: error, info, or debug.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return 0, errors.WithDetailf(ErrBadLevel, "%q is not error, info, or debug", s)
+}
+
+// SetLevel sets the verbosity of logging to l.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+// GetLevel returns the verbosity of logging.
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&level))
+}
+
+// SetDebug enables or disables debug entries for each subsystem
+// in subsystems, whatever the level. It changes nothing unless
+// every subsystem is in Subsystems.
+func SetDebug(subsystems map[string]bool) error {
+	for s := range subsystems {
+		if !knownSubsystem(s) {
+			return errors.WithDetailf(ErrBadSubsystem, "%q is not one of %s", s, strings.Join(Subsystems, ", "))
+		}
+	}
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	for s, on := range subsystems {
+		if on {
+			debug[s] = true
+		} else {
+			delete(debug, s)
+		}
+	}
+	return nil
+}
+
+// DebugSubsystems returns, in order, the subsystems
+// with debug entries enabled by SetDebug.
+func DebugSubsystems() []string {
+	debugMu.RLock()
+	defer debugMu.RUnlock()
+	a := []string{}
+	for s := range debug {
+		a = append(a, s)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// DebugEnabled returns whether Debug writes entries for
+// subsystem, as it does at LevelDebug or if enabled by
+// SetDebug. Callers may check it to skip work done only
+// to log.
+func DebugEnabled(subsystem string) bool {
+	if GetLevel() >= LevelDebug {
+		return true
+	}
+	debugMu.RLock()
+	defer debugMu.RUnlock()
+	return debug[subsystem]
+}
+
+// Debug writes a log entry as Write does, tagged with
+// subsystem, if debug entries are enabled for it.
+func Debug(ctx context.Context, subsystem string, keyvals ...interface{}) {
+	if !DebugEnabled(subsystem) {
+		return
+	}
+	write(ctx, append([]interface{}{KeyCaller, caller(1), KeySubsystem, subsystem}, keyvals...))
+}
+
+func knownSubsystem(subsystem string) bool {
+	for _, s := range Subsystems {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+func hasKey(keyvals []interface{}, key interface{}) bool {
+	for i := 0; i < len(keyvals); i += 2 {
+		if keyvals[i] == key {
+			return true
+		}
+	}
+	return false
+}
@@ -44,9 +44,13 @@ const (
 	KeyCoreID   = "coreid"   // core ID from context
 	KeySubReqID = "subreqid" // potential sub-request ID from context
 
-	KeyMessage = "message" // produced by Message
-	KeyError   = "error"   // produced by Error
-	KeyStack   = "stack"   // used by Write to print stack on subsequent lines
+	KeyBlockHeight = "block_height" // height of the block being processed
+	KeyTxHash      = "tx_hash"      // hash of the transaction being processed
+
+	KeyMessage   = "message"   // produced by Message
+	KeyError     = "error"     // produced by Error
+	KeyStack     = "stack"     // used by Write to print stack on subsequent lines
+	KeySubsystem = "subsystem" // produced by Debug
 
 	keyLogError = "log-error" // for errors produced by the log package itself
 )
@@ -86,8 +90,8 @@ func SetPrefix(keyval ...interface{}) {
 // Duplicate keys will be preserved.
 //
 // Several fields are automatically added to the log entry: a timestamp, a
-// string indicating the file and line number of the caller, and a request ID
-// taken from the context.
+// string indicating the file and line number of the caller, a request ID
+// taken from the context, and any fields added to the context with AddFields.
 //
 // As a special case, the auto-generated caller may be overridden by passing in
 // a new value for the KeyCaller key as the first key-value pair. The override
@@ -98,7 +102,19 @@ func SetPrefix(keyval ...interface{}) {
 // in order of preference:
 //   - a KeyStack value with type []byte or []errors.StackFrame
 //   - a KeyError value with type error, using the result of errors.Stack
+//
+// At LevelError, Write drops entries without a KeyError value.
+// See SetLevel.
 func Write(ctx context.Context, keyvals ...interface{}) {
+	if GetLevel() < LevelInfo && !hasKey(keyvals, KeyError) {
+		return
+	}
+	write(ctx, keyvals)
+}
+
+// write writes a log entry, whatever the level.
+// Its caller must be called by the caller to log.
+func write(ctx context.Context, keyvals []interface{}) {
 	// Invariant: len(keyvals) is always even.
 	if len(keyvals)%2 != 0 {
 		keyvals = append(keyvals, "", keyLogError, "odd number of log params")
@@ -110,7 +126,7 @@ func Write(ctx context.Context, keyvals ...interface{}) {
 		vcaller = formatValue(keyvals[1])
 		keyvals = keyvals[2:]
 	} else {
-		vcaller = caller(1)
+		vcaller = caller(2)
 	}
 
 	t := time.Now().UTC()
@@ -130,6 +146,13 @@ func Write(ctx context.Context, keyvals ...interface{}) {
 		out += " " + KeySubReqID + "=" + formatValue(subreqid)
 	}
 
+	fields := fieldsFromContext(ctx)
+	for i := 0; i < len(fields); i += 2 {
+		if !hasKey(keyvals, fields[i]) {
+			out += " " + formatKey(fields[i]) + "=" + formatValue(fields[i+1])
+		}
+	}
+
 	var stack interface{}
 	for i := 0; i < len(keyvals); i += 2 {
 		k := keyvals[i]
@@ -155,8 +178,9 @@ func Write(ctx context.Context, keyvals ...interface{}) {
 }
 
 // Fatal is equivalent to Write() followed by a call to os.Exit(1).
+// It writes its entry at any level.
 func Fatal(ctx context.Context, keyvals ...interface{}) {
-	Write(ctx, keyvals...)
+	write(ctx, keyvals)
 	os.Exit(1)
 }
 
@@ -329,3 +329,61 @@ func TestFormatValue(t *testing.T) {
 		}
 	}
 }
+
+func TestLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+	defer SetLevel(LevelInfo)
+
+	ctx := context.Background()
+	SetLevel(LevelError)
+	Write(ctx, "msg", "dropped")
+	Write(ctx, KeyError, "kept")
+	Debug(ctx, SubsystemRPC, "msg", "dropped")
+	err := SetDebug(map[string]bool{SubsystemRPC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Debug(ctx, SubsystemRPC, "msg", "rpc debug")
+	Debug(ctx, SubsystemPG, "msg", "dropped")
+	err = SetDebug(map[string]bool{SubsystemRPC: false, "nonsense": true})
+	if errors.Root(err) != ErrBadSubsystem {
+		t.Errorf("SetDebug(nonsense) = %v want %v", err, ErrBadSubsystem)
+	}
+	if got := DebugSubsystems(); len(got) != 1 || got[0] != SubsystemRPC {
+		t.Errorf("DebugSubsystems() = %v want [%s]", got, SubsystemRPC)
+	}
+	SetDebug(map[string]bool{SubsystemRPC: false})
+
+	got := buf.String()
+	if strings.Contains(got, "dropped") {
+		t.Errorf("log = %q; should not contain dropped entries", got)
+	}
+	for _, want := range []string{"error=kept", "at=log_test.go:", "subsystem=rpc", `msg="rpc debug"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log = %q; should contain %q", got, want)
+		}
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	ctx := AddFields(context.Background(), KeyBlockHeight, 5, KeyTxHash, "a")
+	ctx = AddFields(ctx, KeyTxHash, "b")
+	Write(ctx, "msg", "hello", KeyBlockHeight, 6)
+
+	got := buf.String()
+	want := " tx_hash=b msg=hello block_height=6\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("log = %q; should contain %q", got, want)
+	}
+	for _, bad := range []string{"tx_hash=a", "block_height=5"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("log = %q; should not contain %q", got, bad)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package cors lets browser pages from other origins
+// call an HTTP API, following the W3C Cross-Origin
+// Resource Sharing recommendation.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxAge is how long, in seconds, browsers
+// may cache the response to a preflight request.
+const maxAge = 600
+
+// Handler serves requests with Handler, adding the headers that
+// let pages from Origins read the responses. An origin of "*"
+// allows every origin. Preflight requests from allowed origins
+// are answered without calling Handler, so that they need no
+// credentials.
+type Handler struct {
+	Handler http.Handler
+	Origins []string
+
+	// ExposedHeaders are response headers
+	// pages may read, besides the simple ones.
+	ExposedHeaders []string
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !h.allowed(origin) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Add("Vary", "Origin")
+	hdr.Set("Access-Control-Allow-Origin", origin)
+	hdr.Set("Access-Control-Allow-Credentials", "true")
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if r.Method != "OPTIONS" || method == "" {
+		if len(h.ExposedHeaders) > 0 {
+			hdr.Set("Access-Control-Expose-Headers", strings.Join(h.ExposedHeaders, ", "))
+		}
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	// A preflight request.
+	hdr.Set("Access-Control-Allow-Methods", method)
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		hdr.Set("Access-Control-Allow-Headers", headers)
+	}
+	hdr.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h Handler) allowed(origin string) bool {
+	for _, o := range h.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
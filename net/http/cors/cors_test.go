@@ -0,0 +1,52 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var called bool
+	h := Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}),
+		Origins:        []string{"https://dash.example.com"},
+		ExposedHeaders: []string{"Chain-Request-Id"},
+	}
+
+	cases := []struct {
+		method, origin, reqMethod string
+		wantCalled                bool
+		wantAllow                 string
+		wantStatus                int
+	}{
+		{"POST", "", "", true, "", 200},
+		{"POST", "https://evil.example.com", "", true, "", 200},
+		{"POST", "https://dash.example.com", "", true, "https://dash.example.com", 200},
+		{"OPTIONS", "https://dash.example.com", "POST", false, "https://dash.example.com", 204},
+		{"OPTIONS", "https://evil.example.com", "POST", true, "", 200},
+	}
+	for _, c := range cases {
+		called = false
+		req, _ := http.NewRequest(c.method, "/info", nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		if c.reqMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", c.reqMethod)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if called != c.wantCalled {
+			t.Errorf("%s from %q: called = %v want %v", c.method, c.origin, called, c.wantCalled)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != c.wantAllow {
+			t.Errorf("%s from %q: allowed origin = %q want %q", c.method, c.origin, got, c.wantAllow)
+		}
+		if w.Code != c.wantStatus {
+			t.Errorf("%s from %q: status = %d want %d", c.method, c.origin, w.Code, c.wantStatus)
+		}
+	}
+}
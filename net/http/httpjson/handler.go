@@ -1,11 +1,16 @@
 package httpjson
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+
+	chainerrors "chain/errors"
 )
 
 // ErrorWriter is responsible for writing the provided error value
@@ -24,6 +29,7 @@ type handler struct {
 	inType  reflect.Type
 	hasCtx  bool
 	errFunc ErrorWriter
+	strict  bool
 }
 
 // Handler returns an HTTP handler for function f.
@@ -36,7 +42,20 @@ func Handler(f interface{}, errFunc ErrorWriter) (http.Handler, error) {
 		return nil, err
 	}
 
-	h := &handler{fv, inType, hasCtx, errFunc}
+	h := &handler{fv: fv, inType: inType, hasCtx: hasCtx, errFunc: errFunc}
+	return h, nil
+}
+
+// StrictHandler is like Handler, but rejects request bodies
+// with fields that f's request type doesn't have, or values of
+// the wrong JSON type, with ErrBadRequest. Handler would
+// silently ignore an unknown field.
+func StrictHandler(f interface{}, errFunc ErrorWriter) (http.Handler, error) {
+	h, err := Handler(f, errFunc)
+	if err != nil {
+		return nil, err
+	}
+	h.(*handler).strict = true
 	return h, nil
 }
 
@@ -49,8 +68,28 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		a = append(a, reflect.ValueOf(ctx))
 	}
 	if h.inType != nil {
+		var body io.Reader = req.Body
+		if h.strict {
+			b, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				h.errFunc(req.Context(), w, chainerrors.WithDetail(ErrBadRequest, err.Error()))
+				return
+			}
+			// If b isn't valid JSON, Read reports it below.
+			var v interface{}
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.UseNumber()
+			if dec.Decode(&v) == nil {
+				err = validate(h.inType, v, "body")
+				if err != nil {
+					h.errFunc(req.Context(), w, err)
+					return
+				}
+			}
+			body = bytes.NewReader(b)
+		}
 		inPtr := reflect.New(h.inType)
-		err := Read(req.Context(), req.Body, inPtr.Interface())
+		err := Read(req.Context(), body, inPtr.Interface())
 		if err != nil {
 			h.errFunc(req.Context(), w, err)
 			return
@@ -0,0 +1,85 @@
+package httpjson
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A Route is a function served by Handler at Path.
+type Route struct {
+	Path string
+	Func interface{}
+}
+
+// OpenAPI returns an OpenAPI 3 document describing the
+// routes, each as a POST operation. The request and response
+// bodies are described with Schema, from the types in each
+// function's signature. A function returning interface{}
+// has its response described by an empty schema.
+// ErrorSchema, if non-nil, describes error responses.
+func OpenAPI(title, version string, routes []Route, errorSchema map[string]interface{}) map[string]interface{} {
+	paths := make(map[string]interface{})
+	for _, r := range routes {
+		fv := reflect.ValueOf(r.Func)
+		_, inType, err := funcInputType(fv)
+		if err != nil {
+			panic(err)
+		}
+
+		op := map[string]interface{}{
+			"operationId": operationID(r.Path),
+		}
+		if inType != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(Schema(inType)),
+			}
+		}
+
+		respSchema := map[string]interface{}{"type": "object"} // DefaultResponse
+		ft := fv.Type()
+		if ft.NumOut() > 0 && !ft.Out(0).Implements(errorType) {
+			respSchema = Schema(ft.Out(0))
+		}
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "OK",
+				"content":     jsonContent(respSchema),
+			},
+		}
+		if errorSchema != nil {
+			responses["default"] = map[string]interface{}{
+				"description": "Error",
+				"content":     jsonContent(errorSchema),
+			}
+		}
+		op["responses"] = responses
+		paths[r.Path] = map[string]interface{}{"post": op}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// operationID turns a path such as /mockhsm/create-key
+// into an operation ID such as mockhsmCreateKey.
+func operationID(path string) string {
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-'
+	})
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.Title(words[i])
+	}
+	return strings.Join(words, "")
+}
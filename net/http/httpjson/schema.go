@@ -0,0 +1,234 @@
+package httpjson
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"chain/errors"
+)
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Schema returns an OpenAPI 3 schema object describing the
+// JSON encoding of values of type t. It follows the rules of
+// package encoding/json for struct fields. A type with its own
+// MarshalJSON method, other than time.Time, may be encoded as
+// anything, and is described by an empty schema, unless it's
+// also a TextMarshaler, which is described as a string.
+func Schema(t reflect.Type) map[string]interface{} {
+	return schema(t, make(map[reflect.Type]bool))
+}
+
+func schema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if implements(t, jsonMarshalerType) {
+		if implements(t, textMarshalerType) {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{}
+	}
+	if implements(t, textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Ptr:
+		return schema(t.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schema(t.Elem(), seen)}
+	case reflect.Struct:
+		// Describe a recursive type only to the
+		// first level of recursion.
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		props := make(map[string]interface{})
+		for _, f := range jsonFields(t) {
+			props[f.name] = schema(f.typ, seen)
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	return map[string]interface{}{}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(iface))
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFields returns the fields of struct type t
+// that package encoding/json encodes and decodes.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			et := ft
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(et)...)
+				continue
+			}
+		}
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, jsonField{name, ft})
+	}
+	return fields
+}
+
+// validate checks that v, decoded from JSON with UseNumber,
+// could be decoded into a value of type t without any of it
+// being ignored or mistyped. Path names v in errors.
+func validate(t reflect.Type, v interface{}, path string) error {
+	if v == nil {
+		return nil // null is allowed anywhere
+	}
+	if implements(t, jsonUnmarshalerType) {
+		return nil // t decodes itself
+	}
+	if t != timeType && implements(t, textUnmarshalerType) {
+		return want(v, "string", path)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return want(v, "boolean", path)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return want(v, "number", path)
+	case reflect.String:
+		return want(v, "string", path)
+	case reflect.Ptr:
+		return validate(t.Elem(), v, path)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return want(v, "string", path)
+		}
+		a, ok := v.([]interface{})
+		if !ok {
+			return want(v, "array", path)
+		}
+		for i, elem := range a {
+			err := validate(t.Elem(), elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return want(v, "object", path)
+		}
+		for k, elem := range m {
+			err := validate(t.Elem(), elem, path+"."+k)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		if t == timeType {
+			return want(v, "string", path)
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return want(v, "object", path)
+		}
+		fields := jsonFields(t)
+		for k, elem := range m {
+			// Like encoding/json, match names
+			// without regard to case.
+			var f *jsonField
+			for i := range fields {
+				if strings.EqualFold(fields[i].name, k) {
+					f = &fields[i]
+					break
+				}
+			}
+			if f == nil {
+				return errors.WithDetailf(ErrBadRequest, "unknown field %s.%s", path, k)
+			}
+			err := validate(f.typ, elem, path+"."+k)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func want(v interface{}, typ, path string) error {
+	var got string
+	switch v.(type) {
+	case bool:
+		got = "boolean"
+	case json.Number:
+		got = "number"
+	case string:
+		got = "string"
+	case []interface{}:
+		got = "array"
+	case map[string]interface{}:
+		got = "object"
+	}
+	if got == typ {
+		return nil
+	}
+	return errors.WithDetailf(ErrBadRequest, "%s must be %s %s, not %s", path, article(typ), typ, got)
+}
+
+func article(s string) string {
+	if strings.IndexByte("aeiou", s[0]) >= 0 {
+		return "an"
+	}
+	return "a"
+}
@@ -0,0 +1,123 @@
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"chain/errors"
+)
+
+type testHex [2]byte
+
+func (h testHex) MarshalText() ([]byte, error)  { return []byte("abcd"), nil }
+func (h *testHex) UnmarshalText(b []byte) error { return nil }
+
+type testEmbedded struct {
+	Tags map[string]interface{} `json:"tags"`
+}
+
+type testRequest struct {
+	testEmbedded
+	Alias    string    `json:"alias"`
+	Quorum   int       `json:"quorum,omitempty"`
+	Keys     []testHex `json:"keys"`
+	Data     []byte    `json:"data"`
+	At       time.Time `json:"at"`
+	Ignored  string    `json:"-"`
+	Verbose  bool
+	internal int
+}
+
+func TestSchema(t *testing.T) {
+	got := Schema(reflect.TypeOf(&testRequest{}))
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"tags":    map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{}},
+			"alias":   map[string]interface{}{"type": "string"},
+			"quorum":  map[string]interface{}{"type": "integer"},
+			"keys":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"data":    map[string]interface{}{"type": "string", "format": "byte"},
+			"at":      map[string]interface{}{"type": "string", "format": "date-time"},
+			"Verbose": map[string]interface{}{"type": "boolean"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Schema(testRequest) = %v\nwant %v", got, want)
+	}
+}
+
+func TestStrictHandler(t *testing.T) {
+	f := func(req testRequest) string { return req.Alias }
+	cases := []struct {
+		body       string
+		wantDetail string
+	}{
+		{`{"alias":"a","quorum":1,"keys":["abcd"],"tags":{"x":[1]},"verbose":true}`, ""},
+		{`{"ALIAS":"a"}`, ""},
+		{`{"alias":null}`, ""},
+		{`{"alais":"a"}`, "unknown field body.alais"},
+		{`{"alias":1}`, "body.alias must be a string, not number"},
+		{`{"keys":["abcd",2]}`, "body.keys[1] must be a string, not number"},
+		{`{"tags":[]}`, "body.tags must be an object, not array"},
+		{`[]`, "body must be an object, not array"},
+	}
+	for _, c := range cases {
+		var gotErr error
+		h, err := StrictHandler(f, func(ctx context.Context, w http.ResponseWriter, err error) {
+			gotErr = err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(c.body))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if c.wantDetail == "" {
+			if gotErr != nil {
+				t.Errorf("%s: got error %v", c.body, gotErr)
+			}
+			continue
+		}
+		if errors.Root(gotErr) != ErrBadRequest || errors.Detail(gotErr) != c.wantDetail {
+			t.Errorf("%s: got error %v, want ErrBadRequest with detail %q", c.body, gotErr, c.wantDetail)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	routes := []Route{
+		{"/create-thing", func(ctx context.Context, req testRequest) (string, error) { return "", nil }},
+		{"/list-things", func() ([]int, error) { return nil, nil }},
+		{"/reset", func(ctx context.Context) error { return nil }},
+	}
+	doc := OpenAPI("Test API", "1.0", routes, nil)
+	paths := doc["paths"].(map[string]interface{})
+	if len(paths) != 3 {
+		t.Fatalf("got %d paths, want 3", len(paths))
+	}
+
+	op := paths["/create-thing"].(map[string]interface{})["post"].(map[string]interface{})
+	if op["operationId"] != "createThing" {
+		t.Errorf("got operation ID %v, want createThing", op["operationId"])
+	}
+	reqSchema := op["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"]
+	if !reflect.DeepEqual(reqSchema, Schema(reflect.TypeOf(testRequest{}))) {
+		t.Errorf("got request schema %v", reqSchema)
+	}
+
+	op = paths["/list-things"].(map[string]interface{})["post"].(map[string]interface{})
+	if _, ok := op["requestBody"]; ok {
+		t.Error("got a request body for a handler without a request parameter")
+	}
+	respSchema := op["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"]
+	want := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}}
+	if !reflect.DeepEqual(respSchema, want) {
+		t.Errorf("got response schema %v, want %v", respSchema, want)
+	}
+}
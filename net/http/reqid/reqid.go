@@ -94,11 +94,22 @@ func FromSubContext(ctx context.Context) string {
 	return subReqID
 }
 
+// maxIDLen is the length of the longest
+// request ID accepted from a client.
+const maxIDLen = 64
+
+// Handler gives each request an ID, stored in its context and
+// returned in the Chain-Request-Id response header. A request
+// that already has an ID, given by another Chain Core in the
+// Request-ID header or by a proxy in the X-Request-Id header,
+// keeps it, so the request can be followed across processes.
 func Handler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		// TODO(kr): take half of request ID from the client
-		id := New()
+		id := fromHeader(req.Header)
+		if id == "" {
+			id = New()
+		}
 		ctx = NewContext(ctx, id)
 		ctx = context.WithValue(ctx, coreIDKey, req.Header.Get("Chain-Core-ID"))
 		ctx = context.WithValue(ctx, pathKey, req.URL.Path)
@@ -117,3 +128,23 @@ func Handler(handler http.Handler) http.Handler {
 		handler.ServeHTTP(w, req.WithContext(ctx))
 	})
 }
+
+// fromHeader returns the request ID in h, or the empty
+// string if it has none, or none that is safe to log.
+func fromHeader(h http.Header) string {
+	id := h.Get("Request-ID")
+	if id == "" {
+		id = h.Get("X-Request-Id")
+	}
+	if len(id) > maxIDLen || id == Unknown {
+		return ""
+	}
+	for _, c := range id {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == ':'
+		if !ok {
+			return ""
+		}
+	}
+	return id
+}
@@ -0,0 +1,94 @@
+// Package ntp asks NTP servers for the time, to check the local
+// clock. It implements only the client side of SNTP, RFC 4330.
+package ntp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"chain/errors"
+)
+
+// DefaultServer is a pool of public NTP servers.
+const DefaultServer = "pool.ntp.org"
+
+const (
+	// ntpEpoch is the number of seconds between
+	// the NTP epoch, 1900, and the Unix epoch.
+	ntpEpoch = 2208988800
+
+	packetSize = 48
+	timeout    = 5 * time.Second
+
+	versionClient = 4<<3 | 3 // version 4, mode client
+	modeServer    = 4
+)
+
+// ErrBadResponse is returned when an NTP server's
+// response is malformed or refuses to give the time.
+var ErrBadResponse = errors.New("bad NTP response")
+
+// Offset asks the NTP server at addr, a host with an optional
+// port, for the time, and returns how far the local clock is
+// behind it. A negative offset means the local clock is ahead.
+func Offset(ctx context.Context, addr string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, errors.Wrap(err, "dialing NTP server")
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetDeadline(deadline)
+
+	req := make([]byte, packetSize)
+	req[0] = versionClient
+	t1 := time.Now()
+	putTime(req[40:], t1)
+	_, err = conn.Write(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "querying NTP server")
+	}
+
+	resp := make([]byte, packetSize)
+	n, err := conn.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, errors.Wrap(err, "reading NTP response")
+	}
+	switch {
+	case n < packetSize:
+		return 0, errors.WithDetailf(ErrBadResponse, "%d-byte response", n)
+	case resp[0]&7 != modeServer:
+		return 0, errors.WithDetailf(ErrBadResponse, "mode %d", resp[0]&7)
+	case resp[1] == 0:
+		return 0, errors.WithDetailf(ErrBadResponse, "kiss code %q", resp[12:16])
+	case !bytes.Equal(resp[24:32], req[40:48]):
+		return 0, errors.WithDetail(ErrBadResponse, "response is not to this request")
+	}
+
+	// See RFC 4330, section 5.
+	t2 := getTime(resp[32:])
+	t3 := getTime(resp[40:])
+	return (t2.Sub(t1) + t3.Sub(t4)) / 2, nil
+}
+
+func putTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()+ntpEpoch))
+	binary.BigEndian.PutUint32(b[4:], uint32(uint64(t.Nanosecond())<<32/1e9))
+}
+
+func getTime(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b)) - ntpEpoch
+	nsec := int64(uint64(binary.BigEndian.Uint32(b[4:])) * 1e9 >> 32)
+	return time.Unix(sec, nsec)
+}
@@ -0,0 +1,51 @@
+package ntp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOffset(t *testing.T) {
+	const skew = time.Hour
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, packetSize)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp := make([]byte, packetSize)
+		resp[0] = 4<<3 | modeServer
+		resp[1] = 2 // stratum
+		copy(resp[24:32], buf[40:48])
+		now := time.Now().Add(skew)
+		putTime(resp[32:], now)
+		putTime(resp[40:], now)
+		conn.WriteTo(resp, addr)
+	}()
+
+	got, err := Offset(context.Background(), conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got - skew; d < -time.Second || d > time.Second {
+		t.Errorf("Offset = %s want about %s", got, skew)
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	want := time.Date(2016, 12, 19, 10, 30, 0, 500000000, time.UTC)
+	b := make([]byte, 8)
+	putTime(b, want)
+	got := getTime(b)
+	if d := got.Sub(want); d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("getTime(putTime(%s)) = %s", want, got.UTC())
+	}
+}
@@ -0,0 +1,66 @@
+package bc
+
+import (
+	"math"
+
+	"chain/errors"
+	"chain/math/checked"
+)
+
+// MaxAmount is the largest amount of an asset in an input or
+// output, and the largest sum of the amounts of an asset in a
+// transaction's inputs or in its outputs, so that any of them
+// can be represented as an int64.
+const MaxAmount = math.MaxInt64
+
+// ErrAmountOverflow is returned by AddAmounts, SumInputs, and
+// SumOutputs for a sum greater than MaxAmount.
+var ErrAmountOverflow = errors.New("amount overflow")
+
+// AddAmounts returns the sum of amounts, or ErrAmountOverflow if
+// any amount, or the sum, is greater than MaxAmount. Unlike plain
+// uint64 addition, it never wraps around.
+func AddAmounts(amounts ...uint64) (uint64, error) {
+	var sum uint64
+	for _, a := range amounts {
+		var ok bool
+		sum, ok = checked.AddUint64(sum, a)
+		if !ok || sum > MaxAmount {
+			return 0, errors.Wrap(ErrAmountOverflow)
+		}
+	}
+	return sum, nil
+}
+
+// SumInputs returns the sum of the amounts of each asset in ins.
+// Amounts hidden in commitments are zero and count for nothing.
+// It returns ErrAmountOverflow if any sum is greater than
+// MaxAmount.
+func SumInputs(ins []*TxInput) (map[AssetID]uint64, error) {
+	sums := make(map[AssetID]uint64)
+	for i, in := range ins {
+		assetID := in.AssetID() // calculated for issuances, so grab once
+		sum, err := AddAmounts(sums[assetID], in.Amount())
+		if err != nil {
+			return nil, errors.WithDetailf(err, "adding input %d of asset %s", i, assetID)
+		}
+		sums[assetID] = sum
+	}
+	return sums, nil
+}
+
+// SumOutputs returns the sum of the amounts of each asset in outs.
+// Amounts hidden in commitments are zero and count for nothing.
+// It returns ErrAmountOverflow if any sum is greater than
+// MaxAmount.
+func SumOutputs(outs []*TxOutput) (map[AssetID]uint64, error) {
+	sums := make(map[AssetID]uint64)
+	for i, out := range outs {
+		sum, err := AddAmounts(sums[out.AssetID], out.Amount)
+		if err != nil {
+			return nil, errors.WithDetailf(err, "adding output %d of asset %s", i, out.AssetID)
+		}
+		sums[out.AssetID] = sum
+	}
+	return sums, nil
+}
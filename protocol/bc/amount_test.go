@@ -0,0 +1,80 @@
+package bc
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestAddAmounts(t *testing.T) {
+	cases := []struct {
+		amounts []uint64
+		want    uint64
+		wantErr error
+	}{
+		{nil, 0, nil},
+		{[]uint64{1, 2, 3}, 6, nil},
+		{[]uint64{MaxAmount - 1, 1}, MaxAmount, nil},
+		{[]uint64{MaxAmount, 1}, 0, ErrAmountOverflow},
+		{[]uint64{MaxAmount + 1}, 0, ErrAmountOverflow},
+		{[]uint64{math.MaxUint64, 2}, 0, ErrAmountOverflow},       // would wrap to 1
+		{[]uint64{MaxAmount, MaxAmount, 2}, 0, ErrAmountOverflow}, // would wrap to 0
+	}
+	for _, c := range cases {
+		got, err := AddAmounts(c.amounts...)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("AddAmounts(%v) error = %v want %v", c.amounts, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("AddAmounts(%v) = %d want %d", c.amounts, got, c.want)
+		}
+	}
+}
+
+func TestSumOutputs(t *testing.T) {
+	a1, a2 := AssetID{1}, AssetID{2}
+	outs := []*TxOutput{
+		NewTxOutput(a1, 5, nil, nil),
+		NewTxOutput(a2, MaxAmount, nil, nil),
+		NewTxOutput(a1, 7, nil, nil),
+		NewConfidentialOutput(a1, [32]byte{1}, nil, nil, nil),
+	}
+	got, err := SumOutputs(outs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[AssetID]uint64{a1: 12, a2: MaxAmount}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SumOutputs = %v want %v", got, want)
+	}
+
+	outs = append(outs, NewTxOutput(a2, 1, nil, nil))
+	_, err = SumOutputs(outs)
+	if errors.Root(err) != ErrAmountOverflow {
+		t.Errorf("SumOutputs error = %v want %v", err, ErrAmountOverflow)
+	}
+}
+
+func TestSumInputs(t *testing.T) {
+	a1 := AssetID{1}
+	ins := []*TxInput{
+		NewSpendInput(Hash{1}, 0, nil, a1, MaxAmount, nil, nil),
+		NewSpendInput(Hash{2}, 0, nil, a1, MaxAmount, nil, nil),
+		NewSpendInput(Hash{3}, 0, nil, a1, 2, nil, nil),
+	}
+	_, err := SumInputs(ins)
+	if errors.Root(err) != ErrAmountOverflow {
+		t.Errorf("SumInputs error = %v want %v", err, ErrAmountOverflow)
+	}
+
+	got, err := SumInputs(ins[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[AssetID]uint64{a1: 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SumInputs = %v want %v", got, want)
+	}
+}
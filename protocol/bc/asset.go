@@ -1,7 +1,9 @@
 package bc
 
 import (
+	"bytes"
 	"database/sql/driver"
+	"io"
 
 	"chain/crypto/sha3pool"
 	"chain/encoding/blockchain"
@@ -25,10 +27,22 @@ func (a *AssetID) Scan(b interface{}) error     { return (*Hash)(a).Scan(b) }
 func ComputeAssetID(issuanceProgram []byte, initialHash [32]byte, vmVersion uint64) (assetID AssetID) {
 	h := sha3pool.Get256()
 	defer sha3pool.Put256(h)
-	h.Write(initialHash[:])
-	blockchain.WriteVarint63(h, assetVersion)
-	blockchain.WriteVarint63(h, vmVersion)
-	blockchain.WriteVarstr31(h, issuanceProgram) // TODO(bobg): check and return error
+	writeAssetDefinition(h, issuanceProgram, initialHash, vmVersion)
 	h.Read(assetID[:])
 	return assetID
 }
+
+// AssetIDPreimage returns the serialization whose
+// SHA3-256 hash ComputeAssetID returns.
+func AssetIDPreimage(issuanceProgram []byte, initialHash [32]byte, vmVersion uint64) []byte {
+	var buf bytes.Buffer
+	writeAssetDefinition(&buf, issuanceProgram, initialHash, vmVersion)
+	return buf.Bytes()
+}
+
+func writeAssetDefinition(w io.Writer, issuanceProgram []byte, initialHash [32]byte, vmVersion uint64) {
+	w.Write(initialHash[:])
+	blockchain.WriteVarint63(w, assetVersion)
+	blockchain.WriteVarint63(w, vmVersion)
+	blockchain.WriteVarstr31(w, issuanceProgram) // TODO(bobg): check and return error
+}
@@ -1,6 +1,7 @@
 package bc
 
 import (
+	"bytes"
 	"testing"
 
 	"golang.org/x/crypto/sha3"
@@ -21,6 +22,11 @@ func TestComputeAssetID(t *testing.T) {
 	if assetID != want {
 		t.Errorf("asset id = %x want %x", assetID[:], want[:])
 	}
+
+	got := AssetIDPreimage(issuanceScript, initialBlockHash, 1)
+	if !bytes.Equal(got, unhashed) {
+		t.Errorf("preimage = %x want %x", got, unhashed)
+	}
 }
 
 var assetIDSink AssetID
@@ -0,0 +1,326 @@
+// Package bctest provides test vectors for the serialization
+// and hashing of transactions and blocks, and for the
+// computation of asset IDs and transaction Merkle roots.
+//
+// The vectors are kept in protocol/bc/testdata/vectors.json and
+// checked by the tests of package bc. Implementations in other
+// languages can check themselves against the same file.
+// Command bcvectors regenerates it; so does go generate.
+package bctest
+
+//go:generate go run ../../../cmd/bcvectors/main.go -o ../testdata/vectors.json
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+)
+
+const (
+	TypeTx      = "tx"
+	TypeBlock   = "block"
+	TypeAssetID = "asset_id"
+	TypeMerkle  = "merkle_root"
+)
+
+// Vector is a serialized transaction or block along with the
+// hashes computed from it, the inputs to an asset ID along with
+// the asset ID, or a list of serialized transactions along with
+// their Merkle root.
+type Vector struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Hex  string `json:"hex,omitempty"`
+
+	// Invalid vectors must fail to deserialize.
+	// They have no hashes.
+	Invalid bool `json:"invalid,omitempty"`
+
+	// Hash is the transaction hash, or the block header hash.
+	Hash string `json:"hash,omitempty"`
+
+	// WitnessHash is the transaction witness hash.
+	WitnessHash string `json:"witness_hash,omitempty"`
+
+	// SigHashes are the transaction's signature hashes,
+	// one for each input.
+	SigHashes []string `json:"sig_hashes,omitempty"`
+
+	// SigHash is the block header hash for signing.
+	SigHash string `json:"sig_hash,omitempty"`
+
+	// TransactionIDs are the hashes of the block's transactions.
+	TransactionIDs []string `json:"transaction_ids,omitempty"`
+
+	// Transactions are the serialized transactions
+	// whose Merkle root is MerkleRoot.
+	Transactions []string `json:"transactions,omitempty"`
+
+	// MerkleRoot is the Merkle root of Transactions,
+	// or of the block's transactions.
+	MerkleRoot string `json:"merkle_root,omitempty"`
+
+	// IssuanceProgram, InitialBlock, and VMVersion are the
+	// inputs from which AssetID is computed.
+	IssuanceProgram string `json:"issuance_program,omitempty"`
+	InitialBlock    string `json:"initial_block,omitempty"`
+	VMVersion       uint64 `json:"vm_version,omitempty"`
+	AssetID         string `json:"asset_id,omitempty"`
+}
+
+// Vectors returns the test vectors, computed from the
+// transactions and blocks they describe.
+func Vectors() []*Vector {
+	var (
+		initialBlock = bc.Hash{0x03, 0xde, 0xff, 0x1d}
+		issuanceProg = []byte{0x51} // TRUE
+		assetID      = bc.ComputeAssetID(issuanceProg, initialBlock, 1)
+		prevout      = bc.Hash{0xdd, 0x38, 0x5f, 0x6f}
+
+		issuance = bc.NewIssuanceInput([]byte{10, 9, 8}, 1000000000000, []byte("input"), initialBlock, issuanceProg, [][]byte{{1, 2, 3}})
+		spend    = bc.NewSpendInput(prevout, 1, [][]byte{{4, 5}, nil, bytes.Repeat([]byte{6}, 200)}, assetID, 1000000000000, []byte{1}, []byte("spend"))
+		output   = bc.NewTxOutput(assetID, 600000000000, []byte{1}, []byte("output"))
+		change   = bc.NewTxOutput(assetID, 400000000000, []byte{2}, nil)
+	)
+
+	issuanceTx := &bc.TxData{
+		Version:       1,
+		Inputs:        []*bc.TxInput{issuance},
+		Outputs:       []*bc.TxOutput{bc.NewTxOutput(assetID, 1000000000000, []byte{1}, nil)},
+		ReferenceData: []byte("issuance"),
+	}
+	spendTx := &bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{spend},
+		Outputs: []*bc.TxOutput{output, change},
+		MinTime: 1492590000000,
+		MaxTime: 1492590591000,
+	}
+	mixedTx := &bc.TxData{
+		Version:       1,
+		Inputs:        []*bc.TxInput{issuance, spend},
+		Outputs:       []*bc.TxOutput{output, change, bc.NewTxOutput(assetID, 1<<63-1, nil, nil)},
+		MinTime:       1,
+		MaxTime:       1<<63 - 1,
+		ReferenceData: bytes.Repeat([]byte("reference data "), 20),
+	}
+
+	vectors := []*Vector{
+		txVector("empty transaction", &bc.TxData{Version: 1}),
+		txVector("issuance", issuanceTx),
+		txVector("spend with time range", spendTx),
+		txVector("issuance and spend with long reference data", mixedTx),
+		blockVector("initial block", &bc.Block{
+			BlockHeader: bc.BlockHeader{
+				Version:          1,
+				Height:           1,
+				TimestampMS:      1492590000000,
+				ConsensusProgram: []byte{0xae, 0x51, 0x51, 0xad}, // BLOCKSIGHASH 1 1 CHECKMULTISIG
+			},
+		}),
+		blockVector("block with transactions", &bc.Block{
+			BlockHeader: bc.BlockHeader{
+				Version:                1,
+				Height:                 2,
+				PreviousBlockHash:      bc.Hash{0xbe, 0xef},
+				TimestampMS:            1492590001000,
+				TransactionsMerkleRoot: bc.Hash{0x01},
+				AssetsMerkleRoot:       bc.Hash{0x02},
+				ConsensusProgram:       []byte{0xae, 0x51, 0x51, 0xad},
+				Witness:                [][]byte{bytes.Repeat([]byte{0x5a}, 64)},
+			},
+			Transactions: []*bc.Tx{bc.NewTx(*issuanceTx), bc.NewTx(*spendTx)},
+		}),
+	}
+
+	empty := vectors[0].Hex
+	issuanceHex := vectors[1].Hex
+	block := vectors[4].Hex
+	spendHex := vectors[2].Hex
+	mixedHex := vectors[3].Hex
+	vectors = append(vectors,
+		assetIDVector("issuance program TRUE", issuanceProg, initialBlock, 1),
+		assetIDVector("empty issuance program", nil, initialBlock, 1),
+		assetIDVector("other vm version", issuanceProg, initialBlock, 2),
+		assetIDVector("long issuance program", bytes.Repeat([]byte{0x51}, 300), bc.Hash{0xff}, 1),
+		merkleVector("merkle root of no transactions"),
+		merkleVector("merkle root of one transaction", issuanceHex),
+		merkleVector("merkle root of two transactions", issuanceHex, spendHex),
+		merkleVector("merkle root of three transactions", issuanceHex, spendHex, mixedHex),
+		merkleVector("merkle root of five transactions", empty, issuanceHex, spendHex, mixedHex, empty),
+		&Vector{Name: "unsupported transaction serialization flags", Type: TypeTx, Invalid: true, Hex: "05" + empty[2:]},
+		&Vector{Name: "extra common fields in version 1 transaction", Type: TypeTx, Invalid: true, Hex: "070103000000" + empty[10:]},
+		&Vector{Name: "truncated transaction", Type: TypeTx, Invalid: true, Hex: issuanceHex[:len(issuanceHex)-2]},
+		&Vector{Name: "issuance asset ID does not match issuance program", Type: TypeTx, Invalid: true, Hex: strings.Replace(issuanceHex, assetID.String(), bc.AssetID{}.String(), 1)},
+		&Vector{Name: "unsupported block serialization flags", Type: TypeBlock, Invalid: true, Hex: "04" + block[2:]},
+		&Vector{Name: "truncated block", Type: TypeBlock, Invalid: true, Hex: block[:len(block)-2]},
+	)
+	return vectors
+}
+
+func txVector(name string, data *bc.TxData) *Vector {
+	tx := bc.NewTx(*data)
+	b, _ := tx.MarshalText() // error is impossible
+	v := &Vector{
+		Name:        name,
+		Type:        TypeTx,
+		Hex:         string(b),
+		Hash:        tx.Hash.String(),
+		WitnessHash: tx.WitnessHash().String(),
+	}
+	for i := range tx.Inputs {
+		v.SigHashes = append(v.SigHashes, tx.HashForSig(i).String())
+	}
+	return v
+}
+
+func blockVector(name string, block *bc.Block) *Vector {
+	b, _ := block.MarshalText() // error is impossible
+	v := &Vector{
+		Name:    name,
+		Type:    TypeBlock,
+		Hex:     string(b),
+		Hash:    block.Hash().String(),
+		SigHash: block.HashForSig().String(),
+	}
+	for _, tx := range block.Transactions {
+		v.TransactionIDs = append(v.TransactionIDs, tx.Hash.String())
+	}
+	if len(block.Transactions) > 0 {
+		v.MerkleRoot = validation.CalcMerkleRoot(block.Transactions).String()
+	}
+	return v
+}
+
+func assetIDVector(name string, prog []byte, initialBlock bc.Hash, vmVersion uint64) *Vector {
+	return &Vector{
+		Name:            name,
+		Type:            TypeAssetID,
+		IssuanceProgram: hex.EncodeToString(prog),
+		InitialBlock:    initialBlock.String(),
+		VMVersion:       vmVersion,
+		AssetID:         bc.ComputeAssetID(prog, initialBlock, vmVersion).String(),
+	}
+}
+
+// merkleVector returns a Merkle root vector for the transactions
+// serialized in txHexes, which must be valid.
+func merkleVector(name string, txHexes ...string) *Vector {
+	v, _ := merkleVectorErr(name, txHexes) // error is impossible
+	return v
+}
+
+func merkleVectorErr(name string, txHexes []string) (*Vector, error) {
+	txs := make([]*bc.Tx, 0, len(txHexes))
+	for _, h := range txHexes {
+		var tx bc.Tx
+		err := tx.UnmarshalText([]byte(h))
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+	}
+	return &Vector{
+		Name:         name,
+		Type:         TypeMerkle,
+		Transactions: txHexes,
+		MerkleRoot:   validation.CalcMerkleRoot(txs).String(),
+	}, nil
+}
+
+// Check deserializes v and checks that the result
+// reserializes to the same bytes and has the hashes in v.
+// For invalid vectors, it checks that deserialization fails.
+func (v *Vector) Check() error {
+	var (
+		got *Vector
+		err error
+	)
+	switch v.Type {
+	case TypeTx:
+		var tx bc.Tx
+		err = tx.UnmarshalText([]byte(v.Hex))
+		if err == nil {
+			got = txVector(v.Name, &tx.TxData)
+		}
+	case TypeBlock:
+		var block bc.Block
+		err = block.UnmarshalText([]byte(v.Hex))
+		if err == nil {
+			got = blockVector(v.Name, &block)
+		}
+	case TypeAssetID:
+		return v.checkAssetID()
+	case TypeMerkle:
+		got, err = merkleVectorErr(v.Name, v.Transactions)
+		if err != nil {
+			return errors.Wrap(err, v.Name)
+		}
+		if got.MerkleRoot != v.MerkleRoot {
+			return fmt.Errorf("%s: got merkle root %s, want %s", v.Name, got.MerkleRoot, v.MerkleRoot)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: unknown type %q", v.Name, v.Type)
+	}
+
+	if v.Invalid {
+		if err == nil {
+			return fmt.Errorf("%s: invalid %s deserialized without error", v.Name, v.Type)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, v.Name)
+	}
+	if got.Hex != v.Hex {
+		return fmt.Errorf("%s: reserialized to %s", v.Name, got.Hex)
+	}
+	if got.Hash != v.Hash || got.WitnessHash != v.WitnessHash || got.SigHash != v.SigHash {
+		return fmt.Errorf("%s: got hashes %s %s %s, want %s %s %s", v.Name,
+			got.Hash, got.WitnessHash, got.SigHash, v.Hash, v.WitnessHash, v.SigHash)
+	}
+	if !equal(got.SigHashes, v.SigHashes) {
+		return fmt.Errorf("%s: got sig hashes %v, want %v", v.Name, got.SigHashes, v.SigHashes)
+	}
+	if !equal(got.TransactionIDs, v.TransactionIDs) {
+		return fmt.Errorf("%s: got transaction IDs %v, want %v", v.Name, got.TransactionIDs, v.TransactionIDs)
+	}
+	if got.MerkleRoot != v.MerkleRoot {
+		return fmt.Errorf("%s: got merkle root %s, want %s", v.Name, got.MerkleRoot, v.MerkleRoot)
+	}
+	return nil
+}
+
+func (v *Vector) checkAssetID() error {
+	prog, err := hex.DecodeString(v.IssuanceProgram)
+	if err != nil {
+		return errors.Wrap(err, v.Name)
+	}
+	initialBlock, err := bc.ParseHash(v.InitialBlock)
+	if err != nil {
+		return errors.Wrap(err, v.Name)
+	}
+	got := assetIDVector(v.Name, prog, initialBlock, v.VMVersion)
+	if got.AssetID != v.AssetID {
+		return fmt.Errorf("%s: got asset ID %s, want %s", v.Name, got.AssetID, v.AssetID)
+	}
+	return nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -161,6 +161,30 @@ func (bh *BlockHeader) Time() time.Time {
 	return time.Unix(0, int64(tsNano)).UTC()
 }
 
+// MarshalText fulfills the json.Marshaler interface.
+func (bh *BlockHeader) MarshalText() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	_, err := bh.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := make([]byte, hex.EncodedLen(buf.Len()))
+	hex.Encode(enc, buf.Bytes())
+	return enc, nil
+}
+
+// UnmarshalText fulfills the encoding.TextUnmarshaler interface.
+func (bh *BlockHeader) UnmarshalText(text []byte) error {
+	decoded := make([]byte, hex.DecodedLen(len(text)))
+	_, err := hex.Decode(decoded, text)
+	if err != nil {
+		return err
+	}
+	_, err = bh.readFrom(bytes.NewReader(decoded))
+	return err
+}
+
 func (bh *BlockHeader) Scan(val interface{}) error {
 	buf, ok := val.([]byte)
 	if !ok {
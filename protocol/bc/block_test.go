@@ -85,6 +85,29 @@ func TestMarshalBlock(t *testing.T) {
 	}
 }
 
+func TestMarshalBlockHeader(t *testing.T) {
+	bh := &BlockHeader{
+		Version:          1,
+		Height:           2,
+		TimestampMS:      3,
+		ConsensusProgram: []byte{0x51},
+		Witness:          [][]byte{{4}},
+	}
+	got, err := json.Marshal(bh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bh2 BlockHeader
+	err = json.Unmarshal(got, &bh2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*bh, bh2) {
+		t.Errorf("expected marshaled/unmarshaled block header to be:\n%sgot:\n%s", spew.Sdump(*bh), spew.Sdump(bh2))
+	}
+}
+
 func TestEmptyBlock(t *testing.T) {
 	block := Block{
 		BlockHeader: BlockHeader{
@@ -266,6 +266,32 @@ func TestInvalidIssuance(t *testing.T) {
 	}
 }
 
+func TestConfidentialRoundTrip(t *testing.T) {
+	assetID := AssetID{1}
+	out := NewConfidentialOutput(assetID, [32]byte{2}, []byte{3, 4}, []byte{5}, nil)
+	spend := NewSpendInput(Hash{6}, 0, [][]byte{{7}}, assetID, 0, []byte{5}, nil)
+	spend.AssetVersion = ConfidentialAssetVersion
+	spend.TypedInput.(*SpendInput).OutputCommitment = out.OutputCommitment
+	tx := TxData{
+		Version: 2,
+		Inputs:  []*TxInput{spend},
+		Outputs: []*TxOutput{out},
+	}
+
+	b, err := tx.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TxData
+	err = got.UnmarshalText(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, tx) {
+		t.Errorf("got:\n%s\nwant:\n%s", spew.Sdump(got), spew.Sdump(tx))
+	}
+}
+
 func TestEmptyOutpoint(t *testing.T) {
 	g := Outpoint{}.String()
 	w := "0000000000000000000000000000000000000000000000000000000000000000:0"
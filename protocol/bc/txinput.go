@@ -163,7 +163,7 @@ func (t *TxInput) readFrom(r io.Reader, txVersion uint64) (err error) {
 		si      *SpendInput
 		assetID AssetID
 	)
-	if t.AssetVersion == 1 {
+	if t.AssetVersion == 1 || t.AssetVersion == ConfidentialAssetVersion {
 		icBuf := bytes.NewBuffer(inputCommitment)
 		var icType [1]byte
 		_, err = io.ReadFull(icBuf, icType[:])
@@ -174,6 +174,9 @@ func (t *TxInput) readFrom(r io.Reader, txVersion uint64) (err error) {
 		var n int
 		switch icType[0] {
 		case 0:
+			if t.AssetVersion != 1 {
+				return fmt.Errorf("issuance input with asset version %d", t.AssetVersion)
+			}
 			ii = new(IssuanceInput)
 
 			ii.Nonce, n, err = blockchain.ReadVarstr31(icBuf)
@@ -201,7 +204,7 @@ func (t *TxInput) readFrom(r io.Reader, txVersion uint64) (err error) {
 				return err
 			}
 			bytesRead += n
-			n, err = si.OutputCommitment.readFrom(icBuf, txVersion, 1)
+			n, err = si.OutputCommitment.readFrom(icBuf, txVersion, t.AssetVersion)
 			if err != nil {
 				return err
 			}
@@ -293,7 +296,7 @@ func (t TxInput) writeTo(w io.Writer, serflags uint8) {
 }
 
 func (t TxInput) WriteInputCommitment(w io.Writer) {
-	if t.AssetVersion == 1 {
+	if t.AssetVersion == 1 || t.AssetVersion == ConfidentialAssetVersion {
 		switch inp := t.TypedInput.(type) {
 		case *IssuanceInput:
 			w.Write([]byte{0})                     // issuance type
@@ -311,7 +314,7 @@ func (t TxInput) WriteInputCommitment(w io.Writer) {
 }
 
 func (t TxInput) writeInputWitness(w io.Writer) {
-	if t.AssetVersion == 1 {
+	if t.AssetVersion == 1 || t.AssetVersion == ConfidentialAssetVersion {
 		var arguments [][]byte
 		switch inp := t.TypedInput.(type) {
 		case *IssuanceInput:
@@ -22,9 +22,30 @@ type (
 		AssetAmount
 		VMVersion      uint64
 		ControlProgram []byte
+
+		// Confidential is the blinded amount of an output of
+		// asset version ConfidentialAssetVersion, whose Amount
+		// is zero.
+		Confidential *ConfidentialAmount
+	}
+
+	// ConfidentialAmount is a commitment to an amount of an
+	// asset, and a proof that the amount is less than 2^63.
+	// See package chain/crypto/ed25519/confidential.
+	ConfidentialAmount struct {
+		Commitment [32]byte
+		RangeProof []byte
 	}
 )
 
+// ConfidentialAssetVersion is the experimental asset version of
+// outputs whose amounts are hidden in commitments. Its output
+// commitment is the asset ID, the amount commitment, the range
+// proof, the VM version, and the control program.
+// Only validators that enable confidential amounts check them;
+// see validation.EnableConfidentialAmounts.
+const ConfidentialAssetVersion = 2
+
 func NewTxOutput(assetID AssetID, amount uint64, controlProgram, referenceData []byte) *TxOutput {
 	return &TxOutput{
 		AssetVersion: 1,
@@ -40,6 +61,25 @@ func NewTxOutput(assetID AssetID, amount uint64, controlProgram, referenceData [
 	}
 }
 
+// NewConfidentialOutput returns an output of asset version
+// ConfidentialAssetVersion with the commitment and range proof
+// of its amount.
+func NewConfidentialOutput(assetID AssetID, commitment [32]byte, rangeProof, controlProgram, referenceData []byte) *TxOutput {
+	return &TxOutput{
+		AssetVersion: ConfidentialAssetVersion,
+		OutputCommitment: OutputCommitment{
+			AssetAmount:    AssetAmount{AssetID: assetID},
+			VMVersion:      1,
+			ControlProgram: controlProgram,
+			Confidential: &ConfidentialAmount{
+				Commitment: commitment,
+				RangeProof: rangeProof,
+			},
+		},
+		ReferenceData: referenceData,
+	}
+}
+
 // assumes r has sticky errors
 func (to *TxOutput) readFrom(r io.Reader, txVersion uint64) (err error) {
 	to.AssetVersion, _, err = blockchain.ReadVarint63(r)
@@ -69,12 +109,17 @@ func (oc *OutputCommitment) readFrom(r io.Reader, txVersion, assetVersion uint64
 		return n, err
 	}
 
-	if assetVersion != 1 {
+	if assetVersion != 1 && assetVersion != ConfidentialAssetVersion {
 		return n, nil
 	}
 
 	rb := bytes.NewBuffer(b)
-	n1, err := oc.AssetAmount.readFrom(rb)
+	var n1 int
+	if assetVersion == ConfidentialAssetVersion {
+		n1, err = oc.readConfidential(rb)
+	} else {
+		n1, err = oc.AssetAmount.readFrom(rb)
+	}
 	if err != nil {
 		return n, err
 	}
@@ -96,6 +141,21 @@ func (oc *OutputCommitment) readFrom(r io.Reader, txVersion, assetVersion uint64
 	return n, nil
 }
 
+func (oc *OutputCommitment) readConfidential(r io.Reader) (int, error) {
+	oc.Confidential = new(ConfidentialAmount)
+	n1, err := io.ReadFull(r, oc.AssetID[:])
+	if err != nil {
+		return n1, err
+	}
+	n2, err := io.ReadFull(r, oc.Confidential.Commitment[:])
+	if err != nil {
+		return n1 + n2, err
+	}
+	var n3 int
+	oc.Confidential.RangeProof, n3, err = blockchain.ReadVarstr31(r)
+	return n1 + n2 + n3, err
+}
+
 // assumes r has sticky errors
 func (to *TxOutput) writeTo(w io.Writer, serflags byte) {
 	blockchain.WriteVarint63(w, to.AssetVersion) // TODO(bobg): check and return error
@@ -114,8 +174,18 @@ func (to TxOutput) WriteCommitment(w io.Writer) {
 
 func (oc OutputCommitment) writeTo(w io.Writer, assetVersion uint64) {
 	b := new(bytes.Buffer)
-	if assetVersion == 1 {
-		oc.AssetAmount.writeTo(b)
+	if assetVersion == 1 || assetVersion == ConfidentialAssetVersion {
+		if assetVersion == ConfidentialAssetVersion {
+			var ca ConfidentialAmount
+			if oc.Confidential != nil {
+				ca = *oc.Confidential
+			}
+			b.Write(oc.AssetID[:])
+			b.Write(ca.Commitment[:])
+			blockchain.WriteVarstr31(b, ca.RangeProof) // TODO(bobg): check and return error
+		} else {
+			oc.AssetAmount.writeTo(b)
+		}
 		blockchain.WriteVarint63(b, oc.VMVersion) // TODO(bobg): check and return error
 		blockchain.WriteVarstr31(b, oc.ControlProgram)
 	}
@@ -0,0 +1,38 @@
+package bc_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"chain/protocol/bc/bctest"
+)
+
+// TestVectors checks the golden test vectors, which other
+// implementations of the protocol also check themselves against.
+// If they need to change, regenerate them with go generate
+// in package bctest.
+func TestVectors(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden []*bctest.Vector
+	err = json.Unmarshal(b, &golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range golden {
+		err := v.Check()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	got := bctest.Vectors()
+	if !reflect.DeepEqual(got, golden) {
+		t.Error("generated vectors differ from testdata/vectors.json; regenerate them with go generate chain/protocol/bc/bctest")
+	}
+}
@@ -2,7 +2,7 @@ package protocol
 
 import (
 	"context"
-	"fmt"
+	"io/ioutil"
 	"time"
 
 	"chain/crypto/ed25519"
@@ -22,9 +22,17 @@ const maxBlockTxs = 10000
 // snapshot to the Store.
 const saveSnapshotFrequency = time.Hour
 
+// DefaultMaxClockSkew is the default for Chain.MaxClockSkew.
+const DefaultMaxClockSkew = 2 * time.Minute
+
 // ErrBadBlock is returned when a block is invalid.
 var ErrBadBlock = errors.New("invalid block")
 
+// ErrClockSkew is returned when a block's timestamp is too far
+// from the local clock. The block may be valid; the clock here
+// or where the block was made is wrong.
+var ErrClockSkew = errors.New("block timestamp too far from local clock")
+
 // ErrStaleState is returned when the Chain does not have a current
 // blockchain state.
 var ErrStaleState = errors.New("stale blockchain state")
@@ -38,13 +46,15 @@ func (c *Chain) GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
 // GenerateBlock generates a valid, but unsigned, candidate block from
 // the current pending transaction pool. It returns the new block and
 // a snapshot of what the state snapshot is if the block is applied.
+// The pool's order decides which transactions the block includes;
+// they appear in the block in canonical order (see CanonicalTxOrder).
 //
 // After generating the block, the pending transaction pool will be
 // empty.
 func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *state.Snapshot, now time.Time) (b *bc.Block, result *state.Snapshot, err error) {
 	timestampMS := bc.Millis(now)
 	if timestampMS < prev.TimestampMS {
-		return nil, nil, fmt.Errorf("timestamp %d is earlier than prevblock timestamp %d", timestampMS, prev.TimestampMS)
+		return nil, nil, errors.WithDetailf(ErrClockSkew, "local clock %s is behind the previous block's timestamp %s", now.UTC(), prev.Time())
 	}
 
 	// Make a copy of the state that we can apply our changes to.
@@ -58,38 +68,94 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 
 	b = &bc.Block{
 		BlockHeader: bc.BlockHeader{
-			Version:           bc.NewBlockVersion,
+			Version:           validation.SignalVersion(snapshot),
 			Height:            prev.Height + 1,
 			PreviousBlockHash: prev.Hash(),
 			TimestampMS:       timestampMS,
 			ConsensusProgram:  prev.ConsensusProgram,
 		},
 	}
+	validation.ApplySignals(result, b)
+
+	// Stay within the limits of the previous block's consensus
+	// program. The size counts the largest encoding of the
+	// transaction count, so it can only overestimate.
+	limits := vmutil.ParseBlockLimits(prev.ConsensusProgram)
+	maxTxs := maxBlockTxs
+	if limits.MaxTxs > 0 && limits.MaxTxs < maxBlockTxs {
+		maxTxs = int(limits.MaxTxs)
+	}
+	size := validation.BlockSize(b) + 4
 
-	for _, tx := range txs {
-		if len(b.Transactions) >= maxBlockTxs {
+	// Transactions that don't fit, and those spending outputs
+	// not confirmed yet, wait for the next block if the pool
+	// can hold on to them. An unconfirmed parent may come later
+	// in txs, or may not have reached the pool yet.
+	var deferred []*bc.Tx
+	for i, tx := range txs {
+		if len(b.Transactions) >= maxTxs {
+			deferred = append(deferred, txs[i:]...)
 			break
 		}
+		var txSize uint64
+		if limits.MaxBytes > 0 {
+			n, _ := tx.WriteTo(ioutil.Discard)
+			txSize = uint64(n)
+			if size+txSize > limits.MaxBytes {
+				deferred = append(deferred, tx)
+				continue
+			}
+		}
 
 		if validation.ConfirmTx(result, c.InitialBlockHash, b, tx) == nil {
 			validation.ApplyTx(result, tx)
 			b.Transactions = append(b.Transactions, tx)
+			size += txSize
+		} else if spendsMissing(result, tx) && (tx.MaxTime == 0 || tx.MaxTime > timestampMS) {
+			deferred = append(deferred, tx)
 		}
 	}
+	if op, ok := c.pool.(OrphanPool); ok && len(deferred) > 0 {
+		op.Requeue(ctx, deferred)
+	}
+	b.Transactions = CanonicalTxOrder(b.Transactions)
 	b.TransactionsMerkleRoot = validation.CalcMerkleRoot(b.Transactions)
 	b.AssetsMerkleRoot = result.Tree.RootHash()
 	return b, result, nil
 }
 
+// spendsMissing reports whether tx spends an output that
+// isn't in the state tree of snapshot: an output of a
+// transaction not confirmed yet, or one already spent.
+func spendsMissing(snapshot *state.Snapshot, tx *bc.Tx) bool {
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() {
+			continue
+		}
+		k, val := state.OutputTreeItem(state.Prevout(in))
+		if !snapshot.Tree.Contains(k, val) {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateBlock performs validation on an incoming block, in advance
 // of committing the block. ValidateBlock returns the state after
 // the block has been applied.
 func (c *Chain) ValidateBlock(ctx context.Context, prevState *state.Snapshot, prev, block *bc.Block) (*state.Snapshot, error) {
+	ctx = log.AddFields(ctx, log.KeyBlockHeight, block.Height)
+	err := c.checkFinality(block)
+	if err != nil {
+		return nil, err
+	}
 	newState := state.Copy(prevState)
-	err := validation.ValidateBlockForAccept(ctx, newState, c.InitialBlockHash, prev, block, c.ValidateTxCached)
+	err = validation.ValidateBlockForAccept(ctx, newState, c.InitialBlockHash, prev, block, c.ValidateTxCached)
 	if err != nil {
+		log.Debug(ctx, log.SubsystemValidation, log.KeyMessage, "rejected block", "reason", err.Error())
 		return nil, errors.Wrapf(ErrBadBlock, "validate block: %v", err)
 	}
+	log.Debug(ctx, log.SubsystemValidation, log.KeyMessage, "validated block", "txs", len(block.Transactions))
 	// TODO(kr): consider calling CommitBlock here and
 	// renaming this function to AcceptBlock.
 	// See $CHAIN/protocol/doc/spec/validation.md#accept-block
@@ -107,6 +173,8 @@ func (c *Chain) ValidateBlock(ctx context.Context, prevState *state.Snapshot, pr
 // The block parameter must have already been validated before
 // being committed.
 func (c *Chain) CommitBlock(ctx context.Context, block *bc.Block, snapshot *state.Snapshot) error {
+	ctx = log.AddFields(ctx, log.KeyBlockHeight, block.Height)
+
 	// SaveBlock is the linearization point. Once the block is committed
 	// to persistent storage, the block has been applied and everything
 	// else can be derived from that block.
@@ -148,6 +216,22 @@ func (c *Chain) queueSnapshot(ctx context.Context, height uint64, timestamp time
 	}
 }
 
+// SaveSnapshot stores a snapshot of the current state now,
+// instead of waiting for the next periodic snapshot, and
+// returns its height. The current state is only available
+// on the leader.
+func (c *Chain) SaveSnapshot(ctx context.Context) (uint64, error) {
+	block, snapshot := c.State()
+	if block == nil {
+		return 0, errors.New("no current blockchain state")
+	}
+	err := c.store.SaveSnapshot(ctx, block.Height, snapshot)
+	if err != nil {
+		return 0, errors.Wrap(err, "saving snapshot")
+	}
+	return block.Height, nil
+}
+
 func (c *Chain) setHeight(h uint64) {
 	// We call setHeight from two places independently:
 	// CommitBlock and the Postgres LISTEN goroutine.
@@ -169,7 +253,17 @@ func (c *Chain) setHeight(h uint64) {
 // ValidateBlockForSig performs validation on an incoming _unsigned_
 // block in preparation for signing it. By definition it does not
 // execute the sigscript.
+//
+// It returns ErrClockSkew for a block timestamped more than
+// c.MaxClockSkew ahead of the local clock, and ErrBadBlock for
+// a block that violates the consensus rules.
 func (c *Chain) ValidateBlockForSig(ctx context.Context, block *bc.Block) error {
+	if c.MaxClockSkew > 0 {
+		if ahead := block.Time().Sub(time.Now()); ahead > c.MaxClockSkew {
+			return errors.WithDetailf(ErrClockSkew, "block timestamp %s is %s ahead of the local clock, more than the %s allowed", block.Time(), ahead, c.MaxClockSkew)
+		}
+	}
+
 	var (
 		prev     *bc.Block
 		snapshot = state.Empty()
@@ -188,18 +282,34 @@ func (c *Chain) ValidateBlockForSig(ctx context.Context, block *bc.Block) error
 		}
 	}
 
+	err := c.checkFinality(block)
+	if err != nil {
+		return err
+	}
+
 	// TODO(kr): cache the applied snapshot, and maybe
 	// we can skip re-applying it later
 	snapshot = state.Copy(snapshot)
-	err := validation.ValidateBlock(ctx, snapshot, c.InitialBlockHash, prev, block, validation.CheckTxWellFormed)
-	return errors.Wrap(err, "validation")
+	err = validation.ValidateBlock(ctx, snapshot, c.InitialBlockHash, prev, block, validation.CheckTxWellFormed)
+	if err != nil {
+		return errors.WithDetail(ErrBadBlock, err.Error())
+	}
+	return nil
 }
 
 func NewInitialBlock(pubkeys []ed25519.PublicKey, nSigs int, timestamp time.Time) (*bc.Block, error) {
+	return NewInitialBlockWithLimits(pubkeys, nSigs, vmutil.BlockLimits{}, timestamp)
+}
+
+// NewInitialBlockWithLimits is like NewInitialBlock, but its
+// consensus program also commits to limits on the size of the
+// blocks after it. See vmutil.BlockLimitsProgram.
+func NewInitialBlockWithLimits(pubkeys []ed25519.PublicKey, nSigs int, limits vmutil.BlockLimits, timestamp time.Time) (*bc.Block, error) {
 	script, err := vmutil.BlockMultiSigProgram(pubkeys, nSigs)
 	if err != nil {
 		return nil, err
 	}
+	script = vmutil.BlockLimitsProgram(limits, script)
 	b := &bc.Block{
 		BlockHeader: bc.BlockHeader{
 			Version:                bc.NewBlockVersion,
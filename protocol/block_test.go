@@ -12,6 +12,7 @@ import (
 	"chain/protocol/mempool"
 	"chain/protocol/memstore"
 	"chain/protocol/state"
+	"chain/protocol/vmutil"
 	"chain/testutil"
 )
 
@@ -177,7 +178,7 @@ func TestGenerateBlock(t *testing.T) {
 
 	// TODO(bobg): verify these hashes are correct
 	var wantTxRoot, wantAssetsRoot bc.Hash
-	copy(wantTxRoot[:], mustDecodeHex("d0e593c846d7b189bd3e2f55e680016b14989329af1c5e388ff246caedf04bd3"))
+	copy(wantTxRoot[:], mustDecodeHex("9dda7a19e72137fdd87023e4577c4622e24065f251df4720e17ed66a619f284e"))
 	copy(wantAssetsRoot[:], mustDecodeHex("903d9a10ece41f86b7c2cf23c25b09c2086b321d6d63e2ec7fc7405f84121542"))
 
 	want := &bc.Block{
@@ -190,7 +191,7 @@ func TestGenerateBlock(t *testing.T) {
 			TimestampMS:            bc.Millis(now),
 			ConsensusProgram:       b1.ConsensusProgram,
 		},
-		Transactions: txs,
+		Transactions: []*bc.Tx{txs[1], txs[0]}, // in canonical order
 	}
 
 	if !reflect.DeepEqual(got, want) {
@@ -198,6 +199,84 @@ func TestGenerateBlock(t *testing.T) {
 	}
 }
 
+func TestGenerateBlockLimits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, b1 := newTestChain(t, now)
+
+	initialBlockHash := b1.Hash()
+	assetID := bc.ComputeAssetID(nil, initialBlockHash, 1)
+	for i := uint64(1); i <= 3; i++ {
+		tx := bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewIssuanceInput(nil, i, nil, initialBlockHash, nil, nil)},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, i, []byte{byte(i)}, nil)},
+		})
+		err := c.pool.Insert(ctx, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prev := *b1
+	prev.ConsensusProgram = vmutil.BlockLimitsProgram(vmutil.BlockLimits{MaxTxs: 2}, b1.ConsensusProgram)
+	got, _, err := c.GenerateBlock(ctx, &prev, state.Empty(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Transactions) != 2 {
+		t.Errorf("got %d transactions, want 2", len(got.Transactions))
+	}
+	if vmutil.ParseBlockLimits(got.ConsensusProgram).MaxTxs != 2 {
+		t.Errorf("generated block doesn't carry the limits forward")
+	}
+}
+
+func TestGenerateBlockOrphans(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, b1 := newTestChain(t, now)
+	pool := c.pool.(*mempool.MemPool)
+
+	initialBlockHash := b1.Hash()
+	assetID := bc.ComputeAssetID(nil, initialBlockHash, 1)
+	parent := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput(nil, 5, nil, initialBlockHash, nil, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 5, []byte{1}, nil)},
+	})
+	child := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(parent.Hash, 0, nil, assetID, 5, []byte{1}, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 5, []byte{2}, nil)},
+	})
+
+	// The child arrives first, and waits for its parent.
+	err := c.pool.Insert(ctx, child)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, snapshot, err := c.GenerateBlock(ctx, b1, state.Empty(), now)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got.Transactions) != 0 || !pool.Contains(child.Hash) {
+		t.Fatalf("got %d transactions, pool has child %t; want 0, true", len(got.Transactions), pool.Contains(child.Hash))
+	}
+
+	err = c.pool.Insert(ctx, parent)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, _, err = c.GenerateBlock(ctx, got, snapshot, now.Add(time.Second))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got.Transactions) != 2 || pool.Len() != 0 {
+		t.Errorf("got %d transactions, %d left in pool; want 2, 0", len(got.Transactions), pool.Len())
+	}
+}
+
 func TestValidateBlockForSig(t *testing.T) {
 	initialBlock, err := NewInitialBlock(testutil.TestPubs, 1, time.Now())
 	if err != nil {
@@ -214,6 +293,15 @@ func TestValidateBlockForSig(t *testing.T) {
 	if err != nil {
 		t.Error("unexpected error ", err)
 	}
+
+	future, err := NewInitialBlock(testutil.TestPubs, 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	err = c.ValidateBlockForSig(ctx, future)
+	if errors.Root(err) != ErrClockSkew {
+		t.Errorf("ValidateBlockForSig(future block) = %v want %v", err, ErrClockSkew)
+	}
 }
 
 // newTestChain returns a new Chain using memstore and mempool for storage,
@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"bytes"
+	"container/heap"
+
+	"chain/protocol/bc"
+)
+
+// CanonicalTxOrder returns txs in canonical order, so that
+// everyone with the same set of transactions builds the same
+// block from them. Each transaction comes after any others
+// in txs whose outputs it spends; among the transactions
+// that may come next, the one with the lowest hash does.
+//
+// The generator puts block transactions in canonical order,
+// and block signers refuse blocks whose transactions aren't,
+// so that a signer can recompute a block from its transactions
+// and know it is signing what it expects. The consensus rules
+// require only that a transaction follow those it spends.
+//
+// A transaction that appears in txs more than once appears
+// in the result only once, so the result may be shorter.
+func CanonicalTxOrder(txs []*bc.Tx) []*bc.Tx {
+	var (
+		exists   = make(map[bc.Hash]bool)
+		unique   []*bc.Tx
+		parents  = make(map[bc.Hash]int)      // unemitted in-block parents, by child
+		children = make(map[bc.Hash][]*bc.Tx) // by parent
+		ready    txHeap
+	)
+	for _, tx := range txs {
+		if !exists[tx.Hash] {
+			exists[tx.Hash] = true
+			unique = append(unique, tx)
+		}
+	}
+	for _, tx := range unique {
+		seen := make(map[bc.Hash]bool)
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			prev := in.Outpoint().Hash
+			if exists[prev] && !seen[prev] {
+				seen[prev] = true
+				parents[tx.Hash]++
+				children[prev] = append(children[prev], tx)
+			}
+		}
+		if parents[tx.Hash] == 0 {
+			ready = append(ready, tx)
+		}
+	}
+	heap.Init(&ready)
+
+	l := make([]*bc.Tx, 0, len(unique))
+	for ready.Len() > 0 {
+		tx := heap.Pop(&ready).(*bc.Tx)
+		l = append(l, tx)
+		for _, child := range children[tx.Hash] {
+			parents[child.Hash]--
+			if parents[child.Hash] == 0 {
+				heap.Push(&ready, child)
+			}
+		}
+	}
+	if len(l) != len(unique) { // impossible without duplicates
+		panic("cyclical tx ordering")
+	}
+	return l
+}
+
+// IsCanonical reports whether the transactions
+// in b are in canonical order. See CanonicalTxOrder.
+// A block with a duplicate transaction is not.
+func IsCanonical(b *bc.Block) bool {
+	canonical := CanonicalTxOrder(b.Transactions)
+	if len(canonical) != len(b.Transactions) {
+		return false
+	}
+	for i, tx := range b.Transactions {
+		if tx.Hash != canonical[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// txHeap is a min-heap of transactions by hash.
+type txHeap []*bc.Tx
+
+func (h txHeap) Len() int           { return len(h) }
+func (h txHeap) Less(i, j int) bool { return bytes.Compare(h[i].Hash[:], h[j].Hash[:]) < 0 }
+func (h txHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *txHeap) Push(x interface{}) { *h = append(*h, x.(*bc.Tx)) }
+
+func (h *txHeap) Pop() interface{} {
+	old := *h
+	tx := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return tx
+}
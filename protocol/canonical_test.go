@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+func TestCanonicalTxOrder(t *testing.T) {
+	issue := func(n byte) *bc.Tx {
+		return bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{n}, 1, nil, bc.Hash{}, nil, nil)},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, nil, nil)},
+		})
+	}
+	spend := func(parent *bc.Tx) *bc.Tx {
+		return bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewSpendInput(parent.Hash, 0, nil, bc.AssetID{}, 1, nil, nil)},
+		})
+	}
+
+	var a, b *bc.Tx
+	for n := byte(0); a == nil || bytes.Compare(a.Hash[:], b.Hash[:]) < 0; n += 2 {
+		a, b = issue(n), issue(n+1) // want b's hash before a's
+	}
+	child := spend(a)
+
+	// Any order of the same transactions gives the same result.
+	want := []*bc.Tx{b, a, child}
+	for _, txs := range [][]*bc.Tx{
+		{a, b, child},
+		{child, a, b},
+		{b, child, a},
+	} {
+		got := CanonicalTxOrder(txs)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CanonicalTxOrder(%v) = %v want %v", hashes(txs), hashes(got), hashes(want))
+		}
+	}
+
+	if !IsCanonical(&bc.Block{Transactions: want}) {
+		t.Errorf("IsCanonical(%v) = false want true", hashes(want))
+	}
+	if IsCanonical(&bc.Block{Transactions: []*bc.Tx{a, b, child}}) {
+		t.Errorf("IsCanonical(%v) = true want false", hashes([]*bc.Tx{a, b, child}))
+	}
+
+	// A transaction spending another, twice, isn't a cycle.
+	dup := []*bc.Tx{a, child, child}
+	if got := CanonicalTxOrder(dup); !reflect.DeepEqual(got, []*bc.Tx{a, child}) {
+		t.Errorf("CanonicalTxOrder(%v) = %v want %v", hashes(dup), hashes(got), hashes([]*bc.Tx{a, child}))
+	}
+	if IsCanonical(&bc.Block{Transactions: dup}) {
+		t.Errorf("IsCanonical(%v) = true want false", hashes(dup))
+	}
+}
+
+func hashes(txs []*bc.Tx) (a []bc.Hash) {
+	for _, tx := range txs {
+		a = append(a, tx.Hash)
+	}
+	return a
+}
@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrCheckpointMismatch is returned for a block whose
+	// hash differs from the checkpoint at its height.
+	ErrCheckpointMismatch = errors.New("block does not match checkpoint")
+
+	// ErrFinalized is returned for a block that would
+	// replace a final block.
+	ErrFinalized = errors.New("block would replace a final block")
+)
+
+// A Checkpoint names the block that must be at a height.
+// Checkpoints are set by the operator of a core, from a
+// source they trust, so that it refuses any other block
+// at that height, and treats the block as final.
+type Checkpoint struct {
+	Height uint64
+	Hash   bc.Hash
+}
+
+// FinalHeight returns the height of the latest block that is
+// final, or 0 if no block is final. A block is final once it
+// has c.FinalityDepth confirmations (counting itself), or if
+// it is at or below the height of a checkpoint the blockchain
+// has reached. A Chain never accepts a block that would replace
+// a final block, and the query layer reports transactions in
+// final blocks as final.
+func (c *Chain) FinalHeight() uint64 {
+	return c.finalHeight(c.Height())
+}
+
+func (c *Chain) finalHeight(height uint64) uint64 {
+	var final uint64
+	if c.FinalityDepth > 0 && height >= c.FinalityDepth {
+		final = height - c.FinalityDepth + 1
+	}
+	for _, cp := range c.Checkpoints {
+		if cp.Height <= height && cp.Height > final {
+			final = cp.Height
+		}
+	}
+	return final
+}
+
+// checkFinality returns an error if block conflicts with a
+// checkpoint, or would replace a final block.
+func (c *Chain) checkFinality(block *bc.Block) error {
+	for _, cp := range c.Checkpoints {
+		if cp.Height == block.Height && cp.Hash != block.Hash() {
+			return errors.WithDetailf(ErrCheckpointMismatch, "block %d is %s, checkpoint is %s", block.Height, block.Hash(), cp.Hash)
+		}
+	}
+	if final := c.FinalHeight(); block.Height <= final {
+		return errors.WithDetailf(ErrFinalized, "block %d is at or below final height %d", block.Height, final)
+	}
+	return nil
+}
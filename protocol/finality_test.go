@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+func TestFinalHeight(t *testing.T) {
+	c := &Chain{
+		FinalityDepth: 3,
+		Checkpoints:   []Checkpoint{{Height: 5}, {Height: 20}},
+	}
+	cases := []struct {
+		height, want uint64
+	}{
+		{0, 0},
+		{2, 0},
+		{3, 1},
+		{5, 5},
+		{9, 7},
+		{19, 17},
+		{20, 20},
+	}
+	for _, tc := range cases {
+		if got := c.finalHeight(tc.height); got != tc.want {
+			t.Errorf("finalHeight(%d) = %d want %d", tc.height, got, tc.want)
+		}
+	}
+}
+
+func TestValidateBlockFinality(t *testing.T) {
+	ctx := context.Background()
+	c, b1 := newTestChain(t, time.Now())
+	makeEmptyBlock(t, c)
+	b2, err := c.GetBlock(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another block at height 2, as in a reorg.
+	alt, _, err := c.GenerateBlock(ctx, b1, state.Empty(), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alt.Hash() == b2.Hash() {
+		t.Fatal("alternate block 2 is block 2")
+	}
+
+	_, err = c.ValidateBlock(ctx, state.Empty(), b1, alt)
+	if err != nil {
+		t.Errorf("ValidateBlock(alternate block 2) without finality = %v", err)
+	}
+
+	c.FinalityDepth = 1
+	_, err = c.ValidateBlock(ctx, state.Empty(), b1, alt)
+	if errors.Root(err) != ErrFinalized {
+		t.Errorf("ValidateBlock(alternate block 2) with depth 1 = %v want %v", err, ErrFinalized)
+	}
+
+	c.FinalityDepth = 0
+	c.Checkpoints = []Checkpoint{{Height: 3, Hash: bc.Hash{1}}}
+	b3, _, err := c.GenerateBlock(ctx, b2, state.Empty(), time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ValidateBlock(ctx, state.Empty(), b2, b3)
+	if errors.Root(err) != ErrCheckpointMismatch {
+		t.Errorf("ValidateBlock(block 3) = %v want %v", err, ErrCheckpointMismatch)
+	}
+
+	c.Checkpoints = []Checkpoint{{Height: 3, Hash: b3.Hash()}}
+	_, err = c.ValidateBlock(ctx, state.Empty(), b2, b3)
+	if err != nil {
+		t.Errorf("ValidateBlock(checkpointed block 3) = %v", err)
+	}
+}
@@ -1,53 +1,176 @@
 // Package mempool provides a Pool implementation that keeps
 // all pending transactions in memory.
 //
-// It is used in tests to avoid needing a database and is not
-// safe for concurrent access.
+// Transactions wait in one of three priority lanes, chosen by the
+// Submission in the context passed to Insert. Dump returns the
+// high-priority lane first, then normal, then low. Within a lane,
+// it takes transactions from each submitter in turn, and it moves
+// a submitter's high-priority transactions beyond its quota down
+// to the normal lane.
 package mempool
 
 import (
 	"context"
 	"sync"
 
+	"chain/errors"
 	"chain/log"
 	"chain/protocol/bc"
 )
 
-// MemPool satisfies the protocol.Pool interface.
+// Default limits on chains of pending transactions.
+const (
+	DefaultMaxChainDepth   = 25
+	DefaultMaxOrphanBlocks = 10
+	DefaultMaxOrphans      = 1000
+)
+
+// ErrChainTooDeep is returned by Insert for a transaction
+// spending outputs of a chain of pending transactions
+// longer than the pool allows.
+var ErrChainTooDeep = errors.New("too many unconfirmed ancestors")
+
+// MemPool satisfies the protocol.Pool and protocol.OrphanPool
+// interfaces.
 type MemPool struct {
+	// MaxChainDepth is how many pending transactions a chain
+	// of transactions spending each other's outputs may hold.
+	MaxChainDepth int
+
+	// MaxOrphanBlocks is how many blocks a requeued transaction
+	// waits for its parents before it's dropped, and MaxOrphans
+	// how many such transactions may wait at once.
+	MaxOrphanBlocks int
+	MaxOrphans      int
+
 	mu     sync.Mutex
-	pool   []*bc.Tx // in topological order
-	hashes map[bc.Hash]bool
+	pool   []*entry // in insertion order
+	hashes map[bc.Hash]*entry
+	dumped map[bc.Hash]*entry // by the last Dump
+}
+
+type entry struct {
+	tx    *bc.Tx
+	sub   Submission
+	depth int // pending transactions in its chain, itself included
+	held  int // blocks it has waited for its parents
 }
 
 // New returns a new MemPool.
 func New() *MemPool {
-	return &MemPool{hashes: make(map[bc.Hash]bool)}
+	return &MemPool{
+		MaxChainDepth:   DefaultMaxChainDepth,
+		MaxOrphanBlocks: DefaultMaxOrphanBlocks,
+		MaxOrphans:      DefaultMaxOrphans,
+		hashes:          make(map[bc.Hash]*entry),
+	}
 }
 
 // Insert adds a new pending tx to the pending tx pool.
+// If ctx carries a Submission (see NewContext),
+// the tx waits in the lane it names;
+// otherwise it waits in the normal lane.
 func (m *MemPool) Insert(ctx context.Context, tx *bc.Tx) error {
+	sub, ok := FromContext(ctx)
+	if !ok {
+		sub = Submission{Priority: PriorityNormal, Quota: Unlimited}
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.hashes[tx.Hash] {
+	if m.hashes[tx.Hash] != nil {
 		return nil
 	}
 
-	m.hashes[tx.Hash] = true
-	m.pool = append(m.pool, tx)
+	e := &entry{tx: tx, sub: sub, depth: m.depth(tx)}
+	if m.MaxChainDepth > 0 && e.depth > m.MaxChainDepth {
+		return errors.WithDetailf(ErrChainTooDeep, "transaction would be %d deep in a chain of pending transactions; the limit is %d", e.depth, m.MaxChainDepth)
+	}
+	m.hashes[tx.Hash] = e
+	m.pool = append(m.pool, e)
 	return nil
 }
 
+// depth returns the length of the longest chain of pending
+// transactions that tx would end. m.mu must be held.
+func (m *MemPool) depth(tx *bc.Tx) int {
+	d := 1
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() {
+			continue
+		}
+		if p := m.hashes[in.Outpoint().Hash]; p != nil && p.depth+1 > d {
+			d = p.depth + 1
+		}
+	}
+	return d
+}
+
+// Requeue returns to the pool transactions the last Dump returned
+// that couldn't go in a block because they spend outputs that
+// aren't confirmed yet, or didn't fit. They're offered again,
+// ahead of those inserted since, by the next Dump. A transaction
+// requeued for more than MaxOrphanBlocks blocks in a row, or beyond
+// MaxOrphans of them, is dropped.
+func (m *MemPool) Requeue(ctx context.Context, txs []*bc.Tx) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		requeued []*entry
+		dropped  int
+	)
+	for _, tx := range txs {
+		if m.hashes[tx.Hash] != nil {
+			continue
+		}
+		e := m.dumped[tx.Hash]
+		if e == nil {
+			e = &entry{tx: tx, sub: Submission{Priority: PriorityNormal, Quota: Unlimited}}
+		}
+		e.held++
+		if (m.MaxOrphanBlocks > 0 && e.held > m.MaxOrphanBlocks) || (m.MaxOrphans > 0 && len(requeued) >= m.MaxOrphans) {
+			dropped++
+			continue
+		}
+		e.depth = m.depth(tx)
+		m.hashes[tx.Hash] = e
+		requeued = append(requeued, e)
+	}
+	m.pool = append(requeued, m.pool...)
+	m.dumped = nil
+	if dropped > 0 {
+		log.Write(ctx, log.KeyMessage, "dropped orphan transactions", "count", dropped)
+	}
+}
+
+// Contains reports whether the transaction with the
+// given hash is pending in the pool.
+func (m *MemPool) Contains(hash bc.Hash) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hashes[hash] != nil
+}
+
+// Len returns the number of pending transactions in the pool.
+func (m *MemPool) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.pool)
+}
+
 // Dump returns all pending transactions in the pool and
 // empties the pool.
 func (m *MemPool) Dump(ctx context.Context) ([]*bc.Tx, error) {
 	m.mu.Lock()
-	txs := m.pool
+	entries := m.pool
 	m.pool = nil
-	m.hashes = make(map[bc.Hash]bool)
+	m.dumped = m.hashes
+	m.hashes = make(map[bc.Hash]*entry)
 	m.mu.Unlock()
 
+	txs := prioritize(entries)
 	if !isTopSorted(txs) {
 		log.Messagef(ctx, "set of %d txs not in topo order; sorting", len(txs))
 		txs = topSort(txs)
@@ -55,3 +178,53 @@ func (m *MemPool) Dump(ctx context.Context) ([]*bc.Tx, error) {
 
 	return txs, nil
 }
+
+// prioritize orders entries by lane, high first,
+// interleaving submitters within each lane.
+func prioritize(entries []*entry) []*bc.Tx {
+	lanes := make(map[Priority][]*entry)
+	used := make(map[string]int) // high-priority slots used, by submitter
+	for _, e := range entries {
+		p := e.sub.Priority
+		if p == PriorityHigh && e.sub.Quota != Unlimited {
+			if used[e.sub.Submitter] >= e.sub.Quota {
+				p = PriorityNormal
+			} else {
+				used[e.sub.Submitter]++
+			}
+		}
+		lanes[p] = append(lanes[p], e)
+	}
+
+	txs := make([]*bc.Tx, 0, len(entries))
+	for _, p := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		txs = append(txs, roundRobin(lanes[p])...)
+	}
+	return txs
+}
+
+// roundRobin takes one entry from each submitter in turn,
+// visiting submitters in the order they first appear
+// and preserving each submitter's own order.
+func roundRobin(entries []*entry) []*bc.Tx {
+	var submitters []string
+	queues := make(map[string][]*entry)
+	for _, e := range entries {
+		s := e.sub.Submitter
+		if _, ok := queues[s]; !ok {
+			submitters = append(submitters, s)
+		}
+		queues[s] = append(queues[s], e)
+	}
+
+	txs := make([]*bc.Tx, 0, len(entries))
+	for len(txs) < len(entries) {
+		for _, s := range submitters {
+			if q := queues[s]; len(q) > 0 {
+				txs = append(txs, q[0].tx)
+				queues[s] = q[1:]
+			}
+		}
+	}
+	return txs
+}
@@ -0,0 +1,202 @@
+package mempool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestDumpPriority(t *testing.T) {
+	ctx := context.Background()
+
+	var txs []*bc.Tx
+	for i := 0; i < 7; i++ {
+		txs = append(txs, bc.NewTx(bc.TxData{
+			Version:       1,
+			Inputs:        []*bc.TxInput{bc.NewIssuanceInput([]byte{byte(i)}, 1, nil, bc.Hash{}, nil, nil)},
+			ReferenceData: []byte{byte(i)},
+		}))
+	}
+
+	cases := []struct {
+		subs []Submission
+		want []int
+	}{{
+		// lanes, high first
+		subs: []Submission{
+			{Priority: PriorityLow, Quota: Unlimited},
+			{Priority: PriorityNormal, Quota: Unlimited},
+			{Priority: PriorityHigh, Quota: Unlimited},
+		},
+		want: []int{2, 1, 0},
+	}, {
+		// submitters interleaved within a lane
+		subs: []Submission{
+			{Submitter: "a", Quota: Unlimited},
+			{Submitter: "a", Quota: Unlimited},
+			{Submitter: "a", Quota: Unlimited},
+			{Submitter: "b", Quota: Unlimited},
+			{Submitter: "c", Quota: Unlimited},
+			{Submitter: "b", Quota: Unlimited},
+		},
+		want: []int{0, 3, 4, 1, 5, 2},
+	}, {
+		// high-priority txs beyond the quota move to the normal lane
+		subs: []Submission{
+			{Priority: PriorityNormal, Submitter: "b", Quota: 0},
+			{Priority: PriorityHigh, Submitter: "a", Quota: 1},
+			{Priority: PriorityHigh, Submitter: "a", Quota: 1},
+			{Priority: PriorityHigh, Submitter: "a", Quota: 1},
+		},
+		want: []int{1, 0, 2, 3},
+	}}
+
+	for i, c := range cases {
+		pool := New()
+		for j, sub := range c.subs {
+			err := pool.Insert(NewContext(ctx, sub), txs[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := pool.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []*bc.Tx
+		for _, j := range c.want {
+			want = append(want, txs[j])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("case %d: got %v, want %v", i, hashes(got), hashes(want))
+		}
+	}
+}
+
+func TestDumpPriorityTopSort(t *testing.T) {
+	ctx := context.Background()
+
+	parent := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{1}, 1, nil, bc.Hash{}, nil, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, nil, nil)},
+	})
+	child := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(parent.Hash, 0, nil, bc.AssetID{}, 1, nil, nil)},
+	})
+	other := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{2}, 1, nil, bc.Hash{}, nil, nil)},
+	})
+
+	pool := New()
+	pool.Insert(NewContext(ctx, Submission{Priority: PriorityLow, Quota: Unlimited}), parent)
+	pool.Insert(NewContext(ctx, Submission{Priority: PriorityHigh, Quota: Unlimited}), child)
+	pool.Insert(NewContext(ctx, Submission{Priority: PriorityNormal, Quota: Unlimited}), other)
+
+	got, err := pool.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*bc.Tx{other, parent, child}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", hashes(got), hashes(want))
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		got, err := ParsePriority(p.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != p {
+			t.Errorf("ParsePriority(%q) = %v want %v", p.String(), got, p)
+		}
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("ParsePriority(urgent) succeeded, want error")
+	}
+}
+
+func hashes(txs []*bc.Tx) (a []bc.Hash) {
+	for _, tx := range txs {
+		a = append(a, tx.Hash)
+	}
+	return a
+}
+
+func TestChainDepth(t *testing.T) {
+	ctx := context.Background()
+	pool := New()
+	pool.MaxChainDepth = 2
+
+	var prev *bc.Tx
+	for i := 0; i < 3; i++ {
+		in := bc.NewIssuanceInput([]byte{byte(i)}, 1, nil, bc.Hash{}, nil, nil)
+		if prev != nil {
+			in = bc.NewSpendInput(prev.Hash, 0, nil, bc.AssetID{}, 1, nil, nil)
+		}
+		tx := bc.NewTx(bc.TxData{Version: 1, Inputs: []*bc.TxInput{in}})
+		err := pool.Insert(ctx, tx)
+		if i < 2 && err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		if i == 2 && errors.Root(err) != ErrChainTooDeep {
+			t.Errorf("tx %d: err = %v, want ErrChainTooDeep", i, err)
+		}
+		prev = tx
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	ctx := context.Background()
+	pool := New()
+	pool.MaxOrphanBlocks = 2
+
+	orphan := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{}, 1, nil, nil)},
+	})
+	other := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput([]byte{1}, 1, nil, bc.Hash{}, nil, nil)},
+	})
+	err := pool.Insert(NewContext(ctx, Submission{Priority: PriorityHigh, Quota: Unlimited}), orphan)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		txs, err := pool.Dump(ctx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if i == 1 {
+			err = pool.Insert(ctx, other)
+			if err != nil {
+				testutil.FatalErr(t, err)
+			}
+		}
+		pool.Requeue(ctx, txs[:1])
+		if got, want := pool.Contains(orphan.Hash), i <= 2; got != want {
+			t.Errorf("after %d requeues, pool has orphan %t, want %t", i, got, want)
+		}
+	}
+
+	// Requeued transactions keep their lane.
+	pool = New()
+	pool.Insert(NewContext(ctx, Submission{Priority: PriorityLow, Quota: Unlimited}), orphan)
+	txs, _ := pool.Dump(ctx)
+	pool.Requeue(ctx, txs)
+	pool.Insert(ctx, other)
+	txs, _ = pool.Dump(ctx)
+	if !reflect.DeepEqual(txs, []*bc.Tx{other, orphan}) {
+		t.Errorf("Dump after Requeue = %v, want other then orphan", txs)
+	}
+}
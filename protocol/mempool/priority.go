@@ -0,0 +1,81 @@
+package mempool
+
+import (
+	"context"
+
+	"chain/errors"
+)
+
+// ErrBadPriority is returned by ParsePriority for unknown priority names.
+var ErrBadPriority = errors.New("invalid transaction priority")
+
+// Priority selects the lane a pending transaction waits in.
+// Dump returns transactions in higher lanes before those in
+// lower lanes.
+type Priority int8
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// Unlimited is the Quota of a Submission that may place any
+// number of transactions in the high-priority lane.
+const Unlimited = -1
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+// ParsePriority parses the name of a priority lane.
+// The empty string means PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return PriorityNormal, errors.WithDetailf(ErrBadPriority, "unknown priority %q", s)
+}
+
+// Submission describes who submitted a transaction
+// and how urgently they want it included.
+type Submission struct {
+	Priority Priority
+
+	// Submitter identifies the party submitting the transaction,
+	// typically by access token ID. Within a lane, Dump takes
+	// transactions from each submitter in turn so that no single
+	// submitter can crowd out the others.
+	Submitter string
+
+	// Quota is the number of transactions from Submitter that
+	// may occupy the high-priority lane in each call to Dump.
+	// Transactions beyond the quota wait in the normal lane.
+	// Use Unlimited for no limit.
+	Quota int
+}
+
+type submissionKey struct{}
+
+// NewContext returns a context carrying sub.
+// Insert uses it to decide where to place a transaction.
+func NewContext(ctx context.Context, sub Submission) context.Context {
+	return context.WithValue(ctx, submissionKey{}, sub)
+}
+
+// FromContext returns the Submission stored in ctx, if any.
+func FromContext(ctx context.Context) (Submission, bool) {
+	sub, ok := ctx.Value(submissionKey{}).(Submission)
+	return sub, ok
+}
@@ -2,57 +2,52 @@ package mempool
 
 import "chain/protocol/bc"
 
+// topSort returns txs reordered so that each tx comes after
+// any of its parents in txs. Otherwise it keeps the order
+// of txs: a tx is moved only as far as needed to follow
+// its last parent.
 func topSort(txs []*bc.Tx) []*bc.Tx {
 	if len(txs) == 1 {
 		return txs
 	}
 
-	nodes := make(map[bc.Hash]*bc.Tx)
+	exists := make(map[bc.Hash]bool)
 	for _, tx := range txs {
-		nodes[tx.Hash] = tx
+		exists[tx.Hash] = true
 	}
 
-	incomingEdges := make(map[bc.Hash]int)
-	children := make(map[bc.Hash][]bc.Hash)
-	for node, tx := range nodes {
+	var (
+		l       = make([]*bc.Tx, 0, len(txs))
+		emitted = make(map[bc.Hash]bool)
+		waiting = make(map[bc.Hash][]*bc.Tx) // by missing parent
+		visit   func(*bc.Tx)
+	)
+	visit = func(tx *bc.Tx) {
+		if emitted[tx.Hash] {
+			return
+		}
 		for _, in := range tx.Inputs {
 			if in.IsIssuance() {
 				continue
 			}
-			if prev := in.Outpoint().Hash; nodes[prev] != nil {
-				if children[prev] == nil {
-					children[prev] = make([]bc.Hash, 0, 1)
-				}
-				children[prev] = append(children[prev], node)
-				incomingEdges[node]++
+			if prev := in.Outpoint().Hash; exists[prev] && !emitted[prev] {
+				waiting[prev] = append(waiting[prev], tx)
+				return
 			}
 		}
-	}
-
-	var s []bc.Hash
-	for node := range nodes {
-		if incomingEdges[node] == 0 {
-			s = append(s, node)
+		l = append(l, tx)
+		emitted[tx.Hash] = true
+		children := waiting[tx.Hash]
+		delete(waiting, tx.Hash)
+		for _, child := range children {
+			visit(child)
 		}
 	}
-
-	// https://en.wikipedia.org/wiki/Topological_sorting#Algorithms
-	var l []*bc.Tx
-	for len(s) > 0 {
-		n := s[0]
-		s = s[1:]
-		l = append(l, nodes[n])
-
-		for _, m := range children[n] {
-			incomingEdges[m]--
-			if incomingEdges[m] == 0 {
-				delete(incomingEdges, m)
-				s = append(s, m)
-			}
-		}
+	for _, tx := range txs {
+		visit(tx)
 	}
 
-	if len(incomingEdges) > 0 { // should be impossible
+	if len(l) != len(txs) { // should be impossible
 		panic("cyclical tx ordering")
 	}
 
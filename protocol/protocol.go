@@ -53,6 +53,7 @@ import (
 	"chain/errors"
 	"chain/log"
 	"chain/protocol/bc"
+	"chain/protocol/standard"
 	"chain/protocol/state"
 )
 
@@ -96,6 +97,21 @@ type Pool interface {
 	Dump(context.Context) ([]*bc.Tx, error)
 }
 
+// An OrphanPool is a Pool that can hold on to transactions
+// that spend outputs not yet confirmed, so that a transaction
+// submitted before its parent is confirmed, or even seen, isn't
+// lost.
+type OrphanPool interface {
+	Pool
+
+	// Requeue returns to the pool transactions the last
+	// Dump returned that weren't put in a block, because
+	// they spend outputs that aren't confirmed yet or
+	// because the block was full. The pool may drop them
+	// after a while.
+	Requeue(context.Context, []*bc.Tx)
+}
+
 // Chain provides a complete, minimal blockchain database. It
 // delegates the underlying storage to other objects, and uses
 // validation logic from package validation to decide what
@@ -104,6 +120,21 @@ type Chain struct {
 	InitialBlockHash  bc.Hash
 	MaxIssuanceWindow time.Duration // only used by generators
 
+	// Checkpoints and FinalityDepth determine which blocks
+	// are final; see FinalHeight. A FinalityDepth of 0 means
+	// only checkpointed blocks are final.
+	Checkpoints   []Checkpoint
+	FinalityDepth uint64
+
+	// MaxClockSkew is how far ahead of the local clock a block's
+	// timestamp may be for ValidateBlockForSig to accept it.
+	// Zero means no limit.
+	MaxClockSkew time.Duration
+
+	// Standard is the standardness policy AddTx applies
+	// to transactions entering the pending pool.
+	Standard standard.Policy
+
 	state struct {
 		cond     sync.Cond // protects height, block, snapshot
 		height   uint64
@@ -129,6 +160,7 @@ type pendingSnapshot struct {
 func NewChain(ctx context.Context, initialBlockHash bc.Hash, store Store, pool Pool, heights <-chan uint64) (*Chain, error) {
 	c := &Chain{
 		InitialBlockHash: initialBlockHash,
+		MaxClockSkew:     DefaultMaxClockSkew,
 		store:            store,
 		pool:             pool,
 		pendingSnapshots: make(chan pendingSnapshot, 1),
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"chain/errors"
+	"chain/log"
 	"chain/protocol/bc"
 	"chain/protocol/state"
 	"chain/protocol/validation"
@@ -32,6 +33,17 @@ func (c *Chain) Recover(ctx context.Context) (*bc.Block, *state.Snapshot, error)
 	if snapshot == nil {
 		snapshot = state.Empty()
 	}
+	if snapshot.Supplies == nil {
+		// The snapshot was stored before asset supplies were
+		// tracked. Rebuild them from the blocks it covers. A core
+		// bootstrapped from such a snapshot lacks those blocks,
+		// and goes without supplies, as does one whose supply
+		// totals overflow.
+		snapshot.Supplies, err = c.rebuildSupplies(ctx, snapshotHeight)
+		if err != nil {
+			log.Error(ctx, errors.Wrap(err, "rebuilding asset supplies"))
+		}
+	}
 
 	// The true height of the blockchain might be higher than the
 	// height at which the state snapshot was taken. Replay all
@@ -72,3 +84,22 @@ func (c *Chain) Recover(ctx context.Context) (*bc.Block, *state.Snapshot, error)
 
 	return b, snapshot, nil
 }
+
+// rebuildSupplies returns the asset supplies
+// of the blockchain through the given height.
+func (c *Chain) rebuildSupplies(ctx context.Context, height uint64) (state.Supplies, error) {
+	supplies := make(state.Supplies)
+	for h := uint64(1); h <= height; h++ {
+		b, err := c.store.GetBlock(ctx, h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting block %d", h)
+		}
+		for _, tx := range b.Transactions {
+			err = supplies.ApplyTx(tx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "block %d", h)
+			}
+		}
+	}
+	return supplies, nil
+}
@@ -56,6 +56,43 @@ func TestRecoverSnapshotNoAdditionalBlocks(t *testing.T) {
 	}
 }
 
+func TestSaveSnapshot(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestChain(t, time.Now())
+	// Wait for the periodic snapshot of the initial block,
+	// so it can't overwrite the one saved below.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, height, err := c.Store().LatestSnapshot(ctx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if height > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the initial snapshot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	makeEmptyBlock(t, c)
+
+	height, err := c.SaveSnapshot(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if height != 2 {
+		t.Errorf("SaveSnapshot() height = %d, want 2", height)
+	}
+	_, got, err := c.Store().LatestSnapshot(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got != 2 {
+		t.Errorf("latest snapshot height = %d, want 2", got)
+	}
+}
+
 func createEmptyBlock(block *bc.Block, snapshot *state.Snapshot) *bc.Block {
 	return &bc.Block{
 		BlockHeader: bc.BlockHeader{
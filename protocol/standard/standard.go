@@ -0,0 +1,83 @@
+// Package standard decides which transactions are standard.
+//
+// Standardness is local policy, not consensus. A generator
+// refuses nonstandard transactions into its pending pool,
+// so they never reach its blocks, but every core accepts
+// nonstandard transactions in blocks made by others.
+package standard
+
+import (
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+var (
+	// ErrNonstandard is returned by Check for a
+	// transaction that violates the Policy.
+	ErrNonstandard = errors.New("nonstandard transaction")
+
+	// ErrDust is returned by Check for a transaction with
+	// an output below the minimum amount for its asset.
+	ErrDust = errors.New("output amount below dust threshold")
+)
+
+// Policy is a set of standardness rules.
+// The zero Policy finds every transaction standard.
+type Policy struct {
+	// MaxWitnessSize, if nonzero, limits the total size,
+	// in bytes, of each input's witness arguments.
+	MaxWitnessSize int
+
+	// KnownPrograms requires issuance programs and output
+	// control programs to follow templates recognized by
+	// vm.ClassifyProgram.
+	KnownPrograms bool
+
+	// MinAmount, if nonzero, is the least amount an output
+	// may have. Smaller outputs, other than retirements,
+	// are dust. MinAmounts overrides it for some assets;
+	// a zero there exempts an asset.
+	MinAmount  uint64
+	MinAmounts map[bc.AssetID]uint64
+}
+
+// minAmount returns the least amount
+// an output of assetID may have.
+func (p *Policy) minAmount(assetID bc.AssetID) uint64 {
+	if min, ok := p.MinAmounts[assetID]; ok {
+		return min
+	}
+	return p.MinAmount
+}
+
+// Check returns ErrNonstandard or ErrDust, with detail
+// naming the rule, if tx violates p.
+func (p *Policy) Check(tx *bc.TxData) error {
+	for i, in := range tx.Inputs {
+		if p.MaxWitnessSize > 0 {
+			var n int
+			for _, arg := range in.Arguments() {
+				n += len(arg)
+			}
+			if n > p.MaxWitnessSize {
+				return errors.WithDetailf(ErrNonstandard, "input %d witness is %d bytes, more than %d", i, n, p.MaxWitnessSize)
+			}
+		}
+		if p.KnownPrograms && in.IsIssuance() {
+			if vm.ClassifyProgram(in.IssuanceProgram()) == vm.ClassNonStandard {
+				return errors.WithDetailf(ErrNonstandard, "input %d issuance program follows no known template", i)
+			}
+		}
+	}
+	for i, out := range tx.Outputs {
+		class := vm.ClassifyProgram(out.ControlProgram)
+		if p.KnownPrograms && class == vm.ClassNonStandard {
+			return errors.WithDetailf(ErrNonstandard, "output %d control program follows no known template", i)
+		}
+		if min := p.minAmount(out.AssetID); out.Amount < min && class != vm.ClassUnspendable {
+			return errors.WithDetailf(ErrDust, "output %d amount %d of asset %s is below the minimum %d", i, out.Amount, out.AssetID, min)
+		}
+	}
+	return nil
+}
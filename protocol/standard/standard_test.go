@@ -0,0 +1,60 @@
+package standard
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+func TestCheck(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multisig, err := vmutil.P2SPMultiSigProgram([]ed25519.PublicKey{pub}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		weird  = []byte{byte(vm.OP_1), byte(vm.OP_1), byte(vm.OP_ADD)}
+		retire = []byte{byte(vm.OP_FAIL)}
+	)
+	tx := func(witness []byte, issuanceProg, outputProg []byte, amount uint64) *bc.TxData {
+		return &bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewIssuanceInput(nil, amount, nil, bc.Hash{}, issuanceProg, [][]byte{witness})},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, amount, outputProg, nil)},
+		}
+	}
+	other := bc.AssetID{1}
+
+	cases := []struct {
+		p    Policy
+		tx   *bc.TxData
+		want error
+	}{
+		{Policy{}, tx(make([]byte, 1000), weird, weird, 1), nil},
+		{Policy{MaxWitnessSize: 100}, tx(make([]byte, 100), multisig, multisig, 1), nil},
+		{Policy{MaxWitnessSize: 100}, tx(make([]byte, 101), multisig, multisig, 1), ErrNonstandard},
+		{Policy{KnownPrograms: true}, tx(nil, multisig, multisig, 1), nil},
+		{Policy{KnownPrograms: true}, tx(nil, multisig, retire, 1), nil},
+		{Policy{KnownPrograms: true}, tx(nil, weird, multisig, 1), ErrNonstandard},
+		{Policy{KnownPrograms: true}, tx(nil, multisig, weird, 1), ErrNonstandard},
+		{Policy{MinAmount: 10}, tx(nil, multisig, multisig, 10), nil},
+		{Policy{MinAmount: 10}, tx(nil, multisig, multisig, 9), ErrDust},
+		{Policy{MinAmount: 10}, tx(nil, multisig, retire, 1), nil},
+		{Policy{MinAmount: 10, MinAmounts: map[bc.AssetID]uint64{{}: 0}}, tx(nil, multisig, multisig, 1), nil},
+		{Policy{MinAmount: 10, MinAmounts: map[bc.AssetID]uint64{other: 0}}, tx(nil, multisig, multisig, 1), ErrDust},
+		{Policy{MinAmounts: map[bc.AssetID]uint64{{}: 100}}, tx(nil, multisig, multisig, 99), ErrDust},
+	}
+	for i, c := range cases {
+		got := c.p.Check(c.tx)
+		if errors.Root(got) != c.want {
+			t.Errorf("case %d: Check = %v want %v", i, got, c.want)
+		}
+	}
+}
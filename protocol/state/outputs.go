@@ -26,6 +26,15 @@ func NewOutput(o bc.TxOutput, p bc.Outpoint) *Output {
 // only includes the output data that is embedded within inputs (ex,
 // excludes reference data).
 func Prevout(in *bc.TxInput) *Output {
+	if si, ok := in.TypedInput.(*bc.SpendInput); ok && in.AssetVersion == bc.ConfidentialAssetVersion {
+		return &Output{
+			Outpoint: si.Outpoint,
+			TxOutput: bc.TxOutput{
+				AssetVersion:     in.AssetVersion,
+				OutputCommitment: si.OutputCommitment,
+			},
+		}
+	}
 	assetAmount := in.AssetAmount()
 	t := bc.NewTxOutput(assetAmount.AssetID, assetAmount.Amount, in.ControlProgram(), nil)
 	return &Output{
@@ -10,11 +10,21 @@ import (
 // at which it should expire from the issuance memory.
 type PriorIssuances map[bc.Hash]uint64
 
+// Activations maps a block version bit to the height of the
+// first block in which the soft fork it signals takes effect.
+type Activations map[uint8]uint64
+
 // Snapshot encompasses a snapshot of entire blockchain state. It
-// consists of a patricia state tree and the issuances memory.
+// consists of a patricia state tree, the issuances memory, the
+// activation heights of soft forks, and the supply of each asset.
+//
+// Supplies is nil in a snapshot stored before supplies were
+// tracked; see Chain.Recover, which rebuilds them.
 type Snapshot struct {
-	Tree      *patricia.Tree
-	Issuances PriorIssuances
+	Tree        *patricia.Tree
+	Issuances   PriorIssuances
+	Activations Activations
+	Supplies    Supplies
 }
 
 // PruneIssuances modifies a Snapshot, removing all issuance hashes
@@ -35,19 +45,31 @@ func Copy(original *Snapshot) *Snapshot {
 	// We already handle it that way in many places (with explicit
 	// calls to Copy to get the right behavior).
 	c := &Snapshot{
-		Tree:      patricia.Copy(original.Tree),
-		Issuances: make(PriorIssuances, len(original.Issuances)),
+		Tree:        patricia.Copy(original.Tree),
+		Issuances:   make(PriorIssuances, len(original.Issuances)),
+		Activations: make(Activations, len(original.Activations)),
 	}
 	for k, v := range original.Issuances {
 		c.Issuances[k] = v
 	}
+	for k, v := range original.Activations {
+		c.Activations[k] = v
+	}
+	if original.Supplies != nil {
+		c.Supplies = make(Supplies, len(original.Supplies))
+		for k, v := range original.Supplies {
+			c.Supplies[k] = v
+		}
+	}
 	return c
 }
 
 // Empty returns an empty state snapshot.
 func Empty() *Snapshot {
 	return &Snapshot{
-		Tree:      new(patricia.Tree),
-		Issuances: make(PriorIssuances),
+		Tree:        new(patricia.Tree),
+		Issuances:   make(PriorIssuances),
+		Activations: make(Activations),
+		Supplies:    make(Supplies),
 	}
 }
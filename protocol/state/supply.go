@@ -0,0 +1,69 @@
+package state
+
+import (
+	"chain/errors"
+	"chain/math/checked"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// AssetSupply holds the cumulative amounts of an asset
+// issued and retired on the blockchain.
+type AssetSupply struct {
+	Issued  uint64
+	Retired uint64
+}
+
+// Circulating returns the amount of the asset
+// that has been issued and not retired.
+func (s AssetSupply) Circulating() uint64 {
+	return s.Issued - s.Retired
+}
+
+// Supplies maps an asset ID to its supply. Unlike the rest of a
+// snapshot, supplies aren't committed to in the block header; they
+// are derived from the blockchain's history and kept for clients.
+type Supplies map[bc.AssetID]AssetSupply
+
+// ApplyTx adds the issuances and retirements in tx to s.
+// An output is a retirement if its control program
+// is unspendable. If a total would exceed 2^64-1, ApplyTx
+// returns checked.ErrOverflow and leaves s unchanged.
+func (s Supplies) ApplyTx(tx *bc.Tx) error {
+	updated := make(Supplies)
+	get := func(assetID bc.AssetID) AssetSupply {
+		if a, ok := updated[assetID]; ok {
+			return a
+		}
+		return s[assetID]
+	}
+	for _, in := range tx.Inputs {
+		if !in.IsIssuance() {
+			continue
+		}
+		assetID := in.AssetID() // calculated for issuances, so grab once
+		a := get(assetID)
+		var ok bool
+		a.Issued, ok = checked.AddUint64(a.Issued, in.Amount())
+		if !ok {
+			return errors.WithDetailf(checked.ErrOverflow, "issued amount of asset %s", assetID)
+		}
+		updated[assetID] = a
+	}
+	for _, out := range tx.Outputs {
+		if !vmutil.IsUnspendable(out.ControlProgram) {
+			continue
+		}
+		a := get(out.AssetID)
+		var ok bool
+		a.Retired, ok = checked.AddUint64(a.Retired, out.Amount)
+		if !ok {
+			return errors.WithDetailf(checked.ErrOverflow, "retired amount of asset %s", out.AssetID)
+		}
+		updated[out.AssetID] = a
+	}
+	for assetID, a := range updated {
+		s[assetID] = a
+	}
+	return nil
+}
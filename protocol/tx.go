@@ -7,16 +7,24 @@ import (
 	"github.com/golang/groupcache/lru"
 
 	"chain/errors"
+	"chain/log"
 	"chain/protocol/bc"
 	"chain/protocol/validation"
 )
 
+// ErrDuplicateIssuance is returned by CheckIssuanceReplay for a
+// transaction with an issuance already confirmed on the blockchain.
+// Unless it's the very transaction that was confirmed, it can
+// never be; it's most likely a replay of an earlier one.
+var ErrDuplicateIssuance = errors.New("duplicate issuance")
+
 // AddTx inserts tx into the set of "pending" transactions available
 // to be included in the next block produced by GenerateBlock. It should
 // only be called by the Generator.
 //
 // It performs context-free validation of the tx, but does not validate
-// against the current state tree.
+// against the current state tree. It refuses transactions that
+// are valid but nonstandard; see c.Standard.
 //
 // It is okay to add the same transaction more than once; subsequent
 // attempts will have no effect and return a nil error. It is also okay
@@ -26,13 +34,16 @@ import (
 // It is an error to call AddTx before the initial block has landed.
 // Use BlockWaiter to guarantee this.
 func (c *Chain) AddTx(ctx context.Context, tx *bc.Tx) error {
+	ctx = log.AddFields(ctx, log.KeyTxHash, tx.Hash)
 	err := c.ValidateTxCached(tx)
-	if err != nil {
-		return errors.Wrap(err, "tx rejected")
+	if err == nil {
+		err = c.checkIssuanceWindow(tx)
+	}
+	if err == nil {
+		err = c.Standard.Check(&tx.TxData)
 	}
-
-	err = c.checkIssuanceWindow(tx)
 	if err != nil {
+		log.Debug(ctx, log.SubsystemValidation, log.KeyMessage, "rejected tx", "reason", err.Error())
 		return errors.Wrap(err, "tx rejected")
 	}
 
@@ -90,3 +101,33 @@ func (c *Chain) checkIssuanceWindow(tx *bc.Tx) error {
 	}
 	return nil
 }
+
+// CheckIssuanceReplay returns ErrDuplicateIssuance if any
+// issuance in tx is among those the current state records as
+// confirmed. Each is recorded until its transaction's maxtime,
+// after which the issuance is invalid anyway.
+//
+// Like the rest of the state, the record is current only in the
+// leader process. Since AddTx accepts a transaction that's already
+// confirmed, it doesn't make this check; callers that know tx wasn't
+// submitted before can use it to reject replays early.
+func (c *Chain) CheckIssuanceReplay(tx *bc.Tx) error {
+	_, snapshot := c.State()
+	if snapshot == nil {
+		return nil
+	}
+	for i, txi := range tx.Inputs {
+		ii, ok := txi.TypedInput.(*bc.IssuanceInput)
+		if !ok || len(ii.Nonce) == 0 {
+			continue
+		}
+		iHash, err := tx.IssuanceHash(i)
+		if err != nil {
+			return err
+		}
+		if expiryMS, ok := snapshot.Issuances[iHash]; ok {
+			return errors.WithDetailf(ErrDuplicateIssuance, "issuance input %d was confirmed in a transaction valid until %d", i, expiryMS)
+		}
+	}
+	return nil
+}
@@ -64,6 +64,41 @@ func TestAddTx(t *testing.T) {
 	}
 }
 
+func TestCheckIssuanceReplay(t *testing.T) {
+	c, b1 := newTestChain(t, time.Now())
+
+	issueTx, asset, dest := issue(t, nil, nil, 1)
+	err := c.CheckIssuanceReplay(issueTx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Record issueTx's issuance as confirmed.
+	snapshot := state.Empty()
+	err = validation.ApplyTx(snapshot, issueTx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c.setState(b1, snapshot)
+
+	// The same issuance, paid to someone else.
+	replay := issueTx.TxData
+	replay.Outputs = []*bc.TxOutput{bc.NewTxOutput(asset.AssetID, 1, []byte{byte(vm.OP_TRUE)}, nil)}
+	asset.sign(t, &replay, 0)
+	err = c.CheckIssuanceReplay(bc.NewTx(replay))
+	if errors.Root(err) != ErrDuplicateIssuance {
+		t.Errorf("CheckIssuanceReplay(replay) = %v want ErrDuplicateIssuance", err)
+	}
+
+	// A new issuance of the same asset.
+	issueTx2, _, _ := issue(t, asset, dest, 1)
+	issueTx2.Inputs[0].TypedInput.(*bc.IssuanceInput).Nonce = []byte{2}
+	err = c.CheckIssuanceReplay(issueTx2)
+	if err != nil {
+		t.Errorf("CheckIssuanceReplay(new issuance) = %v want nil", err)
+	}
+}
+
 func TestAddTxBadMaxIssuanceWindow(t *testing.T) {
 	ctx := context.Background()
 	c, _ := newTestChain(t, time.Now())
@@ -9,6 +9,7 @@ import (
 
 	"golang.org/x/sync/errgroup"
 
+	"chain/encoding/blockchain"
 	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/state"
@@ -25,6 +26,7 @@ var (
 	ErrBadSig       = errors.New("invalid signature script")
 	ErrBadTxRoot    = errors.New("invalid transaction merkle root")
 	ErrBadStateRoot = errors.New("invalid state merkle root")
+	ErrBlockTooBig  = errors.New("block exceeds consensus limits")
 )
 
 // ValidateBlockForAccept performs steps 1 and 2
@@ -34,24 +36,34 @@ var (
 // then calls ValidateBlock.
 func ValidateBlockForAccept(ctx context.Context, snapshot *state.Snapshot, initialBlockHash bc.Hash, prevBlock, block *bc.Block, validateTx func(*bc.Tx) error) error {
 	if prevBlock != nil {
-		ok, err := vm.VerifyBlockHeader(&prevBlock.BlockHeader, block)
-		if err == nil && !ok {
-			err = ErrFalseVMResult
-		}
+		err := CheckBlockSig(&prevBlock.BlockHeader, block)
 		if err != nil {
-			pkScriptStr, _ := vm.Disassemble(prevBlock.ConsensusProgram)
-			witnessStrs := make([]string, 0, len(block.Witness))
-			for _, w := range block.Witness {
-				witnessStrs = append(witnessStrs, hex.EncodeToString(w))
-			}
-			witnessStr := strings.Join(witnessStrs, "; ")
-			return errors.Wrapf(ErrBadSig, "validation failed in script execution in block (program [%s] witness [%s]): %s", pkScriptStr, witnessStr, err)
+			return err
 		}
 	}
 
 	return ValidateBlock(ctx, snapshot, initialBlockHash, prevBlock, block, validateTx)
 }
 
+// CheckBlockSig evaluates prev's consensus program
+// with block's witness.
+func CheckBlockSig(prev *bc.BlockHeader, block *bc.Block) error {
+	ok, err := vm.VerifyBlockHeader(prev, block)
+	if err == nil && !ok {
+		err = ErrFalseVMResult
+	}
+	if err != nil {
+		pkScriptStr, _ := vm.Disassemble(prev.ConsensusProgram)
+		witnessStrs := make([]string, 0, len(block.Witness))
+		for _, w := range block.Witness {
+			witnessStrs = append(witnessStrs, hex.EncodeToString(w))
+		}
+		witnessStr := strings.Join(witnessStrs, "; ")
+		return errors.Wrapf(ErrBadSig, "validation failed in script execution in block (program [%s] witness [%s]): %s", pkScriptStr, witnessStr, err)
+	}
+	return nil
+}
+
 // ValidateBlock performs the "validate block" procedure from the spec,
 // yielding a new state (recorded in the 'snapshot' argument).
 // See $CHAIN/protocol/doc/spec/validation.md#validate-block.
@@ -71,6 +83,7 @@ func ValidateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHa
 		if err != nil {
 			return err
 		}
+		ApplySignals(snapshot, block)
 		snapshot.PruneIssuances(block.TimestampMS)
 
 		// TODO: Check that other block headers are valid.
@@ -114,6 +127,7 @@ func ValidateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHa
 
 // ApplyBlock applies the transactions in the block to the state tree.
 func ApplyBlock(snapshot *state.Snapshot, block *bc.Block) error {
+	ApplySignals(snapshot, block)
 	snapshot.PruneIssuances(block.TimestampMS)
 	for _, tx := range block.Transactions {
 		err := ApplyTx(snapshot, tx)
@@ -124,32 +138,128 @@ func ApplyBlock(snapshot *state.Snapshot, block *bc.Block) error {
 	return nil
 }
 
+// headerChecks are the checks validateBlockHeader
+// and CheckBlockHeader make, in order.
+var headerChecks = []func(prev *bc.BlockHeader, block *bc.Block) error{
+	CheckBlockHeight,
+	CheckPrevHash,
+	CheckTimestamp,
+	CheckBlockLimits,
+	CheckTxRoot,
+	CheckConsensusProgram,
+}
+
 func validateBlockHeader(prev *bc.BlockHeader, block *bc.Block) error {
+	for _, check := range headerChecks {
+		err := check(prev, block)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckBlockHeader makes the same checks of block, following prev,
+// as ValidateBlock does of the block header, but instead of
+// stopping at the first failure, it returns every failure.
+// Prev is nil for the initial block.
+func CheckBlockHeader(prev *bc.BlockHeader, block *bc.Block) []error {
+	var errs []error
+	for _, check := range headerChecks {
+		err := check(prev, block)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// CheckBlockHeight checks that block's height follows prev's,
+// or is 1 if prev is nil.
+func CheckBlockHeight(prev *bc.BlockHeader, block *bc.Block) error {
 	if prev == nil && block.Height != 1 {
 		return ErrBadHeight
 	}
-	if prev != nil {
-		prevHash := prev.Hash()
-		if !bytes.Equal(block.PreviousBlockHash[:], prevHash[:]) {
-			return ErrBadPrevHash
-		}
-		if block.Height != prev.Height+1 {
-			return ErrBadHeight
-		}
-		if block.TimestampMS < prev.TimestampMS {
-			return ErrBadTimestamp
+	if prev != nil && block.Height != prev.Height+1 {
+		return ErrBadHeight
+	}
+	return nil
+}
+
+// CheckPrevHash checks that block commits to prev's hash.
+func CheckPrevHash(prev *bc.BlockHeader, block *bc.Block) error {
+	if prev == nil {
+		return nil
+	}
+	prevHash := prev.Hash()
+	if !bytes.Equal(block.PreviousBlockHash[:], prevHash[:]) {
+		return ErrBadPrevHash
+	}
+	return nil
+}
+
+// CheckTimestamp checks that block is no older than prev.
+func CheckTimestamp(prev *bc.BlockHeader, block *bc.Block) error {
+	if prev != nil && block.TimestampMS < prev.TimestampMS {
+		return ErrBadTimestamp
+	}
+	return nil
+}
+
+// CheckBlockLimits checks block against the limits on
+// its transactions and size in prev's consensus program.
+// See vmutil.BlockLimits.
+func CheckBlockLimits(prev *bc.BlockHeader, block *bc.Block) error {
+	if prev == nil {
+		return nil
+	}
+	limits := vmutil.ParseBlockLimits(prev.ConsensusProgram)
+	if limits.MaxTxs > 0 && uint64(len(block.Transactions)) > limits.MaxTxs {
+		return errors.WithDetailf(ErrBlockTooBig, "%d transactions, more than %d", len(block.Transactions), limits.MaxTxs)
+	}
+	if limits.MaxBytes > 0 {
+		if size := BlockSize(block); size > limits.MaxBytes {
+			return errors.WithDetailf(ErrBlockTooBig, "%d bytes, more than %d", size, limits.MaxBytes)
 		}
 	}
+	return nil
+}
 
-	txMerkleRoot := CalcMerkleRoot(block.Transactions)
+// CheckTxRoot checks block's transactions merkle root.
+func CheckTxRoot(prev *bc.BlockHeader, block *bc.Block) error {
 	// can be modified to allow soft fork
-	if block.TransactionsMerkleRoot != txMerkleRoot {
+	if block.TransactionsMerkleRoot != CalcMerkleRoot(block.Transactions) {
 		return ErrBadTxRoot
 	}
+	return nil
+}
 
+// CheckConsensusProgram checks that block's
+// consensus program is not unspendable.
+func CheckConsensusProgram(prev *bc.BlockHeader, block *bc.Block) error {
 	if vmutil.IsUnspendable(block.ConsensusProgram) {
 		return ErrBadScript
 	}
-
 	return nil
 }
+
+// BlockSize returns the serialized size of block without its
+// witness, the size vmutil.BlockLimits.MaxBytes limits.
+// The witness is left out so the block's generator knows the
+// size before the signers sign it.
+func BlockSize(block *bc.Block) uint64 {
+	var w countWriter
+	block.BlockHeader.WriteForSigTo(&w)
+	blockchain.WriteVarint31(&w, uint64(len(block.Transactions)))
+	for _, tx := range block.Transactions {
+		tx.WriteTo(&w)
+	}
+	return uint64(w)
+}
+
+type countWriter uint64
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	*w += countWriter(len(p))
+	return len(p), nil
+}
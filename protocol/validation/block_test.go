@@ -8,6 +8,7 @@ import (
 	"chain/protocol/bc"
 	"chain/protocol/state"
 	"chain/protocol/vm"
+	"chain/protocol/vmutil"
 )
 
 // emptyMerkleRoot is the SHA3-256 of "".
@@ -108,3 +109,42 @@ func TestValidateBlockHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateBlockLimits(t *testing.T) {
+	txs := []*bc.Tx{
+		bc.NewTx(bc.TxData{Version: 1, MinTime: 1}),
+		bc.NewTx(bc.TxData{Version: 1, MinTime: 2}),
+	}
+	block := func(prev *bc.BlockHeader) *bc.Block {
+		return &bc.Block{
+			BlockHeader: bc.BlockHeader{
+				PreviousBlockHash:      prev.Hash(),
+				Height:                 2,
+				TransactionsMerkleRoot: CalcMerkleRoot(txs),
+			},
+			Transactions: txs,
+		}
+	}
+	size := BlockSize(block(&bc.BlockHeader{Height: 1}))
+
+	cases := []struct {
+		limits vmutil.BlockLimits
+		want   error
+	}{
+		{vmutil.BlockLimits{}, nil},
+		{vmutil.BlockLimits{MaxTxs: 2}, nil},
+		{vmutil.BlockLimits{MaxTxs: 1}, ErrBlockTooBig},
+		{vmutil.BlockLimits{MaxBytes: size}, nil},
+		{vmutil.BlockLimits{MaxBytes: size - 1}, ErrBlockTooBig},
+	}
+	for _, c := range cases {
+		prev := &bc.BlockHeader{
+			Height:           1,
+			ConsensusProgram: vmutil.BlockLimitsProgram(c.limits, []byte{byte(vm.OP_TRUE)}),
+		}
+		got := validateBlockHeader(prev, block(prev))
+		if errors.Root(got) != c.want {
+			t.Errorf("limits %+v: got %v want %v", c.limits, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"chain/crypto/ed25519/confidential"
+	"chain/protocol/bc"
+)
+
+var confidentialAmounts bool
+
+// EnableConfidentialAmounts makes this process check inputs
+// and outputs of asset version bc.ConfidentialAssetVersion,
+// whose amounts are hidden in commitments. It checks the range
+// proof of each such output, and that, for each asset, the
+// commitments of the inputs, less those of the outputs, balance
+// the plaintext amounts.
+//
+// Confidential amounts are experimental and not part of the
+// protocol: cores that don't enable them see only the plaintext
+// amount, zero, of each confidential output, and reject it as a
+// zero-value output. So only enable them on a development network
+// whose cores all do, or those cores will reject the blocks of
+// the rest. Like other unknown asset versions, they can only be
+// used in transactions of version 2 or greater.
+//
+// EnableConfidentialAmounts must be called before any
+// transaction is validated.
+func EnableConfidentialAmounts() {
+	confidentialAmounts = true
+}
+
+// blindedAmounts holds the commitments to the amounts
+// of one asset in a transaction's inputs and outputs.
+type blindedAmounts struct {
+	in, out []confidential.Commitment
+}
+
+func isConfidential(assetVersion uint64) bool {
+	return confidentialAmounts && assetVersion == bc.ConfidentialAssetVersion
+}
+
+func blindedFor(m map[bc.AssetID]*blindedAmounts, assetID bc.AssetID) *blindedAmounts {
+	b := m[assetID]
+	if b == nil {
+		b = new(blindedAmounts)
+		m[assetID] = b
+	}
+	return b
+}
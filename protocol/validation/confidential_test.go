@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519/confidential"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+func TestConfidentialAmounts(t *testing.T) {
+	defer func() { confidentialAmounts = false }()
+
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	assetID := bc.AssetID{1}
+
+	blind := func() confidential.Scalar {
+		s, err := confidential.RandomScalar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	output := func(amount uint64, r confidential.Scalar) *bc.TxOutput {
+		proof, err := confidential.ProveRange(assetID, amount, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := confidential.Commit(assetID, amount, r)
+		return bc.NewConfidentialOutput(assetID, c, proof, trueProg, nil)
+	}
+	spend := func(out *bc.TxOutput) *bc.TxInput {
+		in := bc.NewSpendInput(bc.Hash{1}, 0, nil, assetID, 0, trueProg, nil)
+		in.AssetVersion = bc.ConfidentialAssetVersion
+		in.TypedInput.(*bc.SpendInput).OutputCommitment = out.OutputCommitment
+		return in
+	}
+
+	r1 := blind()
+	r2 := confidential.BalancingBlind(nil, []confidential.Scalar{r1})
+	badProof := output(3, r2)
+	badProof.Confidential.RangeProof[0] ^= 1
+
+	cases := []struct {
+		name    string
+		inputs  []*bc.TxInput
+		outputs []*bc.TxOutput
+		ok      bool
+	}{{
+		name:    "plaintext to confidential",
+		inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, assetID, 10, trueProg, nil)},
+		outputs: []*bc.TxOutput{output(7, r1), output(3, r2)},
+		ok:      true,
+	}, {
+		name:    "plaintext to too much confidential",
+		inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, assetID, 10, trueProg, nil)},
+		outputs: []*bc.TxOutput{output(7, r1), output(4, r2)},
+	}, {
+		name:    "bad range proof",
+		inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, assetID, 10, trueProg, nil)},
+		outputs: []*bc.TxOutput{output(7, r1), badProof},
+	}, {
+		name:    "confidential to plaintext and confidential",
+		inputs:  []*bc.TxInput{spend(output(7, r1))},
+		outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 4, trueProg, nil), output(3, r1)},
+		ok:      true,
+	}, {
+		name:    "confidential to plaintext",
+		inputs:  []*bc.TxInput{spend(output(7, r1))},
+		outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 7, trueProg, nil)},
+	}}
+
+	confidentialAmounts = true
+	for _, c := range cases {
+		tx := bc.NewTx(bc.TxData{Version: 2, Inputs: c.inputs, Outputs: c.outputs})
+		err := CheckTxWellFormed(tx)
+		if c.ok != (err == nil) {
+			t.Errorf("%s: CheckTxWellFormed = %v, want ok %t", c.name, err, c.ok)
+		}
+	}
+
+	tx := bc.NewTx(bc.TxData{Version: 1, Inputs: cases[0].inputs, Outputs: cases[0].outputs})
+	if CheckTxWellFormed(tx) == nil {
+		t.Error("confidential outputs in transaction version 1 passed validation")
+	}
+}
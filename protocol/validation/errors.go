@@ -0,0 +1,85 @@
+package validation
+
+import "chain/errors"
+
+// A Reason is why a transaction is invalid. Every error
+// returned for an invalid transaction has root ErrBadTx, for
+// callers that need only know it's invalid, and carries its
+// Reason as data under the key "reason", for callers that
+// branch on the rule it broke. See ReasonOf.
+//
+// A Reason's value is a stable, machine-readable code; it is
+// what API clients see.
+type Reason string
+
+// Reasons a transaction is invalid. Some carry further data,
+// noted here, naming the part of the transaction at fault.
+const (
+	ErrTxVersion         Reason = "tx_version"         // transaction version unknown in its block
+	ErrNoInputs          Reason = "no_inputs"          // no inputs
+	ErrTooManyInputs     Reason = "too_many_inputs"    // more inputs than fit in an int32
+	ErrTooManyOutputs    Reason = "too_many_outputs"   // more outputs than fit in an int32
+	ErrEmptyNonces       Reason = "empty_nonces"       // every input an issuance with an empty nonce
+	ErrBadTimeRange      Reason = "bad_time_range"     // maxtime before mintime
+	ErrBeforeMintime     Reason = "before_mintime"     // block time before mintime
+	ErrPastMaxtime       Reason = "past_maxtime"       // block time after maxtime
+	ErrUnboundedIssuance Reason = "unbounded_issuance" // input: issuance with nonce but no time window
+	ErrIssuanceWindow    Reason = "issuance_window"    // input: block time outside issuance's window
+	ErrWrongBlockchain   Reason = "wrong_blockchain"   // input: issuance for another blockchain
+	ErrDuplicateIssuance Reason = "duplicate_issuance" // input: issuance already confirmed
+	ErrDoubleSpend       Reason = "double_spend"       // input, outpoint: output spent or never created
+	ErrDuplicateInput    Reason = "duplicate_input"    // input: same commitment as an earlier input
+	ErrAssetVersion      Reason = "asset_version"      // input or output: asset version unknown or unsupported
+	ErrVMVersion         Reason = "vm_version"         // input or output: vm version unknown
+	ErrBadRangeProof     Reason = "bad_range_proof"    // output: confidential amount without a valid range proof
+	ErrZeroValue         Reason = "zero_value"         // output: amount of 0
+	ErrValueOverflow     Reason = "value_overflow"     // input or output: amount or sum too large
+	ErrUnbalanced        Reason = "unbalanced"         // asset_id: inputs and outputs of an asset differ
+	ErrBadWitness        Reason = "bad_witness"        // input: program failed, usually for want of signatures
+)
+
+var reasonText = map[Reason]string{
+	ErrTxVersion:         "unknown transaction version",
+	ErrNoInputs:          "transaction has no inputs",
+	ErrTooManyInputs:     "too many inputs",
+	ErrTooManyOutputs:    "too many outputs",
+	ErrEmptyNonces:       "all inputs are issuances with empty nonces",
+	ErrBadTimeRange:      "maxtime is before mintime",
+	ErrBeforeMintime:     "block time is before transaction mintime",
+	ErrPastMaxtime:       "block time is after transaction maxtime",
+	ErrUnboundedIssuance: "issuance input with unbounded time window",
+	ErrIssuanceWindow:    "block time is outside issuance time window",
+	ErrWrongBlockchain:   "issuance input is for another blockchain",
+	ErrDuplicateIssuance: "duplicate issuance",
+	ErrDoubleSpend:       "input spends an output that is spent or does not exist",
+	ErrDuplicateInput:    "duplicate input",
+	ErrAssetVersion:      "invalid asset version",
+	ErrVMVersion:         "unknown vm version",
+	ErrBadRangeProof:     "invalid range proof",
+	ErrZeroValue:         "output amount is zero",
+	ErrValueOverflow:     "amount overflows",
+	ErrUnbalanced:        "inputs and outputs are not balanced",
+	ErrBadWitness:        "input program failed",
+}
+
+func (r Reason) Error() string {
+	if s, ok := reasonText[r]; ok {
+		return s
+	}
+	return string(r)
+}
+
+// ReasonOf returns the Reason err says a transaction is
+// invalid, or the empty Reason if it says none.
+func ReasonOf(err error) Reason {
+	r, _ := errors.Data(err)["reason"].(Reason)
+	return r
+}
+
+// txError returns an error with root ErrBadTx and detail,
+// and with data giving reason and the items in keyval,
+// such as the index of the input at fault.
+func txError(reason Reason, detail string, keyval ...interface{}) error {
+	err := errors.WithDetail(ErrBadTx, detail)
+	return errors.WithData(err, append([]interface{}{"reason", reason}, keyval...)...)
+}
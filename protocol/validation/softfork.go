@@ -0,0 +1,89 @@
+package validation
+
+import (
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/vm"
+)
+
+// activationDelay is the number of blocks between the first
+// block signaling a soft fork and the first block in which its
+// rules take effect. It gives the cores on the network time to
+// upgrade before blocks that break the new rules become invalid.
+var activationDelay uint64 = 10000
+
+// A Deployment is a change to the consensus rules introduced
+// as a soft fork: a change that only makes rules stricter, so
+// that blocks valid under the new rules are also valid to cores
+// that don't know about them.
+//
+// A generator that knows a deployment signals it by setting
+// Bit in the version of the blocks it makes. The first block
+// to set the bit schedules the deployment's rules to take effect
+// activationDelay blocks later. From then on, input programs
+// run with Flags set.
+type Deployment struct {
+	Name  string
+	Bit   uint8 // 1 through 63; bit 0 is bc.NewBlockVersion
+	Flags vm.Flags
+}
+
+// Deployments lists the soft forks known to this version
+// of Chain Core. Once a bit has been signaled on a blockchain,
+// it must never be reused for another deployment.
+var Deployments []Deployment
+
+// SignalVersion returns the version for a new block on top of
+// the state in snapshot. It sets the bit of every deployment
+// that has not been signaled yet.
+func SignalVersion(snapshot *state.Snapshot) uint64 {
+	version := uint64(bc.NewBlockVersion)
+	for _, d := range Deployments {
+		if _, ok := snapshot.Activations[d.Bit]; !ok {
+			version |= 1 << d.Bit
+		}
+	}
+	return version
+}
+
+// UnknownBits returns the bits set in a block version
+// that signal no deployment in Deployments.
+func UnknownBits(version uint64) uint64 {
+	version &^= bc.NewBlockVersion
+	for _, d := range Deployments {
+		version &^= 1 << d.Bit
+	}
+	return version
+}
+
+// ApplySignals records in snapshot the activation height of
+// each soft fork that block signals for the first time.
+// Bits are recorded whether or not they signal a known
+// deployment, so that all cores agree on the state.
+func ApplySignals(snapshot *state.Snapshot, block *bc.Block) {
+	for bit := uint8(1); bit < 64; bit++ {
+		if block.Version&(1<<bit) == 0 {
+			continue
+		}
+		if _, ok := snapshot.Activations[bit]; ok {
+			continue
+		}
+		if snapshot.Activations == nil {
+			snapshot.Activations = make(state.Activations)
+		}
+		snapshot.Activations[bit] = block.Height + activationDelay
+	}
+}
+
+// ActiveFlags returns the VM flags of the deployments
+// in effect for a block at the given height.
+func ActiveFlags(snapshot *state.Snapshot, height uint64) vm.Flags {
+	var flags vm.Flags
+	for _, d := range Deployments {
+		h, ok := snapshot.Activations[d.Bit]
+		if ok && height >= h {
+			flags |= d.Flags
+		}
+	}
+	return flags
+}
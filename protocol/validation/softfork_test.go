@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/vm"
+)
+
+func TestSoftForkActivation(t *testing.T) {
+	defer func(d []Deployment, delay uint64) {
+		Deployments, activationDelay = d, delay
+	}(Deployments, activationDelay)
+	Deployments = []Deployment{
+		{Name: "a", Bit: 1, Flags: 1 << 0},
+		{Name: "b", Bit: 2, Flags: 1 << 1},
+	}
+	activationDelay = 10
+
+	snapshot := state.Empty()
+	if got, want := SignalVersion(snapshot), uint64(0x7); got != want {
+		t.Errorf("SignalVersion() = %#x want %#x", got, want)
+	}
+
+	// Block 5 signals deployment a and a deployment
+	// unknown to this core.
+	ApplySignals(snapshot, &bc.Block{BlockHeader: bc.BlockHeader{Version: 0x13, Height: 5}})
+	want := state.Activations{1: 15, 4: 15}
+	if !reflect.DeepEqual(snapshot.Activations, want) {
+		t.Errorf("activations = %v want %v", snapshot.Activations, want)
+	}
+	if got, want := SignalVersion(snapshot), uint64(0x5); got != want {
+		t.Errorf("SignalVersion() = %#x want %#x", got, want)
+	}
+	if got, want := UnknownBits(0x13), uint64(0x10); got != want {
+		t.Errorf("UnknownBits(0x13) = %#x want %#x", got, want)
+	}
+
+	// Signaling again doesn't postpone activation.
+	ApplySignals(snapshot, &bc.Block{BlockHeader: bc.BlockHeader{Version: 0x7, Height: 8}})
+	want = state.Activations{1: 15, 2: 18, 4: 15}
+	if !reflect.DeepEqual(snapshot.Activations, want) {
+		t.Errorf("activations = %v want %v", snapshot.Activations, want)
+	}
+
+	cases := []struct {
+		height uint64
+		want   vm.Flags
+	}{
+		{14, 0},
+		{15, 1},
+		{17, 1},
+		{18, 3},
+	}
+	for _, c := range cases {
+		if got := ActiveFlags(snapshot, c.height); got != c.want {
+			t.Errorf("ActiveFlags(%d) = %d want %d", c.height, got, c.want)
+		}
+	}
+}
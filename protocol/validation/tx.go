@@ -3,11 +3,12 @@ package validation
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
 	"math"
 	"strings"
 
+	"chain/crypto/ed25519/confidential"
 	"chain/errors"
-	"chain/math/checked"
 	"chain/protocol/bc"
 	"chain/protocol/state"
 	"chain/protocol/vm"
@@ -33,14 +34,14 @@ var (
 // ConfirmTx must not mutate the snapshot or the block.
 func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Block, tx *bc.Tx) error {
 	if block.Version == 1 && tx.Version != 1 {
-		return errors.WithDetailf(ErrBadTx, "unknown transaction version %d for block version 1", tx.Version)
+		return txError(ErrTxVersion, fmt.Sprintf("unknown transaction version %d for block version 1", tx.Version))
 	}
 
 	if block.TimestampMS < tx.MinTime {
-		return errors.WithDetail(ErrBadTx, "block time is before transaction min time")
+		return txError(ErrBeforeMintime, "block time is before transaction min time")
 	}
 	if tx.MaxTime > 0 && block.TimestampMS > tx.MaxTime {
-		return errors.WithDetail(ErrBadTx, "block time is after transaction max time")
+		return txError(ErrPastMaxtime, "block time is after transaction max time")
 	}
 
 	for i, txin := range tx.Inputs {
@@ -49,20 +50,20 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 				continue
 			}
 			if ii.InitialBlock != initialBlockHash {
-				return errors.WithDetail(ErrBadTx, "issuance is for different blockchain")
+				return txError(ErrWrongBlockchain, fmt.Sprintf("issuance input %d is for blockchain %s, not %s", i, ii.InitialBlock, initialBlockHash), "input", i)
 			}
 			if len(ii.Nonce) == 0 {
 				continue
 			}
 			if block.TimestampMS < tx.MinTime || block.TimestampMS > tx.MaxTime {
-				return errors.WithDetail(ErrBadTx, "timestamp outside issuance input's time window")
+				return txError(ErrIssuanceWindow, "timestamp outside issuance input's time window", "input", i)
 			}
 			iHash, err := tx.IssuanceHash(i)
 			if err != nil {
 				return err
 			}
 			if _, ok2 := snapshot.Issuances[iHash]; ok2 {
-				return errors.WithDetail(ErrBadTx, "duplicate issuance transaction")
+				return txError(ErrDuplicateIssuance, "duplicate issuance transaction", "input", i)
 			}
 			continue
 		}
@@ -72,9 +73,16 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 		// Lookup the prevout in the blockchain state tree.
 		k, val := state.OutputTreeItem(state.Prevout(txin))
 		if !snapshot.Tree.Contains(k, val) {
-			return errors.WithDetailf(ErrBadTx, "output %s for input %d is invalid", txin.Outpoint().String(), i)
+			return txError(ErrDoubleSpend, fmt.Sprintf("output %s for input %d is invalid", txin.Outpoint().String(), i), "input", i, "outpoint", txin.Outpoint().String())
 		}
 	}
+
+	// CheckTxWellFormed runs the input programs under the
+	// original rules. Once a soft fork has activated, they
+	// must also satisfy its stricter rules.
+	if flags := ActiveFlags(snapshot, block.Height); flags != 0 {
+		return verifyInputs(tx, flags)
+	}
 	return nil
 }
 
@@ -85,14 +93,14 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 // - input scripts pass
 //
 // Result is nil for well-formed transactions, ErrBadTx with
-// supporting detail otherwise.
+// supporting detail and a Reason otherwise.
 func CheckTxWellFormed(tx *bc.Tx) error {
 	if len(tx.Inputs) == 0 {
-		return errors.WithDetail(ErrBadTx, "inputs are missing")
+		return txError(ErrNoInputs, "inputs are missing")
 	}
 
 	if len(tx.Inputs) > math.MaxInt32 {
-		return errors.WithDetail(ErrBadTx, "number of inputs overflows uint32")
+		return txError(ErrTooManyInputs, "number of inputs overflows uint32")
 	}
 
 	// Are all inputs issuances, all with asset version 1, and all with empty nonces?
@@ -113,42 +121,54 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 		}
 	}
 	if allIssuancesWithEmptyNonces {
-		return errors.WithDetail(ErrBadTx, "all inputs are issuances with empty nonce fields")
+		return txError(ErrEmptyNonces, "all inputs are issuances with empty nonce fields")
 	}
 
 	// Check that the transaction maximum time is greater than or equal to the
 	// minimum time, if it is greater than 0.
 	if tx.MaxTime > 0 && tx.MaxTime < tx.MinTime {
-		return errors.WithDetail(ErrBadTx, "positive maxtime must be >= mintime")
+		return txError(ErrBadTimeRange, "positive maxtime must be >= mintime")
 	}
 
 	// Check that each input commitment appears only once. Also check that sums
 	// of inputs and outputs balance, and check that both input and output sums
-	// are less than 2^63 so that they don't overflow their int64 representation.
-	parity := make(map[bc.AssetID]int64)
+	// are at most bc.MaxAmount, so that they don't overflow their int64
+	// representation. Confidential amounts are checked separately, by commitment.
+	inSums := make(map[bc.AssetID]uint64)
+	outSums := make(map[bc.AssetID]uint64)
+	blinded := make(map[bc.AssetID]*blindedAmounts)
 	commitments := make(map[string]int)
 
 	for i, txin := range tx.Inputs {
 		if tx.Version == 1 && txin.AssetVersion != 1 {
-			return errors.WithDetailf(ErrBadTx, "unknown asset version %d in input %d for transaction version 1", txin.AssetVersion, i)
+			return txError(ErrAssetVersion, fmt.Sprintf("unknown asset version %d in input %d for transaction version 1", txin.AssetVersion, i), "input", i)
 		}
 
 		assetID := txin.AssetID()
 
-		if txin.Amount() > math.MaxInt64 {
-			return errors.WithDetail(ErrBadTx, "input value exceeds maximum value of int64")
-		}
+		if isConfidential(txin.AssetVersion) {
+			si, ok := txin.TypedInput.(*bc.SpendInput)
+			if !ok || si.Confidential == nil {
+				return txError(ErrAssetVersion, fmt.Sprintf("input %d of asset version %d is not a confidential spend", i, txin.AssetVersion), "input", i)
+			}
+			b := blindedFor(blinded, assetID)
+			b.in = append(b.in, confidential.Commitment(si.Confidential.Commitment))
+		} else {
+			if txin.Amount() > bc.MaxAmount {
+				return txError(ErrValueOverflow, "input value exceeds maximum value of int64", "input", i)
+			}
 
-		sum, ok := checked.AddInt64(parity[assetID], int64(txin.Amount()))
-		if !ok {
-			return errors.WithDetailf(ErrBadTx, "adding input %d overflows the allowed asset amount", i)
+			sum, err := bc.AddAmounts(inSums[assetID], txin.Amount())
+			if err != nil {
+				return txError(ErrValueOverflow, fmt.Sprintf("adding input %d overflows the allowed asset amount", i), "input", i)
+			}
+			inSums[assetID] = sum
 		}
-		parity[assetID] = sum
 
 		switch x := txin.TypedInput.(type) {
 		case *bc.IssuanceInput:
 			if tx.Version == 1 && x.VMVersion != 1 {
-				return errors.WithDetailf(ErrBadTx, "unknown vm version %d in input %d for transaction version 1", x.VMVersion, i)
+				return txError(ErrVMVersion, fmt.Sprintf("unknown vm version %d in input %d for transaction version 1", x.VMVersion, i), "input", i)
 			}
 			if txin.AssetVersion != 1 {
 				continue
@@ -157,66 +177,96 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 				continue
 			}
 			if tx.MinTime == 0 || tx.MaxTime == 0 {
-				return errors.WithDetail(ErrBadTx, "issuance input with unbounded time window")
+				return txError(ErrUnboundedIssuance, "issuance input with unbounded time window", "input", i)
 			}
 		case *bc.SpendInput:
 			if tx.Version == 1 && x.VMVersion != 1 {
-				return errors.WithDetailf(ErrBadTx, "unknown vm version %d in input %d for transaction version 1", x.VMVersion, i)
+				return txError(ErrVMVersion, fmt.Sprintf("unknown vm version %d in input %d for transaction version 1", x.VMVersion, i), "input", i)
 			}
 		}
 
 		buf := new(bytes.Buffer)
 		txin.WriteInputCommitment(buf)
 		if inp, ok := commitments[string(buf.Bytes())]; ok {
-			return errors.WithDetailf(ErrBadTx, "input %d is a duplicate of %d", i, inp)
+			return txError(ErrDuplicateInput, fmt.Sprintf("input %d is a duplicate of %d", i, inp), "input", i)
 		}
 		commitments[string(buf.Bytes())] = i
 	}
 
 	if len(tx.Outputs) > math.MaxInt32 {
-		return errors.WithDetail(ErrBadTx, "number of outputs overflows int32")
+		return txError(ErrTooManyOutputs, "number of outputs overflows int32")
 	}
 
 	// Check that every output has a valid value.
 	for i, txout := range tx.Outputs {
 		if tx.Version == 1 {
 			if txout.AssetVersion != 1 {
-				return errors.WithDetailf(ErrBadTx, "unknown asset version %d in output %d for transaction version 1", txout.AssetVersion, i)
+				return txError(ErrAssetVersion, fmt.Sprintf("unknown asset version %d in output %d for transaction version 1", txout.AssetVersion, i), "output", i)
 			}
 			if txout.VMVersion != 1 {
-				return errors.WithDetailf(ErrBadTx, "unknown vm version %d in output %d for transaction version 1", txout.VMVersion, i)
+				return txError(ErrVMVersion, fmt.Sprintf("unknown vm version %d in output %d for transaction version 1", txout.VMVersion, i), "output", i)
+			}
+		}
+
+		if isConfidential(txout.AssetVersion) {
+			ca := txout.Confidential
+			if ca == nil || !confidential.VerifyRange(txout.AssetID, confidential.Commitment(ca.Commitment), ca.RangeProof) {
+				return txError(ErrBadRangeProof, fmt.Sprintf("output %d has an invalid range proof", i), "output", i)
 			}
+			b := blindedFor(blinded, txout.AssetID)
+			b.out = append(b.out, confidential.Commitment(ca.Commitment))
+			continue
 		}
 
 		// Transactions cannot have zero-value outputs.
 		// If all inputs have zero value, tx therefore must have no outputs.
 		if txout.Amount == 0 {
-			return errors.WithDetail(ErrBadTx, "output value must be greater than 0")
+			return txError(ErrZeroValue, "output value must be greater than 0", "output", i)
 		}
 
-		if txout.Amount > math.MaxInt64 {
-			return errors.WithDetail(ErrBadTx, "output value exceeds maximum value of int64")
+		if txout.Amount > bc.MaxAmount {
+			return txError(ErrValueOverflow, "output value exceeds maximum value of int64", "output", i)
 		}
 
-		sum, ok := checked.SubInt64(parity[txout.AssetID], int64(txout.Amount))
-		if !ok {
-			return errors.WithDetailf(ErrBadTx, "adding output %d overflows the allowed asset amount", i)
+		sum, err := bc.AddAmounts(outSums[txout.AssetID], txout.Amount)
+		if err != nil {
+			return txError(ErrValueOverflow, fmt.Sprintf("adding output %d overflows the allowed asset amount", i), "output", i)
 		}
-		parity[txout.AssetID] = sum
+		outSums[txout.AssetID] = sum
 	}
 
+	// Both sums are at most bc.MaxAmount, so their
+	// difference can't overflow.
+	parity := make(map[bc.AssetID]int64)
+	for asset, sum := range inSums {
+		parity[asset] += int64(sum)
+	}
+	for asset, sum := range outSums {
+		parity[asset] -= int64(sum)
+	}
 	for asset, val := range parity {
-		if val != 0 {
-			return errors.WithDetailf(ErrBadTx, "amounts for asset %s are not balanced on inputs and outputs", asset)
+		if val != 0 && blinded[asset] == nil {
+			return txError(ErrUnbalanced, fmt.Sprintf("amounts for asset %s are not balanced on inputs and outputs", asset), "asset_id", asset)
+		}
+	}
+	for asset, b := range blinded {
+		if !confidential.Balanced(asset, parity[asset], b.in, b.out) {
+			return txError(ErrUnbalanced, fmt.Sprintf("amounts for asset %s are not balanced on inputs and outputs", asset), "asset_id", asset)
 		}
 	}
 
 	if len(tx.Inputs) > math.MaxInt32 {
-		return errors.WithDetail(ErrBadTx, "number of inputs overflows int32")
+		return txError(ErrTooManyInputs, "number of inputs overflows int32")
 	}
 
+	return verifyInputs(tx, 0)
+}
+
+// verifyInputs runs the program of each input of tx
+// under the rules selected by flags.
+func verifyInputs(tx *bc.Tx, flags vm.Flags) error {
 	for i := range tx.Inputs {
-		ok, err := vm.VerifyTxInput(tx, i)
+		ok, err := vm.VerifyTxInputFlags(tx, i, flags)
 		if err == nil && !ok {
 			err = ErrFalseVMResult
 		}
@@ -237,13 +287,14 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 			for _, arg := range args {
 				hexArgs = append(hexArgs, hex.EncodeToString(arg))
 			}
-			return errors.WithDetailf(ErrBadTx, "validation failed in script execution, input %d (program [%s] args [%s]): %s", i, scriptStr, strings.Join(hexArgs, " "), err)
+			return txError(ErrBadWitness, fmt.Sprintf("validation failed in script execution, input %d (program [%s] args [%s]): %s", i, scriptStr, strings.Join(hexArgs, " "), err), "input", i)
 		}
 	}
 	return nil
 }
 
-// ApplyTx updates the state tree with all the changes to the ledger.
+// ApplyTx updates the state tree with all the changes to the ledger,
+// and the asset supplies with its issuances and retirements.
 func ApplyTx(snapshot *state.Snapshot, tx *bc.Tx) error {
 	for i, in := range tx.Inputs {
 		if ii, ok := in.TypedInput.(*bc.IssuanceInput); ok {
@@ -276,5 +327,14 @@ func ApplyTx(snapshot *state.Snapshot, tx *bc.Tx) error {
 			return err
 		}
 	}
+	if snapshot.Supplies != nil {
+		err := snapshot.Supplies.ApplyTx(tx)
+		if err != nil {
+			// Supplies aren't committed to in the block header,
+			// so an overflow doesn't make tx invalid; the
+			// snapshot goes without them instead.
+			snapshot.Supplies = nil
+		}
+	}
 	return nil
 }
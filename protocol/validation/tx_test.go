@@ -2,11 +2,13 @@ package validation
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 	"time"
 
 	"chain/errors"
+	"chain/math/checked"
 	"chain/protocol/bc"
 	"chain/protocol/state"
 	"chain/protocol/vm"
@@ -166,6 +168,67 @@ func TestUniqueIssuance(t *testing.T) {
 	}
 }
 
+func TestApplyTxSupplies(t *testing.T) {
+	var initialBlockHash bc.Hash
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	retireProg := []byte{byte(vm.OP_FAIL)}
+	assetID := bc.ComputeAssetID(trueProg, initialBlockHash, 1)
+	snapshot := state.Empty()
+
+	issue := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewIssuanceInput(nil, 10, nil, initialBlockHash, trueProg, nil)},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 10, trueProg, nil)},
+	})
+	err := ApplyTx(snapshot, issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retire := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(issue.Hash, 0, nil, assetID, 10, trueProg, nil)},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(assetID, 3, retireProg, nil),
+			bc.NewTxOutput(assetID, 7, trueProg, nil),
+		},
+	})
+	err = ApplyTx(snapshot, retire)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := snapshot.Supplies[assetID]
+	want := state.AssetSupply{Issued: 10, Retired: 3}
+	if got != want {
+		t.Errorf("supply = %+v want %+v", got, want)
+	}
+	if got.Circulating() != 7 {
+		t.Errorf("circulating = %d want 7", got.Circulating())
+	}
+
+	// An overflowing total leaves the supplies unchanged...
+	supplies := state.Supplies{assetID: {Issued: math.MaxUint64 - 5}}
+	err = supplies.ApplyTx(issue)
+	if errors.Root(err) != checked.ErrOverflow {
+		t.Errorf("Supplies.ApplyTx(overflow) = %v want %v", err, checked.ErrOverflow)
+	}
+	if got := supplies[assetID]; got.Issued != math.MaxUint64-5 {
+		t.Errorf("after overflow, supply = %+v want unchanged", got)
+	}
+
+	// ...and the snapshot goes without them, since
+	// the transaction is still valid.
+	snapshot = state.Empty()
+	snapshot.Supplies = supplies
+	err = ApplyTx(snapshot, issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Supplies != nil {
+		t.Errorf("after overflow, snapshot supplies = %v want nil", snapshot.Supplies)
+	}
+}
+
 func TestTxWellFormed(t *testing.T) {
 	var initialBlockHash bc.Hash
 	issuanceProg := []byte{1}
@@ -178,11 +241,13 @@ func TestTxWellFormed(t *testing.T) {
 	testCases := []struct {
 		badTx  bool
 		detail string
+		reason Reason
 		tx     bc.TxData
 	}{
 		{
 			badTx:  true,
 			detail: "inputs are missing",
+			reason: ErrNoInputs,
 			tx: bc.TxData{
 				Version: 1,
 			}, // empty
@@ -190,6 +255,7 @@ func TestTxWellFormed(t *testing.T) {
 		{
 			badTx:  true,
 			detail: fmt.Sprintf("amounts for asset %s are not balanced on inputs and outputs", aid1),
+			reason: ErrUnbalanced,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -203,6 +269,7 @@ func TestTxWellFormed(t *testing.T) {
 		{
 			badTx:  true,
 			detail: fmt.Sprintf("amounts for asset %s are not balanced on inputs and outputs", aid2),
+			reason: ErrUnbalanced,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -215,9 +282,44 @@ func TestTxWellFormed(t *testing.T) {
 				},
 			},
 		},
+		{
+			// three outputs of 2^63-1 sum past 2^64, and would
+			// wrap around to balance the inputs
+			badTx:  true,
+			detail: "adding output 1 overflows the allowed asset amount",
+			reason: ErrValueOverflow,
+			tx: bc.TxData{
+				Version: 1,
+				Inputs: []*bc.TxInput{
+					bc.NewSpendInput(txhash1, 0, nil, aid1, bc.MaxAmount-2, nil, nil),
+				},
+				Outputs: []*bc.TxOutput{
+					bc.NewTxOutput(aid1, bc.MaxAmount, nil, nil),
+					bc.NewTxOutput(aid1, bc.MaxAmount, nil, nil),
+					bc.NewTxOutput(aid1, bc.MaxAmount, nil, nil),
+				},
+			},
+		},
+		{
+			badTx:  true,
+			detail: "adding input 1 overflows the allowed asset amount",
+			reason: ErrValueOverflow,
+			tx: bc.TxData{
+				Version: 1,
+				Inputs: []*bc.TxInput{
+					bc.NewSpendInput(txhash1, 0, nil, aid1, bc.MaxAmount, nil, nil),
+					bc.NewSpendInput(txhash2, 0, nil, aid1, bc.MaxAmount, nil, nil),
+					bc.NewSpendInput(txhash2, 1, nil, aid1, 2, nil, nil),
+				},
+				Outputs: []*bc.TxOutput{
+					bc.NewTxOutput(aid1, 1, nil, nil),
+				},
+			},
+		},
 		{
 			badTx:  true,
 			detail: "output value must be greater than 0",
+			reason: ErrZeroValue,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -274,6 +376,7 @@ func TestTxWellFormed(t *testing.T) {
 		{
 			badTx:  true,
 			detail: "positive maxtime must be >= mintime",
+			reason: ErrBadTimeRange,
 			tx: bc.TxData{
 				Version: 1,
 				MinTime: 2,
@@ -520,6 +623,7 @@ func TestTxWellFormed(t *testing.T) {
 			// unknown asset version in tx version 1 is not ok
 			badTx:  true,
 			detail: "unknown asset version",
+			reason: ErrAssetVersion,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -554,6 +658,7 @@ func TestTxWellFormed(t *testing.T) {
 			// unknown asset version in tx version 1 is not ok
 			badTx:  true,
 			detail: "unknown asset version",
+			reason: ErrAssetVersion,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -588,6 +693,7 @@ func TestTxWellFormed(t *testing.T) {
 			// unknown vm version in tx version 1 is not ok
 			badTx:  true,
 			detail: "unknown vm version",
+			reason: ErrVMVersion,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -620,7 +726,8 @@ func TestTxWellFormed(t *testing.T) {
 		},
 		{
 			// unknown vm version in tx version 1 is not ok
-			badTx: true,
+			badTx:  true,
+			reason: ErrVMVersion,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -655,6 +762,7 @@ func TestTxWellFormed(t *testing.T) {
 			// expansion opcodes in tx version 1 are not ok
 			badTx:  true,
 			detail: "disallowed opcode",
+			reason: ErrBadWitness,
 			tx: bc.TxData{
 				Version: 1,
 				Inputs: []*bc.TxInput{
@@ -698,6 +806,9 @@ func TestTxWellFormed(t *testing.T) {
 		if tc.detail != "" && !strings.Contains(errors.Detail(err), tc.detail) {
 			t.Errorf("errors.Detail: got = %s, want = %s", errors.Detail(err), tc.detail)
 		}
+		if tc.reason != "" && ReasonOf(err) != tc.reason {
+			t.Errorf("test %d: ReasonOf(err) = %q, want %q", i, ReasonOf(err), tc.reason)
+		}
 	}
 }
 
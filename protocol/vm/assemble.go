@@ -115,6 +115,22 @@ func Assemble(s string) (res []byte, err error) {
 }
 
 func Disassemble(prog []byte) (string, error) {
+	_, strs, err := disassemble(prog)
+	if err != nil {
+		return "", err
+	}
+	if strs[len(strs)-1] == "" {
+		strs = strs[:len(strs)-1]
+	}
+	return strings.Join(strs, " "), nil
+}
+
+// disassemble parses prog and returns its instructions along with
+// their text. The text of each instruction includes the jump-target
+// label, if any, preceding it. The returned strings have one more
+// element than the instructions: a label for the end of the program,
+// or the empty string.
+func disassemble(prog []byte) ([]Instruction, []string, error) {
 	var (
 		insts []Instruction
 
@@ -126,7 +142,7 @@ func Disassemble(prog []byte) (string, error) {
 	for i := uint32(0); i < uint32(len(prog)); {
 		inst, err := ParseOp(prog, i)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 		switch inst.Op {
 		case OP_JUMP, OP_JUMPIF:
@@ -150,10 +166,6 @@ func Disassemble(prog []byte) (string, error) {
 	)
 
 	for _, inst := range insts {
-		if label, ok := labels[loc]; ok {
-			strs = append(strs, "$"+label)
-		}
-
 		var str string
 		switch inst.Op {
 		case OP_JUMP, OP_JUMPIF:
@@ -166,16 +178,21 @@ func Disassemble(prog []byte) (string, error) {
 				str = inst.Op.String()
 			}
 		}
+		if label, ok := labels[loc]; ok {
+			str = "$" + label + " " + str
+		}
 		strs = append(strs, str)
 
 		loc += inst.Len
 	}
 
+	var end string
 	if label, ok := labels[loc]; ok {
-		strs = append(strs, "$"+label)
+		end = "$" + label
 	}
+	strs = append(strs, end)
 
-	return strings.Join(strs, " "), nil
+	return insts, strs, nil
 }
 
 // split is a bufio.SplitFunc for scanning the input to Compile.
@@ -0,0 +1,223 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProgramClass names a family of control programs
+// recognized by ClassifyProgram.
+type ProgramClass string
+
+const (
+	// ClassNonStandard is any program not matching a known template.
+	ClassNonStandard ProgramClass = "nonstandard"
+
+	// ClassMultiSig is the pay-to-signed-predicate template
+	// used by account control programs and issuance programs.
+	ClassMultiSig ProgramClass = "multisig"
+
+	// ClassBlockMultiSig is the template of consensus programs,
+	// which check signatures on the block hash.
+	ClassBlockMultiSig ProgramClass = "block_multisig"
+
+	// ClassHashLock is a program requiring the preimage
+	// of a hash: <hash op> <hash> EQUAL.
+	ClassHashLock ProgramClass = "hashlock"
+
+	// ClassTimeLock is a standard program guarded by a check
+	// on the transaction's mintime or maxtime.
+	ClassTimeLock ProgramClass = "timelock"
+
+	// ClassUnspendable is a program beginning with FAIL.
+	ClassUnspendable ProgramClass = "unspendable"
+)
+
+// annotation describes the next n instructions of a program.
+type annotation struct {
+	n    int
+	note string
+}
+
+// ClassifyProgram reports which template, if any, prog follows.
+// A standard program preceded by timelock or hash-check guards
+// (ending in VERIFY) is classified by its first guard.
+func ClassifyProgram(prog []byte) ProgramClass {
+	insts, err := ParseProgram(prog)
+	if err != nil {
+		return ClassNonStandard
+	}
+	class, _ := annotate(insts)
+	return class
+}
+
+// DisassembleAnnotated is like Disassemble, but puts each part of
+// a recognized template on its own line, followed by a comment
+// saying what it does. The first line names the program's class.
+func DisassembleAnnotated(prog []byte) (string, error) {
+	insts, strs, err := disassemble(prog)
+	if err != nil {
+		return "", err
+	}
+	class, notes := annotate(insts)
+
+	lines := []string{"# " + string(class)}
+	i := 0
+	for _, a := range notes {
+		lines = append(lines, strings.Join(strs[i:i+a.n], " ")+"  # "+a.note)
+		i += a.n
+	}
+	if rest := strings.TrimSpace(strings.Join(strs[i:], " ")); rest != "" {
+		lines = append(lines, rest)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// annotate classifies insts and describes the parts
+// of it it recognizes, in order.
+func annotate(insts []Instruction) (ProgramClass, []annotation) {
+	var (
+		notes []annotation
+		guard ProgramClass
+	)
+	for {
+		n, class, note := matchGuard(insts)
+		if n == 0 {
+			break
+		}
+		if guard == "" {
+			guard = class
+		}
+		notes = append(notes, annotation{n, note})
+		insts = insts[n:]
+	}
+
+	class, body := matchBody(insts)
+	notes = append(notes, body...)
+	if class == ClassNonStandard || guard == "" {
+		return class, notes
+	}
+	return guard, notes
+}
+
+// matchGuard matches a timelock or hash check at the start of insts.
+// It returns the number of instructions matched, or 0.
+func matchGuard(insts []Instruction) (int, ProgramClass, string) {
+	if len(insts) >= 3 && insts[2].Op == OP_EQUALVERIFY {
+		if note, ok := matchHash(insts[0], insts[1]); ok {
+			return 3, ClassHashLock, note
+		}
+	}
+	if len(insts) < 4 || insts[3].Op != OP_VERIFY {
+		return 0, "", ""
+	}
+	a, b, cmp := insts[0], insts[1], insts[2].Op
+	if cmp == OP_GREATERTHANOREQUAL {
+		// MINTIME t GREATERTHANOREQUAL is t MINTIME LESSTHANOREQUAL
+		a, b, cmp = b, a, OP_LESSTHANOREQUAL
+	}
+	if cmp != OP_LESSTHANOREQUAL {
+		return 0, "", ""
+	}
+	switch {
+	case isPush(a) && b.Op == OP_MINTIME:
+		if t, ok := timestamp(a); ok {
+			return 4, ClassTimeLock, "timelock: spendable from " + t
+		}
+	case a.Op == OP_MAXTIME && isPush(b):
+		if t, ok := timestamp(b); ok {
+			return 4, ClassTimeLock, "timelock: spendable until " + t
+		}
+	}
+	return 0, "", ""
+}
+
+// matchBody matches the whole of insts against a template.
+func matchBody(insts []Instruction) (ProgramClass, []annotation) {
+	l := len(insts)
+	switch {
+	case l > 0 && insts[0].Op == OP_FAIL:
+		return ClassUnspendable, []annotation{{l, "unspendable"}}
+
+	case l == 3 && insts[2].Op == OP_EQUAL:
+		if note, ok := matchHash(insts[0], insts[1]); ok {
+			return ClassHashLock, []annotation{{3, note}}
+		}
+
+	case l >= 7 &&
+		insts[0].Op == OP_DUP && insts[1].Op == OP_TOALTSTACK && insts[2].Op == OP_SHA3 &&
+		insts[l-4].Op == OP_VERIFY && insts[l-3].Op == OP_FROMALTSTACK &&
+		insts[l-2].Op == OP_0 && insts[l-1].Op == OP_CHECKPREDICATE:
+		if keys, ok := matchMultiSig(insts[3:l-4], "signatures on the predicate hash"); ok {
+			notes := []annotation{{3, "hash the signed predicate"}}
+			notes = append(notes, keys...)
+			notes[len(notes)-1].n++ // VERIFY
+			notes = append(notes, annotation{3, "run the signed predicate"})
+			return ClassMultiSig, notes
+		}
+
+	case l >= 4 && insts[0].Op == OP_BLOCKSIGHASH:
+		if keys, ok := matchMultiSig(insts[1:], "block signatures"); ok {
+			return ClassBlockMultiSig, append([]annotation{{1, "hash the block"}}, keys...)
+		}
+	}
+	return ClassNonStandard, nil
+}
+
+// matchMultiSig matches insts against <pubkey>... <m> <n> CHECKMULTISIG.
+func matchMultiSig(insts []Instruction, what string) ([]annotation, bool) {
+	l := len(insts)
+	if l < 4 || insts[l-1].Op != OP_CHECKMULTISIG || !isPush(insts[l-2]) || !isPush(insts[l-3]) {
+		return nil, false
+	}
+	n, err := AsInt64(insts[l-2].Data)
+	if err != nil || n != int64(l-3) {
+		return nil, false
+	}
+	m, err := AsInt64(insts[l-3].Data)
+	if err != nil || m < 1 || m > n {
+		return nil, false
+	}
+	var notes []annotation
+	for i, inst := range insts[:l-3] {
+		if !isPush(inst) || len(inst.Data) != 32 {
+			return nil, false
+		}
+		notes = append(notes, annotation{1, fmt.Sprintf("pubkey %d of %d", i+1, n)})
+	}
+	notes = append(notes, annotation{3, fmt.Sprintf("require %d of %d %s", m, n, what)})
+	return notes, true
+}
+
+var hashSizes = map[Op]int{
+	OP_SHA3:      32,
+	OP_SHA256:    32,
+	OP_RIPEMD160: 20,
+	OP_SHA1:      20,
+}
+
+// matchHash matches <hash op> <hash>.
+func matchHash(op, hash Instruction) (string, bool) {
+	size := hashSizes[op.Op]
+	if size == 0 || !isPush(hash) || len(hash.Data) != size {
+		return "", false
+	}
+	return fmt.Sprintf("hashlock: require the %s preimage of 0x%x", strings.ToLower(op.Op.String()), hash.Data), true
+}
+
+func isPush(inst Instruction) bool {
+	return inst.Op == OP_0 ||
+		(inst.Op >= OP_DATA_1 && inst.Op <= OP_PUSHDATA4) ||
+		(inst.Op >= OP_1 && inst.Op <= OP_16)
+}
+
+// timestamp formats the millisecond time pushed by inst.
+func timestamp(inst Instruction) (string, bool) {
+	ms, err := AsInt64(inst.Data)
+	if err != nil || ms < 0 {
+		return "", false
+	}
+	t := time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+	return t.UTC().Format(time.RFC3339), true
+}
@@ -0,0 +1,82 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyProgram(t *testing.T) {
+	var (
+		key  = "0x" + strings.Repeat("aa", 32)
+		hash = "0x" + strings.Repeat("bb", 32)
+		p2sp = "DUP TOALTSTACK SHA3 " + key + " " + key + " 1 2 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE"
+	)
+	cases := []struct {
+		prog string
+		want ProgramClass
+	}{
+		{p2sp, ClassMultiSig},
+		{"BLOCKSIGHASH " + key + " 1 1 CHECKMULTISIG", ClassBlockMultiSig},
+		{"SHA256 " + hash + " EQUAL", ClassHashLock},
+		{"SHA3 " + hash + " EQUALVERIFY " + p2sp, ClassHashLock},
+		{"1000 MINTIME LESSTHANOREQUAL VERIFY " + p2sp, ClassTimeLock},
+		{"MAXTIME 1000 LESSTHANOREQUAL VERIFY " + p2sp, ClassTimeLock},
+		{"MINTIME 1000 GREATERTHANOREQUAL VERIFY " + p2sp, ClassTimeLock},
+		{"FAIL " + key, ClassUnspendable},
+		{"1000 MINTIME LESSTHANOREQUAL VERIFY 1", ClassNonStandard},
+		{"DUP TOALTSTACK SHA3 " + key + " 2 1 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE", ClassNonStandard},
+		{"RIPEMD160 " + hash + " EQUAL", ClassNonStandard},
+		{"2 3 ADD 5 NUMEQUAL", ClassNonStandard},
+	}
+	for _, c := range cases {
+		prog, err := Assemble(c.prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ClassifyProgram(prog)
+		if got != c.want {
+			t.Errorf("ClassifyProgram(%s) = %s, want %s", c.prog, got, c.want)
+		}
+	}
+
+	got := ClassifyProgram([]byte{byte(OP_DATA_2)})
+	if got != ClassNonStandard {
+		t.Errorf("ClassifyProgram(short program) = %s, want %s", got, ClassNonStandard)
+	}
+}
+
+func TestDisassembleAnnotated(t *testing.T) {
+	key := strings.Repeat("aa", 32)
+	prog, err := Assemble("1000 MINTIME LESSTHANOREQUAL VERIFY DUP TOALTSTACK SHA3 0x" + key + " 1 1 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DisassembleAnnotated(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"# timelock",
+		"0xe803 MINTIME LESSTHANOREQUAL VERIFY  # timelock: spendable from 1970-01-01T00:00:01Z",
+		"DUP TOALTSTACK SHA3  # hash the signed predicate",
+		"0x" + key + "  # pubkey 1 of 1",
+		"0x01 0x01 CHECKMULTISIG VERIFY  # require 1 of 1 signatures on the predicate hash",
+		"FROMALTSTACK FALSE CHECKPREDICATE  # run the signed predicate",
+	}, "\n")
+	if got != want {
+		t.Errorf("DisassembleAnnotated = %q, want %q", got, want)
+	}
+
+	prog, err = Assemble("2 3 ADD 5 NUMEQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = DisassembleAnnotated(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "# nonstandard\n0x02 0x03 ADD 0x05 NUMEQUAL"
+	if got != want {
+		t.Errorf("DisassembleAnnotated = %q, want %q", got, want)
+	}
+}
@@ -68,6 +68,7 @@ func opCheckPredicate(vm *virtualMachine) error {
 		tx:         vm.tx,
 		inputIndex: vm.inputIndex,
 		sigHasher:  vm.sigHasher,
+		flags:      vm.flags,
 	}
 	vm.dataStack = vm.dataStack[:l-n]
 
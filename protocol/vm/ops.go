@@ -458,6 +458,18 @@ func ParseProgram(prog []byte) ([]Instruction, error) {
 
 var isExpansion [256]bool
 
+// Flags select rules that are enforced only after the soft fork
+// introducing them has activated. The zero value selects the
+// rules of VM version 1 as originally specified.
+type Flags uint64
+
+// opFlags holds, for each opcode introduced by a soft fork,
+// the flags that must be set for it to take effect. Until
+// then, it is an expansion opcode and behaves as a NOP.
+// A soft fork may only assign meaning to expansion opcodes,
+// which transactions of version 1 cannot use.
+var opFlags [256]Flags
+
 func init() {
 	for i := 1; i <= 75; i++ {
 		ops[i] = opInfo{Op(i), fmt.Sprintf("DATA_%d", i), opPushdata}
@@ -482,5 +494,8 @@ func init() {
 			ops[i] = opInfo{Op(i), fmt.Sprintf("NOPx%02x", i), opNop}
 			isExpansion[i] = true
 		}
+		if opFlags[i] != 0 {
+			isExpansion[i] = true
+		}
 	}
 }
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+
+	"chain/crypto/sha3pool"
+	"chain/encoding/blockchain"
+	"chain/protocol/bc"
+)
+
+// maxCachedResults is the number of tx input verification
+// results kept by VerifyTxInput. Most transactions are verified
+// once on entering the pool and again in the block that
+// includes them, so this only needs to cover a few blocks' worth
+// of inputs.
+const maxCachedResults = 100000
+
+var results = resultCache{lru: lru.New(maxCachedResults)}
+
+type result struct {
+	ok  bool
+	err error
+}
+
+type resultCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+}
+
+func (c *resultCache) lookup(key bc.Hash) (result, bool) {
+	c.mu.Lock()
+	v, ok := c.lru.Get(key)
+	c.mu.Unlock()
+	if !ok {
+		return result{}, false
+	}
+	return v.(result), true
+}
+
+func (c *resultCache) add(key bc.Hash, r result) {
+	c.mu.Lock()
+	c.lru.Add(key, r)
+	c.mu.Unlock()
+}
+
+// resultKey identifies a script execution by everything that can
+// affect its outcome: the rules it runs under (vmVersion and flags),
+// the program, its arguments, and the input's sighash.
+func resultKey(vmVersion uint64, flags Flags, program []byte, args [][]byte, sighash bc.Hash) (key bc.Hash) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	blockchain.WriteVarint63(h, vmVersion)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(flags))
+	h.Write(buf[:])
+	blockchain.WriteVarstr31(h, program)
+	blockchain.WriteVarint31(h, uint64(len(args)))
+	for _, arg := range args {
+		blockchain.WriteVarstr31(h, arg)
+	}
+	h.Write(sighash[:])
+	h.Read(key[:])
+	return key
+}
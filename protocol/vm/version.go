@@ -0,0 +1,30 @@
+package vm
+
+import "chain/protocol/bc"
+
+// An interpreter evaluates an input's issuance or control
+// program with the input's witness arguments.
+type interpreter func(tx *bc.Tx, inputIndex int, sigHasher *bc.SigHasher, program []byte, args [][]byte, flags Flags) (bool, error)
+
+// interpreters holds the interpreter for each VM version.
+// Each program carries the version of the VM that must run it,
+// so a change to the VM's semantics goes in a new version,
+// leaving existing outputs and issuances with the rules they
+// were created under. A program with a version not listed
+// here fails with ErrUnsupportedVM.
+var interpreters = map[uint64]interpreter{
+	1: runVersion1,
+}
+
+func runVersion1(tx *bc.Tx, inputIndex int, sigHasher *bc.SigHasher, program []byte, args [][]byte, flags Flags) (bool, error) {
+	vm := virtualMachine{
+		tx:         tx,
+		inputIndex: inputIndex,
+		sigHasher:  sigHasher,
+		flags:      flags,
+
+		program:  program,
+		runLimit: initialRunLimit,
+	}
+	return vm.runWithArgs(args)
+}
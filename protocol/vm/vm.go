@@ -32,6 +32,8 @@ type virtualMachine struct {
 	sigHasher  *bc.SigHasher
 
 	block *bc.Block
+
+	flags Flags
 }
 
 // TraceOut - if non-nil - will receive trace output during
@@ -39,48 +41,103 @@ type virtualMachine struct {
 var TraceOut io.Writer
 
 func VerifyTxInput(tx *bc.Tx, inputIndex int) (ok bool, err error) {
+	return VerifyTxInputFlags(tx, inputIndex, 0)
+}
+
+// VerifyTxInputFlags is like VerifyTxInput, but it also
+// enforces the rules selected by flags.
+func VerifyTxInputFlags(tx *bc.Tx, inputIndex int, flags Flags) (ok bool, err error) {
 	defer func() {
 		if panErr := recover(); panErr != nil {
 			ok = false
 			err = ErrUnexpected
 		}
 	}()
-	return verifyTxInput(tx, inputIndex)
+	return verifyTxInput(tx, inputIndex, flags, true)
 }
 
-func verifyTxInput(tx *bc.Tx, inputIndex int) (bool, error) {
+// VerifyTxInputUncached is like VerifyTxInput, but always runs
+// the program, neither consulting nor filling the cache of
+// results. It is for measuring the cost of running programs.
+func VerifyTxInputUncached(tx *bc.Tx, inputIndex int) (ok bool, err error) {
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			ok = false
+			err = ErrUnexpected
+		}
+	}()
+	return verifyTxInput(tx, inputIndex, 0, false)
+}
+
+// verifyTxInput runs the program of the input at inputIndex of tx.
+// If useCache is set, and tracing is off, it looks for the result
+// in the cache first, and adds it there otherwise.
+func verifyTxInput(tx *bc.Tx, inputIndex int, flags Flags, useCache bool) (bool, error) {
 	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
 		return false, ErrBadValue
 	}
 
 	txinput := tx.Inputs[inputIndex]
 
-	var program []byte
+	var (
+		vmVersion uint64
+		program   []byte
+	)
 	switch inp := txinput.TypedInput.(type) {
 	case *bc.IssuanceInput:
-		if inp.VMVersion != 1 {
-			return false, ErrUnsupportedVM
-		}
-		program = inp.IssuanceProgram
+		vmVersion, program = inp.VMVersion, inp.IssuanceProgram
 	case *bc.SpendInput:
-		if inp.VMVersion != 1 {
-			return false, ErrUnsupportedVM
-		}
-		program = inp.ControlProgram
+		vmVersion, program = inp.VMVersion, inp.ControlProgram
 	default:
 		return false, ErrUnsupportedTx
 	}
+	run, ok := interpreters[vmVersion]
+	if !ok {
+		return false, ErrUnsupportedVM
+	}
 
-	vm := virtualMachine{
-		tx:         tx,
-		inputIndex: inputIndex,
-		sigHasher:  bc.NewSigHasher(&tx.TxData),
+	sigHasher := bc.NewSigHasher(&tx.TxData)
+	args := txinput.Arguments()
+
+	// The sighash commits to everything the program can inspect
+	// other than its arguments, so together with the program and
+	// the arguments it determines the result.
+	useCache = useCache && TraceOut == nil
+	var key bc.Hash
+	if useCache {
+		key = resultKey(vmVersion, flags, program, args, sigHasher.Hash(inputIndex))
+		if r, ok := results.lookup(key); ok {
+			return r.ok, r.err
+		}
+	}
 
-		program:  program,
-		runLimit: initialRunLimit,
+	ok, err := run(tx, inputIndex, sigHasher, program, args, flags)
+	if useCache {
+		results.add(key, result{ok, err})
+	}
+	return ok, err
+}
+
+// VerifyPredicate reports whether program, a predicate such
+// as a signature program, succeeds when run with no arguments
+// by version 1 of the VM in the context of the input at
+// inputIndex of tx. A signer uses it to check that a transaction
+// satisfies a predicate before signing.
+func VerifyPredicate(tx *bc.Tx, inputIndex int, program []byte) (ok bool, err error) {
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			ok = false
+			err = ErrUnexpected
+		}
+	}()
+	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
+		return false, ErrBadValue
 	}
+	return runVersion1(tx, inputIndex, bc.NewSigHasher(&tx.TxData), program, nil, 0)
+}
 
-	for _, arg := range txinput.Arguments() {
+func (vm *virtualMachine) runWithArgs(args [][]byte) (bool, error) {
+	for _, arg := range args {
 		err := vm.push(arg, false)
 		if err != nil {
 			return false, err
@@ -153,7 +210,7 @@ func (vm *virtualMachine) step() error {
 
 	vm.deferredCost = 0
 	vm.data = inst.Data
-	err = ops[inst.Op].fn(vm)
+	err = vm.opFunc(inst.Op)(vm)
 	if err != nil {
 		return err
 	}
@@ -183,6 +240,16 @@ func (vm *virtualMachine) isDisallowedOpcode(op Op) bool {
 	return isExpansion[op]
 }
 
+// opFunc returns the function implementing op. An opcode
+// introduced by a soft fork behaves as a NOP until the
+// flag that enables it is set.
+func (vm *virtualMachine) opFunc(op Op) func(*virtualMachine) error {
+	if f := opFlags[op]; f != 0 && vm.flags&f != f {
+		return opNop
+	}
+	return ops[op].fn
+}
+
 func (vm *virtualMachine) push(data []byte, deferred bool) error {
 	cost := 8 + int64(len(data))
 	if deferred {
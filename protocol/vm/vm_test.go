@@ -248,6 +248,149 @@ func TestVerifyTxInput(t *testing.T) {
 	}
 }
 
+func TestVerifyTxInputVersion(t *testing.T) {
+	// Pretend a new VM version has been introduced
+	// that requires its program to equal its argument.
+	var gotVersion2 bool
+	interpreters[2] = func(tx *bc.Tx, inputIndex int, sigHasher *bc.SigHasher, program []byte, args [][]byte, flags Flags) (bool, error) {
+		gotVersion2 = true
+		return len(args) == 1 && bytes.Equal(args[0], program), nil
+	}
+	defer delete(interpreters, 2)
+
+	prog := []byte{byte(OP_TRUE)}
+	in := bc.NewSpendInput(bc.Hash{}, 0, [][]byte{prog}, bc.AssetID{}, 1, prog, nil)
+	in.TypedInput.(*bc.SpendInput).VMVersion = 2
+	tx := bc.NewTx(bc.TxData{Version: 2, Inputs: []*bc.TxInput{in}})
+
+	ok, err := VerifyTxInput(tx, 0)
+	if err != nil || !ok || !gotVersion2 {
+		t.Errorf("VerifyTxInput(version 2) = %v, %v want true, nil from the version 2 interpreter", ok, err)
+	}
+
+	// The same program under version 1 gets version 1 semantics,
+	// where the extra argument leaves a true value on the stack.
+	gotVersion2 = false
+	in.TypedInput.(*bc.SpendInput).VMVersion = 1
+	in.SetArguments([][]byte{{}})
+	tx = bc.NewTx(bc.TxData{Version: 2, Inputs: []*bc.TxInput{in}})
+	ok, err = VerifyTxInput(tx, 0)
+	if err != nil || !ok || gotVersion2 {
+		t.Errorf("VerifyTxInput(version 1) = %v, %v want true, nil from the version 1 interpreter", ok, err)
+	}
+
+	in.TypedInput.(*bc.SpendInput).VMVersion = 3
+	tx = bc.NewTx(bc.TxData{Version: 2, Inputs: []*bc.TxInput{in}})
+	_, err = VerifyTxInput(tx, 0)
+	if err != ErrUnsupportedVM {
+		t.Errorf("VerifyTxInput(version 3) err = %v want %v", err, ErrUnsupportedVM)
+	}
+}
+
+func TestVerifyTxInputCached(t *testing.T) {
+	// Tracing bypasses the cache.
+	oldTraceOut := TraceOut
+	TraceOut = nil
+	defer func() { TraceOut = oldTraceOut }()
+
+	prog := []byte{byte(OP_ADD), byte(OP_5), byte(OP_NUMEQUAL)}
+	tx := bc.NewTx(bc.TxData{
+		Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, [][]byte{{2}, {3}}, bc.AssetID{}, 1, prog, nil)},
+	})
+
+	key := resultKey(1, 0, prog, tx.Inputs[0].Arguments(), tx.HashForSig(0))
+	results.lru.Remove(key)
+
+	ok, err := VerifyTxInputUncached(tx, 0)
+	if err != nil || !ok {
+		t.Fatalf("VerifyTxInputUncached = %v, %v want true, nil", ok, err)
+	}
+	if _, cached := results.lookup(key); cached {
+		t.Fatal("result cached by VerifyTxInputUncached")
+	}
+
+	for i := 0; i < 2; i++ {
+		ok, err := VerifyTxInput(tx, 0)
+		if err != nil || !ok {
+			t.Fatalf("VerifyTxInput (attempt %d) = %v, %v want true, nil", i, ok, err)
+		}
+		if _, cached := results.lookup(key); !cached {
+			t.Fatalf("result not cached after attempt %d", i)
+		}
+	}
+
+	// Different arguments must not hit the cached result.
+	tx.Inputs[0].SetArguments([][]byte{{2}, {2}})
+	ok, err = VerifyTxInput(tx, 0)
+	if err != nil || ok {
+		t.Errorf("VerifyTxInput with new arguments = %v, %v want false, nil", ok, err)
+	}
+}
+
+func TestVerifyTxInputFlags(t *testing.T) {
+	// Pretend a soft fork turns NOPxfe into FAIL.
+	const op = Op(0xfe)
+	const flag = Flags(1 << 3)
+	oldInfo := ops[op]
+	ops[op] = opInfo{op, "TESTFAIL", opFail}
+	opFlags[op] = flag
+	defer func() {
+		ops[op] = oldInfo
+		opFlags[op] = 0
+	}()
+
+	prog := []byte{byte(op), byte(OP_TRUE)}
+	tx := bc.NewTx(bc.TxData{
+		Version: 2,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil)},
+	})
+
+	ok, err := VerifyTxInputFlags(tx, 0, 0)
+	if err != nil || !ok {
+		t.Errorf("VerifyTxInputFlags(0) = %v, %v want true, nil", ok, err)
+	}
+	ok, err = VerifyTxInputFlags(tx, 0, flag)
+	if err != ErrReturn || ok {
+		t.Errorf("VerifyTxInputFlags(%d) = %v, %v want false, %v", flag, ok, err, ErrReturn)
+	}
+
+	// Transactions of version 1 still cannot use the opcode.
+	tx = bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil)},
+	})
+	_, err = VerifyTxInputFlags(tx, 0, flag)
+	if err != ErrDisallowedOpcode {
+		t.Errorf("VerifyTxInputFlags(version 1) err = %v want %v", err, ErrDisallowedOpcode)
+	}
+}
+
+func TestVerifyPredicate(t *testing.T) {
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		MaxTime: 100,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, []byte{byte(OP_FAIL)}, nil)},
+	})
+	cases := []struct {
+		max    byte
+		wantOK bool
+	}{
+		{100, true},
+		{50, false},
+	}
+	for _, c := range cases {
+		prog := []byte{byte(OP_MAXTIME), byte(OP_DATA_1), c.max, byte(OP_LESSTHANOREQUAL)}
+		ok, err := VerifyPredicate(tx, 0, prog)
+		if err != nil || ok != c.wantOK {
+			t.Errorf("VerifyPredicate(MAXTIME <= %d) = %v, %v want %v, nil", c.max, ok, err, c.wantOK)
+		}
+	}
+	_, err := VerifyPredicate(tx, 1, []byte{byte(OP_TRUE)})
+	if err != ErrBadValue {
+		t.Errorf("VerifyPredicate(bad index) err = %v want %v", err, ErrBadValue)
+	}
+}
+
 func TestVerifyBlockHeader(t *testing.T) {
 	block := &bc.Block{
 		BlockHeader: bc.BlockHeader{Witness: [][]byte{{2}, {3}}},
@@ -454,7 +597,7 @@ func TestVerifyTxInputQuickCheck(t *testing.T) {
 		tx := bc.NewTx(bc.TxData{
 			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, witnesses, bc.AssetID{}, 10, program, nil)},
 		})
-		verifyTxInput(tx, 0)
+		verifyTxInput(tx, 0, 0, false)
 		return true
 	}
 	if err := quick.Check(f, nil); err != nil {
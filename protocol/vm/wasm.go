@@ -0,0 +1,245 @@
+package vm
+
+import (
+	"math"
+
+	"golang.org/x/crypto/sha3"
+
+	"chain/crypto/ed25519"
+	"chain/protocol/bc"
+	"chain/protocol/vm/wasm"
+)
+
+// VersionWASM is the experimental VM version whose programs
+// are WebAssembly modules. See EnableWASM.
+const VersionWASM = 2
+
+// A WebAssembly program runs far more instructions than a
+// program for VM version 1 does for the same work, so its run
+// limit, and the cost of each introspection function, is the
+// VM version 1 one multiplied by wasmCostScale.
+const (
+	wasmCostScale = 100
+	wasmRunLimit  = initialRunLimit * wasmCostScale
+)
+
+// EnableWASM makes this process accept programs of VM version
+// VersionWASM, which are WebAssembly modules run by package wasm.
+//
+// A module must export a function named "verify" taking no
+// arguments and returning an i32, which is nonzero if the input
+// is authorized. It gets the input's witness arguments, and what
+// a VM version 1 program can learn about the transaction, from
+// the functions that the host provides in the import module
+// "chain" (see wasmHost.imports).
+//
+// WebAssembly programs are experimental and not part of the
+// protocol: cores with and without them enabled disagree about
+// which transactions are valid, so only enable them on a
+// development network whose cores all do. As with any VM version
+// other than 1, they can only be used in transactions of version
+// 2 or greater.
+//
+// EnableWASM must be called before any input is verified.
+func EnableWASM() {
+	interpreters[VersionWASM] = runWASM
+}
+
+func runWASM(tx *bc.Tx, inputIndex int, sigHasher *bc.SigHasher, program []byte, args [][]byte, flags Flags) (bool, error) {
+	mod, err := wasm.Decode(program)
+	if err != nil {
+		return false, err
+	}
+	h := &wasmHost{tx: tx, inputIndex: inputIndex, sigHasher: sigHasher, args: args}
+	results, _, err := mod.Run(h.imports(), "verify", nil, wasmRunLimit)
+	if err == wasm.ErrRunLimitExceeded {
+		return false, ErrRunLimitExceeded
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(results) != 1 {
+		return false, ErrBadValue
+	}
+	return uint32(results[0]) != 0, nil
+}
+
+type wasmHost struct {
+	tx         *bc.Tx
+	inputIndex int
+	sigHasher  *bc.SigHasher
+	args       [][]byte
+}
+
+func hostFunc(cost int64, params, results []wasm.ValueType, call func(mem *wasm.Memory, args []uint64) ([]uint64, error)) *wasm.HostFunc {
+	return &wasm.HostFunc{
+		Type: wasm.FuncType{Params: params, Results: results},
+		Cost: cost * wasmCostScale,
+		Call: call,
+	}
+}
+
+func vals(types ...wasm.ValueType) []wasm.ValueType { return types }
+
+// imports returns the host functions of the "chain" import module.
+// Those taking a pointer read or write memory at it; 32-byte
+// values such as asset IDs and hashes are written in full.
+//
+//	arg_count() i32                     number of witness arguments
+//	arg_len(i i32) i32                  length of argument i
+//	arg_read(i, ptr i32)                copy argument i to ptr
+//	input_index() i32                   index of the input being verified
+//	input_amount() i64                  amount of the input
+//	input_asset(ptr i32)                asset ID of the input
+//	min_time() i64                      transaction min time
+//	max_time() i64                      transaction max time, or max int64 if unbounded
+//	tx_sighash(ptr i32)                 signature hash of the input
+//	output_count() i32                  number of outputs
+//	output_amount(i i32) i64            amount of output i
+//	output_asset(i, ptr i32)            asset ID of output i
+//	output_vm_version(i i32) i64        VM version of output i
+//	output_program_len(i i32) i32       length of output i's control program
+//	output_program_read(i, ptr i32)     copy output i's control program to ptr
+//	sha3(ptr, len, out i32)             SHA3-256 of len bytes at ptr, written to out
+//	check_sig(msg, msglen, pub, sig i32) i32
+//	                                    1 if the 64-byte ed25519 signature at sig
+//	                                    of msglen bytes at msg verifies with the
+//	                                    32-byte public key at pub, else 0
+//
+// Indexes out of range, like memory accesses out of bounds,
+// fail the program.
+func (h *wasmHost) imports() wasm.Imports {
+	i32, i64 := wasm.I32, wasm.I64
+	input := h.tx.Inputs[h.inputIndex]
+	output := func(i uint64) (*bc.TxOutput, error) {
+		if uint32(i) >= uint32(len(h.tx.Outputs)) {
+			return nil, ErrBadValue
+		}
+		return h.tx.Outputs[uint32(i)], nil
+	}
+	arg := func(i uint64) ([]byte, error) {
+		if uint32(i) >= uint32(len(h.args)) {
+			return nil, ErrBadValue
+		}
+		return h.args[uint32(i)], nil
+	}
+
+	chain := map[string]*wasm.HostFunc{
+		"arg_count": hostFunc(1, nil, vals(i32), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			return []uint64{uint64(len(h.args))}, nil
+		}),
+		"arg_len": hostFunc(1, vals(i32), vals(i32), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			b, err := arg(a[0])
+			return []uint64{uint64(len(b))}, err
+		}),
+		"arg_read": hostFunc(1, vals(i32, i32), nil, func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			b, err := arg(a[0])
+			if err != nil {
+				return nil, err
+			}
+			return nil, mem.Write(uint32(a[1]), b)
+		}),
+		"input_index": hostFunc(1, nil, vals(i32), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			return []uint64{uint64(h.inputIndex)}, nil
+		}),
+		"input_amount": hostFunc(1, nil, vals(i64), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			return []uint64{input.Amount()}, nil
+		}),
+		"input_asset": hostFunc(1, vals(i32), nil, func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			assetID := input.AssetID()
+			return nil, mem.Write(uint32(a[0]), assetID[:])
+		}),
+		"min_time": hostFunc(1, nil, vals(i64), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			return []uint64{h.tx.MinTime}, nil
+		}),
+		"max_time": hostFunc(1, nil, vals(i64), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			maxTime := h.tx.MaxTime
+			if maxTime == 0 || maxTime > math.MaxInt64 {
+				maxTime = uint64(math.MaxInt64)
+			}
+			return []uint64{maxTime}, nil
+		}),
+		"tx_sighash": hostFunc(256, vals(i32), nil, func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			hash := h.sigHasher.Hash(h.inputIndex)
+			return nil, mem.Write(uint32(a[0]), hash[:])
+		}),
+		"output_count": hostFunc(1, nil, vals(i32), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			return []uint64{uint64(len(h.tx.Outputs))}, nil
+		}),
+		"output_amount": hostFunc(1, vals(i32), vals(i64), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			o, err := output(a[0])
+			if err != nil {
+				return nil, err
+			}
+			return []uint64{o.Amount}, nil
+		}),
+		"output_asset": hostFunc(1, vals(i32, i32), nil, func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			o, err := output(a[0])
+			if err != nil {
+				return nil, err
+			}
+			return nil, mem.Write(uint32(a[1]), o.AssetID[:])
+		}),
+		"output_vm_version": hostFunc(1, vals(i32), vals(i64), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			o, err := output(a[0])
+			if err != nil {
+				return nil, err
+			}
+			return []uint64{o.VMVersion}, nil
+		}),
+		"output_program_len": hostFunc(1, vals(i32), vals(i32), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			o, err := output(a[0])
+			if err != nil {
+				return nil, err
+			}
+			return []uint64{uint64(len(o.ControlProgram))}, nil
+		}),
+		"output_program_read": hostFunc(1, vals(i32, i32), nil, func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			o, err := output(a[0])
+			if err != nil {
+				return nil, err
+			}
+			return nil, mem.Write(uint32(a[1]), o.ControlProgram)
+		}),
+		"check_sig": hostFunc(1024, vals(i32, i32, i32, i32), vals(i32), func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+			msg, err := mem.Read(uint32(a[0]), uint32(a[1]))
+			if err != nil {
+				return nil, err
+			}
+			pub, err := mem.Read(uint32(a[2]), ed25519.PublicKeySize)
+			if err != nil {
+				return nil, err
+			}
+			sig, err := mem.Read(uint32(a[3]), ed25519.SignatureSize)
+			if err != nil {
+				return nil, err
+			}
+			var ok uint64
+			if ed25519.Verify(ed25519.PublicKey(pub), msg, sig) {
+				ok = 1
+			}
+			return []uint64{ok}, nil
+		}),
+	}
+
+	// Like SHA3 in VM version 1, hashing costs
+	// at least 64, or 1 per byte.
+	hash := hostFunc(0, vals(i32, i32, i32), nil, func(mem *wasm.Memory, a []uint64) ([]uint64, error) {
+		b, err := mem.Read(uint32(a[0]), uint32(a[1]))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha3.Sum256(b)
+		return nil, mem.Write(uint32(a[2]), sum[:])
+	})
+	hash.ArgCost = func(a []uint64) int64 {
+		n := int64(uint32(a[1]))
+		if n < 64 {
+			n = 64
+		}
+		return n * wasmCostScale
+	}
+	chain["sha3"] = hash
+
+	return wasm.Imports{"chain": chain}
+}
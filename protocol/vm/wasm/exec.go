@@ -0,0 +1,491 @@
+package wasm
+
+import (
+	"encoding/binary"
+
+	"chain/errors"
+)
+
+// Errors returned when running a module.
+var (
+	ErrRunLimitExceeded = errors.New("wasm: run limit exceeded")
+	ErrTrap             = errors.New("wasm: trap")
+	ErrMemoryBounds     = errors.New("wasm: out of bounds memory access")
+)
+
+// memoryPageCost is the run limit cost of each
+// page of memory a module has or grows.
+const memoryPageCost = 1024
+
+// A HostFunc is a function the host provides
+// for modules to import.
+type HostFunc struct {
+	Type FuncType
+
+	// Cost is charged against the run limit for each call,
+	// plus, if ArgCost is set, what it returns for the call's
+	// arguments.
+	Cost    int64
+	ArgCost func(args []uint64) int64
+
+	// Call implements the function. It receives mem,
+	// which is nil if the module has no memory, and an
+	// argument for each of Type's params, and it must
+	// return a result for each of Type's results.
+	Call func(mem *Memory, args []uint64) ([]uint64, error)
+}
+
+// Imports holds host functions by module and name.
+type Imports map[string]map[string]*HostFunc
+
+// Memory is the linear memory of a running module.
+type Memory struct {
+	data     []byte
+	maxPages uint32
+}
+
+// Read returns the n bytes of memory at ptr.
+// The result aliases the memory.
+func (m *Memory) Read(ptr, n uint32) ([]byte, error) {
+	if m == nil || uint64(ptr)+uint64(n) > uint64(len(m.data)) {
+		return nil, ErrMemoryBounds
+	}
+	return m.data[ptr : ptr+n], nil
+}
+
+// Write copies b into memory at ptr.
+func (m *Memory) Write(ptr uint32, b []byte) error {
+	dst, err := m.Read(ptr, uint32(len(b)))
+	if err != nil {
+		return err
+	}
+	copy(dst, b)
+	return nil
+}
+
+// Run instantiates m, resolving its imports from imports,
+// and calls its exported function entry with args. It charges
+// every instruction against runLimit, and returns the function's
+// results and what remains of the run limit.
+func (m *Module) Run(imports Imports, entry string, args []uint64, runLimit int64) ([]uint64, int64, error) {
+	x := &machine{mod: m, runLimit: runLimit}
+	for _, imp := range m.imports {
+		h := imports[imp.module][imp.name]
+		if h == nil {
+			return nil, x.runLimit, errors.WithDetailf(ErrBadModule, "unresolved import %s.%s", imp.module, imp.name)
+		}
+		if !sameType(h.Type, m.types[imp.typ]) {
+			return nil, x.runLimit, errors.WithDetailf(ErrBadModule, "import %s.%s has the wrong type", imp.module, imp.name)
+		}
+		x.host = append(x.host, h)
+	}
+	idx, ok := m.exports[entry]
+	if !ok {
+		return nil, x.runLimit, errors.WithDetailf(ErrBadModule, "no exported function %s", entry)
+	}
+	typ := m.funcType(idx)
+	if len(args) != len(typ.Params) {
+		return nil, x.runLimit, errors.WithDetailf(ErrBadModule, "%s takes %d arguments, not %d", entry, len(typ.Params), len(args))
+	}
+
+	if m.hasMemory {
+		err := x.charge(int64(m.minPages) * memoryPageCost)
+		if err != nil {
+			return nil, x.runLimit, err
+		}
+		x.mem = &Memory{data: make([]byte, m.minPages*pageSize), maxPages: m.maxMem}
+		for _, d := range m.data {
+			copy(x.mem.data[d.offset:], d.init)
+		}
+	}
+	for _, g := range m.globals {
+		x.globals = append(x.globals, g.init)
+	}
+
+	for i, a := range args {
+		x.stack = append(x.stack, mask(typ.Params[i], a))
+	}
+	err := x.call(idx)
+	if err != nil {
+		return nil, x.runLimit, err
+	}
+	return x.stack, x.runLimit, nil
+}
+
+func sameType(a, b FuncType) bool {
+	if len(a.Params) != len(b.Params) || len(a.Results) != len(b.Results) {
+		return false
+	}
+	for i := range a.Params {
+		if a.Params[i] != b.Params[i] {
+			return false
+		}
+	}
+	for i := range a.Results {
+		if a.Results[i] != b.Results[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func mask(t ValueType, v uint64) uint64 {
+	if t == I32 {
+		return uint64(uint32(v))
+	}
+	return v
+}
+
+type machine struct {
+	mod      *Module
+	host     []*HostFunc
+	mem      *Memory
+	globals  []uint64
+	stack    []uint64
+	floor    int // stack height at entry to the current function
+	depth    int
+	runLimit int64
+}
+
+func (x *machine) charge(n int64) error {
+	if n > x.runLimit {
+		x.runLimit = 0
+		return ErrRunLimitExceeded
+	}
+	x.runLimit -= n
+	return nil
+}
+
+func (x *machine) push(v uint64) error {
+	if len(x.stack) >= maxStack {
+		return errors.WithDetail(ErrTrap, "stack overflow")
+	}
+	x.stack = append(x.stack, v)
+	return nil
+}
+
+func (x *machine) pop() (uint64, error) {
+	if len(x.stack) <= x.floor {
+		return 0, errors.WithDetail(ErrTrap, "stack underflow")
+	}
+	v := x.stack[len(x.stack)-1]
+	x.stack = x.stack[:len(x.stack)-1]
+	return v, nil
+}
+
+func (x *machine) call(idx uint32) error {
+	typ := x.mod.funcType(idx)
+	n := len(typ.Params)
+	if len(x.stack)-x.floor < n {
+		return errors.WithDetail(ErrTrap, "stack underflow")
+	}
+	args := make([]uint64, n)
+	copy(args, x.stack[len(x.stack)-n:])
+	x.stack = x.stack[:len(x.stack)-n]
+
+	if int(idx) < len(x.host) {
+		h := x.host[idx]
+		cost := h.Cost
+		if h.ArgCost != nil {
+			cost += h.ArgCost(args)
+		}
+		err := x.charge(cost)
+		if err != nil {
+			return err
+		}
+		results, err := h.Call(x.mem, args)
+		if err != nil {
+			return err
+		}
+		if len(results) != len(typ.Results) {
+			return errors.WithDetailf(ErrTrap, "host function returned %d results", len(results))
+		}
+		for i, v := range results {
+			err = x.push(mask(typ.Results[i], v))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if x.depth >= maxCallDepth {
+		return errors.WithDetail(ErrTrap, "call stack exhausted")
+	}
+	x.depth++
+	oldFloor := x.floor
+	x.floor = len(x.stack)
+	f := x.mod.funcs[int(idx)-len(x.host)]
+	locals := make([]uint64, f.locals)
+	copy(locals, args)
+	err := x.exec(f, len(typ.Results), locals)
+	x.floor = oldFloor
+	x.depth--
+	return err
+}
+
+type label struct {
+	cont   int // index of the instruction before the one to continue at
+	height int // stack height at entry
+	arity  int // number of values a branch carries
+	loop   bool
+}
+
+func (x *machine) exec(f *function, nresults int, locals []uint64) error {
+	code := f.code
+	// The function body is an implicit block
+	// whose end is the last instruction.
+	labels := []label{{cont: len(code) - 1, height: x.floor, arity: nresults}}
+
+	for pc := 0; pc < len(code); pc++ {
+		in := &code[pc]
+		err := x.charge(1)
+		if err != nil {
+			return err
+		}
+
+		switch op := in.op; op {
+		case opUnreachable:
+			return errors.WithDetail(ErrTrap, "unreachable executed")
+		case opNop:
+		case opBlock:
+			labels = append(labels, label{cont: int(in.x), height: len(x.stack), arity: int(in.arity)})
+		case opLoop:
+			labels = append(labels, label{cont: pc, height: len(x.stack), loop: true})
+		case opIf:
+			c, err := x.pop()
+			if err != nil {
+				return err
+			}
+			l := label{cont: int(in.x), height: len(x.stack), arity: int(in.arity)}
+			if uint32(c) != 0 {
+				labels = append(labels, l)
+			} else if in.y != 0 {
+				labels = append(labels, l)
+				pc = int(in.y)
+			} else {
+				pc = int(in.x)
+			}
+		case opElse, opEnd:
+			// Reached the end of a block, or of the
+			// true branch of an if, by falling through.
+			l := labels[len(labels)-1]
+			labels = labels[:len(labels)-1]
+			if len(x.stack) != l.height+l.arity {
+				return errors.WithDetail(ErrTrap, "stack height mismatch at end of block")
+			}
+			if op == opElse {
+				pc = l.cont
+			}
+		case opBr, opBrIf, opBrTable, opReturn:
+			depth := int(in.x)
+			if op == opBrIf {
+				c, err := x.pop()
+				if err != nil {
+					return err
+				}
+				if uint32(c) == 0 {
+					continue
+				}
+			} else if op == opBrTable {
+				i, err := x.pop()
+				if err != nil {
+					return err
+				}
+				if uint32(i) < uint32(len(in.table)) {
+					depth = int(in.table[uint32(i)])
+				}
+			} else if op == opReturn {
+				depth = len(labels) - 1
+			}
+			if depth >= len(labels) {
+				return errors.WithDetail(ErrTrap, "branch out of function")
+			}
+			l := labels[len(labels)-1-depth]
+			if len(x.stack) < l.height+l.arity {
+				return errors.WithDetail(ErrTrap, "stack underflow")
+			}
+			x.stack = append(x.stack[:l.height], x.stack[len(x.stack)-l.arity:]...)
+			if l.loop {
+				labels = labels[:len(labels)-depth]
+			} else {
+				labels = labels[:len(labels)-1-depth]
+			}
+			pc = l.cont
+		case opCall:
+			err = x.call(in.x)
+			if err != nil {
+				return err
+			}
+		case opDrop:
+			_, err = x.pop()
+			if err != nil {
+				return err
+			}
+		case opSelect:
+			c, err := x.pop()
+			if err != nil {
+				return err
+			}
+			b, err := x.pop()
+			if err != nil {
+				return err
+			}
+			a, err := x.pop()
+			if err != nil {
+				return err
+			}
+			if uint32(c) == 0 {
+				a = b
+			}
+			err = x.push(a)
+			if err != nil {
+				return err
+			}
+		case opLocalGet:
+			err = x.push(locals[in.x])
+			if err != nil {
+				return err
+			}
+		case opLocalSet, opLocalTee:
+			v, err := x.pop()
+			if err != nil {
+				return err
+			}
+			locals[in.x] = v
+			if op == opLocalTee {
+				x.stack = append(x.stack, v)
+			}
+		case opGlobalGet:
+			err = x.push(x.globals[in.x])
+			if err != nil {
+				return err
+			}
+		case opGlobalSet:
+			v, err := x.pop()
+			if err != nil {
+				return err
+			}
+			x.globals[in.x] = v
+		case opMemorySize:
+			err = x.push(uint64(len(x.mem.data) / pageSize))
+			if err != nil {
+				return err
+			}
+		case opMemoryGrow:
+			err = x.memoryGrow()
+			if err != nil {
+				return err
+			}
+		case opI32Const, opI64Const:
+			err = x.push(in.imm)
+			if err != nil {
+				return err
+			}
+		default:
+			if size := memOps[op]; size > 0 {
+				err = x.memoryAccess(in, size)
+			} else if f := unops[op]; f != nil {
+				var a uint64
+				a, err = x.pop()
+				if err == nil {
+					x.stack = append(x.stack, f(a))
+				}
+			} else {
+				var a, b uint64
+				b, err = x.pop()
+				if err == nil {
+					a, err = x.pop()
+				}
+				if err == nil {
+					var v uint64
+					v, err = binops[op](a, b)
+					x.stack = append(x.stack, v)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(x.stack) != x.floor+nresults {
+		return errors.WithDetail(ErrTrap, "stack height mismatch at end of function")
+	}
+	return nil
+}
+
+func (x *machine) memoryGrow() error {
+	n, err := x.pop()
+	if err != nil {
+		return err
+	}
+	old := uint32(len(x.mem.data) / pageSize)
+	if uint64(old)+uint64(uint32(n)) > uint64(x.mem.maxPages) {
+		return x.push(uint64(^uint32(0)))
+	}
+	err = x.charge(int64(uint32(n)) * memoryPageCost)
+	if err != nil {
+		return err
+	}
+	x.mem.data = append(x.mem.data, make([]byte, int(uint32(n))*pageSize)...)
+	return x.push(uint64(old))
+}
+
+func (x *machine) memoryAccess(in *instr, size uint32) error {
+	isStore := in.op >= opI32Store
+	var v uint64
+	if isStore {
+		var err error
+		v, err = x.pop()
+		if err != nil {
+			return err
+		}
+	}
+	base, err := x.pop()
+	if err != nil {
+		return err
+	}
+	addr := uint64(uint32(base)) + in.imm
+	if addr+uint64(size) > uint64(len(x.mem.data)) {
+		return ErrMemoryBounds
+	}
+	b := x.mem.data[addr : addr+uint64(size)]
+
+	if isStore {
+		switch size {
+		case 1:
+			b[0] = byte(v)
+		case 2:
+			binary.LittleEndian.PutUint16(b, uint16(v))
+		case 4:
+			binary.LittleEndian.PutUint32(b, uint32(v))
+		case 8:
+			binary.LittleEndian.PutUint64(b, v)
+		}
+		return nil
+	}
+
+	switch in.op {
+	case opI32Load:
+		v = uint64(binary.LittleEndian.Uint32(b))
+	case opI64Load:
+		v = binary.LittleEndian.Uint64(b)
+	case opI32Load8S:
+		v = uint64(uint32(int32(int8(b[0]))))
+	case opI32Load8U, opI64Load8U:
+		v = uint64(b[0])
+	case opI32Load16S:
+		v = uint64(uint32(int32(int16(binary.LittleEndian.Uint16(b)))))
+	case opI32Load16U, opI64Load16U:
+		v = uint64(binary.LittleEndian.Uint16(b))
+	case opI64Load8S:
+		v = uint64(int64(int8(b[0])))
+	case opI64Load16S:
+		v = uint64(int64(int16(binary.LittleEndian.Uint16(b))))
+	case opI64Load32S:
+		v = uint64(int64(int32(binary.LittleEndian.Uint32(b))))
+	case opI64Load32U:
+		v = uint64(binary.LittleEndian.Uint32(b))
+	}
+	return x.push(v)
+}
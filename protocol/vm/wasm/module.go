@@ -0,0 +1,491 @@
+/*
+Package wasm implements a metered interpreter for a deterministic
+subset of WebAssembly, for running contract programs that don't
+fit the stack machine of VM version 1.
+
+The subset is the WebAssembly MVP without floating point, tables,
+indirect calls, start functions or imported memories and globals:
+everything whose behavior could vary between machines, plus what
+a contract has no need for. A module's only link to the outside
+is the functions it imports from its host.
+
+Every instruction executed costs one unit of the run limit given
+to Run, and host functions and memory growth cost more, so a
+module's resource use is bounded no matter what it does.
+*/
+package wasm
+
+import (
+	"chain/errors"
+)
+
+// ValueType is the type of a WebAssembly value.
+// Only the integer types are supported.
+type ValueType byte
+
+const (
+	I32 ValueType = 0x7f
+	I64 ValueType = 0x7e
+)
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params, Results []ValueType
+}
+
+const (
+	pageSize = 1 << 16
+
+	// MaxPages is the most 64KiB pages of memory
+	// a module may have.
+	MaxPages = 16
+
+	maxLocals    = 1024
+	maxCallDepth = 64
+	maxStack     = 1 << 14
+)
+
+// ErrBadModule is returned when decoding a malformed
+// module or one that uses unsupported features.
+var ErrBadModule = errors.New("wasm: invalid module")
+
+// A Module is a decoded WebAssembly module.
+type Module struct {
+	types   []FuncType
+	imports []importedFunc
+	funcs   []*function
+	exports map[string]uint32
+
+	hasMemory        bool
+	minPages, maxMem uint32
+
+	globals []global
+	data    []dataSegment
+}
+
+type importedFunc struct {
+	module, name string
+	typ          uint32
+}
+
+type function struct {
+	typ    uint32
+	locals int // including params
+	code   []instr
+}
+
+type global struct {
+	mutable bool
+	init    uint64
+}
+
+type dataSegment struct {
+	offset uint32
+	init   []byte
+}
+
+const (
+	secCustom   = 0
+	secType     = 1
+	secImport   = 2
+	secFunction = 3
+	secTable    = 4
+	secMemory   = 5
+	secGlobal   = 6
+	secExport   = 7
+	secStart    = 8
+	secElement  = 9
+	secCode     = 10
+	secData     = 11
+)
+
+// Decode decodes and checks a module in the WebAssembly
+// binary format.
+func Decode(b []byte) (*Module, error) {
+	r := &reader{b: b}
+	if string(r.bytes(4)) != "\x00asm" || string(r.bytes(4)) != "\x01\x00\x00\x00" {
+		return nil, errors.WithDetail(ErrBadModule, "bad header")
+	}
+	m := &Module{exports: make(map[string]uint32)}
+	var (
+		last     byte
+		funcDecl []uint32
+		haveCode bool
+	)
+	for r.err == nil && r.len() > 0 {
+		id := r.byte()
+		size := r.u32()
+		if r.err != nil || int(size) > r.len() {
+			return nil, errors.WithDetail(ErrBadModule, "truncated section")
+		}
+		sr := &reader{b: r.bytes(int(size))}
+		if id == secCustom {
+			continue
+		}
+		if id <= last {
+			return nil, errors.WithDetailf(ErrBadModule, "section %d out of order", id)
+		}
+		last = id
+
+		var err error
+		switch id {
+		case secType:
+			err = m.decodeTypes(sr)
+		case secImport:
+			err = m.decodeImports(sr)
+		case secFunction:
+			n := sr.count()
+			for i := 0; i < n && sr.err == nil; i++ {
+				t := sr.u32()
+				if int(t) >= len(m.types) {
+					return nil, errors.WithDetailf(ErrBadModule, "function %d has bad type %d", i, t)
+				}
+				funcDecl = append(funcDecl, t)
+			}
+		case secTable:
+			// Without indirect calls or element segments,
+			// a table can't be used, so it's harmless.
+		case secMemory:
+			err = m.decodeMemory(sr)
+		case secGlobal:
+			err = m.decodeGlobals(sr)
+		case secExport:
+			err = m.decodeExports(sr, len(m.imports)+len(funcDecl))
+		case secCode:
+			err = m.decodeCode(sr, funcDecl)
+			haveCode = true
+		case secData:
+			err = m.decodeData(sr)
+		default:
+			err = errors.WithDetailf(ErrBadModule, "unsupported section %d", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sr.err != nil || sr.len() > 0 {
+			return nil, errors.WithDetailf(ErrBadModule, "malformed section %d", id)
+		}
+	}
+	if r.err != nil {
+		return nil, errors.WithDetail(ErrBadModule, "truncated module")
+	}
+	if len(funcDecl) > 0 && !haveCode {
+		return nil, errors.WithDetail(ErrBadModule, "missing code section")
+	}
+	return m, nil
+}
+
+func (m *Module) decodeTypes(r *reader) error {
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		if r.byte() != 0x60 {
+			return errors.WithDetailf(ErrBadModule, "type %d is not a function type", i)
+		}
+		var ft FuncType
+		var err error
+		ft.Params, err = valueTypes(r)
+		if err != nil {
+			return err
+		}
+		ft.Results, err = valueTypes(r)
+		if err != nil {
+			return err
+		}
+		if len(ft.Results) > 1 {
+			return errors.WithDetailf(ErrBadModule, "type %d has multiple results", i)
+		}
+		m.types = append(m.types, ft)
+	}
+	return nil
+}
+
+func valueTypes(r *reader) ([]ValueType, error) {
+	n := r.count()
+	types := make([]ValueType, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		t := ValueType(r.byte())
+		if t != I32 && t != I64 {
+			return nil, errors.WithDetailf(ErrBadModule, "unsupported value type %#x", byte(t))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func (m *Module) decodeImports(r *reader) error {
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		imp := importedFunc{module: r.name(), name: r.name()}
+		if kind := r.byte(); kind != 0 {
+			return errors.WithDetailf(ErrBadModule, "import %s.%s is not a function", imp.module, imp.name)
+		}
+		imp.typ = r.u32()
+		if int(imp.typ) >= len(m.types) {
+			return errors.WithDetailf(ErrBadModule, "import %s.%s has bad type %d", imp.module, imp.name, imp.typ)
+		}
+		m.imports = append(m.imports, imp)
+	}
+	return nil
+}
+
+func (m *Module) decodeMemory(r *reader) error {
+	n := r.count()
+	if n > 1 {
+		return errors.WithDetail(ErrBadModule, "multiple memories")
+	}
+	if n == 0 {
+		return nil
+	}
+	m.hasMemory = true
+	flags := r.byte()
+	m.minPages = r.u32()
+	m.maxMem = MaxPages
+	switch flags {
+	case 0:
+	case 1:
+		if max := r.u32(); max < m.maxMem {
+			m.maxMem = max
+		}
+	default:
+		return errors.WithDetailf(ErrBadModule, "bad memory limits flags %#x", flags)
+	}
+	if m.minPages > m.maxMem {
+		return errors.WithDetailf(ErrBadModule, "memory of %d pages exceeds limit of %d", m.minPages, m.maxMem)
+	}
+	return nil
+}
+
+func (m *Module) decodeGlobals(r *reader) error {
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		t := ValueType(r.byte())
+		mut := r.byte()
+		if mut > 1 {
+			return errors.WithDetailf(ErrBadModule, "global %d has bad mutability", i)
+		}
+		v, err := constExpr(r, t)
+		if err != nil {
+			return err
+		}
+		m.globals = append(m.globals, global{mutable: mut == 1, init: v})
+	}
+	return nil
+}
+
+// constExpr reads an initializer expression, which
+// must be a single constant of type t.
+func constExpr(r *reader, t ValueType) (uint64, error) {
+	var v uint64
+	switch op := r.byte(); {
+	case op == opI32Const && t == I32:
+		v = uint64(uint32(r.s32()))
+	case op == opI64Const && t == I64:
+		v = uint64(r.s64())
+	default:
+		return 0, errors.WithDetail(ErrBadModule, "unsupported initializer expression")
+	}
+	if r.byte() != opEnd {
+		return 0, errors.WithDetail(ErrBadModule, "unsupported initializer expression")
+	}
+	return v, nil
+}
+
+func (m *Module) decodeExports(r *reader, nfuncs int) error {
+	n := r.count()
+	seen := make(map[string]bool)
+	for i := 0; i < n && r.err == nil; i++ {
+		name := r.name()
+		kind := r.byte()
+		idx := r.u32()
+		if seen[name] {
+			return errors.WithDetailf(ErrBadModule, "duplicate export %s", name)
+		}
+		seen[name] = true
+		// Exported memories and globals are of no use
+		// to the host, so only functions are recorded.
+		if kind == 0 {
+			if int(idx) >= nfuncs {
+				return errors.WithDetailf(ErrBadModule, "export %s has bad function %d", name, idx)
+			}
+			m.exports[name] = idx
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeCode(r *reader, funcDecl []uint32) error {
+	n := r.count()
+	if n != len(funcDecl) {
+		return errors.WithDetailf(ErrBadModule, "%d function bodies for %d functions", n, len(funcDecl))
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		size := r.u32()
+		if r.err != nil || int(size) > r.len() {
+			return errors.WithDetailf(ErrBadModule, "truncated body of function %d", i)
+		}
+		br := &reader{b: r.bytes(int(size))}
+		f := &function{typ: funcDecl[i], locals: len(m.types[funcDecl[i]].Params)}
+		ngroups := br.count()
+		for j := 0; j < ngroups && br.err == nil; j++ {
+			count := br.u32()
+			if t := ValueType(br.byte()); t != I32 && t != I64 {
+				return errors.WithDetailf(ErrBadModule, "unsupported value type %#x", byte(t))
+			}
+			if uint64(f.locals)+uint64(count) > maxLocals {
+				return errors.WithDetailf(ErrBadModule, "function %d has too many locals", i)
+			}
+			f.locals += int(count)
+		}
+		m.funcs = append(m.funcs, f)
+		err := m.compile(br, f)
+		if err != nil {
+			return errors.WithDetailf(err, "function %d", len(m.imports)+i)
+		}
+		if br.err != nil || br.len() > 0 {
+			return errors.WithDetailf(ErrBadModule, "malformed body of function %d", len(m.imports)+i)
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeData(r *reader) error {
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		if r.u32() != 0 {
+			return errors.WithDetailf(ErrBadModule, "unsupported data segment %d", i)
+		}
+		if !m.hasMemory {
+			return errors.WithDetail(ErrBadModule, "data segment without memory")
+		}
+		off, err := constExpr(r, I32)
+		if err != nil {
+			return err
+		}
+		init := r.bytes(r.count())
+		if uint64(off)+uint64(len(init)) > uint64(m.minPages)*pageSize {
+			return errors.WithDetailf(ErrBadModule, "data segment %d out of bounds", i)
+		}
+		m.data = append(m.data, dataSegment{offset: uint32(off), init: init})
+	}
+	return nil
+}
+
+func (m *Module) funcType(idx uint32) FuncType {
+	if int(idx) < len(m.imports) {
+		return m.types[m.imports[idx].typ]
+	}
+	return m.types[m.funcs[int(idx)-len(m.imports)].typ]
+}
+
+// reader reads the parts of a module from b,
+// recording the first error, after which it reads nothing.
+type reader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *reader) len() int { return len(r.b) - r.pos }
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = ErrBadModule
+	}
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || r.len() < 1 {
+		r.fail()
+		return 0
+	}
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.len() < n {
+		r.fail()
+		return nil
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(r.count()))
+}
+
+// count reads the length of a vector whose items each take
+// at least a byte, so it rejects counts exceeding the bytes left.
+func (r *reader) count() int {
+	n := r.u32()
+	if uint64(n) > uint64(r.len()) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) u32() uint32 {
+	return uint32(r.uleb(32))
+}
+
+// uleb reads an unsigned LEB128 number of at most bits bits.
+func (r *reader) uleb(bits uint) uint64 {
+	var v uint64
+	for shift := uint(0); shift < bits; shift += 7 {
+		c := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if bits-shift < 7 && uint64(c&0x7f)>>(bits-shift) != 0 {
+			r.fail()
+			return 0
+		}
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v
+		}
+	}
+	r.fail()
+	return 0
+}
+
+func (r *reader) s32() int32 {
+	v := r.sleb(32)
+	if v < -1<<31 || v > 1<<31-1 {
+		r.fail()
+		return 0
+	}
+	return int32(v)
+}
+
+func (r *reader) s64() int64 {
+	return r.sleb(64)
+}
+
+// sleb reads a signed LEB128 number of at most bits bits.
+func (r *reader) sleb(bits uint) int64 {
+	var v int64
+	for shift := uint(0); shift < bits; shift += 7 {
+		c := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift == 63 && c != 0 && c != 0x7f {
+			r.fail()
+			return 0
+		}
+		v |= int64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			if shift+7 < 64 && c&0x40 != 0 {
+				v |= -1 << (shift + 7)
+			}
+			return v
+		}
+	}
+	r.fail()
+	return 0
+}
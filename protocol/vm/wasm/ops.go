@@ -0,0 +1,358 @@
+package wasm
+
+import "chain/errors"
+
+const (
+	opUnreachable = 0x00
+	opNop         = 0x01
+	opBlock       = 0x02
+	opLoop        = 0x03
+	opIf          = 0x04
+	opElse        = 0x05
+	opEnd         = 0x0b
+	opBr          = 0x0c
+	opBrIf        = 0x0d
+	opBrTable     = 0x0e
+	opReturn      = 0x0f
+	opCall        = 0x10
+	opDrop        = 0x1a
+	opSelect      = 0x1b
+	opLocalGet    = 0x20
+	opLocalSet    = 0x21
+	opLocalTee    = 0x22
+	opGlobalGet   = 0x23
+	opGlobalSet   = 0x24
+	opI32Load     = 0x28
+	opI64Load     = 0x29
+	opI32Load8S   = 0x2c
+	opI32Load8U   = 0x2d
+	opI32Load16S  = 0x2e
+	opI32Load16U  = 0x2f
+	opI64Load8S   = 0x30
+	opI64Load8U   = 0x31
+	opI64Load16S  = 0x32
+	opI64Load16U  = 0x33
+	opI64Load32S  = 0x34
+	opI64Load32U  = 0x35
+	opI32Store    = 0x36
+	opI64Store    = 0x37
+	opI32Store8   = 0x3a
+	opI32Store16  = 0x3b
+	opI64Store8   = 0x3c
+	opI64Store16  = 0x3d
+	opI64Store32  = 0x3e
+	opMemorySize  = 0x3f
+	opMemoryGrow  = 0x40
+	opI32Const    = 0x41
+	opI64Const    = 0x42
+)
+
+// An instr is a decoded instruction.
+type instr struct {
+	op byte
+
+	// For block, loop and if, the number of results.
+	arity byte
+
+	// For block, loop and if, the indexes of the matching end
+	// and else (zero if there's none). For branches, the label
+	// depth; for br_table, the default depth. For calls, locals
+	// and globals, the index.
+	x, y uint32
+
+	// Constants and memory offsets.
+	imm uint64
+
+	table []uint32 // br_table depths
+}
+
+// memOps gives the access size, in bytes,
+// of each load and store instruction.
+var memOps = map[byte]uint32{
+	opI32Load: 4, opI64Load: 8,
+	opI32Load8S: 1, opI32Load8U: 1, opI32Load16S: 2, opI32Load16U: 2,
+	opI64Load8S: 1, opI64Load8U: 1, opI64Load16S: 2, opI64Load16U: 2,
+	opI64Load32S: 4, opI64Load32U: 4,
+	opI32Store: 4, opI64Store: 8, opI32Store8: 1, opI32Store16: 2,
+	opI64Store8: 1, opI64Store16: 2, opI64Store32: 4,
+}
+
+// compile decodes the instructions of f's body from r,
+// checking that blocks nest and that indexes are in range.
+// Operand types are not checked; the interpreter treats
+// every value as 64 bits, so a mistyped module is still
+// deterministic, and the stack is checked as it runs.
+func (m *Module) compile(r *reader, f *function) error {
+	type open struct {
+		idx     int
+		hasElse bool
+	}
+	var ctl []open
+	nfuncs := uint32(len(m.imports) + len(m.funcs))
+	for r.err == nil {
+		in := instr{op: r.byte()}
+		idx := len(f.code)
+		switch op := in.op; {
+		case op == opBlock || op == opLoop || op == opIf:
+			switch bt := r.byte(); ValueType(bt) {
+			case 0x40:
+			case I32, I64:
+				in.arity = 1
+			default:
+				return errors.WithDetailf(ErrBadModule, "unsupported block type %#x", bt)
+			}
+			ctl = append(ctl, open{idx: idx})
+		case op == opElse:
+			if len(ctl) == 0 || f.code[ctl[len(ctl)-1].idx].op != opIf || ctl[len(ctl)-1].hasElse {
+				return errors.WithDetail(ErrBadModule, "else outside if")
+			}
+			ctl[len(ctl)-1].hasElse = true
+			f.code[ctl[len(ctl)-1].idx].y = uint32(idx)
+		case op == opEnd:
+			if len(ctl) == 0 {
+				f.code = append(f.code, in)
+				return nil
+			}
+			o := ctl[len(ctl)-1]
+			ctl = ctl[:len(ctl)-1]
+			start := &f.code[o.idx]
+			start.x = uint32(idx)
+			if start.op == opIf && start.arity > 0 && !o.hasElse {
+				return errors.WithDetail(ErrBadModule, "if with a result has no else")
+			}
+		case op == opBr || op == opBrIf:
+			in.x = r.u32()
+			if int(in.x) > len(ctl) {
+				return errors.WithDetailf(ErrBadModule, "bad label %d", in.x)
+			}
+		case op == opBrTable:
+			n := r.count()
+			in.table = make([]uint32, n)
+			for i := range in.table {
+				in.table[i] = r.u32()
+				if int(in.table[i]) > len(ctl) {
+					return errors.WithDetailf(ErrBadModule, "bad label %d", in.table[i])
+				}
+			}
+			in.x = r.u32()
+			if int(in.x) > len(ctl) {
+				return errors.WithDetailf(ErrBadModule, "bad label %d", in.x)
+			}
+		case op == opCall:
+			in.x = r.u32()
+			if in.x >= nfuncs {
+				return errors.WithDetailf(ErrBadModule, "bad function %d", in.x)
+			}
+		case op == opLocalGet || op == opLocalSet || op == opLocalTee:
+			in.x = r.u32()
+			if int(in.x) >= f.locals {
+				return errors.WithDetailf(ErrBadModule, "bad local %d", in.x)
+			}
+		case op == opGlobalGet || op == opGlobalSet:
+			in.x = r.u32()
+			if int(in.x) >= len(m.globals) {
+				return errors.WithDetailf(ErrBadModule, "bad global %d", in.x)
+			}
+			if op == opGlobalSet && !m.globals[in.x].mutable {
+				return errors.WithDetailf(ErrBadModule, "global %d is immutable", in.x)
+			}
+		case memOps[op] > 0:
+			if !m.hasMemory {
+				return errors.WithDetail(ErrBadModule, "memory access without memory")
+			}
+			r.u32() // alignment hint
+			in.imm = uint64(r.u32())
+		case op == opMemorySize || op == opMemoryGrow:
+			if !m.hasMemory {
+				return errors.WithDetail(ErrBadModule, "memory access without memory")
+			}
+			if r.byte() != 0 {
+				return errors.WithDetail(ErrBadModule, "bad memory index")
+			}
+		case op == opI32Const:
+			in.imm = uint64(uint32(r.s32()))
+		case op == opI64Const:
+			in.imm = uint64(r.s64())
+		case op == opUnreachable || op == opNop || op == opReturn || op == opDrop || op == opSelect:
+		case unops[op] != nil || binops[op] != nil:
+		default:
+			return errors.WithDetailf(ErrBadModule, "unsupported opcode %#x", op)
+		}
+		f.code = append(f.code, in)
+	}
+	return errors.WithDetail(ErrBadModule, "unterminated function body")
+}
+
+// ErrDivZero is returned by integer division by zero.
+var ErrDivZero = errors.New("wasm: integer divide by zero")
+
+// ErrOverflow is returned by signed division of the
+// most negative integer by -1.
+var ErrOverflow = errors.New("wasm: integer overflow")
+
+// unops and binops implement the numeric instructions.
+// Values of type i32 are held in the low 32 bits of their
+// uint64, and comparisons produce 1 for true and 0 for false.
+var (
+	unops  [256]func(a uint64) uint64
+	binops [256]func(a, b uint64) (uint64, error)
+)
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func clz(a uint64) int {
+	n := 0
+	for ; n < 64 && a&(1<<63) == 0; n++ {
+		a <<= 1
+	}
+	return n
+}
+
+func ctz(a uint64) int {
+	n := 0
+	for ; n < 64 && a&1 == 0; n++ {
+		a >>= 1
+	}
+	return n
+}
+
+func popcnt(a uint64) int {
+	n := 0
+	for ; a != 0; a &= a - 1 {
+		n++
+	}
+	return n
+}
+
+func init() {
+	// i32
+	unops[0x45] = func(a uint64) uint64 { return b2u(uint32(a) == 0) }
+	binops[0x46] = func(a, b uint64) (uint64, error) { return b2u(uint32(a) == uint32(b)), nil }
+	binops[0x47] = func(a, b uint64) (uint64, error) { return b2u(uint32(a) != uint32(b)), nil }
+	binops[0x48] = func(a, b uint64) (uint64, error) { return b2u(int32(a) < int32(b)), nil }
+	binops[0x49] = func(a, b uint64) (uint64, error) { return b2u(uint32(a) < uint32(b)), nil }
+	binops[0x4a] = func(a, b uint64) (uint64, error) { return b2u(int32(a) > int32(b)), nil }
+	binops[0x4b] = func(a, b uint64) (uint64, error) { return b2u(uint32(a) > uint32(b)), nil }
+	binops[0x4c] = func(a, b uint64) (uint64, error) { return b2u(int32(a) <= int32(b)), nil }
+	binops[0x4d] = func(a, b uint64) (uint64, error) { return b2u(uint32(a) <= uint32(b)), nil }
+	binops[0x4e] = func(a, b uint64) (uint64, error) { return b2u(int32(a) >= int32(b)), nil }
+	binops[0x4f] = func(a, b uint64) (uint64, error) { return b2u(uint32(a) >= uint32(b)), nil }
+
+	// i64
+	unops[0x50] = func(a uint64) uint64 { return b2u(a == 0) }
+	binops[0x51] = func(a, b uint64) (uint64, error) { return b2u(a == b), nil }
+	binops[0x52] = func(a, b uint64) (uint64, error) { return b2u(a != b), nil }
+	binops[0x53] = func(a, b uint64) (uint64, error) { return b2u(int64(a) < int64(b)), nil }
+	binops[0x54] = func(a, b uint64) (uint64, error) { return b2u(a < b), nil }
+	binops[0x55] = func(a, b uint64) (uint64, error) { return b2u(int64(a) > int64(b)), nil }
+	binops[0x56] = func(a, b uint64) (uint64, error) { return b2u(a > b), nil }
+	binops[0x57] = func(a, b uint64) (uint64, error) { return b2u(int64(a) <= int64(b)), nil }
+	binops[0x58] = func(a, b uint64) (uint64, error) { return b2u(a <= b), nil }
+	binops[0x59] = func(a, b uint64) (uint64, error) { return b2u(int64(a) >= int64(b)), nil }
+	binops[0x5a] = func(a, b uint64) (uint64, error) { return b2u(a >= b), nil }
+
+	unops[0x67] = func(a uint64) uint64 { return uint64(clz(a<<32 | 1<<31)) }
+	unops[0x68] = func(a uint64) uint64 { return uint64(ctz(uint64(uint32(a)) | 1<<32)) }
+	unops[0x69] = func(a uint64) uint64 { return uint64(popcnt(uint64(uint32(a)))) }
+	binops[0x6a] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) + uint32(b)), nil }
+	binops[0x6b] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) - uint32(b)), nil }
+	binops[0x6c] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) * uint32(b)), nil }
+	binops[0x6d] = func(a, b uint64) (uint64, error) {
+		if int32(b) == 0 {
+			return 0, ErrDivZero
+		}
+		if int32(a) == -1<<31 && int32(b) == -1 {
+			return 0, ErrOverflow
+		}
+		return uint64(uint32(int32(a) / int32(b))), nil
+	}
+	binops[0x6e] = func(a, b uint64) (uint64, error) {
+		if uint32(b) == 0 {
+			return 0, ErrDivZero
+		}
+		return uint64(uint32(a) / uint32(b)), nil
+	}
+	binops[0x6f] = func(a, b uint64) (uint64, error) {
+		if int32(b) == 0 {
+			return 0, ErrDivZero
+		}
+		if int32(b) == -1 {
+			return 0, nil
+		}
+		return uint64(uint32(int32(a) % int32(b))), nil
+	}
+	binops[0x70] = func(a, b uint64) (uint64, error) {
+		if uint32(b) == 0 {
+			return 0, ErrDivZero
+		}
+		return uint64(uint32(a) % uint32(b)), nil
+	}
+	binops[0x71] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) & uint32(b)), nil }
+	binops[0x72] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) | uint32(b)), nil }
+	binops[0x73] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) ^ uint32(b)), nil }
+	binops[0x74] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) << (b & 31)), nil }
+	binops[0x75] = func(a, b uint64) (uint64, error) { return uint64(uint32(int32(a) >> (b & 31))), nil }
+	binops[0x76] = func(a, b uint64) (uint64, error) { return uint64(uint32(a) >> (b & 31)), nil }
+	binops[0x77] = func(a, b uint64) (uint64, error) { return uint64(uint32(a)<<(b&31) | uint32(a)>>(32-b&31)), nil }
+	binops[0x78] = func(a, b uint64) (uint64, error) { return uint64(uint32(a)>>(b&31) | uint32(a)<<(32-b&31)), nil }
+
+	unops[0x79] = func(a uint64) uint64 { return uint64(clz(a)) }
+	unops[0x7a] = func(a uint64) uint64 { return uint64(ctz(a)) }
+	unops[0x7b] = func(a uint64) uint64 { return uint64(popcnt(a)) }
+	binops[0x7c] = func(a, b uint64) (uint64, error) { return a + b, nil }
+	binops[0x7d] = func(a, b uint64) (uint64, error) { return a - b, nil }
+	binops[0x7e] = func(a, b uint64) (uint64, error) { return a * b, nil }
+	binops[0x7f] = func(a, b uint64) (uint64, error) {
+		if b == 0 {
+			return 0, ErrDivZero
+		}
+		if int64(a) == -1<<63 && int64(b) == -1 {
+			return 0, ErrOverflow
+		}
+		return uint64(int64(a) / int64(b)), nil
+	}
+	binops[0x80] = func(a, b uint64) (uint64, error) {
+		if b == 0 {
+			return 0, ErrDivZero
+		}
+		return a / b, nil
+	}
+	binops[0x81] = func(a, b uint64) (uint64, error) {
+		if b == 0 {
+			return 0, ErrDivZero
+		}
+		if int64(b) == -1 {
+			return 0, nil
+		}
+		return uint64(int64(a) % int64(b)), nil
+	}
+	binops[0x82] = func(a, b uint64) (uint64, error) {
+		if b == 0 {
+			return 0, ErrDivZero
+		}
+		return a % b, nil
+	}
+	binops[0x83] = func(a, b uint64) (uint64, error) { return a & b, nil }
+	binops[0x84] = func(a, b uint64) (uint64, error) { return a | b, nil }
+	binops[0x85] = func(a, b uint64) (uint64, error) { return a ^ b, nil }
+	binops[0x86] = func(a, b uint64) (uint64, error) { return a << (b & 63), nil }
+	binops[0x87] = func(a, b uint64) (uint64, error) { return uint64(int64(a) >> (b & 63)), nil }
+	binops[0x88] = func(a, b uint64) (uint64, error) { return a >> (b & 63), nil }
+	binops[0x89] = func(a, b uint64) (uint64, error) { return a<<(b&63) | a>>(64-b&63), nil }
+	binops[0x8a] = func(a, b uint64) (uint64, error) { return a>>(b&63) | a<<(64-b&63), nil }
+
+	// conversions
+	unops[0xa7] = func(a uint64) uint64 { return uint64(uint32(a)) }
+	unops[0xac] = func(a uint64) uint64 { return uint64(int64(int32(a))) }
+	unops[0xad] = func(a uint64) uint64 { return uint64(uint32(a)) }
+	unops[0xc0] = func(a uint64) uint64 { return uint64(uint32(int32(int8(a)))) }
+	unops[0xc1] = func(a uint64) uint64 { return uint64(uint32(int32(int16(a)))) }
+	unops[0xc2] = func(a uint64) uint64 { return uint64(int64(int8(a))) }
+	unops[0xc3] = func(a uint64) uint64 { return uint64(int64(int16(a))) }
+	unops[0xc4] = func(a uint64) uint64 { return uint64(int64(int32(a))) }
+}
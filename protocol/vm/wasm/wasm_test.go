@@ -0,0 +1,307 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"chain/errors"
+)
+
+type testFunc struct {
+	typ    FuncType
+	locals []ValueType
+	code   []byte // without the final end
+}
+
+// buildModule assembles a module importing a function
+// "env.fN" of each type in imports, defining funcs, and
+// exporting the last of them as "main". If pages > 0, it
+// has that much memory, initialized with data at offset 16.
+func buildModule(imports []FuncType, funcs []testFunc, pages int, data []byte) []byte {
+	var types, imps, decls, code [][]byte
+	for i, t := range imports {
+		imps = append(imps, cat(name("env"), name(fmt.Sprintf("f%d", i)), []byte{0}, leb(uint64(len(types)))))
+		types = append(types, funcType(t))
+	}
+	for _, f := range funcs {
+		decls = append(decls, leb(uint64(len(types))))
+		types = append(types, funcType(f.typ))
+		var locals [][]byte
+		for _, l := range f.locals {
+			locals = append(locals, []byte{1, byte(l)})
+		}
+		body := cat(vec(locals), f.code, []byte{opEnd})
+		code = append(code, cat(leb(uint64(len(body))), body))
+	}
+	main := uint64(len(imports) + len(funcs) - 1)
+
+	m := []byte("\x00asm\x01\x00\x00\x00")
+	m = append(m, section(secType, vec(types))...)
+	if len(imps) > 0 {
+		m = append(m, section(secImport, vec(imps))...)
+	}
+	m = append(m, section(secFunction, vec(decls))...)
+	if pages > 0 {
+		m = append(m, section(secMemory, cat([]byte{1, 0}, leb(uint64(pages))))...)
+	}
+	m = append(m, section(secExport, vec([][]byte{cat(name("main"), []byte{0}, leb(main))}))...)
+	m = append(m, section(secCode, vec(code))...)
+	if pages > 0 && data != nil {
+		seg := cat([]byte{0, opI32Const, 16, opEnd}, leb(uint64(len(data))), data)
+		m = append(m, section(secData, vec([][]byte{seg}))...)
+	}
+	return m
+}
+
+func cat(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+
+func leb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func vec(items [][]byte) []byte { return cat(leb(uint64(len(items))), cat(items...)) }
+
+func name(s string) []byte { return cat(leb(uint64(len(s))), []byte(s)) }
+
+func section(id byte, content []byte) []byte {
+	return cat([]byte{id}, leb(uint64(len(content))), content)
+}
+
+func funcType(t FuncType) []byte {
+	var params, results [][]byte
+	for _, p := range t.Params {
+		params = append(params, []byte{byte(p)})
+	}
+	for _, r := range t.Results {
+		results = append(results, []byte{byte(r)})
+	}
+	return cat([]byte{0x60}, vec(params), vec(results))
+}
+
+var (
+	noneToI32 = FuncType{Results: []ValueType{I32}}
+	noneToI64 = FuncType{Results: []ValueType{I64}}
+	i32ToI32  = FuncType{Params: []ValueType{I32}, Results: []ValueType{I32}}
+	i64ToI64  = FuncType{Params: []ValueType{I64}, Results: []ValueType{I64}}
+)
+
+func TestRun(t *testing.T) {
+	factorial := testFunc{typ: i64ToI64, code: []byte{
+		opLocalGet, 0, 0x50, // i64.eqz
+		opIf, byte(I64),
+		opI64Const, 1,
+		opElse,
+		opLocalGet, 0,
+		opLocalGet, 0, opI64Const, 1, 0x7d, // i64.sub
+		opCall, 0,
+		0x7e, // i64.mul
+		opEnd,
+	}}
+
+	cases := []struct {
+		name    string
+		imports []FuncType
+		funcs   []testFunc
+		pages   int
+		data    []byte
+		args    []uint64
+		want    uint64
+		wantErr error
+	}{{
+		name:  "add",
+		funcs: []testFunc{{typ: noneToI32, code: []byte{opI32Const, 2, opI32Const, 3, 0x6a}}},
+		want:  5,
+	}, {
+		name:  "i32 wraps",
+		funcs: []testFunc{{typ: noneToI32, code: []byte{opI32Const, 0x7f, opI32Const, 1, 0x6a}}}, // -1 + 1
+		want:  0,
+	}, {
+		name: "recursion",
+		funcs: []testFunc{factorial, {typ: noneToI64, code: []byte{
+			opI64Const, 20, opCall, 0,
+		}}},
+		want: 2432902008176640000,
+	}, {
+		name: "loop",
+		funcs: []testFunc{{typ: noneToI32, locals: []ValueType{I32, I32}, code: []byte{
+			opBlock, 0x40,
+			opLoop, 0x40,
+			opLocalGet, 0, opI32Const, 10, 0x4b, opBrIf, 1, // i > 10
+			opLocalGet, 1, opLocalGet, 0, 0x6a, opLocalSet, 1, // s += i
+			opLocalGet, 0, opI32Const, 1, 0x6a, opLocalSet, 0, // i++
+			opBr, 0,
+			opEnd,
+			opEnd,
+			opLocalGet, 1,
+		}}},
+		want: 55,
+	}, {
+		name: "br_table",
+		funcs: []testFunc{{typ: i32ToI32, code: []byte{
+			opBlock, 0x40, opBlock, 0x40, opBlock, 0x40,
+			opLocalGet, 0, opBrTable, 2, 0, 1, 2,
+			opEnd, opI32Const, 10, opReturn,
+			opEnd, opI32Const, 20, opReturn,
+			opEnd, opI32Const, 30,
+		}}},
+		args: []uint64{1},
+		want: 20,
+	}, {
+		name: "memory",
+		funcs: []testFunc{{typ: noneToI64, code: cat(
+			[]byte{opI32Const, 8, opI64Const}, []byte{0x88, 0x8e, 0x98, 0xa8, 0xc0, 0xe0, 0x80, 0x81, 0x01}, // 0x0102030405060708
+			[]byte{opI64Store, 3, 0},
+			[]byte{opI32Const, 8, opI32Load8U, 0, 1, 0xad}, // i64.extend_i32_u
+		)}},
+		pages: 1,
+		want:  0x07,
+	}, {
+		name:  "data",
+		funcs: []testFunc{{typ: noneToI32, code: []byte{opI32Const, 0, opI32Load8U, 0, 17}}},
+		pages: 1,
+		data:  []byte("hi"),
+		want:  'i',
+	}, {
+		name: "memory.grow",
+		funcs: []testFunc{{typ: noneToI32, code: []byte{
+			opI32Const, 1, opMemoryGrow, 0, opDrop,
+			opI32Const, MaxPages, opMemoryGrow, 0, // fails
+			opMemorySize, 0,
+			0x6a,
+		}}},
+		pages: 1,
+		want:  1, // -1 + 2 pages
+	}, {
+		name:    "memory bounds",
+		funcs:   []testFunc{{typ: noneToI32, code: []byte{opI32Const, 0, opI32Load, 2, 0xfd, 0xff, 0x03}}},
+		pages:   1,
+		wantErr: ErrMemoryBounds,
+	}, {
+		name:    "divide by zero",
+		funcs:   []testFunc{{typ: noneToI32, code: []byte{opI32Const, 1, opI32Const, 0, 0x6e}}},
+		wantErr: ErrDivZero,
+	}, {
+		name:    "unreachable",
+		funcs:   []testFunc{{typ: noneToI32, code: []byte{opUnreachable}}},
+		wantErr: ErrTrap,
+	}, {
+		name:    "stack underflow",
+		funcs:   []testFunc{{typ: noneToI32, code: []byte{0x6a}}},
+		wantErr: ErrTrap,
+	}, {
+		name:    "run limit",
+		funcs:   []testFunc{{typ: noneToI32, code: []byte{opLoop, 0x40, opBr, 0, opEnd, opI32Const, 0}}},
+		wantErr: ErrRunLimitExceeded,
+	}, {
+		name:    "call depth",
+		funcs:   []testFunc{{typ: noneToI32, code: []byte{opCall, 0}}},
+		wantErr: ErrTrap,
+	}, {
+		name:    "host function",
+		imports: []FuncType{i64ToI64},
+		funcs:   []testFunc{{typ: noneToI64, code: []byte{opI64Const, 21, opCall, 0}}},
+		want:    42,
+	}}
+
+	double := &HostFunc{
+		Type: i64ToI64,
+		Cost: 5,
+		Call: func(mem *Memory, args []uint64) ([]uint64, error) {
+			return []uint64{2 * args[0]}, nil
+		},
+	}
+	imports := Imports{"env": {"f0": double}}
+
+	for _, c := range cases {
+		m, err := Decode(buildModule(c.imports, c.funcs, c.pages, c.data))
+		if err != nil {
+			t.Errorf("%s: Decode error %s", c.name, err)
+			continue
+		}
+		got, _, err := m.Run(imports, "main", c.args, 100000)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("%s: Run error = %v want %v", c.name, err, c.wantErr)
+			continue
+		}
+		if c.wantErr == nil && (len(got) != 1 || got[0] != c.want) {
+			t.Errorf("%s: Run = %v want [%d]", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRunCost(t *testing.T) {
+	called := false
+	host := &HostFunc{
+		Type: noneToI32,
+		Cost: 100,
+		Call: func(mem *Memory, args []uint64) ([]uint64, error) {
+			called = true
+			return []uint64{1}, nil
+		},
+	}
+	m, err := Decode(buildModule([]FuncType{noneToI32}, []testFunc{{typ: noneToI32, code: []byte{opCall, 0}}}, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := Imports{"env": {"f0": host}}
+
+	// One page of memory, one host call and
+	// the two instructions call and end.
+	const want = memoryPageCost + 100 + 2
+	_, left, err := m.Run(imports, "main", nil, want+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left != 10 {
+		t.Errorf("run limit left = %d want 10", left)
+	}
+
+	called = false
+	_, _, err = m.Run(imports, "main", nil, memoryPageCost+100)
+	if err != ErrRunLimitExceeded {
+		t.Errorf("Run error = %v want %v", err, ErrRunLimitExceeded)
+	}
+	if called {
+		t.Error("host function called without enough run limit")
+	}
+
+	_, _, err = m.Run(Imports{}, "main", nil, want)
+	if errors.Root(err) != ErrBadModule {
+		t.Errorf("Run with missing import error = %v want %v", err, ErrBadModule)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	good := buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{opI32Const, 1}}}, 0, nil)
+	_, err := Decode(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]byte{
+		"bad header":    append([]byte("\x00asm\x02\x00\x00\x00"), good[8:]...),
+		"truncated":     good[:len(good)-1],
+		"float":         buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{0x43, 0, 0, 0, 0, opDrop, opI32Const, 1}}}, 0, nil),
+		"bad local":     buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{opLocalGet, 0}}}, 0, nil),
+		"bad label":     buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{opBr, 1}}}, 0, nil),
+		"no memory":     buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{opI32Const, 0, opI32Load, 2, 0}}}, 0, nil),
+		"unclosed":      buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{opBlock, 0x40, opI32Const, 1}}}, 0, nil),
+		"big memory":    buildModule(nil, []testFunc{{typ: noneToI32, code: []byte{opI32Const, 1}}}, MaxPages+1, nil),
+		"start section": append(append([]byte{}, good...), section(secStart, []byte{0})...),
+	}
+	for name, b := range cases {
+		_, err := Decode(b)
+		if errors.Root(err) != ErrBadModule {
+			t.Errorf("%s: Decode error = %v want %v", name, err, ErrBadModule)
+		}
+	}
+}
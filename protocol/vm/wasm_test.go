@@ -0,0 +1,49 @@
+package vm
+
+import (
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+func TestVerifyTxInputWASM(t *testing.T) {
+	// A module whose verify function
+	// returns chain.input_amount() == 5.
+	prog := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // header
+		0x01, 0x09, 0x02, 0x60, 0x00, 0x01, 0x7e, 0x60, 0x00, 0x01, 0x7f, // types () i64 and () i32
+		0x02, 0x16, 0x01, 0x05, 'c', 'h', 'a', 'i', 'n', // import chain.input_amount
+		0x0c, 'i', 'n', 'p', 'u', 't', '_', 'a', 'm', 'o', 'u', 'n', 't', 0x00, 0x00,
+		0x03, 0x02, 0x01, 0x01, // one function of type 1
+		0x07, 0x0a, 0x01, 0x06, 'v', 'e', 'r', 'i', 'f', 'y', 0x00, 0x01, // export verify
+		0x0a, 0x09, 0x01, 0x07, 0x00, // code
+		0x10, 0x00, // call input_amount
+		0x42, 0x05, // i64.const 5
+		0x51, // i64.eq
+		0x0b, // end
+	}
+
+	verify := func(amount uint64) (bool, error) {
+		in := bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, amount, prog, nil)
+		in.TypedInput.(*bc.SpendInput).VMVersion = VersionWASM
+		tx := bc.NewTx(bc.TxData{Version: 2, Inputs: []*bc.TxInput{in}})
+		return VerifyTxInput(tx, 0)
+	}
+
+	_, err := verify(5)
+	if err != ErrUnsupportedVM {
+		t.Fatalf("VerifyTxInput without EnableWASM err = %v want %v", err, ErrUnsupportedVM)
+	}
+
+	EnableWASM()
+	defer delete(interpreters, VersionWASM)
+
+	ok, err := verify(5)
+	if err != nil || !ok {
+		t.Errorf("VerifyTxInput(amount 5) = %v, %v want true, nil", ok, err)
+	}
+	ok, err = verify(6)
+	if err != nil || ok {
+		t.Errorf("VerifyTxInput(amount 6) = %v, %v want false, nil", ok, err)
+	}
+}
@@ -1,6 +1,8 @@
 package vmutil
 
 import (
+	"bytes"
+
 	"chain/crypto/ed25519"
 	"chain/errors"
 	"chain/protocol/vm"
@@ -49,7 +51,9 @@ func ParseBlockMultiSigProgram(script []byte) ([]ed25519.PublicKey, int, error)
 	if err != nil {
 		return nil, 0, errors.Wrap(ErrMultisigFormat, "parsing npubkeys")
 	}
-	if int(npubkeys) != len(pops)-4 {
+	// Count backwards from the end, allowing a commitment to
+	// block limits at the beginning (see BlockLimitsProgram).
+	if int(npubkeys) > len(pops)-4 || pops[len(pops)-4-int(npubkeys)].Op != vm.OP_BLOCKSIGHASH {
 		return nil, 0, vm.ErrShortProgram
 	}
 	nrequired, err := vm.AsInt64(pops[len(pops)-3].Data)
@@ -73,6 +77,58 @@ func ParseBlockMultiSigProgram(script []byte) ([]ed25519.PublicKey, int, error)
 	return pubkeys, int(nrequired), nil
 }
 
+// BlockLimits are consensus limits on the size of a block.
+// MaxBytes limits its serialized size, not counting the block
+// witness, and MaxTxs the number of its transactions.
+// Zero means no limit.
+type BlockLimits struct {
+	MaxBytes uint64
+	MaxTxs   uint64
+}
+
+// blockLimitsTag marks the commitment to block limits
+// at the beginning of a consensus program.
+var blockLimitsTag = []byte("blocklimits")
+
+// BlockLimitsProgram returns prog, a consensus program,
+// prefixed with a commitment to limits that doesn't affect its
+// result: <"blocklimits"> <maxbytes> <maxtxs> 2DROP DROP.
+// Since each block carries the consensus program for the next,
+// the limits hold for every block after the one it's in, unless
+// a block changes the program. If limits is zero, it returns prog.
+func BlockLimitsProgram(limits BlockLimits, prog []byte) []byte {
+	if limits == (BlockLimits{}) {
+		return prog
+	}
+	builder := NewBuilder()
+	builder.AddData(blockLimitsTag)
+	builder.AddInt64(int64(limits.MaxBytes)).AddInt64(int64(limits.MaxTxs))
+	builder.AddOp(vm.OP_2DROP).AddOp(vm.OP_DROP)
+	return append(builder.Program, prog...)
+}
+
+// ParseBlockLimits returns the block limits committed to by a
+// consensus program made with BlockLimitsProgram, or zero
+// limits if it has none.
+func ParseBlockLimits(prog []byte) BlockLimits {
+	pops, err := vm.ParseProgram(prog)
+	if err != nil || len(pops) < 5 {
+		return BlockLimits{}
+	}
+	if !bytes.Equal(pops[0].Data, blockLimitsTag) || pops[3].Op != vm.OP_2DROP || pops[4].Op != vm.OP_DROP {
+		return BlockLimits{}
+	}
+	maxBytes, err := vm.AsInt64(pops[1].Data)
+	if err != nil || maxBytes < 0 {
+		return BlockLimits{}
+	}
+	maxTxs, err := vm.AsInt64(pops[2].Data)
+	if err != nil || maxTxs < 0 {
+		return BlockLimits{}
+	}
+	return BlockLimits{MaxBytes: uint64(maxBytes), MaxTxs: uint64(maxTxs)}
+}
+
 func P2SPMultiSigProgram(pubkeys []ed25519.PublicKey, nrequired int) ([]byte, error) {
 	err := checkMultiSigParams(int64(nrequired), int64(len(pubkeys)))
 	if err != nil {
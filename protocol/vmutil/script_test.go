@@ -108,3 +108,24 @@ func TestBlockMultisig(t *testing.T) {
 		t.Errorf("expected second pubkey to be %x, got %x", pub2, pubs[1])
 	}
 }
+
+func TestBlockLimitsProgram(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	prog, _ := BlockMultiSigProgram([]ed25519.PublicKey{pub}, 1)
+	if got := ParseBlockLimits(prog); got != (BlockLimits{}) {
+		t.Errorf("ParseBlockLimits(no limits) = %+v want zero", got)
+	}
+
+	limits := BlockLimits{MaxBytes: 1 << 20, MaxTxs: 500}
+	limited := BlockLimitsProgram(limits, prog)
+	if got := ParseBlockLimits(limited); got != limits {
+		t.Errorf("ParseBlockLimits = %+v want %+v", got, limits)
+	}
+	pubs, n, err := ParseBlockMultiSigProgram(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(pubs) != 1 || !bytes.Equal(pubs[0], pub) {
+		t.Errorf("ParseBlockMultiSigProgram = %x, %d want [%x], 1", pubs, n, pub)
+	}
+}